@@ -0,0 +1,154 @@
+package geerpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ACLRule 是一条访问控制规则，为匹配 Identity 的调用方允许或拒绝一组方法。
+// Identity 为精确的身份，或以 "*" 结尾的前缀（单独的 "*" 匹配任何已认证的调用方），
+// 空字符串只匹配未认证的调用方。方法模式为 "Service.Method"、"Service.*" 或 "*"
+type ACLRule struct {
+	Identity string   `json:"identity"`
+	Allow    []string `json:"allow,omitempty"`
+	Deny     []string `json:"deny,omitempty"`
+}
+
+// ACL 是基于规则的访问控制，实现了 Authorizer 接口，通过 Server.SetAuthorizer 在认证之后、分发之前生效。
+// 所有匹配调用方身份的规则中，任一 Deny 模式匹配则拒绝，否则任一 Allow 模式匹配则允许，都不匹配时拒绝。
+// 规则可以通过 SetRules 在运行时替换，也可以通过 LoadFile 和 WatchFile 从 JSON 文件加载。
+// 注意内置的 Health 服务同样受 ACL 控制，使用注册中心的主动健康检查时需要为其放行
+type ACL struct {
+	mu      sync.RWMutex // 保护以下字段
+	rules   []ACLRule
+	modTime time.Time // 上次加载时文件的修改时间
+}
+
+var _ Authorizer = (*ACL)(nil)
+
+// NewACL 创建一个使用给定规则的 ACL 实例
+func NewACL(rules ...ACLRule) *ACL {
+	return &ACL{rules: rules}
+}
+
+// SetRules 替换所有规则
+func (a *ACL) SetRules(rules []ACLRule) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = rules
+}
+
+// Rules 返回当前的规则
+func (a *ACL) Rules() []ACLRule {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	rules := make([]ACLRule, len(a.rules))
+	copy(rules, a.rules)
+	return rules
+}
+
+// Authorize 按规则检查 identity 是否可以调用 serviceMethod
+func (a *ACL) Authorize(identity, serviceMethod string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	allowed := false
+	for _, rule := range a.rules {
+		if !aclIdentityMatch(rule.Identity, identity) {
+			continue
+		}
+		for _, pattern := range rule.Deny {
+			if aclMethodMatch(pattern, serviceMethod) {
+				return ErrPermissionDenied
+			}
+		}
+		for _, pattern := range rule.Allow {
+			if aclMethodMatch(pattern, serviceMethod) {
+				allowed = true
+			}
+		}
+	}
+	if !allowed {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// aclIdentityMatch 判断 identity 是否匹配规则的身份模式，空模式只匹配未认证的调用方
+func aclIdentityMatch(pattern, identity string) bool {
+	if pattern == "" {
+		return identity == ""
+	}
+	return matchIdentity(pattern, identity)
+}
+
+// aclMethodMatch 判断 serviceMethod 是否匹配方法模式
+func aclMethodMatch(pattern, serviceMethod string) bool {
+	if pattern == "*" || pattern == serviceMethod {
+		return true
+	}
+	if strings.HasSuffix(pattern, ".*") {
+		return strings.HasPrefix(serviceMethod, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+// LoadFile 在文件发生变化时从 JSON 文件加载规则，文件内容为 ACLRule 数组，例如：
+//
+//	[{"identity": "spiffe://example.org/ns/prod/*", "allow": ["Account.*"], "deny": ["Account.Delete"]}]
+//
+// 文件内容无效时保留原有的规则并返回错误
+func (a *ACL) LoadFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	a.mu.RLock()
+	unchanged := info.ModTime().Equal(a.modTime)
+	a.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []ACLRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return errors.New("rpc acl: invalid rule file " + path + ": " + err.Error())
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = rules
+	a.modTime = info.ModTime()
+	return nil
+}
+
+// WatchFile 每隔 interval（0 表示默认的 2 秒）检查一次文件，文件变化后重新加载规则，
+// 返回的 stop 函数用于停止检查
+func (a *ACL) WatchFile(path string, interval time.Duration) (stop func()) {
+	if interval == 0 {
+		interval = time.Second * 2
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := a.LoadFile(path); err != nil {
+					DefaultLogger().Error("rpc acl: reload rule file err", "err", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,71 @@
+package geerpc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestACL_Authorize(t *testing.T) {
+	acl := NewACL(
+		ACLRule{Identity: "spiffe://prod/*", Allow: []string{"Account.*"}},
+		ACLRule{Identity: "spiffe://prod/batch", Deny: []string{"Account.Delete"}},
+		ACLRule{Identity: "", Allow: []string{"Health.Check"}},
+	)
+	cases := []struct {
+		identity, method string
+		allowed          bool
+	}{
+		{"spiffe://prod/web", "Account.Delete", true},
+		{"spiffe://prod/batch", "Account.Get", true},
+		{"spiffe://prod/batch", "Account.Delete", false}, // 拒绝优先于其他规则的允许
+		{"spiffe://dev/web", "Account.Get", false},       // 没有匹配的规则时拒绝
+		{"", "Health.Check", true},
+		{"spiffe://prod/web", "Health.Check", false}, // 空身份的规则只匹配未认证的调用方
+	}
+	for _, c := range cases {
+		err := acl.Authorize(c.identity, c.method)
+		if (err == nil) != c.allowed {
+			t.Fatalf("%s calling %s: expect allowed=%v, got %v", c.identity, c.method, c.allowed, err)
+		}
+	}
+}
+
+func TestACL_LoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "acl.json")
+	_ = ioutil.WriteFile(path, []byte(`[{"identity": "*", "allow": ["*"], "deny": ["Bar.Timeout"]}]`), 0644)
+
+	acl := NewACL()
+	if err := acl.LoadFile(path); err != nil || len(acl.Rules()) != 1 {
+		t.Fatalf("expect 1 rule, got %v, err %v", acl.Rules(), err)
+	}
+	server := newAuthServer(StaticTokens{"good": "alice"})
+	server.SetAuthorizer(acl)
+	client, err := DialInProc(server, &Option{Credentials: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var identity string
+	if err := client.Call(context.Background(), "Who.Identity", 1, &identity); err != nil {
+		t.Fatal(err)
+	}
+
+	// 无效的规则文件不会替换原有的规则
+	_ = ioutil.WriteFile(path, []byte(`not json`), 0644)
+	_ = os.Chtimes(path, acl.modTime.Add(1e9), acl.modTime.Add(1e9))
+	if err := acl.LoadFile(path); err == nil || len(acl.Rules()) != 1 {
+		t.Fatalf("expect an error and the old rules kept, got %v, err %v", acl.Rules(), err)
+	}
+	acl.SetRules([]ACLRule{{Identity: "bob", Allow: []string{"*"}}})
+	if err := client.Call(context.Background(), "Who.Identity", 1, &identity); err == nil || err.Error() != ErrPermissionDenied.Error() {
+		t.Fatalf("expect ErrPermissionDenied after the rules change, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package geerpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// adminStatus 是 defaultAdminPath 返回的可调整参数的当前值
+type adminStatus struct {
+	Readiness     string   `json:"readiness"`
+	Inflight      int      `json:"inflight"`
+	Connections   int      `json:"connections"`
+	LogLevel      string   `json:"log_level,omitempty"`
+	RateBurst     int      `json:"rate_limit_burst"`
+	RatePerSecond int      `json:"rate_limit_per_second"`
+	HandleTimeout string   `json:"handle_timeout"`
+	Registrars    []string `json:"registrars,omitempty"`
+}
+
+// HandleAdminHTTP 在 mux 上挂载运行时管理接口，mux 为 nil 时使用 http.DefaultServeMux。
+// 管理接口可以改变服务器的行为，authorize 是必需的（例如 BearerToken），为 nil 时不挂载任何接口，
+// authorize 返回 false 的请求得到 401。修改类的接口只接受 POST，每次修改都会记录日志：
+//   - defaultAdminPath：当前的就绪状态、日志级别、速率限制和超时
+//   - defaultAdminPath + "/drain"：就绪状态设置为 HealthDraining 并从注册中心注销，不再接收新的流量
+//   - defaultAdminPath + "/undrain"：就绪状态恢复为 HealthServing 并重新登记到注册中心
+//   - defaultAdminPath + "/deregister"：只从注册中心注销，不改变就绪状态
+//   - defaultAdminPath + "/loglevel?level=debug"：修改日志级别，Logger 需要实现 LevelLogger
+//   - defaultAdminPath + "/ratelimit?burst=20&per_second=5"：修改每个连接的请求速率限制，参见 SetConnRateLimit
+//   - defaultAdminPath + "/timeout?handle=5s"：修改服务端处理请求的超时时间，参见 SetHandleTimeout
+//   - defaultAdminPath + "/goroutines"：所有协程的调用栈（GET）
+//   - defaultAdminPath + "/connections"：每个连接的统计数据（GET）
+//
+// 注册中心的注销和重新登记通过实现了 RegistrarPlugin 的插件完成，例如 registry.NewPlugin
+func (server *Server) HandleAdminHTTP(mux *http.ServeMux, authorize func(req *http.Request) bool) {
+	if authorize == nil {
+		server.log().Error("rpc server: admin http requires an authorize function, not mounted")
+		return
+	}
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	guard := func(method string, h http.HandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !authorize(req) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="geerpc admin"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if method != "" && req.Method != method {
+				w.Header().Set("Allow", method)
+				http.Error(w, "405 must "+method, http.StatusMethodNotAllowed)
+				return
+			}
+			h(w, req)
+		})
+	}
+	mux.Handle(defaultAdminPath, guard(http.MethodGet, server.serveAdminStatus))
+	mux.Handle(defaultAdminPath+"/drain", guard(http.MethodPost, server.serveAdminDrain))
+	mux.Handle(defaultAdminPath+"/undrain", guard(http.MethodPost, server.serveAdminUndrain))
+	mux.Handle(defaultAdminPath+"/deregister", guard(http.MethodPost, server.serveAdminDeregister))
+	mux.Handle(defaultAdminPath+"/loglevel", guard(http.MethodPost, server.serveAdminLogLevel))
+	mux.Handle(defaultAdminPath+"/ratelimit", guard(http.MethodPost, server.serveAdminRateLimit))
+	mux.Handle(defaultAdminPath+"/timeout", guard(http.MethodPost, server.serveAdminTimeout))
+	mux.Handle(defaultAdminPath+"/goroutines", guard(http.MethodGet, serveGoroutines))
+	mux.Handle(defaultAdminPath+"/connections", guard(http.MethodGet, server.serveConnections))
+	server.log().Info("rpc server admin path", "path", defaultAdminPath)
+}
+
+// serveAdminStatus 以 JSON 格式输出可调整参数的当前值
+func (server *Server) serveAdminStatus(w http.ResponseWriter, _ *http.Request) {
+	rate := server.currentConnRate()
+	status := adminStatus{
+		Readiness:     server.Readiness(),
+		Inflight:      server.Inflight(),
+		Connections:   len(server.Connections()),
+		RateBurst:     rate.burst,
+		RatePerSecond: rate.perSecond,
+		HandleTimeout: server.handleTimeout(0).String(),
+	}
+	if l, ok := server.log().(LevelLogger); ok {
+		status.LogLevel = l.Level().String()
+	}
+	for _, p := range server.registrars() {
+		status.Registrars = append(status.Registrars, p.Name())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+func (server *Server) serveAdminDrain(w http.ResponseWriter, req *http.Request) {
+	server.SetServingStatus(HealthDraining)
+	server.log().Warn("rpc server admin: drain", "remote", req.RemoteAddr)
+	server.adminDeregister(w, req)
+}
+
+func (server *Server) serveAdminUndrain(w http.ResponseWriter, req *http.Request) {
+	for _, p := range server.registrars() {
+		p.Reregister()
+	}
+	server.SetServingStatus(HealthServing)
+	server.log().Warn("rpc server admin: undrain", "remote", req.RemoteAddr)
+	writeAdminOK(w)
+}
+
+func (server *Server) serveAdminDeregister(w http.ResponseWriter, req *http.Request) {
+	server.log().Warn("rpc server admin: deregister", "remote", req.RemoteAddr)
+	server.adminDeregister(w, req)
+}
+
+// adminDeregister 调用所有 RegistrarPlugin 的 Deregister，全部成功时返回 200，否则返回 502 和第一个错误
+func (server *Server) adminDeregister(w http.ResponseWriter, req *http.Request) {
+	var err error
+	for _, p := range server.registrars() {
+		if e := p.Deregister(); e != nil {
+			server.log().Error("rpc server admin: deregister error", "plugin", p.Name(), "remote", req.RemoteAddr, "err", e)
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeAdminOK(w)
+}
+
+func (server *Server) serveAdminLogLevel(w http.ResponseWriter, req *http.Request) {
+	l, ok := server.log().(LevelLogger)
+	if !ok {
+		http.Error(w, "501 logger does not support levels", http.StatusNotImplemented)
+		return
+	}
+	level, err := ParseLevel(req.FormValue("level"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	old := l.Level()
+	l.SetLevel(level)
+	// 在修改之后用 Warn 记录，保证调高级别时也能看到这条日志
+	server.log().Warn("rpc server admin: log level changed", "remote", req.RemoteAddr, "from", old.String(), "to", level.String())
+	writeAdminOK(w)
+}
+
+func (server *Server) serveAdminRateLimit(w http.ResponseWriter, req *http.Request) {
+	burst, err1 := strconv.Atoi(req.FormValue("burst"))
+	perSecond, err2 := strconv.Atoi(req.FormValue("per_second"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "400 burst and per_second must be integers", http.StatusBadRequest)
+		return
+	}
+	server.SetConnRateLimit(burst, perSecond)
+	server.log().Warn("rpc server admin: connection rate limit changed", "remote", req.RemoteAddr, "burst", burst, "per_second", perSecond)
+	writeAdminOK(w)
+}
+
+func (server *Server) serveAdminTimeout(w http.ResponseWriter, req *http.Request) {
+	d, err := time.ParseDuration(req.FormValue("handle"))
+	if err != nil || d < 0 {
+		http.Error(w, "400 handle must be a non-negative duration such as 5s", http.StatusBadRequest)
+		return
+	}
+	server.SetHandleTimeout(d)
+	server.log().Warn("rpc server admin: handle timeout changed", "remote", req.RemoteAddr, "timeout", d)
+	writeAdminOK(w)
+}
+
+// registrars 返回实现了 RegistrarPlugin 的插件
+func (server *Server) registrars() []RegistrarPlugin {
+	var ps []RegistrarPlugin
+	for _, p := range server.plugins {
+		if p, ok := p.(RegistrarPlugin); ok {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// serveGoroutines 以文本格式输出所有协程的调用栈，与 pprof 的 goroutine?debug=2 相同
+func serveGoroutines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func writeAdminOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,134 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 审计记录的结果
+const (
+	AuditOK    = "ok"    // 调用成功
+	AuditError = "error" // 调用返回错误或处理超时
+)
+
+// maxAuditSummary 是参数摘要的最大长度，超出部分被截断
+const maxAuditSummary = 256
+
+// AuditRecord 是一次调用的审计记录
+type AuditRecord struct {
+	Time          time.Time     `json:"time"`               // 调用开始的时间
+	Identity      string        `json:"identity,omitempty"` // 调用方身份，由认证机制填充，未认证时为空
+	Remote        string        `json:"remote"`             // 客户端地址
+	ServiceMethod string        `json:"method"`
+	RequestID     string        `json:"request_id,omitempty"`
+	ArgsSummary   string        `json:"args_summary"` // 参数的文本摘要，最多 256 个字符
+	ArgsHash      string        `json:"args_hash"`    // 参数 gob 编码的 SHA-256，用于比对而无需记录完整参数
+	Result        string        `json:"result"`       // AuditOK 或 AuditError
+	Error         string        `json:"error,omitempty"`
+	Duration      time.Duration `json:"duration"`
+}
+
+// AuditSink 接收审计记录。Audit 在请求处理完成后同步调用，耗时的操作（如写入远程存储）应自行异步处理
+type AuditSink interface {
+	Audit(rec *AuditRecord)
+}
+
+// AuditFunc 将普通函数适配为 AuditSink
+type AuditFunc func(rec *AuditRecord)
+
+// Audit 调用 f(rec)
+func (f AuditFunc) Audit(rec *AuditRecord) { f(rec) }
+
+// JSONAuditSink 将每条审计记录以一行 JSON 写入 io.Writer
+type JSONAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONAuditSink 创建一个写入 w 的 JSONAuditSink
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{w: w}
+}
+
+// Audit 将审计记录编码为 JSON 并写入
+func (s *JSONAuditSink) Audit(rec *AuditRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(append(data, '\n'))
+}
+
+// SetAuditSink 设置接收审计记录的 AuditSink，每个处理完的请求都会生成一条记录，传入 nil 关闭审计。
+// 应在开始服务之前调用
+func (server *Server) SetAuditSink(s AuditSink) {
+	server.audit = s
+}
+
+// identityKey 是 context 中保存调用方身份的键
+type identityKey struct{}
+
+// WithIdentity 返回携带调用方身份的 context
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext 返回 context 中的调用方身份，未认证时返回空字符串
+func IdentityFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(identityKey{}).(string)
+	return identity
+}
+
+// auditRequest 生成请求的审计记录并发送给 AuditSink
+func (server *Server) auditRequest(req *request, start time.Time, d time.Duration, errMsg string) {
+	if server.audit == nil {
+		return
+	}
+	rec := &AuditRecord{
+		Time:          start,
+		Identity:      req.identity,
+		Remote:        req.remote,
+		ServiceMethod: req.h.ServiceMethod,
+		RequestID:     req.h.RequestID,
+		ArgsSummary:   argsSummary(req.argv.Interface()),
+		ArgsHash:      argsHash(req.argv.Interface()),
+		Result:        AuditOK,
+		Duration:      d,
+	}
+	if errMsg != "" {
+		rec.Result = AuditError
+		rec.Error = errMsg
+	}
+	server.audit.Audit(rec)
+}
+
+// argsSummary 返回参数的文本摘要，超过 maxAuditSummary 时截断。指针输出其指向的值而不是地址
+func argsSummary(v interface{}) string {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		v = rv.Elem().Interface()
+	}
+	s := fmt.Sprintf("%+v", v)
+	if len(s) > maxAuditSummary {
+		s = s[:maxAuditSummary] + "..."
+	}
+	return s
+}
+
+// argsHash 返回参数 gob 编码后的 SHA-256，无法编码时返回空字符串
+func argsHash(v interface{}) string {
+	h := sha256.New()
+	if err := gob.NewEncoder(h).Encode(v); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
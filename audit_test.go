@@ -0,0 +1,108 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_SetAuditSink(t *testing.T) {
+	var mu sync.Mutex
+	var records []*AuditRecord
+	server := newAuthServer(StaticTokens{"good": "alice"})
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	server.SetAuditSink(AuditFunc(func(rec *AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, rec)
+	}))
+	client, err := DialInProc(server, &Option{Credentials: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	_ = client.Call(WithRequestID(context.Background(), "req-1"), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_ = client.Call(context.Background(), "Flaky.Fail", 7, &reply)
+	_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+
+	// 审计记录在响应发出之后生成
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		n := len(records)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 3 {
+		t.Fatalf("expect one record per request, got %d", len(records))
+	}
+	// 审计在各自的请求处理完成后进行，记录的顺序不固定
+	var ok, failed, again *AuditRecord
+	for _, rec := range records {
+		switch {
+		case rec.ServiceMethod == "Flaky.Fail":
+			failed = rec
+		case rec.RequestID == "req-1":
+			ok = rec
+		default:
+			again = rec
+		}
+	}
+	if ok == nil || failed == nil || again == nil {
+		t.Fatalf("expect a record for each request, got %+v", records)
+	}
+	if ok.ServiceMethod != "Foo.Sum" || ok.Identity != "alice" || ok.RequestID != "req-1" || ok.Remote == "" ||
+		ok.Result != AuditOK || ok.Error != "" || ok.ArgsSummary != "{Num1:1 Num2:2}" || len(ok.ArgsHash) != 64 || ok.Time.IsZero() {
+		t.Fatalf("unexpected record %+v", ok)
+	}
+	if failed.ServiceMethod != "Flaky.Fail" || failed.Result != AuditError || failed.Error != "boom" || failed.ArgsSummary != "7" {
+		t.Fatalf("unexpected record %+v", failed)
+	}
+	// 相同的参数得到相同的哈希
+	if again.ArgsHash != ok.ArgsHash || failed.ArgsHash == ok.ArgsHash {
+		t.Fatalf("expect the hash to identify the arguments, got %s, %s, %s", ok.ArgsHash, failed.ArgsHash, again.ArgsHash)
+	}
+}
+
+func TestArgsSummary(t *testing.T) {
+	if s := argsSummary(strings.Repeat("a", maxAuditSummary+10)); len(s) != maxAuditSummary+3 || !strings.HasSuffix(s, "...") {
+		t.Fatalf("expect the summary to be truncated, got %d bytes", len(s))
+	}
+	n := 3
+	if s := argsSummary(&n); s != "3" {
+		t.Fatalf("expect the value a pointer points to, got %s", s)
+	}
+	if s := argsSummary(&Args{Num1: 1}); s != "{Num1:1 Num2:0}" {
+		t.Fatalf("expect the value a pointer points to, got %s", s)
+	}
+	if h := argsHash(make(chan int)); h != "" {
+		t.Fatalf("expect an empty hash for unencodable arguments, got %s", h)
+	}
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	var buf syncBuffer
+	sink := NewJSONAuditSink(&buf)
+	sink.Audit(&AuditRecord{ServiceMethod: "Foo.Sum", Result: AuditOK, Duration: time.Second})
+	sink.Audit(&AuditRecord{ServiceMethod: "Flaky.Fail", Result: AuditError, Error: "boom"})
+	records := buf.records(t)
+	if len(records) != 2 || records[0]["method"] != "Foo.Sum" || records[0]["duration"] != float64(time.Second) ||
+		records[0]["error"] != nil || records[1]["error"] != "boom" {
+		t.Fatalf("unexpected records %v", records)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal([]byte(strings.SplitN(buf.buf.String(), "\n", 2)[0]), &rec); err != nil || rec.Duration != time.Second {
+		t.Fatalf("expect records to decode back, got %+v, %v", rec, err)
+	}
+}
@@ -0,0 +1,106 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrUnauthenticated 表示设置了 TokenValidator 的服务器收到了没有有效凭证的请求
+var ErrUnauthenticated = errors.New("rpc server: unauthenticated")
+
+// TokenValidator 验证客户端提供的凭证，返回调用方身份，凭证无效时返回错误
+type TokenValidator interface {
+	Validate(token string) (identity string, err error)
+}
+
+// TokenValidatorFunc 将普通函数适配为 TokenValidator
+type TokenValidatorFunc func(token string) (identity string, err error)
+
+// Validate 调用 f(token)
+func (f TokenValidatorFunc) Validate(token string) (string, error) { return f(token) }
+
+// StaticTokens 是一个使用静态密钥的 TokenValidator，键为令牌，值为令牌对应的调用方身份
+type StaticTokens map[string]string
+
+// Validate 以恒定时间比较令牌，返回匹配的令牌对应的身份
+func (s StaticTokens) Validate(token string) (string, error) {
+	identity, found := "", false
+	for t, id := range s {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			identity, found = id, true
+		}
+	}
+	if !found {
+		return "", errors.New("rpc server: invalid token")
+	}
+	return identity, nil
+}
+
+// SetTokenValidator 设置服务器验证凭证使用的 TokenValidator。设置后，每个请求都必须经过认证：
+// 连接握手时携带 Option.Credentials，或者调用时通过 WithCredentials 携带单次调用的凭证，
+// 也可以是经过验证的 mTLS 客户端证书。握手凭证无效的连接会被直接关闭，
+// 没有有效凭证的调用返回 ErrUnauthenticated。为 nil（默认）时不做认证，应在开始服务之前调用
+func (server *Server) SetTokenValidator(v TokenValidator) {
+	server.validator = v
+}
+
+// authenticateConn 验证握手时携带的凭证，验证通过时将得到的身份记录到连接上
+func (server *Server) authenticateConn(conn *connTracker, credentials string) error {
+	if server.validator == nil || credentials == "" {
+		return nil
+	}
+	if server.lockout.locked(conn.remote) {
+		return ErrUnauthenticated
+	}
+	identity, err := server.validator.Validate(credentials)
+	if err != nil {
+		server.authFailed(conn.remote)
+		return err
+	}
+	server.lockout.succeed(conn.remote)
+	server.connsMu.Lock()
+	defer server.connsMu.Unlock()
+	conn.identity = identity
+	conn.authenticated = true
+	return nil
+}
+
+// authenticate 确定请求的调用方身份：单次调用的凭证优先于连接的身份
+func (server *Server) authenticate(req *request, conn *connTracker) error {
+	req.identity = conn.identity
+	if server.validator == nil {
+		return nil
+	}
+	if req.h.Token == "" {
+		if conn.authenticated || conn.identity != "" {
+			return nil
+		}
+		return ErrUnauthenticated
+	}
+	if server.lockout.locked(req.remote) {
+		return ErrUnauthenticated
+	}
+	identity, err := server.validator.Validate(req.h.Token)
+	if err != nil {
+		server.authFailed(req.remote)
+		server.log().Warn("rpc server: invalid credentials", "method", req.h.ServiceMethod, "remote", req.remote, "err", err)
+		return ErrUnauthenticated
+	}
+	req.identity = identity
+	return nil
+}
+
+// credentialsKey 是 context 中保存单次调用凭证的键
+type credentialsKey struct{}
+
+// WithCredentials 返回携带单次调用凭证的 context，Client.Call 会将凭证随请求头发送给服务端
+func WithCredentials(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, token)
+}
+
+// credentialsFromContext 返回 context 中的单次调用凭证
+func credentialsFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(credentialsKey{}).(string)
+	return token
+}
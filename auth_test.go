@@ -0,0 +1,107 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Who 返回调用方的身份，用于测试认证和授权
+type Who int
+
+func (w Who) Identity(ctx context.Context, argv int, reply *string) error {
+	*reply = IdentityFromContext(ctx)
+	return nil
+}
+
+// newAuthServer 创建一个注册了 Who 的服务器，使用 v 验证凭证
+func newAuthServer(v TokenValidator) *Server {
+	server := NewServer()
+	var w Who
+	_ = server.Register(&w)
+	server.SetTokenValidator(v)
+	return server
+}
+
+// signHS256 生成使用 secret 签名的 HS256 JWT
+func signHS256(secret []byte, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestServer_TokenValidator(t *testing.T) {
+	server := newAuthServer(StaticTokens{"good": "alice", "other": "bob"})
+
+	// 握手凭证确定连接的身份
+	client, err := DialInProc(server, &Option{Credentials: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var identity string
+	if err := client.Call(context.Background(), "Who.Identity", 1, &identity); err != nil || identity != "alice" {
+		t.Fatalf("expect identity alice, got %q, err %v", identity, err)
+	}
+	// 单次调用的凭证优先于连接的身份
+	if err := client.Call(WithCredentials(context.Background(), "other"), "Who.Identity", 1, &identity); err != nil || identity != "bob" {
+		t.Fatalf("expect identity bob, got %q, err %v", identity, err)
+	}
+	if err := client.Call(WithCredentials(context.Background(), "bad"), "Who.Identity", 1, &identity); err == nil || err.Error() != ErrUnauthenticated.Error() {
+		t.Fatalf("expect ErrUnauthenticated for invalid per-call credentials, got %v", err)
+	}
+
+	// 没有凭证的连接只能通过单次调用的凭证完成认证
+	anonymous, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer anonymous.Close()
+	if err := anonymous.Call(context.Background(), "Who.Identity", 1, &identity); err == nil || err.Error() != ErrUnauthenticated.Error() {
+		t.Fatalf("expect ErrUnauthenticated without credentials, got %v", err)
+	}
+	if err := anonymous.Call(WithCredentials(context.Background(), "good"), "Who.Identity", 1, &identity); err != nil || identity != "alice" {
+		t.Fatalf("expect identity alice, got %q, err %v", identity, err)
+	}
+
+	// 握手凭证无效的连接被关闭
+	rejected, err := DialInProc(server, &Option{Credentials: "bad", ConnectTimeout: time.Second})
+	if err == nil {
+		defer rejected.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := rejected.Call(ctx, "Who.Identity", 1, &identity); err == nil {
+			t.Fatal("expect the connection with invalid credentials to be rejected")
+		}
+	}
+}
+
+func TestJWTValidator(t *testing.T) {
+	v := NewJWTValidator([]byte("secret"), "issuer", "geerpc")
+	now := time.Now().Unix()
+	valid := map[string]interface{}{"iss": "issuer", "sub": "alice", "aud": []string{"other", "geerpc"}, "exp": now + 60}
+	if id, err := v.Validate(signHS256([]byte("secret"), valid)); err != nil || id != "alice" {
+		t.Fatalf("expect identity alice, got %q, err %v", id, err)
+	}
+	cases := map[string]map[string]interface{}{
+		"expired":      {"iss": "issuer", "sub": "alice", "aud": "geerpc", "exp": now - 60},
+		"not yet":      {"iss": "issuer", "sub": "alice", "aud": "geerpc", "nbf": now + 60},
+		"wrong issuer": {"iss": "other", "sub": "alice", "aud": "geerpc"},
+		"wrong aud":    {"iss": "issuer", "sub": "alice", "aud": "other"},
+	}
+	for name, claims := range cases {
+		if _, err := v.Validate(signHS256([]byte("secret"), claims)); err == nil {
+			t.Fatalf("%s: expect the token to be rejected", name)
+		}
+	}
+	if _, err := v.Validate(signHS256([]byte("wrong"), valid)); err == nil {
+		t.Fatal("expect a token signed with a wrong key to be rejected")
+	}
+}
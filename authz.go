@@ -0,0 +1,95 @@
+package geerpc
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+)
+
+// ErrPermissionDenied 表示调用方无权调用请求的方法
+var ErrPermissionDenied = errors.New("rpc server: permission denied")
+
+// Authorizer 在请求分发给服务之前决定调用方是否可以调用 serviceMethod，返回错误表示拒绝。
+// identity 是认证得到的调用方身份（例如 mTLS 客户端证书中的 SPIFFE ID），未认证时为空字符串
+type Authorizer interface {
+	Authorize(identity, serviceMethod string) error
+}
+
+// AuthorizerFunc 将普通函数适配为 Authorizer
+type AuthorizerFunc func(identity, serviceMethod string) error
+
+// Authorize 调用 f(identity, serviceMethod)
+func (f AuthorizerFunc) Authorize(identity, serviceMethod string) error {
+	return f(identity, serviceMethod)
+}
+
+// AllowIdentities 是一个简单的 Authorizer，按服务或方法列出允许调用的身份。
+// 键为 "Service.Method" 或 "Service"，方法级的规则优先于服务级的规则；
+// 身份以 "*" 结尾时按前缀匹配，例如 "spiffe://example.org/ns/prod/*"。
+// 没有对应规则的服务和方法允许任何调用方调用
+type AllowIdentities map[string][]string
+
+// Authorize 检查 identity 是否在 serviceMethod 或其所属服务的允许列表中
+func (a AllowIdentities) Authorize(identity, serviceMethod string) error {
+	allowed, ok := a[serviceMethod]
+	if !ok {
+		service := serviceMethod
+		if dot := strings.LastIndex(serviceMethod, "."); dot >= 0 {
+			service = serviceMethod[:dot]
+		}
+		if allowed, ok = a[service]; !ok {
+			return nil
+		}
+	}
+	for _, pattern := range allowed {
+		if matchIdentity(pattern, identity) {
+			return nil
+		}
+	}
+	return ErrPermissionDenied
+}
+
+// matchIdentity 判断 identity 是否匹配 pattern，pattern 以 "*" 结尾时按前缀匹配
+func matchIdentity(pattern, identity string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return identity != "" && strings.HasPrefix(identity, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == identity
+}
+
+// SetAuthorizer 设置服务器的 Authorizer，每个请求在分发之前都会经过它的检查，
+// 被拒绝的请求返回其错误信息。为 nil（默认）时不做检查，应在开始服务之前调用
+func (server *Server) SetAuthorizer(a Authorizer) {
+	server.authorizer = a
+}
+
+// authorize 检查请求是否被允许
+func (server *Server) authorize(req *request) error {
+	if server.authorizer == nil {
+		return nil
+	}
+	if err := server.authorizer.Authorize(req.identity, req.h.ServiceMethod); err != nil {
+		server.log().Warn("rpc server: request denied", "method", req.h.ServiceMethod, "identity", req.identity, "remote", req.remote, "err", err)
+		return err
+	}
+	return nil
+}
+
+// TLSIdentity 从 TLS 连接的客户端证书中提取调用方身份：
+// 优先使用 URI SAN 中的 SPIFFE ID（spiffe://...），其次是第一个 DNS SAN，最后是证书主题的 CommonName。
+// 没有客户端证书时返回空字符串。服务端需要在 tls.Config 中设置 ClientAuth 和 ClientCAs 以要求并验证客户端证书
+func TLSIdentity(state tls.ConnectionState) string {
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+	cert := state.PeerCertificates[0]
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return cert.Subject.CommonName
+}
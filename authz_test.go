@@ -0,0 +1,163 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// issueCert 使用 parent 和 parentKey 签发 tmpl 描述的证书，parent 为 nil 时签发自签名证书
+func issueCert(t *testing.T, tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+// clientCert 签发一个身份为 spiffeID 的客户端证书
+func clientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, spiffeID string) tls.Certificate {
+	uri, _ := url.Parse(spiffeID)
+	_, _, cert := issueCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		URIs:        []*url.URL{uri},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	return cert
+}
+
+func TestTLSIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/web")
+	other, _ := url.Parse("https://example.org/web")
+	cases := []struct {
+		cert     *x509.Certificate
+		identity string
+	}{
+		{&x509.Certificate{URIs: []*url.URL{other, spiffe}, DNSNames: []string{"web.example.org"}, Subject: pkix.Name{CommonName: "web"}}, spiffe.String()},
+		{&x509.Certificate{URIs: []*url.URL{other}, DNSNames: []string{"web.example.org"}, Subject: pkix.Name{CommonName: "web"}}, "web.example.org"},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "web"}}, "web"},
+	}
+	for _, c := range cases {
+		if got := TLSIdentity(tls.ConnectionState{PeerCertificates: []*x509.Certificate{c.cert}}); got != c.identity {
+			t.Fatalf("expect identity %q, got %q", c.identity, got)
+		}
+	}
+	if got := TLSIdentity(tls.ConnectionState{}); got != "" {
+		t.Fatalf("expect no identity without a client certificate, got %q", got)
+	}
+}
+
+func TestAllowIdentities(t *testing.T) {
+	a := AllowIdentities{
+		"Account":        {"spiffe://example.org/ns/prod/*"},
+		"Account.Delete": {"spiffe://example.org/ns/prod/sa/admin"},
+	}
+	cases := []struct {
+		identity, method string
+		allowed          bool
+	}{
+		{"spiffe://example.org/ns/prod/sa/web", "Account.Get", true},
+		{"spiffe://example.org/ns/dev/sa/web", "Account.Get", false},
+		{"", "Account.Get", false},                                       // 前缀规则不匹配未认证的调用方
+		{"spiffe://example.org/ns/prod/sa/web", "Account.Delete", false}, // 方法级的规则优先
+		{"spiffe://example.org/ns/prod/sa/admin", "Account.Delete", true},
+		{"", "Health.Check", true}, // 没有规则的服务允许任何调用方
+	}
+	for _, c := range cases {
+		err := a.Authorize(c.identity, c.method)
+		if (err == nil) != c.allowed {
+			t.Fatalf("%q calling %s: expect allowed=%v, got %v", c.identity, c.method, c.allowed, err)
+		}
+		if err != nil && err != ErrPermissionDenied {
+			t.Fatalf("expect ErrPermissionDenied, got %v", err)
+		}
+	}
+}
+
+func TestServer_MTLSAuthorization(t *testing.T) {
+	ca, caKey, _ := issueCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "geerpc-test-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	_, _, serverCert := issueCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	server := NewServer()
+	var w Who
+	_ = server.Register(&w)
+	server.SetAuthorizer(AllowIdentities{"Who.Identity": {"spiffe://example.org/ns/prod/*"}})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.AcceptTLS(l, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+
+	call := func(certs ...tls.Certificate) (string, error) {
+		opt := &Option{TLSConfig: &tls.Config{RootCAs: pool, Certificates: certs}, ConnectTimeout: time.Second}
+		client, err := Dial("tcp", l.Addr().String(), opt)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = client.Close() }()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var identity string
+		err = client.Call(ctx, "Who.Identity", 1, &identity)
+		return identity, err
+	}
+
+	prod := "spiffe://example.org/ns/prod/sa/web"
+	if identity, err := call(clientCert(t, ca, caKey, prod)); err != nil || identity != prod {
+		t.Fatalf("expect %s to be allowed and see its identity, got %q, %v", prod, identity, err)
+	}
+	if _, err := call(clientCert(t, ca, caKey, "spiffe://example.org/ns/dev/sa/web")); err == nil || err.Error() != ErrPermissionDenied.Error() {
+		t.Fatalf("expect ErrPermissionDenied for the dev identity, got %v", err)
+	}
+	if _, err := call(); err == nil {
+		t.Fatal("expect a client without a certificate to be rejected")
+	}
+	// 不受信任的 CA 签发的证书同样被拒绝
+	otherCA, otherKey, _ := issueCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	if _, err := call(clientCert(t, otherCA, otherKey, prod)); err == nil {
+		t.Fatal("expect a certificate from an untrusted CA to be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+// Package bench 对 geerpc 服务进行压测，按配置的并发数和参数大小持续发起调用，统计吞吐量和延迟分位数，
+// 用于在修改编解码器或传输层前后得到可以比较的结果。cmd/geerpc-bench 是基于它的命令行工具。
+//
+// 包中的基准测试在本地启动服务器，覆盖回显、大参数和广播（fan-out）场景与各个编解码器、传输方式的组合，
+// 修改编解码器或服务器后运行 go test ./bench -run NONE -bench . 即可发现性能退化，
+// 加上 -bench.profile <dir> 为每个场景分别采集 CPU 和堆内存数据
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"geerpc"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultServiceMethod 是默认的压测目标，即注册 Echo 后提供的方法
+const DefaultServiceMethod = "Echo.Echo"
+
+// Echo 是用于压测的服务，原样返回参数，注册到服务器后即可作为 DefaultServiceMethod 的目标
+type Echo struct{}
+
+// Echo 原样返回 args
+func (Echo) Echo(args []byte, reply *[]byte) error {
+	*reply = args
+	return nil
+}
+
+// Config 是一次压测的配置
+type Config struct {
+	ServiceMethod string        // 压测的方法，默认为 DefaultServiceMethod
+	Concurrency   int           // 同时发起调用的 goroutine 数量，默认为 1
+	Requests      int           // 总请求数，与 Duration 至少设置一个，都设置时先达到的为准
+	Duration      time.Duration // 压测的持续时间
+	PayloadSize   int           // 默认参数（[]byte）的字节数
+	Timeout       time.Duration // 单次调用的超时时间，0 表示不限制
+
+	// Args 和 Reply 不为 nil 时用于生成每次调用的参数和返回值，
+	// 默认分别为 PayloadSize 字节的 []byte 和 *[]byte，适用于 Echo 等参数为 []byte 的方法
+	Args  func() interface{}
+	Reply func() interface{}
+}
+
+// Result 是一次压测的结果，延迟统计包括失败的调用
+type Result struct {
+	Requests   int           // 完成的请求数
+	Errors     int           // 失败的请求数
+	FirstError error         // 第一个失败的请求返回的错误
+	Elapsed    time.Duration // 压测实际的持续时间
+	Throughput float64       // 每秒完成的请求数
+
+	Min, Mean, Max      time.Duration
+	P50, P90, P99, P999 time.Duration
+}
+
+// String 返回结果的单行摘要
+func (r *Result) String() string {
+	return fmt.Sprintf("requests=%d errors=%d elapsed=%v throughput=%.1f/s min=%v mean=%v p50=%v p90=%v p99=%v p99.9=%v max=%v",
+		r.Requests, r.Errors, r.Elapsed.Round(time.Millisecond), r.Throughput,
+		r.Min, r.Mean, r.P50, r.P90, r.P99, r.P999, r.Max)
+}
+
+// Run 通过 c（*geerpc.Client 或 *xclient.XClient）按 cfg 进行压测，直到达到 Requests 或 Duration，或者 ctx 被取消
+func Run(ctx context.Context, c geerpc.Caller, cfg Config) (*Result, error) {
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		return nil, errors.New("bench: Requests or Duration must be set")
+	}
+	if cfg.ServiceMethod == "" {
+		cfg.ServiceMethod = DefaultServiceMethod
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Args == nil {
+		payload := make([]byte, cfg.PayloadSize)
+		for i := range payload {
+			payload[i] = byte(i)
+		}
+		cfg.Args = func() interface{} { return payload }
+	}
+	if cfg.Reply == nil {
+		cfg.Reply = func() interface{} { return new([]byte) }
+	}
+
+	var (
+		remaining  = int64(cfg.Requests)
+		errCount   int64
+		firstError atomic.Value
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		latencies  []time.Duration
+	)
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	start := time.Now()
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local []time.Duration
+			for ctx.Err() == nil {
+				if cfg.Requests > 0 && atomic.AddInt64(&remaining, -1) < 0 {
+					break
+				}
+				d, err := call(c, cfg)
+				local = append(local, d)
+				if err != nil && atomic.AddInt64(&errCount, 1) == 1 {
+					firstError.Store(errorValue{err})
+				}
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	r := &Result{Requests: len(latencies), Errors: int(errCount), Elapsed: time.Since(start)}
+	if v, ok := firstError.Load().(errorValue); ok {
+		r.FirstError = v.err
+	}
+	if r.Elapsed > 0 {
+		r.Throughput = float64(r.Requests) / r.Elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		var total time.Duration
+		for _, d := range latencies {
+			total += d
+		}
+		r.Min, r.Max = latencies[0], latencies[len(latencies)-1]
+		r.Mean = total / time.Duration(len(latencies))
+		r.P50 = percentile(latencies, 0.5)
+		r.P90 = percentile(latencies, 0.9)
+		r.P99 = percentile(latencies, 0.99)
+		r.P999 = percentile(latencies, 0.999)
+	}
+	return r, nil
+}
+
+// errorValue 包装 error，使不同具体类型的错误可以存入同一个 atomic.Value
+type errorValue struct{ err error }
+
+// call 发起一次调用并返回耗时。调用使用独立的 context，压测结束时正在进行的调用不会被取消，以免被计为失败
+func call(c geerpc.Caller, cfg Config) (time.Duration, error) {
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := c.Call(ctx, cfg.ServiceMethod, cfg.Args(), cfg.Reply())
+	return time.Since(start), err
+}
+
+// percentile 返回已排序的 sorted 中的 p 分位数（最近秩法）
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
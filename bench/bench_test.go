@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"geerpc"
+	"geerpc/codec"
+	"geerpc/xclient"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// profileDir 不为空时，每个场景的 CPU 和堆内存数据分别写入该目录下以场景命名的文件，例如
+//
+//	go test ./bench -run NONE -bench Echo -bench.profile /tmp/prof
+var profileDir = flag.String("bench.profile", "", "write per-scenario CPU and heap profiles to this directory")
+
+var codecs = []codec.Type{codec.GobType, codec.JsonType}
+
+// profile 在设置了 -bench.profile 时采集 b 的性能数据，计时之外的准备工作不在其中
+func profile(b *testing.B) {
+	if *profileDir == "" {
+		return
+	}
+	name := filepath.Join(*profileDir, strings.NewReplacer("/", "_", "=", "-").Replace(b.Name()))
+	stop, err := StartProfile(name+".cpu.pprof", name+".mem.pprof")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if err := stop(); err != nil {
+			b.Error(err)
+		}
+	})
+}
+
+func option(ct codec.Type) *geerpc.Option {
+	opt := *geerpc.DefaultOption
+	opt.CodecType = ct
+	return &opt
+}
+
+// runEcho 在每个编解码器和传输方式的组合上并发调用 Echo.Echo，参数为 size 字节
+func runEcho(b *testing.B, size int) {
+	payload := bytes.Repeat([]byte{'x'}, size)
+	for _, transport := range Transports {
+		for _, ct := range codecs {
+			b.Run(fmt.Sprintf("%s/%s", transport, strings.TrimPrefix(string(ct), "application/")), func(b *testing.B) {
+				s, err := StartServer(transport)
+				if err != nil {
+					b.Skip(err)
+				}
+				defer s.Close()
+				client, err := s.Dial(option(ct))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer client.Close()
+				b.SetBytes(int64(size) * 2)
+				b.ReportAllocs()
+				profile(b)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						var reply []byte
+						if err := client.Call(context.Background(), DefaultServiceMethod, payload, &reply); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkEcho(b *testing.B) { runEcho(b, 64) }
+
+func BenchmarkLargePayload(b *testing.B) { runEcho(b, 1<<20) }
+
+// BenchmarkFanOut 通过 XClient.Broadcast 同时调用多个服务器，衡量并发调用和汇总结果的开销
+func BenchmarkFanOut(b *testing.B) {
+	for _, n := range []int{4, 16} {
+		b.Run(fmt.Sprintf("servers=%d", n), func(b *testing.B) {
+			addrs := make([]string, n)
+			for i := range addrs {
+				s, err := StartServer("tcp")
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer s.Close()
+				addrs[i] = s.Addr
+			}
+			xc := xclient.NewXClient(xclient.NewMultiServerDiscovery(addrs), xclient.RandomSelect, nil)
+			defer func() { _ = xc.Close() }()
+			payload := bytes.Repeat([]byte{'x'}, 64)
+			b.ReportAllocs()
+			profile(b)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var reply []byte
+				if err := xc.Broadcast(context.Background(), DefaultServiceMethod, payload, &reply); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, err := StartServer("inproc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	client, err := s.Dial(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	r, err := Run(context.Background(), client, Config{Concurrency: 4, Requests: 100, PayloadSize: 16, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 100 || r.Errors != 0 || r.P50 <= 0 || r.Max < r.P99 {
+		t.Fatalf("unexpected result: %v (first error: %v)", r, r.FirstError)
+	}
+}
+
+func TestRun_ErrorsAndDuration(t *testing.T) {
+	s, err := StartServer("inproc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	client, err := s.Dial(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := Run(context.Background(), client, Config{}); err == nil {
+		t.Fatal("expect an error when neither Requests nor Duration is set")
+	}
+	// 失败的调用计入请求数和错误数，并保留第一个错误
+	r, err := Run(context.Background(), client, Config{ServiceMethod: "Echo.Missing", Requests: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 5 || r.Errors != 5 || r.FirstError == nil {
+		t.Fatalf("expect 5 failed requests, got %v (first error: %v)", r, r.FirstError)
+	}
+	// 只设置 Duration 时持续压测到超时
+	r, err = Run(context.Background(), client, Config{Concurrency: 2, Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests == 0 || r.Errors != 0 || r.Elapsed < 50*time.Millisecond || r.Throughput <= 0 {
+		t.Fatalf("unexpected result: %v (first error: %v)", r, r.FirstError)
+	}
+}
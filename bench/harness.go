@@ -0,0 +1,117 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"geerpc"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+)
+
+// Transports 是 StartServer 支持的传输方式
+var Transports = []string{"tcp", "unix", "inproc"}
+
+// inProcSeq 用于生成不重复的进程内监听器名称
+var inProcSeq uint64
+
+// Server 是压测用的本地服务器，注册了 Echo 且不限制请求速率，
+// 使同一个场景在不同机器、不同提交之间的结果可以比较
+type Server struct {
+	*geerpc.Server
+	Addr string // 可以传给 geerpc.XDial 的地址，例如 tcp@127.0.0.1:34567
+
+	l   net.Listener
+	dir string // unix 套接字所在的临时目录
+}
+
+// StartServer 在 transport 上启动一个压测用的服务器：tcp 监听本地回环地址的随机端口，
+// unix 在临时目录中创建套接字，inproc 创建进程内监听器，不经过操作系统的网络栈
+func StartServer(transport string) (*Server, error) {
+	s := &Server{Server: geerpc.NewServer()}
+	s.SetConnRateLimit(0, 0)
+	if err := s.Register(Echo{}); err != nil {
+		return nil, err
+	}
+	var err error
+	switch transport {
+	case "tcp":
+		s.l, err = net.Listen("tcp", "127.0.0.1:0")
+	case "unix":
+		if s.dir, err = ioutil.TempDir("", "geerpc-bench"); err == nil {
+			s.l, err = net.Listen("unix", filepath.Join(s.dir, "bench.sock"))
+		}
+	case "inproc":
+		s.l, err = geerpc.ListenInProc(fmt.Sprintf("geerpc-bench-%d", atomic.AddUint64(&inProcSeq, 1)))
+	default:
+		err = errors.New("bench: unknown transport " + transport)
+	}
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	s.Addr = transport + "@" + s.l.Addr().String()
+	go s.Accept(s.l)
+	return s, nil
+}
+
+// Dial 使用 opt 连接到服务器，opt 为 nil 时使用默认选项
+func (s *Server) Dial(opt *geerpc.Option) (*geerpc.Client, error) {
+	if opt == nil {
+		return geerpc.XDial(s.Addr)
+	}
+	return geerpc.XDial(s.Addr, opt)
+}
+
+// Close 关闭服务器和它的所有连接，并删除临时文件
+func (s *Server) Close() error {
+	err := s.Shutdown(context.Background())
+	if s.l != nil {
+		_ = s.l.Close() // 启动失败时监听器没有交给 Accept，Shutdown 不会关闭它
+	}
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+	}
+	return err
+}
+
+// StartProfile 开始采集 CPU 性能数据并写入 cpuFile，返回的 stop 停止采集，并在 memFile 不为空时写入堆内存数据。
+// 文件名为空表示不采集对应的数据，便于只分析某一个场景而不是整个压测过程
+func StartProfile(cpuFile, memFile string) (stop func() error, err error) {
+	var cpu *os.File
+	if cpuFile != "" {
+		if cpu, err = os.Create(cpuFile); err != nil {
+			return nil, err
+		}
+		if err = pprof.StartCPUProfile(cpu); err != nil {
+			_ = cpu.Close()
+			return nil, err
+		}
+	}
+	return func() error {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return err
+			}
+		}
+		if memFile == "" {
+			return nil
+		}
+		f, err := os.Create(memFile)
+		if err != nil {
+			return err
+		}
+		runtime.GC() // 使堆内存数据反映最近一次垃圾回收后的状态
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
@@ -0,0 +1,54 @@
+package geerpc
+
+import (
+	"context"
+	"time"
+)
+
+// Caller 是发起一次调用的接口，*Client 和 xclient.XClient 都实现了它，geerpc-gen 生成的客户端存根基于它发起调用
+type Caller interface {
+	Call(ctx context.Context, serviceMethod string, args, reply interface{}) error
+}
+
+// CallOptions 是单次调用的选项
+type CallOptions struct {
+	Timeout     time.Duration // 调用的超时时间，0 表示只受 ctx 限制
+	Credentials string        // 单次调用的凭证，参见 WithCredentials
+	RequestID   string        // 请求 ID，参见 WithRequestID
+}
+
+// CallOption 设置单次调用的选项
+type CallOption func(*CallOptions)
+
+// CallTimeout 设置调用的超时时间
+func CallTimeout(d time.Duration) CallOption {
+	return func(o *CallOptions) { o.Timeout = d }
+}
+
+// CallCredentials 设置单次调用的凭证
+func CallCredentials(token string) CallOption {
+	return func(o *CallOptions) { o.Credentials = token }
+}
+
+// CallRequestID 设置调用的请求 ID
+func CallRequestID(id string) CallOption {
+	return func(o *CallOptions) { o.RequestID = id }
+}
+
+// ApplyCallOptions 返回应用了 opts 的 context，调用结束后必须调用返回的 cancel
+func ApplyCallOptions(ctx context.Context, opts ...CallOption) (context.Context, context.CancelFunc) {
+	var o CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Credentials != "" {
+		ctx = WithCredentials(ctx, o.Credentials)
+	}
+	if o.RequestID != "" {
+		ctx = WithRequestID(ctx, o.RequestID)
+	}
+	if o.Timeout > 0 {
+		return context.WithTimeout(ctx, o.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,117 @@
+package geerpc
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 抓包记录的方向
+const (
+	CaptureSend = ">" // 本端发送的数据
+	CaptureRecv = "<" // 本端接收的数据
+)
+
+// Capture 将连接上收发的原始字节写入 io.Writer，用于在没有抓包工具时排查编解码不匹配、握手数据损坏等问题。
+// 每次读写生成一条记录，每条记录占一行，格式为 "时间戳 连接编号 方向 base64数据"，
+// 可以用 ReadCapture 解析后按顺序回放
+type Capture struct {
+	nextConn uint64 // 原子操作访问，放在首位以保证 64 位对齐
+
+	mu sync.Mutex // 保证记录不会交错
+	w  io.Writer
+}
+
+// NewCapture 创建一个将记录写入 w 的 Capture 实例
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{w: w}
+}
+
+// CaptureRecord 是一条抓包记录
+type CaptureRecord struct {
+	Time time.Time
+	Conn uint64 // 连接编号，同一个 Capture 中的每个连接从 1 开始依次编号
+	Dir  string // CaptureSend 或 CaptureRecv
+	Data []byte
+}
+
+// record 写入一条记录，写入失败时忽略，不影响连接本身
+func (c *Capture) record(conn uint64, dir string, data []byte) {
+	line := fmt.Sprintf("%s %d %s %s\n", time.Now().Format(time.RFC3339Nano), conn, dir, base64.StdEncoding.EncodeToString(data))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = io.WriteString(c.w, line)
+}
+
+// wrap 包装连接，使其读写的数据被记录下来
+func (c *Capture) wrap(conn io.ReadWriteCloser) io.ReadWriteCloser {
+	return &captureConn{ReadWriteCloser: conn, capture: c, id: atomic.AddUint64(&c.nextConn, 1)}
+}
+
+// captureConn 将读写的数据记录到 Capture 的连接
+type captureConn struct {
+	io.ReadWriteCloser
+	capture *Capture
+	id      uint64
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if n > 0 {
+		c.capture.record(c.id, CaptureRecv, p[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	if n > 0 {
+		c.capture.record(c.id, CaptureSend, p[:n])
+	}
+	return n, err
+}
+
+// ReadCapture 解析 Capture 写入的记录
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 && len(fields) != 3 {
+			return nil, errors.New("rpc capture: malformed record at line " + strconv.Itoa(line))
+		}
+		t, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return nil, errors.New("rpc capture: invalid time at line " + strconv.Itoa(line))
+		}
+		conn, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, errors.New("rpc capture: invalid conn at line " + strconv.Itoa(line))
+		}
+		if fields[2] != CaptureSend && fields[2] != CaptureRecv {
+			return nil, errors.New("rpc capture: invalid direction at line " + strconv.Itoa(line))
+		}
+		var data []byte
+		if len(fields) == 4 {
+			if data, err = base64.StdEncoding.DecodeString(fields[3]); err != nil {
+				return nil, errors.New("rpc capture: invalid data at line " + strconv.Itoa(line))
+			}
+		}
+		records = append(records, CaptureRecord{Time: t, Conn: conn, Dir: fields[2], Data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,92 @@
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captured 按方向拼接 buf 中连接 conn 的抓包数据
+func captured(t *testing.T, buf *syncBuffer, conn uint64, dir string) []byte {
+	t.Helper()
+	buf.mu.Lock()
+	records, err := ReadCapture(bytes.NewReader(buf.buf.Bytes()))
+	buf.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for _, r := range records {
+		if r.Conn == conn && r.Dir == dir {
+			data = append(data, r.Data...)
+		}
+	}
+	return data
+}
+
+func TestCapture(t *testing.T) {
+	var serverBuf, clientBuf syncBuffer
+	server := NewServer()
+	server.SetCapture(NewCapture(&serverBuf))
+	var foo Foo
+	_ = server.Register(&foo)
+	clientCapture := NewCapture(&clientBuf)
+	for i := 0; i < 2; i++ {
+		client, err := DialInProc(server, &Option{Capture: clientCapture})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply int
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("expect the call to work with capture on, got %d, %v", reply, err)
+		}
+		_ = client.Close()
+	}
+
+	// 每个连接依次编号，选项握手也被记录
+	for conn := uint64(1); conn <= 2; conn++ {
+		sent := captured(t, &clientBuf, conn, CaptureSend)
+		if !bytes.HasPrefix(sent, []byte("{")) || !strings.Contains(string(sent), "MagicNumber") {
+			t.Fatalf("expect the option handshake first, got %q", sent)
+		}
+		// 客户端发送的字节就是服务端收到的字节，反之亦然
+		if got := captured(t, &serverBuf, conn, CaptureRecv); !bytes.Equal(got, sent) {
+			t.Fatalf("conn %d: expect the server to receive what the client sent", conn)
+		}
+		var recv []byte
+		for i := 0; i < 100; i++ {
+			recv = captured(t, &clientBuf, conn, CaptureRecv)
+			if len(recv) > 0 && bytes.Equal(recv, captured(t, &serverBuf, conn, CaptureSend)) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if len(recv) == 0 || !bytes.Equal(recv, captured(t, &serverBuf, conn, CaptureSend)) {
+			t.Fatalf("conn %d: expect the client to receive what the server sent", conn)
+		}
+	}
+}
+
+func TestReadCapture(t *testing.T) {
+	records, err := ReadCapture(strings.NewReader("2026-01-02T03:04:05.5Z 7 > aGk=\n\n2026-01-02T03:04:05.6Z 7 <\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Conn != 7 || records[0].Dir != CaptureSend || string(records[0].Data) != "hi" ||
+		records[1].Dir != CaptureRecv || len(records[1].Data) != 0 || !records[0].Time.Before(records[1].Time) {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	for _, line := range []string{
+		"2026-01-02T03:04:05Z 1",
+		"yesterday 1 > aGk=",
+		"2026-01-02T03:04:05Z x > aGk=",
+		"2026-01-02T03:04:05Z 1 = aGk=",
+		"2026-01-02T03:04:05Z 1 > !!",
+	} {
+		if _, err := ReadCapture(strings.NewReader(line)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("expect an error for %q, got %v", line, err)
+		}
+	}
+}
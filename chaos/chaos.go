@@ -0,0 +1,178 @@
+// Package chaos 提供故障注入的拦截器，按配置的概率注入延迟、错误、丢弃的响应和断开的连接，
+// 用于在预发环境中验证调用方的重试、熔断和故障转移策略是否按预期工作。
+//
+// 服务端拦截器（Server.Use）注入的故障经过网络到达调用方，与真实的故障没有区别，会被 XClient 的熔断器统计；
+// 客户端拦截器（XClient.Use）位于选择后端之前，注入的故障不会被熔断器统计，适用于测试调用方自身的超时和降级逻辑
+package chaos
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"geerpc/xclient"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected 是 Config.Error 为 nil 时注入的错误
+var ErrInjected = errors.New("rpc chaos: injected fault")
+
+// Config 是故障注入的配置，各个概率的取值范围为 [0, 1]。
+// 延迟与其他故障独立注入；错误、丢弃响应和断开连接三者互斥，它们的概率之和不应超过 1
+type Config struct {
+	LatencyRate float64       // 注入延迟的概率
+	Latency     time.Duration // 注入的延迟
+	Jitter      time.Duration // 在 Latency 的基础上随机增加 [0, Jitter) 的延迟
+
+	ErrorRate float64 // 返回错误的概率
+	Error     error   // 返回的错误，为 nil 时使用 ErrInjected，例如 geerpc.ErrQuotaExceeded
+
+	DropRate  float64 // 丢弃响应的概率：服务方法照常执行，但调用方收不到响应，直到超时
+	ResetRate float64 // 断开连接的概率：服务端关闭连接，连接上所有正在进行的调用都会失败
+
+	// Methods 不为 nil 时只对返回 true 的方法注入故障，例如跳过 "Health.Check"
+	Methods func(serviceMethod string) bool
+}
+
+// Stats 是已注入的故障数
+type Stats struct {
+	Latency uint64
+	Errors  uint64
+	Drops   uint64
+	Resets  uint64
+}
+
+// Injector 按 Config 注入故障，配置可以在运行时通过 SetConfig 修改
+type Injector struct {
+	// 以下计数器使用原子操作访问，放在首位以保证 64 位对齐
+	latency uint64
+	errors  uint64
+	drops   uint64
+	resets  uint64
+
+	mu  sync.Mutex // 保护 cfg
+	cfg Config
+}
+
+// New 创建一个按 cfg 注入故障的 Injector
+func New(cfg Config) *Injector {
+	return &Injector{cfg: cfg}
+}
+
+// SetConfig 修改注入故障的配置，对之后的调用生效，传入零值 Config 即停止注入
+func (in *Injector) SetConfig(cfg Config) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.cfg = cfg
+}
+
+// Stats 返回已注入的故障数
+func (in *Injector) Stats() Stats {
+	return Stats{
+		Latency: atomic.LoadUint64(&in.latency),
+		Errors:  atomic.LoadUint64(&in.errors),
+		Drops:   atomic.LoadUint64(&in.drops),
+		Resets:  atomic.LoadUint64(&in.resets),
+	}
+}
+
+// 一次调用注入的故障
+const (
+	faultNone = iota
+	faultError
+	faultDrop
+	faultReset
+)
+
+// decide 决定一次调用注入的延迟和故障
+func (in *Injector) decide(serviceMethod string) (delay time.Duration, fault int, err error) {
+	in.mu.Lock()
+	cfg := in.cfg
+	in.mu.Unlock()
+	if cfg.Methods != nil && !cfg.Methods(serviceMethod) {
+		return 0, faultNone, nil
+	}
+	if cfg.LatencyRate > 0 && rand.Float64() < cfg.LatencyRate {
+		delay = cfg.Latency
+		if cfg.Jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(cfg.Jitter)))
+		}
+		atomic.AddUint64(&in.latency, 1)
+	}
+	r := rand.Float64()
+	switch {
+	case r < cfg.ErrorRate:
+		atomic.AddUint64(&in.errors, 1)
+		err = cfg.Error
+		if err == nil {
+			err = ErrInjected
+		}
+		return delay, faultError, err
+	case r < cfg.ErrorRate+cfg.DropRate:
+		atomic.AddUint64(&in.drops, 1)
+		return delay, faultDrop, nil
+	case r < cfg.ErrorRate+cfg.DropRate+cfg.ResetRate:
+		atomic.AddUint64(&in.resets, 1)
+		return delay, faultReset, nil
+	}
+	return delay, faultNone, nil
+}
+
+// ServerInterceptor 返回在服务端注入故障的拦截器，丢弃响应和断开连接通过 geerpc.ErrDropResponse 和 geerpc.ErrResetConn 实现
+func (in *Injector) ServerInterceptor() geerpc.ServerInterceptor {
+	return func(ctx context.Context, info *geerpc.CallInfo, args, reply interface{}, next geerpc.Handler) error {
+		delay, fault, err := in.decide(info.ServiceMethod)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		switch fault {
+		case faultError:
+			return err
+		case faultDrop:
+			_ = next(ctx, info, args, reply)
+			return geerpc.ErrDropResponse
+		case faultReset:
+			return geerpc.ErrResetConn
+		}
+		return next(ctx, info, args, reply)
+	}
+}
+
+// ClientInterceptor 返回在 XClient 中注入故障的拦截器。丢弃响应时调用照常发出，但直到 ctx 结束才返回 ctx 的错误，
+// ctx 没有截止时间时会一直等待；断开连接时不发出调用，直接返回 geerpc.ErrShutdown
+func (in *Injector) ClientInterceptor() xclient.Interceptor {
+	return func(ctx context.Context, serviceMethod string, args, reply interface{}, next xclient.Invoker) error {
+		delay, fault, err := in.decide(serviceMethod)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		switch fault {
+		case faultError:
+			return err
+		case faultDrop:
+			_ = next(ctx, serviceMethod, args, reply)
+			<-ctx.Done()
+			return ctx.Err()
+		case faultReset:
+			return geerpc.ErrShutdown
+		}
+		return next(ctx, serviceMethod, args, reply)
+	}
+}
+
+// sleep 等待 d，ctx 先结束时返回 ctx 的错误
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
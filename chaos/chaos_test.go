@@ -0,0 +1,63 @@
+package chaos
+
+import (
+	"context"
+	"geerpc"
+	"testing"
+	"time"
+)
+
+type Foo struct{}
+
+func (Foo) Sum(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func newClient(t *testing.T, in *Injector) *geerpc.Client {
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	server.Use(in.ServerInterceptor())
+	if err := server.Register(Foo{}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestServerInterceptor(t *testing.T) {
+	in := New(Config{ErrorRate: 1})
+	client := newClient(t, in)
+	var reply int
+	err := client.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply)
+	if err == nil || err.Error() != ErrInjected.Error() {
+		t.Fatalf("expect injected error, got %v", err)
+	}
+
+	in.SetConfig(Config{DropRate: 1, LatencyRate: 1, Latency: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	start := time.Now()
+	err = client.Call(ctx, "Foo.Sum", [2]int{1, 2}, &reply)
+	cancel()
+	if err == nil || time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("expect timeout on dropped response, got %v", err)
+	}
+
+	in.SetConfig(Config{ResetRate: 1})
+	if err = client.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err == nil || client.IsAvailable() {
+		t.Fatalf("expect connection reset, got %v", err)
+	}
+
+	client = newClient(t, in)
+	defer func() { _ = client.Close() }()
+	in.SetConfig(Config{ResetRate: 1, Methods: func(string) bool { return false }})
+	if err = client.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect no fault, got %d, err %v", reply, err)
+	}
+	if s := in.Stats(); s != (Stats{Latency: 1, Errors: 1, Drops: 1, Resets: 1}) {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
@@ -0,0 +1,139 @@
+package geerpc
+
+import (
+	"bytes"
+	"errors"
+	"geerpc/codec"
+	"io"
+	"sync/atomic"
+)
+
+// SetChunkSize 开启返回值的分块发送：编码后超过 size 字节的返回值被拆分为若干个不超过 size 字节的分块，
+// 每个分块作为单独的消息发送，同一连接上其他调用的响应可以插在分块之间，
+// 使一个很大（例如 50MB）的返回值不会让连接上所有其他调用等待它发送完毕。
+//
+// 只对在握手时声明支持分块的客户端（本包的 Client 都会声明）生效，size <= 0（默认）表示不分块。
+// 开启后每个返回值先在内存中单独编码一次以确定大小，不超过 size 的返回值再照常编码发送，
+// 因此只适合返回值可能很大的服务，size 通常取几百 KB。可以在运行时调用，对之后建立的连接生效
+func (server *Server) SetChunkSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	atomic.StoreInt64(&server.chunkSize, int64(size))
+}
+
+// sendReply 发送调用的返回值，返回写入的消息体字节数，大小未知或写入失败时返回 -1
+func (c *codecConn) sendReply(req *request) int {
+	body := req.replyv.Interface()
+	if c.chunk <= 0 {
+		return c.server.sendResponse(c.cc, req.h, body, c.sending)
+	}
+	data, err := encodeStandalone(c.opt.CodecType, body)
+	if err != nil || len(data) <= c.chunk {
+		// 编码失败时照常发送，由连接的编解码器报告错误
+		return c.server.sendResponse(c.cc, req.h, body, c.sending)
+	}
+	// 每个分块单独获取写锁，其他调用的响应可以在分块之间发送
+	h := &codec.Header{ServiceMethod: req.h.ServiceMethod, Seq: req.h.Seq, RequestID: req.h.RequestID, Chunked: true}
+	size := len(data)
+	for len(data) > 0 {
+		n := c.chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		h.More = n < len(data)
+		c.sending.Lock()
+		err := c.cc.Write(h, data[:n])
+		c.sending.Unlock()
+		if err != nil {
+			c.server.log().Error("rpc server: write response error", "err", err)
+			return -1
+		}
+		data = data[n:]
+	}
+	return size
+}
+
+// receiveChunk 读取返回值的一个分块，收到最后一个分块后解码返回值并结束调用，只在接收响应的协程中调用。
+// 调用已经结束（例如超时或被取消）时丢弃之前收到的分块。已经收到的分块总大小超过 maxReply 时，
+// 调用以 codec.ErrMessageTooLarge 失败，之后的分块被丢弃，防止服务端用大量分块耗尽客户端的内存
+func (client *Client) receiveChunk(h *codec.Header) error {
+	var chunk []byte
+	if err := client.cc.ReadBody(&chunk); err != nil {
+		return err
+	}
+	if client.maxReply > 0 && len(client.chunks[h.Seq])+len(chunk) > client.maxReply {
+		delete(client.chunks, h.Seq)
+		if call := client.removeCall(h.Seq); call != nil {
+			call.Error = errors.New("reading body " + codec.ErrMessageTooLarge.Error())
+			client.finishCall(call)
+		}
+		return nil
+	}
+	data := append(client.chunks[h.Seq], chunk...)
+	if h.More {
+		client.mu.Lock()
+		_, ok := client.pending[h.Seq]
+		client.mu.Unlock()
+		if !ok {
+			delete(client.chunks, h.Seq)
+			return nil
+		}
+		if client.chunks == nil {
+			client.chunks = make(map[uint64][]byte)
+		}
+		client.chunks[h.Seq] = data
+		return nil
+	}
+	delete(client.chunks, h.Seq)
+	call := client.removeCall(h.Seq)
+	if call == nil {
+		return nil
+	}
+	if err := decodeStandalone(client.opt.CodecType, data, call.Reply); err != nil {
+		call.Error = errors.New("reading body " + err.Error())
+	} else if client.opt.Metrics != nil {
+		client.opt.Metrics.observeSizes(client.peer, call.ServiceMethod, -1, len(data))
+	}
+	client.finishCall(call)
+	return nil
+}
+
+// memConn 是只用于单独编码或解码一个消息的内存连接
+type memConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (memConn) Close() error { return nil }
+
+// encodeStandalone 使用 t 类型的新编解码器编码 body，结果不依赖连接上之前的消息（例如 gob 的类型定义）
+func encodeStandalone(t codec.Type, body interface{}) ([]byte, error) {
+	f := codec.NewCodecFuncMap[t]
+	if f == nil {
+		return nil, errors.New("rpc: invalid codec type " + string(t))
+	}
+	var buf bytes.Buffer
+	if err := f(memConn{Writer: &buf}).Write(&codec.Header{}, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeStandalone 将 encodeStandalone 的结果解码到 reply 中
+func decodeStandalone(t codec.Type, data []byte, reply interface{}) error {
+	f := codec.NewCodecFuncMap[t]
+	if f == nil {
+		return errors.New("rpc: invalid codec type " + string(t))
+	}
+	cc := f(memConn{Reader: bytes.NewReader(data)})
+	if l, ok := cc.(codec.Limiter); ok {
+		// 分块已经全部收到，拼接后的大小已经由 receiveChunk 检查
+		l.SetLimits(codec.Limits{MaxMessageSize: -1})
+	}
+	var h codec.Header
+	if err := cc.ReadHeader(&h); err != nil {
+		return err
+	}
+	return cc.ReadBody(reply)
+}
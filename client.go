@@ -2,14 +2,18 @@ package geerpc
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"geerpc/codec"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Call 表示一个活跃的 RPC 调用。
@@ -20,8 +24,17 @@ type Call struct {
 	Reply         interface{} // 函数的返回值
 	Error         error       // 若出现错误，将被设置
 	Done          chan *Call  // 在调用完成时发送信号
+	RequestID     string      // 请求 ID，随请求头发送给服务端
+	token         string      // 单次调用的凭证
+	start         time.Time   // 发起调用的时间
 }
 
+// ServerError 是服务端返回的错误，包括服务方法返回的错误和服务端拒绝处理请求的原因，
+// 与连接、传输错误以及客户端的超时和取消相区分
+type ServerError string
+
+func (e ServerError) Error() string { return string(e) }
+
 func (call *Call) done() {
 	call.Done <- call
 }
@@ -29,15 +42,18 @@ func (call *Call) done() {
 // Client 表示一个 RPC 客户端。
 // 一个客户端可以有多个未完成的 Calls，且可以被多个 goroutine 同时使用。
 type Client struct {
-	cc       codec.Codec      // 编解码器
-	opt      *Option          // 客户端选项
-	sending  sync.Mutex       // 保护以下部分
-	header   codec.Header     // 请求头
-	mu       sync.Mutex       // 保护以下部分
-	seq      uint64           // 调用序号
-	pending  map[uint64]*Call // 未完成的调用
-	closing  bool             // 用户调用了 Close
-	shutdown bool             // 服务器告知停止
+	cc       codec.Codec       // 编解码器
+	opt      *Option           // 客户端选项
+	sending  sync.Mutex        // 保护以下部分
+	header   codec.Header      // 请求头
+	mu       sync.Mutex        // 保护以下部分
+	seq      uint64            // 调用序号
+	pending  map[uint64]*Call  // 未完成的调用
+	closing  bool              // 用户调用了 Close
+	shutdown bool              // 服务器告知停止
+	peer     string            // 服务端地址，用于日志
+	chunks   map[uint64][]byte // 分块发送的返回值已经收到的部分，只在接收响应的协程中访问
+	maxReply int               // 分块发送的返回值拼接后的最大字节数，与编解码器对单个消息的默认限制相同
 }
 
 var _ io.Closer = (*Client)(nil)
@@ -62,7 +78,312 @@ func (client *Client) IsAvailable() bool {
 	return !client.shutdown && !client.closing
 }
 
-// ...
+// registerCall 注册一个调用，并为其分配序号
+func (client *Client) registerCall(call *Call) (uint64, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.closing || client.shutdown {
+		return 0, ErrShutdown
+	}
+	call.Seq = client.seq
+	client.pending[call.Seq] = call
+	client.seq++
+	return call.Seq, nil
+}
+
+// removeCall 根据序号从 pending 中移除对应的调用并返回
+func (client *Client) removeCall(seq uint64) *Call {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	call := client.pending[seq]
+	delete(client.pending, seq)
+	return call
+}
+
+// terminateCalls 在服务端或客户端发生错误时，将错误信息通知所有 pending 状态的调用
+func (client *Client) terminateCalls(err error) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.shutdown = true
+	for _, call := range client.pending {
+		call.Error = err
+		client.finishCall(call)
+	}
+}
+
+// receive 持续接收服务端的响应
+func (client *Client) receive() {
+	var err error
+	var h codec.Header // 在循环之间复用，避免每个响应分配一个头部
+	for err == nil {
+		h = codec.Header{}
+		if err = client.cc.ReadHeader(&h); err != nil {
+			break
+		}
+		if h.Chunked && h.Error == "" {
+			err = client.receiveChunk(&h)
+			continue
+		}
+		if len(client.chunks) > 0 {
+			delete(client.chunks, h.Seq) // 服务端在返回值发送完之前报告了错误（例如处理超时）
+		}
+		call := client.removeCall(h.Seq)
+		switch {
+		case call == nil:
+			// 通常意味着 Write 部分失败，且调用已经被移除
+			err = client.cc.ReadBody(nil)
+		case h.Error != "":
+			call.Error = ServerError(h.Error)
+			err = client.cc.ReadBody(nil)
+			client.finishCall(call)
+		default:
+			err = client.cc.ReadBody(call.Reply)
+			if err != nil {
+				call.Error = errors.New("reading body " + err.Error())
+			} else {
+				client.observeSizes(call.ServiceMethod, false)
+			}
+			client.finishCall(call)
+		}
+	}
+	// 发生错误，终止所有 pending 状态的调用
+	client.terminateCalls(err)
+}
+
+// send 发送一个调用请求
+func (client *Client) send(call *Call) {
+	// 确保客户端发送完整的请求
+	client.sending.Lock()
+	defer client.sending.Unlock()
+
+	// 注册这个调用
+	seq, err := client.registerCall(call)
+	if err != nil {
+		call.Error = err
+		client.finishCall(call)
+		return
+	}
+
+	// 准备请求头
+	client.header.ServiceMethod = call.ServiceMethod
+	client.header.Seq = seq
+	client.header.Error = ""
+	client.header.RequestID = call.RequestID
+	client.header.Token = call.token
+
+	// 编码并发送请求
+	// 写入成功后调用可能已经完成并被 Client.Call 放回 callPool，不能再访问 call
+	if err := client.cc.Write(&client.header, call.Args); err == nil {
+		client.observeSizes(client.header.ServiceMethod, true)
+	} else {
+		call := client.removeCall(seq)
+		// call 可能为 nil，通常意味着 Write 部分失败，
+		// 客户端已经收到响应并处理
+		if call != nil {
+			call.Error = err
+			client.finishCall(call)
+		}
+	}
+}
+
+// Go 异步调用函数，返回表示该调用的 Call 结构体
+func (client *Client) Go(serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	return client.start(newRequestID(), "", serviceMethod, args, reply, done)
+}
+
+// start 使用给定的请求 ID 和单次调用的凭证发起异步调用
+func (client *Client) start(requestID, token, serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	if done == nil {
+		done = make(chan *Call, 10)
+	} else if cap(done) == 0 {
+		log.Panic("rpc client: done channel is unbuffered")
+	}
+	call := &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         reply,
+		Done:          done,
+		RequestID:     requestID,
+		token:         token,
+		start:         time.Now(),
+	}
+	client.send(call)
+	return call
+}
+
+// callPool 缓存 Client.Call 使用的 Call 及其 Done 通道。Go 返回的 Call 交给了调用方，不放入池中
+var callPool = sync.Pool{New: func() interface{} { return &Call{Done: make(chan *Call, 1)} }}
+
+// Call 调用指定的函数，等待其完成，并返回错误状态
+func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	call := callPool.Get().(*Call)
+	call.ServiceMethod, call.Args, call.Reply = serviceMethod, args, reply
+	call.RequestID, call.token, call.start = requestID, credentialsFromContext(ctx), time.Now()
+	client.send(call)
+	select {
+	case <-ctx.Done():
+		err := errors.New("rpc client: call failed: " + ctx.Err().Error() + " (request_id=" + requestID + ")")
+		if call := client.removeCall(call.Seq); call != nil {
+			call.Error = err
+			client.observeCall(call)
+		}
+		client.opt.Events.Publish(CallTimedOut{
+			Time:          time.Now(),
+			Side:          "client",
+			ServiceMethod: serviceMethod,
+			RequestID:     requestID,
+			Remote:        client.peer,
+			Err:           err.Error(),
+		})
+		// 调用仍可能被接收响应的协程完成，因此不放回 callPool
+		return err
+	case <-call.Done:
+		err := call.Error
+		*call = Call{Done: call.Done}
+		callPool.Put(call)
+		return err
+	}
+}
+
+// parseOptions 解析选项，未提供时使用默认选项
+func parseOptions(opts ...*Option) (*Option, error) {
+	// 如果 opts 为空或传入 nil
+	if len(opts) == 0 || opts[0] == nil {
+		return DefaultOption, nil
+	}
+	if len(opts) != 1 {
+		return nil, errors.New("number of options is more than 1")
+	}
+	opt := opts[0]
+	opt.MagicNumber = DefaultOption.MagicNumber
+	if opt.CodecType == "" {
+		opt.CodecType = DefaultOption.CodecType
+	}
+	return opt, nil
+}
+
+// NewClient 创建一个 Client 实例，并与服务端交换选项
+func NewClient(conn net.Conn, opt *Option) (*Client, error) {
+	f := codec.NewCodecFuncMap[opt.CodecType]
+	if f == nil {
+		err := fmt.Errorf("invalid codec type %s", opt.CodecType)
+		opt.log().Error("rpc client: codec error", "err", err)
+		return nil, err
+	}
+	if opt.SigningKey != nil && opt.SigningKeyID == "" {
+		err := errors.New("rpc client: SigningKeyID is required when SigningKey is set")
+		opt.log().Error("rpc client: options error", "err", err)
+		return nil, err
+	}
+	if opt.Encryption != nil && opt.Encryption.Primary() == "" {
+		err := errors.New("rpc client: Encryption has no keys")
+		opt.log().Error("rpc client: options error", "err", err)
+		return nil, err
+	}
+	var rwc io.ReadWriteCloser = conn
+	if opt.Capture != nil {
+		rwc = opt.Capture.wrap(conn)
+	}
+	// 将选项发送给服务端，声明可以接收分块的返回值，启用签名时为本次握手填写时间戳和随机数，不修改调用方的 Option
+	handshake := *opt
+	handshake.Chunking = true
+	if opt.SigningKey != nil {
+		handshake.SigningTimestamp = time.Now().Unix()
+		handshake.SigningNonce = newSigningNonce()
+	}
+	handshake.Encrypted = opt.Encryption != nil
+	if err := json.NewEncoder(rwc).Encode(&handshake); err != nil {
+		opt.log().Error("rpc client: options error", "err", err)
+		_ = conn.Close()
+		return nil, err
+	}
+	if opt.SigningKey != nil {
+		rwc = newSignedConn(rwc, sessionKey(opt.SigningKey, handshake.SigningTimestamp, handshake.SigningNonce), signClient)
+	}
+	if opt.Encryption != nil {
+		rwc = newEncryptedConn(rwc, opt.Encryption, signClient)
+	}
+	return newClientCodec(f(rwc), opt, conn.RemoteAddr().String()), nil
+}
+
+// newClientCodec 使用编解码器创建 Client 实例，并启动接收协程
+func newClientCodec(cc codec.Codec, opt *Option, peer string) *Client {
+	client := &Client{
+		seq:      1, // 序号从 1 开始，0 表示无效调用
+		cc:       cc,
+		opt:      opt,
+		pending:  make(map[uint64]*Call),
+		peer:     peer,
+		maxReply: codec.DefaultMaxMessageSize,
+	}
+	go client.receive()
+	return client
+}
+
+type clientResult struct {
+	client *Client
+	err    error
+}
+
+type newClientFunc func(conn net.Conn, opt *Option) (client *Client, err error)
+
+// dialTimeout 带超时地连接服务端并创建客户端
+func dialTimeout(f newClientFunc, network, address string, opts ...*Option) (client *Client, err error) {
+	return dialTimeoutALPN(f, alpnProtocol, network, address, opts...)
+}
+
+// dialTimeoutALPN 与 dialTimeout 相同，设置了 Option.TLSConfig 时在 TLS 握手中协商 ALPN 协议 proto，
+// TLS 握手也受 ConnectTimeout 的限制
+func dialTimeoutALPN(f newClientFunc, proto, network, address string, opts ...*Option) (client *Client, err error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout(network, address, opt.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// 如果创建客户端失败，关闭连接
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+	ch := make(chan clientResult)
+	go func() {
+		var c net.Conn = conn
+		if opt.TLSConfig != nil {
+			var err error
+			if c, err = clientTLS(conn, opt.TLSConfig, address, proto); err != nil {
+				ch <- clientResult{err: err}
+				return
+			}
+		}
+		client, err := f(c, opt)
+		ch <- clientResult{client: client, err: err}
+	}()
+	if opt.ConnectTimeout == 0 {
+		result := <-ch
+		return result.client, result.err
+	}
+	select {
+	case <-time.After(opt.ConnectTimeout):
+		return nil, fmt.Errorf("rpc client: connect timeout: expect within %s", opt.ConnectTimeout)
+	case result := <-ch:
+		return result.client, result.err
+	}
+}
+
+// Dial 连接到指定网络地址的 RPC 服务器
+func Dial(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewClient, network, address, opts...)
+}
 
 // NewHTTPClient 通过 HTTP 连接创建一个 Client 实例
 func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
@@ -81,12 +402,14 @@ func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
 
 // DialHTTP 连接到指定网络地址的 HTTP RPC 服务器
 func DialHTTP(network, address string, opts ...*Option) (*Client, error) {
-	return dialTimeout(NewHTTPClient, network, address, opts...)
+	return dialTimeoutALPN(NewHTTPClient, alpnHTTP, network, address, opts...)
 }
 
 // XDial 根据第一个参数 rpcAddr 调用不同的函数来连接到 RPC 服务器
 // rpcAddr 是一个通用格式（protocol@addr），用于表示 RPC 服务器
-// 例如，http@10.0.0.1:7001，tcp@10.0.0.1:9999，unix@/tmp/geerpc.sock
+// 例如，http@10.0.0.1:7001，tcp@10.0.0.1:9999，unix@/tmp/geerpc.sock，
+// inproc@name 连接到 ListenInProc 创建的同名进程内监听器，
+// ws@10.0.0.1:7001 和 wss@10.0.0.1:7001 通过 WebSocket 连接到 HTTP RPC 服务器的默认路径
 func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
 	parts := strings.Split(rpcAddr, "@")
 	if len(parts) != 2 {
@@ -96,6 +419,10 @@ func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
 	switch protocol {
 	case "http":
 		return DialHTTP("tcp", addr, opts...)
+	case "inproc":
+		return dialInProc(addr, opts...)
+	case "ws", "wss":
+		return DialWebSocket(protocol+"://"+addr, opts...)
 	default:
 		// tcp, unix 或其他传输协议
 		return Dial(protocol, addr, opts...)
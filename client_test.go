@@ -1,11 +1,20 @@
 package geerpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"geerpc/codec"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -17,6 +26,19 @@ func (b Bar) Timeout(argv int, reply *int) error {
 	return nil
 }
 
+func (b Bar) RequestID(ctx context.Context, argv int, reply *string) error {
+	*reply = RequestIDFromContext(ctx)
+	return nil
+}
+
+func (b Bar) Blob(n int, reply *[]byte) error {
+	*reply = make([]byte, n)
+	for i := range *reply {
+		(*reply)[i] = byte(i)
+	}
+	return nil
+}
+
 func startServer(addr chan string) {
 	var b Bar
 	_ = Register(&b)
@@ -53,7 +75,8 @@ func TestClient_Call(t *testing.T) {
 	time.Sleep(time.Second)
 	t.Run("client timeout", func(t *testing.T) {
 		client, _ := Dial("tcp", addr)
-		ctx, _ := context.WithTimeout(context.Background(), time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 		var reply int
 		err := client.Call(ctx, "Bar.Timeout", 1, &reply)
 		_assert(err != nil && strings.Contains(err.Error(), ctx.Err().Error()), "expect a timeout error")
@@ -66,6 +89,14 @@ func TestClient_Call(t *testing.T) {
 		err := client.Call(context.Background(), "Bar.Timeout", 1, &reply)
 		_assert(err != nil && strings.Contains(err.Error(), "handle timeout"), "expect a timeout error")
 	})
+	t.Run("request id", func(t *testing.T) {
+		client, _ := Dial("tcp", addr)
+		var reply string
+		err := client.Call(WithRequestID(context.Background(), "req-1"), "Bar.RequestID", 1, &reply)
+		_assert(err == nil && reply == "req-1", "expect the request id to be propagated")
+		err = client.Call(context.Background(), "Bar.RequestID", 1, &reply)
+		_assert(err == nil && reply != "" && reply != "req-1", "expect a generated request id")
+	})
 }
 
 func TestXDial(t *testing.T) {
@@ -76,7 +107,9 @@ func TestXDial(t *testing.T) {
 			_ = os.Remove(addr)
 			l, err := net.Listen("unix", addr)
 			if err != nil {
-				t.Fatal("failed to listen unix socket")
+				t.Error("failed to listen unix socket")
+				close(ch)
+				return
 			}
 			ch <- struct{}{}
 			Accept(l)
@@ -86,3 +119,367 @@ func TestXDial(t *testing.T) {
 		_assert(err == nil, "failed to connect unix socket")
 	}
 }
+
+func TestDialInProc(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	client, err := DialInProc(server)
+	_assert(err == nil, "failed to dial in-process server")
+	var reply string
+	err = client.Call(WithRequestID(context.Background(), "req-1"), "Bar.RequestID", 1, &reply)
+	_assert(err == nil && reply == "req-1", "expect the call to go through the pipe")
+
+	l, err := ListenInProc("geerpc-test")
+	_assert(err == nil, "failed to listen in-process")
+	go server.Accept(l)
+	_, err = XDial("inproc@geerpc-test")
+	_assert(err == nil, "failed to connect in-process listener")
+	_ = l.Close()
+	_, err = XDial("inproc@geerpc-test")
+	_assert(err != nil, "expect closed listener to refuse connections")
+}
+
+// 选项和第一个请求在同一次写入中到达时，JSON 解码器会多读请求的数据，
+// 服务端需要把这部分数据交还给编解码器，而不是丢弃第一个请求
+func TestServer_HandshakeBufferedRequest(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	cliConn, srvConn := net.Pipe()
+	go server.ServeConn(srvConn)
+	defer func() { _ = cliConn.Close() }()
+
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(DefaultOption)
+	h := &codec.Header{ServiceMethod: "Bar.RequestID", Seq: 1, RequestID: "req-1"}
+	if err := codec.NewGobCodec(memConn{Writer: &buf}).Write(h, 1); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _, _ = cliConn.Write(buf.Bytes()) }()
+
+	_ = cliConn.SetDeadline(time.Now().Add(time.Second))
+	cc := codec.NewGobCodec(cliConn)
+	var rh codec.Header
+	var reply string
+	if err := cc.ReadHeader(&rh); err != nil {
+		t.Fatalf("expect a response to the first request, got %v", err)
+	}
+	if err := cc.ReadBody(&reply); err != nil || rh.Seq != 1 || rh.Error != "" || reply != "req-1" {
+		t.Fatalf("expect reply req-1 for seq 1, got seq %d %q, error %q, err %v", rh.Seq, reply, rh.Error, err)
+	}
+}
+
+// writeCounter 统计写入连接的次数
+type writeCounter struct {
+	net.Conn
+	writes int32
+}
+
+func (c *writeCounter) Write(p []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return c.Conn.Write(p)
+}
+
+func TestServer_WriteCoalescing(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetConnRateLimit(0, 0)
+	server.SetWriteCoalescing(50*time.Millisecond, 0)
+	cliConn, srvConn := net.Pipe()
+	counter := &writeCounter{Conn: srvConn}
+	go server.ServeConn(counter)
+	client, err := NewClient(cliConn, DefaultOption)
+	_assert(err == nil, "failed to create client")
+	defer func() { _ = client.Close() }()
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply string
+			id := fmt.Sprintf("req-%d", i)
+			if err := client.Call(WithRequestID(context.Background(), id), "Bar.RequestID", i, &reply); err != nil || reply != id {
+				errs <- fmt.Errorf("call %d: reply %q, err %v", i, reply, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if writes := atomic.LoadInt32(&counter.writes); writes >= n {
+		t.Fatalf("expect %d responses to be coalesced, got %d writes", n, writes)
+	}
+}
+
+// Blocker 的方法阻塞到 release 被关闭
+type Blocker struct{ release chan struct{} }
+
+func (b *Blocker) Wait(argv int, reply *int) error {
+	<-b.release
+	*reply = argv
+	return nil
+}
+
+func TestServer_HandleTimeoutRelease(t *testing.T) {
+	server := NewServer()
+	b := &Blocker{release: make(chan struct{})}
+	_ = server.Register(b)
+	server.SetConnRateLimit(0, 0)
+	cliConn, srvConn := net.Pipe()
+	counter := &writeCounter{Conn: srvConn}
+	go server.ServeConn(counter)
+	opt := *DefaultOption
+	opt.HandleTimeout = 50 * time.Millisecond
+	client, err := NewClient(cliConn, &opt)
+	_assert(err == nil, "failed to create client")
+	defer func() { _ = client.Close() }()
+
+	before := runtime.NumGoroutine()
+	var reply int
+	err = client.Call(context.Background(), "Blocker.Wait", 1, &reply)
+	_assert(err != nil && strings.Contains(err.Error(), "handle timeout"), "expect a timeout error")
+	writes := atomic.LoadInt32(&counter.writes)
+
+	// 方法在超时后返回，执行它的协程应当退出，且不再发送第二个响应
+	close(b.release)
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("expect goroutines to return to %d after the handler returns, got %d", before, n)
+	}
+	if w := atomic.LoadInt32(&counter.writes); w != writes {
+		t.Fatalf("expect no response after the timeout one, got %d more writes", w-writes)
+	}
+	err = client.Call(context.Background(), "Blocker.Wait", 2, &reply)
+	_assert(err == nil && reply == 2, "expect the connection to keep serving calls")
+}
+
+func TestServer_ChunkSize(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetConnRateLimit(0, 0)
+	server.SetChunkSize(4 << 10)
+	for _, ct := range []codec.Type{codec.GobType, codec.JsonType} {
+		cliConn, srvConn := net.Pipe()
+		counter := &writeCounter{Conn: srvConn}
+		go server.ServeConn(counter)
+		opt, _ := parseOptions(&Option{CodecType: ct})
+		client, err := NewClient(cliConn, opt)
+		_assert(err == nil, "failed to create client")
+
+		// 大的返回值分块发送，期间同一连接上的其他调用照常完成
+		const size = 64 << 10
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				var reply []byte
+				err := client.Call(context.Background(), "Bar.Blob", size, &reply)
+				if err != nil || len(reply) != size || reply[size-1] != byte((size-1)%256) {
+					t.Errorf("%s: expect a %d-byte reply, got %d bytes, err %v", ct, size, len(reply), err)
+				}
+			}()
+			go func(i int) {
+				defer wg.Done()
+				var reply string
+				id := fmt.Sprintf("req-%d", i)
+				if err := client.Call(WithRequestID(context.Background(), id), "Bar.RequestID", i, &reply); err != nil || reply != id {
+					t.Errorf("%s: call %d: reply %q, err %v", ct, i, reply, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if writes := atomic.LoadInt32(&counter.writes); writes < 4*size/(4<<10) {
+			t.Fatalf("%s: expect large replies to be sent in chunks, got %d writes", ct, writes)
+		}
+		_ = client.Close()
+	}
+}
+
+func TestClient_ChunkedReplyLimit(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetConnRateLimit(0, 0)
+	server.SetChunkSize(4 << 10)
+	client, err := DialInProc(server)
+	_assert(err == nil, "failed to create client")
+	defer func() { _ = client.Close() }()
+	client.maxReply = 16 << 10
+
+	// 分块的总大小超过限制时调用失败，即使每个分块都不超过单个消息的限制
+	var reply []byte
+	err = client.Call(context.Background(), "Bar.Blob", 64<<10, &reply)
+	if err == nil || !strings.Contains(err.Error(), codec.ErrMessageTooLarge.Error()) {
+		t.Fatalf("expect the reply to be rejected, got %d bytes, err %v", len(reply), err)
+	}
+	// 被拒绝的返回值剩余的分块被丢弃，连接上之后的调用不受影响
+	if err := client.Call(context.Background(), "Bar.Blob", 12<<10, &reply); err != nil || len(reply) != 12<<10 {
+		t.Fatalf("expect a reply within the limit, got %d bytes, err %v", len(reply), err)
+	}
+	var id string
+	if err := client.Call(WithRequestID(context.Background(), "req-1"), "Bar.RequestID", 1, &id); err != nil || id != "req-1" {
+		t.Fatalf("expect the connection to keep serving calls, got %q, err %v", id, err)
+	}
+}
+
+func TestServer_AcceptEvented(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetConnRateLimit(0, 0)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	accepted := make(chan error, 1)
+	go func() { accepted <- server.AcceptEvented(l) }()
+
+	var clients []*Client
+	for _, ct := range []codec.Type{codec.GobType, codec.JsonType} {
+		for i := 0; i < 3; i++ {
+			client, err := Dial("tcp", l.Addr().String(), &Option{CodecType: ct})
+			if err != nil {
+				select {
+				case err := <-accepted:
+					t.Skip(err)
+				default:
+				}
+				t.Fatal(err)
+			}
+			clients = append(clients, client)
+		}
+	}
+	// 每个连接上依次进行几轮并发调用，轮次之间连接空闲，在事件循环中等待
+	for round := 0; round < 3; round++ {
+		var wg sync.WaitGroup
+		for i, client := range clients {
+			for j := 0; j < 5; j++ {
+				wg.Add(1)
+				go func(client *Client, id string) {
+					defer wg.Done()
+					var reply string
+					err := client.Call(WithRequestID(context.Background(), id), "Bar.RequestID", 1, &reply)
+					if err != nil || reply != id {
+						t.Errorf("call %s: reply %q, err %v", id, reply, err)
+					}
+				}(client, fmt.Sprintf("%d-%d-%d", round, i, j))
+			}
+		}
+		wg.Wait()
+		time.Sleep(20 * time.Millisecond)
+	}
+	_assert(len(server.Connections()) == len(clients), "expect %d connections", len(clients))
+
+	// 客户端关闭的连接由事件循环发现并清理
+	_ = clients[0].Close()
+	for i := 0; i < 100 && len(server.Connections()) != len(clients)-1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_assert(len(server.Connections()) == len(clients)-1, "expect the closed connection to be untracked")
+
+	// 关闭服务器时，在事件循环中等待的连接也被关闭
+	err := server.Shutdown(context.Background())
+	_assert(err == nil, "expect shutdown to succeed")
+	_assert(<-accepted == nil, "expect AcceptEvented to return after shutdown")
+	for i := 0; i < 100 && len(server.Connections()) != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	_assert(len(server.Connections()) == 0, "expect all connections to be closed")
+	var reply string
+	err = clients[1].Call(context.Background(), "Bar.RequestID", 1, &reply)
+	_assert(err != nil, "expect calls on a closed connection to fail")
+}
+
+func TestDialWebSocket(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	for _, ct := range []codec.Type{codec.GobType, codec.JsonType} {
+		client, err := XDial("ws@"+addr, &Option{CodecType: ct})
+		_assert(err == nil, "failed to dial websocket")
+		var reply string
+		err = client.Call(WithRequestID(context.Background(), "req-1"), "Bar.RequestID", 1, &reply)
+		_assert(err == nil && reply == "req-1", "expect the call to go through the websocket with "+string(ct))
+		_ = client.Close()
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+defaultRPCPath, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "http://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	_assert(err == nil && resp.StatusCode == http.StatusForbidden, "expect cross-origin websocket to be rejected")
+	_ = resp.Body.Close()
+}
+
+func TestServer_SetConnRateLimit(t *testing.T) {
+	cases := []struct {
+		burst, perSecond int
+		capacity         int // 0 表示不限制
+	}{
+		{10, 2, 10},
+		{3, 5, 3},
+		{0, 5, 5}, // burst 小于 1 时与 perSecond 相同
+		{10, 0, 0},
+		{10, -1, 0},
+	}
+	for _, c := range cases {
+		server := NewServer()
+		server.SetConnRateLimit(c.burst, c.perSecond)
+		cc := server.newCodecConn(nil, DefaultOption, nil)
+		if c.capacity == 0 {
+			_assert(cc.tb == nil, "expect no rate limit for perSecond %d", c.perSecond)
+			continue
+		}
+		_assert(cc.tb != nil && cc.tb.capacity == c.capacity && cc.tb.refillAmount == c.perSecond,
+			"expect capacity %d and %d tokens per second, got %+v", c.capacity, c.perSecond, cc.tb)
+	}
+	if cc := NewServer().newCodecConn(nil, DefaultOption, nil); cc.tb == nil || cc.tb.capacity != defaultConnRateLimit.burst {
+		t.Fatalf("expect the default rate limit, got %+v", cc.tb)
+	}
+
+	// 不限制时连续的调用不会被令牌桶推迟
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetConnRateLimit(0, 0)
+	client, err := DialInProc(server)
+	_assert(err == nil, "failed to dial in-process server")
+	defer func() { _ = client.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 30; i++ {
+		var reply string
+		if err := client.Call(ctx, "Bar.RequestID", 1, &reply); err != nil {
+			t.Fatalf("call %d: expect no rate limit, got %v", i, err)
+		}
+	}
+}
+
+func TestServer_Services(t *testing.T) {
+	server := NewServer()
+	if names := server.Services(); len(names) != 0 {
+		t.Fatalf("expect no services on a new server, got %v", names)
+	}
+	var b Bar
+	var foo Foo
+	_ = server.Register(&b)
+	_ = server.RegisterName("Arith", &foo)
+	if names := server.Services(); !reflect.DeepEqual(names, []string{"Arith", "Bar"}) {
+		t.Fatalf("expect registered services sorted by name, got %v", names)
+	}
+}
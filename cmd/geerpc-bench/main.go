@@ -0,0 +1,211 @@
+// geerpc-bench 对 geerpc 服务进行压测，输出每组并发数和参数大小下的吞吐量和延迟分位数。
+//
+// 用法：
+//
+//	geerpc-bench -serve <protocol@addr>
+//	geerpc-bench [flags] <protocol@addr>
+//
+// -serve 启动一个注册了 bench.Echo 的服务器，用于单独评估编解码器和传输层的开销。
+// 压测时 -c 和 -size 可以是逗号分隔的多个值，每种组合依次运行一轮，例如：
+//
+//	geerpc-bench -c 1,16,64 -size 64,4096 -d 10s tcp@localhost:9999
+//
+// 默认的压测目标是 Echo.Echo，-method 指定的其他方法的参数也必须是 []byte，返回值是 *[]byte。
+// 服务器默认限制每个连接的请求速率，压测其他服务器时需要先通过 Server.SetConnRateLimit 放开限制，
+// -serve 启动的服务器不限制速率。
+//
+// -cpuprofile 和 -memprofile 采集压测客户端（或 -serve 启动的服务器，收到中断信号退出时写入）的性能数据，
+// 可以用 go tool pprof 分析。编解码器和传输层的固定场景参见 geerpc/bench 包中的 go test -bench 基准测试
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"geerpc"
+	"geerpc/bench"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("geerpc-bench: ")
+	serve := flag.Bool("serve", false, "启动注册了 Echo 服务的服务器，而不是进行压测")
+	method := flag.String("method", bench.DefaultServiceMethod, "压测的方法")
+	concurrency := flag.String("c", "1", "并发数，多个值以逗号分隔")
+	sizes := flag.String("size", "64", "参数的字节数，多个值以逗号分隔")
+	requests := flag.Int("n", 0, "每轮的请求数")
+	duration := flag.Duration("d", 10*time.Second, "每轮的持续时间，设置了 -n 时默认不限制")
+	conns := flag.Int("conns", 1, "连接数，并发的调用轮流使用这些连接")
+	timeout := flag.Duration("timeout", 10*time.Second, "连接和单次调用的超时时间")
+	token := flag.String("token", "", "握手时发送的凭证（Option.Credentials）")
+	useTLS := flag.Bool("tls", false, "通过 TLS 连接服务器")
+	insecure := flag.Bool("insecure", false, "使用 TLS 时不校验服务器证书")
+	cpuprofile := flag.String("cpuprofile", "", "将 CPU 性能数据写入该文件")
+	memprofile := flag.String("memprofile", "", "退出时将堆内存数据写入该文件")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: geerpc-bench -serve <protocol@addr>\n")
+		fmt.Fprintf(os.Stderr, "       geerpc-bench [flags] <protocol@addr>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	stopProfile, err := bench.StartProfile(*cpuprofile, *memprofile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *serve {
+		// 服务器一直运行，收到中断信号时写入性能数据后退出
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			<-sig
+			if err := stopProfile(); err != nil {
+				log.Fatal(err)
+			}
+			os.Exit(0)
+		}()
+		log.Fatal(serveEcho(flag.Arg(0)))
+	}
+	cs, err := parseInts(*concurrency)
+	if err != nil {
+		log.Fatal("-c: ", err)
+	}
+	ss, err := parseInts(*sizes)
+	if err != nil {
+		log.Fatal("-size: ", err)
+	}
+	d := *duration
+	if *requests > 0 && !flagSet("d") {
+		d = 0
+	}
+
+	opt := *geerpc.DefaultOption
+	opt.ConnectTimeout = *timeout
+	opt.Credentials = *token
+	if *useTLS || *insecure {
+		opt.TLSConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	}
+	p := &pool{}
+	for i := 0; i < *conns; i++ {
+		client, err := geerpc.XDial(flag.Arg(0), &opt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() { _ = client.Close() }()
+		p.clients = append(p.clients, client)
+	}
+
+	fmt.Printf("%11s %8s %9s %7s %10s %10s %10s %10s %10s %10s %10s\n",
+		"concurrency", "size", "requests", "errors", "req/s", "mean", "p50", "p90", "p99", "p99.9", "max")
+	for _, c := range cs {
+		for _, size := range ss {
+			r, err := bench.Run(context.Background(), p, bench.Config{
+				ServiceMethod: *method,
+				Concurrency:   c,
+				Requests:      *requests,
+				Duration:      d,
+				PayloadSize:   size,
+				Timeout:       *timeout,
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%11d %8d %9d %7d %10.1f %10v %10v %10v %10v %10v %10v\n", c, size, r.Requests, r.Errors,
+				r.Throughput, round(r.Mean), round(r.P50), round(r.P90), round(r.P99), round(r.P999), round(r.Max))
+			if r.FirstError != nil {
+				log.Printf("concurrency=%d size=%d: first error: %v", c, size, r.FirstError)
+			}
+		}
+	}
+	if err := stopProfile(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// pool 将调用轮流分配给多个连接
+type pool struct {
+	next    uint64
+	clients []*geerpc.Client
+}
+
+func (p *pool) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	i := atomic.AddUint64(&p.next, 1)
+	return p.clients[i%uint64(len(p.clients))].Call(ctx, serviceMethod, args, reply)
+}
+
+// serveEcho 在 rpcAddr（格式与 XDial 相同）上启动注册了 bench.Echo 的服务器
+func serveEcho(rpcAddr string) error {
+	parts := strings.SplitN(rpcAddr, "@", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("wrong format '%s', expect protocol@addr", rpcAddr)
+	}
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	if err := server.Register(bench.Echo{}); err != nil {
+		return err
+	}
+	network := parts[0]
+	if network == "http" {
+		network = "tcp"
+	}
+	l, err := net.Listen(network, parts[1])
+	if err != nil {
+		return err
+	}
+	log.Printf("serving %s on %s", bench.DefaultServiceMethod, l.Addr())
+	if parts[0] == "http" {
+		return http.Serve(l, server)
+	}
+	server.Accept(l)
+	return nil
+}
+
+// round 保留 3 位有效数字左右的精度，使表格对齐
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
+
+func parseInts(s string) ([]int, error) {
+	var ns []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid value %d", n)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// flagSet 返回命令行中是否显式设置了名为 name 的参数
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"geerpc"
+	"geerpc/bench"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInts(t *testing.T) {
+	if got, err := parseInts("1, 4,16"); err != nil || !reflect.DeepEqual(got, []int{1, 4, 16}) {
+		t.Fatalf("expect [1 4 16], got %v, err %v", got, err)
+	}
+	for _, s := range []string{"", "1,x", "-1"} {
+		if _, err := parseInts(s); err == nil {
+			t.Fatalf("expect an error for %q", s)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	cases := map[time.Duration]time.Duration{
+		1234567890: 1235 * time.Millisecond,
+		1234567:    1235 * time.Microsecond,
+		1234:       1234,
+	}
+	for d, want := range cases {
+		if got := round(d); got != want {
+			t.Fatalf("expect %v for %v, got %v", want, d, got)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	_ = server.Register(bench.Echo{})
+	p := new(pool)
+	for i := 0; i < 2; i++ {
+		client, err := geerpc.DialInProc(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = client.Close() }()
+		p.clients = append(p.clients, client)
+	}
+	r, err := bench.Run(context.Background(), p, bench.Config{Concurrency: 2, Requests: 20, PayloadSize: 8})
+	if err != nil || r.Errors != 0 || r.Requests != 20 {
+		t.Fatalf("expect 20 successful requests, got %v, err %v", r, err)
+	}
+}
@@ -0,0 +1,223 @@
+// geerpc-cli 连接到 geerpc 服务器，列出服务并以 JSON 参数调用方法，用于临时调试，无需为每次调用编写 Go 程序。
+//
+// 用法：
+//
+//	geerpc-cli [flags] <protocol@addr> list [Service]
+//	geerpc-cli [flags] <protocol@addr> describe Service.Method
+//	geerpc-cli [flags] <protocol@addr> call Service.Method ['<json>' | -]
+//	geerpc-cli [flags] <protocol@addr>
+//
+// 地址的格式与 geerpc.XDial 相同，例如 tcp@localhost:9999、http@localhost:7001、unix@/tmp/geerpc.sock。
+// 服务器需要调用 Server.SetReflection(true)，geerpc-cli 通过内置的 Reflection 服务获取参数和返回值的结构，
+// 据此将 JSON 参数转换为对应的类型，并将返回值输出为 JSON。call 的参数为 - 时从标准输入读取，省略时使用零值。
+// 不指定命令时进入交互模式，每行输入一条命令。
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"geerpc"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("geerpc-cli: ")
+	timeout := flag.Duration("timeout", 10*time.Second, "连接和每次调用的超时时间")
+	token := flag.String("token", "", "握手时发送的凭证（Option.Credentials）")
+	useTLS := flag.Bool("tls", false, "通过 TLS 连接服务器")
+	insecure := flag.Bool("insecure", false, "使用 TLS 时不校验服务器证书")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: geerpc-cli [flags] <protocol@addr> [list [Service] | describe Service.Method | call Service.Method ['<json>' | -]]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opt := *geerpc.DefaultOption
+	opt.ConnectTimeout = *timeout
+	opt.Credentials = *token
+	if *useTLS || *insecure {
+		opt.TLSConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	}
+	client, err := geerpc.XDial(flag.Arg(0), &opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	c := &cli{client: client, timeout: *timeout, out: os.Stdout}
+
+	if flag.NArg() == 1 {
+		c.repl(os.Stdin)
+		return
+	}
+	if err := c.run(flag.Args()[1:], os.Stdin); err != nil {
+		_ = client.Close()
+		log.Fatal(err)
+	}
+}
+
+type cli struct {
+	client  *geerpc.Client
+	timeout time.Duration
+	out     io.Writer
+}
+
+// run 执行一条命令，stdin 用于读取 call 的 - 参数
+func (c *cli) run(args []string, stdin io.Reader) error {
+	switch args[0] {
+	case "list":
+		if len(args) > 2 {
+			return errors.New("usage: list [Service]")
+		}
+		service := ""
+		if len(args) == 2 {
+			service = args[1]
+		}
+		return c.list(service)
+	case "describe":
+		if len(args) != 2 {
+			return errors.New("usage: describe Service.Method")
+		}
+		return c.describe(args[1])
+	case "call":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("usage: call Service.Method ['<json>' | -]")
+		}
+		var input string
+		if len(args) == 3 {
+			input = args[2]
+		}
+		if input == "-" {
+			b, err := ioutil.ReadAll(stdin)
+			if err != nil {
+				return err
+			}
+			input = string(b)
+		}
+		return c.call(args[1], input)
+	default:
+		return fmt.Errorf("unknown command %q, expect list, describe or call", args[0])
+	}
+}
+
+// repl 逐行读取并执行命令，直到输入 quit 或读到 EOF。call 的 JSON 参数可以包含空格，作为该行剩余的部分
+func (c *cli) repl(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	prompt := func() { _, _ = fmt.Fprint(c.out, "> ") }
+	for prompt(); scanner.Scan(); prompt() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return
+		}
+		args := strings.Fields(line)
+		if args[0] == "call" && len(args) > 2 {
+			rest := strings.TrimSpace(strings.TrimPrefix(line, "call"))
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, args[1]))
+			args = []string{"call", args[1], rest}
+		}
+		if err := c.run(args, strings.NewReader("")); err != nil {
+			_, _ = fmt.Fprintln(c.out, "error:", err)
+		}
+		if !c.client.IsAvailable() {
+			_, _ = fmt.Fprintln(c.out, "error: connection closed")
+			return
+		}
+	}
+}
+
+// services 通过 Reflection 服务获取服务的结构，service 为空时返回所有服务
+func (c *cli) services(service string) ([]geerpc.ServiceSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	var reply []geerpc.ServiceSchema
+	if err := c.client.Call(ctx, "Reflection.List", service, &reply); err != nil {
+		if strings.Contains(err.Error(), "can't find service") {
+			return nil, fmt.Errorf("%v (the server must enable Server.SetReflection)", err)
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// method 查找 serviceMethod 的结构
+func (c *cli) method(serviceMethod string) (*geerpc.MethodSchema, error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return nil, fmt.Errorf("service/method request ill-formed: %s", serviceMethod)
+	}
+	services, err := c.services(serviceMethod[:dot])
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range services {
+		for i := range s.Methods {
+			if s.Methods[i].Name == serviceMethod[dot+1:] {
+				return &s.Methods[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("can't find method %s", serviceMethod)
+}
+
+func (c *cli) list(service string) error {
+	services, err := c.services(service)
+	if err != nil {
+		return err
+	}
+	if service != "" && len(services) == 0 {
+		return fmt.Errorf("can't find service %s", service)
+	}
+	for _, s := range services {
+		for _, m := range s.Methods {
+			_, _ = fmt.Fprintf(c.out, "%s.%s(%s) %s\n", s.Name, m.Name, typeName(m.Args), typeName(m.Reply))
+		}
+	}
+	return nil
+}
+
+func (c *cli) describe(serviceMethod string) error {
+	m, err := c.method(serviceMethod)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(c.out, "args:  %s\nreply: %s\n", typeString(m.Args, ""), typeString(m.Reply, ""))
+	return nil
+}
+
+func (c *cli) call(serviceMethod, input string) error {
+	m, err := c.method(serviceMethod)
+	if err != nil {
+		return err
+	}
+	args, err := newValue(m.Args, input)
+	if err != nil {
+		return fmt.Errorf("args: %v", err)
+	}
+	reply, err := newValue(m.Reply, "")
+	if err != nil {
+		return fmt.Errorf("reply: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if err := c.client.Call(ctx, serviceMethod, args.Elem().Interface(), reply.Interface()); err != nil {
+		return err
+	}
+	return printJSON(c.out, reply.Interface())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"geerpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Shop struct{}
+
+func (Shop) Place(args Order, reply *Order) error {
+	*reply = args
+	reply.Items = append(reply.Items, "receipt")
+	return nil
+}
+
+func (Shop) Ping(args struct{}, reply *string) error {
+	*reply = "pong"
+	return nil
+}
+
+func TestCli(t *testing.T) {
+	server := geerpc.NewServer()
+	_ = server.Register(Shop{})
+	server.SetReflection(true)
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var out bytes.Buffer
+	c := &cli{client: client, timeout: time.Second, out: &out}
+
+	if err := c.run([]string{"list", "Shop"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Shop.Ping(struct{...}) string\nShop.Place(main.Order) main.Order\n" {
+		t.Fatalf("unexpected list output %q", out.String())
+	}
+	if err := c.run([]string{"list", "Missing"}, nil); err == nil {
+		t.Fatal("expect an error listing an unknown service")
+	}
+
+	out.Reset()
+	if err := c.run([]string{"describe", "Shop.Place"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "args:  main.Order struct {\n\tID int64\n") {
+		t.Fatalf("unexpected describe output %q", out.String())
+	}
+
+	out.Reset()
+	if err := c.run([]string{"call", "Shop.Place", "-"}, strings.NewReader(`{"id": 3, "items": ["x"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"id": 3`) || !strings.Contains(out.String(), `"receipt"`) {
+		t.Fatalf("unexpected call output %q", out.String())
+	}
+	out.Reset()
+	if err := c.run([]string{"call", "Shop.Ping"}, nil); err != nil || out.String() != "\"pong\"\n" {
+		t.Fatalf("expect pong, got %q, %v", out.String(), err)
+	}
+	if err := c.run([]string{"call", "Shop.Missing"}, nil); err == nil {
+		t.Fatal("expect an error calling an unknown method")
+	}
+
+	server.SetReflection(false)
+	if err := c.run([]string{"list"}, nil); err == nil || !strings.Contains(err.Error(), "SetReflection") {
+		t.Fatalf("expect a hint to enable reflection, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var basicTypes = map[string]reflect.Type{
+	"bool":    reflect.TypeOf(false),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"string":  reflect.TypeOf(""),
+	"bytes":   reflect.TypeOf([]byte(nil)),
+	"time":    reflect.TypeOf(time.Time{}),
+}
+
+// goType 根据 TypeSchema 构造一个与服务端类型的 gob 编码兼容的类型，无法构造时返回 nil。
+// gob 按字段名匹配结构体，因此动态构造的匿名结构体可以与服务端的具名类型互相编解码
+func goType(s *geerpc.TypeSchema) reflect.Type {
+	if s == nil {
+		return nil
+	}
+	if t, ok := basicTypes[s.Kind]; ok {
+		return t
+	}
+	switch s.Kind {
+	case "slice":
+		if elem := goType(s.Elem); elem != nil {
+			return reflect.SliceOf(elem)
+		}
+	case "array":
+		if elem := goType(s.Elem); elem != nil {
+			return reflect.ArrayOf(s.Len, elem)
+		}
+	case "map":
+		key, elem := goType(s.Key), goType(s.Elem)
+		if key != nil && elem != nil {
+			return reflect.MapOf(key, elem)
+		}
+	case "struct":
+		var fields []reflect.StructField
+		for _, f := range s.Fields {
+			// 无法构造的字段直接省略，gob 在编解码时会忽略对方多出的字段
+			if t := goType(f.Type); t != nil {
+				field := reflect.StructField{Name: f.Name, Type: t}
+				if f.JSON != "" {
+					// 保留服务端的 json 标签，参数和返回值的 JSON 字段名与服务端一致
+					field.Tag = reflect.StructTag(`json:"` + f.JSON + `"`)
+				}
+				fields = append(fields, field)
+			}
+		}
+		return reflect.StructOf(fields)
+	}
+	return nil
+}
+
+// newValue 创建 s 对应类型的值并返回其指针，input 不为空时将其作为 JSON 解码到该值中
+func newValue(s *geerpc.TypeSchema, input string) (reflect.Value, error) {
+	t := goType(s)
+	if t == nil {
+		return reflect.Value{}, errors.New("unsupported type " + typeName(s))
+	}
+	v := reflect.New(t)
+	switch t.Kind() {
+	case reflect.Map:
+		v.Elem().Set(reflect.MakeMap(t))
+	case reflect.Slice:
+		v.Elem().Set(reflect.MakeSlice(t, 0, 0))
+	}
+	if strings.TrimSpace(input) != "" {
+		dec := json.NewDecoder(strings.NewReader(input))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return v, nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// typeName 返回类型的简短名称，与 typeString 不同，它不展开结构体
+func typeName(s *geerpc.TypeSchema) string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Kind == "slice":
+		return "[]" + typeName(s.Elem)
+	case s.Kind == "array":
+		return "[" + strconv.Itoa(s.Len) + "]" + typeName(s.Elem)
+	case s.Kind == "map":
+		return "map[" + typeName(s.Key) + "]" + typeName(s.Elem)
+	case s.Kind == "struct":
+		return "struct{...}"
+	}
+	return typeString(s, "")
+}
+
+// typeString 以 Go 语法的形式返回类型的结构，结构体展开为多行，indent 为当前的缩进
+func typeString(s *geerpc.TypeSchema, indent string) string {
+	switch s.Kind {
+	case "bytes":
+		return "[]byte"
+	case "time":
+		return "time.Time"
+	case "slice":
+		return "[]" + typeString(s.Elem, indent)
+	case "array":
+		return "[" + strconv.Itoa(s.Len) + "]" + typeString(s.Elem, indent)
+	case "map":
+		return "map[" + typeString(s.Key, indent) + "]" + typeString(s.Elem, indent)
+	case "struct":
+		var b strings.Builder
+		if s.Name != "" {
+			b.WriteString(s.Name + " ")
+		}
+		if len(s.Fields) == 0 {
+			b.WriteString("struct{}")
+			return b.String()
+		}
+		b.WriteString("struct {\n")
+		for _, f := range s.Fields {
+			b.WriteString(indent + "\t" + f.Name + " " + typeString(f.Type, indent+"\t") + "\n")
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	case "opaque", "recursive", "interface":
+		name := s.Name
+		if name == "" {
+			name = s.Kind
+		}
+		return name + " /* " + s.Kind + ", unsupported */"
+	}
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Kind
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"geerpc"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Order struct {
+	ID      int64             `json:"id"`
+	Items   []string          `json:"items"`
+	Counts  map[string]uint16 `json:"counts"`
+	Origin  Point
+	Box     [2]Point
+	Created time.Time
+	Raw     []byte
+	Note    interface{} // 无法构造，调用方省略该字段
+}
+
+// Types 的每个方法的参数类型用于构造测试的 TypeSchema
+type Types struct{}
+
+func (Types) Order(args Order, reply *int) error                { return nil }
+func (Types) Point(args Point, reply *int) error                { return nil }
+func (Types) Map(args map[string]int, reply *int) error         { return nil }
+func (Types) Nested(args []map[string][2]int, reply *int) error { return nil }
+
+func schemaOf(t *testing.T, method string) *geerpc.TypeSchema {
+	server := geerpc.NewServer()
+	_ = server.Register(Types{})
+	for _, s := range server.Schema() {
+		for _, m := range s.Methods {
+			if s.Name == "Types" && m.Name == method {
+				return m.Args
+			}
+		}
+	}
+	t.Fatalf("can't find method Types.%s", method)
+	return nil
+}
+
+func TestGoType(t *testing.T) {
+	s := schemaOf(t, "Order")
+	typ := goType(s)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		t.Fatalf("expect a struct type, got %v", typ)
+	}
+	if _, ok := typ.FieldByName("Note"); ok {
+		t.Fatal("expect the interface field to be omitted")
+	}
+	f, _ := typ.FieldByName("Counts")
+	if f.Type != reflect.TypeOf(map[string]uint16(nil)) || f.Tag.Get("json") != "counts" {
+		t.Fatalf("unexpected Counts field: %v %q", f.Type, f.Tag)
+	}
+	f, _ = typ.FieldByName("Box")
+	if f.Type.Kind() != reflect.Array || f.Type.Len() != 2 {
+		t.Fatalf("unexpected Box field: %v", f.Type)
+	}
+	for _, kind := range []string{"opaque", "recursive", "interface"} {
+		if typ := goType(&geerpc.TypeSchema{Kind: kind}); typ != nil {
+			t.Fatalf("expect %s to be unsupported, got %v", kind, typ)
+		}
+	}
+	if typ := goType(&geerpc.TypeSchema{Kind: "slice", Elem: &geerpc.TypeSchema{Kind: "interface"}}); typ != nil {
+		t.Fatalf("expect a slice of unsupported elements to be unsupported, got %v", typ)
+	}
+}
+
+func TestNewValue_GobCompatible(t *testing.T) {
+	v, err := newValue(schemaOf(t, "Order"), `{"id": 7, "items": ["a"], "counts": {"a": 2}, "Origin": {"X": 1, "Y": 2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v.Elem().Interface()); err != nil {
+		t.Fatal(err)
+	}
+	var order Order
+	if err := gob.NewDecoder(&buf).Decode(&order); err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 7 || !reflect.DeepEqual(order.Items, []string{"a"}) || order.Counts["a"] != 2 || order.Origin != (Point{1, 2}) {
+		t.Fatalf("unexpected decoded order: %+v", order)
+	}
+
+	if _, err := newValue(schemaOf(t, "Order"), `{"unknown": 1}`); err == nil {
+		t.Fatal("expect unknown fields to be rejected")
+	}
+	if _, err := newValue(&geerpc.TypeSchema{Kind: "interface"}, ""); err == nil {
+		t.Fatal("expect unsupported types to be rejected")
+	}
+	m, err := newValue(schemaOf(t, "Map"), "")
+	if err != nil || m.Elem().IsNil() {
+		t.Fatalf("expect an empty non-nil map, got %v, %v", m, err)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	if name := typeName(schemaOf(t, "Order")); name != "main.Order" {
+		t.Fatalf("expect main.Order, got %s", name)
+	}
+	if name := typeName(schemaOf(t, "Nested")); name != "[]map[string][2]int" {
+		t.Fatalf("unexpected name %s", name)
+	}
+	s := typeString(schemaOf(t, "Point"), "")
+	if s != "main.Point struct {\n\tX int\n\tY int\n}" {
+		t.Fatalf("unexpected type string %q", s)
+	}
+	if s := typeString(&geerpc.TypeSchema{Kind: "recursive", Name: "main.Node"}, ""); !strings.Contains(s, "unsupported") {
+		t.Fatalf("expect recursive types to be marked unsupported, got %q", s)
+	}
+}
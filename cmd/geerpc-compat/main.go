@@ -0,0 +1,99 @@
+// geerpc-compat 保存服务器 API 的快照，并在部署之前与上一个版本的快照比较，发现不兼容的变更。
+//
+// 用法：
+//
+//	geerpc-compat [flags] snapshot <protocol@addr> > api.json
+//	geerpc-compat [flags] diff old.json (new.json | <protocol@addr>)
+//
+// snapshot 通过内置的 Reflection 服务获取服务的结构，服务器需要调用 Server.SetReflection(true)，
+// 也可以在构建时使用 Server.Schema 和 compat.Write 生成快照而无需启动服务器。
+// diff 输出所有差异，存在不兼容的变更时以状态码 1 退出，可以直接用作 CI 的检查步骤。
+// 判断规则参见 compat.Diff
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"geerpc"
+	"geerpc/compat"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("geerpc-compat: ")
+	timeout := flag.Duration("timeout", 10*time.Second, "连接和获取快照的超时时间")
+	token := flag.String("token", "", "握手时发送的凭证（Option.Credentials）")
+	useTLS := flag.Bool("tls", false, "通过 TLS 连接服务器")
+	insecure := flag.Bool("insecure", false, "使用 TLS 时不校验服务器证书")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: geerpc-compat [flags] snapshot <protocol@addr>\n       geerpc-compat [flags] diff old.json (new.json | <protocol@addr>)\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opt := *geerpc.DefaultOption
+	opt.ConnectTimeout = *timeout
+	opt.Credentials = *token
+	if *useTLS || *insecure {
+		opt.TLSConfig = &tls.Config{InsecureSkipVerify: *insecure}
+	}
+	fetch := func(addr string) []geerpc.ServiceSchema {
+		client, err := geerpc.XDial(addr, &opt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() { _ = client.Close() }()
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		services, err := compat.Fetch(ctx, client)
+		if err != nil {
+			log.Fatalf("%v (the server must enable Server.SetReflection)", err)
+		}
+		return services
+	}
+
+	switch {
+	case flag.NArg() == 2 && flag.Arg(0) == "snapshot":
+		if err := compat.Write(os.Stdout, fetch(flag.Arg(1))); err != nil {
+			log.Fatal(err)
+		}
+	case flag.NArg() == 3 && flag.Arg(0) == "diff":
+		old := readSnapshot(flag.Arg(1))
+		var cur []geerpc.ServiceSchema
+		if strings.Contains(flag.Arg(2), "@") {
+			cur = fetch(flag.Arg(2))
+		} else {
+			cur = readSnapshot(flag.Arg(2))
+		}
+		changes := compat.Diff(old, cur)
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if compat.HasBreaking(changes) {
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// readSnapshot 读取 snapshot 命令保存的快照文件
+func readSnapshot(path string) []geerpc.ServiceSchema {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	services, err := compat.Read(f)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return services
+}
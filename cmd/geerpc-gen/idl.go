@@ -0,0 +1,566 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 支持的 IDL 是 proto3 的一个子集：
+//
+//	syntax = "proto3";
+//	package arith;
+//	option go_package = "example.com/arith;arith";
+//
+//	// Args 是 Sum 的参数
+//	message Args {
+//	  int64 num1 = 1;
+//	  repeated string tags = 2;
+//	  map<string, Item> items = 3;
+//	}
+//
+//	enum Op { ADD = 0; SUB = 1; }
+//
+//	service Arith {
+//	  rpc Sum (Args) returns (Reply);
+//	}
+//
+// 不支持嵌套的 message 和 enum、oneof、import 以及流式方法。
+// 紧挨着声明之前的 // 注释会作为生成代码的文档注释
+
+// idlFile 是解析后的 IDL 文件
+type idlFile struct {
+	Package  string
+	Messages []*idlMessage
+	Enums    []*idlEnum
+	Services []*idlService
+}
+
+type idlMessage struct {
+	Name    string
+	Comment []string
+	Fields  []*idlField
+}
+
+type idlField struct {
+	Name     string // IDL 中的字段名，用作 json 标签
+	GoName   string
+	GoType   string
+	Comment  []string
+	Repeated bool
+}
+
+type idlEnum struct {
+	Name    string
+	Comment []string
+	Values  []idlEnumValue
+}
+
+type idlEnumValue struct {
+	Name   string
+	Number int
+}
+
+type idlService struct {
+	Name    string
+	Comment []string
+	Methods []idlMethod
+}
+
+type idlMethod struct {
+	Name    string
+	Comment []string
+	Args    string
+	Reply   string
+}
+
+// idlScalars 是 proto3 标量类型对应的 Go 类型
+var idlScalars = map[string]string{
+	"double": "float64", "float": "float32",
+	"int32": "int32", "sint32": "int32", "sfixed32": "int32",
+	"int64": "int64", "sint64": "int64", "sfixed64": "int64",
+	"uint32": "uint32", "fixed32": "uint32",
+	"uint64": "uint64", "fixed64": "uint64",
+	"bool": "bool", "string": "string", "bytes": "[]byte",
+}
+
+// idlToken 是 IDL 的一个词法单元
+type idlToken struct {
+	text     string
+	line     int
+	comments []string // 紧挨着该单元之前的 // 注释
+}
+
+// idlParser 是 IDL 的递归下降解析器
+type idlParser struct {
+	toks []idlToken
+	pos  int
+}
+
+// parseIDL 解析 IDL 文件的内容
+func parseIDL(src string) (*idlFile, error) {
+	toks, err := lexIDL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &idlParser{toks: toks}
+	f, err := p.file()
+	if err != nil {
+		return nil, err
+	}
+	return f, f.resolve()
+}
+
+// lexIDL 将源码切分为词法单元，字符串保留引号
+func lexIDL(src string) ([]idlToken, error) {
+	var toks []idlToken
+	var comments []string
+	line := 1
+	lastLine := 0 // 上一个注释或单元所在的行，用于判断注释是否紧挨着声明
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+			if line-lastLine > 1 {
+				comments = nil // 空行隔开的注释不属于之后的声明
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			comments = append(comments, strings.TrimSpace(src[i+2:i+end]))
+			lastLine = line
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			lastLine = line
+			i += end + 4
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			toks = append(toks, idlToken{text: src[i : i+end+2], line: line, comments: comments})
+			comments, lastLine = nil, line
+			i += end + 2
+		case strings.IndexByte("{}()<>[];=,", c) >= 0:
+			toks = append(toks, idlToken{text: string(c), line: line, comments: comments})
+			comments, lastLine = nil, line
+			i++
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] == '-' ||
+				unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, idlToken{text: src[i:j], line: line, comments: comments})
+			comments, lastLine = nil, line
+			i = j
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+		}
+	}
+	return toks, nil
+}
+
+func (p *idlParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+func (p *idlParser) next() idlToken {
+	if p.pos >= len(p.toks) {
+		return idlToken{}
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *idlParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.toks) {
+		line = p.toks[p.pos].line
+	} else if len(p.toks) > 0 {
+		line = p.toks[len(p.toks)-1].line
+	}
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// expect 读取下一个单元并检查它是否为 text
+func (p *idlParser) expect(text string) error {
+	if p.peek() != text {
+		return p.errorf("expect %q, but got %q", text, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// ident 读取一个标识符
+func (p *idlParser) ident() (string, error) {
+	t := p.peek()
+	if t == "" || strings.IndexByte("{}()<>[];=,\"'", t[0]) >= 0 {
+		return "", p.errorf("expect identifier, but got %q", t)
+	}
+	p.pos++
+	return t, nil
+}
+
+// skipStatement 跳过直到分号（包括分号）的内容
+func (p *idlParser) skipStatement() error {
+	for p.peek() != ";" {
+		if p.peek() == "" {
+			return p.errorf("expect \";\"")
+		}
+		p.pos++
+	}
+	p.pos++
+	return nil
+}
+
+func (p *idlParser) file() (*idlFile, error) {
+	f := new(idlFile)
+	for p.peek() != "" {
+		tok := p.next()
+		var err error
+		switch tok.text {
+		case "syntax", "option":
+			if tok.text == "option" && p.peek() == "go_package" {
+				p.pos += 2 // go_package =
+				if v, e := strconv.Unquote(p.next().text); e == nil {
+					if semi := strings.LastIndexByte(v, ';'); semi >= 0 {
+						f.Package = v[semi+1:]
+					} else {
+						f.Package = v[strings.LastIndexByte(v, '/')+1:]
+					}
+				}
+			}
+			err = p.skipStatement()
+		case "package":
+			var name string
+			if name, err = p.ident(); err == nil {
+				if f.Package == "" {
+					f.Package = name[strings.LastIndexByte(name, '.')+1:]
+				}
+				err = p.expect(";")
+			}
+		case "message":
+			var m *idlMessage
+			if m, err = p.message(tok.comments); err == nil {
+				f.Messages = append(f.Messages, m)
+			}
+		case "enum":
+			var e *idlEnum
+			if e, err = p.enum(tok.comments); err == nil {
+				f.Enums = append(f.Enums, e)
+			}
+		case "service":
+			var s *idlService
+			if s, err = p.service(tok.comments); err == nil {
+				f.Services = append(f.Services, s)
+			}
+		case ";":
+		default:
+			p.pos--
+			err = p.errorf("unsupported declaration %q", tok.text)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *idlParser) message(comments []string) (*idlMessage, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &idlMessage{Name: name, Comment: comments}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, p.errorf("expect \"}\"")
+		}
+		tok := p.toks[p.pos]
+		switch tok.text {
+		case ";":
+			p.pos++
+			continue
+		case "option", "reserved":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+			continue
+		case "message", "enum", "oneof":
+			return nil, p.errorf("nested %s is not supported", tok.text)
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		field.Comment = tok.comments
+		m.Fields = append(m.Fields, field)
+	}
+	p.pos++
+	return m, nil
+}
+
+// field 解析一个字段，类型暂时保存 IDL 中的写法，由 resolve 转换为 Go 类型
+func (p *idlParser) field() (*idlField, error) {
+	f := new(idlField)
+	if p.peek() == "repeated" {
+		f.Repeated = true
+		p.pos++
+	} else if p.peek() == "optional" {
+		p.pos++
+	}
+	if p.peek() == "map" {
+		p.pos++
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		key, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		value, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		f.GoType = "map<" + key + "," + value + ">"
+	} else {
+		typ, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		f.GoType = typ
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	f.Name = name
+	f.GoName = goName(name)
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	if _, err := strconv.Atoi(p.next().text); err != nil {
+		p.pos--
+		return nil, p.errorf("invalid field number %q", p.peek())
+	}
+	if p.peek() == "[" {
+		for p.peek() != "]" && p.peek() != "" {
+			p.pos++
+		}
+		p.pos++
+	}
+	return f, p.expect(";")
+}
+
+func (p *idlParser) enum(comments []string) (*idlEnum, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &idlEnum{Name: name, Comment: comments}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, p.errorf("expect \"}\"")
+		}
+		if p.peek() == "option" || p.peek() == "reserved" {
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		vname, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(p.next().text)
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("invalid enum value %q", p.peek())
+		}
+		e.Values = append(e.Values, idlEnumValue{Name: vname, Number: n})
+		if err := p.skipStatement(); err != nil {
+			return nil, err
+		}
+	}
+	p.pos++
+	return e, nil
+}
+
+func (p *idlParser) service(comments []string) (*idlService, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	s := &idlService{Name: name, Comment: comments}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for p.peek() != "}" {
+		tok := p.next()
+		switch tok.text {
+		case "":
+			return nil, p.errorf("expect \"}\"")
+		case ";":
+			continue
+		case "option":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+			continue
+		case "rpc":
+		default:
+			p.pos--
+			return nil, p.errorf("expect rpc, but got %q", tok.text)
+		}
+		m := idlMethod{Comment: tok.comments}
+		if m.Name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if m.Args, err = p.rpcType(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("returns"); err != nil {
+			return nil, err
+		}
+		if m.Reply, err = p.rpcType(); err != nil {
+			return nil, err
+		}
+		if p.peek() == "{" {
+			for p.peek() != "}" && p.peek() != "" {
+				p.pos++
+			}
+			p.pos++
+		} else if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		s.Methods = append(s.Methods, m)
+	}
+	p.pos++
+	return s, nil
+}
+
+// rpcType 解析 rpc 声明中带括号的消息类型
+func (p *idlParser) rpcType() (string, error) {
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	if p.peek() == "stream" {
+		return "", p.errorf("streaming methods are not supported")
+	}
+	typ, err := p.ident()
+	if err != nil {
+		return "", err
+	}
+	return typ, p.expect(")")
+}
+
+// resolve 检查引用的类型是否都已定义，并将字段类型转换为 Go 类型
+func (f *idlFile) resolve() error {
+	if f.Package == "" {
+		return errors.New("missing package declaration")
+	}
+	messages := make(map[string]bool)
+	enums := make(map[string]bool)
+	for _, m := range f.Messages {
+		messages[m.Name] = true
+	}
+	for _, e := range f.Enums {
+		enums[e.Name] = true
+	}
+	goType := func(t string) (string, error) {
+		t = t[strings.LastIndexByte(t, '.')+1:] // 忽略包名前缀
+		switch {
+		case idlScalars[t] != "":
+			return idlScalars[t], nil
+		case enums[t]:
+			return t, nil
+		case messages[t]:
+			return "*" + t, nil
+		}
+		return "", errors.New("unknown type " + t)
+	}
+	for _, m := range f.Messages {
+		for _, field := range m.Fields {
+			var err error
+			if strings.HasPrefix(field.GoType, "map<") {
+				kv := strings.Split(strings.TrimSuffix(strings.TrimPrefix(field.GoType, "map<"), ">"), ",")
+				var k, v string
+				if k, err = goType(kv[0]); err == nil {
+					if v, err = goType(kv[1]); err == nil {
+						field.GoType = "map[" + k + "]" + v
+					}
+				}
+			} else if field.GoType, err = goType(field.GoType); err == nil && field.Repeated {
+				field.GoType = "[]" + field.GoType
+			}
+			if err != nil {
+				return fmt.Errorf("message %s field %s: %v", m.Name, field.Name, err)
+			}
+		}
+	}
+	for _, s := range f.Services {
+		if messages[s.Name] || enums[s.Name] {
+			return errors.New("service " + s.Name + " has the same name as a message or enum")
+		}
+		for i, m := range s.Methods {
+			for _, t := range []*string{&s.Methods[i].Args, &s.Methods[i].Reply} {
+				*t = (*t)[strings.LastIndexByte(*t, '.')+1:]
+				if !messages[*t] {
+					return fmt.Errorf("service %s method %s: unknown message %s", s.Name, m.Name, *t)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// goName 将 IDL 中的名字转换为导出的 Go 名字，例如 user_id 转换为 UserId
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
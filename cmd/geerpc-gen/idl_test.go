@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"geerpc"
+	"geerpc/cmd/geerpc-gen/testdata/calcpb"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderIDL(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/calcpb/calc.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseIDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := renderIDL(f, "calc.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/calcpb/calc.geerpc.go", code)
+}
+
+func TestParseIDL_Errors(t *testing.T) {
+	cases := map[string]string{
+		"missing package":   `message A {} service S { rpc M (A) returns (A); }`,
+		"unknown type":      `package p; message A { Missing m = 1; }`,
+		"unknown message":   `package p; message A {} service S { rpc M (A) returns (B); }`,
+		"streaming":         `package p; message A {} service S { rpc M (stream A) returns (A); }`,
+		"bad field number":  `package p; message A { int32 a = x; }`,
+		"unsupported decl":  `package p; import "other.proto";`,
+		"name clash":        `package p; message S {} service S { rpc M (S) returns (S); }`,
+		"unterminated body": `package p; message A { int32 a = 1;`,
+	}
+	for name, src := range cases {
+		if _, err := parseIDL(src); err == nil {
+			t.Fatalf("%s: expect an error for %q", name, src)
+		}
+	}
+}
+
+// calcImpl 实现生成的 calcpb.CalcServer
+type calcImpl struct{}
+
+func (calcImpl) Eval(ctx context.Context, args *calcpb.Request, reply *calcpb.Result) error {
+	reply.Value = args.Operands[0]
+	for _, n := range args.Operands[1:] {
+		if args.Op == calcpb.Op_MUL {
+			reply.Value *= n
+		} else {
+			reply.Value += n
+		}
+	}
+	reply.Echo = args
+	return nil
+}
+
+func (calcImpl) Ping(ctx context.Context, args *calcpb.Request, reply *calcpb.Result) error {
+	reply.Trace = []byte(geerpc.RequestIDFromContext(ctx))
+	return nil
+}
+
+func TestGeneratedIDL(t *testing.T) {
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	if err := calcpb.RegisterCalcServer(server, calcImpl{}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	stub := calcpb.NewCalcClient(client)
+	req := &calcpb.Request{Op: calcpb.Op_MUL, Operands: []int64{2, 3, 4}, Labels: map[string]string{"k": "v"}}
+	res, err := stub.Eval(context.Background(), req)
+	if err != nil || res.Value != 24 {
+		t.Fatalf("expect 24, got %+v, err %v", res, err)
+	}
+	if res.Echo == nil || res.Echo.Labels["k"] != "v" {
+		t.Fatalf("expect nested messages and maps to round-trip, got %+v", res.Echo)
+	}
+	res, err = stub.Ping(context.Background(), &calcpb.Request{}, geerpc.CallRequestID("req-1"))
+	if err != nil || string(res.Trace) != "req-1" {
+		t.Fatalf("expect the request id to reach the implementation, got %q, err %v", res.Trace, err)
+	}
+
+	// 只有 CalcServer 中声明的方法被暴露
+	var reply calcpb.Result
+	err = client.Call(context.Background(), "Calc.Missing", &calcpb.Request{}, &reply)
+	if err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("expect an unknown method error, got %v", err)
+	}
+}
+
+// TestRegisterServer_CompileCheck 确认 RegisterCalcServer 在编译时检查实现：
+// 实现了 CalcServer 全部方法的类型可以注册，缺少方法或签名不符的类型编译失败
+func TestRegisterServer_CompileCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compile check in short mode")
+	}
+	dir, err := ioutil.TempDir("testdata", "compile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const header = `package compile
+
+import (
+	"context"
+	"geerpc"
+	"geerpc/cmd/geerpc-gen/testdata/calcpb"
+)
+
+var _ = context.Background
+
+type impl struct{}
+
+func (impl) Eval(ctx context.Context, args *calcpb.Request, reply *calcpb.Result) error { return nil }
+`
+	cases := []struct {
+		name, src string
+		ok        bool
+	}{
+		{"complete", `func (impl) Ping(ctx context.Context, args *calcpb.Request, reply *calcpb.Result) error { return nil }`, true},
+		{"missing method", ``, false},
+		{"wrong signature", `func (impl) Ping(args *calcpb.Request, reply *calcpb.Result) error { return nil }`, false},
+	}
+	for _, c := range cases {
+		src := header + c.src + "\n\nfunc register(s *geerpc.Server) error { return calcpb.RegisterCalcServer(s, impl{}) }\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "impl.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), "build", "./"+filepath.ToSlash(dir)).CombinedOutput()
+		if (err == nil) != c.ok {
+			t.Fatalf("%s: expect build ok=%v, got %v:\n%s", c.name, c.ok, err, out)
+		}
+		if !c.ok && !strings.Contains(string(out), "Ping") {
+			t.Fatalf("%s: expect the error to name the Ping method, got:\n%s", c.name, out)
+		}
+	}
+}
+
+func TestGeneratedIDL_DeprecatedRegister(t *testing.T) {
+	server := geerpc.NewServer()
+	if err := calcpb.RegisterCalc(server, calcImpl{}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	res, err := calcpb.NewCalcClient(client).Eval(context.Background(), &calcpb.Request{Operands: []int64{1, 2}})
+	if err != nil || res.Value != 3 {
+		t.Fatalf("expect RegisterCalc to behave like RegisterCalcServer, got %+v, err %v", res, err)
+	}
+}
@@ -0,0 +1,106 @@
+// geerpc-gen 为 geerpc 服务生成强类型的客户端存根，调用方不再需要手写 "Service.Method" 字符串和 interface{} 参数。
+//
+// 用法：
+//
+//	geerpc-gen -type Arith [-service Arith] [-output arith_client.go] [dir]
+//
+// -type 是 dir（默认为当前目录）中的一个接口类型，或者是注册到服务器的接收器类型。
+// 与 Server.Register 的规则相同，只有形如 Method([ctx context.Context,] args T, reply *R) error 的导出方法会生成存根。
+// 生成的文件与 -type 位于同一个包中，包含 <Service>Client 类型和构造函数 New<Service>Client，
+// 每个方法形如 Method(ctx context.Context, args T, opts ...geerpc.CallOption) (R, error)，
+// 底层通过 geerpc.Caller（*geerpc.Client 或 *xclient.XClient）发起调用。
+//
+// 在源文件中加入下面的注释后，go generate 会重新生成存根：
+//
+//	//go:generate go run geerpc/cmd/geerpc-gen -type Arith
+//
+// 也可以先在 IDL 文件中定义服务，再生成全部代码，API 的变化可以直接在 IDL 文件上评审：
+//
+//	geerpc-gen -idl arith.proto [-output arith.geerpc.go]
+//
+// IDL 是 proto3 的一个子集（message、enum、service 和 rpc，参见 idl.go），生成的文件包含消息和枚举对应的 Go 类型、
+// 服务端需要实现的 <Service>Server 接口、注册函数 Register<Service>Server(server, impl) 以及与 -type 相同的客户端存根。
+// 注册函数同时为每个方法注册生成的适配器（参见 geerpc.Server.RegisterHandlers），服务端调用方法时不经过反射。
+// impl 的类型在编译时检查，IDL 中新增了方法而实现没有跟上时编译失败，而不是在运行时才返回找不到方法。
+// 生成的文件的包名取自 option go_package 或 package 声明
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("geerpc-gen: ")
+	typeName := flag.String("type", "", "接口或接收器类型的名称")
+	serviceName := flag.String("service", "", "服务名，默认与 -type 相同")
+	idl := flag.String("idl", "", "IDL 文件，与 -type 二选一")
+	output := flag.String("output", "", "输出文件，默认为 <type>_client.go 或 <idl>.geerpc.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: geerpc-gen -type T [-service name] [-output file] [dir]\n")
+		fmt.Fprintf(os.Stderr, "       geerpc-gen -idl file.proto [-output file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if (*typeName == "") == (*idl == "") || flag.NArg() > 1 || (*idl != "" && flag.NArg() > 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *idl != "" {
+		if err := generateIDL(*idl, *output); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_client.go"
+	}
+	if !filepath.IsAbs(*output) {
+		*output = filepath.Join(dir, *output)
+	}
+
+	svc, err := parseService(dir, *typeName, filepath.Base(*output))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *serviceName != "" {
+		svc.Name = *serviceName
+	}
+	src, err := renderClient(svc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generateIDL 根据 IDL 文件生成代码，output 为空时写入 IDL 文件所在目录的 <idl>.geerpc.go
+func generateIDL(path, output string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := parseIDL(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	code, err := renderIDL(f, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = strings.TrimSuffix(path, filepath.Ext(path)) + ".geerpc.go"
+	}
+	return ioutil.WriteFile(output, code, 0644)
+}
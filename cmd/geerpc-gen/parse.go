@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// service 描述要生成代码的服务
+type service struct {
+	Name    string   // 服务名，即 "Service.Method" 中的 Service
+	Package string   // 生成的文件所在的包
+	Imports []string // 参数和返回值类型引用的导入，形如 `name "path"` 或 `"path"`
+	Methods []method
+}
+
+// method 描述一个 RPC 方法
+type method struct {
+	Name  string
+	Args  string // 参数类型的源码
+	Reply string // 返回值指向的类型的源码（去掉了 *）
+}
+
+// parseService 解析 dir 中的 Go 源文件，收集类型 typeName 的 RPC 方法，skip 是需要忽略的文件名（生成的输出文件）
+func parseService(dir, typeName, skip string) (*service, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != skip
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		svc, found, err := parsePackage(fset, pkg, typeName)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return svc, nil
+		}
+	}
+	return nil, errors.New("type " + typeName + " not found in " + dir)
+}
+
+// parsePackage 在包中查找类型 typeName，类型是接口时使用接口的方法，否则使用以它为接收器的方法
+func parsePackage(fset *token.FileSet, pkg *ast.Package, typeName string) (*service, bool, error) {
+	svc := &service{Name: typeName, Package: pkg.Name}
+	imports := make(map[string]string) // 参数和返回值类型引用的包名 -> 导入声明
+	found := false
+	names := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := pkg.Files[name]
+		fileImports := importsOf(file)
+		add := func(m method, exprs ...ast.Expr) {
+			svc.Methods = append(svc.Methods, m)
+			for _, e := range exprs {
+				for _, q := range qualifiers(e) {
+					if spec, ok := fileImports[q]; ok {
+						imports[q] = spec
+					}
+				}
+			}
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok || ts.Name.Name != typeName {
+						continue
+					}
+					found = true
+					iface, ok := ts.Type.(*ast.InterfaceType)
+					if !ok {
+						continue
+					}
+					for _, field := range iface.Methods.List {
+						ft, ok := field.Type.(*ast.FuncType)
+						if !ok || len(field.Names) == 0 {
+							continue // 嵌入的接口
+						}
+						if m, args, reply, ok := rpcMethod(fset, field.Names[0].Name, ft); ok {
+							add(m, args, reply)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil || len(decl.Recv.List) != 1 || receiverName(decl.Recv.List[0].Type) != typeName {
+					continue
+				}
+				if m, args, reply, ok := rpcMethod(fset, decl.Name.Name, decl.Type); ok {
+					add(m, args, reply)
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, false, nil
+	}
+	if len(svc.Methods) == 0 {
+		return nil, false, errors.New("type " + typeName + " has no RPC methods")
+	}
+	sort.Slice(svc.Methods, func(i, j int) bool { return svc.Methods[i].Name < svc.Methods[j].Name })
+	for _, spec := range imports {
+		svc.Imports = append(svc.Imports, spec)
+	}
+	sort.Strings(svc.Imports)
+	return svc, true, nil
+}
+
+// rpcMethod 检查方法是否满足 Server.Register 的规则：
+// 导出的方法，参数为 ([ctx context.Context,] args T, reply *R)，唯一的返回值为 error
+func rpcMethod(fset *token.FileSet, name string, ft *ast.FuncType) (m method, args, reply ast.Expr, ok bool) {
+	if !ast.IsExported(name) {
+		return m, nil, nil, false
+	}
+	var params []ast.Expr
+	for _, field := range ft.Params.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			params = append(params, field.Type)
+		}
+	}
+	if len(params) == 3 && exprString(fset, params[0]) == "context.Context" {
+		params = params[1:]
+	}
+	if len(params) != 2 || ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 ||
+		exprString(fset, ft.Results.List[0].Type) != "error" {
+		return m, nil, nil, false
+	}
+	star, isPtr := params[1].(*ast.StarExpr)
+	if !isPtr {
+		return m, nil, nil, false
+	}
+	return method{Name: name, Args: exprString(fset, params[0]), Reply: exprString(fset, star.X)}, params[0], star.X, true
+}
+
+// receiverName 返回接收器的类型名，忽略指针
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// importsOf 返回文件中的导入，键为引用时使用的包名
+func importsOf(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		decl := spec.Path.Value
+		if spec.Name != nil {
+			name = spec.Name.Name
+			decl = name + " " + decl
+		}
+		imports[name] = decl
+	}
+	return imports
+}
+
+// qualifiers 返回类型表达式中引用的包名，例如 map[string]*pkg.T 返回 pkg
+func qualifiers(expr ast.Expr) []string {
+	var names []string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				names = append(names, ident.Name)
+			}
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// exprString 返回表达式的源码
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
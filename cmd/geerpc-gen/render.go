@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+// templates 包含生成代码使用的模板：client 是客户端存根，clientFile 和 idlFile 是完整的文件
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"comment":  comment,
+	"unexport": func(s string) string { return strings.ToLower(s[:1]) + s[1:] },
+}).Parse(`
+{{- define "client"}}
+// {{.Name}}Client 是 {{.Name}} 服务的客户端存根
+type {{.Name}}Client struct {
+	c geerpc.Caller
+}
+
+// New{{.Name}}Client 创建一个通过 c（*geerpc.Client 或 *xclient.XClient）调用 {{.Name}} 服务的客户端存根
+func New{{.Name}}Client(c geerpc.Caller) *{{.Name}}Client {
+	return &{{.Name}}Client{c: c}
+}
+{{range .Methods}}
+// {{.Name}} 调用 {{$.Name}}.{{.Name}}
+func (c *{{$.Name}}Client) {{.Name}}(ctx context.Context, args {{.Args}}, opts ...geerpc.CallOption) ({{.Reply}}, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply {{.Reply}}
+	err := c.c.Call(ctx, "{{$.Name}}.{{.Name}}", args, &reply)
+	return reply, err
+}
+{{end}}
+{{- end}}
+
+{{- define "clientFile"}}// Code generated by geerpc-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"geerpc"
+{{- range .Imports}}
+{{- if and (ne . "\"context\"") (ne . "\"geerpc\"")}}
+	{{.}}
+{{- end}}
+{{- end}}
+)
+{{template "client" .}}
+{{- end}}
+
+{{- define "idlFile"}}// Code generated by geerpc-gen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"geerpc"
+)
+{{range .Enums}}
+{{comment .Comment .Name}}type {{.Name}} int32
+
+const (
+{{- $enum := .Name}}
+{{- range .Values}}
+	{{$enum}}_{{.Name}} {{$enum}} = {{.Number}}
+{{- end}}
+)
+{{end}}
+{{- range .Messages}}
+{{comment .Comment .Name}}type {{.Name}} struct {
+{{- range .Fields}}
+	{{comment .Comment ""}}{{.GoName}} {{.GoType}} ` + "`json:\"{{.Name}},omitempty\"`" + `
+{{- end}}
+}
+{{end}}
+{{- range .Services}}
+{{comment .Comment (printf "%sServer" .Name)}}type {{.Name}}Server interface {
+{{- range .Methods}}
+	{{comment .Comment ""}}{{.Name}}(ctx context.Context, args *{{.Args}}, reply *{{.Reply}}) error
+{{- end}}
+}
+
+// Register{{.Name}}Server 将 impl 注册为 server 上的 {{.Name}} 服务，只有 {{.Name}}Server 中声明的方法会被暴露。
+// impl 必须实现 {{.Name}}Server 的全部方法，IDL 中新增的方法没有实现时编译失败，而不是在调用时才找不到方法。
+// 每个方法都注册了生成的适配器，调用时不经过反射
+func Register{{.Name}}Server(server *geerpc.Server, impl {{.Name}}Server) error {
+	return server.RegisterHandlers("{{.Name}}", &{{unexport .Name}}Service{impl}, map[string]geerpc.MethodHandler{
+{{- range .Methods}}
+		"{{.Name}}": func(ctx context.Context, args, reply interface{}) error {
+			return impl.{{.Name}}(ctx, args.(*{{.Args}}), reply.(*{{.Reply}}))
+		},
+{{- end}}
+	})
+}
+
+// Register{{.Name}} 与 Register{{.Name}}Server 相同
+//
+// Deprecated: 使用 Register{{.Name}}Server
+func Register{{.Name}}(server *geerpc.Server, impl {{.Name}}Server) error {
+	return Register{{.Name}}Server(server, impl)
+}
+
+// {{unexport .Name}}Service 将 {{.Name}}Server 的方法转发给 impl，每个方法的签名都在编译时检查
+type {{unexport .Name}}Service struct {
+	impl {{.Name}}Server
+}
+
+var _ {{.Name}}Server = (*{{unexport .Name}}Service)(nil)
+{{$svc := .Name}}
+{{- range .Methods}}
+func (s *{{unexport $svc}}Service) {{.Name}}(ctx context.Context, args *{{.Args}}, reply *{{.Reply}}) error {
+	return s.impl.{{.Name}}(ctx, args, reply)
+}
+{{end}}
+{{- template "client" .Client}}
+{{- end}}
+{{- end}}
+`))
+
+// renderClient 生成客户端存根的源码
+func renderClient(svc *service) ([]byte, error) {
+	return render("clientFile", svc)
+}
+
+// idlView 是生成 IDL 对应代码时传给模板的数据
+type idlView struct {
+	*idlFile
+	Source   string
+	Services []idlServiceView
+}
+
+type idlServiceView struct {
+	*idlService
+	Client *service
+}
+
+// renderIDL 生成 IDL 中定义的类型、服务端注册代码和客户端存根的源码
+func renderIDL(f *idlFile, source string) ([]byte, error) {
+	view := idlView{idlFile: f, Source: source}
+	for _, s := range f.Services {
+		client := &service{Name: s.Name, Package: f.Package}
+		for _, m := range s.Methods {
+			client.Methods = append(client.Methods, method{Name: m.Name, Args: "*" + m.Args, Reply: m.Reply})
+		}
+		view.Services = append(view.Services, idlServiceView{idlService: s, Client: client})
+	}
+	return render("idlFile", view)
+}
+
+func render(name string, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// comment 将 IDL 中的注释转换为 Go 注释，没有注释时为 name（不为空）生成一行默认注释
+func comment(lines []string, name string) string {
+	if len(lines) == 0 {
+		if name == "" {
+			return ""
+		}
+		return "// " + name + " 由 IDL 生成\n"
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString("// " + line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"geerpc"
+	"geerpc/cmd/geerpc-gen/testdata/arith"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "用生成的代码更新 testdata 中的期望输出")
+
+// checkGolden 比较生成的代码与 testdata 中的期望输出，-update 时改为写入期望输出。
+// 期望输出本身是可以编译的 Go 文件，测试通过导入它们确认生成的代码可以使用
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("generated code differs from %s, run go test -update to accept the change:\n%s", path, got)
+	}
+}
+
+func TestRenderClient(t *testing.T) {
+	cases := []struct {
+		dir, typeName, service, output string
+	}{
+		{"testdata/arith", "Arith", "", "arith_client.go"},            // 接收器类型，参数类型来自其他包
+		{"testdata/shapes", "Shapes", "Geometry", "shapes_client.go"}, // 接口，服务名与类型名不同
+	}
+	for _, c := range cases {
+		t.Run(c.typeName, func(t *testing.T) {
+			svc, err := parseService(c.dir, c.typeName, c.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.service != "" {
+				svc.Name = c.service
+			}
+			src, err := renderClient(svc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join(c.dir, c.output), src)
+		})
+	}
+}
+
+func TestParseService_Errors(t *testing.T) {
+	if _, err := parseService("testdata/arith", "Missing", ""); err == nil {
+		t.Fatal("expect an error for an unknown type")
+	}
+	if _, err := parseService("testdata/arith", "Args", "arith_client.go"); err == nil {
+		t.Fatal("expect an error for a type without RPC methods")
+	}
+}
+
+func TestGeneratedClient(t *testing.T) {
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	_ = server.Register(new(arith.Arith))
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	stub := arith.NewArithClient(client)
+	if sum, err := stub.Sum(context.Background(), arith.Args{Num1: 1, Num2: 2}); err != nil || sum != 3 {
+		t.Fatalf("expect 3, got %d, err %v", sum, err)
+	}
+	if _, err := stub.Div(context.Background(), arith.Args{Num1: 1}); err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("expect the method error, got %v", err)
+	}
+	// CallOption 作用于单次调用
+	_, err = stub.Sleep(context.Background(), time.Second, geerpc.CallTimeout(50*time.Millisecond))
+	if err == nil {
+		t.Fatal("expect the per-call timeout to end the call")
+	}
+}
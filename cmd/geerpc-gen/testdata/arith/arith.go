@@ -0,0 +1,44 @@
+// Package arith 是 geerpc-gen 根据接收器类型生成存根的测试输入
+package arith
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type Args struct{ Num1, Num2 int }
+
+type Arith int
+
+func (Arith) Sum(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+// Sleep 声明了 context.Context 参数，参数类型来自其他包
+func (Arith) Sleep(ctx context.Context, d time.Duration, reply *string) error {
+	select {
+	case <-time.After(d):
+		*reply = "awake"
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (Arith) Div(args Args, reply *float64) error {
+	if args.Num2 == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = float64(args.Num1) / float64(args.Num2)
+	return nil
+}
+
+// 以下方法不满足 RPC 方法的要求，不生成存根
+
+func (Arith) sum(args Args, reply *int) error { return nil }
+
+func (Arith) Reset() {}
+
+func (Arith) Value(args Args, reply int) error { return nil }
@@ -0,0 +1,46 @@
+// Code generated by geerpc-gen. DO NOT EDIT.
+
+package arith
+
+import (
+	"context"
+	"geerpc"
+	"time"
+)
+
+// ArithClient 是 Arith 服务的客户端存根
+type ArithClient struct {
+	c geerpc.Caller
+}
+
+// NewArithClient 创建一个通过 c（*geerpc.Client 或 *xclient.XClient）调用 Arith 服务的客户端存根
+func NewArithClient(c geerpc.Caller) *ArithClient {
+	return &ArithClient{c: c}
+}
+
+// Div 调用 Arith.Div
+func (c *ArithClient) Div(ctx context.Context, args Args, opts ...geerpc.CallOption) (float64, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply float64
+	err := c.c.Call(ctx, "Arith.Div", args, &reply)
+	return reply, err
+}
+
+// Sleep 调用 Arith.Sleep
+func (c *ArithClient) Sleep(ctx context.Context, args time.Duration, opts ...geerpc.CallOption) (string, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply string
+	err := c.c.Call(ctx, "Arith.Sleep", args, &reply)
+	return reply, err
+}
+
+// Sum 调用 Arith.Sum
+func (c *ArithClient) Sum(ctx context.Context, args Args, opts ...geerpc.CallOption) (int, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply int
+	err := c.c.Call(ctx, "Arith.Sum", args, &reply)
+	return reply, err
+}
@@ -0,0 +1,103 @@
+// Code generated by geerpc-gen from calc.proto. DO NOT EDIT.
+
+package calcpb
+
+import (
+	"context"
+	"geerpc"
+)
+
+// Op 是运算的种类
+type Op int32
+
+const (
+	Op_ADD Op = 0
+	Op_MUL Op = 1
+)
+
+// Request 是 Eval 的参数
+type Request struct {
+	Op Op `json:"op,omitempty"`
+	// 参与运算的数
+	Operands  []int64           `json:"operands,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	RequestId string            `json:"request_id,omitempty"`
+}
+
+// Result 由 IDL 生成
+type Result struct {
+	Value int64    `json:"value,omitempty"`
+	Trace []byte   `json:"trace,omitempty"`
+	Echo  *Request `json:"echo,omitempty"`
+}
+
+// Calc 是一个计算服务
+type CalcServer interface {
+	// Eval 按 op 对所有操作数求值
+	Eval(ctx context.Context, args *Request, reply *Result) error
+	Ping(ctx context.Context, args *Request, reply *Result) error
+}
+
+// RegisterCalcServer 将 impl 注册为 server 上的 Calc 服务，只有 CalcServer 中声明的方法会被暴露。
+// impl 必须实现 CalcServer 的全部方法，IDL 中新增的方法没有实现时编译失败，而不是在调用时才找不到方法。
+// 每个方法都注册了生成的适配器，调用时不经过反射
+func RegisterCalcServer(server *geerpc.Server, impl CalcServer) error {
+	return server.RegisterHandlers("Calc", &calcService{impl}, map[string]geerpc.MethodHandler{
+		"Eval": func(ctx context.Context, args, reply interface{}) error {
+			return impl.Eval(ctx, args.(*Request), reply.(*Result))
+		},
+		"Ping": func(ctx context.Context, args, reply interface{}) error {
+			return impl.Ping(ctx, args.(*Request), reply.(*Result))
+		},
+	})
+}
+
+// RegisterCalc 与 RegisterCalcServer 相同
+//
+// Deprecated: 使用 RegisterCalcServer
+func RegisterCalc(server *geerpc.Server, impl CalcServer) error {
+	return RegisterCalcServer(server, impl)
+}
+
+// calcService 将 CalcServer 的方法转发给 impl，每个方法的签名都在编译时检查
+type calcService struct {
+	impl CalcServer
+}
+
+var _ CalcServer = (*calcService)(nil)
+
+func (s *calcService) Eval(ctx context.Context, args *Request, reply *Result) error {
+	return s.impl.Eval(ctx, args, reply)
+}
+
+func (s *calcService) Ping(ctx context.Context, args *Request, reply *Result) error {
+	return s.impl.Ping(ctx, args, reply)
+}
+
+// CalcClient 是 Calc 服务的客户端存根
+type CalcClient struct {
+	c geerpc.Caller
+}
+
+// NewCalcClient 创建一个通过 c（*geerpc.Client 或 *xclient.XClient）调用 Calc 服务的客户端存根
+func NewCalcClient(c geerpc.Caller) *CalcClient {
+	return &CalcClient{c: c}
+}
+
+// Eval 调用 Calc.Eval
+func (c *CalcClient) Eval(ctx context.Context, args *Request, opts ...geerpc.CallOption) (Result, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply Result
+	err := c.c.Call(ctx, "Calc.Eval", args, &reply)
+	return reply, err
+}
+
+// Ping 调用 Calc.Ping
+func (c *CalcClient) Ping(ctx context.Context, args *Request, opts ...geerpc.CallOption) (Result, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply Result
+	err := c.c.Call(ctx, "Calc.Ping", args, &reply)
+	return reply, err
+}
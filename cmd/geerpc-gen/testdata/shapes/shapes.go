@@ -0,0 +1,12 @@
+// Package shapes 是 geerpc-gen 根据接口生成存根的测试输入
+package shapes
+
+import "context"
+
+type Rect struct{ W, H float64 }
+
+type Shapes interface {
+	Area(ctx context.Context, r Rect, reply *float64) error
+	Scale(r Rect, reply *Rect) error
+	Name() string
+}
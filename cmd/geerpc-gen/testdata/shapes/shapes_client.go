@@ -0,0 +1,36 @@
+// Code generated by geerpc-gen. DO NOT EDIT.
+
+package shapes
+
+import (
+	"context"
+	"geerpc"
+)
+
+// GeometryClient 是 Geometry 服务的客户端存根
+type GeometryClient struct {
+	c geerpc.Caller
+}
+
+// NewGeometryClient 创建一个通过 c（*geerpc.Client 或 *xclient.XClient）调用 Geometry 服务的客户端存根
+func NewGeometryClient(c geerpc.Caller) *GeometryClient {
+	return &GeometryClient{c: c}
+}
+
+// Area 调用 Geometry.Area
+func (c *GeometryClient) Area(ctx context.Context, args Rect, opts ...geerpc.CallOption) (float64, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply float64
+	err := c.c.Call(ctx, "Geometry.Area", args, &reply)
+	return reply, err
+}
+
+// Scale 调用 Geometry.Scale
+func (c *GeometryClient) Scale(ctx context.Context, args Rect, opts ...geerpc.CallOption) (Rect, error) {
+	ctx, cancel := geerpc.ApplyCallOptions(ctx, opts...)
+	defer cancel()
+	var reply Rect
+	err := c.c.Call(ctx, "Geometry.Scale", args, &reply)
+	return reply, err
+}
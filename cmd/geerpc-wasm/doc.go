@@ -0,0 +1,18 @@
+// geerpc-wasm 编译为 WebAssembly 后在浏览器中运行，通过 WebSocket 和 JSON 编解码器调用 geerpc 服务，
+// 供内部工具的前端在开发时直接调用服务，无需额外的网关。
+//
+// 构建：
+//
+//	GOOS=js GOARCH=wasm go build -o geerpc.wasm ./cmd/geerpc-wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .  // Go 1.24 之前位于 misc/wasm
+//
+// 页面加载 wasm_exec.js 并运行 geerpc.wasm 后，全局对象 geerpc 提供以下接口，所有调用都返回 Promise：
+//
+//	const client = await geerpc.dial("ws://localhost:7001/_geeprc_", {token: "...", timeout: 5000});
+//	const reply = await client.call("Arith.Add", {Num1: 1, Num2: 2}, {timeout: 1000});
+//	client.close();
+//
+// 参数和返回值是普通的 JavaScript 值，按 JSON 转换为服务方法的参数类型；服务方法返回的错误以 Error 的形式拒绝 Promise。
+// 服务端需要通过 HandleHTTP（或以 Server 作为 http.Handler）提供 HTTP RPC 服务，
+// 页面与服务不同源时需要调用 Server.SetWebSocketOrigins 允许页面的来源
+package main
@@ -0,0 +1,112 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"geerpc"
+	"geerpc/codec"
+	"syscall/js"
+	"time"
+)
+
+func main() {
+	js.Global().Set("geerpc", js.ValueOf(map[string]interface{}{
+		"dial": js.FuncOf(dial),
+	}))
+	select {}
+}
+
+// dial 实现 geerpc.dial(url, {token, timeout})，返回的 Promise 解析为客户端对象
+func dial(this js.Value, args []js.Value) interface{} {
+	url := arg(args, 0).String()
+	opts := arg(args, 1)
+	return promise(func() (interface{}, error) {
+		opt := &geerpc.Option{CodecType: codec.JsonType}
+		if opts.Truthy() {
+			if token := opts.Get("token"); token.Truthy() {
+				opt.Credentials = token.String()
+			}
+			opt.ConnectTimeout = millis(opts.Get("timeout"))
+		}
+		client, err := geerpc.DialWebSocket(url, opt)
+		if err != nil {
+			return nil, err
+		}
+		return newClientObject(client), nil
+	})
+}
+
+// newClientObject 返回包装 client 的 JavaScript 对象，提供 call 和 close 方法
+func newClientObject(client *geerpc.Client) js.Value {
+	var call, closeFn js.Func
+	call = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		serviceMethod := arg(args, 0).String()
+		params := "null"
+		if v := arg(args, 1); !v.IsUndefined() {
+			params = js.Global().Get("JSON").Call("stringify", v).String()
+		}
+		timeout := time.Duration(0)
+		if opts := arg(args, 2); opts.Truthy() {
+			timeout = millis(opts.Get("timeout"))
+		}
+		return promise(func() (interface{}, error) {
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			var reply json.RawMessage
+			if err := client.Call(ctx, serviceMethod, json.RawMessage(params), &reply); err != nil {
+				return nil, err
+			}
+			return js.Global().Get("JSON").Call("parse", string(reply)), nil
+		})
+	})
+	closeFn = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		_ = client.Close()
+		call.Release()
+		closeFn.Release()
+		return nil
+	})
+	return js.ValueOf(map[string]interface{}{"call": call, "close": closeFn})
+}
+
+// promise 在新的协程中执行 f 并返回对应的 Promise，f 返回错误时以 Error 拒绝。
+// 事件处理函数不能阻塞，网络操作必须在协程中进行
+func promise(f func() (interface{}, error)) js.Value {
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			defer executor.Release()
+			v, err := f()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(executor)
+}
+
+// arg 返回第 i 个参数，不存在时返回 undefined
+func arg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// millis 将以毫秒为单位的数字转换为 time.Duration，不是数字时返回 0
+func millis(v js.Value) time.Duration {
+	if v.Type() != js.TypeNumber {
+		return 0
+	}
+	return time.Duration(v.Float() * float64(time.Millisecond))
+}
@@ -0,0 +1,10 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+import "log"
+
+func main() {
+	log.Fatal("geerpc-wasm: build with GOOS=js GOARCH=wasm and run in a browser")
+}
@@ -9,6 +9,13 @@ type Header struct {
 	ServiceMethod string // 格式为 "Service.Method"
 	Seq           uint64 // 客户端选择的序列号
 	Error         string
+	RequestID     string // 请求 ID，用于在多个服务之间关联日志
+	Token         string // 单次调用的凭证，为空时使用连接握手时的凭证
+
+	// Chunked 表示消息体是分块发送的返回值中的一块（[]byte），More 表示之后还有同一个调用的分块。
+	// 所有分块拼接起来是返回值单独编码的结果，参见 geerpc.Server.SetChunkSize
+	Chunked bool `json:",omitempty"`
+	More    bool `json:",omitempty"`
 }
 
 // Codec 定义了编解码器的接口
@@ -19,6 +26,19 @@ type Codec interface {
 	Write(*Header, interface{}) error
 }
 
+// Sizer 由能够报告消息体编码大小的编解码器实现，用于统计每个方法的消息大小。
+// ReadBodySize 只在读取消息的协程中调用，WrittenBodySize 只在持有写锁时调用
+type Sizer interface {
+	ReadBodySize() int    // 最近一次 ReadBody 读取的字节数
+	WrittenBodySize() int // 最近一次 Write 写入的消息体字节数
+}
+
+// Buffered 由在内部缓冲读取数据的编解码器实现，Buffered 返回已经从连接读取但尚未解码的字节数，
+// 只在读取消息的协程中调用。事件驱动的服务器据此判断连接上是否还有未处理的请求
+type Buffered interface {
+	Buffered() int
+}
+
 // NewCodecFunc 是用于创建 Codec 实例的函数类型
 type NewCodecFunc func(io.ReadWriteCloser) Codec
 
@@ -27,7 +47,7 @@ type Type string
 
 const (
 	GobType  Type = "application/gob"
-	JsonType Type = "application/json" // 未实现
+	JsonType Type = "application/json"
 )
 
 // NewCodecFuncMap 存储不同类型的编解码器创建函数
@@ -36,4 +56,5 @@ var NewCodecFuncMap map[Type]NewCodecFunc
 func init() {
 	NewCodecFuncMap = make(map[Type]NewCodecFunc)
 	NewCodecFuncMap[GobType] = NewGobCodec
+	NewCodecFuncMap[JsonType] = NewJsonCodec
 }
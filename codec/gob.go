@@ -13,18 +13,32 @@ type GobCodec struct {
 	buf  *bufio.Writer
 	dec  *gob.Decoder
 	enc  *gob.Encoder
+	r    *countingReader // 统计读取的字节数
+	w    *countingWriter // 统计写入的字节数
+
+	readBody    int // 最近一次 ReadBody 读取的字节数
+	writtenBody int // 最近一次 Write 写入的消息体字节数
+	limits      Limits
 }
 
 var _ Codec = (*GobCodec)(nil)
+var _ Sizer = (*GobCodec)(nil)
+var _ Limiter = (*GobCodec)(nil)
+var _ Buffered = (*GobCodec)(nil)
 
 // NewGobCodec 创建一个 GobCodec 实例
 func NewGobCodec(conn io.ReadWriteCloser) Codec {
 	buf := bufio.NewWriter(conn)
+	// countingReader 实现了 io.ByteReader，gob 不会再额外缓冲，因此统计的字节数是准确的
+	r := &countingReader{r: bufio.NewReader(conn), limit: DefaultMaxMessageSize}
+	w := &countingWriter{w: buf}
 	return &GobCodec{
 		conn: conn,
 		buf:  buf,
-		dec:  gob.NewDecoder(conn),
-		enc:  gob.NewEncoder(buf),
+		dec:  gob.NewDecoder(r),
+		enc:  gob.NewEncoder(w),
+		r:    r,
+		w:    w,
 	}
 }
 
@@ -33,9 +47,21 @@ func (c *GobCodec) ReadHeader(h *Header) error {
 	return c.dec.Decode(h)
 }
 
+// SetLimits 设置解码的资源限制，默认只限制单个消息不超过 DefaultMaxMessageSize。应在读取第一个消息之前调用
+func (c *GobCodec) SetLimits(l Limits) {
+	c.limits = l
+	c.r.limit = l.messageSize()
+}
+
 // ReadBody 从连接中读取消息体
 func (c *GobCodec) ReadBody(body interface{}) error {
-	return c.dec.Decode(body)
+	start := c.r.n
+	err := c.dec.Decode(body)
+	c.readBody = int(c.r.n - start)
+	if err == nil && body != nil {
+		err = c.limits.Check(body)
+	}
+	return err
 }
 
 // Write 将消息头和消息体编码并写入连接
@@ -50,14 +76,114 @@ func (c *GobCodec) Write(h *Header, body interface{}) (err error) {
 		log.Println("rpc: gob error encoding header:", err)
 		return
 	}
+	start := c.w.n
 	if err = c.enc.Encode(body); err != nil {
 		log.Println("rpc: gob error encoding body:", err)
 		return
 	}
+	c.writtenBody = int(c.w.n - start)
 	return
 }
 
+// ReadBodySize 返回最近一次 ReadBody 读取的字节数
+func (c *GobCodec) ReadBodySize() int {
+	return c.readBody
+}
+
+// WrittenBodySize 返回最近一次 Write 写入的消息体字节数
+func (c *GobCodec) WrittenBodySize() int {
+	return c.writtenBody
+}
+
+// Buffered 返回已经读取但尚未解码的字节数
+func (c *GobCodec) Buffered() int {
+	return c.r.r.Buffered()
+}
+
 // Close 关闭连接
 func (c *GobCodec) Close() error {
 	return c.conn.Close()
 }
+
+// countingReader 统计从 bufio.Reader 读取的字节数，并在 gob 读取每个消息之前检查消息的长度。
+// gob 的数据流由若干个消息组成，每个消息以编码为 gob 无符号整数的长度开头，
+// countingReader 在交出长度前缀之前先窥视完整的长度，超过 limit 时返回 ErrMessageTooLarge，
+// 使 gob 不会为声称很大的消息分配内存
+type countingReader struct {
+	r         *bufio.Reader
+	n         int64
+	limit     int64 // 单个消息的最大字节数，0 表示不限制
+	remaining int64 // 当前消息（包括长度前缀）尚未读取的字节数
+}
+
+// next 在消息边界上窥视下一个消息的长度
+func (r *countingReader) next() error {
+	b, err := r.r.Peek(1)
+	if err != nil {
+		return err
+	}
+	prefix, size := int64(1), uint64(b[0])
+	if b[0] >= 0x80 {
+		// 长度大于 127 时，第一个字节是后续字节数的相反数，后续字节是大端序的长度
+		k := int(-int8(b[0]))
+		if k < 1 || k > 8 {
+			return ErrMessageTooLarge
+		}
+		if b, err = r.r.Peek(1 + k); err != nil {
+			return err
+		}
+		size = 0
+		for _, c := range b[1:] {
+			size = size<<8 | uint64(c)
+		}
+		prefix += int64(k)
+	}
+	if size > uint64(r.limit) {
+		return ErrMessageTooLarge
+	}
+	r.remaining = prefix + int64(size)
+	return nil
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.limit > 0 {
+		if r.remaining == 0 {
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+		}
+		if int64(len(p)) > r.remaining {
+			p = p[:r.remaining]
+		}
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	if r.limit > 0 && r.remaining == 0 {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+		r.remaining--
+	}
+	return b, err
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// bufferConn 是写入的数据可以再被读出的内存连接
+type bufferConn struct {
+	bytes.Buffer
+}
+
+func (*bufferConn) Close() error { return nil }
+
+func TestGobCodec_Header(t *testing.T) {
+	c := NewGobCodec(new(bufferConn))
+	for _, want := range []Header{
+		{ServiceMethod: "Foo.Sum", Seq: 1, RequestID: "req-1", Token: "t"},
+		{ServiceMethod: "Foo.Sum", Seq: 2},
+		{ServiceMethod: "Bar.Echo", Seq: 3, Error: "failed"},
+	} {
+		h := want
+		if err := c.Write(&h, want.Seq); err != nil {
+			t.Fatal(err)
+		}
+		var got Header
+		var body uint64
+		if err := c.ReadHeader(&got); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ReadBody(&body); err != nil {
+			t.Fatal(err)
+		}
+		if got != want || body != want.Seq {
+			t.Fatalf("expect %+v (%d), got %+v (%d)", want, want.Seq, got, body)
+		}
+	}
+}
+
+// BenchmarkGobCodec_Header 比较请求头和服务端回复的响应头的开销：
+// 响应头不回显凭证，客户端每次读取少解码一个字符串
+func BenchmarkGobCodec_Header(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		h    Header
+	}{
+		{"request", Header{ServiceMethod: "Foo.Sum", RequestID: "0123456789abcdef", Token: "secret-token-0123"}},
+		{"response", Header{ServiceMethod: "Foo.Sum", RequestID: "0123456789abcdef"}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := NewGobCodec(new(bufferConn))
+			h := bc.h
+			var got Header
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.Seq = uint64(i)
+				if err := c.Write(&h, 1); err != nil {
+					b.Fatal(err)
+				}
+				got = Header{}
+				if err := c.ReadHeader(&got); err != nil {
+					b.Fatal(err)
+				}
+				if err := c.ReadBody(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGobCodec_BodySize(t *testing.T) {
+	conn := new(bufferConn)
+	c := NewGobCodec(conn).(*GobCodec)
+	for _, body := range [][]byte{make([]byte, 10), make([]byte, 1000)} {
+		if err := c.Write(&Header{ServiceMethod: "Foo.Sum", Seq: 1}, body); err != nil {
+			t.Fatal(err)
+		}
+		// 消息体的大小不包括消息头
+		written := c.WrittenBodySize()
+		if written <= len(body) || written > len(body)+16 || written >= conn.Len() {
+			t.Fatalf("expect the body size to be about %d bytes, got %d of %d", len(body), written, conn.Len())
+		}
+		var h Header
+		var got []byte
+		if err := c.ReadHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ReadBody(&got); err != nil {
+			t.Fatal(err)
+		}
+		if read := c.ReadBodySize(); read != written {
+			t.Fatalf("expect to read the %d bytes written, got %d", written, read)
+		}
+	}
+}
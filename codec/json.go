@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+)
+
+// JsonCodec 实现了 Codec 接口，使用 JSON 进行编解码。消息头和消息体依次作为独立的 JSON 值写入连接，
+// 便于浏览器等没有 gob 实现的客户端使用。
+//
+// 消息体为 json.RawMessage（写入）或 *json.RawMessage（读取）时原样传递编码后的字节，
+// 网关等只转发消息体的调用方因此不需要解码再重新编码，参见 xclient.Gateway.SetRawForwarding。
+// 这样读取的消息体不受 Limits 中长度和深度的限制，由最终解码它的一方检查
+type JsonCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	r    *jsonLimitReader
+	dec  *json.Decoder
+
+	readBody    int // 最近一次 ReadBody 读取的字节数
+	writtenBody int // 最近一次 Write 写入的消息体字节数
+	limits      Limits
+}
+
+var _ Codec = (*JsonCodec)(nil)
+var _ Sizer = (*JsonCodec)(nil)
+var _ Limiter = (*JsonCodec)(nil)
+var _ Buffered = (*JsonCodec)(nil)
+
+// NewJsonCodec 创建一个 JsonCodec 实例
+func NewJsonCodec(conn io.ReadWriteCloser) Codec {
+	r := &jsonLimitReader{r: conn, limit: DefaultMaxMessageSize}
+	return &JsonCodec{
+		conn: conn,
+		buf:  bufio.NewWriter(conn),
+		r:    r,
+		dec:  json.NewDecoder(r),
+	}
+}
+
+// SetLimits 设置解码的资源限制，默认只限制单个消息不超过 DefaultMaxMessageSize。应在读取第一个消息之前调用
+func (c *JsonCodec) SetLimits(l Limits) {
+	c.limits = l
+	c.r.limit = l.messageSize()
+}
+
+// ReadHeader 从连接中读取消息头
+func (c *JsonCodec) ReadHeader(h *Header) error {
+	c.r.reset()
+	return c.dec.Decode(h)
+}
+
+// ReadBody 从连接中读取消息体，body 为 nil 时丢弃消息体
+func (c *JsonCodec) ReadBody(body interface{}) error {
+	c.r.reset()
+	var raw json.RawMessage
+	if err := c.dec.Decode(&raw); err != nil {
+		return err
+	}
+	c.readBody = len(raw)
+	if body == nil {
+		return nil
+	}
+	if p, ok := body.(*json.RawMessage); ok {
+		// 调用方只转发消息体，不需要解码。raw 是解码器缓冲区的副本，可以直接交出
+		*p = raw
+		return nil
+	}
+	if err := c.limits.checkJSON(raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, body); err != nil {
+		return err
+	}
+	return c.limits.Check(body)
+}
+
+// Write 将消息头和消息体编码并写入连接
+func (c *JsonCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	header, err := json.Marshal(h)
+	if err != nil {
+		log.Println("rpc: json error encoding header:", err)
+		return
+	}
+	var data []byte
+	if raw, ok := body.(json.RawMessage); ok && json.Valid(raw) {
+		data = raw // 已编码的消息体原样写入，不再解码和重新编码
+	} else if data, err = json.Marshal(body); err != nil {
+		log.Println("rpc: json error encoding body:", err)
+		return
+	}
+	_, _ = c.buf.Write(header)
+	_ = c.buf.WriteByte('\n')
+	_, _ = c.buf.Write(data)
+	err = c.buf.WriteByte('\n')
+	c.writtenBody = len(data)
+	return
+}
+
+// ReadBodySize 返回最近一次 ReadBody 读取的字节数
+func (c *JsonCodec) ReadBodySize() int {
+	return c.readBody
+}
+
+// WrittenBodySize 返回最近一次 Write 写入的消息体字节数
+func (c *JsonCodec) WrittenBodySize() int {
+	return c.writtenBody
+}
+
+// Buffered 返回解码器中已经读取但尚未解码的字节数，不计算消息之间的空白（例如每个消息末尾的换行符）
+func (c *JsonCodec) Buffered() int {
+	b, _ := ioutil.ReadAll(c.dec.Buffered())
+	return len(bytes.TrimSpace(b))
+}
+
+// Close 关闭连接
+func (c *JsonCodec) Close() error {
+	return c.conn.Close()
+}
+
+// jsonLimitReader 限制解码单个 JSON 值时从连接读取的字节数，每次解码之前调用 reset。
+// json.Decoder 会预读，因此限制是近似的，但足以阻止声称很大的消息无限制地占用内存
+type jsonLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64 // 0 表示不限制
+}
+
+func (l *jsonLimitReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		if l.n >= l.limit {
+			return 0, ErrMessageTooLarge
+		}
+		if int64(len(p)) > l.limit-l.n {
+			p = p[:l.limit-l.n]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+func (l *jsonLimitReader) reset() { l.n = 0 }
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// ErrMessageTooLarge 表示收到的消息超过了 Limits.MaxMessageSize，连接无法继续使用
+var ErrMessageTooLarge = errors.New("rpc codec: message too large")
+
+// DefaultMaxMessageSize 是未设置 Limits 时单个消息的最大字节数。
+// gob 解码时的内存分配只受这一限制约束，因此默认值较小，返回值更大的服务应开启分块发送（Server.SetChunkSize）
+const DefaultMaxMessageSize = 16 << 20
+
+// Limits 限制解码单个消息时使用的资源，防止很小的恶意消息在解码时展开为大量的内存分配。
+// MaxMessageSize 在读取消息内容之前检查，是所有编解码器都能在解码期间生效的限制。
+// MaxLength 和 MaxDepth 的生效时机取决于编解码器：
+//   - JSON 在解码之前扫描消息体，嵌套深度或数组、对象的元素个数超限时不进行解码；
+//   - msgpack 在解码时检查嵌套深度；
+//   - gob 的解码过程无法中途干预，只在消息体解码完成后、交给业务代码之前检查，
+//     解码期间的内存分配只受 MaxMessageSize 约束，需要防御放大攻击时应同时调小 MaxMessageSize
+type Limits struct {
+	MaxMessageSize int // 单个消息（消息头、消息体或类型定义）的最大字节数，0 表示 DefaultMaxMessageSize，负数表示不限制
+	MaxLength      int // 消息体中字符串、切片、数组和映射的最大长度，0 表示不限制
+	MaxDepth       int // 消息体的最大嵌套深度（结构体、指针、切片和映射各算一层），0 表示不限制
+}
+
+// Limiter 由支持解码限制的编解码器实现
+type Limiter interface {
+	SetLimits(Limits)
+}
+
+// messageSize 返回单个消息的最大字节数，0 表示不限制
+func (l Limits) messageSize() int64 {
+	switch {
+	case l.MaxMessageSize == 0:
+		return DefaultMaxMessageSize
+	case l.MaxMessageSize < 0:
+		return 0
+	}
+	return int64(l.MaxMessageSize)
+}
+
+// Check 检查解码后的 v 是否满足 MaxLength 和 MaxDepth 的限制
+func (l Limits) Check(v interface{}) error {
+	if l.MaxLength <= 0 && l.MaxDepth <= 0 {
+		return nil
+	}
+	return l.check(reflect.ValueOf(v), 0)
+}
+
+func (l Limits) check(v reflect.Value, depth int) error {
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return errors.New("rpc codec: message nested deeper than " + strconv.Itoa(l.MaxDepth))
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		if l.MaxLength > 0 && v.Len() > l.MaxLength {
+			return errors.New("rpc codec: " + v.Kind().String() + " length " + strconv.Itoa(v.Len()) +
+				" exceeds limit " + strconv.Itoa(l.MaxLength))
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return l.check(v.Elem(), depth+1)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := l.check(v.Field(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil // []byte 只需要检查长度
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := l.check(v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := l.check(iter.Key(), depth+1); err != nil {
+				return err
+			}
+			if err := l.check(iter.Value(), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkJSON 在解码之前扫描 JSON 消息，检查数组和对象的嵌套深度与元素个数，
+// 使声称包含大量元素的消息在分配内存之前就被拒绝。JSON 的嵌套层数不超过解码后的 Go 值的层数，
+// 因此深度检查只会比 Check 宽松；对象的键数同样受 MaxLength 限制，即使它被解码为结构体。解码后仍由 Check 精确检查
+func (l Limits) checkJSON(data []byte) error {
+	if l.MaxLength <= 0 && l.MaxDepth <= 0 {
+		return nil
+	}
+	var commas []int // 每一层容器中已经出现的逗号数，非空容器的元素个数为逗号数加一
+	inString, escaped := false, false
+	for _, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			commas = append(commas, 0)
+			if l.MaxDepth > 0 && len(commas) > l.MaxDepth {
+				return errors.New("rpc codec: message nested deeper than " + strconv.Itoa(l.MaxDepth))
+			}
+		case ']', '}':
+			if len(commas) > 0 {
+				commas = commas[:len(commas)-1]
+			}
+		case ',':
+			if len(commas) == 0 {
+				continue
+			}
+			commas[len(commas)-1]++
+			if l.MaxLength > 0 && commas[len(commas)-1] >= l.MaxLength {
+				return errors.New("rpc codec: json container length exceeds limit " + strconv.Itoa(l.MaxLength))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+type nested struct {
+	Name  string
+	Items []int
+	Next  *nested
+}
+
+// writeBody 使用 f 创建的编解码器写入一个消息，返回写入的连接
+func writeBody(t *testing.T, f NewCodecFunc, body interface{}) *bufferConn {
+	conn := new(bufferConn)
+	if err := f(conn).Write(&Header{ServiceMethod: "Foo.Sum", Seq: 1}, body); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readBody 使用 f 创建的、带有限制 l 的编解码器读取 conn 中的消息体
+func readBody(f NewCodecFunc, conn *bufferConn, l Limits, body interface{}) error {
+	c := f(conn)
+	c.(Limiter).SetLimits(l)
+	var h Header
+	if err := c.ReadHeader(&h); err != nil {
+		return err
+	}
+	return c.ReadBody(body)
+}
+
+func TestLimits(t *testing.T) {
+	deep := &nested{Name: "a", Next: &nested{Name: "b", Next: &nested{Name: "c"}}}
+	for name, f := range map[string]NewCodecFunc{"gob": NewGobCodec, "json": NewJsonCodec} {
+		t.Run(name, func(t *testing.T) {
+			var v nested
+			if err := readBody(f, writeBody(t, f, &nested{Items: make([]int, 10)}), Limits{MaxLength: 10}, &v); err != nil {
+				t.Fatalf("expect a body within the limits to be accepted, got %v", err)
+			}
+			if err := readBody(f, writeBody(t, f, &nested{Items: make([]int, 11)}), Limits{MaxLength: 10}, &v); err == nil {
+				t.Fatal("expect a slice longer than MaxLength to be rejected")
+			}
+			if err := readBody(f, writeBody(t, f, &nested{Name: strings.Repeat("x", 11)}), Limits{MaxLength: 10}, &v); err == nil {
+				t.Fatal("expect a string longer than MaxLength to be rejected")
+			}
+			if err := readBody(f, writeBody(t, f, deep), Limits{MaxDepth: 3}, &v); err == nil {
+				t.Fatal("expect a body nested deeper than MaxDepth to be rejected")
+			}
+			if err := readBody(f, writeBody(t, f, deep), Limits{MaxDepth: 8}, &v); err != nil {
+				t.Fatalf("expect a body within MaxDepth to be accepted, got %v", err)
+			}
+			if err := readBody(f, writeBody(t, f, &nested{Name: strings.Repeat("x", 1024)}), Limits{MaxMessageSize: 512}, &v); err != ErrMessageTooLarge {
+				t.Fatalf("expect ErrMessageTooLarge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLimits_CheckJSON(t *testing.T) {
+	l := Limits{MaxLength: 3, MaxDepth: 2}
+	for data, ok := range map[string]bool{
+		`[1,2,3]`:                   true,
+		`[1,2,3,4]`:                 false,
+		`{"a":1,"b":2,"c":3,"d":4}`: false,
+		`[[1,2,3],[4]]`:             true,
+		`[[[1]]]`:                   false,
+		`["[[[,,,,"]`:               true, // 字符串中的括号和逗号不计入
+		`["a\"[[[,,,,"]`:            true,
+		`[]`:                        true,
+	} {
+		if err := l.checkJSON([]byte(data)); (err == nil) != ok {
+			t.Fatalf("%s: expect ok=%v, got %v", data, ok, err)
+		}
+	}
+}
+
+// TestJsonCodec_LimitsBeforeDecode 检查 JSON 在解码之前拒绝超限的消息：
+// 解码目标为结构体时，超限的数组在解码后已无法检查
+func TestJsonCodec_LimitsBeforeDecode(t *testing.T) {
+	conn := writeBody(t, NewJsonCodec, map[string]interface{}{"Items": make([]struct{}, 100)})
+	var v struct{}
+	if err := readBody(NewJsonCodec, conn, Limits{MaxLength: 10}, &v); err == nil {
+		t.Fatal("expect an oversized array to be rejected before decoding")
+	}
+}
+
+// TestGobCodec_DefaultMessageSize 检查未设置 Limits 时 gob 拒绝超过 DefaultMaxMessageSize 的消息
+func TestGobCodec_DefaultMessageSize(t *testing.T) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	_ = enc.Encode(&Header{ServiceMethod: "Foo.Sum"})
+	_ = enc.Encode(make([]byte, DefaultMaxMessageSize+1))
+	c := NewGobCodec(&bufferConn{Buffer: buf})
+	var h Header
+	if err := c.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	if err := c.ReadBody(&body); err != ErrMessageTooLarge {
+		t.Fatalf("expect ErrMessageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// MessagePack 格式的编解码，只处理通用的值：nil、bool、int64、uint64、float64、string、[]byte、
+// []interface{} 和 map[string]interface{}。与 Go 类型之间的转换借助 encoding/json 完成（参见 MsgpackRPCCodec），
+// 字段名遵循 json 标签，与 JSON-RPC 和 gRPC 适配一致。扩展类型（ext）不被支持
+
+var errMsgpackFormat = errors.New("rpc codec: invalid msgpack data")
+
+// maxMsgpackDepth 是解码时允许的最大嵌套深度，防止恶意的深层嵌套耗尽协程栈，更严格的限制参见 Limits.MaxDepth
+const maxMsgpackDepth = 256
+
+// msgpackReader 从连接中解码 MessagePack 值，每个消息解码之前调用 reset
+type msgpackReader struct {
+	r     *bufio.Reader
+	n     int64 // 当前消息已读取的字节数
+	limit int64 // 单个消息的最大字节数，0 表示不限制
+}
+
+func (d *msgpackReader) reset() { d.n = 0 }
+
+// reserve 在读取 n 个字节之前检查消息的大小限制
+func (d *msgpackReader) reserve(n int64) error {
+	if d.limit > 0 && n > d.limit-d.n {
+		return ErrMessageTooLarge
+	}
+	d.n += n
+	return nil
+}
+
+func (d *msgpackReader) readByte() (byte, error) {
+	if err := d.reserve(1); err != nil {
+		return 0, err
+	}
+	return d.r.ReadByte()
+}
+
+// readUint 读取 size 个字节的大端序无符号整数
+func (d *msgpackReader) readUint(size int) (uint64, error) {
+	if err := d.reserve(int64(size)); err != nil {
+		return 0, err
+	}
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// readBytes 读取 n 个字节。没有大小限制时按块读取，避免声称很大的长度一次分配大量内存
+func (d *msgpackReader) readBytes(n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, ErrMessageTooLarge
+	}
+	if err := d.reserve(int64(n)); err != nil {
+		return nil, err
+	}
+	if n <= 64<<10 {
+		b := make([]byte, n)
+		_, err := io.ReadFull(d.r, b)
+		return b, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode 解码一个值，depth 是当前的嵌套深度
+func (d *msgpackReader) decode(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, errMsgpackFormat
+	}
+	c, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.decodeMap(uint64(c&0x0f), depth)
+	case c >= 0x90 && c <= 0x9f:
+		return d.decodeArray(uint64(c&0x0f), depth)
+	case c >= 0xa0 && c <= 0xbf:
+		b, err := d.readBytes(uint64(c & 0x1f))
+		return string(b), err
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin 8/16/32
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8/16/32/64
+		u, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return u, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3: // int 8/16/32/64
+		size := 1 << (c - 0xd0)
+		u, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, nil // 符号扩展
+	case 0xd9, 0xda, 0xdb: // str 8/16/32
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(n)
+		return string(b), err
+	case 0xdc, 0xdd: // array 16/32
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf: // map 16/32
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("rpc codec: unsupported msgpack type 0x%02x", c)
+}
+
+// checkCount 检查数组或映射声称的元素个数，每个元素至少占用一个字节
+func (d *msgpackReader) checkCount(n uint64) error {
+	if n > math.MaxInt32 || (d.limit > 0 && int64(n) > d.limit-d.n) {
+		return ErrMessageTooLarge
+	}
+	return nil
+}
+
+func (d *msgpackReader) decodeArray(n uint64, depth int) (interface{}, error) {
+	if err := d.checkCount(n); err != nil {
+		return nil, err
+	}
+	a := make([]interface{}, 0, minInt(int(n), 1024))
+	for i := uint64(0); i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// decodeMap 解码映射，不是字符串的键转换为字符串，以便之后转换为 JSON 对象
+func (d *msgpackReader) decodeMap(n uint64, depth int) (interface{}, error) {
+	if err := d.checkCount(n); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, minInt(int(n), 1024))
+	for i := uint64(0); i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch k := k.(type) {
+		case string:
+			m[k] = v
+		case []byte:
+			m[string(k)] = v
+		default:
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// appendMsgpack 将通用的值编码后追加到 b，json.Number 按整数或浮点数编码
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int64:
+		return appendMsgpackInt(b, v), nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return appendMsgpackInt(b, int64(v)), nil
+		}
+		return appendUint(append(b, 0xcf), v, 8), nil
+	case float64:
+		return appendUint(append(b, 0xcb), math.Float64bits(v), 8), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return appendMsgpackInt(b, i), nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return appendMsgpack(b, u)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendMsgpack(b, f)
+	case string:
+		n := len(v)
+		switch {
+		case n <= 31:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = appendUint(append(b, 0xda), uint64(n), 2)
+		default:
+			b = appendUint(append(b, 0xdb), uint64(n), 4)
+		}
+		return append(b, v...), nil
+	case []byte:
+		n := len(v)
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			b = appendUint(append(b, 0xc5), uint64(n), 2)
+		default:
+			b = appendUint(append(b, 0xc6), uint64(n), 4)
+		}
+		return append(b, v...), nil
+	case []interface{}:
+		b = appendMsgpackLen(b, len(v), 0x90, 0xdc)
+		var err error
+		for _, e := range v {
+			if b, err = appendMsgpack(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = appendMsgpackLen(b, len(v), 0x80, 0xde)
+		var err error
+		for k, e := range v {
+			b, _ = appendMsgpack(b, k)
+			if b, err = appendMsgpack(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("rpc codec: cannot encode %T as msgpack", v)
+}
+
+// appendMsgpackInt 使用最短的形式编码整数
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return appendUint(append(b, 0xd1), uint64(i), 2)
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return appendUint(append(b, 0xd2), uint64(i), 4)
+	}
+	return appendUint(append(b, 0xd3), uint64(i), 8)
+}
+
+// appendMsgpackLen 编码数组或映射的长度，fix 是长度不超过 15 时的类型字节，ext16 是 16 位长度的类型字节
+func appendMsgpackLen(b []byte, n int, fix, ext16 byte) []byte {
+	switch {
+	case n <= 15:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, ext16), uint64(n), 2)
+	}
+	return appendUint(append(b, ext16+1), uint64(n), 4)
+}
+
+// appendUint 追加 size 个字节的大端序整数
+func appendUint(b []byte, u uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(u>>(8*uint(i))))
+	}
+	return b
+}
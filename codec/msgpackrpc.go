@@ -0,0 +1,251 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// MessagePack-RPC 的消息类型
+const (
+	msgpackRequest  = 0 // [0, msgid, method, params]
+	msgpackResponse = 1 // [1, msgid, error, result]
+	msgpackNotify   = 2 // [2, method, params]
+)
+
+// MsgpackRPCCodec 实现了 Codec 接口，使用 MessagePack-RPC 的消息格式，
+// 使 Python、Ruby 等语言现有的 msgpack-rpc 客户端无需转换层即可调用 geerpc 服务：
+//   - 请求的 method 即 "Service.Method"，msgid 由 Codec 映射为 Header.Seq，响应原样带回；
+//   - 通知（notify）照常调用服务方法，但不发送响应；
+//   - params 为空时参数为零值，只有一个元素时该元素为参数；参数是结构体时，多个元素依次对应结构体的导出字段，
+//     参数是切片时，多个元素组成该切片；
+//   - 参数和返回值通过 encoding/json 与 Go 类型相互转换，字段名遵循 json 标签；
+//   - 错误以字符串形式放在响应的 error 中。
+//
+// MessagePack-RPC 没有握手，连接上不能传递凭证、签名或加密，参见 geerpc.ServeMsgpackRPCConn
+type MsgpackRPCCodec struct {
+	conn io.ReadWriteCloser
+	r    *msgpackReader
+
+	params   []interface{} // 最近一次 ReadHeader 读取的请求参数
+	seq      uint64        // 最近一次分配的序列号，只在读取消息的协程中访问
+	readBody int
+
+	mu      sync.Mutex             // 保护 pending
+	pending map[uint64]interface{} // 序列号 -> 请求的 msgid，通知不在其中
+
+	out         []byte // 编码响应的缓冲区，只在持有写锁时访问
+	writtenBody int
+	limits      Limits
+}
+
+var _ Codec = (*MsgpackRPCCodec)(nil)
+var _ Sizer = (*MsgpackRPCCodec)(nil)
+var _ Limiter = (*MsgpackRPCCodec)(nil)
+var _ Buffered = (*MsgpackRPCCodec)(nil)
+
+// NewMsgpackRPCCodec 创建一个 MsgpackRPCCodec 实例
+func NewMsgpackRPCCodec(conn io.ReadWriteCloser) Codec {
+	return &MsgpackRPCCodec{
+		conn:    conn,
+		r:       &msgpackReader{r: bufio.NewReader(conn), limit: DefaultMaxMessageSize},
+		pending: make(map[uint64]interface{}),
+	}
+}
+
+// SetLimits 设置解码的资源限制，默认只限制单个消息不超过 DefaultMaxMessageSize。应在读取第一个消息之前调用
+func (c *MsgpackRPCCodec) SetLimits(l Limits) {
+	c.limits = l
+	c.r.limit = l.messageSize()
+}
+
+// ReadHeader 读取一个完整的请求或通知消息，参数留给 ReadBody 转换
+func (c *MsgpackRPCCodec) ReadHeader(h *Header) error {
+	c.r.reset()
+	v, err := c.r.decode(0)
+	if err != nil {
+		return err
+	}
+	c.readBody = int(c.r.n)
+	msg, ok := v.([]interface{})
+	if !ok || len(msg) == 0 {
+		return errMsgpackFormat
+	}
+	typ, _ := msg[0].(int64)
+	var method, params interface{}
+	var msgid interface{}
+	switch {
+	case typ == msgpackRequest && len(msg) == 4:
+		msgid, method, params = msg[1], msg[2], msg[3]
+	case typ == msgpackNotify && len(msg) == 3:
+		method, params = msg[1], msg[2]
+	default:
+		return errors.New("rpc codec: expect a msgpack-rpc request or notification")
+	}
+	switch m := method.(type) {
+	case string:
+		h.ServiceMethod = m
+	case []byte: // 旧版本的客户端把字符串编码为 raw
+		h.ServiceMethod = string(m)
+	default:
+		return errors.New("rpc codec: msgpack-rpc method must be a string")
+	}
+	switch p := params.(type) {
+	case []interface{}:
+		c.params = p
+	case nil:
+		c.params = nil
+	default:
+		c.params = []interface{}{p}
+	}
+	c.seq++
+	h.Seq = c.seq
+	if typ == msgpackRequest {
+		c.mu.Lock()
+		c.pending[h.Seq] = msgid
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// ReadBody 将 ReadHeader 读取的参数转换到 body 中，body 为 nil 时丢弃参数
+func (c *MsgpackRPCCodec) ReadBody(body interface{}) error {
+	params := c.params
+	c.params = nil
+	if body == nil {
+		return nil
+	}
+	arg, err := msgpackArg(params, reflect.TypeOf(body).Elem())
+	if err != nil || arg == nil {
+		return err
+	}
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, body); err != nil {
+		return err
+	}
+	return c.limits.Check(body)
+}
+
+// msgpackArg 根据参数类型 t 将位置参数组合为一个值，没有参数时返回 nil
+func msgpackArg(params []interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case len(params) == 0:
+		return nil, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		if _, ok := params[0].([]interface{}); ok && len(params) == 1 {
+			return params[0], nil
+		}
+		return params, nil
+	case len(params) == 1:
+		return params[0], nil
+	case t.Kind() == reflect.Struct:
+		names := jsonFieldNames(t)
+		if len(params) > len(names) {
+			return nil, errors.New("rpc codec: too many positional parameters for " + t.String())
+		}
+		m := make(map[string]interface{}, len(params))
+		for i, p := range params {
+			m[names[i]] = p
+		}
+		return m, nil
+	}
+	return nil, errors.New("rpc codec: expect at most one positional parameter")
+}
+
+// jsonFieldNames 按顺序返回结构体导出字段在 JSON 中的名字
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag == "-" {
+			continue
+		} else if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Write 发送请求的响应，通知的响应被丢弃
+func (c *MsgpackRPCCodec) Write(h *Header, body interface{}) (err error) {
+	c.mu.Lock()
+	msgid, ok := c.pending[h.Seq]
+	delete(c.pending, h.Seq)
+	c.mu.Unlock()
+	if !ok {
+		c.writtenBody = 0
+		return nil
+	}
+	var errv, result interface{}
+	if h.Error != "" {
+		errv = h.Error
+	} else if result, err = msgpackValue(body); err != nil {
+		errv, result = "rpc codec: cannot encode result: "+err.Error(), nil
+	}
+	b := append(c.out[:0], 0x94, msgpackResponse)
+	if b, err = appendMsgpack(b, msgid); err == nil {
+		b, err = appendMsgpack(b, errv)
+	}
+	start := len(b)
+	if err == nil {
+		b, err = appendMsgpack(b, result)
+	}
+	if err != nil {
+		return err
+	}
+	c.out = b
+	c.writtenBody = len(b) - start
+	if _, err = c.conn.Write(b); err != nil {
+		_ = c.Close()
+	}
+	return err
+}
+
+// msgpackValue 借助 encoding/json 将 Go 的值转换为可以编码为 MessagePack 的通用值
+func msgpackValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out interface{}
+	err = dec.Decode(&out)
+	return out, err
+}
+
+// ReadBodySize 返回最近一次读取的消息的字节数（包括方法名等消息头）
+func (c *MsgpackRPCCodec) ReadBodySize() int {
+	return c.readBody
+}
+
+// WrittenBodySize 返回最近一次 Write 写入的返回值的字节数
+func (c *MsgpackRPCCodec) WrittenBodySize() int {
+	return c.writtenBody
+}
+
+// Buffered 返回已经读取但尚未解码的字节数
+func (c *MsgpackRPCCodec) Buffered() int {
+	return c.r.r.Buffered()
+}
+
+// Close 关闭连接
+func (c *MsgpackRPCCodec) Close() error {
+	return c.conn.Close()
+}
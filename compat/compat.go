@@ -0,0 +1,255 @@
+// Package compat 检查服务 API 的兼容性：将服务器的反射数据（服务、方法以及参数和返回值的字段结构）保存为快照，
+// 在部署之前与上一个版本的快照比较，找出会让已有调用方出错的变更，例如删除了方法、改变了字段的类型，
+// 或者在 JSON 编解码器下改变了字段的名字。
+//
+// 快照可以在构建时通过 Server.Schema 生成，也可以通过 Fetch 从开启了 SetReflection 的服务器获取，
+// 参见 cmd/geerpc-compat
+package compat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"geerpc"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtinServices 是框架内置的服务，由框架负责兼容性，Fetch 不把它们放入快照
+var builtinServices = map[string]bool{"Health": true, "Reflection": true}
+
+// Fetch 通过内置的 Reflection 服务获取服务器上注册的服务，不包括内置服务。服务器需要开启 SetReflection
+func Fetch(ctx context.Context, client *geerpc.Client) ([]geerpc.ServiceSchema, error) {
+	var all []geerpc.ServiceSchema
+	if err := client.Call(ctx, "Reflection.List", "", &all); err != nil {
+		return nil, err
+	}
+	services := all[:0]
+	for _, s := range all {
+		if !builtinServices[s.Name] {
+			services = append(services, s)
+		}
+	}
+	return services, nil
+}
+
+// Write 将快照以缩进的 JSON 格式写入 w，便于提交到版本库并审查差异
+func Write(w io.Writer, services []geerpc.ServiceSchema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(services)
+}
+
+// Read 读取 Write 写入的快照
+func Read(r io.Reader) ([]geerpc.ServiceSchema, error) {
+	var services []geerpc.ServiceSchema
+	if err := json.NewDecoder(r).Decode(&services); err != nil {
+		return nil, fmt.Errorf("rpc compat: invalid snapshot: %v", err)
+	}
+	return services, nil
+}
+
+// Change 是两个快照之间的一处差异
+type Change struct {
+	Path     string // 发生变化的位置，例如 "Foo.Sum args.Num1"
+	Message  string
+	Breaking bool // 是否会让已有的调用方出错或丢失数据
+}
+
+// String 返回可读的描述，不兼容的变更以 "BREAKING" 开头
+func (c Change) String() string {
+	level := "compatible"
+	if c.Breaking {
+		level = "BREAKING"
+	}
+	return level + ": " + c.Path + ": " + c.Message
+}
+
+// HasBreaking 判断 changes 中是否有不兼容的变更
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff 比较旧快照 old 和新快照 cur，返回按位置排序的差异。判断兼容性时假定旧的调用方与新的服务器通信：
+//   - 删除服务或方法、删除字段（同时检查 gob 使用的字段名和 JSON 使用的名字）、改变类型是不兼容的；
+//   - 参数的数字类型变宽（例如 int32 变为 int64）是兼容的，返回值则相反，因为旧的调用方可能无法容纳更大的值；
+//   - 新增服务、方法和字段是兼容的，旧的调用方不会发送也不会读取它们
+func Diff(old, cur []geerpc.ServiceSchema) []Change {
+	d := &differ{}
+	curServices := make(map[string]geerpc.ServiceSchema, len(cur))
+	for _, s := range cur {
+		curServices[s.Name] = s
+	}
+	oldServices := make(map[string]bool, len(old))
+	for _, oldSvc := range old {
+		oldServices[oldSvc.Name] = true
+		curSvc, ok := curServices[oldSvc.Name]
+		if !ok {
+			d.add(oldSvc.Name, true, "service removed")
+			continue
+		}
+		curMethods := make(map[string]geerpc.MethodSchema, len(curSvc.Methods))
+		for _, m := range curSvc.Methods {
+			curMethods[m.Name] = m
+		}
+		oldMethods := make(map[string]bool, len(oldSvc.Methods))
+		for _, om := range oldSvc.Methods {
+			oldMethods[om.Name] = true
+			path := oldSvc.Name + "." + om.Name
+			nm, ok := curMethods[om.Name]
+			if !ok {
+				d.add(path, true, "method removed")
+				continue
+			}
+			d.compare(path+" args", om.Args, nm.Args, true)
+			d.compare(path+" reply", om.Reply, nm.Reply, false)
+		}
+		for _, m := range curSvc.Methods {
+			if !oldMethods[m.Name] {
+				d.add(oldSvc.Name+"."+m.Name, false, "method added")
+			}
+		}
+	}
+	for _, s := range cur {
+		if !oldServices[s.Name] {
+			d.add(s.Name, false, "service added")
+		}
+	}
+	sort.SliceStable(d.changes, func(i, j int) bool { return d.changes[i].Path < d.changes[j].Path })
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(path string, breaking bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{Path: path, Message: fmt.Sprintf(format, args...), Breaking: breaking})
+}
+
+// compare 比较同一位置的新旧类型，args 表示该类型由调用方发送给服务器（参数），否则由服务器发送给调用方（返回值）
+func (d *differ) compare(path string, o, n *geerpc.TypeSchema, args bool) {
+	if o == nil || n == nil {
+		return
+	}
+	if o.Kind != n.Kind {
+		if from, to, ok := numericSizes(o.Kind, n.Kind); ok {
+			// 参数变宽、返回值变窄时接收的一方可以容纳发送的一方的所有值
+			if to == from || (to > from) == args {
+				d.add(path, false, "%s changed to %s", o.Kind, n.Kind)
+			} else {
+				d.add(path, true, "%s changed to %s, values may overflow", o.Kind, n.Kind)
+			}
+			return
+		}
+		d.add(path, true, "type changed from %s to %s", kindString(o), kindString(n))
+		return
+	}
+	switch o.Kind {
+	case "slice":
+		d.compare(path+"[]", o.Elem, n.Elem, args)
+	case "array":
+		if o.Len != n.Len {
+			d.add(path, true, "array length changed from %d to %d", o.Len, n.Len)
+		}
+		d.compare(path+"[]", o.Elem, n.Elem, args)
+	case "map":
+		d.compare(path+"[key]", o.Key, n.Key, args)
+		d.compare(path+"[]", o.Elem, n.Elem, args)
+	case "struct":
+		d.compareFields(path, o, n, args)
+	case "opaque", "interface", "recursive":
+		if o.Name != n.Name {
+			d.add(path, false, "%s type changed from %s to %s and cannot be checked", o.Kind, o.Name, n.Name)
+		}
+	}
+}
+
+// compareFields 比较结构体的字段。gob 按字段名匹配，JSON 按 json 标签中的名字匹配，两者分别检查
+func (d *differ) compareFields(path string, o, n *geerpc.TypeSchema, args bool) {
+	byName := make(map[string]*geerpc.FieldSchema, len(n.Fields))
+	byJSON := make(map[string]*geerpc.FieldSchema, len(n.Fields))
+	for i := range n.Fields {
+		f := &n.Fields[i]
+		byName[f.Name] = f
+		if name := jsonName(f); name != "-" {
+			byJSON[name] = f
+		}
+	}
+	matched := make(map[string]bool, len(o.Fields))
+	for i := range o.Fields {
+		of := &o.Fields[i]
+		fpath := path + "." + of.Name
+		nf, inGob := byName[of.Name]
+		oj := jsonName(of)
+		_, inJSON := byJSON[oj]
+		if oj == "-" {
+			inJSON = true // JSON 本来就不传输该字段
+		}
+		switch {
+		case !inGob && !inJSON:
+			d.add(fpath, true, "field removed")
+		case !inGob:
+			d.add(fpath, true, "field removed or renamed (gob)")
+		case !inJSON:
+			d.add(fpath, true, "JSON name %q removed or renamed (json)", oj)
+		}
+		if inGob {
+			matched[nf.Name] = true
+			d.compare(fpath, of.Type, nf.Type, args)
+		}
+	}
+	for _, f := range n.Fields {
+		if !matched[f.Name] {
+			d.add(path+"."+f.Name, false, "field added")
+		}
+	}
+}
+
+// jsonName 返回字段在 JSON 中的名字
+func jsonName(f *geerpc.FieldSchema) string {
+	if f.JSON != "" {
+		return f.JSON
+	}
+	return f.Name
+}
+
+// kindString 返回类型的简短描述，具名类型附带名字
+func kindString(t *geerpc.TypeSchema) string {
+	if t.Name != "" {
+		return t.Kind + " (" + t.Name + ")"
+	}
+	return t.Kind
+}
+
+// numericSizes 在 from 和 to 是同一类数字（有符号整数、无符号整数或浮点数）时返回它们的位数。
+// int 和 uint 按 64 位计算，与 gob 的编码方式一致
+func numericSizes(from, to string) (int, int, bool) {
+	class := func(kind string) (string, int) {
+		for _, prefix := range []string{"int", "uint", "float"} {
+			if strings.HasPrefix(kind, prefix) {
+				rest := strings.TrimPrefix(kind, prefix)
+				if rest == "" && prefix != "float" {
+					return prefix, 64
+				}
+				if n, err := strconv.Atoi(rest); err == nil {
+					return prefix, n
+				}
+			}
+		}
+		return "", 0
+	}
+	fc, fs := class(from)
+	tc, ts := class(to)
+	if fc == "" || fc != tc {
+		return 0, 0, false
+	}
+	return fs, ts, true
+}
@@ -0,0 +1,109 @@
+package compat
+
+import (
+	"bytes"
+	"context"
+	"geerpc"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ArgsV1 struct {
+	Num1, Num2 int32
+	Note       string `json:"note"`
+	Dropped    bool
+}
+
+type ReplyV1 struct {
+	Sum   int32
+	Items []string
+}
+
+type calcV1 int
+
+func (calcV1) Sum(args ArgsV1, reply *ReplyV1) error { return nil }
+func (calcV1) Sub(args ArgsV1, reply *ReplyV1) error { return nil }
+
+type ArgsV2 struct {
+	Num1, Num2 int64
+	Note       string `json:"comment"`
+	Extra      string
+}
+
+type ReplyV2 struct {
+	Sum   int64
+	Items []int
+}
+
+type calcV2 int
+
+func (calcV2) Sum(args ArgsV2, reply *ReplyV2) error { return nil }
+func (calcV2) Mul(args ArgsV2, reply *ReplyV2) error { return nil }
+
+func schema(t *testing.T, rcvr interface{}) []geerpc.ServiceSchema {
+	server := geerpc.NewServer()
+	if err := server.RegisterName("Calc", rcvr); err != nil {
+		t.Fatal(err)
+	}
+	return server.Schema()
+}
+
+func TestDiff(t *testing.T) {
+	changes := Diff(schema(t, new(calcV1)), schema(t, new(calcV2)))
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+	want := []string{
+		"compatible: Calc.Mul: method added",
+		"BREAKING: Calc.Sub: method removed",
+		"BREAKING: Calc.Sum args.Dropped: field removed",
+		"compatible: Calc.Sum args.Extra: field added",
+		"BREAKING: Calc.Sum args.Note: JSON name \"note\" removed or renamed (json)",
+		"compatible: Calc.Sum args.Num1: int32 changed to int64",
+		"compatible: Calc.Sum args.Num2: int32 changed to int64",
+		"BREAKING: Calc.Sum reply.Items[]: type changed from string to int",
+		"BREAKING: Calc.Sum reply.Sum: int32 changed to int64, values may overflow",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !HasBreaking(changes) {
+		t.Fatal("expect breaking changes")
+	}
+	if changes := Diff(schema(t, new(calcV1)), schema(t, new(calcV1))); len(changes) != 0 {
+		t.Fatalf("expect no changes, got %v", changes)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := geerpc.NewServer()
+	server.SetReflection(true)
+	server.SetConnRateLimit(0, 0)
+	_ = server.RegisterName("Calc", new(calcV1))
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	go server.Accept(l)
+	defer l.Close()
+	client, err := geerpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	services, err := Fetch(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, services); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshot, server.Schema()) {
+		t.Fatalf("expect the snapshot to match the server schema, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,401 @@
+// Package config 从配置文件构造 Server、客户端的 Option、服务发现和负载均衡模式，
+// 使各个服务共用同一种配置格式，而不必各自解析命令行参数。支持 YAML 和 TOML 的常用子集
+// （参见 confparse.ParseYAML 和 confparse.ParseTOML），文件中的每个值都可以被环境变量覆盖，例如：
+//
+//	server:
+//	  addr: ":9999"
+//	  handle_timeout: 5s
+//	  ip_allow: [10.0.0.0/8]
+//	client:
+//	  codec: json
+//	  select_mode: round_robin
+//	  discovery:
+//	    type: registry
+//	    registry: http://localhost:9999/_geerpc_/registry
+//
+// GEERPC_SERVER_HANDLE_TIMEOUT=10s 覆盖 server.handle_timeout，列表使用逗号分隔，
+// 适合在容器中注入凭证等不应写入文件的配置
+package config
+
+import (
+	"errors"
+	"fmt"
+	"geerpc"
+	"geerpc/codec"
+	"geerpc/internal/confparse"
+	"geerpc/xclient"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultEnvPrefix 是 Load 使用的环境变量前缀
+const DefaultEnvPrefix = "GEERPC"
+
+// Format 是配置文件的格式
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// Config 是服务端和客户端共用的配置，未设置的值使用框架的默认值
+type Config struct {
+	Server ServerConfig `config:"server"`
+	Client ClientConfig `config:"client"`
+}
+
+// ServerConfig 是服务端的配置
+type ServerConfig struct {
+	Network string `config:"network"` // 监听的网络，默认为 "tcp"
+	Addr    string `config:"addr"`    // 监听的地址，例如 ":9999"
+
+	HandleTimeout      time.Duration `config:"handle_timeout"`        // 参见 Server.SetHandleTimeout
+	SlowCallThreshold  time.Duration `config:"slow_call_threshold"`   // 参见 Server.SetSlowCallThreshold
+	OverloadThreshold  int           `config:"overload_threshold"`    // 参见 Server.SetOverloadThreshold
+	RateLimitPerSecond int           `config:"rate_limit_per_second"` // 每个连接每秒的请求数，0 使用默认值，负数表示不限制
+	RateLimitBurst     int           `config:"rate_limit_burst"`      // 每个连接的突发请求数，0 表示与 RateLimitPerSecond 相同
+
+	WriteCoalescingDelay time.Duration `config:"write_coalescing_delay"` // 参见 Server.SetWriteCoalescing，0 表示不合并
+	WriteCoalescingBytes int           `config:"write_coalescing_bytes"` // 参见 Server.SetWriteCoalescing
+	ChunkSize            int           `config:"chunk_size"`             // 参见 Server.SetChunkSize，0 表示不分块
+
+	LogLevel       string `config:"log_level"`       // "debug"、"info"、"warn" 或 "error"，text 格式时修改默认 Logger 的级别，为空时不修改
+	LogFormat      string `config:"log_format"`      // "text"（默认，使用标准库 log 包）或 "json"（输出到标准错误）
+	RequestLogging bool   `config:"request_logging"` // 参见 Server.SetRequestLogging
+
+	JSONRPC          bool     `config:"jsonrpc"`           // 参见 Server.SetJSONRPC
+	Reflection       bool     `config:"reflection"`        // 参见 Server.SetReflection
+	DisableHealth    bool     `config:"disable_health"`    // 参见 Server.SetHealthService
+	IPAllow          []string `config:"ip_allow"`          // 参见 Server.SetIPFilter
+	IPDeny           []string `config:"ip_deny"`           // 参见 Server.SetIPFilter
+	WebSocketOrigins []string `config:"websocket_origins"` // 参见 Server.SetWebSocketOrigins
+}
+
+// ClientConfig 是客户端的配置
+type ClientConfig struct {
+	Codec             string        `config:"codec"`               // "gob"（默认）或 "json"
+	ConnectTimeout    time.Duration `config:"connect_timeout"`     // 0 使用 DefaultOption 的值
+	HandleTimeout     time.Duration `config:"handle_timeout"`      // 参见 Option.HandleTimeout
+	SlowCallThreshold time.Duration `config:"slow_call_threshold"` // 参见 Option.SlowCallThreshold
+	Credentials       string        `config:"credentials"`         // 参见 Option.Credentials，通常通过环境变量设置
+
+	// SelectMode 是负载均衡模式："random"（默认）、"round_robin"、"weighted_random"、"hash" 或 "least_load"
+	SelectMode string          `config:"select_mode"`
+	Discovery  DiscoveryConfig `config:"discovery"`
+}
+
+// DiscoveryConfig 是服务发现的配置，Type 决定使用哪些字段：
+//   - "static"：Servers 中固定的服务器列表，例如 "tcp@10.0.0.1:9999"
+//   - "file"：Path 指向的 JSON 或 YAML 文件，每隔 Interval 检查一次，参见 xclient.NewFileDiscovery
+//   - "registry"：Registry 地址的 geerpc 注册中心，Service 不为空时只发现提供该服务的服务器
+//   - "dns"：Name 解析出的地址加上 Port
+//   - "consul"：Consul 地址上名为 Service 的服务
+type DiscoveryConfig struct {
+	Type     string        `config:"type"`
+	Servers  []string      `config:"servers"`
+	Path     string        `config:"path"`
+	Interval time.Duration `config:"interval"`
+	Registry string        `config:"registry"`
+	Consul   string        `config:"consul"`
+	Service  string        `config:"service"`
+	Name     string        `config:"name"`
+	Port     int           `config:"port"`
+	Timeout  time.Duration `config:"timeout"` // 请求注册中心、DNS 或 Consul 的超时，0 使用各自的默认值
+	Watch    bool          `config:"watch"`   // registry 和 consul 是否通过长轮询监听变化
+}
+
+// selectModes 是 SelectMode 的取值
+var selectModes = map[string]xclient.SelectMode{
+	"":                xclient.RandomSelect,
+	"random":          xclient.RandomSelect,
+	"round_robin":     xclient.RoundRobinSelect,
+	"weighted_random": xclient.WeightedRandomSelect,
+	"hash":            xclient.HashSelect,
+	"least_load":      xclient.LeastLoadSelect,
+}
+
+// codecTypes 是 Codec 的取值
+var codecTypes = map[string]codec.Type{
+	"":     codec.GobType,
+	"gob":  codec.GobType,
+	"json": codec.JsonType,
+}
+
+// Load 读取配置文件，格式由扩展名决定（.yaml、.yml 或 .toml），然后使用以 DefaultEnvPrefix 开头的环境变量覆盖，
+// 最后检查配置是否有效
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = YAML
+	case ".toml":
+		format = TOML
+	default:
+		return nil, errors.New("rpc config: unknown config file extension: " + path)
+	}
+	c, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := c.ApplyEnv(DefaultEnvPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Parse 解析配置文件的内容，未知的键返回错误，以便及时发现拼写错误。Parse 不检查配置是否有效，参见 Validate
+func Parse(data []byte, format Format) (*Config, error) {
+	var vs confparse.Values
+	var err error
+	switch format {
+	case YAML:
+		vs, err = confparse.ParseYAML(data)
+	case TOML:
+		vs, err = confparse.ParseTOML(data)
+	default:
+		return nil, errors.New("rpc config: unknown format " + string(format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	fs := make(map[string]reflect.Value)
+	fields(reflect.ValueOf(c).Elem(), "", fs)
+	names := make([]string, 0, len(vs))
+	for key := range vs {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		v := vs[key]
+		f, ok := fs[key]
+		if !ok {
+			return nil, fmt.Errorf("rpc config: line %d: unknown key %q", v.Line, key)
+		}
+		if err := setField(f, v); err != nil {
+			return nil, fmt.Errorf("rpc config: line %d: %s: %v", v.Line, key, err)
+		}
+	}
+	return c, nil
+}
+
+// ApplyEnv 使用环境变量覆盖配置，变量名为 prefix 加上大写的键，"." 替换为 "_"，
+// 例如 GEERPC_CLIENT_DISCOVERY_TYPE。lookup 通常为 os.LookupEnv
+func (c *Config) ApplyEnv(prefix string, lookup func(key string) (string, bool)) error {
+	fs := make(map[string]reflect.Value)
+	fields(reflect.ValueOf(c).Elem(), "", fs)
+	for _, key := range keys(fs) {
+		name := strings.ToUpper(prefix + "_" + strings.Replace(key, ".", "_", -1))
+		s, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(fs[key], confparse.Value{Scalar: s}); err != nil {
+			return fmt.Errorf("rpc config: %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate 检查配置是否有效，返回的错误包含所有无效的值
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	s := &c.Server
+	check(s.Addr != "" || s.Network == "", "server.network is set but server.addr is empty")
+	check(s.HandleTimeout >= 0, "server.handle_timeout must not be negative")
+	check(s.SlowCallThreshold >= 0, "server.slow_call_threshold must not be negative")
+	check(s.OverloadThreshold >= 0, "server.overload_threshold must not be negative")
+	check(s.RateLimitBurst >= 0, "server.rate_limit_burst must not be negative")
+	if s.LogLevel != "" {
+		_, err := geerpc.ParseLevel(s.LogLevel)
+		check(err == nil, "server.log_level: unknown level %q", s.LogLevel)
+	}
+	check(s.LogFormat == "" || s.LogFormat == "text" || s.LogFormat == "json", "server.log_format must be text or json, got %q", s.LogFormat)
+	for _, cidr := range append(append([]string(nil), s.IPAllow...), s.IPDeny...) {
+		check(validCIDR(cidr), "server.ip_allow/ip_deny: invalid address %q", cidr)
+	}
+
+	cl := &c.Client
+	_, ok := codecTypes[cl.Codec]
+	check(ok, "client.codec must be gob or json, got %q", cl.Codec)
+	_, ok = selectModes[cl.SelectMode]
+	check(ok, "client.select_mode: unknown mode %q", cl.SelectMode)
+	check(cl.ConnectTimeout >= 0, "client.connect_timeout must not be negative")
+	check(cl.HandleTimeout >= 0, "client.handle_timeout must not be negative")
+
+	d := &cl.Discovery
+	switch d.Type {
+	case "":
+	case "static":
+		check(len(d.Servers) > 0, "client.discovery.servers is required for static discovery")
+		for _, addr := range d.Servers {
+			check(strings.Contains(addr, "@"), "client.discovery.servers: expect protocol@addr, got %q", addr)
+		}
+	case "file":
+		check(d.Path != "", "client.discovery.path is required for file discovery")
+	case "registry":
+		check(d.Registry != "", "client.discovery.registry is required for registry discovery")
+	case "dns":
+		check(d.Name != "", "client.discovery.name is required for dns discovery")
+		check(d.Port > 0 && d.Port < 65536, "client.discovery.port must be between 1 and 65535")
+	case "consul":
+		check(d.Consul != "", "client.discovery.consul is required for consul discovery")
+		check(d.Service != "", "client.discovery.service is required for consul discovery")
+	default:
+		check(false, "client.discovery.type: unknown type %q", d.Type)
+	}
+	check(d.Interval >= 0 && d.Timeout >= 0, "client.discovery.interval and timeout must not be negative")
+	if len(errs) > 0 {
+		return errors.New("rpc config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validCIDR 判断 s 是否是 CIDR 或单个 IP
+func validCIDR(s string) bool {
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return true
+	}
+	return net.ParseIP(s) != nil
+}
+
+// Apply 将配置应用到 server，应在注册服务和开始服务之前调用
+func (c *ServerConfig) Apply(server *geerpc.Server) error {
+	if c.LogLevel != "" || c.LogFormat == "json" {
+		level := geerpc.LevelInfo
+		if c.LogLevel != "" {
+			var err error
+			if level, err = geerpc.ParseLevel(c.LogLevel); err != nil {
+				return err
+			}
+		}
+		if c.LogFormat == "json" {
+			server.SetLogger(geerpc.NewJSONLogger(os.Stderr, level))
+		} else if l, ok := geerpc.DefaultLogger().(geerpc.LevelLogger); ok {
+			l.SetLevel(level)
+		}
+	}
+	if c.HandleTimeout > 0 {
+		server.SetHandleTimeout(c.HandleTimeout)
+	}
+	if c.SlowCallThreshold > 0 {
+		server.SetSlowCallThreshold(c.SlowCallThreshold)
+	}
+	if c.OverloadThreshold > 0 {
+		server.SetOverloadThreshold(c.OverloadThreshold)
+	}
+	if c.RateLimitPerSecond != 0 {
+		server.SetConnRateLimit(c.RateLimitBurst, c.RateLimitPerSecond)
+	}
+	if c.WriteCoalescingDelay > 0 {
+		server.SetWriteCoalescing(c.WriteCoalescingDelay, c.WriteCoalescingBytes)
+	}
+	if c.ChunkSize > 0 {
+		server.SetChunkSize(c.ChunkSize)
+	}
+	server.SetRequestLogging(c.RequestLogging)
+	server.SetJSONRPC(c.JSONRPC)
+	server.SetReflection(c.Reflection)
+	server.SetHealthService(!c.DisableHealth)
+	if len(c.WebSocketOrigins) > 0 {
+		server.SetWebSocketOrigins(c.WebSocketOrigins...)
+	}
+	return server.SetIPFilter(c.IPAllow, c.IPDeny)
+}
+
+// NewServer 创建一个按配置设置好的 Server
+func (c *ServerConfig) NewServer() (*geerpc.Server, error) {
+	server := geerpc.NewServer()
+	if err := c.Apply(server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// Listen 在配置的地址上监听，返回的监听器通常传给 Server.Accept
+func (c *ServerConfig) Listen() (net.Listener, error) {
+	if c.Addr == "" {
+		return nil, errors.New("rpc config: server.addr is empty")
+	}
+	network := c.Network
+	if network == "" {
+		network = "tcp"
+	}
+	return net.Listen(network, c.Addr)
+}
+
+// Option 返回按配置设置的客户端选项，未设置的值使用 DefaultOption 的值
+func (c *ClientConfig) Option() *geerpc.Option {
+	opt := *geerpc.DefaultOption
+	if t, ok := codecTypes[c.Codec]; ok {
+		opt.CodecType = t
+	}
+	if c.ConnectTimeout > 0 {
+		opt.ConnectTimeout = c.ConnectTimeout
+	}
+	opt.HandleTimeout = c.HandleTimeout
+	opt.SlowCallThreshold = c.SlowCallThreshold
+	opt.Credentials = c.Credentials
+	return &opt
+}
+
+// Mode 返回负载均衡模式，未知的模式返回 RandomSelect（Validate 会报告）
+func (c *ClientConfig) Mode() xclient.SelectMode {
+	return selectModes[c.SelectMode]
+}
+
+// NewDiscovery 按配置创建服务发现，Discovery.Type 为空时返回错误
+func (c *ClientConfig) NewDiscovery() (xclient.Discovery, error) {
+	d := &c.Discovery
+	switch d.Type {
+	case "static":
+		return xclient.NewMultiServerDiscovery(d.Servers), nil
+	case "file":
+		return xclient.NewFileDiscovery(d.Path, d.Interval)
+	case "registry":
+		gd := xclient.NewGeeRegistryServiceDiscovery(d.Registry, d.Service, d.Timeout)
+		if d.Watch {
+			gd.Watch()
+		}
+		return gd, nil
+	case "dns":
+		return xclient.NewDNSDiscovery(d.Name, d.Port, d.Timeout), nil
+	case "consul":
+		cd := xclient.NewConsulDiscovery(d.Consul, d.Service, d.Timeout)
+		if d.Watch {
+			cd.Watch()
+		}
+		return cd, nil
+	case "":
+		return nil, errors.New("rpc config: client.discovery.type is empty")
+	}
+	return nil, errors.New("rpc config: unknown discovery type " + d.Type)
+}
+
+// NewXClient 按配置创建服务发现和 XClient
+func (c *ClientConfig) NewXClient() (*xclient.XClient, error) {
+	d, err := c.NewDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	return xclient.NewXClient(d, c.Mode(), c.Option()), nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"geerpc/codec"
+	"geerpc/xclient"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `
+# 服务端
+server:
+  addr: ":0"
+  handle_timeout: 5s
+  rate_limit_per_second: 100
+  ip_allow: [10.0.0.0/8, "127.0.0.1"]
+  jsonrpc: true
+client:
+  codec: json
+  select_mode: round_robin
+  discovery:
+    type: static
+    servers:
+      - tcp@10.0.0.1:9999
+      - 'tcp@10.0.0.2:9999'  # 第二台
+`
+
+const testTOML = `
+# 服务端
+[server]
+addr = ":0"
+handle_timeout = "5s"
+rate_limit_per_second = 100
+ip_allow = [
+  "10.0.0.0/8",
+  "127.0.0.1",
+]
+jsonrpc = true
+
+[client]
+codec = "json"
+select_mode = "round_robin"
+
+[client.discovery]
+type = "static"
+servers = ["tcp@10.0.0.1:9999", "tcp@10.0.0.2:9999"] # 第二台
+`
+
+func TestParse(t *testing.T) {
+	want := &Config{
+		Server: ServerConfig{
+			Addr:               ":0",
+			HandleTimeout:      5 * time.Second,
+			RateLimitPerSecond: 100,
+			IPAllow:            []string{"10.0.0.0/8", "127.0.0.1"},
+			JSONRPC:            true,
+		},
+		Client: ClientConfig{
+			Codec:      "json",
+			SelectMode: "round_robin",
+			Discovery: DiscoveryConfig{
+				Type:    "static",
+				Servers: []string{"tcp@10.0.0.1:9999", "tcp@10.0.0.2:9999"},
+			},
+		},
+	}
+	for format, data := range map[Format]string{YAML: testYAML, TOML: testTOML} {
+		c, err := Parse([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Fatalf("%s: got %+v, want %+v", format, c, want)
+		}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, data := range []string{
+		"server:\n  adr: \":0\"\n",             // 拼写错误
+		"server:\n  handle_timeout: 5\n",       // 缺少单位
+		"server:\n  addr: a\n  addr: b\n",      // 重复的键
+		"client:\n  codec: [gob, json]\n",      // 标量字段不接受列表
+		"server:\n  jsonrpc: \"unterminated\n", // 未闭合的字符串
+		"- a\n",                                // 没有键的列表项
+		"server:\n\taddr: a\n",                 // 使用制表符缩进
+		"server:\n  - a\n  addr: b\n",          // 列表与嵌套的键混用
+	} {
+		if _, err := Parse([]byte(data), YAML); err == nil {
+			t.Fatalf("expect an error for %q", data)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := Parse([]byte(`
+[server]
+log_level = "loud"
+ip_deny = ["not-an-ip"]
+[client]
+select_mode = "fastest"
+[client.discovery]
+type = "dns"
+`), TOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("expect validation to fail")
+	}
+	for _, s := range []string{"log_level", "ip_allow/ip_deny", "select_mode", "discovery.name", "discovery.port"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expect %q to be reported, got %v", s, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "geerpc.yaml")
+	if err := ioutil.WriteFile(path, []byte(testYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv("GEERPC_SERVER_HANDLE_TIMEOUT", "10s")
+	_ = os.Setenv("GEERPC_CLIENT_DISCOVERY_SERVERS", "tcp@10.0.0.3:9999, tcp@10.0.0.4:9999")
+	defer os.Unsetenv("GEERPC_SERVER_HANDLE_TIMEOUT")
+	defer os.Unsetenv("GEERPC_CLIENT_DISCOVERY_SERVERS")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.HandleTimeout != 10*time.Second {
+		t.Fatalf("expect the environment to override handle_timeout, got %v", c.Server.HandleTimeout)
+	}
+	if want := []string{"tcp@10.0.0.3:9999", "tcp@10.0.0.4:9999"}; !reflect.DeepEqual(c.Client.Discovery.Servers, want) {
+		t.Fatalf("expect the environment to override servers, got %v", c.Client.Discovery.Servers)
+	}
+
+	server, err := c.Server.NewServer()
+	if err != nil || server == nil {
+		t.Fatal("failed to create server:", err)
+	}
+	l, err := c.Server.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	if opt := c.Client.Option(); opt.CodecType != codec.JsonType || opt.ConnectTimeout == 0 {
+		t.Fatalf("unexpected option %+v", opt)
+	}
+	if c.Client.Mode() != xclient.RoundRobinSelect {
+		t.Fatalf("unexpected select mode %v", c.Client.Mode())
+	}
+	xc, err := c.Client.NewXClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = xc.Close()
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"geerpc/internal/confparse"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fields 收集结构体中带有 config 标签的字段，键为以 "." 连接的完整路径，嵌套的结构体展开为多个键
+func fields(v reflect.Value, prefix string, out map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("config")
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			fields(f, name, out)
+			continue
+		}
+		out[name] = f
+	}
+}
+
+// keys 返回排序后的键，保证错误信息和环境变量的处理顺序稳定
+func keys(m map[string]reflect.Value) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField 将 v 转换为字段的类型并赋值，列表字段也接受以逗号分隔的标量（来自环境变量）
+func setField(f reflect.Value, v confparse.Value) error {
+	if f.Kind() == reflect.Slice {
+		list := v.List
+		if !v.IsList {
+			list = nil
+			for _, s := range strings.Split(v.Scalar, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+		return nil
+	}
+	if v.IsList {
+		return errors.New("expect a single value, got a list")
+	}
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(v.Scalar)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v.Scalar)
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(v.Scalar)
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(v.Scalar)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v.Scalar)
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(v.Scalar)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v.Scalar)
+		}
+		f.SetInt(int64(n))
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package geerpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats 是单个连接统计数据的快照
+type ConnStats struct {
+	ID           uint64        `json:"id"`
+	Remote       string        `json:"remote"`
+	Identity     string        `json:"identity,omitempty"` // 调用方身份，例如 mTLS 客户端证书中的 SPIFFE ID
+	Start        time.Time     `json:"start"`
+	Uptime       time.Duration `json:"uptime"`
+	Requests     uint64        `json:"requests"`  // 连接上读取的请求数
+	BytesIn      uint64        `json:"bytes_in"`  // 从连接读取的字节数，包括选项握手
+	BytesOut     uint64        `json:"bytes_out"` // 向连接写入的字节数
+	LastActivity time.Time     `json:"last_activity"`
+}
+
+// connTracker 统计一个连接读写的字节数、请求数和最近一次活动的时间
+type connTracker struct {
+	// 以下计数器使用原子操作访问，放在首位以保证 64 位对齐
+	requests     uint64
+	bytesIn      uint64
+	bytesOut     uint64
+	lastActivity int64 // UnixNano
+
+	io.ReadWriteCloser
+	id       uint64
+	remote   string
+	identity string
+	start    time.Time
+
+	authenticated bool // 握手时携带了有效的凭证
+}
+
+func (c *connTracker) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if n > 0 {
+		atomic.AddUint64(&c.bytesIn, uint64(n))
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *connTracker) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	if n > 0 {
+		atomic.AddUint64(&c.bytesOut, uint64(n))
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// snapshot 返回连接统计数据的副本
+func (c *connTracker) snapshot(now time.Time) ConnStats {
+	return ConnStats{
+		ID:           c.id,
+		Remote:       c.remote,
+		Identity:     c.identity,
+		Start:        c.start,
+		Uptime:       now.Sub(c.start),
+		Requests:     atomic.LoadUint64(&c.requests),
+		BytesIn:      atomic.LoadUint64(&c.bytesIn),
+		BytesOut:     atomic.LoadUint64(&c.bytesOut),
+		LastActivity: time.Unix(0, atomic.LoadInt64(&c.lastActivity)),
+	}
+}
+
+// trackConn 包装连接并登记到服务器的连接表中，返回的 untrack 函数在连接关闭时调用
+func (server *Server) trackConn(id uint64, remote, identity string, conn io.ReadWriteCloser) (c *connTracker, untrack func()) {
+	now := time.Now()
+	c = &connTracker{ReadWriteCloser: conn, id: id, remote: remote, identity: identity, start: now, lastActivity: now.UnixNano()}
+	server.connsMu.Lock()
+	defer server.connsMu.Unlock()
+	if server.conns == nil {
+		server.conns = make(map[uint64]*connTracker)
+	}
+	server.conns[id] = c
+	return c, func() {
+		server.connsMu.Lock()
+		defer server.connsMu.Unlock()
+		delete(server.conns, id)
+	}
+}
+
+// Connections 返回所有打开的连接的统计数据，按请求数从多到少排序，用于找出请求最多的客户端
+func (server *Server) Connections() []ConnStats {
+	now := time.Now()
+	server.connsMu.Lock()
+	stats := make([]ConnStats, 0, len(server.conns))
+	for _, c := range server.conns {
+		stats = append(stats, c.snapshot(now))
+	}
+	server.connsMu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].ID < stats[j].ID
+	})
+	return stats
+}
+
+// serveConnections 以 JSON 格式输出所有打开的连接的统计数据
+func (server *Server) serveConnections(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(server.Connections())
+}
@@ -0,0 +1,65 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_Connections(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	busy, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = busy.Close() }()
+	idle, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply int
+	_ = idle.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	for i := 0; i < 3; i++ {
+		_ = busy.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	}
+
+	// 按请求数从多到少排序
+	stats := server.Connections()
+	if len(stats) != 2 || stats[0].Requests != 3 || stats[1].Requests != 1 {
+		t.Fatalf("expect the busy connection first, got %+v", stats)
+	}
+	for _, s := range stats {
+		if s.Remote != "inproc" || s.BytesIn == 0 || s.BytesOut == 0 || s.Uptime <= 0 ||
+			s.LastActivity.Before(s.Start) || s.LastActivity.After(time.Now()) {
+			t.Fatalf("unexpected stats %+v", s)
+		}
+	}
+	// 两个连接的选项握手相同，请求更多的连接读取的字节更多
+	if stats[0].BytesIn <= stats[1].BytesIn {
+		t.Fatalf("expect more bytes from the busy connection, got %+v", stats)
+	}
+
+	mux := http.NewServeMux()
+	server.HandleDebugHTTP(mux)
+	w := get(mux, defaultDebugPath+"/connections")
+	var served []ConnStats
+	if err := json.NewDecoder(w.Body).Decode(&served); err != nil || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expect JSON, got %v", err)
+	}
+	if len(served) != 2 || served[0].ID != stats[0].ID || served[0].Requests != 3 {
+		t.Fatalf("expect the debug endpoint to serve Connections, got %+v", served)
+	}
+
+	// 关闭的连接从连接表中移除
+	_ = idle.Close()
+	for i := 0; i < 100 && len(server.Connections()) != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := server.Connections(); len(stats) != 1 || stats[0].Requests != 3 {
+		t.Fatalf("expect only the open connection, got %+v", stats)
+	}
+}
@@ -5,9 +5,11 @@
 package geerpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
+	"sort"
 )
 
 // debugText 是用于展示调试信息的 HTML 模板
@@ -19,11 +21,16 @@ const debugText = `<html>
 	Service {{.Name}}
 	<hr>
 		<table>
-		<th align=center>Method</th><th align=center>Calls</th>
+		<th align=center>Method</th><th align=center>Calls</th><th align=center>Errors</th>
+		<th align=center>In-flight</th><th align=center>Mean</th><th align=center>P95</th>
 		{{range $name, $mtype := .Method}}
 			<tr>
 			<td align=left font=fixed>{{$name}}({{$mtype.ArgType}}, {{$mtype.ReplyType}}) error</td>
 			<td align=center>{{$mtype.NumCalls}}</td>
+			<td align=center>{{$mtype.NumErrors}}</td>
+			<td align=center>{{$mtype.Inflight}}</td>
+			<td align=center>{{$mtype.MeanLatency}}</td>
+			<td align=center>{{$mtype.P95Latency}}</td>
 			</tr>
 		{{end}}
 		</table>
@@ -62,3 +69,58 @@ func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		_, _ = fmt.Fprintln(w, "rpc: error executing template:", err.Error())
 	}
 }
+
+// debugMethodStats 是 /debug/geerpc.json 中单个方法的统计信息
+type debugMethodStats struct {
+	Name        string  `json:"name"`
+	ArgType     string  `json:"arg_type"`
+	ReplyType   string  `json:"reply_type"`
+	Calls       uint64  `json:"calls"`
+	Errors      uint64  `json:"errors"`
+	Inflight    int64   `json:"inflight"`
+	MeanLatency float64 `json:"mean_latency_seconds"`
+	P95Latency  float64 `json:"p95_latency_seconds"`
+	RequestSize uint64  `json:"request_bytes"`
+	ReplySize   uint64  `json:"reply_bytes"`
+}
+
+// debugServiceStats 是 /debug/geerpc.json 中单个服务的统计信息
+type debugServiceStats struct {
+	Name    string             `json:"name"`
+	Methods []debugMethodStats `json:"methods"`
+}
+
+// debugJSON 以 JSON 格式输出与调试页面相同的信息，便于工具和仪表盘抓取
+type debugJSON struct {
+	*Server
+}
+
+// ServeHTTP 在 /debug/geerpc.json 上输出按名称排序的服务、方法及其调用统计
+func (server debugJSON) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	services := make([]debugServiceStats, 0)
+	server.serviceMap.Range(func(namei, svci interface{}) bool {
+		svc := svci.(*service)
+		stats := debugServiceStats{Name: namei.(string), Methods: make([]debugMethodStats, 0, len(svc.method))}
+		for name, mtype := range svc.method {
+			stats.Methods = append(stats.Methods, debugMethodStats{
+				Name:        name,
+				ArgType:     mtype.ArgType.String(),
+				ReplyType:   mtype.ReplyType.String(),
+				Calls:       mtype.NumCalls(),
+				Errors:      mtype.NumErrors(),
+				Inflight:    mtype.Inflight(),
+				MeanLatency: mtype.MeanLatency().Seconds(),
+				P95Latency:  mtype.P95Latency().Seconds(),
+				RequestSize: mtype.RequestBytes(),
+				ReplySize:   mtype.ReplyBytes(),
+			})
+		}
+		sort.Slice(stats.Methods, func(i, j int) bool { return stats.Methods[i].Name < stats.Methods[j].Name })
+		services = append(services, stats)
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(services)
+}
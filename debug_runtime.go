@@ -0,0 +1,179 @@
+package geerpc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// runtimeStats 是 <defaultDebugPath>/runtime 返回的运行时概况
+type runtimeStats struct {
+	GoVersion   string `json:"go_version"`
+	NumCPU      int    `json:"num_cpu"`
+	Goroutines  int    `json:"goroutines"`
+	Connections int64  `json:"connections"`
+	Accepted    uint64 `json:"connections_total"`
+	Inflight    int64  `json:"inflight"`
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapSys     uint64 `json:"heap_sys_bytes"`
+	NumGC       uint32 `json:"num_gc"`
+}
+
+// SetDebugPprof 设置 HandleDebugHTTP 是否挂载 pprof 性能分析接口，默认关闭，
+// 应在调用 HandleHTTP 或 HandleDebugHTTP 之前设置
+func (server *Server) SetDebugPprof(enable bool) {
+	server.pprof = enable
+}
+
+// SetDebugHTTP 设置 HandleDebugHTTP 是否挂载调试接口以及谁可以访问。调试页面会列出服务器的所有服务和方法，
+// 生产环境的监听器上通常应关闭（enable 为 false），或者通过 authorize 只允许运维人员访问（例如 BearerToken），
+// authorize 返回 false 的请求得到 401。默认开启且不做检查，应在调用 HandleHTTP 或 HandleDebugHTTP 之前设置
+func (server *Server) SetDebugHTTP(enable bool, authorize func(req *http.Request) bool) {
+	server.noDebug = !enable
+	server.debugAuth = authorize
+}
+
+// BearerToken 返回一个检查 "Authorization: Bearer <token>" 请求头的函数，可用于 SetDebugHTTP。
+// 请求头必须带有 "Bearer " 前缀，令牌以常量时间比较
+func BearerToken(token string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		auth := req.Header.Get("Authorization")
+		if token == "" || !strings.HasPrefix(auth, "Bearer ") {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) == 1
+	}
+}
+
+// guardDebug 为调试接口加上 SetDebugHTTP 设置的访问检查
+func (server *Server) guardDebug(h http.Handler) http.Handler {
+	authorize := server.debugAuth
+	if authorize == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !authorize(req) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="geerpc debug"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// HandleDebugHTTP 在 mux 上挂载调试接口，mux 为 nil 时使用 http.DefaultServeMux，SetDebugHTTP 关闭调试接口时不挂载任何接口：
+//   - defaultDebugPath：HTML 调试页面
+//   - defaultDebugPath + ".json"：JSON 格式的调试信息
+//   - defaultDebugPath + "/runtime"：协程数、连接数、内存等运行时概况
+//   - defaultDebugPath + "/connections"：每个连接的客户端地址、连接时长、请求数、收发字节数和最近活动时间
+//   - defaultDebugPath + "/pprof/"：pprof 性能分析接口，仅在 SetDebugPprof(true) 后挂载
+//
+// HandleHTTP 会在 http.DefaultServeMux 上调用它，因此使用 HandleHTTP 时无需再次调用，
+// 单独调用时通常传入监听在内部端口上的 mux，这样生产环境调试不需要再启动一个 HTTP 服务器
+func (server *Server) HandleDebugHTTP(mux *http.ServeMux) {
+	if server.noDebug {
+		return
+	}
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(defaultDebugPath, server.guardDebug(debugHTTP{server}))
+	mux.Handle(defaultDebugPath+".json", server.guardDebug(debugJSON{server}))
+	mux.Handle(defaultDebugPath+"/runtime", server.guardDebug(http.HandlerFunc(server.serveRuntime)))
+	mux.Handle(defaultDebugPath+"/connections", server.guardDebug(http.HandlerFunc(server.serveConnections)))
+	if server.pprof {
+		mux.Handle(defaultDebugPath+"/pprof/", server.guardDebug(http.HandlerFunc(servePprof)))
+	}
+	server.log().Info("rpc server debug path", "path", defaultDebugPath)
+}
+
+// serveRuntime 以 JSON 格式输出运行时概况
+func (server *Server) serveRuntime(w http.ResponseWriter, _ *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := runtimeStats{
+		GoVersion:   runtime.Version(),
+		NumCPU:      runtime.NumCPU(),
+		Goroutines:  runtime.NumGoroutine(),
+		Connections: atomic.LoadInt64(&server.connections),
+		Accepted:    atomic.LoadUint64(&server.accepted),
+		Inflight:    atomic.LoadInt64(&server.inflight),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapSys:     mem.HeapSys,
+		NumGC:       mem.NumGC,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// servePprof 提供与 net/http/pprof 兼容的性能分析接口。
+// 直接使用 runtime/pprof 实现，避免导入 net/http/pprof 时在 http.DefaultServeMux 上自动注册处理程序
+func servePprof(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, defaultDebugPath+"/pprof/")
+	seconds, _ := strconv.Atoi(req.FormValue("seconds"))
+	switch name {
+	case "":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = fmt.Fprintln(w, "profile?seconds=30")
+		_, _ = fmt.Fprintln(w, "trace?seconds=1")
+		for _, p := range pprof.Profiles() {
+			_, _ = fmt.Fprintf(w, "%s?debug=1\n", p.Name())
+		}
+	case "profile":
+		if seconds <= 0 {
+			seconds = 30
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, "could not enable CPU profiling: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(req, time.Duration(seconds)*time.Second)
+		pprof.StopCPUProfile()
+	case "trace":
+		if seconds <= 0 {
+			seconds = 1
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := trace.Start(w); err != nil {
+			http.Error(w, "could not enable tracing: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(req, time.Duration(seconds)*time.Second)
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			http.Error(w, "unknown profile: "+name, http.StatusNotFound)
+			return
+		}
+		debug, _ := strconv.Atoi(req.FormValue("debug"))
+		if name == "heap" && req.FormValue("gc") != "" {
+			runtime.GC()
+		}
+		if debug != 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		_ = p.WriteTo(w, debug)
+	}
+}
+
+// sleep 等待 d，请求被取消时提前返回
+func sleep(req *http.Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-req.Context().Done():
+	}
+}
@@ -0,0 +1,124 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	cases := []struct {
+		token, header string
+		allowed       bool
+	}{
+		{"secret", "Bearer secret", true},
+		{"secret", "secret", false}, // 缺少 "Bearer " 前缀
+		{"secret", "Bearer wrong", false},
+		{"secret", "Basic secret", false},
+		{"secret", "", false},
+		{"", "Bearer ", false}, // 空令牌不允许任何请求
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		if got := BearerToken(c.token)(req); got != c.allowed {
+			t.Fatalf("token %q, header %q: expect allowed=%v, got %v", c.token, c.header, c.allowed, got)
+		}
+	}
+}
+
+func TestServer_SetDebugHTTP(t *testing.T) {
+	server := NewServer()
+	server.SetDebugHTTP(false, nil)
+	mux := http.NewServeMux()
+	server.HandleDebugHTTP(mux)
+	if _, pattern := mux.Handler(httptest.NewRequest("GET", defaultDebugPath, nil)); pattern != "" {
+		t.Fatalf("expect no debug endpoints when disabled, got %s", pattern)
+	}
+
+	server = NewServer()
+	server.SetDebugHTTP(true, BearerToken("secret"))
+	mux = http.NewServeMux()
+	server.HandleDebugHTTP(mux)
+	for _, path := range []string{defaultDebugPath, defaultDebugPath + ".json", defaultDebugPath + "/runtime"} {
+		for header, code := range map[string]int{"": http.StatusUnauthorized, "secret": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("Authorization", header)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != code {
+				t.Fatalf("%s with %q: expect %d, got %d", path, header, code, w.Code)
+			}
+		}
+	}
+}
+
+// get 通过 mux 发出 GET 请求
+func get(mux http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestServer_HandleDebugHTTP(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	mux := http.NewServeMux()
+	server.HandleDebugHTTP(mux)
+
+	if body := get(mux, defaultDebugPath).Body.String(); !strings.Contains(body, "Service Foo") || !strings.Contains(body, "Sum(geerpc.Args, *int) error") {
+		t.Fatalf("expect the HTML page to list the services, got %s", body)
+	}
+	var stats runtimeStats
+	if err := json.NewDecoder(get(mux, defaultDebugPath+"/runtime").Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.GoVersion != runtime.Version() || stats.NumCPU <= 0 || stats.Goroutines <= 0 ||
+		stats.Connections != 1 || stats.Accepted != 1 || stats.HeapAlloc == 0 {
+		t.Fatalf("unexpected runtime stats %+v", stats)
+	}
+	// pprof 默认不挂载
+	if w := get(mux, defaultDebugPath+"/pprof/"); w.Code != http.StatusNotFound {
+		t.Fatalf("expect pprof to be off by default, got %d", w.Code)
+	}
+
+	server = NewServer()
+	server.SetDebugPprof(true)
+	mux = http.NewServeMux()
+	server.HandleDebugHTTP(mux)
+	if body := get(mux, defaultDebugPath+"/pprof/").Body.String(); !strings.Contains(body, "profile?seconds=30") || !strings.Contains(body, "goroutine?debug=1") {
+		t.Fatalf("expect the profile index, got %s", body)
+	}
+	w := get(mux, defaultDebugPath+"/pprof/goroutine?debug=1")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || !strings.Contains(w.Body.String(), "goroutine profile:") {
+		t.Fatalf("expect a text goroutine profile, got %s", w.Body.String())
+	}
+	if w := get(mux, defaultDebugPath+"/pprof/heap?gc=1"); w.Header().Get("Content-Type") != "application/octet-stream" || w.Body.Len() == 0 {
+		t.Fatalf("expect a binary heap profile, got %q", w.Header().Get("Content-Type"))
+	}
+	if w := get(mux, defaultDebugPath+"/pprof/nosuch"); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for an unknown profile, got %d", w.Code)
+	}
+	// 请求取消后 CPU 采样提前结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", defaultDebugPath+"/pprof/profile?seconds=30", nil).WithContext(ctx))
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Fatalf("expect a CPU profile, got %d", w.Code)
+	}
+}
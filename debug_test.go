@@ -0,0 +1,68 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugJSON(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_ = client.Call(context.Background(), "Flaky.Fail", 1, &reply)
+	// 请求的统计在响应发出之后才记录
+	scrape(t, server.MetricsHandler(), `geerpc_server_requests_total{method="Flaky.Fail"} 1`, `geerpc_server_requests_total{method="Foo.Sum"} 1`)
+
+	w := httptest.NewRecorder()
+	debugJSON{server}.ServeHTTP(w, httptest.NewRequest("GET", defaultDebugPath+".json", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expect application/json, got %q", ct)
+	}
+	var services []debugServiceStats
+	if err := json.NewDecoder(w.Body).Decode(&services); err != nil {
+		t.Fatal(err)
+	}
+	// 服务和方法都按名称排序
+	if len(services) != 2 || services[0].Name != "Flaky" || services[1].Name != "Foo" {
+		t.Fatalf("expect services sorted by name, got %+v", services)
+	}
+	fail := services[0].Methods[0]
+	if fail.Name != "Fail" || fail.ArgType != "int" || fail.ReplyType != "*int" || fail.Calls != 1 || fail.Errors != 1 {
+		t.Fatalf("unexpected stats for Flaky.Fail %+v", fail)
+	}
+	methods := services[1].Methods
+	for i := 1; i < len(methods); i++ {
+		if methods[i-1].Name > methods[i].Name {
+			t.Fatalf("expect methods sorted by name, got %+v", methods)
+		}
+	}
+	var sum *debugMethodStats
+	for i := range methods {
+		if methods[i].Name == "Sum" {
+			sum = &methods[i]
+		}
+	}
+	if sum == nil || sum.ArgType != "geerpc.Args" || sum.Calls != 1 || sum.Errors != 0 || sum.Inflight != 0 ||
+		sum.MeanLatency <= 0 || sum.RequestSize == 0 || sum.ReplySize == 0 {
+		t.Fatalf("unexpected stats for Foo.Sum %+v", sum)
+	}
+
+	// 没有注册服务时输出空数组而不是 null
+	w = httptest.NewRecorder()
+	debugJSON{NewServer()}.ServeHTTP(w, httptest.NewRequest("GET", defaultDebugPath+".json", nil))
+	if body := strings.TrimSpace(w.Body.String()); body != "[]" {
+		t.Fatalf("expect an empty array, got %q", body)
+	}
+}
@@ -0,0 +1,268 @@
+package geerpc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ErrBadCiphertext 表示收到的加密帧无法解密（密钥不匹配或数据被篡改），连接会被关闭
+var ErrBadCiphertext = errors.New("rpc: bad ciphertext")
+
+// Keyring 保存加密连接使用的 AES 密钥，每个密钥由一个 ID 标识，ID 随每个加密帧一起发送。
+// 接收方根据帧中的 ID 选择解密密钥，因此可以同时持有多个有效的密钥，轮换步骤为：
+//  1. 在所有服务端和客户端上 Add 新密钥；
+//  2. 在客户端上 SetPrimary 新密钥，之后写入的帧（包括已有连接）使用新密钥加密，服务端按请求使用的密钥回复；
+//  3. 所有客户端切换完成后，Remove 旧密钥。
+//
+// Keyring 可以被多个连接共享，所有方法都是并发安全的
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+// NewKeyring 创建一个空的 Keyring
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]cipher.AEAD)}
+}
+
+// Add 添加或替换一个密钥，key 的长度必须为 16、24 或 32 字节（AES-128、AES-192 或 AES-256）。
+// 第一个添加的密钥会成为主密钥
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return errors.New("rpc: invalid encryption key id")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = aead
+	if k.primary == "" {
+		k.primary = id
+	}
+	return nil
+}
+
+// SetPrimary 设置用于加密写入数据的主密钥
+func (k *Keyring) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return errors.New("rpc: unknown encryption key " + id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Remove 删除一个密钥，之后使用该密钥加密的帧将无法解密。主密钥不能被删除
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.primary {
+		return errors.New("rpc: can't remove the primary encryption key")
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// IDs 返回所有密钥的 ID，按字典序排列
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Primary 返回主密钥的 ID，没有密钥时返回空字符串
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// lookup 返回 id 对应的密钥，不存在时返回 nil
+func (k *Keyring) lookup(id string) cipher.AEAD {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[id]
+}
+
+// SetEncryption 设置服务器解密连接数据使用的 Keyring。客户端通过 Option.Encryption 启用加密后，
+// 双方写入的每一段数据都使用 AES-GCM 加密，帧中带有密钥 ID 和递增的序号，篡改、删除或重排数据都会导致连接被关闭。
+// 服务端使用请求帧中的密钥回复，因此只需持有所有有效的密钥，轮换时无需与客户端同时切换。
+// require 为 true 时拒绝没有启用加密的连接。适用于无法使用完整 TLS 的传输，应在开始服务之前调用
+func (server *Server) SetEncryption(keys *Keyring, require bool) {
+	server.encryptKeys = keys
+	server.requireEncrypt = require
+}
+
+// checkEncryption 检查握手选项中的加密设置是否满足服务器的要求
+func (server *Server) checkEncryption(opt *Option) error {
+	if !opt.Encrypted {
+		if server.requireEncrypt {
+			return errors.New("rpc server: encryption required")
+		}
+		return nil
+	}
+	if server.encryptKeys == nil {
+		return errors.New("rpc server: encryption not supported")
+	}
+	return nil
+}
+
+// encryptedConn 加密连接上的数据。每次写入被编码为一个帧：
+// 4 字节帧长度、1 字节密钥 ID 长度、密钥 ID、12 字节随机数、密文，
+// 附加数据覆盖方向标记、8 字节帧序号和密钥 ID
+type encryptedConn struct {
+	io.ReadWriteCloser
+	keys       *Keyring
+	reply      bool // 为 true 时使用最近读取的帧的密钥写入（服务端），否则使用主密钥（客户端）
+	readDir    byte // 读取的帧的方向
+	writeDir   byte // 写入的帧的方向
+	wmu        sync.Mutex
+	wseq, rseq uint64
+	idMu       sync.Mutex // 保护 lastID
+	lastID     string     // 最近读取的帧使用的密钥 ID
+	pending    []byte     // 当前帧中尚未被读取的明文
+	hdr        [4]byte
+	readBuf    []byte
+}
+
+// newEncryptedConn 创建一个 encryptedConn，dir 是本端写入的方向，服务端使用 signServer
+func newEncryptedConn(conn io.ReadWriteCloser, keys *Keyring, dir byte) *encryptedConn {
+	c := &encryptedConn{ReadWriteCloser: conn, keys: keys, writeDir: dir, readDir: signServer}
+	if dir == signServer {
+		c.readDir = signClient
+		c.reply = true
+	}
+	return c
+}
+
+// additionalData 返回一个帧的附加认证数据
+func additionalData(dir byte, seq uint64, id string) []byte {
+	ad := make([]byte, 9+len(id))
+	ad[0] = dir
+	binary.BigEndian.PutUint64(ad[1:], seq)
+	copy(ad[9:], id)
+	return ad
+}
+
+// writeKey 返回写入时使用的密钥
+func (c *encryptedConn) writeKey() (string, cipher.AEAD, error) {
+	var id string
+	if c.reply {
+		c.idMu.Lock()
+		id = c.lastID
+		c.idMu.Unlock()
+	}
+	if id == "" {
+		id = c.keys.Primary()
+	}
+	aead := c.keys.lookup(id)
+	if aead == nil {
+		return "", nil, errors.New("rpc: unknown encryption key " + id)
+	}
+	return id, aead, nil
+}
+
+func (c *encryptedConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	id, aead, err := c.writeKey()
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxSignedFrame {
+			chunk = chunk[:maxSignedFrame]
+		}
+		head := 4 + 1 + len(id) + aead.NonceSize()
+		frame := make([]byte, head, head+len(chunk)+aead.Overhead())
+		binary.BigEndian.PutUint32(frame, uint32(cap(frame)-4))
+		frame[4] = byte(len(id))
+		copy(frame[5:], id)
+		nonce := frame[5+len(id) : head]
+		if _, err := rand.Read(nonce); err != nil {
+			return written, err
+		}
+		frame = aead.Seal(frame, nonce, chunk, additionalData(c.writeDir, c.wseq, id))
+		c.wseq++
+		if _, err := c.ReadWriteCloser.Write(frame); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *encryptedConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if err := c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readFrame 读取并解密下一个帧
+func (c *encryptedConn) readFrame() error {
+	if _, err := io.ReadFull(c.ReadWriteCloser, c.hdr[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(c.hdr[:])
+	if n < 1 || n > maxSignedFrame+1+255+64 {
+		return ErrBadCiphertext
+	}
+	if cap(c.readBuf) < int(n) {
+		c.readBuf = make([]byte, n)
+	}
+	frame := c.readBuf[:n]
+	if _, err := io.ReadFull(c.ReadWriteCloser, frame); err != nil {
+		return err
+	}
+	idLen := int(frame[0])
+	if len(frame) < 1+idLen {
+		return ErrBadCiphertext
+	}
+	id := string(frame[1 : 1+idLen])
+	aead := c.keys.lookup(id)
+	if aead == nil {
+		return errors.New("rpc: unknown encryption key " + id)
+	}
+	rest := frame[1+idLen:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return ErrBadCiphertext
+	}
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(sealed[:0], nonce, sealed, additionalData(c.readDir, c.rseq, id))
+	if err != nil {
+		return ErrBadCiphertext
+	}
+	c.rseq++
+	c.idMu.Lock()
+	c.lastID = id
+	c.idMu.Unlock()
+	c.pending = plain
+	return nil
+}
@@ -0,0 +1,123 @@
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// newTestKeyring 创建包含给定密钥 ID 的 Keyring，密钥由 ID 重复得到
+func newTestKeyring(t *testing.T, ids ...string) *Keyring {
+	k := NewKeyring()
+	for _, id := range ids {
+		if err := k.Add(id, bytes.Repeat([]byte(id[:1]), 32)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k
+}
+
+func TestEncryptedConn(t *testing.T) {
+	keys := newTestKeyring(t, "k1")
+	var frames [][]byte
+	buf := &bufferConn{}
+	w := newEncryptedConn(buf, keys, signClient)
+	for _, p := range []string{"hello", "world"} {
+		_, _ = w.Write([]byte(p))
+		frames = append(frames, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	read := func(keys *Keyring, frames ...[]byte) ([]byte, error) {
+		buf := &bufferConn{}
+		for _, f := range frames {
+			buf.Write(f)
+		}
+		return ioutil.ReadAll(newEncryptedConn(buf, keys, signServer))
+	}
+	if data, err := read(keys, frames...); err != nil || string(data) != "helloworld" {
+		t.Fatalf("expect helloworld, got %q, err %v", data, err)
+	}
+	if bytes.Contains(frames[0], []byte("hello")) {
+		t.Fatal("expect the frame not to contain the plaintext")
+	}
+
+	tampered := append([]byte(nil), frames[0]...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := read(keys, tampered, frames[1]); err != ErrBadCiphertext {
+		t.Fatalf("expect ErrBadCiphertext for a tampered frame, got %v", err)
+	}
+	if _, err := read(keys, frames[1], frames[0]); err != ErrBadCiphertext {
+		t.Fatalf("expect ErrBadCiphertext for reordered frames, got %v", err)
+	}
+	// 同名但内容不同的密钥无法解密
+	other := NewKeyring()
+	_ = other.Add("k1", bytes.Repeat([]byte{'x'}, 32))
+	if _, err := read(other, frames...); err != ErrBadCiphertext {
+		t.Fatalf("expect ErrBadCiphertext for a different key, got %v", err)
+	}
+	if _, err := read(newTestKeyring(t, "k2"), frames...); err == nil {
+		t.Fatal("expect an error for an unknown key id")
+	}
+}
+
+func TestServer_EncryptionKeyRotation(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	serverKeys := newTestKeyring(t, "k1")
+	server.SetEncryption(serverKeys, true)
+
+	clientKeys := newTestKeyring(t, "k1")
+	client, err := DialInProc(server, &Option{Encryption: clientKeys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	call := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var reply string
+		return client.Call(ctx, "Bar.RequestID", 1, &reply)
+	}
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换：双方添加新密钥，客户端切换主密钥，最后删除旧密钥，已有的连接不受影响
+	_ = serverKeys.Add("k2", bytes.Repeat([]byte{'k'}, 32))
+	_ = clientKeys.Add("k2", bytes.Repeat([]byte{'k'}, 32))
+	if err := clientKeys.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(); err != nil {
+		t.Fatalf("expect calls to continue after switching the primary key, got %v", err)
+	}
+	if err := clientKeys.Remove("k2"); err == nil {
+		t.Fatal("expect the primary key not to be removable")
+	}
+	_ = clientKeys.Remove("k1")
+	_ = serverKeys.SetPrimary("k2")
+	if err := serverKeys.Remove("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(); err != nil {
+		t.Fatalf("expect calls to continue after removing the old key, got %v", err)
+	}
+	if ids := serverKeys.IDs(); len(ids) != 1 || ids[0] != "k2" {
+		t.Fatalf("expect only k2 left, got %v", ids)
+	}
+
+	// 只持有已删除密钥的客户端被拒绝
+	stale, err := DialInProc(server, &Option{Encryption: newTestKeyring(t, "k1"), ConnectTimeout: time.Second})
+	if err == nil {
+		defer stale.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var reply string
+		if err := stale.Call(ctx, "Bar.RequestID", 1, &reply); err == nil {
+			t.Fatal("expect a client using a removed key to be rejected")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package geerpc
+
+import (
+	"sync"
+	"time"
+)
+
+// Event 是框架内部发布到 EventBus 上的事件，订阅者可以通过类型断言区分具体的事件
+type Event interface {
+	EventName() string
+}
+
+// ConnAccepted 在服务器开始为一个新连接服务时发布
+type ConnAccepted struct {
+	Time   time.Time
+	Remote string
+}
+
+// ConnRejected 在服务器读取握手数据之前拒绝一个连接时发布，例如客户端地址不在允许的范围内
+type ConnRejected struct {
+	Time   time.Time
+	Remote string
+	Reason string
+}
+
+// HandshakeFailed 在服务器解析客户端选项失败时发布，例如幻数或编解码器类型不正确
+type HandshakeFailed struct {
+	Time   time.Time
+	Remote string
+	Err    error
+}
+
+// SourceLockedOut 在一个来源因认证失败次数过多而被暂时锁定时发布，参见 SetAuthLockout
+type SourceLockedOut struct {
+	Time     time.Time
+	Remote   string // 来源 IP
+	Failures int
+	Until    time.Time // 锁定的截止时间
+}
+
+// RequestRejectedRateLimit 在服务器因连接的请求速率超过限制，或调用方身份超出配额（参见 SetQuotas）而拒绝请求时发布
+type RequestRejectedRateLimit struct {
+	Time     time.Time
+	Remote   string
+	Identity string // 超出配额的调用方身份，连接级限流时为空
+}
+
+// CallTimedOut 在服务端处理超时或客户端等待超时（context 被取消）时发布
+type CallTimedOut struct {
+	Time          time.Time
+	Side          string // "server" 或 "client"
+	ServiceMethod string
+	RequestID     string
+	Remote        string // 对端地址
+	Err           string
+}
+
+// BackendEjected 在 XClient 剔除一个不可用的后端时发布
+type BackendEjected struct {
+	Time   time.Time
+	Addr   string
+	Reason string // "unavailable" 表示缓存的连接已断开，"circuit-open" 表示熔断器打开
+}
+
+func (ConnAccepted) EventName() string             { return "ConnAccepted" }
+func (ConnRejected) EventName() string             { return "ConnRejected" }
+func (HandshakeFailed) EventName() string          { return "HandshakeFailed" }
+func (SourceLockedOut) EventName() string          { return "SourceLockedOut" }
+func (RequestRejectedRateLimit) EventName() string { return "RequestRejectedRateLimit" }
+func (CallTimedOut) EventName() string             { return "CallTimedOut" }
+func (BackendEjected) EventName() string           { return "BackendEjected" }
+
+// EventBus 是进程内的事件总线，运维人员可以订阅框架事件并实现自定义的响应（例如告警、自动排空），
+// 而无需修改框架内部。事件在发布者的协程中同步分发，订阅者应尽快返回，耗时的处理应自行异步进行
+type EventBus struct {
+	mu     sync.RWMutex // 保护以下字段
+	subs   map[int]func(Event)
+	nextID int
+}
+
+// NewEventBus 创建一个 EventBus 实例
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]func(Event))}
+}
+
+// Subscribe 订阅所有事件，返回的 cancel 函数用于取消订阅
+func (b *EventBus) Subscribe(fn func(Event)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish 将事件分发给所有订阅者，b 为 nil 时忽略
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// SetEventBus 设置服务器发布事件使用的 EventBus，为 nil（默认）时不发布事件，应在开始服务之前调用
+func (server *Server) SetEventBus(b *EventBus) {
+	server.events = b
+}
@@ -0,0 +1,124 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder 记录 EventBus 上发布的事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// wait 等待一个名为 name 的事件
+func (r *eventRecorder) wait(t *testing.T, name string) Event {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		r.mu.Lock()
+		for _, e := range r.events {
+			if e.EventName() == name {
+				r.mu.Unlock()
+				return e
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect a %s event", name)
+	return nil
+}
+
+func TestEventBus(t *testing.T) {
+	var bus *EventBus
+	bus.Publish(ConnAccepted{}) // nil 的 EventBus 忽略事件
+
+	bus = NewEventBus()
+	var a, b eventRecorder
+	cancelA := bus.Subscribe(a.record)
+	bus.Subscribe(b.record)
+	bus.Publish(ConnAccepted{Remote: "r1"})
+	cancelA()
+	cancelA() // 重复取消没有影响
+	bus.Publish(ConnRejected{Remote: "r2"})
+	if len(a.events) != 1 || a.events[0].(ConnAccepted).Remote != "r1" {
+		t.Fatalf("expect the cancelled subscriber to miss later events, got %+v", a.events)
+	}
+	if len(b.events) != 2 || b.events[1].EventName() != "ConnRejected" {
+		t.Fatalf("expect every event, got %+v", b.events)
+	}
+}
+
+func TestServer_SetEventBus(t *testing.T) {
+	var events eventRecorder
+	bus := NewEventBus()
+	bus.Subscribe(events.record)
+	server := NewServer()
+	server.SetEventBus(bus)
+	server.SetHandleTimeout(20 * time.Millisecond)
+	var s Sleepy
+	_ = server.Register(&s)
+
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	if e := events.wait(t, "ConnAccepted").(ConnAccepted); e.Remote != "inproc" || e.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	var reply []byte
+	if err := client.Call(WithRequestID(context.Background(), "req-1"), "Sleepy.Sleep", 100, &reply); err == nil {
+		t.Fatal("expect the call to time out on the server")
+	}
+	e := events.wait(t, "CallTimedOut").(CallTimedOut)
+	if e.Side != "server" || e.ServiceMethod != "Sleepy.Sleep" || e.RequestID != "req-1" || !strings.Contains(e.Err, "handle timeout") {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	// 幻数不正确的握手
+	s1, c1 := net.Pipe()
+	go server.ServeConn(s1)
+	_ = json.NewEncoder(c1).Encode(&Option{MagicNumber: 1})
+	_ = c1.Close()
+	if e := events.wait(t, "HandshakeFailed").(HandshakeFailed); !strings.Contains(e.Err.Error(), "invalid magic number") {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+func TestClient_EventBus(t *testing.T) {
+	var events eventRecorder
+	bus := NewEventBus()
+	bus.Subscribe(events.record)
+	server := NewServer()
+	var s Sleepy
+	_ = server.Register(&s)
+	client, err := DialInProc(server, &Option{Events: bus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(WithRequestID(context.Background(), "req-2"), 20*time.Millisecond)
+	defer cancel()
+	var reply []byte
+	if err := client.Call(ctx, "Sleepy.Sleep", 100, &reply); err == nil {
+		t.Fatal("expect the call to time out on the client")
+	}
+	e := events.wait(t, "CallTimedOut").(CallTimedOut)
+	if e.Side != "client" || e.ServiceMethod != "Sleepy.Sleep" || e.RequestID != "req-2" || e.Remote != "inproc" ||
+		!strings.Contains(e.Err, "deadline exceeded") {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
@@ -0,0 +1,224 @@
+package geerpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// errEventedUnsupported 表示当前平台没有 epoll 或 kqueue
+var errEventedUnsupported = errors.New("rpc server: event-driven connections are not supported on this platform")
+
+// AcceptEvented 与 Accept 相同，但使用 epoll（Linux）或 kqueue（BSD、macOS）等待空闲连接上的请求：
+// 连接空闲时不占用协程，有数据可读时才启动一个协程读取并分派请求，直到缓冲区中没有未处理的数据。
+// 适用于有大量（例如 10 万以上）大部分时间空闲的连接、每个连接一个协程的内存开销成为瓶颈的部署。
+//
+// 只有在 TCP 或 Unix 连接上使用 gob、JSON 编解码器的普通连接会交给事件循环；TLS、签名和加密的连接
+// 在内部缓冲解码后的数据，JSON-RPC 连接没有可以暂停的读取循环，它们仍然由协程服务。
+// 握手期间和请求处理期间仍然使用协程，因此该模式只减少空闲连接的开销。
+// 当前平台不支持时返回错误，监听器被关闭时返回 nil
+func (server *Server) AcceptEvented(lis net.Listener) error {
+	p, err := newPoller(server)
+	if err != nil {
+		return err
+	}
+	go p.loop()
+	defer p.stop()
+	server.acceptLoop(lis, func(conn net.Conn) {
+		pc, err := p.newConn(conn)
+		if err != nil {
+			server.ServeConn(conn)
+			return
+		}
+		server.serveConn(pc, pc)
+	})
+	return nil
+}
+
+// AcceptEvented 使用 DefaultServer 以事件驱动的方式接受监听器上的连接
+func AcceptEvented(lis net.Listener) error { return DefaultServer.AcceptEvented(lis) }
+
+// poller 是一个 epoll 或 kqueue 实例以及在其中等待的连接
+type poller struct {
+	server *Server
+	fd     int
+
+	mu       sync.Mutex // 保护以下部分
+	conns    map[int]*pollConn
+	active   int  // 通过 newConn 创建且尚未关闭的连接数
+	stopping bool // 监听器已关闭，所有连接关闭后释放 fd
+}
+
+func newPoller(server *Server) (*poller, error) {
+	fd, err := pollCreate()
+	if err != nil {
+		return nil, err
+	}
+	return &poller{server: server, fd: fd, conns: make(map[int]*pollConn)}, nil
+}
+
+// loop 等待连接可读，为每个可读的连接启动一个协程，直到 stop 之后所有连接都已关闭
+func (p *poller) loop() {
+	fds := make([]int, 128)
+	ready := make([]*pollConn, 0, len(fds))
+	for {
+		n, err := pollWait(p.fd, fds)
+		if err != nil && err != syscall.EINTR {
+			p.server.log().Error("rpc server: poll error", "err", err)
+		}
+		ready = ready[:0]
+		p.mu.Lock()
+		for _, fd := range fds[:n] {
+			if pc := p.conns[fd]; pc != nil {
+				ready = append(ready, pc)
+			}
+		}
+		done := p.stopping && p.active == 0
+		p.mu.Unlock()
+		for _, pc := range ready {
+			if pc.wake() {
+				go pc.serve()
+			}
+		}
+		if done {
+			_ = pollClose(p.fd)
+			return
+		}
+	}
+}
+
+// stop 在监听器关闭后调用，已有的连接继续服务直到关闭
+func (p *poller) stop() {
+	p.mu.Lock()
+	p.stopping = true
+	p.mu.Unlock()
+}
+
+// newConn 包装 conn 以便之后登记到事件循环，conn 没有可以等待的文件描述符（例如 TLS 连接）时返回错误
+func (p *poller) newConn(conn net.Conn) (*pollConn, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, errEventedUnsupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	pc := &pollConn{Conn: conn, p: p, fd: -1, serving: true}
+	if err := rc.Control(func(fd uintptr) { pc.fd = int(fd) }); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.active++
+	p.mu.Unlock()
+	return pc, nil
+}
+
+// pollConn 是交给事件循环的连接。每次可读时只触发一次（one-shot），服务完缓冲区中的请求后重新登记，
+// 保证同一时刻最多只有一个协程读取连接。握手期间由握手的协程负责，握手之后没有交给事件循环的连接与普通连接相同
+type pollConn struct {
+	net.Conn
+	p        *poller
+	fd       int
+	cc       *codecConn
+	buffered func() int // 已经读取但尚未处理的字节数
+	done     func()     // 连接处理结束后关闭连接并取消登记
+
+	mu         sync.Mutex // 保护以下部分
+	registered bool       // 已经添加到事件循环
+	serving    bool       // 有协程正在读取连接，它负责发现连接关闭并清理
+	removed    bool       // 已经从事件循环中移除，之后不能再登记（文件描述符可能被新连接复用）
+	finish     sync.Once
+}
+
+// start 在握手完成后调用，先处理握手时已经读取的请求，然后登记到事件循环
+func (pc *pollConn) start(cc *codecConn, buffered func() int, done func()) {
+	pc.cc, pc.buffered, pc.done = cc, buffered, done
+	pc.serve()
+}
+
+// wake 在连接可读时由事件循环调用，连接已经关闭时返回 false
+func (pc *pollConn) wake() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.removed {
+		return false
+	}
+	pc.serving = true
+	return true
+}
+
+// serve 读取并分派请求，直到缓冲区中没有未处理的数据，然后重新登记到事件循环
+func (pc *pollConn) serve() {
+	for {
+		if !pc.cc.next() {
+			pc.close()
+			return
+		}
+		if pc.buffered() == 0 {
+			break
+		}
+	}
+	if err := pc.arm(); err != nil {
+		pc.close()
+	}
+}
+
+// arm 登记或重新登记连接，等待下一次可读。登记之后连接可能随时被事件循环唤醒，调用方不能再访问连接
+func (pc *pollConn) arm() error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.removed {
+		return errShuttingDown
+	}
+	pc.serving = false
+	p := pc.p
+	if pc.registered {
+		return pollRearm(p.fd, pc.fd)
+	}
+	pc.registered = true
+	p.mu.Lock()
+	p.conns[pc.fd] = pc
+	p.mu.Unlock()
+	return pollAdd(p.fd, pc.fd)
+}
+
+// remove 将连接从事件循环中移除，必须在关闭文件描述符之前调用。
+// 返回连接是否正在事件循环中等待，此时没有协程会发现连接已关闭
+func (pc *pollConn) remove() (parked bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.removed {
+		return false
+	}
+	pc.removed = true
+	p := pc.p
+	if pc.registered {
+		_ = pollRemove(p.fd, pc.fd)
+	}
+	p.mu.Lock()
+	delete(p.conns, pc.fd)
+	p.active--
+	p.mu.Unlock()
+	return pc.registered && !pc.serving
+}
+
+// close 等待连接上的请求处理完成后关闭连接，只执行一次
+func (pc *pollConn) close() {
+	pc.finish.Do(func() {
+		pc.remove()
+		pc.cc.close()
+		pc.done()
+	})
+}
+
+// Close 关闭连接。连接正在事件循环中等待时在新的协程中完成清理（调用方可能持有 Shutdown 遍历连接时的锁）
+func (pc *pollConn) Close() error {
+	parked := pc.remove()
+	err := pc.Conn.Close()
+	if parked {
+		go pc.close()
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package geerpc
+
+import "syscall"
+
+// epoll 的实现。每个连接使用 EPOLLONESHOT 登记，触发一次后需要 pollRearm 重新登记
+
+const pollEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+
+func pollCreate() (int, error) {
+	return syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+}
+
+func pollAdd(pfd, fd int) error {
+	return syscall.EpollCtl(pfd, syscall.EPOLL_CTL_ADD, fd, &syscall.EpollEvent{Events: pollEvents, Fd: int32(fd)})
+}
+
+func pollRearm(pfd, fd int) error {
+	return syscall.EpollCtl(pfd, syscall.EPOLL_CTL_MOD, fd, &syscall.EpollEvent{Events: pollEvents, Fd: int32(fd)})
+}
+
+func pollRemove(pfd, fd int) error {
+	return syscall.EpollCtl(pfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+// pollWait 等待最多一秒，将可读的连接的文件描述符写入 fds，返回其个数
+func pollWait(pfd int, fds []int) (int, error) {
+	events := make([]syscall.EpollEvent, len(fds))
+	n, err := syscall.EpollWait(pfd, events, 1000)
+	if n < 0 {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		fds[i] = int(events[i].Fd)
+	}
+	return n, err
+}
+
+func pollClose(pfd int) error {
+	return syscall.Close(pfd)
+}
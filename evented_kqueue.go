@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package geerpc
+
+import "syscall"
+
+// kqueue 的实现。每个连接使用 EV_ONESHOT 登记，触发一次后需要 pollRearm 重新登记
+
+func pollCreate() (int, error) {
+	fd, err := syscall.Kqueue()
+	if err == nil {
+		syscall.CloseOnExec(fd)
+	}
+	return fd, err
+}
+
+func pollChange(pfd, fd int, flags int) error {
+	var ev syscall.Kevent_t
+	syscall.SetKevent(&ev, fd, syscall.EVFILT_READ, flags)
+	_, err := syscall.Kevent(pfd, []syscall.Kevent_t{ev}, nil, nil)
+	return err
+}
+
+func pollAdd(pfd, fd int) error {
+	return pollChange(pfd, fd, syscall.EV_ADD|syscall.EV_ONESHOT)
+}
+
+func pollRearm(pfd, fd int) error {
+	return pollChange(pfd, fd, syscall.EV_ADD|syscall.EV_ONESHOT)
+}
+
+func pollRemove(pfd, fd int) error {
+	return pollChange(pfd, fd, syscall.EV_DELETE)
+}
+
+// pollWait 等待最多一秒，将可读的连接的文件描述符写入 fds，返回其个数
+func pollWait(pfd int, fds []int) (int, error) {
+	events := make([]syscall.Kevent_t, len(fds))
+	timeout := syscall.Timespec{Sec: 1}
+	n, err := syscall.Kevent(pfd, nil, events, &timeout)
+	if n < 0 {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		fds[i] = int(events[i].Ident)
+	}
+	return n, err
+}
+
+func pollClose(pfd int) error {
+	return syscall.Close(pfd)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package geerpc
+
+// 没有 epoll 和 kqueue 的平台上 AcceptEvented 返回 errEventedUnsupported
+
+func pollCreate() (int, error)                 { return -1, errEventedUnsupported }
+func pollAdd(pfd, fd int) error                { return errEventedUnsupported }
+func pollRearm(pfd, fd int) error              { return errEventedUnsupported }
+func pollRemove(pfd, fd int) error             { return errEventedUnsupported }
+func pollWait(pfd int, fds []int) (int, error) { return 0, errEventedUnsupported }
+func pollClose(pfd int) error                  { return errEventedUnsupported }
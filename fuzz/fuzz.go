@@ -0,0 +1,139 @@
+// Package fuzz 将任意字节送入服务端的连接处理和编解码器，用于模糊测试握手、消息头和消息体的解析。
+// 本包的 Fuzz* 测试基于 Go 原生的模糊测试（go test -fuzz），也可以在其他模糊测试工具中调用
+// ServeConn、ServeNetRPCConn 和 Codec，以 Handshake 和 Requests 生成的合法请求作为初始语料：
+//
+//	go test geerpc/fuzz -run '^$' -fuzz FuzzServeConn -fuzztime 1m
+//
+// 输入可能导致服务端崩溃、死锁，或者很短的输入在解码时展开为远超 Limits 的内存分配，这些都应视为缺陷
+package fuzz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"geerpc/codec"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Limits 是 NewServer 和模糊测试使用的解码限制，远小于默认值，使内存分配的异常更容易被发现
+var Limits = codec.Limits{MaxMessageSize: 1 << 20, MaxLength: 1 << 16, MaxDepth: 32}
+
+// Args 是 Target 服务的参数，包含切片、映射、指针和嵌套，覆盖 gob 解码的主要路径
+type Args struct {
+	Name   string
+	Values []int
+	Tags   map[string]string
+	Data   []byte
+	Child  *Args
+	At     time.Time
+}
+
+// Target 是 NewServer 注册的服务
+type Target struct{}
+
+// Echo 原样返回参数
+func (Target) Echo(args Args, reply *Args) error {
+	*reply = args
+	return nil
+}
+
+// Sum 返回所有参数之和
+func (Target) Sum(args []int, reply *int) error {
+	for _, v := range args {
+		*reply += v
+	}
+	return nil
+}
+
+// NewServer 返回一个用于模糊测试的服务器：注册了 Target 服务，开启 JSON-RPC 和反射服务，
+// 解码限制为 Limits，不限制连接的请求速率，丢弃所有日志
+func NewServer() *geerpc.Server {
+	server := geerpc.NewServer()
+	server.SetLogger(nopLogger{})
+	server.SetConnRateLimit(0, 0)
+	server.SetDecodeLimits(Limits)
+	server.SetJSONRPC(true)
+	server.SetReflection(true)
+	_ = server.Register(Target{})
+	return server
+}
+
+// ServeConn 将 data 作为客户端发送的全部数据交给 server.ServeConn，丢弃服务端的响应，在服务端关闭连接后返回
+func ServeConn(server *geerpc.Server, data []byte) {
+	server.ServeConn(newConn(data))
+}
+
+// ServeNetRPCConn 与 ServeConn 相同，但使用 server.ServeNetRPCConn，即标准库 net/rpc 的协议
+func ServeNetRPCConn(server *geerpc.Server, data []byte) {
+	server.ServeNetRPCConn(newConn(data))
+}
+
+// Codec 使用 typ 对应的编解码器交替读取 data 中的消息头和消息体，消息体解码为 Args，直到出错。
+// 输入耗尽时返回 nil，其他错误原样返回
+func Codec(typ codec.Type, data []byte, l codec.Limits) error {
+	f := codec.NewCodecFuncMap[typ]
+	if f == nil {
+		return errors.New("fuzz: unknown codec type " + string(typ))
+	}
+	cc := f(newConn(data))
+	if lc, ok := cc.(codec.Limiter); ok {
+		lc.SetLimits(l)
+	}
+	for {
+		var h codec.Header
+		if err := cc.ReadHeader(&h); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var body Args
+		if err := cc.ReadBody(&body); err != nil {
+			return err
+		}
+	}
+}
+
+// Handshake 返回客户端以 opt 连接时发送的选项握手，opt 为 nil 时使用 geerpc.DefaultOption
+func Handshake(opt *geerpc.Option) []byte {
+	if opt == nil {
+		opt = geerpc.DefaultOption
+	}
+	data, _ := json.Marshal(opt)
+	return append(data, '\n')
+}
+
+// Requests 返回调用 serviceMethod 的 gob 编码的请求，args 中的每个值生成一个请求，序号从 1 开始。
+// 与 Handshake 拼接后是 ServeConn 的合法输入，单独使用时是 ServeNetRPCConn 和 Codec 的合法输入
+func Requests(serviceMethod string, args ...interface{}) []byte {
+	var buf bytes.Buffer
+	cc := codec.NewGobCodec(&rwc{Reader: &bytes.Buffer{}, Writer: &buf})
+	for i, a := range args {
+		_ = cc.Write(&codec.Header{ServiceMethod: serviceMethod, Seq: uint64(i + 1)}, a)
+	}
+	return buf.Bytes()
+}
+
+// rwc 由独立的 Reader 和 Writer 组成，Close 不做任何事
+type rwc struct {
+	io.Reader
+	io.Writer
+}
+
+func (rwc) Close() error { return nil }
+
+// newConn 返回读取 data 并丢弃写入数据的连接
+func newConn(data []byte) io.ReadWriteCloser {
+	return &rwc{Reader: bytes.NewReader(data), Writer: ioutil.Discard}
+}
+
+// nopLogger 丢弃所有日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
@@ -0,0 +1,49 @@
+//go:build go1.18
+// +build go1.18
+
+package fuzz
+
+import (
+	"geerpc"
+	"geerpc/codec"
+	"testing"
+	"time"
+)
+
+var seedArgs = []interface{}{
+	Args{},
+	Args{Name: "geerpc", Values: []int{1, 2, 3}, Tags: map[string]string{"k": "v"}, Data: []byte("data"),
+		Child: &Args{Name: "child"}, At: time.Unix(1700000000, 0).UTC()},
+}
+
+func FuzzServeConn(f *testing.F) {
+	f.Add(append(Handshake(nil), Requests("Target.Echo", seedArgs...)...))
+	f.Add(append(Handshake(nil), Requests("Target.Sum", []int{1, 2})...))
+	f.Add(append(Handshake(nil), Requests("Reflection.List", "")...))
+	f.Add(append(Handshake(&geerpc.Option{MagicNumber: geerpc.MagicNumber, CodecType: codec.GobType, Credentials: "token"}),
+		Requests("Missing.Method", 1)...))
+	f.Add([]byte(`{"jsonrpc":"2.0","id":1,"method":"Target.Echo","params":[{"Name":"a","Values":[1]}]}`))
+	f.Add([]byte(`[{"jsonrpc":"2.0","id":1,"method":"Target.Sum","params":[1,2]},{"jsonrpc":"2.0","method":"Health.Check","params":""}]`))
+	f.Add([]byte(`{"MagicNumber":3927900}`))
+	server := NewServer()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ServeConn(server, data)
+	})
+}
+
+func FuzzServeNetRPCConn(f *testing.F) {
+	f.Add(Requests("Target.Echo", seedArgs...))
+	f.Add(Requests("Target.Sum", []int{1, 2}, []int{}))
+	server := NewServer()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ServeNetRPCConn(server, data)
+	})
+}
+
+func FuzzGobCodec(f *testing.F) {
+	f.Add(Requests("Target.Echo", seedArgs...))
+	f.Add(Requests("Target.Echo", Args{Values: make([]int, 100)}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = Codec(codec.GobType, data, Limits)
+	})
+}
@@ -0,0 +1,270 @@
+// Package geerpctest 提供用于测试 geerpc 调用方的内存模拟服务器。
+// 每个 "Service.Method" 的响应可以单独设置为固定的返回值、错误或延迟，测试结束后可以检查收到的调用，
+// 调用方的单元测试因此不需要真实的监听器和端口，也不需要实现服务本身：
+//
+//	srv := geerpctest.NewServer()
+//	defer srv.Close()
+//	srv.Stub("Arith.Add", geerpctest.Stub{Reply: 3, Args: Args{}})
+//	client, _ := srv.Dial(nil)
+//	// ... 调用被测代码
+//	srv.AssertCalledWith(t, "Arith.Add", Args{Num1: 1, Num2: 2})
+package geerpctest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geerpc"
+	"geerpc/codec"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Stub 是一个方法的模拟响应
+type Stub struct {
+	Reply interface{}   // 返回给调用方的值，类型需要能够被调用方的 reply 解码，Err 为空时必须设置
+	Err   string        // 不为空时调用返回该错误
+	Delay time.Duration // 响应前等待的时间，可用于测试调用方的超时处理
+
+	// Args 不为 nil 时按它的类型解码请求参数并记录在 Call.Args 中，否则请求参数被丢弃
+	Args interface{}
+}
+
+// Call 是服务器收到的一次调用
+type Call struct {
+	ServiceMethod string
+	Args          interface{} // 解码后的参数，仅当对应的 Stub 设置了 Args 时记录
+	Token         string      // 调用使用的凭证，单次调用没有设置时为连接握手时的凭证
+	RequestID     string
+	Time          time.Time
+}
+
+// Server 是内存中的模拟服务器，它实现了 geerpc 的 gob 协议，但不分发到真实的服务，
+// 而是按 Stub 设置的方式响应。可以同时被多个连接和多个 goroutine 使用
+type Server struct {
+	mu     sync.Mutex // 保护以下字段
+	stubs  map[string]Stub
+	calls  []Call
+	conns  map[io.Closer]struct{}
+	closed bool
+
+	done chan struct{} // Close 时关闭，中断正在等待 Delay 的响应
+}
+
+// NewServer 创建一个没有任何 Stub 的模拟服务器，未设置 Stub 的方法返回找不到方法的错误
+func NewServer() *Server {
+	return &Server{
+		stubs: make(map[string]Stub),
+		conns: make(map[io.Closer]struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Stub 设置 serviceMethod 的响应，覆盖之前的设置
+func (s *Server) Stub(serviceMethod string, stub Stub) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs[serviceMethod] = stub
+}
+
+// Dial 通过内存中的 net.Pipe 连接到模拟服务器并返回客户端，opt 为 nil 时使用 geerpc.DefaultOption
+func (s *Server) Dial(opt *geerpc.Option) (*geerpc.Client, error) {
+	if opt == nil {
+		o := *geerpc.DefaultOption
+		opt = &o
+	}
+	server, client := net.Pipe()
+	go s.ServeConn(server)
+	return geerpc.NewClient(client, opt)
+}
+
+// ServeConn 在 conn 上提供服务，直到连接关闭。不支持签名和加密的连接
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	var opt geerpc.Option
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&opt); err != nil || opt.MagicNumber != geerpc.MagicNumber {
+		return
+	}
+	f := codec.NewCodecFuncMap[opt.CodecType]
+	if f == nil || opt.SigningKeyID != "" || opt.Encrypted {
+		return
+	}
+	// 与 geerpc.Server 相同，拼回 JSON 解码器多读的数据，并跳过选项末尾的换行符
+	r := bufio.NewReader(io.MultiReader(dec.Buffered(), conn))
+	if b, err := r.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = r.Discard(1)
+	}
+	cc := f(&bufferedConn{Reader: r, ReadWriteCloser: conn})
+	sending := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	closing := make(chan struct{}) // 连接断开后不再等待 Delay
+	for {
+		var h codec.Header
+		if err := cc.ReadHeader(&h); err != nil {
+			break
+		}
+		s.mu.Lock()
+		stub, ok := s.stubs[h.ServiceMethod]
+		s.mu.Unlock()
+		call := Call{ServiceMethod: h.ServiceMethod, Token: h.Token, RequestID: h.RequestID, Time: time.Now()}
+		if call.Token == "" {
+			call.Token = opt.Credentials
+		}
+		var err error
+		if ok && stub.Args != nil {
+			argv := reflect.New(reflect.TypeOf(stub.Args))
+			if err = cc.ReadBody(argv.Interface()); err == nil {
+				call.Args = argv.Elem().Interface()
+			}
+		} else {
+			err = cc.ReadBody(nil)
+		}
+		if err != nil {
+			break
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, call)
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func(h codec.Header) {
+			defer wg.Done()
+			var reply interface{} = struct{}{}
+			switch {
+			case !ok:
+				h.Error = "rpc server: can't find method " + h.ServiceMethod
+			case stub.Delay > 0 && !s.sleep(stub.Delay, closing):
+				return
+			case stub.Err != "":
+				h.Error = stub.Err
+			case stub.Reply == nil:
+				h.Error = "geerpctest: Stub.Reply is nil for " + h.ServiceMethod
+			default:
+				reply = stub.Reply
+			}
+			sending.Lock()
+			defer sending.Unlock()
+			_ = cc.Write(&h, reply)
+		}(h)
+	}
+	close(closing)
+	wg.Wait()
+}
+
+// sleep 等待 d，服务器或连接关闭时提前返回 false
+func (s *Server) sleep(d time.Duration, closing <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.done:
+		return false
+	case <-closing:
+		return false
+	}
+}
+
+// Close 关闭所有连接，正在等待 Delay 的调用不再响应
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("geerpctest: server already closed")
+	}
+	s.closed = true
+	close(s.done)
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// Calls 返回收到的 serviceMethod 的调用，serviceMethod 为空时返回所有调用，按收到的顺序排列
+func (s *Server) Calls(serviceMethod string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if serviceMethod == "" || c.ServiceMethod == serviceMethod {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset 清空记录的调用，保留 Stub
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+}
+
+// TB 是断言使用的 testing.TB 的子集
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertCalled 检查 serviceMethod 恰好被调用了 times 次
+func (s *Server) AssertCalled(t TB, serviceMethod string, times int) bool {
+	t.Helper()
+	if n := len(s.Calls(serviceMethod)); n != times {
+		t.Errorf("geerpctest: %s called %d times, want %d", serviceMethod, n, times)
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled 检查 serviceMethod 没有被调用
+func (s *Server) AssertNotCalled(t TB, serviceMethod string) bool {
+	t.Helper()
+	return s.AssertCalled(t, serviceMethod, 0)
+}
+
+// AssertCalledWith 检查 serviceMethod 至少有一次调用的参数与 args 相同（reflect.DeepEqual），
+// 对应的 Stub 需要设置 Args 才会记录参数
+func (s *Server) AssertCalledWith(t TB, serviceMethod string, args interface{}) bool {
+	t.Helper()
+	calls := s.Calls(serviceMethod)
+	for _, c := range calls {
+		if reflect.DeepEqual(c.Args, args) {
+			return true
+		}
+	}
+	got := make([]string, len(calls))
+	for i, c := range calls {
+		got[i] = fmt.Sprintf("%+v", c.Args)
+	}
+	t.Errorf("geerpctest: %s not called with %+v, got %v", serviceMethod, args, got)
+	return false
+}
+
+// bufferedConn 先读取选项解码时缓冲的剩余数据，再继续读取原连接
+type bufferedConn struct {
+	io.Reader
+	io.ReadWriteCloser
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
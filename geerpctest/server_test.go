@@ -0,0 +1,66 @@
+package geerpctest
+
+import (
+	"context"
+	"geerpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Args struct{ Num1, Num2 int }
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer func() { _ = srv.Close() }()
+	srv.Stub("Arith.Add", Stub{Reply: 3, Args: Args{}})
+	srv.Stub("Arith.Div", Stub{Err: "divide by zero"})
+	srv.Stub("Arith.Slow", Stub{Reply: 1, Delay: time.Second})
+
+	opt := *geerpc.DefaultOption
+	opt.Credentials = "secret"
+	client, err := srv.Dial(&opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply int
+	if err := client.Call(context.Background(), "Arith.Add", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("Arith.Add: reply %d, err %v", reply, err)
+	}
+	if err := client.Call(context.Background(), "Arith.Div", Args{}, &reply); err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("Arith.Div: expect stubbed error, got %v", err)
+	}
+	if err := client.Call(context.Background(), "Arith.Mul", Args{}, &reply); err == nil || !strings.Contains(err.Error(), "can't find method") {
+		t.Fatalf("Arith.Mul: expect can't find method, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Call(ctx, "Arith.Slow", Args{}, &reply); err == nil {
+		t.Fatal("Arith.Slow: expect timeout")
+	}
+
+	srv.AssertCalled(t, "Arith.Add", 1)
+	srv.AssertCalledWith(t, "Arith.Add", Args{Num1: 1, Num2: 2})
+	srv.AssertNotCalled(t, "Arith.Sub")
+	if calls := srv.Calls(""); len(calls) != 4 || calls[0].Token != "secret" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+
+	var rec recorder
+	srv.AssertCalledWith(&rec, "Arith.Add", Args{Num1: 2, Num2: 2})
+	srv.AssertCalled(&rec, "Arith.Div", 2)
+	if len(rec.errors) != 2 {
+		t.Fatalf("expect 2 failed assertions, got %v", rec.errors)
+	}
+}
+
+// recorder 记录断言失败的信息，用于测试断言本身
+type recorder struct{ errors []string }
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, format)
+}
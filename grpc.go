@@ -0,0 +1,252 @@
+package geerpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC 状态码，参见 https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcUnknown            = 2
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnauthenticated    = 16
+)
+
+// grpcContentType 是 GRPCHandler 支持的内容类型，消息使用 JSON 编码
+const grpcContentType = "application/grpc+json"
+
+// GRPCHandler 返回一个以 gRPC 协议提供服务器上所有服务的 http.Handler，便于 gRPC 客户端在迁移期间调用 geerpc 服务：
+//   - 方法 Service.Method 对应 gRPC 路径 /Service/Method，服务名可以带有包名前缀（/pkg.Service/Method）；
+//   - 消息使用 JSON 编码（内容类型 application/grpc+json，例如 grpc-go 中注册一个名为 "json" 的编解码器并使用
+//     grpc.CallContentSubtype("json")），请求消息可以使用 gzip 压缩，不支持 protobuf 编码和流式调用；
+//   - 元数据 authorization: Bearer <token> 作为单次调用的凭证，x-request-id 作为请求 ID，grpc-timeout 作为调用的截止时间；
+//   - 错误映射为 gRPC 状态码：认证失败为 UNAUTHENTICATED，授权失败为 PERMISSION_DENIED，超出配额为 RESOURCE_EXHAUSTED，
+//     找不到方法为 UNIMPLEMENTED，参数无法解码为 INVALID_ARGUMENT，超时为 DEADLINE_EXCEEDED，服务方法返回的错误为 UNKNOWN。
+//
+// gRPC 要求 HTTP/2，应使用启用了 TLS 的 http.Server（例如 ListenAndServeTLS）挂载该处理程序。
+// 与 JSON-RPC 一样，要求签名或加密的服务器拒绝 gRPC 请求
+func (server *Server) GRPCHandler() http.Handler {
+	return grpcHandler{server}
+}
+
+type grpcHandler struct {
+	server *Server
+}
+
+// ServeHTTP 处理一个 unary gRPC 调用
+func (h grpcHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	server := h.server
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "405 must POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := req.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/grpc") {
+		http.Error(w, "415 unsupported content type "+ct, http.StatusUnsupportedMediaType)
+		return
+	} else if ct != grpcContentType {
+		writeGRPCStatus(w, grpcUnimplemented, "rpc server: only "+grpcContentType+" is supported")
+		return
+	}
+	if !server.permitConn(req.RemoteAddr) {
+		writeGRPCStatus(w, grpcPermissionDenied, "rpc server: connection rejected")
+		return
+	}
+	if err := server.checkHandshakeless(); err != nil {
+		writeGRPCStatus(w, grpcFailedPrecondition, err.Error())
+		return
+	}
+	serviceMethod, ok := grpcServiceMethod(req.URL.Path)
+	if !ok {
+		writeGRPCStatus(w, grpcUnimplemented, "rpc server: service/method request ill-formed: "+req.URL.Path)
+		return
+	}
+
+	ctx := req.Context()
+	if v := req.Header.Get("Grpc-Timeout"); v != "" {
+		timeout, err := parseGRPCTimeout(v)
+		if err != nil {
+			writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var token string
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	requestID := req.Header.Get("X-Request-Id")
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	msg, err := readGRPCMessage(req.Body, req.Header.Get("Grpc-Encoding"), server.handshakelessMessageSize())
+	if err != nil {
+		writeGRPCStatus(w, grpcInternal, err.Error())
+		return
+	}
+
+	reply, stage, err := server.directCall(ctx, serviceMethod, requestID, token, &connTracker{remote: req.RemoteAddr}, func(argvi interface{}) error {
+		if len(bytes.TrimSpace(msg)) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg, argvi)
+	})
+	if err != nil {
+		writeGRPCStatus(w, grpcCode(stage, err), err.Error())
+		return
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		writeGRPCStatus(w, grpcInternal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set("X-Request-Id", requestID)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(body)))
+	_, _ = w.Write(prefix)
+	_, _ = w.Write(body)
+	w.Header().Set("Grpc-Status", strconv.Itoa(grpcOK))
+	w.Header().Set("Grpc-Message", "")
+}
+
+// grpcServiceMethod 将 gRPC 路径 /pkg.Service/Method 转换为 Service.Method
+func grpcServiceMethod(path string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	service := parts[0]
+	if dot := strings.LastIndex(service, "."); dot >= 0 {
+		service = service[dot+1:]
+	}
+	return service + "." + parts[1], true
+}
+
+// parseGRPCTimeout 解析 grpc-timeout 头，格式为不超过 8 位的正整数加单位（H、M、S、m、u、n）
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, errors.New("rpc server: invalid grpc-timeout " + v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("rpc server: invalid grpc-timeout " + v)
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	unit, ok := units[v[len(v)-1]]
+	if !ok {
+		return 0, errors.New("rpc server: invalid grpc-timeout " + v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// readGRPCMessage 读取请求中唯一的消息：1 字节压缩标志、4 字节长度和消息内容。limit 为 0 表示不限制长度
+func readGRPCMessage(r io.Reader, encoding string, limit int64) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil // 没有消息，使用参数的零值
+		}
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if limit > 0 && int64(n) > limit {
+		return nil, fmt.Errorf("rpc server: grpc message of %d bytes exceeds limit %d", n, limit)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	if prefix[0] == 0 {
+		return msg, nil
+	}
+	if encoding != "gzip" {
+		return nil, errors.New("rpc server: unsupported grpc-encoding " + encoding)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	var lr io.Reader = zr
+	if limit > 0 {
+		lr = io.LimitReader(zr, limit+1)
+	}
+	msg, err = ioutil.ReadAll(lr)
+	if err == nil && limit > 0 && int64(len(msg)) > limit {
+		err = fmt.Errorf("rpc server: decompressed grpc message exceeds limit %d", limit)
+	}
+	return msg, err
+}
+
+// grpcCode 根据直接调用失败的阶段和错误选择 gRPC 状态码
+func grpcCode(stage int, err error) int {
+	switch stage {
+	case callNotFound:
+		return grpcUnimplemented
+	case callBadArgs:
+		return grpcInvalidArgument
+	case callRejected:
+		switch {
+		case errors.Is(err, ErrUnauthenticated):
+			return grpcUnauthenticated
+		case errors.Is(err, ErrQuotaExceeded):
+			return grpcResourceExhausted
+		}
+		return grpcPermissionDenied
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return grpcDeadlineExceeded
+	case context.Canceled:
+		return grpcCanceled
+	}
+	return grpcUnknown
+}
+
+// writeGRPCStatus 返回一个只包含状态的响应（Trailers-Only）
+func writeGRPCStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage 按 gRPC 的要求对 grpc-message 做百分号编码
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package geerpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// grpcFrame 按 gRPC 的格式封装一个消息
+func grpcFrame(msg []byte, compressed bool) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	if compressed {
+		frame[0] = 1
+	}
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// grpcResult 是一次 gRPC 调用的结果
+type grpcResult struct {
+	status    int
+	message   string
+	body      []byte
+	requestID string
+}
+
+// grpcCall 发送一个 unary gRPC 请求，header 中的值覆盖默认的请求头
+func grpcCall(t *testing.T, url, path string, frame []byte, header map[string]string) grpcResult {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+path, bytes.NewReader(frame))
+	req.Header.Set("Content-Type", grpcContentType)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := ioutil.ReadAll(resp.Body)
+	r := grpcResult{requestID: resp.Header.Get("X-Request-Id")}
+	if len(data) >= 5 {
+		r.body = data[5:]
+	}
+	// 成功的调用在 trailer 中返回状态，只包含状态的响应在响应头中返回
+	status := resp.Trailer.Get("Grpc-Status")
+	r.message = resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		r.message = resp.Header.Get("Grpc-Message")
+	}
+	r.status, _ = strconv.Atoi(status)
+	return r
+}
+
+func TestServer_GRPCHandler(t *testing.T) {
+	server := newCalcServer(false)
+	_ = server.Register(new(Bar))
+	ts := httptest.NewServer(server.GRPCHandler())
+	defer ts.Close()
+
+	// 服务名可以带有包名前缀，x-request-id 原样返回
+	r := grpcCall(t, ts.URL, "/geerpc.Foo/Sum", grpcFrame([]byte(`{"Num1":1,"Num2":2}`), false), map[string]string{"X-Request-Id": "req-1"})
+	if r.status != grpcOK || string(r.body) != "3" || r.requestID != "req-1" {
+		t.Fatalf("expect result 3 with request id req-1, got %+v", r)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(`{"Num1":8,"Num2":2}`))
+	_ = zw.Close()
+	r = grpcCall(t, ts.URL, "/Calc/Div", grpcFrame(buf.Bytes(), true), map[string]string{"Grpc-Encoding": "gzip"})
+	if r.status != grpcOK || string(r.body) != "4" {
+		t.Fatalf("expect result 4 for a gzip message, got %+v", r)
+	}
+
+	// 错误映射为 gRPC 状态码
+	cases := []struct {
+		name   string
+		path   string
+		msg    string
+		header map[string]string
+		status int
+	}{
+		{"method error", "/Calc/Div", `{"Num1":1}`, nil, grpcUnknown},
+		{"unknown method", "/Calc/Missing", `{}`, nil, grpcUnimplemented},
+		{"ill-formed path", "/Calc", `{}`, nil, grpcUnimplemented},
+		{"bad args", "/Foo/Sum", `"bad"`, nil, grpcInvalidArgument},
+		{"deadline", "/Bar/Timeout", `1`, map[string]string{"Grpc-Timeout": "50m"}, grpcDeadlineExceeded},
+		{"bad timeout", "/Foo/Sum", `{}`, map[string]string{"Grpc-Timeout": "1x"}, grpcInvalidArgument},
+		{"protobuf", "/Foo/Sum", `{}`, map[string]string{"Content-Type": "application/grpc"}, grpcUnimplemented},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start := time.Now()
+			r := grpcCall(t, ts.URL, c.path, grpcFrame([]byte(c.msg), false), c.header)
+			if r.status != c.status {
+				t.Fatalf("expect status %d, got %+v", c.status, r)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Fatalf("expect the call to end by its deadline, took %v", d)
+			}
+		})
+	}
+	if r := grpcCall(t, ts.URL, "/Calc/Div", grpcFrame([]byte(`{"Num1":1}`), false), nil); r.message != "divide by zero" {
+		t.Fatalf("expect the method error in grpc-message, got %q", r.message)
+	}
+
+	resp, err := http.Post(ts.URL+"/Foo/Sum", "application/json", bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expect 415 for a non-gRPC request, got %d", resp.StatusCode)
+	}
+}
+
+func TestServer_GRPCHandlerAuth(t *testing.T) {
+	ts := httptest.NewServer(newAuthServer(StaticTokens{"good": "alice"}).GRPCHandler())
+	defer ts.Close()
+
+	// authorization 元数据作为单次调用的凭证
+	r := grpcCall(t, ts.URL, "/Who/Identity", grpcFrame([]byte(`1`), false), map[string]string{"Authorization": "Bearer good"})
+	if r.status != grpcOK || string(r.body) != `"alice"` {
+		t.Fatalf("expect identity alice, got %+v", r)
+	}
+	for _, token := range []string{"", "Bearer bad"} {
+		r := grpcCall(t, ts.URL, "/Who/Identity", grpcFrame([]byte(`1`), false), map[string]string{"Authorization": token})
+		if r.status != grpcUnauthenticated {
+			t.Fatalf("expect UNAUTHENTICATED for %q, got %+v", token, r)
+		}
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	for v, want := range map[string]time.Duration{"1H": time.Hour, "2M": 2 * time.Minute, "3S": 3 * time.Second,
+		"50m": 50 * time.Millisecond, "7u": 7 * time.Microsecond, "9n": 9} {
+		if got, err := parseGRPCTimeout(v); err != nil || got != want {
+			t.Fatalf("expect %v for %s, got %v, err %v", want, v, got, err)
+		}
+	}
+	for _, v := range []string{"", "S", "1", "123456789S", "-1S", "1x"} {
+		if _, err := parseGRPCTimeout(v); err == nil {
+			t.Fatalf("expect an error for %q", v)
+		}
+	}
+	if got := encodeGRPCMessage("50% off\n"); got != "50%25 off%0A" {
+		t.Fatalf("expect percent-encoded message, got %q", got)
+	}
+}
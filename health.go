@@ -0,0 +1,132 @@
+package geerpc
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// 健康检查返回的状态。进程存活时 Health.Live 总是返回 HealthServing，
+// Health.Check 返回服务器是否就绪，只有 HealthServing 表示可以接收流量
+const (
+	HealthServing    = "SERVING"
+	HealthNotServing = "NOT_SERVING" // 尚未就绪，例如仍在注册服务或预热
+	HealthDraining   = "DRAINING"    // 正在排空，等待已有请求完成后退出
+	HealthOverloaded = "OVERLOADED"  // 正在处理的请求数达到了 SetOverloadThreshold 设置的阈值
+)
+
+const (
+	defaultLivenessPath  = "/healthz"
+	defaultReadinessPath = "/readyz"
+)
+
+// Health 是每个 Server 内置的健康检查服务，无需注册即可调用 "Health.Check" 和 "Health.Live"。
+// 与进程级的心跳不同，它经过完整的 RPC 处理流程，能够发现监听器卡死等问题
+type Health struct {
+	server *Server
+}
+
+// Check 返回服务器的就绪状态，service 参数目前未使用。
+// 注册中心的主动健康检查会摘除返回值不是 HealthServing 的服务器，直到检查再次通过
+func (h *Health) Check(service string, status *string) error {
+	*status = h.server.Readiness()
+	return nil
+}
+
+// Live 返回服务器的存活状态，能够处理请求时总是返回 HealthServing，service 参数目前未使用
+func (h *Health) Live(service string, status *string) error {
+	*status = HealthServing
+	return nil
+}
+
+// SetServingStatus 设置服务器的就绪状态，默认为 HealthServing。
+// 通常在启动时先设置为 HealthNotServing，注册完服务后再设置为 HealthServing；
+// 退出前设置为 HealthDraining，使编排系统和注册中心不再发送新的流量
+func (server *Server) SetServingStatus(status string) {
+	server.status.Store(status)
+}
+
+// SetOverloadThreshold 设置过载阈值，正在处理的请求数达到 n 时就绪状态为 HealthOverloaded，
+// n <= 0（默认）表示不检查
+func (server *Server) SetOverloadThreshold(n int) {
+	atomic.StoreInt64(&server.overload, int64(n))
+}
+
+// Readiness 返回服务器当前的就绪状态
+func (server *Server) Readiness() string {
+	if status, _ := server.status.Load().(string); status != "" && status != HealthServing {
+		return status
+	}
+	if n := atomic.LoadInt64(&server.overload); n > 0 && atomic.LoadInt64(&server.inflight) >= n {
+		return HealthOverloaded
+	}
+	return HealthServing
+}
+
+// SetHealthService 设置是否提供内置的 Health 服务以及 HTTP 健康检查接口，默认提供。
+// 关闭后 "Health.Check" 等调用返回找不到服务，HandleHealthHTTP 不挂载任何接口，
+// 适用于不希望在对外的监听器上暴露内部状态的环境。需要保留健康检查但限制调用方时，可以使用 SetAuthorizer。
+// 应在调用 HandleHealthHTTP 之前设置，对 "Health.*" 调用的影响在设置后立即生效
+func (server *Server) SetHealthService(enable bool) {
+	server.builtinMu.Lock()
+	defer server.builtinMu.Unlock()
+	server.noHealth = !enable
+	server.buildBuiltin()
+}
+
+// HandleHealthHTTP 在 mux 上挂载 HTTP 健康检查接口，mux 为 nil 时使用 http.DefaultServeMux：
+//   - defaultLivenessPath：进程存活时总是返回 200
+//   - defaultReadinessPath：就绪时返回 200，否则返回 503，响应体为就绪状态
+//
+// HandleHTTP 不会挂载这些接口，以免与程序已经在 http.DefaultServeMux 上注册的同名路径冲突
+func (server *Server) HandleHealthHTTP(mux *http.ServeMux) {
+	if server.noHealth {
+		return
+	}
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	mux.HandleFunc(defaultLivenessPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, HealthServing+"\n")
+	})
+	mux.HandleFunc(defaultReadinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		status := server.Readiness()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if status != HealthServing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = io.WriteString(w, status+"\n")
+	})
+}
+
+// builtinService 返回名为 name 的内置服务，不存在时返回 nil
+func (server *Server) builtinService(name string) *service {
+	return server.builtinServices()[name]
+}
+
+// builtinServices 返回所有内置服务，第一次调用时生成
+func (server *Server) builtinServices() map[string]*service {
+	if m, ok := server.builtin.Load().(map[string]*service); ok {
+		return m
+	}
+	server.builtinMu.Lock()
+	defer server.builtinMu.Unlock()
+	return server.buildBuiltin()
+}
+
+// buildBuiltin 根据 noHealth 和 reflection 重新生成内置服务，调用方需持有 server.builtinMu。
+// 修改这两个设置时都会调用它，因此在处理过请求之后修改设置同样生效
+func (server *Server) buildBuiltin() map[string]*service {
+	m := make(map[string]*service)
+	if !server.noHealth {
+		s := newService(&Health{server: server})
+		m[s.name] = s
+	}
+	if server.reflection {
+		s := newService(&Reflection{server: server})
+		m[s.name] = s
+	}
+	server.builtin.Store(m)
+	return m
+}
@@ -0,0 +1,120 @@
+package geerpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServer_HealthService(t *testing.T) {
+	server := NewServer()
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	call := func(method string) string {
+		var status string
+		if err := client.Call(context.Background(), method, "", &status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	if got := call("Health.Check"); got != HealthServing {
+		t.Fatalf("expect SERVING by default, got %s", got)
+	}
+	// 就绪状态变化不影响存活状态
+	for _, status := range []string{HealthNotServing, HealthDraining} {
+		server.SetServingStatus(status)
+		if got := call("Health.Check"); got != status {
+			t.Fatalf("expect readiness %s, got %s", status, got)
+		}
+		if got := call("Health.Live"); got != HealthServing {
+			t.Fatalf("expect liveness SERVING while %s, got %s", status, got)
+		}
+	}
+	server.SetServingStatus(HealthServing)
+
+	// 正在处理的请求数达到阈值时为 OVERLOADED
+	server.SetOverloadThreshold(2)
+	atomic.StoreInt64(&server.inflight, 1)
+	if got := server.Readiness(); got != HealthServing {
+		t.Fatalf("expect SERVING below the threshold, got %s", got)
+	}
+	atomic.StoreInt64(&server.inflight, 2)
+	if got := server.Readiness(); got != HealthOverloaded {
+		t.Fatalf("expect OVERLOADED at the threshold, got %s", got)
+	}
+	server.SetOverloadThreshold(0)
+	if got := server.Readiness(); got != HealthServing {
+		t.Fatalf("expect no overload check with threshold 0, got %s", got)
+	}
+}
+
+func TestServer_HandleHealthHTTP(t *testing.T) {
+	server := NewServer()
+	mux := http.NewServeMux()
+	server.HandleHealthHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	get := func(path string) (int, string) {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(body))
+	}
+
+	if code, body := get(defaultReadinessPath); code != http.StatusOK || body != HealthServing {
+		t.Fatalf("expect 200 SERVING, got %d %s", code, body)
+	}
+	server.SetServingStatus(HealthDraining)
+	if code, body := get(defaultReadinessPath); code != http.StatusServiceUnavailable || body != HealthDraining {
+		t.Fatalf("expect 503 DRAINING, got %d %s", code, body)
+	}
+	if code, _ := get(defaultLivenessPath); code != http.StatusOK {
+		t.Fatalf("expect liveness 200 while draining, got %d", code)
+	}
+}
+
+func TestServer_HealthServiceDisabled(t *testing.T) {
+	server := NewServer()
+	server.SetHealthService(false)
+	mux := http.NewServeMux()
+	server.HandleHealthHTTP(mux)
+	if _, pattern := mux.Handler(httptest.NewRequest("GET", defaultLivenessPath, nil)); pattern != "" {
+		t.Fatalf("expect no health endpoints, got %s", pattern)
+	}
+
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var status string
+	if err := client.Call(context.Background(), "Health.Check", "", &status); err == nil {
+		t.Fatal("expect Health.Check to be unavailable")
+	}
+}
+
+// handleHTTPOnce 保证 -count 大于 1 时不会在 http.DefaultServeMux 上重复注册
+var handleHTTPOnce sync.Once
+
+func TestServer_HandleHTTPKeepsHealthPaths(t *testing.T) {
+	// HandleHTTP 不挂载健康检查接口，程序已经注册的同名路径不会引起 panic
+	handleHTTPOnce.Do(func() {
+		http.HandleFunc(defaultLivenessPath, func(http.ResponseWriter, *http.Request) {})
+		NewServer().HandleHTTP()
+	})
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest("GET", defaultReadinessPath, nil)); pattern == defaultReadinessPath {
+		t.Fatal("expect HandleHTTP not to mount the readiness endpoint")
+	}
+}
@@ -0,0 +1,152 @@
+package geerpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// 进程内传输使用 net.Pipe 连接客户端和服务端，数据经过完整的选项握手和编解码流程，但不经过 TCP，
+// 适用于测试（无需分配端口）和把多个服务打包进同一个进程的部署。
+// DialInProc 直接连接到一个 Server；ListenInProc 返回一个具名的监听器，XDial("inproc@name") 连接到它
+
+var errInProcClosed = errors.New("rpc: inproc listener closed")
+
+// inProcListeners 保存 ListenInProc 创建的监听器，键为名称
+var inProcListeners = struct {
+	sync.Mutex
+	m map[string]*InProcListener
+}{m: make(map[string]*InProcListener)}
+
+// inProcAddr 是进程内连接的地址
+type inProcAddr string
+
+func (a inProcAddr) Network() string { return "inproc" }
+func (a inProcAddr) String() string  { return string(a) }
+
+// inProcConn 将 net.Pipe 的地址替换为监听器的名称，使日志和连接统计中的地址有意义
+type inProcConn struct {
+	net.Conn
+	addr inProcAddr
+}
+
+func (c *inProcConn) LocalAddr() net.Addr  { return c.addr }
+func (c *inProcConn) RemoteAddr() net.Addr { return c.addr }
+
+// InProcListener 是进程内的监听器，实现了 net.Listener，可以传给 Server.Accept 等函数
+type InProcListener struct {
+	name   string
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+// ListenInProc 创建名为 name 的进程内监听器，之后 XDial("inproc@" + name) 会连接到它。
+// 同一个名称同时只能有一个监听器，Close 后可以重新使用
+func ListenInProc(name string) (*InProcListener, error) {
+	inProcListeners.Lock()
+	defer inProcListeners.Unlock()
+	if _, ok := inProcListeners.m[name]; ok {
+		return nil, errors.New("rpc: inproc address already in use: " + name)
+	}
+	l := &InProcListener{name: name, conns: make(chan net.Conn), closed: make(chan struct{})}
+	inProcListeners.m[name] = l
+	return l, nil
+}
+
+// Accept 等待并返回下一个连接
+func (l *InProcListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errInProcClosed
+	}
+}
+
+// Close 停止监听并释放名称，已经建立的连接不受影响
+func (l *InProcListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+		inProcListeners.Lock()
+		delete(inProcListeners.m, l.name)
+		inProcListeners.Unlock()
+	})
+	return nil
+}
+
+// Addr 返回监听器的地址，即它的名称
+func (l *InProcListener) Addr() net.Addr {
+	return inProcAddr(l.name)
+}
+
+// dial 创建一对管道并将服务端一端交给 Accept，timeout 为 0 时一直等待
+func (l *InProcListener) dial(timeout time.Duration) (net.Conn, error) {
+	server, client := net.Pipe()
+	addr := inProcAddr(l.name)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case l.conns <- &inProcConn{Conn: server, addr: addr}:
+		return &inProcConn{Conn: client, addr: addr}, nil
+	case <-l.closed:
+	case <-expired:
+		_ = server.Close()
+		_ = client.Close()
+		return nil, errors.New("rpc client: inproc dial timeout: " + l.name)
+	}
+	_ = server.Close()
+	_ = client.Close()
+	return nil, errors.New("rpc client: connection refused: inproc@" + l.name)
+}
+
+// dialInProc 连接到名为 name 的进程内监听器
+func dialInProc(name string, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	inProcListeners.Lock()
+	l := inProcListeners.m[name]
+	inProcListeners.Unlock()
+	if l == nil {
+		return nil, errors.New("rpc client: connection refused: inproc@" + name)
+	}
+	conn, err := l.dial(opt.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return newInProcClient(conn, opt)
+}
+
+// DialInProc 通过 net.Pipe 连接到 server 并返回客户端，无需监听器。
+// 连接不经过 TLS，Option.TLSConfig 被忽略，其他选项（凭证、签名、加密等）与网络连接相同
+func DialInProc(server *Server, opts ...*Option) (*Client, error) {
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	s, c := net.Pipe()
+	addr := inProcAddr("inproc")
+	go server.ServeConn(&inProcConn{Conn: s, addr: addr})
+	return newInProcClient(&inProcConn{Conn: c, addr: addr}, opt)
+}
+
+// newInProcClient 在 conn 上完成握手，握手受 ConnectTimeout 的限制：服务端没有读取选项时管道的写入会一直阻塞
+func newInProcClient(conn net.Conn, opt *Option) (*Client, error) {
+	if opt.ConnectTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(opt.ConnectTimeout))
+	}
+	client, err := NewClient(conn, opt)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+	return client, nil
+}
@@ -0,0 +1,59 @@
+package geerpc
+
+import (
+	"context"
+	"errors"
+)
+
+// 拦截器返回以下错误时，服务端不发送错误响应：ErrDropResponse 丢弃本次调用的响应，ErrResetConn 立即关闭连接。
+// 它们用于故障注入（参见 chaos 包），调用方会观察到调用超时或连接断开。只对 geerpc 协议的连接有效，
+// JSON-RPC 和 gRPC 请求会收到错误信息
+var (
+	ErrDropResponse = errors.New("rpc server: response dropped")
+	ErrResetConn    = errors.New("rpc server: connection reset")
+)
+
+// CallInfo 描述服务端收到的一次调用
+type CallInfo struct {
+	ServiceMethod string
+	RequestID     string
+	Remote        string // 客户端地址
+	Identity      string // 认证得到的调用方身份，未认证时为空
+	Token         string // 单次调用的凭证，没有时为握手时的凭证
+}
+
+// Handler 执行服务端的一次调用，args 和 reply 与服务方法的参数相同
+type Handler func(ctx context.Context, info *CallInfo, args, reply interface{}) error
+
+// ServerInterceptor 拦截服务端的调用，可以在调用 next 前后加入额外逻辑，例如鉴权、注入上下文或记录日志。
+// 返回错误而不调用 next 会拒绝调用，错误信息会返回给客户端
+type ServerInterceptor func(ctx context.Context, info *CallInfo, args, reply interface{}, next Handler) error
+
+// Use 追加服务端拦截器，先追加的拦截器位于调用链的外层。拦截器在认证、授权和配额检查之后执行，应在开始服务之前调用
+func (server *Server) Use(interceptors ...ServerInterceptor) {
+	server.interceptors = append(server.interceptors, interceptors...)
+}
+
+// invoke 经过拦截器调用请求的服务方法
+func (server *Server) invoke(ctx context.Context, req *request) error {
+	if len(server.interceptors) == 0 {
+		return req.svc.callContext(ctx, req.mtype, req.argv, req.replyv)
+	}
+	handler := Handler(func(ctx context.Context, _ *CallInfo, _, _ interface{}) error {
+		return req.svc.callContext(ctx, req.mtype, req.argv, req.replyv)
+	})
+	for i := len(server.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := server.interceptors[i], handler
+		handler = func(ctx context.Context, info *CallInfo, args, reply interface{}) error {
+			return interceptor(ctx, info, args, reply, next)
+		}
+	}
+	info := &CallInfo{
+		ServiceMethod: req.h.ServiceMethod,
+		RequestID:     req.h.RequestID,
+		Remote:        req.remote,
+		Identity:      req.identity,
+		Token:         req.token,
+	}
+	return handler(ctx, info, req.argv.Interface(), req.replyv.Interface())
+}
@@ -0,0 +1,268 @@
+// Package confparse 解析配置文件使用的 YAML 和 TOML 子集，供 config 和 xclient 等包共享
+package confparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value 是配置文件中的一个值，键为以 "." 连接的完整路径，例如 "server.handle_timeout"
+type Value struct {
+	Scalar string
+	List   []string
+	IsList bool
+	Line   int // 所在的行号，用于错误信息
+}
+
+// Values 是解析后的配置文件，键为完整路径
+type Values map[string]Value
+
+// set 记录键值，重复的键返回错误
+func (vs Values) set(key string, v Value) error {
+	if old, ok := vs[key]; ok {
+		return fmt.Errorf("rpc config: line %d: duplicate key %q (first defined at line %d)", v.Line, key, old.Line)
+	}
+	vs[key] = v
+	return nil
+}
+
+// ParseYAML 解析 YAML 的一个子集：以空格缩进的嵌套映射、"key: value" 形式的标量、
+// "- item" 形式的块列表、"[a, b]" 形式的行内列表、单引号和双引号字符串以及 "#" 注释。
+// 不支持锚点、多文档、多行字符串和映射的列表，配置不需要这些特性
+func ParseYAML(data []byte) (Values, error) {
+	type parent struct {
+		indent int
+		key    string
+	}
+	vs := make(Values)
+	var stack []parent
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := strings.TrimRight(stripComment(raw), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("rpc config: line %d: tabs are not allowed for indentation", lineNo)
+		}
+		indent := len(line) - len(trimmed)
+
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			// 列表项可以与所属的键缩进相同
+			for len(stack) > 0 && indent < stack[len(stack)-1].indent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("rpc config: line %d: list item without a key", lineNo)
+			}
+			key := stack[len(stack)-1].key
+			item, err := parseScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-")), lineNo)
+			if err != nil {
+				return nil, err
+			}
+			v, ok := vs[key]
+			if ok && !v.IsList {
+				return nil, fmt.Errorf("rpc config: line %d: key %q mixes a value and list items", lineNo, key)
+			}
+			v.IsList, v.Line = true, lineNo
+			v.List = append(v.List, item)
+			vs[key] = v
+			continue
+		}
+
+		for len(stack) > 0 && indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		colon := strings.Index(trimmed, ": ")
+		if colon < 0 && strings.HasSuffix(trimmed, ":") {
+			colon = len(trimmed) - 1
+		}
+		if colon <= 0 {
+			return nil, fmt.Errorf("rpc config: line %d: expect \"key: value\"", lineNo)
+		}
+		key := strings.TrimSpace(trimmed[:colon])
+		if len(stack) > 0 {
+			if _, ok := vs[stack[len(stack)-1].key]; ok {
+				return nil, fmt.Errorf("rpc config: line %d: key %q mixes list items and nested keys", lineNo, stack[len(stack)-1].key)
+			}
+			key = stack[len(stack)-1].key + "." + key
+		}
+		rest := strings.TrimSpace(trimmed[colon+1:])
+		if rest == "" {
+			// 值为空：之后缩进更深的行是嵌套的键或列表项
+			stack = append(stack, parent{indent: indent, key: key})
+			continue
+		}
+		v, err := parseValue(rest, lineNo)
+		if err != nil {
+			return nil, err
+		}
+		if err := vs.set(key, v); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+// ParseTOML 解析 TOML 的一个子集："[a.b]" 表头、"key = value"（键可以是以 "." 连接的路径）、
+// 字符串、布尔值、数字、可以跨行的数组以及 "#" 注释。不支持表数组（"[[a]]"）和行内表
+func ParseTOML(data []byte) (Values, error) {
+	vs := make(Values)
+	table := ""
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("rpc config: line %d: arrays of tables are not supported", lineNo)
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("rpc config: line %d: unterminated table header", lineNo)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table == "" {
+				return nil, fmt.Errorf("rpc config: line %d: empty table name", lineNo)
+			}
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("rpc config: line %d: expect \"key = value\"", lineNo)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if table != "" {
+			key = table + "." + key
+		}
+		rest := strings.TrimSpace(line[eq+1:])
+		// 数组可以跨行，拼接到方括号闭合为止
+		for strings.HasPrefix(rest, "[") && !closed(rest) && i+1 < len(lines) {
+			i++
+			rest += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		v, err := parseValue(rest, lineNo)
+		if err != nil {
+			return nil, err
+		}
+		if err := vs.set(key, v); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+// closed 判断 s 中引号以外的方括号是否已经闭合
+func closed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// stripComment 去掉引号以外的 "#" 注释
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseValue 解析标量或 "[a, b]" 形式的列表
+func parseValue(s string, line int) (Value, error) {
+	if !strings.HasPrefix(s, "[") {
+		scalar, err := parseScalar(s, line)
+		return Value{Scalar: scalar, Line: line}, err
+	}
+	if !strings.HasSuffix(s, "]") {
+		return Value{}, fmt.Errorf("rpc config: line %d: unterminated list", line)
+	}
+	v := Value{IsList: true, Line: line}
+	for _, item := range splitList(s[1 : len(s)-1]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue // 允许结尾的逗号
+		}
+		scalar, err := parseScalar(item, line)
+		if err != nil {
+			return Value{}, err
+		}
+		v.List = append(v.List, scalar)
+	}
+	return v, nil
+}
+
+// splitList 按引号以外的逗号分割列表
+func splitList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// parseScalar 去掉字符串的引号，双引号字符串按 Go 的规则处理转义，单引号字符串中连续的两个单引号表示一个单引号
+func parseScalar(s string, line int) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("rpc config: line %d: invalid string %s", line, s)
+		}
+		return unquoted, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("rpc config: line %d: invalid string %s", line, s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
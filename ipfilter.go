@@ -0,0 +1,97 @@
+package geerpc
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ipFilter 按 CIDR 范围过滤客户端地址
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// parseCIDRs 解析 CIDR 列表，单个 IP 地址视为只包含该地址的范围
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.New("rpc server: invalid CIDR " + s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// permits 判断地址是否被允许：匹配 deny 的地址被拒绝；allow 不为空时只允许匹配 allow 的地址。
+// 无法解析为 IP 的地址（例如 Unix 套接字）只在 allow 为空时被允许
+func (f *ipFilter) permits(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return len(f.allow) == 0
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIPFilter 设置接受连接时的地址过滤，allow 和 deny 为 CIDR（或单个 IP）列表。
+// 匹配 deny 的连接被拒绝；allow 不为空时只接受匹配 allow 的连接。
+// 被拒绝的连接在读取握手数据之前（TLS 连接在 TLS 握手之前）就被关闭，并发布 ConnRejected 事件。
+// 两个列表都为空时关闭过滤，应在开始服务之前调用
+func (server *Server) SetIPFilter(allow, deny []string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	if len(allowNets) == 0 && len(denyNets) == 0 {
+		server.ipFilter = nil
+		return nil
+	}
+	server.ipFilter = &ipFilter{allow: allowNets, deny: denyNets}
+	return nil
+}
+
+// permitConn 检查客户端地址是否被允许（不被 IP 过滤拒绝，也没有因认证失败被锁定），不允许时记录日志并发布事件
+func (server *Server) permitConn(remote string) bool {
+	if server.ipFilter != nil && !server.ipFilter.permits(remote) {
+		server.log().Warn("rpc server: connection rejected by ip filter", "remote", remote)
+		server.events.Publish(ConnRejected{Time: time.Now(), Remote: remote, Reason: "ip filter"})
+		return false
+	}
+	if server.lockout.locked(remote) {
+		server.log().Warn("rpc server: connection rejected by auth lockout", "remote", remote)
+		server.events.Publish(ConnRejected{Time: time.Now(), Remote: remote, Reason: "auth lockout"})
+		return false
+	}
+	return true
+}
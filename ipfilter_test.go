@@ -0,0 +1,70 @@
+package geerpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIPFilter_Permits(t *testing.T) {
+	allow, _ := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.7", "fd00::/8"})
+	deny, _ := parseCIDRs([]string{"10.1.0.0/16"})
+	f := &ipFilter{allow: allow, deny: deny}
+	cases := map[string]bool{
+		"10.2.3.4:5000":      true,
+		"10.1.2.3:5000":      false, // 拒绝优先于允许
+		"192.168.1.7:5000":   true,
+		"192.168.1.8:5000":   false, // 不在允许列表中
+		"[fd00::1]:5000":     true,
+		"[2001:db8::1]:5000": false,
+		"/tmp/geerpc.sock":   false, // 设置了允许列表时拒绝无法解析的地址
+	}
+	for addr, want := range cases {
+		if got := f.permits(addr); got != want {
+			t.Fatalf("%s: expect permitted=%v, got %v", addr, want, got)
+		}
+	}
+	denyOnly := &ipFilter{deny: deny}
+	if !denyOnly.permits("/tmp/geerpc.sock") || !denyOnly.permits("8.8.8.8:53") || denyOnly.permits("10.1.0.1:53") {
+		t.Fatal("expect a deny-only filter to permit everything outside the deny list")
+	}
+	if _, err := parseCIDRs([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("expect an error for an invalid CIDR")
+	}
+}
+
+// callFiltered 启动一个使用给定地址过滤的服务器，并从本地回环地址发起一次调用
+func callFiltered(t *testing.T, allow, deny []string) error {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	if err := server.SetIPFilter(allow, deny); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.Accept(l)
+
+	client, err := Dial("tcp", l.Addr().String(), &Option{ConnectTimeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reply string
+	return client.Call(ctx, "Bar.RequestID", 1, &reply)
+}
+
+func TestServer_SetIPFilter(t *testing.T) {
+	if err := callFiltered(t, nil, []string{"127.0.0.0/8"}); err == nil {
+		t.Fatal("expect a denied address to be rejected")
+	}
+	if err := callFiltered(t, []string{"127.0.0.1"}, nil); err != nil {
+		t.Fatalf("expect an allowed address to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"geerpc/codec"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JSON-RPC 2.0 规范定义的错误码
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcServerError    = -32000 // 服务方法返回的错误，以及认证、授权和配额检查失败
+)
+
+// jsonrpcRequest 是 JSON-RPC 2.0 的请求对象，ID 为 nil 表示通知，不需要响应
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// jsonrpcError 是 JSON-RPC 2.0 的错误对象
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// jsonrpcResponse 是 JSON-RPC 2.0 的响应对象，Result 和 Error 只有一个被设置
+type jsonrpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// SetJSONRPC 设置是否接受 JSON-RPC 2.0 请求，默认关闭。开启后：
+//   - 连接上的第一个 JSON 值带有 "jsonrpc" 成员（或是批量请求的数组）时，不再要求 Option 握手，
+//     之后连接上的每个 JSON 值都作为 JSON-RPC 请求处理；
+//   - ServeHTTP 接受 POST 请求，请求体是一个 JSON-RPC 请求或批量请求，Authorization: Bearer 头作为单次调用的凭证。
+//
+// method 形如 "Service.Method"，params 可以是参数对象本身，也可以是只含一个参数的数组。
+// 请求同样经过认证、授权、配额和拦截器；要求签名或加密的服务器不接受 JSON-RPC 连接。应在开始服务之前调用
+func (server *Server) SetJSONRPC(enable bool) {
+	server.jsonrpc = enable
+}
+
+// isJSONRPC 判断握手时读到的第一个 JSON 值是否是 JSON-RPC 请求
+func isJSONRPC(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		return true
+	}
+	var probe struct {
+		Version string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.Version != ""
+}
+
+// checkJSONRPC 检查服务器是否可以接受 JSON-RPC 请求
+func (server *Server) checkJSONRPC() error {
+	if !server.jsonrpc {
+		return errors.New("rpc server: json-rpc not enabled")
+	}
+	return server.checkHandshakeless()
+}
+
+// handshakelessMessageSize 返回没有 Option 握手的请求（JSON-RPC 和 gRPC）中单个消息的最大字节数，0 表示不限制
+func (server *Server) handshakelessMessageSize() int64 {
+	if server.decodeLimits == nil || server.decodeLimits.MaxMessageSize == 0 {
+		return codec.DefaultMaxMessageSize
+	}
+	if server.decodeLimits.MaxMessageSize < 0 {
+		return 0
+	}
+	return int64(server.decodeLimits.MaxMessageSize)
+}
+
+// messageLimitReader 限制连接上单个消息的字节数，每解码完一个消息后调用 reset
+type messageLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64 // 0 表示不限制
+}
+
+func (l *messageLimitReader) Read(p []byte) (int, error) {
+	if l.limit > 0 {
+		if l.n >= l.limit {
+			return 0, codec.ErrMessageTooLarge
+		}
+		if int64(len(p)) > l.limit-l.n {
+			p = p[:l.limit-l.n]
+		}
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+func (l *messageLimitReader) reset() { l.n = 0 }
+
+// serveJSONRPC 在连接上处理 JSON-RPC 消息，first 是握手时已经读取的第一个消息，rest 是之后的数据
+func (server *Server) serveJSONRPC(conn *connTracker, w io.Writer, first json.RawMessage, rest io.Reader) {
+	lr := &messageLimitReader{r: rest, limit: server.handshakelessMessageSize()}
+	dec := json.NewDecoder(lr)
+	enc := json.NewEncoder(w)
+	sending := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	send := func(resp interface{}) {
+		sending.Lock()
+		defer sending.Unlock()
+		if err := enc.Encode(resp); err != nil {
+			server.log().Error("rpc server: write json-rpc response error", "err", err)
+		}
+	}
+	raw := first
+	for {
+		wg.Add(1)
+		go func(raw json.RawMessage) {
+			defer wg.Done()
+			if resp := server.handleJSONRPC(context.Background(), raw, conn, ""); resp != nil {
+				send(resp)
+			}
+		}(raw)
+		raw = nil
+		lr.reset()
+		if err := dec.Decode(&raw); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				server.log().Error("rpc server: read json-rpc error", "err", err)
+				if _, ok := err.(*json.SyntaxError); ok {
+					send(jsonrpcFailure(nil, jsonrpcParseError, err.Error()))
+				}
+			}
+			break
+		}
+	}
+	wg.Wait()
+}
+
+// serveJSONRPCHTTP 处理 POST 请求体中的 JSON-RPC 消息
+func (server *Server) serveJSONRPCHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := server.checkJSONRPC(); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	body := io.Reader(req.Body)
+	if limit := server.handshakelessMessageSize(); limit > 0 {
+		body = http.MaxBytesReader(w, req.Body, limit)
+	}
+	var raw json.RawMessage
+	var resp interface{}
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		resp = jsonrpcFailure(nil, jsonrpcParseError, err.Error())
+	} else {
+		var token string
+		if auth := req.Header.Get("Authorization"); len(auth) > 7 && auth[:7] == "Bearer " {
+			token = auth[7:]
+		}
+		// HTTP 请求没有连接级别的身份，使用一个不登记的 connTracker 承载客户端地址
+		resp = server.handleJSONRPC(req.Context(), raw, &connTracker{remote: req.RemoteAddr}, token)
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent) // 只包含通知
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handleJSONRPC 处理一个 JSON-RPC 消息（单个请求或批量请求），没有需要返回的响应时返回 nil
+func (server *Server) handleJSONRPC(ctx context.Context, raw json.RawMessage, conn *connTracker, token string) interface{} {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '[' {
+		if resp := server.handleJSONRPCRequest(ctx, raw, conn, token); resp != nil {
+			return resp
+		}
+		return nil
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return jsonrpcFailure(nil, jsonrpcParseError, err.Error())
+	}
+	if len(batch) == 0 {
+		return jsonrpcFailure(nil, jsonrpcInvalidRequest, "empty batch")
+	}
+	resps := make([]*jsonrpcResponse, len(batch))
+	var wg sync.WaitGroup
+	for i := range batch {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i] = server.handleJSONRPCRequest(ctx, batch[i], conn, token)
+		}(i)
+	}
+	wg.Wait()
+	var out []*jsonrpcResponse
+	for _, resp := range resps {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// handleJSONRPCRequest 处理单个 JSON-RPC 请求，请求是通知时返回 nil
+func (server *Server) handleJSONRPCRequest(ctx context.Context, raw json.RawMessage, conn *connTracker, token string) *jsonrpcResponse {
+	var r jsonrpcRequest
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return jsonrpcFailure(nil, jsonrpcInvalidRequest, err.Error())
+	}
+	if r.Version != "2.0" || r.Method == "" {
+		return jsonrpcFailure(r.ID, jsonrpcInvalidRequest, "invalid request")
+	}
+	atomic.AddUint64(&conn.requests, 1)
+	resp := server.callJSONRPC(ctx, &r, conn, token)
+	if r.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+// callJSONRPC 调用请求的方法并生成响应
+func (server *Server) callJSONRPC(ctx context.Context, r *jsonrpcRequest, conn *connTracker, token string) *jsonrpcResponse {
+	reply, stage, err := server.directCall(ctx, r.Method, newRequestID(), token, conn, func(argvi interface{}) error {
+		return decodeJSONRPCParams(r.Params, argvi)
+	})
+	if err != nil {
+		code := jsonrpcServerError
+		switch stage {
+		case callNotFound:
+			code = jsonrpcMethodNotFound
+		case callBadArgs:
+			code = jsonrpcInvalidParams
+		}
+		return jsonrpcFailure(r.ID, code, err.Error())
+	}
+	result, err := json.Marshal(reply)
+	if err != nil {
+		return jsonrpcFailure(r.ID, jsonrpcServerError, err.Error())
+	}
+	return &jsonrpcResponse{Version: "2.0", Result: result, ID: r.ID}
+}
+
+// decodeJSONRPCParams 将 params 解码到方法参数中：params 可以是参数本身，也可以是只含一个参数的数组，省略时使用零值
+func decodeJSONRPCParams(params json.RawMessage, argvi interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if t := reflect.TypeOf(argvi).Elem(); params[0] == '[' && t.Kind() != reflect.Slice &&
+		!(t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice) {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return err
+		}
+		switch len(positional) {
+		case 0:
+			return nil
+		case 1:
+			params = positional[0]
+		default:
+			return errors.New("rpc server: expect at most one positional parameter")
+		}
+	}
+	return json.Unmarshal(params, argvi)
+}
+
+// 直接调用（JSON-RPC 和 gRPC 等不经过 Codec 的请求）失败的阶段
+const (
+	callNotFound = iota + 1 // 找不到服务或方法
+	callBadArgs             // 参数无法解码或超出解码限制
+	callRejected            // 认证、授权或配额检查失败
+	callFailed              // 服务方法返回错误，或者 ctx 在方法返回之前结束
+)
+
+// directCall 按照与 serveCodec 相同的流程（认证、授权、配额和拦截器）调用 serviceMethod，
+// decode 将请求参数解码到 argvi（总是指针）中。ctx 结束时不再等待方法返回。失败时同时返回失败的阶段
+func (server *Server) directCall(ctx context.Context, serviceMethod, requestID, token string, conn *connTracker,
+	decode func(argvi interface{}) error) (reply interface{}, stage int, err error) {
+	req := &request{
+		h:      &codec.Header{ServiceMethod: serviceMethod, RequestID: requestID, Token: token},
+		remote: conn.remote,
+		token:  token,
+	}
+	req.svc, req.mtype, err = server.findService(serviceMethod)
+	if err != nil {
+		return nil, callNotFound, err
+	}
+	req.argv = req.mtype.newArgv()
+	req.replyv = req.mtype.newReplyv()
+	argvi := req.argv.Interface()
+	if req.argv.Type().Kind() != reflect.Ptr {
+		argvi = req.argv.Addr().Interface()
+	}
+	err = decode(argvi)
+	if err == nil && server.decodeLimits != nil {
+		err = server.decodeLimits.Check(argvi)
+	}
+	if err != nil {
+		return nil, callBadArgs, err
+	}
+	err = server.authenticate(req, conn)
+	if err == nil {
+		err = server.authorize(req)
+	}
+	if err == nil {
+		err = server.acquireQuota(req)
+	}
+	if err != nil {
+		return nil, callRejected, err
+	}
+
+	atomic.AddInt64(&server.inflight, 1)
+	defer atomic.AddInt64(&server.inflight, -1)
+	start := time.Now()
+	ctx = WithRequestID(ctx, requestID)
+	if req.identity != "" {
+		ctx = WithIdentity(ctx, req.identity)
+	}
+	called := make(chan error, 1)
+	go func() {
+		err := server.invoke(ctx, req)
+		if req.release != nil {
+			req.release() // ctx 结束后方法仍在执行，直到返回才释放并发配额
+		}
+		called <- err
+	}()
+	select {
+	case err = <-called:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+	server.finishRequest(req, start, errMsg)
+	if err != nil {
+		return nil, callFailed, err
+	}
+	return req.replyv.Interface(), 0, nil
+}
+
+// jsonrpcFailure 返回一个错误响应
+func jsonrpcFailure(id json.RawMessage, code int, msg string) *jsonrpcResponse {
+	return &jsonrpcResponse{Version: "2.0", Error: &jsonrpcError{Code: code, Message: msg}, ID: id}
+}
@@ -0,0 +1,141 @@
+package geerpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Calc int
+
+func (Calc) Div(args Args, reply *int) error {
+	if args.Num2 == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.Num1 / args.Num2
+	return nil
+}
+
+// newCalcServer 返回注册了 Foo 和 Calc 的服务器
+func newCalcServer(jsonrpc bool) *Server {
+	server := NewServer()
+	server.SetConnRateLimit(0, 0)
+	server.SetJSONRPC(jsonrpc)
+	_ = server.Register(new(Foo))
+	_ = server.Register(new(Calc))
+	return server
+}
+
+// jsonrpcReply 是测试中解码的 JSON-RPC 响应
+type jsonrpcReply struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *jsonrpcError   `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func TestServer_JSONRPCConn(t *testing.T) {
+	server := newCalcServer(true)
+	cliConn, srvConn := net.Pipe()
+	go server.ServeConn(srvConn)
+	defer func() { _ = cliConn.Close() }()
+	_ = cliConn.SetDeadline(time.Now().Add(time.Second))
+	dec := json.NewDecoder(bufio.NewReader(cliConn))
+	send := func(msg string) {
+		t.Helper()
+		if _, err := cliConn.Write([]byte(msg + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 没有 Option 握手，第一个 JSON 值就是请求；params 可以是参数本身或只含一个参数的数组
+	send(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":2},"id":1}`)
+	var resp jsonrpcReply
+	if err := dec.Decode(&resp); err != nil || resp.Version != "2.0" || string(resp.ID) != "1" || string(resp.Result) != "3" {
+		t.Fatalf("expect result 3 for id 1, got %+v, err %v", resp, err)
+	}
+	send(`{"jsonrpc":"2.0","method":"Foo.Sum","params":[{"Num1":2,"Num2":3}],"id":"two"}`)
+	resp = jsonrpcReply{}
+	if err := dec.Decode(&resp); err != nil || string(resp.ID) != `"two"` || string(resp.Result) != "5" {
+		t.Fatalf("expect result 5 for positional params, got %+v, err %v", resp, err)
+	}
+
+	// 批量请求中的通知没有响应，每个失败的请求带有对应的错误码
+	send(`[{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":1}},` +
+		`{"jsonrpc":"2.0","method":"Foo.Missing","id":3},` +
+		`{"jsonrpc":"2.0","method":"Calc.Div","params":{"Num1":1,"Num2":0},"id":4},` +
+		`{"jsonrpc":"2.0","method":"Foo.Sum","params":"bad","id":5},` +
+		`{"jsonrpc":"1.0","method":"Foo.Sum","id":6}]`)
+	var batch []jsonrpcReply
+	if err := dec.Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"3": jsonrpcMethodNotFound, "4": jsonrpcServerError, "5": jsonrpcInvalidParams, "6": jsonrpcInvalidRequest}
+	if len(batch) != len(want) {
+		t.Fatalf("expect %d responses without the notification, got %d", len(want), len(batch))
+	}
+	for _, r := range batch {
+		if r.Error == nil || r.Error.Code != want[string(r.ID)] {
+			t.Fatalf("expect error code %d for id %s, got %+v", want[string(r.ID)], r.ID, r.Error)
+		}
+	}
+
+	// 无法解析的消息得到 Parse error 响应，连接随后关闭
+	send(`{"jsonrpc":`)
+	send(`}`)
+	resp = jsonrpcReply{}
+	if err := dec.Decode(&resp); err != nil || resp.Error == nil || resp.Error.Code != jsonrpcParseError {
+		t.Fatalf("expect a parse error, got %+v, err %v", resp, err)
+	}
+}
+
+func TestServer_JSONRPCHTTP(t *testing.T) {
+	ts := httptest.NewServer(newCalcServer(true))
+	defer ts.Close()
+	post := func(body string) (*http.Response, jsonrpcReply) {
+		t.Helper()
+		resp, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var r jsonrpcReply
+		if resp.StatusCode == http.StatusOK {
+			_ = json.NewDecoder(resp.Body).Decode(&r)
+		}
+		return resp, r
+	}
+
+	resp, r := post(`{"jsonrpc":"2.0","method":"Calc.Div","params":{"Num1":9,"Num2":3},"id":7}`)
+	if resp.StatusCode != http.StatusOK || string(r.Result) != "3" || string(r.ID) != "7" {
+		t.Fatalf("expect result 3, got %d %+v", resp.StatusCode, r)
+	}
+	// 只包含通知的请求没有响应体
+	if resp, _ := post(`{"jsonrpc":"2.0","method":"Foo.Sum","params":{"Num1":1,"Num2":1}}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expect 204 for a notification, got %d", resp.StatusCode)
+	}
+	if _, r := post(`not json`); r.Error == nil || r.Error.Code != jsonrpcParseError {
+		t.Fatalf("expect a parse error, got %+v", r)
+	}
+	if _, r := post(`[]`); r.Error == nil || r.Error.Code != jsonrpcInvalidRequest {
+		t.Fatalf("expect an invalid request error for an empty batch, got %+v", r)
+	}
+
+	// 未开启 SetJSONRPC 时拒绝 JSON-RPC 请求
+	disabled := httptest.NewServer(newCalcServer(false))
+	defer disabled.Close()
+	resp, err := http.Post(disabled.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"Foo.Sum","id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("expect JSON-RPC to be rejected when it is not enabled")
+	}
+}
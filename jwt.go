@@ -0,0 +1,137 @@
+package geerpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// JWTValidator 是验证 HS256 签名的 JWT 的 TokenValidator，返回的身份为 sub 声明
+type JWTValidator struct {
+	Secret   []byte        // HMAC 密钥
+	Issuer   string        // 不为空时要求 iss 声明与之相等
+	Audience string        // 不为空时要求 aud 声明包含它
+	Leeway   time.Duration // 检查 exp 和 nbf 时允许的时钟偏差
+}
+
+// NewJWTValidator 创建一个 JWTValidator 实例
+func NewJWTValidator(secret []byte, issuer, audience string) *JWTValidator {
+	return &JWTValidator{Secret: secret, Issuer: issuer, Audience: audience}
+}
+
+// Validate 验证 JWT 的签名和声明，返回 sub 声明
+func (v *JWTValidator) Validate(token string) (string, error) {
+	header, claims, signingInput, sig, err := parseJWT(token)
+	if err != nil {
+		return "", err
+	}
+	if header.Alg != "HS256" {
+		return "", errors.New("rpc jwt: unsupported algorithm " + header.Alg)
+	}
+	mac := hmac.New(sha256.New, v.Secret)
+	_, _ = mac.Write([]byte(signingInput))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errors.New("rpc jwt: invalid signature")
+	}
+	if err := claims.verify(v.Issuer, v.Audience, v.Leeway, time.Now()); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+// jwtHeader 是 JWT 的头部
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// jwtClaims 是 JWT 的标准声明，Raw 保存所有声明
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  jwtAudience     `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	Raw       json.RawMessage `json:"-"`
+}
+
+// jwtAudience 兼容 aud 声明为字符串或字符串数组两种形式
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = jwtAudience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// verify 检查签发者、受众和有效期
+func (c *jwtClaims) verify(issuer, audience string, leeway time.Duration, now time.Time) error {
+	if issuer != "" && c.Issuer != issuer {
+		return errors.New("rpc jwt: unexpected issuer " + c.Issuer)
+	}
+	if audience != "" {
+		found := false
+		for _, aud := range c.Audience {
+			if aud == audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("rpc jwt: audience mismatch")
+		}
+	}
+	if c.ExpiresAt != 0 && now.Add(-leeway).Unix() >= c.ExpiresAt {
+		return errors.New("rpc jwt: token expired")
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Unix() < c.NotBefore {
+		return errors.New("rpc jwt: token not valid yet")
+	}
+	return nil
+}
+
+// parseJWT 解析 JWT 的三个部分，不验证签名
+func parseJWT(token string) (header jwtHeader, claims jwtClaims, signingInput string, sig []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = errors.New("rpc jwt: malformed token")
+		return
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		err = errors.New("rpc jwt: malformed header")
+		return
+	}
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		err = errors.New("rpc jwt: malformed header")
+		return
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		err = errors.New("rpc jwt: malformed claims")
+		return
+	}
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		err = errors.New("rpc jwt: malformed claims")
+		return
+	}
+	claims.Raw = claimsJSON
+	if sig, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		err = errors.New("rpc jwt: malformed signature")
+		return
+	}
+	signingInput = parts[0] + "." + parts[1]
+	return
+}
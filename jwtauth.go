@@ -0,0 +1,296 @@
+package geerpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Claims 是 JWT 中的所有声明，由 JWTAuth 注入到服务方法的 context 中
+type Claims map[string]interface{}
+
+// String 返回字符串类型的声明，不存在或不是字符串时返回空字符串
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Subject 返回 sub 声明
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+type claimsKey struct{}
+
+// WithClaims 返回携带 JWT 声明的 context
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext 返回 context 中的 JWT 声明，调用没有经过 JWTAuth 验证时返回 nil
+func ClaimsFromContext(ctx context.Context) Claims {
+	claims, _ := ctx.Value(claimsKey{}).(Claims)
+	return claims
+}
+
+// 从 JWKS 地址获取公钥的时间间隔
+const (
+	defaultJWKSRefresh = time.Minute * 10 // 定期刷新的间隔
+	minJWKSRefresh     = time.Second * 10 // 遇到未知 kid 时两次刷新之间的最小间隔
+)
+
+// JWTAuth 是验证 JWT 的服务端拦截器，支持 HS256（Secret）以及从 JWKS 地址获取公钥的 RS256 和 ES256。
+// 令牌取自单次调用的凭证（WithCredentials），没有时取握手时的 Option.Credentials。
+// 验证通过后，声明通过 WithClaims 注入服务方法的 context，sub 声明作为调用方身份（IdentityFromContext）。
+// 与 JWTValidator 不同，它在服务方法调用之前执行，各个服务可以直接读取声明而无需各自解析令牌。
+//
+// 拦截器在认证、授权和配额检查之后执行，单独通过 Server.Use 使用时，
+// SetAuthorizer 和 SetQuotas 看不到 sub 声明得到的身份。需要按 JWT 的身份授权或限流时使用 Server.UseJWT
+type JWTAuth struct {
+	Issuer   string        // 不为空时要求 iss 声明与之相等
+	Audience string        // 不为空时要求 aud 声明包含它
+	Leeway   time.Duration // 检查 exp 和 nbf 时允许的时钟偏差
+	Secret   []byte        // 不为 nil 时接受使用该密钥签名的 HS256 令牌
+
+	JWKSURL    string        // 不为空时从该地址获取 RS256 和 ES256 的公钥
+	Refresh    time.Duration // 定期刷新 JWKS 的间隔，为 0 时使用 10 分钟，遇到未知的 kid 时也会刷新
+	HTTPClient *http.Client  // 为 nil 时使用超时为 10 秒的客户端
+
+	// Skip 不为 nil 时，返回 true 的方法不要求令牌，例如 "Health.Check"。通过 Server.UseJWT 使用时不生效
+	Skip func(serviceMethod string) bool
+
+	logger func() Logger // 由 Server.UseJWT 设置为返回服务器的 Logger，为 nil 时使用 DefaultLogger
+
+	mu      sync.Mutex // 保护以下字段
+	keys    map[string]crypto.PublicKey
+	fetched time.Time // 上一次成功获取 JWKS 的时间
+	tried   time.Time // 上一次尝试获取 JWKS 的时间
+}
+
+// UseJWT 使用 a 验证 JWT：a 同时作为服务器的 TokenValidator（参见 SetTokenValidator），
+// 在认证阶段验证令牌并以 sub 声明作为调用方身份，使 SetAuthorizer 和 SetQuotas 按 JWT 的身份生效；
+// 并追加 a.Interceptor()，将声明注入服务方法的 context。a 的日志输出到服务器的 Logger。
+// 认证阶段要求每个调用都携带有效的令牌，因此 a.Skip 不再生效。应在开始服务之前调用
+func (server *Server) UseJWT(a *JWTAuth) {
+	a.logger = server.log
+	server.SetTokenValidator(TokenValidatorFunc(func(token string) (string, error) {
+		claims, err := a.Validate(token)
+		if err != nil {
+			return "", err
+		}
+		return claims.Subject(), nil
+	}))
+	server.Use(a.Interceptor())
+}
+
+// log 返回 a 使用的 Logger
+func (a *JWTAuth) log() Logger {
+	if a.logger != nil {
+		return a.logger()
+	}
+	return DefaultLogger()
+}
+
+// Interceptor 返回验证 JWT 的 ServerInterceptor，没有有效令牌的调用返回 ErrUnauthenticated
+func (a *JWTAuth) Interceptor() ServerInterceptor {
+	return func(ctx context.Context, info *CallInfo, args, reply interface{}, next Handler) error {
+		if a.Skip != nil && a.Skip(info.ServiceMethod) {
+			return next(ctx, info, args, reply)
+		}
+		claims, err := a.Validate(info.Token)
+		if err != nil {
+			a.log().Warn("rpc server: invalid jwt", "method", info.ServiceMethod, "remote", info.Remote, "err", err)
+			return ErrUnauthenticated
+		}
+		info.Identity = claims.Subject()
+		ctx = WithIdentity(WithClaims(ctx, claims), info.Identity)
+		return next(ctx, info, args, reply)
+	}
+}
+
+// Validate 验证令牌的签名和声明，返回所有声明
+func (a *JWTAuth) Validate(token string) (Claims, error) {
+	if token == "" {
+		return nil, errors.New("rpc jwt: missing token")
+	}
+	header, claims, signingInput, sig, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.verifySignature(header, signingInput, sig); err != nil {
+		return nil, err
+	}
+	if err := claims.verify(a.Issuer, a.Audience, a.Leeway, time.Now()); err != nil {
+		return nil, err
+	}
+	var all Claims
+	if err := json.Unmarshal(claims.Raw, &all); err != nil {
+		return nil, errors.New("rpc jwt: malformed claims")
+	}
+	return all, nil
+}
+
+// verifySignature 根据令牌头部的算法验证签名
+func (a *JWTAuth) verifySignature(header jwtHeader, signingInput string, sig []byte) error {
+	if header.Alg == "HS256" {
+		if a.Secret == nil {
+			return errors.New("rpc jwt: unsupported algorithm HS256")
+		}
+		mac := hmac.New(sha256.New, a.Secret)
+		_, _ = mac.Write([]byte(signingInput))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("rpc jwt: invalid signature")
+		}
+		return nil
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return errors.New("rpc jwt: unsupported algorithm " + header.Alg)
+	}
+	if a.JWKSURL == "" {
+		return errors.New("rpc jwt: unsupported algorithm " + header.Alg)
+	}
+	key, err := a.publicKey(header.Kid)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg == "ES256" && len(sig) == 64 {
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.New("rpc jwt: invalid signature")
+}
+
+// publicKey 返回 kid 对应的公钥，JWKS 过期或找不到 kid 时重新获取。
+// kid 为空且 JWKS 中只有一个公钥时使用该公钥
+func (a *JWTAuth) publicKey(kid string) (crypto.PublicKey, error) {
+	refresh := a.Refresh
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	now := time.Now()
+	a.mu.Lock()
+	key, ok := a.lookupKey(kid)
+	fetch := (!ok || now.Sub(a.fetched) > refresh) && now.Sub(a.tried) >= minJWKSRefresh
+	if fetch {
+		a.tried = now
+	}
+	a.mu.Unlock()
+	if fetch {
+		// 在锁外获取 JWKS，缓慢的 JWKS 地址不会阻塞其他调用，它们在获取期间继续使用已有的公钥
+		keys, err := a.fetchJWKS()
+		if err != nil {
+			a.log().Error("rpc jwt: fetch jwks err", "url", a.JWKSURL, "err", err)
+		} else {
+			a.mu.Lock()
+			a.keys, a.fetched = keys, now
+			key, ok = a.lookupKey(kid)
+			a.mu.Unlock()
+		}
+	}
+	if !ok {
+		return nil, errors.New("rpc jwt: unknown key id " + kid)
+	}
+	return key, nil
+}
+
+// lookupKey 在已获取的公钥中查找 kid，调用方需持有 a.mu
+func (a *JWTAuth) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+// jwk 是 JWKS 中的一个公钥，只解析 RSA 和 P-256 椭圆曲线公钥需要的字段
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS 从 JWKS 地址获取公钥，无法解析的公钥会被跳过
+func (a *JWTAuth) fetchJWKS() (map[string]crypto.PublicKey, error) {
+	client := a.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 10}
+	}
+	resp, err := client.Get(a.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("rpc jwt: jwks endpoint returned " + resp.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key := k.publicKey(); key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey 将 JWK 转换为公钥，不支持或无效时返回 nil
+func (k jwk) publicKey() crypto.PublicKey {
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	switch k.Kty {
+	case "RSA":
+		n, e := decode(k.N), decode(k.E)
+		if n == nil || e == nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		x, y := decode(k.X), decode(k.Y)
+		if k.Crv != "P-256" || x == nil || y == nil || !elliptic.P256().IsOnCurve(x, y) {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package geerpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signJWT 使用 key 生成 RS256 或 ES256 签名的 JWT
+func signJWT(t *testing.T, key crypto.Signer, kid string, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rb):32], rb)
+		copy(sig[64-len(sb):], sb)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// jwksServer 是一个可以替换公钥的 JWKS 地址
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+	delay   time.Duration
+}
+
+func newJWKSServer() *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		keys, delay := s.keys, s.delay
+		s.fetches++
+		s.mu.Unlock()
+		time.Sleep(delay)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	return s
+}
+
+// set 替换 JWKS 中的公钥
+func (s *jwksServer) set(kid string, key crypto.PublicKey) {
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	var k jwk
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		k = jwk{Kty: "RSA", Kid: kid, N: enc(key.N.Bytes()), E: enc(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		k = jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: enc(key.X.Bytes()), Y: enc(key.Y.Bytes())}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = []jwk{k}
+}
+
+func TestJWTAuth_Validate(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := newJWKSServer()
+	defer jwks.Close()
+	jwks.set("rsa-1", &rsaKey.PublicKey)
+
+	a := &JWTAuth{Issuer: "issuer", Audience: "geerpc", Secret: []byte("secret"), JWKSURL: jwks.URL, Leeway: time.Second}
+	now := time.Now().Unix()
+	claims := map[string]interface{}{"iss": "issuer", "sub": "alice", "aud": []string{"other", "geerpc"}, "exp": now + 60, "nbf": now - 60}
+
+	if c, err := a.Validate(signHS256([]byte("secret"), claims)); err != nil || c.Subject() != "alice" {
+		t.Fatalf("HS256: expect subject alice, got %v, err %v", c, err)
+	}
+	if c, err := a.Validate(signJWT(t, rsaKey, "rsa-1", claims)); err != nil || c.Subject() != "alice" {
+		t.Fatalf("RS256: expect subject alice, got %v, err %v", c, err)
+	}
+	// RS256 的公钥不能验证 ES256 令牌，即使 kid 相同
+	if _, err := a.Validate(signJWT(t, ecKey, "rsa-1", claims)); err == nil {
+		t.Fatal("expect an ES256 token to be rejected by an RSA key")
+	}
+
+	cases := map[string]map[string]interface{}{
+		"expired":      {"iss": "issuer", "sub": "alice", "aud": "geerpc", "exp": now - 60},
+		"not yet":      {"iss": "issuer", "sub": "alice", "aud": "geerpc", "nbf": now + 60},
+		"wrong aud":    {"iss": "issuer", "sub": "alice", "aud": []string{"a", "b"}},
+		"wrong issuer": {"iss": "other", "sub": "alice", "aud": "geerpc"},
+	}
+	for name, c := range cases {
+		if _, err := a.Validate(signJWT(t, rsaKey, "rsa-1", c)); err == nil {
+			t.Fatalf("%s: expect the token to be rejected", name)
+		}
+	}
+	// exp 在允许的时钟偏差内时仍然有效
+	if _, err := a.Validate(signHS256([]byte("secret"), map[string]interface{}{"iss": "issuer", "sub": "alice", "aud": "geerpc", "exp": now})); err != nil {
+		t.Fatalf("expect exp within the leeway to be accepted, got %v", err)
+	}
+
+	// 密钥轮换：遇到未知的 kid 时重新获取 JWKS
+	jwks.set("ec-2", &ecKey.PublicKey)
+	a.mu.Lock()
+	a.tried = time.Time{} // 跳过两次获取之间的最小间隔
+	a.mu.Unlock()
+	if c, err := a.Validate(signJWT(t, ecKey, "ec-2", claims)); err != nil || c.Subject() != "alice" {
+		t.Fatalf("ES256: expect subject alice after refreshing the JWKS, got %v, err %v", c, err)
+	}
+	// 最小间隔内未知的 kid 不会再次触发获取
+	jwks.mu.Lock()
+	before := jwks.fetches
+	jwks.mu.Unlock()
+	if _, err := a.Validate(signJWT(t, ecKey, "unknown", claims)); err == nil {
+		t.Fatal("expect an unknown kid to be rejected")
+	}
+	jwks.mu.Lock()
+	after := jwks.fetches
+	jwks.mu.Unlock()
+	if after != before {
+		t.Fatalf("expect no JWKS fetch within the minimum interval, got %d", after-before)
+	}
+}
+
+func TestJWTAuth_FetchOutsideLock(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := newJWKSServer()
+	defer jwks.Close()
+	jwks.set("ec-1", &ecKey.PublicKey)
+	a := &JWTAuth{JWKSURL: jwks.URL}
+	token := signJWT(t, ecKey, "ec-1", map[string]interface{}{"sub": "alice"})
+	if _, err := a.Validate(token); err != nil {
+		t.Fatal(err)
+	}
+
+	// JWKS 到期后由一个调用缓慢地刷新，其他调用继续使用已有的公钥，不必等待
+	jwks.mu.Lock()
+	jwks.delay = 500 * time.Millisecond
+	jwks.mu.Unlock()
+	a.mu.Lock()
+	a.fetched, a.tried = time.Time{}, time.Time{}
+	a.mu.Unlock()
+	go func() { _, _ = a.Validate(token) }()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := a.Validate(token); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("expect validation not to wait for the JWKS fetch, took %v", d)
+	}
+}
+
+func TestServer_UseJWT(t *testing.T) {
+	server := NewServer()
+	var w Who
+	_ = server.Register(&w)
+	server.UseJWT(&JWTAuth{Secret: []byte("secret")})
+	server.SetAuthorizer(NewACL(ACLRule{Identity: "alice", Allow: []string{"Who.*"}}))
+	server.SetQuotas(Quota{}, map[string]Quota{"alice": {RequestsPerSecond: 1}})
+
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	call := func(sub string) (string, error) {
+		var identity string
+		ctx := WithCredentials(context.Background(), signHS256([]byte("secret"), map[string]interface{}{"sub": sub}))
+		err := client.Call(ctx, "Who.Identity", 1, &identity)
+		return identity, err
+	}
+	// ACL 和配额看到的是 JWT 的 sub 声明
+	if identity, err := call("alice"); err != nil || identity != "alice" {
+		t.Fatalf("expect identity alice, got %q, err %v", identity, err)
+	}
+	if _, err := call("alice"); err == nil || err.Error() != ErrQuotaExceeded.Error() {
+		t.Fatalf("expect alice's quota to apply, got %v", err)
+	}
+	if _, err := call("bob"); err == nil || err.Error() != ErrPermissionDenied.Error() {
+		t.Fatalf("expect the ACL to deny bob, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package geerpc
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxLockoutSources 是记录认证失败的来源数量上限，超过后清理已过期的记录
+const maxLockoutSources = 10000
+
+// authLockout 按来源地址记录认证失败的次数，失败过多的来源会被暂时拒绝
+type authLockout struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	ban         time.Duration
+	sources     map[string]*authFailures
+}
+
+// authFailures 是一个来源的认证失败记录
+type authFailures struct {
+	count       int
+	first       time.Time // 当前统计窗口内第一次失败的时间
+	bannedUntil time.Time
+}
+
+// SetAuthLockout 设置认证失败的锁定策略：同一来源 IP 在 window 内认证失败（握手凭证或单次调用的凭证无效）
+// 达到 maxFailures 次后，在 ban 到 1.5*ban 之间的随机时长内被拒绝，随机化使攻击者难以精确地安排重试。
+// 被锁定的来源的新连接直接被关闭，已有连接上携带凭证的调用返回 ErrUnauthenticated。
+// 锁定时发布 SourceLockedOut 事件，被拒绝的连接发布 ConnRejected 事件。maxFailures <= 0（默认）表示不锁定，应在开始服务之前调用
+func (server *Server) SetAuthLockout(maxFailures int, window, ban time.Duration) {
+	server.lockout.mu.Lock()
+	defer server.lockout.mu.Unlock()
+	server.lockout.maxFailures = maxFailures
+	server.lockout.window = window
+	server.lockout.ban = ban
+	server.lockout.sources = make(map[string]*authFailures)
+}
+
+// sourceHost 返回地址中的主机部分
+func sourceHost(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// locked 判断来源当前是否被锁定
+func (l *authLockout) locked(remote string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxFailures <= 0 {
+		return false
+	}
+	f := l.sources[sourceHost(remote)]
+	return f != nil && time.Now().Before(f.bannedUntil)
+}
+
+// fail 记录一次认证失败，来源因此被锁定时返回失败次数和锁定的截止时间
+func (l *authLockout) fail(remote string) (failures int, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxFailures <= 0 {
+		return 0, time.Time{}
+	}
+	now := time.Now()
+	host := sourceHost(remote)
+	f := l.sources[host]
+	if f == nil || now.Sub(f.first) > l.window && now.After(f.bannedUntil) {
+		if f == nil && len(l.sources) >= maxLockoutSources {
+			l.prune(now)
+		}
+		f = &authFailures{first: now}
+		l.sources[host] = f
+	}
+	f.count++
+	if f.count < l.maxFailures || now.Before(f.bannedUntil) {
+		return 0, time.Time{}
+	}
+	ban := l.ban
+	if ban > 0 {
+		ban += time.Duration(rand.Int63n(int64(ban)/2 + 1))
+	}
+	f.bannedUntil = now.Add(ban)
+	failures = f.count
+	f.count, f.first = 0, f.bannedUntil
+	return failures, f.bannedUntil
+}
+
+// succeed 在来源认证成功后清除其失败记录
+func (l *authLockout) succeed(remote string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f := l.sources[sourceHost(remote)]; f != nil && !time.Now().Before(f.bannedUntil) {
+		delete(l.sources, sourceHost(remote))
+	}
+}
+
+// prune 清理已经过期的记录，调用方需持有 l.mu
+func (l *authLockout) prune(now time.Time) {
+	for host, f := range l.sources {
+		if now.Sub(f.first) > l.window && now.After(f.bannedUntil) {
+			delete(l.sources, host)
+		}
+	}
+}
+
+// authFailed 记录来源的一次认证失败，来源因此被锁定时记录日志并发布 SourceLockedOut 事件
+func (server *Server) authFailed(remote string) {
+	failures, until := server.lockout.fail(remote)
+	if failures == 0 {
+		return
+	}
+	server.log().Warn("rpc server: source locked out after repeated auth failures",
+		"remote", sourceHost(remote), "failures", failures, "until", until.Format(time.RFC3339))
+	server.events.Publish(SourceLockedOut{Time: time.Now(), Remote: sourceHost(remote), Failures: failures, Until: until})
+}
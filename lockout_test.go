@@ -0,0 +1,68 @@
+package geerpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAuthLockout(t *testing.T) {
+	server := NewServer()
+	server.SetAuthLockout(3, time.Minute, 40*time.Millisecond)
+	l := &server.lockout
+
+	for i := 0; i < 2; i++ {
+		if failures, _ := l.fail("10.0.0.1:1000"); failures != 0 {
+			t.Fatalf("expect no lockout after %d failures", i+1)
+		}
+	}
+	if l.locked("10.0.0.1:1000") {
+		t.Fatal("expect the source not to be locked before reaching the limit")
+	}
+	// 同一主机的不同端口计入同一来源
+	failures, until := l.fail("10.0.0.1:2000")
+	if failures != 3 || !l.locked("10.0.0.1:3000") {
+		t.Fatalf("expect the source to be locked after 3 failures, got %d", failures)
+	}
+	if d := time.Until(until); d <= 0 || d > 60*time.Millisecond {
+		t.Fatalf("expect the ban to last between 40ms and 60ms, got %v", d)
+	}
+	if l.locked("10.0.0.2:1000") {
+		t.Fatal("expect other sources not to be locked")
+	}
+	// 锁定期间的成功认证不会解除锁定
+	l.succeed("10.0.0.1:1000")
+	if !l.locked("10.0.0.1:1000") {
+		t.Fatal("expect the lockout to survive a success during the ban")
+	}
+	time.Sleep(70 * time.Millisecond)
+	if l.locked("10.0.0.1:1000") {
+		t.Fatal("expect the lockout to expire after the ban")
+	}
+	if failures, _ := l.fail("10.0.0.1:1000"); failures != 0 {
+		t.Fatal("expect the failure count to restart after the ban")
+	}
+}
+
+func TestServer_SetAuthLockout(t *testing.T) {
+	server := newAuthServer(StaticTokens{"good": "alice"})
+	server.SetAuthLockout(2, time.Minute, 50*time.Millisecond)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	call := func(token string) error {
+		var identity string
+		return client.Call(WithCredentials(context.Background(), token), "Who.Identity", 1, &identity)
+	}
+	_ = call("bad")
+	_ = call("bad")
+	if err := call("good"); err == nil || err.Error() != ErrUnauthenticated.Error() {
+		t.Fatalf("expect valid credentials to be rejected while locked out, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := call("good"); err != nil {
+		t.Fatalf("expect valid credentials to be accepted after the ban, got %v", err)
+	}
+}
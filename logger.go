@@ -0,0 +1,81 @@
+package geerpc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Logger 是 GeeRPC 输出日志使用的接口，可以适配到 zap、slog 等日志库。
+// keyvals 是交替出现的键和值，例如 Error("rpc server: read body err", "err", err)
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// stdLogger 使用标准库的 log 包输出日志，是默认的 Logger，默认输出所有级别的日志
+type stdLogger struct{}
+
+var _ LevelLogger = stdLogger{}
+
+// stdLevel 是 stdLogger 输出日志的最低级别，使用原子操作访问
+var stdLevel int32
+
+func (stdLogger) Debug(msg string, keyvals ...interface{}) { stdLog(LevelDebug, msg, keyvals) }
+func (stdLogger) Info(msg string, keyvals ...interface{})  { stdLog(LevelInfo, msg, keyvals) }
+func (stdLogger) Warn(msg string, keyvals ...interface{})  { stdLog(LevelWarn, msg, keyvals) }
+func (stdLogger) Error(msg string, keyvals ...interface{}) { stdLog(LevelError, msg, keyvals) }
+
+// Level 返回输出日志的最低级别，所有 stdLogger 共用同一个级别
+func (stdLogger) Level() Level { return Level(atomic.LoadInt32(&stdLevel)) }
+
+// SetLevel 修改输出日志的最低级别
+func (stdLogger) SetLevel(level Level) { atomic.StoreInt32(&stdLevel, int32(level)) }
+
+func stdLog(level Level, msg string, keyvals []interface{}) {
+	if level >= Level(atomic.LoadInt32(&stdLevel)) {
+		log.Println(formatLog(msg, keyvals))
+	}
+}
+
+// formatLog 将消息和键值对格式化为 "msg key=value ..." 的形式
+func formatLog(msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			_, _ = fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			_, _ = fmt.Fprintf(&b, " %v", keyvals[i])
+		}
+	}
+	return b.String()
+}
+
+// loggerHolder 使 atomic.Value 中始终保存同一具体类型
+type loggerHolder struct {
+	Logger
+}
+
+var defaultLogger atomic.Value
+
+func init() {
+	defaultLogger.Store(loggerHolder{stdLogger{}})
+}
+
+// SetLogger 设置默认的 Logger，没有单独设置 Logger 的 Server、Client、XClient、
+// 服务发现和注册中心都使用它输出日志。l 为 nil 时恢复为使用标准库 log 包
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdLogger{}
+	}
+	defaultLogger.Store(loggerHolder{l})
+}
+
+// DefaultLogger 返回默认的 Logger
+func DefaultLogger() Logger {
+	return defaultLogger.Load().(loggerHolder).Logger
+}
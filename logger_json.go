@@ -0,0 +1,123 @@
+package geerpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level 是日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String 返回日志级别的名称
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// ParseLevel 将 "debug"、"info"、"warn" 或 "error"（不区分大小写）解析为日志级别
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("rpc: unknown log level %q", s)
+}
+
+// LevelLogger 由可以在运行时调整级别的 Logger 实现，例如 JSONLogger 和默认的 Logger，
+// 管理接口（参见 HandleAdminHTTP）通过它修改日志级别
+type LevelLogger interface {
+	Logger
+	Level() Level
+	SetLevel(level Level)
+}
+
+// JSONLogger 是一个结构化的 Logger，每条日志输出为一行 JSON 对象，
+// 包含 time、level、msg 字段以及所有键值对，日志系统无需正则解析即可直接索引。
+// error 类型的值输出为错误信息，time.Duration 类型的值输出为秒数
+type JSONLogger struct {
+	level int32 // 使用原子操作访问，可以在运行时修改
+
+	mu sync.Mutex // 保证每条日志完整地写入
+	w  io.Writer
+}
+
+var _ LevelLogger = (*JSONLogger)(nil)
+
+// NewJSONLogger 创建一个向 w 输出级别不低于 level 的日志的 JSONLogger
+func NewJSONLogger(w io.Writer, level Level) *JSONLogger {
+	return &JSONLogger{w: w, level: int32(level)}
+}
+
+// Level 返回输出日志的最低级别
+func (l *JSONLogger) Level() Level { return Level(atomic.LoadInt32(&l.level)) }
+
+// SetLevel 修改输出日志的最低级别，可以在运行时调用
+func (l *JSONLogger) SetLevel(level Level) { atomic.StoreInt32(&l.level, int32(level)) }
+
+func (l *JSONLogger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+func (l *JSONLogger) Info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals) }
+func (l *JSONLogger) Warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals) }
+func (l *JSONLogger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+// log 将一条日志编码为 JSON 并写入
+func (l *JSONLogger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.Level() {
+		return
+	}
+	record := map[string]interface{}{
+		"time":  time.Now().Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   msg,
+	}
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 >= len(keyvals) {
+			record["extra"] = jsonValue(keyvals[i])
+			break
+		}
+		record[fmt.Sprint(keyvals[i])] = jsonValue(keyvals[i+1])
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"level": level.String(), "msg": msg, "log_error": err.Error()})
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(data, '\n'))
+}
+
+// jsonValue 将不能直接编码为 JSON 的值转换为合适的形式
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.Seconds()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
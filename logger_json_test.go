@@ -0,0 +1,131 @@
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 是可以并发写入的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records 将输出的每一行解析为 JSON 对象
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expect one JSON object per line, got %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, s := range []string{"debug", "INFO", "Warn", "error"} {
+		l, err := ParseLevel(s)
+		if err != nil || !strings.EqualFold(l.String(), s) {
+			t.Fatalf("expect %s to parse, got %v, %v", s, l, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("expect an error for an unknown level")
+	}
+	if s := Level(9).String(); s != "Level(9)" {
+		t.Fatalf("unexpected name for an unknown level: %s", s)
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf syncBuffer
+	l := NewJSONLogger(&buf, LevelInfo)
+	l.Debug("hidden")
+	l.Info("request", "err", errors.New("boom"), "duration", 1500*time.Millisecond, "want", LevelWarn, "n", 3, "dangling")
+	l.SetLevel(LevelError)
+	l.Warn("hidden")
+	l.Error("unencodable", "ch", make(chan int))
+
+	records := buf.records(t)
+	if len(records) != 2 {
+		t.Fatalf("expect 2 records above the level, got %v", records)
+	}
+	r := records[0]
+	if r["msg"] != "request" || r["level"] != "info" || r["err"] != "boom" || r["duration"] != 1.5 ||
+		r["want"] != "warn" || r["n"] != float64(3) || r["extra"] != "dangling" {
+		t.Fatalf("unexpected record %v", r)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, r["time"].(string)); err != nil {
+		t.Fatalf("expect an RFC 3339 time, got %v", r["time"])
+	}
+	// 键值对无法编码时仍然输出消息和编码错误
+	if r := records[1]; r["msg"] != "unencodable" || r["level"] != "error" || r["log_error"] == nil {
+		t.Fatalf("unexpected record %v", r)
+	}
+}
+
+func TestServer_SetRequestLogging(t *testing.T) {
+	var buf syncBuffer
+	server := NewServer()
+	server.SetLogger(NewJSONLogger(&buf, LevelInfo))
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	server.SetRequestLogging(true)
+	_ = client.Call(WithRequestID(context.Background(), "req-1"), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	_ = client.Call(context.Background(), "Flaky.Fail", 1, &reply)
+
+	var handled []map[string]interface{}
+	for i := 0; i < 100; i++ {
+		handled = handled[:0]
+		for _, r := range buf.records(t) {
+			if r["msg"] == "rpc server: request handled" {
+				handled = append(handled, r)
+			}
+		}
+		if len(handled) >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(handled) != 2 {
+		t.Fatalf("expect 2 request logs after enabling, got %v", handled)
+	}
+	// 日志在各自的请求处理完成后输出，顺序不固定
+	ok, failed := handled[0], handled[1]
+	if ok["method"] != "Foo.Sum" {
+		ok, failed = failed, ok
+	}
+	if ok["method"] != "Foo.Sum" || ok["request_id"] != "req-1" || ok["remote"] == nil || ok["duration"] == nil || ok["error"] != nil {
+		t.Fatalf("unexpected request log %v", ok)
+	}
+	if failed["method"] != "Flaky.Fail" || failed["error"] != "boom" {
+		t.Fatalf("unexpected failed request log %v", failed)
+	}
+}
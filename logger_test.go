@@ -0,0 +1,110 @@
+package geerpc
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logEntry 是 recordLogger 记录的一条日志
+type logEntry struct {
+	level   Level
+	msg     string
+	keyvals []interface{}
+}
+
+// recordLogger 记录收到的所有日志，用于测试
+type recordLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordLogger) Debug(msg string, keyvals ...interface{}) { l.add(LevelDebug, msg, keyvals) }
+func (l *recordLogger) Info(msg string, keyvals ...interface{})  { l.add(LevelInfo, msg, keyvals) }
+func (l *recordLogger) Warn(msg string, keyvals ...interface{})  { l.add(LevelWarn, msg, keyvals) }
+func (l *recordLogger) Error(msg string, keyvals ...interface{}) { l.add(LevelError, msg, keyvals) }
+
+func (l *recordLogger) add(level Level, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level, msg, keyvals})
+}
+
+// find 返回第一条消息为 msg 的日志
+func (l *recordLogger) find(msg string) (logEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func TestFormatLog(t *testing.T) {
+	cases := []struct {
+		keyvals []interface{}
+		want    string
+	}{
+		{nil, "msg"},
+		{[]interface{}{"err", errors.New("boom"), "n", 2}, "msg err=boom n=2"},
+		{[]interface{}{"dangling"}, "msg dangling"},
+	}
+	for _, c := range cases {
+		if got := formatLog("msg", c.keyvals); got != c.want {
+			t.Fatalf("expect %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(nil)
+	global := &recordLogger{}
+	SetLogger(global)
+	if DefaultLogger() != Logger(global) {
+		t.Fatal("expect DefaultLogger to return the logger set by SetLogger")
+	}
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	e, ok := global.find("rpc server: register")
+	if !ok || e.level != LevelInfo || len(e.keyvals) != 2 || e.keyvals[1] != "Foo.Sum" {
+		t.Fatalf("expect the server to log through the default logger, got %+v", e)
+	}
+
+	// Server.SetLogger 优先于默认的 Logger
+	own := &recordLogger{}
+	server.SetLogger(own)
+	var b Bar
+	_ = server.Register(&b)
+	if _, ok := own.find("rpc server: register"); !ok {
+		t.Fatal("expect the server's own logger to be used")
+	}
+	if len(global.entries) != 1 {
+		t.Fatalf("expect no more logs through the default logger, got %+v", global.entries)
+	}
+
+	SetLogger(nil)
+	if _, ok := DefaultLogger().(stdLogger); !ok {
+		t.Fatalf("expect SetLogger(nil) to restore the standard logger, got %T", DefaultLogger())
+	}
+}
+
+func TestStdLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	l := stdLogger{}
+	defer l.SetLevel(l.Level())
+	l.SetLevel(LevelWarn)
+	l.Info("hidden")
+	l.Warn("shown", "k", "v")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown k=v") {
+		t.Fatalf("expect only warnings and above, got %q", out)
+	}
+}
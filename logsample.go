@@ -0,0 +1,28 @@
+package geerpc
+
+import "sync/atomic"
+
+// LogSampling 定义了详细请求日志的采样策略，使详细日志可以在生产环境中长期开启而不会淹没日志系统
+type LogSampling struct {
+	Every     int  // 每 Every 个请求记录一个，<= 0 表示不按比例采样
+	AllErrors bool // 失败或超时的请求总是记录
+}
+
+// SetRequestLogSampling 设置详细请求日志的采样策略。被采样的请求输出一条 Info 级别的日志，
+// 除了 SetRequestLogging 输出的字段外，还携带参数摘要 args 和响应摘要 reply（仅成功时），
+// 摘要最多 256 个字符。传入零值关闭采样
+func (server *Server) SetRequestLogSampling(s LogSampling) {
+	server.sampling = s
+}
+
+// sampleRequest 判断一个请求是否被采样
+func (server *Server) sampleRequest(failed bool) bool {
+	s := server.sampling
+	if failed && s.AllErrors {
+		return true
+	}
+	if s.Every <= 0 {
+		return false
+	}
+	return atomic.AddUint64(&server.sampleSeq, 1)%uint64(s.Every) == 0
+}
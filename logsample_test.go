@@ -0,0 +1,78 @@
+package geerpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServer_SampleRequest(t *testing.T) {
+	server := NewServer()
+	if server.sampleRequest(false) || server.sampleRequest(true) {
+		t.Fatal("expect no sampling by default")
+	}
+	server.SetRequestLogSampling(LogSampling{Every: 3})
+	var got []bool
+	for i := 0; i < 6; i++ {
+		got = append(got, server.sampleRequest(false))
+	}
+	if got[0] || got[1] || !got[2] || got[3] || got[4] || !got[5] {
+		t.Fatalf("expect every third request to be sampled, got %v", got)
+	}
+	server.SetRequestLogSampling(LogSampling{AllErrors: true})
+	if !server.sampleRequest(true) || server.sampleRequest(false) {
+		t.Fatal("expect only failed requests to be sampled")
+	}
+}
+
+func TestServer_SetRequestLogSampling(t *testing.T) {
+	l := &recordLogger{}
+	server := NewServer()
+	server.SetLogger(l)
+	server.SetRequestLogSampling(LogSampling{Every: 3, AllErrors: true})
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	for i := 0; i < 6; i++ {
+		_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	}
+	_ = client.Call(context.Background(), "Flaky.Fail", 7, &reply)
+
+	// 6 个成功的请求中采样 2 个，失败的请求总是记录
+	var sampled []logEntry
+	for i := 0; i < 100 && len(sampled) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		sampled = sampled[:0]
+		l.mu.Lock()
+		for _, e := range l.entries {
+			if e.msg == "rpc server: request handled" {
+				sampled = append(sampled, e)
+			}
+		}
+		l.mu.Unlock()
+	}
+	if len(sampled) != 3 {
+		t.Fatalf("expect 3 sampled requests, got %+v", sampled)
+	}
+	for _, e := range sampled {
+		switch e.keyval("method") {
+		case "Foo.Sum":
+			if e.level != LevelInfo || e.keyval("args") != "{Num1:1 Num2:2}" || e.keyval("reply") != "3" {
+				t.Fatalf("unexpected sampled request %+v", e)
+			}
+		case "Flaky.Fail":
+			if e.keyval("args") != "7" || e.keyval("error") != "boom" || e.keyval("reply") != nil {
+				t.Fatalf("expect a failed request without the reply, got %+v", e)
+			}
+		default:
+			t.Fatalf("unexpected log %+v", e)
+		}
+	}
+}
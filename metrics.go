@@ -0,0 +1,201 @@
+package geerpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMetricsPath 是 HandleMetricsHTTP 注册指标处理程序的默认路径
+const defaultMetricsPath = "/metrics"
+
+// latencyBuckets 是请求处理耗时直方图的桶上界（秒）
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// sizeBuckets 是消息体大小直方图的桶上界（字节）
+var sizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// serverMetrics 记录服务器每个方法的请求指标
+type serverMetrics struct {
+	mu      sync.Mutex // 保护 methods
+	methods map[string]*methodMetrics
+}
+
+// methodMetrics 记录单个方法的请求数、错误数、耗时直方图以及请求和响应的大小直方图
+type methodMetrics struct {
+	requests uint64
+	errors   uint64
+	buckets  []uint64 // 与 latencyBuckets 一一对应的累计计数
+	sum      float64
+
+	requestSize sizeHistogram
+	replySize   sizeHistogram
+}
+
+// sizeHistogram 是消息体大小的直方图
+type sizeHistogram struct {
+	buckets []uint64 // 与 sizeBuckets 一一对应的累计计数
+	count   uint64
+	sum     float64
+}
+
+// observe 记录一个消息体大小，size < 0 表示大小未知，不做记录
+func (h *sizeHistogram) observe(size int) {
+	if size < 0 {
+		return
+	}
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(sizeBuckets))
+	}
+	v := float64(size)
+	for i, le := range sizeBuckets {
+		if v <= le {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// writeSizeHistogram 以 Prometheus 文本格式输出一个大小直方图的所有样本，labels 是不含花括号的标签列表
+func writeSizeHistogram(w io.Writer, name, labels string, h *sizeHistogram) {
+	if h.count == 0 {
+		return
+	}
+	for i, le := range sizeBuckets {
+		_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, le, h.buckets[i])
+	}
+	_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	_, _ = fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	_, _ = fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// observe 记录一次请求的处理耗时和结果
+func (m *serverMetrics) observe(serviceMethod string, d time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.methods == nil {
+		m.methods = make(map[string]*methodMetrics)
+	}
+	mm := m.methods[serviceMethod]
+	if mm == nil {
+		mm = newMethodMetrics()
+		m.methods[serviceMethod] = mm
+	}
+	mm.observe(d, failed)
+}
+
+// observeSizes 记录一次请求或响应的消息体大小，size < 0 表示大小未知或没有对应的消息
+func (m *serverMetrics) observeSizes(serviceMethod string, requestSize, replySize int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.methods == nil {
+		m.methods = make(map[string]*methodMetrics)
+	}
+	mm := m.methods[serviceMethod]
+	if mm == nil {
+		mm = newMethodMetrics()
+		m.methods[serviceMethod] = mm
+	}
+	mm.requestSize.observe(requestSize)
+	mm.replySize.observe(replySize)
+}
+
+// newMethodMetrics 创建一个空的 methodMetrics
+func newMethodMetrics() *methodMetrics {
+	return &methodMetrics{buckets: make([]uint64, len(latencyBuckets))}
+}
+
+// observe 记录一次请求，调用方负责加锁
+func (mm *methodMetrics) observe(d time.Duration, failed bool) {
+	mm.requests++
+	if failed {
+		mm.errors++
+	}
+	v := d.Seconds()
+	for i, le := range latencyBuckets {
+		if v <= le {
+			mm.buckets[i]++
+		}
+	}
+	mm.sum += v
+}
+
+// metricsHTTP 以 Prometheus 文本格式输出服务器的指标
+type metricsHTTP struct {
+	*Server
+}
+
+// ServeHTTP 输出服务器的连接数、正在处理的请求数，以及每个方法的请求数、错误数和耗时直方图
+func (server metricsHTTP) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_connections Number of open client connections.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_connections gauge\n")
+	_, _ = fmt.Fprintf(w, "geerpc_server_connections %d\n", atomic.LoadInt64(&server.connections))
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_connections_total Client connections accepted.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_connections_total counter\n")
+	_, _ = fmt.Fprintf(w, "geerpc_server_connections_total %d\n", atomic.LoadUint64(&server.accepted))
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_inflight_requests Number of requests being handled.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_inflight_requests gauge\n")
+	_, _ = fmt.Fprintf(w, "geerpc_server_inflight_requests %d\n", atomic.LoadInt64(&server.inflight))
+
+	m := &server.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	methods := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_requests_total Requests handled, by method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_requests_total counter\n")
+	for _, name := range methods {
+		_, _ = fmt.Fprintf(w, "geerpc_server_requests_total{method=%q} %d\n", name, m.methods[name].requests)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_errors_total Requests that returned an error or timed out, by method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_errors_total counter\n")
+	for _, name := range methods {
+		_, _ = fmt.Fprintf(w, "geerpc_server_errors_total{method=%q} %d\n", name, m.methods[name].errors)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_request_duration_seconds Latency of request handling, by method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_request_duration_seconds histogram\n")
+	for _, name := range methods {
+		mm := m.methods[name]
+		for i, le := range latencyBuckets {
+			_, _ = fmt.Fprintf(w, "geerpc_server_request_duration_seconds_bucket{method=%q,le=\"%g\"} %d\n", name, le, mm.buckets[i])
+		}
+		_, _ = fmt.Fprintf(w, "geerpc_server_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", name, mm.requests)
+		_, _ = fmt.Fprintf(w, "geerpc_server_request_duration_seconds_sum{method=%q} %g\n", name, mm.sum)
+		_, _ = fmt.Fprintf(w, "geerpc_server_request_duration_seconds_count{method=%q} %d\n", name, mm.requests)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_request_size_bytes Size of encoded request bodies, by method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_request_size_bytes histogram\n")
+	for _, name := range methods {
+		writeSizeHistogram(w, "geerpc_server_request_size_bytes", fmt.Sprintf("method=%q", name), &m.methods[name].requestSize)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_server_reply_size_bytes Size of encoded reply bodies, by method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_server_reply_size_bytes histogram\n")
+	for _, name := range methods {
+		writeSizeHistogram(w, "geerpc_server_reply_size_bytes", fmt.Sprintf("method=%q", name), &m.methods[name].replySize)
+	}
+}
+
+// MetricsHandler 返回以 Prometheus 文本格式输出服务器指标的 HTTP 处理程序
+func (server *Server) MetricsHandler() http.Handler {
+	return metricsHTTP{server}
+}
+
+// HandleMetricsHTTP 在 defaultMetricsPath 上注册服务器指标的 HTTP 处理程序，可以与 HandleHTTP 一起使用
+func (server *Server) HandleMetricsHTTP() {
+	http.Handle(defaultMetricsPath, server.MetricsHandler())
+	server.log().Info("rpc server metrics path", "path", defaultMetricsPath)
+}
+
+// HandleMetricsHTTP 是 DefaultServer 注册指标处理程序的便捷方法
+func HandleMetricsHTTP() {
+	DefaultServer.HandleMetricsHTTP()
+}
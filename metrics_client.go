@@ -0,0 +1,164 @@
+package geerpc
+
+import (
+	"fmt"
+	"geerpc/codec"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricsCollector 可以向 ClientMetrics 注册，在输出指标时追加自己的指标，
+// 例如 XClient 的熔断器状态和连接池大小。WriteMetrics 应输出完整的 Prometheus 文本格式（包括 HELP 和 TYPE 行）
+type MetricsCollector interface {
+	WriteMetrics(w io.Writer)
+}
+
+// ClientMetrics 从调用方的角度记录每个目标（服务端地址）每个方法的请求数、错误数和耗时直方图，
+// 并以 Prometheus 文本格式输出，使依赖服务的健康状况在调用方可见。
+// 通过 Option.Metrics 传给 Client 和 XClient，多个客户端可以共享同一个实例
+type ClientMetrics struct {
+	mu         sync.Mutex // 保护以下字段
+	calls      map[clientTarget]*methodMetrics
+	collectors []MetricsCollector
+}
+
+// clientTarget 是客户端指标的标签
+type clientTarget struct {
+	target string
+	method string
+}
+
+// NewClientMetrics 创建一个 ClientMetrics 实例
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{calls: make(map[clientTarget]*methodMetrics)}
+}
+
+// Register 注册一个 MetricsCollector
+func (m *ClientMetrics) Register(c MetricsCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collectors = append(m.collectors, c)
+}
+
+// Unregister 取消注册一个 MetricsCollector
+func (m *ClientMetrics) Unregister(c MetricsCollector) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, collector := range m.collectors {
+		if collector == c {
+			m.collectors = append(m.collectors[:i], m.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// observe 记录一次调用的耗时和结果
+func (m *ClientMetrics) observe(target, serviceMethod string, d time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := clientTarget{target: target, method: serviceMethod}
+	mm := m.calls[key]
+	if mm == nil {
+		mm = newMethodMetrics()
+		m.calls[key] = mm
+	}
+	mm.observe(d, failed)
+}
+
+// observeSizes 记录一次请求或响应的消息体大小，size < 0 表示大小未知或没有对应的消息
+func (m *ClientMetrics) observeSizes(target, serviceMethod string, requestSize, replySize int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := clientTarget{target: target, method: serviceMethod}
+	mm := m.calls[key]
+	if mm == nil {
+		mm = newMethodMetrics()
+		m.calls[key] = mm
+	}
+	mm.requestSize.observe(requestSize)
+	mm.replySize.observe(replySize)
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出每个目标每个方法的请求数、错误数和耗时直方图，以及已注册的 MetricsCollector 的指标
+func (m *ClientMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.mu.Lock()
+	keys := make([]clientTarget, 0, len(m.calls))
+	for key := range m.calls {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].method < keys[j].method
+	})
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_client_requests_total Calls made, by target and method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_client_requests_total counter\n")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "geerpc_client_requests_total{target=%q,method=%q} %d\n", key.target, key.method, m.calls[key].requests)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_client_errors_total Calls that failed or timed out, by target and method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_client_errors_total counter\n")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "geerpc_client_errors_total{target=%q,method=%q} %d\n", key.target, key.method, m.calls[key].errors)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_client_request_duration_seconds Latency of calls as seen by the caller, by target and method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_client_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		mm := m.calls[key]
+		for i, le := range latencyBuckets {
+			_, _ = fmt.Fprintf(w, "geerpc_client_request_duration_seconds_bucket{target=%q,method=%q,le=\"%g\"} %d\n", key.target, key.method, le, mm.buckets[i])
+		}
+		_, _ = fmt.Fprintf(w, "geerpc_client_request_duration_seconds_bucket{target=%q,method=%q,le=\"+Inf\"} %d\n", key.target, key.method, mm.requests)
+		_, _ = fmt.Fprintf(w, "geerpc_client_request_duration_seconds_sum{target=%q,method=%q} %g\n", key.target, key.method, mm.sum)
+		_, _ = fmt.Fprintf(w, "geerpc_client_request_duration_seconds_count{target=%q,method=%q} %d\n", key.target, key.method, mm.requests)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_client_request_size_bytes Size of encoded request bodies sent, by target and method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_client_request_size_bytes histogram\n")
+	for _, key := range keys {
+		writeSizeHistogram(w, "geerpc_client_request_size_bytes", fmt.Sprintf("target=%q,method=%q", key.target, key.method), &m.calls[key].requestSize)
+	}
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_client_reply_size_bytes Size of encoded reply bodies received, by target and method.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_client_reply_size_bytes histogram\n")
+	for _, key := range keys {
+		writeSizeHistogram(w, "geerpc_client_reply_size_bytes", fmt.Sprintf("target=%q,method=%q", key.target, key.method), &m.calls[key].replySize)
+	}
+	collectors := make([]MetricsCollector, len(m.collectors))
+	copy(collectors, m.collectors)
+	m.mu.Unlock()
+	for _, c := range collectors {
+		c.WriteMetrics(w)
+	}
+}
+
+// finishCall 在调用完成时记录慢调用日志和指标，并通知调用方
+func (client *Client) finishCall(call *Call) {
+	client.logSlowCall(call)
+	client.observeCall(call)
+	call.done()
+}
+
+// observeCall 在设置了 Option.Metrics 时记录调用的指标
+func (client *Client) observeCall(call *Call) {
+	if client.opt.Metrics != nil {
+		client.opt.Metrics.observe(client.peer, call.ServiceMethod, time.Since(call.start), call.Error != nil)
+	}
+}
+
+// observeSizes 在设置了 Option.Metrics 且编解码器实现了 codec.Sizer 时记录消息体大小，
+// request 为 true 时记录刚写入的请求，否则记录刚读取的响应
+func (client *Client) observeSizes(serviceMethod string, request bool) {
+	s, ok := client.cc.(codec.Sizer)
+	if client.opt.Metrics == nil || !ok {
+		return
+	}
+	if request {
+		client.opt.Metrics.observeSizes(client.peer, serviceMethod, s.WrittenBodySize(), -1)
+	} else {
+		client.opt.Metrics.observeSizes(client.peer, serviceMethod, -1, s.ReadBodySize())
+	}
+}
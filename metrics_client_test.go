@@ -0,0 +1,56 @@
+package geerpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// staticCollector 输出固定的指标，用于测试 MetricsCollector
+type staticCollector string
+
+func (c staticCollector) WriteMetrics(w io.Writer) { _, _ = fmt.Fprintln(w, string(c)) }
+
+func TestClientMetrics(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	metrics := NewClientMetrics()
+	client, err := DialInProc(server, &Option{Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	for i := 0; i < 2; i++ {
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = client.Call(context.Background(), "Flaky.Fail", 1, &reply)
+
+	c := staticCollector("custom_metric 1")
+	metrics.Register(c)
+	body := scrape(t, metrics,
+		`geerpc_client_requests_total{target="inproc",method="Foo.Sum"} 2`,
+		`geerpc_client_errors_total{target="inproc",method="Foo.Sum"} 0`,
+		`geerpc_client_requests_total{target="inproc",method="Flaky.Fail"} 1`,
+		`geerpc_client_errors_total{target="inproc",method="Flaky.Fail"} 1`,
+		`geerpc_client_request_duration_seconds_bucket{target="inproc",method="Foo.Sum",le="+Inf"} 2`,
+		`geerpc_client_request_duration_seconds_count{target="inproc",method="Foo.Sum"} 2`,
+		`geerpc_client_request_size_bytes_count{target="inproc",method="Foo.Sum"} 2`,
+		`geerpc_client_reply_size_bytes_count{target="inproc",method="Foo.Sum"} 2`,
+		"custom_metric 1",
+	)
+	if strings.Index(body, `method="Flaky.Fail"`) > strings.Index(body, `method="Foo.Sum"`) {
+		t.Fatalf("expect methods sorted by name:\n%s", body)
+	}
+	metrics.Unregister(c)
+	if body := scrape(t, metrics); strings.Contains(body, "custom_metric") {
+		t.Fatalf("expect the collector to be unregistered:\n%s", body)
+	}
+}
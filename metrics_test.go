@@ -0,0 +1,164 @@
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Flaky 的方法总是返回错误，用于测试失败的请求
+type Flaky int
+
+func (f Flaky) Fail(n int, reply *int) error { return errors.New("boom") }
+
+// scrape 读取 h 输出的指标，直到包含 want 中的所有行或超时，返回最后一次读取的内容。
+// 请求的指标在响应发出之后才记录，因此需要等待
+func scrape(t *testing.T, h http.Handler, want ...string) string {
+	t.Helper()
+	var body string
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		b, _ := ioutil.ReadAll(w.Body)
+		body = string(b)
+		missing := false
+		for _, line := range want {
+			if !strings.Contains(body, line+"\n") {
+				missing = true
+			}
+		}
+		if !missing {
+			return body
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect %q in the metrics:\n%s", want, body)
+	return body
+}
+
+func TestServer_Metrics(t *testing.T) {
+	server := NewServer()
+	var foo Foo
+	var flaky Flaky
+	_ = server.Register(&foo)
+	_ = server.Register(&flaky)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply int
+	for i := 0; i < 2; i++ {
+		if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Call(context.Background(), "Flaky.Fail", 1, &reply); err == nil {
+		t.Fatal("expect Flaky.Fail to fail")
+	}
+
+	h := server.MetricsHandler()
+	body := scrape(t, h,
+		"geerpc_server_connections 1",
+		"geerpc_server_connections_total 1",
+		"geerpc_server_inflight_requests 0",
+		`geerpc_server_requests_total{method="Foo.Sum"} 2`,
+		`geerpc_server_errors_total{method="Foo.Sum"} 0`,
+		`geerpc_server_requests_total{method="Flaky.Fail"} 1`,
+		`geerpc_server_errors_total{method="Flaky.Fail"} 1`,
+		`geerpc_server_request_duration_seconds_bucket{method="Foo.Sum",le="+Inf"} 2`,
+		`geerpc_server_request_duration_seconds_count{method="Foo.Sum"} 2`,
+	)
+	// 方法按名称排序输出
+	if strings.Index(body, `{method="Flaky.Fail"}`) > strings.Index(body, `{method="Foo.Sum"}`) {
+		t.Fatalf("expect methods sorted by name:\n%s", body)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("expect the Prometheus text format, got %q", ct)
+	}
+
+	// 连接关闭后连接数随之减少，累计连接数不变
+	_ = client.Close()
+	scrape(t, h, "geerpc_server_connections 0", "geerpc_server_connections_total 1")
+}
+
+func TestSizeHistogram(t *testing.T) {
+	var h sizeHistogram
+	h.observe(-1) // 大小未知时不记录
+	var buf strings.Builder
+	writeSizeHistogram(&buf, "size", `method="m"`, &h)
+	if buf.Len() != 0 {
+		t.Fatalf("expect no samples for an empty histogram, got %q", buf.String())
+	}
+	h.observe(10)
+	h.observe(300)
+	h.observe(5000000)
+	writeSizeHistogram(&buf, "size", `method="m"`, &h)
+	for _, line := range []string{
+		`size_bucket{method="m",le="64"} 1`,
+		`size_bucket{method="m",le="256"} 1`,
+		`size_bucket{method="m",le="1024"} 2`,
+		`size_bucket{method="m",le="4.194304e+06"} 2`,
+		`size_bucket{method="m",le="+Inf"} 3`,
+		`size_sum{method="m"} 5.00031e+06`,
+		`size_count{method="m"} 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("expect %q in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestServer_SizeMetrics(t *testing.T) {
+	server := NewServer()
+	var s Sleepy
+	_ = server.Register(&s)
+	metrics := NewClientMetrics()
+	client, err := DialInProc(server, &Option{Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply []byte
+	if err := client.Call(context.Background(), "Sleepy.Sleep", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	// 返回值是 100 字节的切片，加上 gob 的长度前缀落在 (64, 256] 的桶中
+	serverBody := scrape(t, server.MetricsHandler(),
+		`geerpc_server_reply_size_bytes_bucket{method="Sleepy.Sleep",le="64"} 0`,
+		`geerpc_server_reply_size_bytes_bucket{method="Sleepy.Sleep",le="256"} 1`,
+		`geerpc_server_request_size_bytes_bucket{method="Sleepy.Sleep",le="64"} 1`,
+		`geerpc_server_request_size_bytes_count{method="Sleepy.Sleep"} 1`,
+	)
+	clientBody := scrape(t, metrics,
+		`geerpc_client_reply_size_bytes_bucket{target="inproc",method="Sleepy.Sleep",le="64"} 0`,
+		`geerpc_client_reply_size_bytes_bucket{target="inproc",method="Sleepy.Sleep",le="256"} 1`,
+		`geerpc_client_request_size_bytes_count{target="inproc",method="Sleepy.Sleep"} 1`,
+	)
+	// 两端统计的是同一份编码后的消息体，大小相同
+	for _, name := range []string{"request", "reply"} {
+		serverSum := metricValue(serverBody, fmt.Sprintf(`geerpc_server_%s_size_bytes_sum{method="Sleepy.Sleep"}`, name))
+		clientSum := metricValue(clientBody, fmt.Sprintf(`geerpc_client_%s_size_bytes_sum{target="inproc",method="Sleepy.Sleep"}`, name))
+		if serverSum == "" || serverSum != clientSum {
+			t.Fatalf("expect the same %s size on both sides, got %q and %q", name, serverSum, clientSum)
+		}
+	}
+}
+
+// metricValue 返回 body 中名称和标签为 series 的样本值，不存在时返回空字符串
+func metricValue(body, series string) string {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			return strings.TrimPrefix(line, series+" ")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package geerpc
+
+import (
+	"geerpc/codec"
+	"io"
+	"net"
+	"time"
+)
+
+// ServeMsgpackRPCConn 使用 MessagePack-RPC 协议在连接上提供服务，直到客户端挂断，
+// 使 Python、Ruby 等语言现有的 msgpack-rpc 客户端无需转换层即可调用 geerpc 服务器上的服务，
+// 消息格式和参数的转换规则参见 codec.MsgpackRPCCodec。
+//
+// 与 net/rpc 的连接一样，MessagePack-RPC 的连接没有握手凭证、单次调用凭证、签名和加密：
+// 设置了 TokenValidator 时只有携带客户端证书的 TLS 连接能通过认证，要求签名或加密的服务器拒绝这样的连接。
+// 除此之外请求与 geerpc 客户端的请求经过相同的处理：速率限制、授权、配额、拦截器和处理超时
+func (server *Server) ServeMsgpackRPCConn(conn io.ReadWriteCloser) {
+	if !server.handshakeTLS(conn) {
+		return
+	}
+	conn, tracker, done := server.openConn(conn)
+	defer done()
+	if conn == nil {
+		return
+	}
+	if err := server.checkHandshakeless(); err != nil {
+		server.log().Warn("rpc server: msgpack-rpc connection rejected", "remote", tracker.remote, "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: tracker.remote, Err: err})
+		return
+	}
+	cc := codec.NewMsgpackRPCCodec(server.coalesce(conn))
+	if l, ok := cc.(codec.Limiter); ok && server.decodeLimits != nil {
+		l.SetLimits(*server.decodeLimits)
+	}
+	opt := *DefaultOption
+	server.serveCodec(cc, &opt, tracker)
+}
+
+// AcceptMsgpackRPC 接受监听器上的连接，并使用 MessagePack-RPC 协议为每个连接提供服务（参见 ServeMsgpackRPCConn）。
+// MessagePack-RPC 客户端与 geerpc 客户端需要使用不同的监听器
+func (server *Server) AcceptMsgpackRPC(lis net.Listener) {
+	server.acceptLoop(lis, func(conn net.Conn) { server.ServeMsgpackRPCConn(conn) })
+}
+
+// AcceptMsgpackRPC 使用 DefaultServer 以 MessagePack-RPC 协议接受监听器上的连接
+func AcceptMsgpackRPC(lis net.Listener) { DefaultServer.AcceptMsgpackRPC(lis) }
@@ -0,0 +1,77 @@
+package geerpc
+
+import (
+	"crypto/tls"
+	"errors"
+	"geerpc/codec"
+	"io"
+	"net"
+	"time"
+)
+
+// ServeNetRPCConn 使用标准库 net/rpc 的协议在连接上提供服务，直到客户端挂断，
+// 使现有的 net/rpc 客户端（rpc.Dial、rpc.NewClient）无需修改即可调用 geerpc 服务器上的服务，便于逐步迁移。
+//
+// net/rpc 的连接没有 Option 握手，直接交换 gob 编码的请求头和消息体；geerpc 的消息头是 net/rpc 消息头的超集，
+// gob 按字段名匹配，因此两者在连接上的格式相同。这样的连接没有握手凭证、单次调用凭证、签名和加密：
+// 设置了 TokenValidator 时只有携带客户端证书的 TLS 连接能通过认证，要求签名或加密的服务器拒绝这样的连接
+func (server *Server) ServeNetRPCConn(conn io.ReadWriteCloser) {
+	if !server.handshakeTLS(conn) {
+		return
+	}
+	conn, tracker, done := server.openConn(conn)
+	defer done()
+	if conn == nil {
+		return
+	}
+	if err := server.checkHandshakeless(); err != nil {
+		server.log().Warn("rpc server: net/rpc connection rejected", "remote", tracker.remote, "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: tracker.remote, Err: err})
+		return
+	}
+	cc := codec.NewGobCodec(server.coalesce(conn))
+	if l, ok := cc.(codec.Limiter); ok && server.decodeLimits != nil {
+		l.SetLimits(*server.decodeLimits)
+	}
+	opt := *DefaultOption
+	server.serveCodec(cc, &opt, tracker)
+}
+
+// handshakeTLS 在没有 Option 握手的连接上先完成 TLS 握手，以便从客户端证书中获取调用方身份。
+// conn 不是 TLS 连接时直接返回 true，握手失败时关闭连接并返回 false
+func (server *Server) handshakeTLS(conn io.ReadWriteCloser) bool {
+	c, ok := conn.(*tls.Conn)
+	if !ok {
+		return true
+	}
+	_ = c.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	err := c.Handshake()
+	_ = c.SetDeadline(time.Time{})
+	if err != nil {
+		server.log().Warn("rpc server: tls handshake failed", "remote", c.RemoteAddr().String(), "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: c.RemoteAddr().String(), Err: err})
+		_ = c.Close()
+		return false
+	}
+	return true
+}
+
+// AcceptNetRPC 接受监听器上的连接，并使用 net/rpc 的协议为每个连接提供服务（参见 ServeNetRPCConn）。
+// net/rpc 客户端与 geerpc 客户端需要使用不同的监听器
+func (server *Server) AcceptNetRPC(lis net.Listener) {
+	server.acceptLoop(lis, func(conn net.Conn) { server.ServeNetRPCConn(conn) })
+}
+
+// AcceptNetRPC 使用 DefaultServer 以 net/rpc 的协议接受监听器上的连接
+func AcceptNetRPC(lis net.Listener) { DefaultServer.AcceptNetRPC(lis) }
+
+// checkHandshakeless 检查服务器是否可以接受没有 Option 握手的连接（net/rpc、MessagePack-RPC 和 JSON-RPC），这样的连接无法签名或加密
+func (server *Server) checkHandshakeless() error {
+	switch {
+	case server.requireSigning:
+		return errors.New("rpc server: request signing required")
+	case server.requireEncrypt:
+		return errors.New("rpc server: encryption required")
+	}
+	return nil
+}
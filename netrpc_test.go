@@ -0,0 +1,81 @@
+package geerpc
+
+import (
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startNetRPC 在本地端口上以 net/rpc 的协议启动 server，返回监听地址
+func startNetRPC(t *testing.T, server *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.AcceptNetRPC(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String()
+}
+
+func TestServer_AcceptNetRPC(t *testing.T) {
+	addr := startNetRPC(t, newCalcServer(false))
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// 标准库客户端无需修改即可调用，并发的调用在同一连接上按序号匹配
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var reply int
+			if err := client.Call("Foo.Sum", Args{Num1: i, Num2: i}, &reply); err != nil || reply != 2*i {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("expect every call to succeed, got %v", err)
+	}
+
+	// 服务方法和找不到方法的错误以 rpc.ServerError 返回，连接保持可用
+	var reply int
+	if err := client.Call("Calc.Div", Args{Num1: 1}, &reply); err != rpc.ServerError("divide by zero") {
+		t.Fatalf("expect the method error, got %v", err)
+	}
+	if _, ok := client.Call("Calc.Missing", Args{}, &reply).(rpc.ServerError); !ok {
+		t.Fatal("expect a server error for an unknown method")
+	}
+	if err := client.Call("Calc.Div", Args{Num1: 6, Num2: 3}, &reply); err != nil || reply != 2 {
+		t.Fatalf("expect 2, got %d, err %v", reply, err)
+	}
+}
+
+func TestServer_AcceptNetRPCRejected(t *testing.T) {
+	// 要求签名的服务器无法对 net/rpc 连接验证签名，直接拒绝
+	server := newCalcServer(false)
+	server.SetSigningKeys(func(string) []byte { return []byte("key") }, true)
+	addr := startNetRPC(t, server)
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	call := client.Go("Foo.Sum", Args{Num1: 1, Num2: 2}, new(int), nil)
+	select {
+	case <-call.Done:
+		if call.Error == nil {
+			t.Fatal("expect the call to fail on a server that requires signing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the connection to be closed")
+	}
+}
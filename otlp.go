@@ -0,0 +1,291 @@
+package geerpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// metricsStart 是累计指标的起始时间
+var metricsStart = time.Now()
+
+// OTLPOptions 定义了通过 OTLP/HTTP 推送指标的参数
+type OTLPOptions struct {
+	Endpoint    string            // OTLP 收集器的 metrics 地址，例如 http://localhost:4318/v1/metrics
+	Interval    time.Duration     // 推送间隔，为 0 时使用 10s
+	Headers     map[string]string // 附加的请求头，例如收集器的认证信息
+	ServiceName string            // 资源属性 service.name
+	HTTPClient  *http.Client      // 为 nil 时使用 http.DefaultClient
+}
+
+// StartOTLPExport 定期将服务器的指标（与 MetricsHandler 输出的计数器和直方图相同）以 OTLP/HTTP JSON 格式
+// 推送给 OpenTelemetry 收集器，适用于只通过 OTLP 收集遥测数据的环境。返回的 stop 函数用于停止推送
+func (server *Server) StartOTLPExport(opt OTLPOptions) (stop func()) {
+	return startOTLPExport(opt, server.otlpMetrics, server.log())
+}
+
+// StartOTLPExport 定期将客户端的调用指标以 OTLP/HTTP JSON 格式推送给 OpenTelemetry 收集器。
+// 注册的 MetricsCollector（例如 XClient 的熔断器状态）只通过 ServeHTTP 输出，不会被推送
+func (m *ClientMetrics) StartOTLPExport(opt OTLPOptions) (stop func()) {
+	return startOTLPExport(opt, m.otlpMetrics, DefaultLogger())
+}
+
+// startOTLPExport 启动定期推送的协程
+func startOTLPExport(opt OTLPOptions, collect func(now time.Time) []otlpMetric, logger Logger) (stop func()) {
+	if opt.Interval <= 0 {
+		opt.Interval = time.Second * 10
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(opt.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := pushOTLP(opt, collect(time.Now())); err != nil {
+					logger.Warn("rpc otlp: push metrics failed", "endpoint", opt.Endpoint, "err", err)
+				}
+			}
+		}
+	}()
+	var stopped int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+			close(done)
+		}
+	}
+}
+
+// pushOTLP 将指标编码为 OTLP JSON 并发送给收集器，没有数据点的指标会被跳过
+func pushOTLP(opt OTLPOptions, all []otlpMetric) error {
+	metrics := make([]otlpMetric, 0, len(all))
+	for _, m := range all {
+		if len(m.Gauge.points())+len(m.Sum.points())+len(m.Histogram.points()) > 0 {
+			metrics = append(metrics, m)
+		}
+	}
+	var resource otlpResource
+	if opt.ServiceName != "" {
+		resource.Attributes = []otlpAttribute{otlpString("service.name", opt.ServiceName)}
+	}
+	body, err := json.Marshal(otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     resource,
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "geerpc"}, Metrics: metrics}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", opt.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range opt.Headers {
+		req.Header.Set(k, v)
+	}
+	client := opt.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("rpc otlp: collector returned " + resp.Status)
+	}
+	return nil
+}
+
+// otlpMetrics 返回服务器指标的 OTLP 表示
+func (server *Server) otlpMetrics(now time.Time) []otlpMetric {
+	metrics := []otlpMetric{
+		otlpGauge("geerpc.server.connections", "Number of open client connections.", "{connection}",
+			otlpIntPoint(nil, now, uint64(atomic.LoadInt64(&server.connections)))),
+		otlpSum("geerpc.server.connections.total", "Client connections accepted.", "{connection}",
+			otlpIntPoint(nil, now, atomic.LoadUint64(&server.accepted))),
+		otlpGauge("geerpc.server.inflight_requests", "Number of requests being handled.", "{request}",
+			otlpIntPoint(nil, now, uint64(atomic.LoadInt64(&server.inflight)))),
+	}
+	m := &server.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	methods := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	labels := make([][]otlpAttribute, len(methods))
+	mms := make([]*methodMetrics, len(methods))
+	for i, name := range methods {
+		labels[i] = []otlpAttribute{otlpString("method", name)}
+		mms[i] = m.methods[name]
+	}
+	return append(metrics, methodOTLPMetrics("geerpc.server", labels, mms, now)...)
+}
+
+// otlpMetrics 返回客户端指标的 OTLP 表示
+func (m *ClientMetrics) otlpMetrics(now time.Time) []otlpMetric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	labels := make([][]otlpAttribute, 0, len(m.calls))
+	mms := make([]*methodMetrics, 0, len(m.calls))
+	for key, mm := range m.calls {
+		labels = append(labels, []otlpAttribute{otlpString("target", key.target), otlpString("method", key.method)})
+		mms = append(mms, mm)
+	}
+	return methodOTLPMetrics("geerpc.client", labels, mms, now)
+}
+
+// methodOTLPMetrics 将每组标签对应的 methodMetrics 转换为请求数、错误数、耗时和消息大小指标，调用方负责加锁
+func methodOTLPMetrics(prefix string, labels [][]otlpAttribute, mms []*methodMetrics, now time.Time) []otlpMetric {
+	var requests, errs, durations, requestSizes, replySizes []otlpDataPoint
+	for i, mm := range mms {
+		requests = append(requests, otlpIntPoint(labels[i], now, mm.requests))
+		errs = append(errs, otlpIntPoint(labels[i], now, mm.errors))
+		durations = append(durations, otlpHistogramPoint(labels[i], now, latencyBuckets, mm.buckets, mm.requests, mm.sum))
+		if h := mm.requestSize; h.count > 0 {
+			requestSizes = append(requestSizes, otlpHistogramPoint(labels[i], now, sizeBuckets, h.buckets, h.count, h.sum))
+		}
+		if h := mm.replySize; h.count > 0 {
+			replySizes = append(replySizes, otlpHistogramPoint(labels[i], now, sizeBuckets, h.buckets, h.count, h.sum))
+		}
+	}
+	return []otlpMetric{
+		otlpSum(prefix+".requests", "Requests, by method.", "{request}", requests...),
+		otlpSum(prefix+".errors", "Requests that returned an error or timed out, by method.", "{request}", errs...),
+		otlpHistogram(prefix+".request.duration", "Latency of requests, by method.", "s", durations...),
+		otlpHistogram(prefix+".request.size", "Size of encoded request bodies, by method.", "By", requestSizes...),
+		otlpHistogram(prefix+".reply.size", "Size of encoded reply bodies, by method.", "By", replySizes...),
+	}
+}
+
+// 以下类型对应 OTLP ExportMetricsServiceRequest 的 JSON 编码，
+// 按照 protobuf 的 JSON 映射，64 位整数编码为字符串
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Unit        string    `json:"unit,omitempty"`
+	Gauge       *otlpData `json:"gauge,omitempty"`
+	Sum         *otlpData `json:"sum,omitempty"`
+	Histogram   *otlpData `json:"histogram,omitempty"`
+}
+
+type otlpData struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality,omitempty"` // 2 表示累计值
+	IsMonotonic            bool            `json:"isMonotonic,omitempty"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt,omitempty"`
+	Count             string          `json:"count,omitempty"`
+	Sum               *float64        `json:"sum,omitempty"`
+	BucketCounts      []string        `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64       `json:"explicitBounds,omitempty"`
+}
+
+// points 返回数据点，d 为 nil 时返回 nil
+func (d *otlpData) points() []otlpDataPoint {
+	if d == nil {
+		return nil
+	}
+	return d.DataPoints
+}
+
+// otlpAggregationCumulative 表示数据点是自 StartTimeUnixNano 以来的累计值
+const otlpAggregationCumulative = 2
+
+func otlpString(key, value string) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	a.Value.StringValue = value
+	return a
+}
+
+func otlpGauge(name, description, unit string, points ...otlpDataPoint) otlpMetric {
+	return otlpMetric{Name: name, Description: description, Unit: unit, Gauge: &otlpData{DataPoints: points}}
+}
+
+func otlpSum(name, description, unit string, points ...otlpDataPoint) otlpMetric {
+	return otlpMetric{Name: name, Description: description, Unit: unit, Sum: &otlpData{
+		DataPoints:             points,
+		AggregationTemporality: otlpAggregationCumulative,
+		IsMonotonic:            true,
+	}}
+}
+
+func otlpHistogram(name, description, unit string, points ...otlpDataPoint) otlpMetric {
+	return otlpMetric{Name: name, Description: description, Unit: unit, Histogram: &otlpData{
+		DataPoints:             points,
+		AggregationTemporality: otlpAggregationCumulative,
+	}}
+}
+
+func otlpIntPoint(attrs []otlpAttribute, now time.Time, v uint64) otlpDataPoint {
+	return otlpDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: strconv.FormatInt(metricsStart.UnixNano(), 10),
+		TimeUnixNano:      strconv.FormatInt(now.UnixNano(), 10),
+		AsInt:             strconv.FormatUint(v, 10),
+	}
+}
+
+// otlpHistogramPoint 将累计的桶计数（Prometheus 风格）转换为 OTLP 使用的每个桶各自的计数
+func otlpHistogramPoint(attrs []otlpAttribute, now time.Time, bounds []float64, cumulative []uint64, count uint64, sum float64) otlpDataPoint {
+	counts := make([]string, len(bounds)+1)
+	var prev uint64
+	for i := range bounds {
+		counts[i] = strconv.FormatUint(cumulative[i]-prev, 10)
+		prev = cumulative[i]
+	}
+	counts[len(bounds)] = strconv.FormatUint(count-prev, 10)
+	return otlpDataPoint{
+		Attributes:        attrs,
+		StartTimeUnixNano: strconv.FormatInt(metricsStart.UnixNano(), 10),
+		TimeUnixNano:      strconv.FormatInt(now.UnixNano(), 10),
+		Count:             strconv.FormatUint(count, 10),
+		Sum:               &sum,
+		BucketCounts:      counts,
+		ExplicitBounds:    bounds,
+	}
+}
@@ -0,0 +1,172 @@
+package geerpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// otlpCollector 是记录收到的推送的 OTLP 收集器
+type otlpCollector struct {
+	mu       sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+	status   int
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	var r otlpRequest
+	_ = json.Unmarshal(body, &r)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, r)
+	c.headers = append(c.headers, req.Header)
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+}
+
+// count 返回收到的推送数
+func (c *otlpCollector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests)
+}
+
+// metric 等待一次包含名为 name 的指标的推送，返回该指标和推送的请求头
+func (c *otlpCollector) metric(t *testing.T, name string) (otlpMetric, otlpResource, http.Header) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		c.mu.Lock()
+		for j := len(c.requests) - 1; j >= 0; j-- {
+			for _, rm := range c.requests[j].ResourceMetrics {
+				for _, sm := range rm.ScopeMetrics {
+					for _, m := range sm.Metrics {
+						if m.Name == name {
+							c.mu.Unlock()
+							return m, rm.Resource, c.headers[j]
+						}
+					}
+				}
+			}
+		}
+		c.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect metric %s to be pushed", name)
+	return otlpMetric{}, otlpResource{}, nil
+}
+
+func TestServer_StartOTLPExport(t *testing.T) {
+	collector := &otlpCollector{}
+	ts := httptest.NewServer(collector)
+	defer ts.Close()
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	for i := 0; i < 2; i++ {
+		_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+	}
+	scrape(t, server.MetricsHandler(), `geerpc_server_requests_total{method="Foo.Sum"} 2`)
+
+	stop := server.StartOTLPExport(OTLPOptions{
+		Endpoint:    ts.URL + "/v1/metrics",
+		Interval:    20 * time.Millisecond,
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+		ServiceName: "demo",
+	})
+	requests, resource, header := collector.metric(t, "geerpc.server.requests")
+	if header.Get("Authorization") != "Bearer secret" || header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	if len(resource.Attributes) != 1 || resource.Attributes[0].Key != "service.name" || resource.Attributes[0].Value.StringValue != "demo" {
+		t.Fatalf("expect the service name as a resource attribute, got %+v", resource)
+	}
+	if requests.Sum == nil || !requests.Sum.IsMonotonic || requests.Sum.AggregationTemporality != otlpAggregationCumulative {
+		t.Fatalf("expect a cumulative monotonic sum, got %+v", requests)
+	}
+	p := requests.Sum.DataPoints[0]
+	if p.AsInt != "2" || p.Attributes[0].Key != "method" || p.Attributes[0].Value.StringValue != "Foo.Sum" {
+		t.Fatalf("unexpected data point %+v", p)
+	}
+
+	// 直方图使用每个桶各自的计数，桶数比边界多一个
+	duration, _, _ := collector.metric(t, "geerpc.server.request.duration")
+	p = duration.Histogram.DataPoints[0]
+	if len(p.BucketCounts) != len(p.ExplicitBounds)+1 || p.Count != "2" {
+		t.Fatalf("unexpected histogram point %+v", p)
+	}
+	var total uint64
+	for _, c := range p.BucketCounts {
+		n, _ := strconv.ParseUint(c, 10, 64)
+		total += n
+	}
+	if total != 2 {
+		t.Fatalf("expect the bucket counts to add up to the count, got %v", p.BucketCounts)
+	}
+
+	// stop 可以重复调用，之后不再推送
+	stop()
+	stop()
+	time.Sleep(50 * time.Millisecond)
+	n := collector.count()
+	time.Sleep(100 * time.Millisecond)
+	if collector.count() != n {
+		t.Fatal("expect no pushes after stop")
+	}
+}
+
+func TestStartOTLPExport_CollectorError(t *testing.T) {
+	ts := httptest.NewServer(&otlpCollector{status: http.StatusServiceUnavailable})
+	defer ts.Close()
+	l := &recordLogger{}
+	server := NewServer()
+	server.SetLogger(l)
+	stop := server.StartOTLPExport(OTLPOptions{Endpoint: ts.URL, Interval: 10 * time.Millisecond})
+	defer stop()
+	if e := waitLog(t, l, "rpc otlp: push metrics failed"); e.level != LevelWarn || e.keyval("endpoint") != ts.URL {
+		t.Fatalf("unexpected log %+v", e)
+	}
+}
+
+func TestClientMetrics_StartOTLPExport(t *testing.T) {
+	collector := &otlpCollector{}
+	ts := httptest.NewServer(collector)
+	defer ts.Close()
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	metrics := NewClientMetrics()
+	client, err := DialInProc(server, &Option{Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	_ = client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+
+	stop := metrics.StartOTLPExport(OTLPOptions{Endpoint: ts.URL, Interval: 20 * time.Millisecond})
+	defer stop()
+	requests, _, _ := collector.metric(t, "geerpc.client.requests")
+	p := requests.Sum.DataPoints[0]
+	if p.AsInt != "1" || len(p.Attributes) != 2 || p.Attributes[0].Value.StringValue != "inproc" || p.Attributes[1].Value.StringValue != "Foo.Sum" {
+		t.Fatalf("unexpected data point %+v", p)
+	}
+	size, _, _ := collector.metric(t, "geerpc.client.reply.size")
+	if p := size.Histogram.DataPoints[0]; p.Count != "1" || *p.Sum <= 0 {
+		t.Fatalf("unexpected reply size point %+v", p)
+	}
+}
@@ -0,0 +1,248 @@
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Plugin 是框架扩展的接口，插件通过 AddPlugin 添加到服务器，并实现以下一个或多个接口，在对应的阶段被调用：
+//   - RegisterPlugin：注册服务时
+//   - ServePlugin：Accept 等方法开始在监听器上提供服务时
+//   - ConnAcceptPlugin、ConnClosePlugin：连接建立和关闭时
+//   - PreCallPlugin、PostCallPlugin：调用服务方法前后
+//   - ShutdownPlugin：Shutdown 开始排空时
+//   - RegistrarPlugin：管理接口注销和重新登记服务器时
+//
+// 这样指标上报、认证和注册中心客户端等功能可以作为可选的模块提供，例如 registry.Plugin
+type Plugin interface {
+	Name() string // 插件的名称，用于日志
+}
+
+// RegisterPlugin 在服务注册到服务器之前调用，返回错误时注册失败，Register 返回该错误
+type RegisterPlugin interface {
+	Plugin
+	Register(name string, rcvr interface{}) error
+}
+
+// ServePlugin 在 Accept、AcceptTLS 或 AcceptNetRPC 开始接受监听器上的连接时调用，addr 是监听的地址
+type ServePlugin interface {
+	Plugin
+	Serve(addr net.Addr)
+}
+
+// ConnAcceptPlugin 在连接建立后、选项握手之前调用，remote 是客户端地址（无法获取时为空）。
+// 可以返回包装后的连接，之后的读写都经过它；返回错误时连接被拒绝并关闭
+type ConnAcceptPlugin interface {
+	Plugin
+	ConnAccept(conn io.ReadWriteCloser, remote string) (io.ReadWriteCloser, error)
+}
+
+// ConnClosePlugin 在连接关闭后调用，stats 是连接最终的统计数据
+type ConnClosePlugin interface {
+	Plugin
+	ConnClose(stats ConnStats)
+}
+
+// PreCallPlugin 在认证、授权和配额检查之后、调用服务方法之前调用，返回错误时拒绝调用，错误信息会返回给客户端
+type PreCallPlugin interface {
+	Plugin
+	PreCall(ctx context.Context, info *CallInfo, args interface{}) error
+}
+
+// PostCallPlugin 在服务方法返回之后调用，err 是服务方法或之前的插件返回的错误
+type PostCallPlugin interface {
+	Plugin
+	PostCall(ctx context.Context, info *CallInfo, args, reply interface{}, err error)
+}
+
+// ShutdownPlugin 在 Shutdown 开始排空时调用，此时服务器已经不再就绪，但正在处理的请求尚未完成，
+// 适合从注册中心注销等需要先于关闭连接完成的工作。返回的错误会由 Shutdown 返回，但不会中断关闭
+type ShutdownPlugin interface {
+	Plugin
+	Shutdown(ctx context.Context) error
+}
+
+// RegistrarPlugin 由把服务器登记到注册中心的插件实现（例如 registry.Plugin），
+// 管理接口（参见 HandleAdminHTTP）通过它注销服务器以及在恢复流量时重新登记
+type RegistrarPlugin interface {
+	Plugin
+	Deregister() error
+	Reregister()
+}
+
+// AddPlugin 添加插件。实现了 PreCallPlugin 或 PostCallPlugin 的插件以拦截器的形式追加到调用链上，
+// 与 Use 追加的拦截器按添加的顺序排列。应在注册服务和开始服务之前调用
+func (server *Server) AddPlugin(plugins ...Plugin) {
+	for _, p := range plugins {
+		server.plugins = append(server.plugins, p)
+		if interceptor := pluginInterceptor(p); interceptor != nil {
+			server.interceptors = append(server.interceptors, interceptor)
+		}
+	}
+}
+
+// Plugins 返回已添加的插件
+func (server *Server) Plugins() []Plugin {
+	return append([]Plugin(nil), server.plugins...)
+}
+
+// pluginInterceptor 将插件的 PreCall 和 PostCall 转换为拦截器，两者都没有实现时返回 nil
+func pluginInterceptor(p Plugin) ServerInterceptor {
+	pre, hasPre := p.(PreCallPlugin)
+	post, hasPost := p.(PostCallPlugin)
+	if !hasPre && !hasPost {
+		return nil
+	}
+	return func(ctx context.Context, info *CallInfo, args, reply interface{}, next Handler) error {
+		var err error
+		if hasPre {
+			err = pre.PreCall(ctx, info, args)
+		}
+		if err == nil {
+			err = next(ctx, info, args, reply)
+		}
+		if hasPost {
+			post.PostCall(ctx, info, args, reply, err)
+		}
+		return err
+	}
+}
+
+// pluginsRegister 依次调用 RegisterPlugin
+func (server *Server) pluginsRegister(name string, rcvr interface{}) error {
+	for _, p := range server.plugins {
+		if p, ok := p.(RegisterPlugin); ok {
+			if err := p.Register(name, rcvr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pluginsAccept 依次调用 ConnAcceptPlugin，连接被拒绝时返回 nil
+func (server *Server) pluginsAccept(conn io.ReadWriteCloser, remote string) io.ReadWriteCloser {
+	for _, p := range server.plugins {
+		if p, ok := p.(ConnAcceptPlugin); ok {
+			c, err := p.ConnAccept(conn, remote)
+			if err != nil {
+				server.log().Warn("rpc server: connection rejected by plugin", "plugin", p.Name(), "remote", remote, "err", err)
+				server.events.Publish(ConnRejected{Time: time.Now(), Remote: remote, Reason: "plugin " + p.Name()})
+				return nil
+			}
+			conn = c
+		}
+	}
+	return conn
+}
+
+// pluginsConnClose 依次调用 ConnClosePlugin
+func (server *Server) pluginsConnClose(tracker *connTracker) {
+	var stats *ConnStats
+	for _, p := range server.plugins {
+		if p, ok := p.(ConnClosePlugin); ok {
+			if stats == nil {
+				s := tracker.snapshot(time.Now())
+				stats = &s
+			}
+			p.ConnClose(*stats)
+		}
+	}
+}
+
+// errShuttingDown 是服务器排空期间收到的新请求返回的错误
+var errShuttingDown = errors.New("rpc server: shutting down")
+
+// acceptLoop 接受监听器上的连接，通过地址过滤的连接交给 serve 处理，直到监听器被关闭
+func (server *Server) acceptLoop(lis net.Listener, serve func(conn net.Conn)) {
+	if !server.trackListener(lis) {
+		_ = lis.Close()
+		return
+	}
+	defer server.untrackListener(lis)
+	for _, p := range server.plugins {
+		if p, ok := p.(ServePlugin); ok {
+			p.Serve(lis.Addr())
+		}
+	}
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&server.closing) == 0 {
+				server.log().Error("rpc server: accept error", "err", err)
+			}
+			return
+		}
+		if !server.permitConn(conn.RemoteAddr().String()) {
+			_ = conn.Close()
+			continue
+		}
+		go serve(conn)
+	}
+}
+
+// trackListener 登记正在接受连接的监听器，Shutdown 时关闭它们。服务器已经关闭时返回 false
+func (server *Server) trackListener(lis net.Listener) bool {
+	server.listenersMu.Lock()
+	defer server.listenersMu.Unlock()
+	if atomic.LoadInt32(&server.closing) != 0 {
+		return false
+	}
+	if server.listeners == nil {
+		server.listeners = make(map[net.Listener]struct{})
+	}
+	server.listeners[lis] = struct{}{}
+	return true
+}
+
+func (server *Server) untrackListener(lis net.Listener) {
+	server.listenersMu.Lock()
+	defer server.listenersMu.Unlock()
+	delete(server.listeners, lis)
+}
+
+// Shutdown 优雅地关闭服务器：将就绪状态设置为 HealthDraining 并调用 ShutdownPlugin，关闭 Accept 等方法使用的监听器，
+// 等待正在处理的请求完成，然后关闭所有连接。之后建立的连接（例如通过 ServeHTTP）会被立即关闭。
+// ctx 在请求完成之前结束时不再等待，直接关闭连接并返回 ctx 的错误，否则返回第一个 ShutdownPlugin 的错误
+func (server *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&server.closing, 1)
+	server.SetServingStatus(HealthDraining)
+	var err error
+	for _, p := range server.plugins {
+		if p, ok := p.(ShutdownPlugin); ok {
+			if e := p.Shutdown(ctx); e != nil {
+				server.log().Error("rpc server: plugin shutdown error", "plugin", p.Name(), "err", e)
+				if err == nil {
+					err = e
+				}
+			}
+		}
+	}
+	server.listenersMu.Lock()
+	for lis := range server.listeners {
+		_ = lis.Close()
+	}
+	server.listenersMu.Unlock()
+
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	for server.Inflight() > 0 && ctx.Err() == nil {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	}
+	server.connsMu.Lock()
+	for _, c := range server.conns {
+		_ = c.Close()
+	}
+	server.connsMu.Unlock()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
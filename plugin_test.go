@@ -0,0 +1,170 @@
+package geerpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testPlugin struct {
+	mu       sync.Mutex
+	services []string
+	calls    []string
+	closed   int
+	reject   bool
+}
+
+func (p *testPlugin) Name() string { return "test" }
+
+func (p *testPlugin) Register(name string, _ interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services = append(p.services, name)
+	return nil
+}
+
+func (p *testPlugin) ConnAccept(conn io.ReadWriteCloser, _ string) (io.ReadWriteCloser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reject {
+		return nil, errors.New("rejected")
+	}
+	return conn, nil
+}
+
+func (p *testPlugin) ConnClose(ConnStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed++
+}
+
+func (p *testPlugin) PreCall(_ context.Context, _ *CallInfo, args interface{}) error {
+	if a, ok := args.(Args); ok && a.Num1 < 0 {
+		return errors.New("denied by plugin")
+	}
+	return nil
+}
+
+func (p *testPlugin) PostCall(_ context.Context, info *CallInfo, _, _ interface{}, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, info.ServiceMethod)
+}
+
+func TestServer_Plugins(t *testing.T) {
+	p := &testPlugin{}
+	server := NewServer()
+	server.SetConnRateLimit(0, 0)
+	server.AddPlugin(p)
+	var foo Foo
+	_ = server.Register(&foo)
+	_assert(len(p.services) == 1 && p.services[0] == "Foo", "expect RegisterPlugin to see the service")
+
+	client, err := DialInProc(server)
+	_assert(err == nil, "failed to dial")
+	var reply int
+	err = client.Call(context.Background(), "Foo.Sum", Args{1, 2}, &reply)
+	_assert(err == nil && reply == 3, "expect the call to succeed")
+	err = client.Call(context.Background(), "Foo.Sum", Args{-1, 2}, &reply)
+	_assert(err != nil && err.Error() == "denied by plugin", "expect PreCall to reject the call, got %v", err)
+	_ = client.Close()
+	time.Sleep(50 * time.Millisecond)
+	p.mu.Lock()
+	_assert(len(p.calls) == 2 && p.calls[0] == "Foo.Sum", "expect PostCall to see both calls")
+	_assert(p.closed == 1, "expect ConnClose to be called once, got %d", p.closed)
+	p.reject = true
+	p.mu.Unlock()
+
+	_, err = DialInProc(server, &Option{ConnectTimeout: 100 * time.Millisecond})
+	_assert(err != nil, "expect the plugin to reject the connection")
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	server := NewServer()
+	server.SetConnRateLimit(0, 0)
+	var b Bar
+	_ = server.Register(&b)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	accepted := make(chan struct{})
+	go func() {
+		server.Accept(l)
+		close(accepted)
+	}()
+	client, err := Dial("tcp", l.Addr().String())
+	_assert(err == nil, "failed to dial")
+	call := client.Go("Bar.Timeout", 1, new(int), nil)
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	err = server.Shutdown(context.Background())
+	_assert(err == nil, "expect shutdown to succeed")
+	_assert(time.Since(start) > time.Second, "expect shutdown to wait for the inflight call")
+	<-call.Done
+	_assert(call.Error == nil, "expect the inflight call to complete, got %v", call.Error)
+	<-accepted
+	_assert(server.Readiness() == HealthDraining, "expect the server to be draining")
+	_, err = Dial("tcp", l.Addr().String(), &Option{ConnectTimeout: time.Second})
+	_assert(err != nil, "expect the listener to be closed")
+}
+
+type testRegistrar struct {
+	registered int32
+}
+
+func (p *testRegistrar) Name() string { return "registrar" }
+
+func (p *testRegistrar) Deregister() error {
+	atomic.StoreInt32(&p.registered, 0)
+	return nil
+}
+
+func (p *testRegistrar) Reregister() { atomic.StoreInt32(&p.registered, 1) }
+
+func TestServer_AdminHTTP(t *testing.T) {
+	server := NewServer()
+	logger := NewJSONLogger(ioutil.Discard, LevelInfo)
+	server.SetLogger(logger)
+	registrar := &testRegistrar{registered: 1}
+	server.AddPlugin(registrar)
+	mux := http.NewServeMux()
+	server.HandleAdminHTTP(mux, BearerToken("secret"))
+	do := func(method, path string, token string) int {
+		req := httptest.NewRequest(method, defaultAdminPath+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	_assert(do(http.MethodPost, "/drain", "") == http.StatusUnauthorized, "expect 401 without token")
+	_assert(do(http.MethodPost, "/drain", "wrong") == http.StatusUnauthorized, "expect 401 with a wrong token")
+	_assert(do(http.MethodGet, "/drain", "secret") == http.StatusMethodNotAllowed, "expect mutations to require POST")
+	_assert(do(http.MethodGet, "", "secret") == http.StatusOK, "expect status to succeed")
+
+	_assert(do(http.MethodPost, "/drain", "secret") == http.StatusOK, "expect drain to succeed")
+	_assert(server.Readiness() == HealthDraining, "expect the server to be draining")
+	_assert(atomic.LoadInt32(&registrar.registered) == 0, "expect drain to deregister")
+	_assert(do(http.MethodPost, "/undrain", "secret") == http.StatusOK, "expect undrain to succeed")
+	_assert(server.Readiness() == HealthServing, "expect the server to be serving")
+	_assert(atomic.LoadInt32(&registrar.registered) == 1, "expect undrain to reregister")
+
+	_assert(do(http.MethodPost, "/loglevel?level=debug", "secret") == http.StatusOK, "expect loglevel to succeed")
+	_assert(logger.Level() == LevelDebug, "expect the log level to be debug")
+	_assert(do(http.MethodPost, "/loglevel?level=loud", "secret") == http.StatusBadRequest, "expect an unknown level to be rejected")
+	_assert(do(http.MethodPost, "/ratelimit?burst=20&per_second=5", "secret") == http.StatusOK, "expect ratelimit to succeed")
+	_assert(server.currentConnRate() == connRateLimit{burst: 20, perSecond: 5}, "expect the rate limit to change")
+	_assert(do(http.MethodPost, "/timeout?handle=3s", "secret") == http.StatusOK, "expect timeout to succeed")
+	_assert(server.handleTimeout(0) == 3*time.Second && server.handleTimeout(time.Second) == time.Second,
+		"expect the shorter of the server and client timeouts")
+	_assert(do(http.MethodGet, "/goroutines", "secret") == http.StatusOK, "expect goroutines to succeed")
+}
@@ -0,0 +1,105 @@
+package geerpc
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded 表示调用方身份的请求速率或并发调用数超过了配额，客户端可以稍后重试
+var ErrQuotaExceeded = errors.New("rpc server: quota exceeded")
+
+// Quota 定义了一个调用方身份的配额，字段为 0 表示不限制
+type Quota struct {
+	RequestsPerSecond int // 每秒的请求数
+	Burst             int // 允许连续发送的请求数，为 0 时等于 RequestsPerSecond
+	MaxConcurrent     int // 同时处理中的请求数
+}
+
+// quotaLimiter 按调用方身份记录配额的使用情况
+type quotaLimiter struct {
+	mu        sync.Mutex
+	def       Quota
+	overrides map[string]Quota
+	usage     map[string]*quotaUsage
+}
+
+// quotaUsage 是一个身份的令牌桶和并发调用数
+type quotaUsage struct {
+	quota    Quota
+	bucket   *TokenBucket // 不限制速率时为 nil
+	inflight int
+}
+
+// SetQuotas 按认证得到的调用方身份（参见 SetTokenValidator 和 AcceptTLS）设置配额。
+// overrides 中列出的身份使用各自的配额，其余身份（包括未认证的空身份，它们共享一份配额）使用 def。
+// 超出配额的请求返回 ErrQuotaExceeded 并发布 RequestRejectedRateLimit 事件，其他身份的请求不受影响。
+// 再次调用会重置所有身份的用量，应在开始服务之前调用
+func (server *Server) SetQuotas(def Quota, overrides map[string]Quota) {
+	server.quotas.mu.Lock()
+	defer server.quotas.mu.Unlock()
+	server.quotas.def = def
+	server.quotas.overrides = overrides
+	server.quotas.usage = make(map[string]*quotaUsage)
+}
+
+// acquire 为 identity 的一个请求占用配额，成功时返回的 release 必须在请求处理结束后调用
+func (l *quotaLimiter) acquire(identity string) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.usage == nil {
+		return func() {}, nil
+	}
+	u, ok := l.usage[identity]
+	if !ok {
+		q, ok := l.overrides[identity]
+		if !ok {
+			q = l.def
+		}
+		u = &quotaUsage{quota: q}
+		if q.RequestsPerSecond > 0 {
+			burst := q.Burst
+			if burst <= 0 {
+				burst = q.RequestsPerSecond
+			}
+			u.bucket = newRateBucket(burst, q.RequestsPerSecond)
+		}
+		l.usage[identity] = u
+	}
+	if u.quota.MaxConcurrent > 0 && u.inflight >= u.quota.MaxConcurrent {
+		return nil, ErrQuotaExceeded
+	}
+	if u.bucket != nil && !u.bucket.Allow() {
+		return nil, ErrQuotaExceeded
+	}
+	u.inflight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			u.inflight--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// newRateBucket 创建容量为 burst、每秒补充 perSecond 个令牌的令牌桶。
+// 速率超过每毫秒一个令牌时改为每毫秒批量补充，避免补充间隔被截断为 0
+func newRateBucket(burst, perSecond int) *TokenBucket {
+	if interval := time.Second / time.Duration(perSecond); interval >= time.Millisecond {
+		return NewTokenBucket(burst, 1, interval)
+	}
+	return NewTokenBucket(burst, perSecond/1000, time.Millisecond)
+}
+
+// acquireQuota 检查请求的调用方是否还有配额，超出时记录日志并发布事件
+func (server *Server) acquireQuota(req *request) error {
+	release, err := server.quotas.acquire(req.identity)
+	if err != nil {
+		server.log().Warn("rpc server: quota exceeded", "identity", req.identity, "method", req.h.ServiceMethod)
+		server.events.Publish(RequestRejectedRateLimit{Time: time.Now(), Remote: req.remote, Identity: req.identity})
+		return err
+	}
+	req.release = release
+	return nil
+}
@@ -0,0 +1,83 @@
+package geerpc
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQuotaLimiter(t *testing.T) {
+	server := NewServer()
+	server.SetQuotas(Quota{RequestsPerSecond: 1, Burst: 2}, map[string]Quota{"batch": {MaxConcurrent: 1}})
+	l := &server.quotas
+
+	// alice 用完自己的配额后，bob 的请求不受影响
+	for i := 0; i < 2; i++ {
+		if _, err := l.acquire("alice"); err != nil {
+			t.Fatalf("expect request %d within the burst, got %v", i, err)
+		}
+	}
+	if _, err := l.acquire("alice"); err != ErrQuotaExceeded {
+		t.Fatalf("expect ErrQuotaExceeded after the burst, got %v", err)
+	}
+	if _, err := l.acquire("bob"); err != nil {
+		t.Fatalf("expect another identity to keep its own quota, got %v", err)
+	}
+
+	// 单独配置的身份只限制并发数
+	release, err := l.acquire("batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.acquire("batch"); err != ErrQuotaExceeded {
+		t.Fatalf("expect ErrQuotaExceeded while a request is in flight, got %v", err)
+	}
+	release()
+	release() // 重复调用 release 不会多释放
+	if release, err = l.acquire("batch"); err != nil {
+		t.Fatalf("expect a request after release, got %v", err)
+	}
+	if _, err := l.acquire("batch"); err != ErrQuotaExceeded {
+		t.Fatalf("expect a repeated release not to free extra slots, got %v", err)
+	}
+	release()
+}
+
+func TestServer_SetQuotas(t *testing.T) {
+	server := newAuthServer(StaticTokens{"a": "alice", "b": "bob"})
+	server.SetQuotas(Quota{RequestsPerSecond: 1}, nil)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	call := func(token string) error {
+		var identity string
+		return client.Call(WithCredentials(context.Background(), token), "Who.Identity", 1, &identity)
+	}
+	if err := call("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("a"); err == nil || err.Error() != ErrQuotaExceeded.Error() {
+		t.Fatalf("expect ErrQuotaExceeded for alice, got %v", err)
+	}
+	if err := call("b"); err != nil {
+		t.Fatalf("expect bob not to be limited by alice's usage, got %v", err)
+	}
+}
+
+func TestQuotaLimiter_HighRate(t *testing.T) {
+	// 速率超过每纳秒一个令牌时补充间隔不会被截断为 0
+	for _, rate := range []int{2e6, 2e9, math.MaxInt32} {
+		server := NewServer()
+		server.SetQuotas(Quota{RequestsPerSecond: rate, Burst: 1}, nil)
+		if _, err := server.quotas.acquire("alice"); err != nil {
+			t.Fatalf("rate %d: expect the first request to pass, got %v", rate, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+		if _, err := server.quotas.acquire("alice"); err != nil {
+			t.Fatalf("rate %d: expect the bucket to refill, got %v", rate, err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package geerpc
+
+import (
+	"encoding"
+	"encoding/gob"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServiceSchema 描述一个服务及其方法，由内置的 Reflection 服务返回
+type ServiceSchema struct {
+	Name    string
+	Methods []MethodSchema
+}
+
+// MethodSchema 描述一个方法的参数和返回值（返回值为 reply 指针指向的类型）
+type MethodSchema struct {
+	Name  string
+	Args  *TypeSchema
+	Reply *TypeSchema
+}
+
+// TypeSchema 描述一个类型在 gob 和 JSON 中的结构，调用方据此可以在没有 Go 类型定义的情况下构造参数和解码返回值，
+// 例如 geerpc-cli。Kind 的取值为：
+//   - bool、int、int8、int16、int32、int64、uint、uint8、uint16、uint32、uint64、float32、float64、string、bytes、time；
+//   - slice、array（Len）、map（Key）：元素类型为 Elem；
+//   - struct：导出的字段为 Fields；
+//   - opaque：自定义了 gob 编码的类型，recursive：递归引用自身的类型，interface：接口类型，这三种无法在调用方构造
+type TypeSchema struct {
+	Kind   string
+	Name   string // 具名类型的名字（包括包名），仅用于展示
+	Elem   *TypeSchema
+	Key    *TypeSchema
+	Len    int
+	Fields []FieldSchema
+}
+
+// FieldSchema 描述结构体的一个字段
+type FieldSchema struct {
+	Name string
+	Type *TypeSchema
+	JSON string // 字段在 JSON 中的名字（来自 json 标签），与 Name 相同时为空，"-" 表示 JSON 忽略该字段
+}
+
+// Reflection 是内置的反射服务，SetReflection(true) 后可以调用 "Reflection.List" 获取所有服务的方法和类型
+type Reflection struct {
+	server *Server
+}
+
+// List 返回服务器上注册的所有服务（包括内置服务），按名称排序，name 不为空时只返回该服务
+func (r *Reflection) List(name string, reply *[]ServiceSchema) error {
+	for _, schema := range r.server.Schema() {
+		if name == "" || schema.Name == name {
+			*reply = append(*reply, schema)
+		}
+	}
+	for _, s := range r.server.builtinServices() {
+		if name == "" || s.name == name {
+			*reply = append(*reply, serviceSchema(s))
+		}
+	}
+	sort.Slice(*reply, func(i, j int) bool { return (*reply)[i].Name < (*reply)[j].Name })
+	return nil
+}
+
+// Schema 返回服务器上通过 Register 注册的所有服务的结构（不包括内置服务），按名称排序，
+// 与 Reflection.List 的结果相同，但无需开启 SetReflection 和启动服务，例如在构建时生成 API 快照（参见 compat 包）
+func (server *Server) Schema() []ServiceSchema {
+	var schemas []ServiceSchema
+	server.serviceMap.Range(func(_, v interface{}) bool {
+		schemas = append(schemas, serviceSchema(v.(*service)))
+		return true
+	})
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// serviceSchema 返回服务的结构，方法按名称排序
+func serviceSchema(s *service) ServiceSchema {
+	schema := ServiceSchema{Name: s.name}
+	for mname, m := range s.method {
+		schema.Methods = append(schema.Methods, MethodSchema{
+			Name:  mname,
+			Args:  typeSchema(m.ArgType, nil),
+			Reply: typeSchema(m.ReplyType.Elem(), nil),
+		})
+	}
+	sort.Slice(schema.Methods, func(i, j int) bool { return schema.Methods[i].Name < schema.Methods[j].Name })
+	return schema
+}
+
+// SetReflection 设置是否提供内置的 Reflection 服务，默认不提供。
+// 它会暴露所有方法的参数和返回值结构，对外的服务器可以配合 SetAuthorizer 只允许内部调用方访问。
+// 可以在任何时候调用，对之后的请求生效
+func (server *Server) SetReflection(enable bool) {
+	server.builtinMu.Lock()
+	defer server.builtinMu.Unlock()
+	server.reflection = enable
+	server.buildBuiltin()
+}
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfGobEncoder    = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	typeOfBinaryMarshal = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// typeSchema 返回类型 t 的结构，seen 记录正在展开的结构体，用于发现递归类型
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) *TypeSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := &TypeSchema{Kind: t.Kind().String()}
+	if t.Name() != "" && t.PkgPath() != "" {
+		s.Name = t.String()
+	}
+	switch {
+	case t == typeOfTime:
+		s.Kind = "time"
+		return s
+	case t.Implements(typeOfGobEncoder), reflect.PtrTo(t).Implements(typeOfGobEncoder),
+		t.Implements(typeOfBinaryMarshal), reflect.PtrTo(t).Implements(typeOfBinaryMarshal):
+		s.Kind = "opaque"
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			s.Kind = "bytes"
+			return s
+		}
+		if t.Kind() == reflect.Array {
+			s.Len = t.Len()
+		}
+		s.Elem = typeSchema(t.Elem(), seen)
+	case reflect.Map:
+		s.Key = typeSchema(t.Key(), seen)
+		s.Elem = typeSchema(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			s.Kind = "recursive"
+			return s
+		}
+		if seen == nil {
+			seen = make(map[reflect.Type]bool)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue // gob 只编码导出的字段
+			}
+			switch f.Type.Kind() {
+			case reflect.Chan, reflect.Func:
+				continue // gob 忽略的字段
+			}
+			s.Fields = append(s.Fields, FieldSchema{Name: f.Name, Type: typeSchema(f.Type, seen), JSON: jsonFieldName(f)})
+		}
+	}
+	return s
+}
+
+// jsonFieldName 返回字段在 JSON 中的名字，与字段名相同时返回空字符串
+func jsonFieldName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "-"
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" && name != f.Name {
+		return name
+	}
+	return ""
+}
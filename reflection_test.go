@@ -0,0 +1,115 @@
+package geerpc
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Node 是一个引用自身的类型
+type Node struct {
+	Value    int
+	Children []*Node
+}
+
+// Item 覆盖 TypeSchema 支持的各类字段
+type Item struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"-"`
+	Data    []byte
+	Tags    map[string]bool
+	Point   [2]float64
+	Created time.Time
+	Big     *big.Int
+	Tree    Node
+	Notify  chan int // gob 忽略的字段
+	secret  string
+}
+
+type Catalog int
+
+func (Catalog) Get(id int64, reply *Item) error { return nil }
+
+func TestServer_Reflection(t *testing.T) {
+	server := NewServer()
+	var c Catalog
+	_ = server.Register(&c)
+	client, err := DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// 默认不提供 Reflection，处理过请求之后再开启同样生效
+	var schemas []ServiceSchema
+	if err := client.Call(context.Background(), "Reflection.List", "", &schemas); err == nil {
+		t.Fatal("expect Reflection to be disabled by default")
+	}
+	server.SetReflection(true)
+	if err := client.Call(context.Background(), "Reflection.List", "", &schemas); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range schemas {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Catalog", "Health", "Reflection"}) {
+		t.Fatalf("expect registered and builtin services in order, got %v", names)
+	}
+
+	schemas = nil
+	if err := client.Call(context.Background(), "Reflection.List", "Catalog", &schemas); err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 1 || len(schemas[0].Methods) != 1 || schemas[0].Methods[0].Name != "Get" {
+		t.Fatalf("expect Catalog.Get only, got %+v", schemas)
+	}
+	m := schemas[0].Methods[0]
+	if m.Args.Kind != "int64" || m.Reply.Kind != "struct" || m.Reply.Name != "geerpc.Item" {
+		t.Fatalf("unexpected method schema: args %+v, reply %+v", m.Args, m.Reply)
+	}
+
+	server.SetReflection(false)
+	if err := client.Call(context.Background(), "Reflection.List", "", &schemas); err == nil {
+		t.Fatal("expect Reflection to be disabled again")
+	}
+}
+
+func TestTypeSchema(t *testing.T) {
+	s := typeSchema(reflect.TypeOf(Item{}), nil)
+	fields := make(map[string]FieldSchema)
+	for _, f := range s.Fields {
+		fields[f.Name] = f
+	}
+	if _, ok := fields["Notify"]; ok {
+		t.Fatal("expect channel fields to be skipped")
+	}
+	if _, ok := fields["secret"]; ok {
+		t.Fatal("expect unexported fields to be skipped")
+	}
+	cases := map[string]string{
+		"ID": "int64", "Name": "string", "Data": "bytes", "Tags": "map",
+		"Point": "array", "Created": "time", "Big": "opaque", "Tree": "struct",
+	}
+	for name, kind := range cases {
+		if got := fields[name].Type.Kind; got != kind {
+			t.Fatalf("%s: expect kind %s, got %s", name, kind, got)
+		}
+	}
+	if fields["ID"].JSON != "id" || fields["Name"].JSON != "-" || fields["Data"].JSON != "" {
+		t.Fatalf("unexpected JSON names: %+v", s.Fields)
+	}
+	if p := fields["Point"].Type; p.Len != 2 || p.Elem.Kind != "float64" {
+		t.Fatalf("expect [2]float64, got %+v", p)
+	}
+	if m := fields["Tags"].Type; m.Key.Kind != "string" || m.Elem.Kind != "bool" {
+		t.Fatalf("expect map[string]bool, got %+v", m)
+	}
+	// Node 引用自身，展开到第二层时标记为 recursive
+	tree := fields["Tree"].Type
+	if len(tree.Fields) != 2 || tree.Fields[1].Type.Elem.Kind != "recursive" {
+		t.Fatalf("expect the recursive field to be marked, got %+v", tree.Fields)
+	}
+}
@@ -0,0 +1,173 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// SetAdminHTTP 挂载 <registryPath>/weight 和 <registryPath>/quarantine 管理接口，authorize 检查请求是否来自管理员，
+// 例如 geerpc.BearerToken(adminToken)，返回 false 的请求得到 401。管理员凭据与 SetToken 的共享令牌相互独立，
+// 持有共享令牌的服务器不能调整其他服务器的权重或隔离它们。authorize 为 nil（默认）时不挂载管理接口，请求得到 404
+func (r *GeeRegistry) SetAdminHTTP(authorize func(req *http.Request) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adminAuth = authorize
+}
+
+// serveAdmin 检查管理员凭据后将请求交给 h，未设置 SetAdminHTTP 时管理接口不可用
+func (r *GeeRegistry) serveAdmin(w http.ResponseWriter, req *http.Request, h http.HandlerFunc) {
+	r.mu.Lock()
+	authorize := r.adminAuth
+	r.mu.Unlock()
+	if authorize == nil {
+		writeJSON(w, http.StatusNotFound, apiError{"admin endpoints are disabled, see SetAdminHTTP"})
+		return
+	}
+	if !authorize(req) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="geerpc registry admin"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	h(w, req)
+}
+
+// weightOverride 是 <registryPath>/weight 的请求和响应格式
+type weightOverride struct {
+	Addr   string `json:"addr"`
+	Weight *int   `json:"weight"`
+}
+
+// SetWeight 由管理员设置服务器的权重，覆盖服务器心跳中携带的权重，并立即通知服务发现客户端。
+// 权重为 0 表示排空：服务器仍保持注册，但 GeeRegistryDiscovery 不会再把请求路由给它。
+// 服务器尚未注册时，覆盖会在其注册后生效
+func (r *GeeRegistry) SetWeight(addr string, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.weights == nil {
+		r.weights = make(map[string]int)
+	}
+	r.weights[addr] = weight
+	if s, ok := r.servers[addr]; ok && s.Meta.Weight != weight {
+		s.Meta.Weight = weight
+		r.bump(EventUpdate, s)
+	}
+}
+
+// ResetWeight 取消管理员为服务器设置的权重，服务器下一次心跳后恢复为自己声明的权重
+func (r *GeeRegistry) ResetWeight(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.weights, addr)
+}
+
+// serveWeight 处理 <registryPath>/weight：
+// GET 返回所有被覆盖的权重，PUT/POST 以 JSON 格式的 {"addr": ..., "weight": ...} 设置权重，
+// DELETE ?addr= 取消覆盖。权重覆盖只作用于当前注册中心，不会转发给集群中的其他成员
+func (r *GeeRegistry) serveWeight(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		r.mu.Lock()
+		overrides := make([]weightOverride, 0, len(r.weights))
+		for addr, weight := range r.weights {
+			weight := weight
+			overrides = append(overrides, weightOverride{Addr: addr, Weight: &weight})
+		}
+		r.mu.Unlock()
+		writeJSON(w, http.StatusOK, overrides)
+	case "PUT", "POST":
+		var o weightOverride
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodySize)).Decode(&o); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid weight: " + err.Error()})
+			return
+		}
+		if !validAddr(o.Addr) || o.Weight == nil || *o.Weight < 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{"addr and a non-negative weight are required"})
+			return
+		}
+		r.SetWeight(o.Addr, *o.Weight)
+		writeJSON(w, http.StatusOK, o)
+	case "DELETE":
+		addr := req.URL.Query().Get("addr")
+		if addr == "" {
+			writeJSON(w, http.StatusBadRequest, apiError{"addr is required"})
+			return
+		}
+		r.ResetWeight(addr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+	}
+}
+
+// Quarantine 隔离服务器：服务器保持注册并继续接收心跳，但不再出现在返回给服务发现客户端的服务器列表中，
+// 订阅者会收到该服务器的 leave 事件。用于故障期间立即将异常的实例从轮换中摘除
+func (r *GeeRegistry) Quarantine(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.quarantined == nil {
+		r.quarantined = make(map[string]bool)
+	}
+	if r.quarantined[addr] {
+		return
+	}
+	wasHidden := r.hidden(addr)
+	r.quarantined[addr] = true
+	if s, ok := r.servers[addr]; ok && !wasHidden {
+		r.bump(EventLeave, s)
+	}
+}
+
+// Unquarantine 解除对服务器的隔离，订阅者会收到该服务器的 join 事件
+func (r *GeeRegistry) Unquarantine(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.quarantined[addr] {
+		return
+	}
+	delete(r.quarantined, addr)
+	if s, ok := r.servers[addr]; ok {
+		r.bump(EventJoin, s)
+	}
+}
+
+// serveQuarantine 处理 <registryPath>/quarantine：
+// GET 返回所有被隔离的地址，PUT/POST 以 JSON 格式的 {"addr": ...} 隔离服务器，
+// DELETE ?addr= 解除隔离。隔离只作用于当前注册中心，不会转发给集群中的其他成员
+func (r *GeeRegistry) serveQuarantine(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		r.mu.Lock()
+		addrs := make([]string, 0, len(r.quarantined))
+		for addr := range r.quarantined {
+			addrs = append(addrs, addr)
+		}
+		r.mu.Unlock()
+		sort.Strings(addrs)
+		writeJSON(w, http.StatusOK, addrs)
+	case "PUT", "POST":
+		var reg Registration
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodySize)).Decode(&reg); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid request: " + err.Error()})
+			return
+		}
+		if !validAddr(reg.Addr) {
+			writeJSON(w, http.StatusBadRequest, apiError{"addr must be in the form protocol@addr"})
+			return
+		}
+		r.Quarantine(reg.Addr)
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		addr := req.URL.Query().Get("addr")
+		if addr == "" {
+			writeJSON(w, http.StatusBadRequest, apiError{"addr is required"})
+			return
+		}
+		r.Unquarantine(addr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+	}
+}
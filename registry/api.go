@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Registration 是 JSON API 中单个服务器的表示，
+// 元数据字段与 addr 平铺在同一个 JSON 对象中
+type Registration struct {
+	Addr string `json:"addr"`
+	Meta
+	Load *Load `json:"load,omitempty"` // 服务器在心跳中报告的负载
+}
+
+// Load 是服务器随心跳一起报告的当前负载。负载变化不会改变服务器列表的版本号，
+// 也不会唤醒长轮询和事件流，但会改变 GET <registryPath>/servers 的 ETag，
+// 服务发现客户端在下一次拉取服务器列表时能看到最新的负载
+type Load struct {
+	Inflight   int     `json:"inflight"`              // 正在处理的请求数
+	QueueDepth int     `json:"queue_depth,omitempty"` // 排队等待处理的请求数
+	CPU        float64 `json:"cpu,omitempty"`         // CPU 使用率，取值范围 0~1
+}
+
+// ServerList 是 GET <registryPath>/servers 返回的 JSON 格式
+type ServerList struct {
+	Index   uint64         `json:"index"` // 服务器列表的版本号，可用于长轮询
+	Servers []Registration `json:"servers"`
+}
+
+// maxWait 是长轮询请求的最长等待时间
+const maxWait = time.Minute * 5
+
+// apiError 是 JSON API 返回的错误格式
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON 以 JSON 格式写入响应
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// serveServers 处理 GET <registryPath>/servers。
+// 默认返回 JSON，Accept 只接受 text/plain 时返回每行一个地址的纯文本，
+// ?service=<name> 只返回提供该服务的服务器，?zone=<zone> 只返回位于该可用区的服务器。
+// 带上 ?index=N&wait=30s 时为长轮询：如果当前版本号仍为 N，
+// 请求会阻塞到成员发生变化或等待超时为止，响应头 X-Geerpc-Index 携带最新的版本号。
+// 响应头 ETag 由版本号和负载的版本号生成，If-None-Match 与之相同时返回 304
+func (r *GeeRegistry) serveServers(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+		return
+	}
+	q := req.URL.Query()
+	if q.Get("index") != "" {
+		index, err := strconv.ParseUint(q.Get("index"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid index: " + err.Error()})
+			return
+		}
+		wait, err := time.ParseDuration(q.Get("wait"))
+		if err != nil || wait <= 0 || wait > maxWait {
+			wait = maxWait
+		}
+		r.waitChange(req.Context(), index, wait)
+	}
+	// 先读取负载的版本号，之后报告的负载最多使 ETag 落后于内容，不会使客户端错过更新
+	loads := r.loadVersion()
+	index, items := r.snapshot(false)
+	items = filterItems(items, q)
+	w.Header().Set("X-Geerpc-Index", strconv.FormatUint(index, 10))
+	if notModified(w, req, strconv.FormatUint(index, 10)+"."+strconv.FormatUint(loads, 10)) {
+		return
+	}
+	if accept := req.Header.Get("Accept"); strings.Contains(accept, "text/plain") && !strings.Contains(accept, "json") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, s := range items {
+			_, _ = w.Write([]byte(s.Addr + "\n"))
+		}
+		return
+	}
+	list := ServerList{Index: index, Servers: make([]Registration, 0, len(items))}
+	for _, s := range items {
+		list.Servers = append(list.Servers, Registration{Addr: s.Addr, Meta: s.Meta, Load: s.Load})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// etagEpoch 区分不同的注册中心进程，避免注册中心重启后版本号从头计数导致 ETag 冲突
+var etagEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// notModified 根据版本 version 设置 ETag 响应头，如果请求的 If-None-Match 与之相同，
+// 说明客户端缓存的列表仍是最新的，直接返回 304 并返回 true
+func notModified(w http.ResponseWriter, req *http.Request, version string) bool {
+	etag := `"` + etagEpoch + "-" + version + `"`
+	w.Header().Set("ETag", etag)
+	for _, tag := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// filterItems 根据查询参数过滤服务器：?service= 只返回提供该服务的服务器，
+// ?zone= 只返回位于该可用区的服务器
+func filterItems(items []ServerItem, q url.Values) []ServerItem {
+	if q.Get("service") == "" && q.Get("zone") == "" {
+		return items
+	}
+	filtered := make([]ServerItem, 0, len(items))
+	for _, s := range items {
+		if s.Meta.matches(q) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// matches 判断服务器是否满足查询参数中的 service 和 zone 条件
+func (m Meta) matches(q url.Values) bool {
+	zone := q.Get("zone")
+	return m.Hosts(q.Get("service")) && (zone == "" || m.Zone == zone)
+}
+
+// waitChange 阻塞到版本号不再是 index、等待超时或请求被取消为止。
+// 过期的服务器只在读取列表时被清理，因此等待期间每秒检查一次
+func (r *GeeRegistry) waitChange(ctx context.Context, index uint64, wait time.Duration) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		current, changed := r.watch()
+		if current != index {
+			return
+		}
+		select {
+		case <-changed:
+		case <-tick.C:
+			r.aliveItems()
+		case <-timeout.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// serveRegister 处理 POST <registryPath>/register（注册或心跳）
+// 和 DELETE <registryPath>/register（注销），请求体为 JSON 格式的 Registration，
+// DELETE 也可以通过 ?addr= 指定地址
+func (r *GeeRegistry) serveRegister(w http.ResponseWriter, req *http.Request) {
+	var reg Registration
+	switch req.Method {
+	case "POST", "DELETE":
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+		return
+	}
+	if addr := req.URL.Query().Get("addr"); req.Method == "DELETE" && addr != "" {
+		reg.Addr = addr
+	} else {
+		if mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mt != "application/json" {
+			writeJSON(w, http.StatusUnsupportedMediaType, apiError{"content type must be application/json"})
+			return
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBodySize)).Decode(&reg); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{"invalid registration: " + err.Error()})
+			return
+		}
+	}
+	if reg.Addr == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{"addr is required"})
+		return
+	}
+	if !validAddr(reg.Addr) {
+		writeJSON(w, http.StatusBadRequest, apiError{"addr must be in the form protocol@addr"})
+		return
+	}
+	signed := reg
+	if req.Method == "DELETE" {
+		signed = Registration{Addr: reg.Addr} // 注销只对地址签名，与转发给其他注册中心的请求保持一致
+	}
+	if !r.verifySignature(req, req.Method, signed) {
+		writeJSON(w, http.StatusUnauthorized, apiError{"missing or invalid signature"})
+		return
+	}
+	if req.Method == "DELETE" {
+		r.replicate(req, "DELETE", reg)
+		if !r.removeServer(reg.Addr) {
+			writeJSON(w, http.StatusNotFound, apiError{"server not registered: " + reg.Addr})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	r.putServer(reg.Addr, reg.Meta)
+	if reg.Load != nil {
+		r.setLoad(reg.Addr, reg.Load)
+	}
+	r.replicate(req, "POST", reg)
+	writeJSON(w, http.StatusOK, reg)
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// servers 返回 GET <registryPath>/servers?query 的服务器地址
+func servers(t *testing.T, url, query string) []string {
+	t.Helper()
+	resp, err := http.Get(url + "/servers?" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var list ServerList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	addrs := make([]string, 0, len(list.Servers))
+	for _, s := range list.Servers {
+		addrs = append(addrs, s.Addr)
+	}
+	return addrs
+}
+
+// readEvents 订阅事件流，返回依次收到的事件，ctx 结束时关闭
+func readEvents(t *testing.T, client *Client, opt ListOptions) (<-chan Event, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	body, err := client.Events(ctx, opt)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	events := make(chan Event, 16)
+	go func() {
+		defer func() { _ = body.Close() }()
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				var e Event
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)
+				events <- e
+			}
+		}
+		close(events)
+	}()
+	return events, cancel
+}
+
+// nextEvent 返回下一个事件，一秒内没有收到时测试失败
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("expect an event")
+	}
+	return Event{}
+}
+
+func TestMeta_Hosts(t *testing.T) {
+	m := Meta{Services: []string{"Foo", "Bar"}}
+	if !m.Hosts("Foo") || !m.Hosts("") || m.Hosts("Baz") {
+		t.Fatal("expect Hosts to match the declared services only")
+	}
+	if !(Meta{}).Hosts("Baz") {
+		t.Fatal("expect servers without declared services to host any service")
+	}
+}
+
+func TestGeeRegistry_ServiceFilter(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+	_ = client.Register("tcp@127.0.0.1:1", Meta{Services: []string{"Foo"}})
+	_ = client.Register("tcp@127.0.0.1:2", Meta{Services: []string{"Bar"}})
+	_ = client.Register("tcp@127.0.0.1:3", Meta{}) // 旧版本的服务器，没有声明服务
+
+	if got := servers(t, ts.URL, "service=Foo"); !reflect.DeepEqual(got, []string{"tcp@127.0.0.1:1", "tcp@127.0.0.1:3"}) {
+		t.Fatalf("unexpected servers for Foo: %v", got)
+	}
+	list, _, err := client.List(ListOptions{Service: "Bar"})
+	if err != nil || len(list.Servers) != 2 || list.Servers[0].Addr != "tcp@127.0.0.1:2" {
+		t.Fatalf("unexpected servers for Bar: %+v, %v", list, err)
+	}
+	resp, err := http.Get(ts.URL + "?service=Bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("X-Geerpc-Servers"); got != "tcp@127.0.0.1:2,tcp@127.0.0.1:3" {
+		t.Fatalf("unexpected legacy servers for Bar: %q", got)
+	}
+
+	// 事件流只推送提供该服务的服务器的变化，不再提供该服务的服务器视为离开
+	events, cancel := readEvents(t, client, ListOptions{Service: "Foo"})
+	defer cancel()
+	if e := nextEvent(t, events); e.Type != EventSync || len(e.List.Servers) != 2 {
+		t.Fatalf("expect a sync event with 2 servers, got %+v", e)
+	}
+	_ = client.Register("tcp@127.0.0.1:4", Meta{Services: []string{"Bar"}})
+	_ = client.Register("tcp@127.0.0.1:5", Meta{Services: []string{"Foo"}})
+	if e := nextEvent(t, events); e.Type != EventJoin || e.Server.Addr != "tcp@127.0.0.1:5" {
+		t.Fatalf("expect only the Foo server to join, got %+v", e)
+	}
+	_ = client.Register("tcp@127.0.0.1:1", Meta{Services: []string{"Bar"}})
+	if e := nextEvent(t, events); e.Type != EventLeave || e.Server.Addr != "tcp@127.0.0.1:1" {
+		t.Fatalf("expect the server that dropped Foo to leave, got %+v", e)
+	}
+}
+
+func TestGeeRegistry_ZoneFilter(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+	_ = client.Register("tcp@127.0.0.1:1", Meta{Zone: "a", Services: []string{"Foo"}})
+	_ = client.Register("tcp@127.0.0.1:2", Meta{Zone: "a", Services: []string{"Bar"}})
+	_ = client.Register("tcp@127.0.0.1:3", Meta{Zone: "b", Services: []string{"Foo"}})
+
+	if got := servers(t, ts.URL, "zone=a"); !reflect.DeepEqual(got, []string{"tcp@127.0.0.1:1", "tcp@127.0.0.1:2"}) {
+		t.Fatalf("unexpected servers in zone a: %v", got)
+	}
+	if got := servers(t, ts.URL, "zone=a&service=Foo"); !reflect.DeepEqual(got, []string{"tcp@127.0.0.1:1"}) {
+		t.Fatalf("expect zone and service filters to combine, got %v", got)
+	}
+	if got := servers(t, ts.URL, "zone=c"); len(got) != 0 {
+		t.Fatalf("expect no servers in zone c, got %v", got)
+	}
+	resp, err := http.Get(ts.URL + "?zone=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Get("X-Geerpc-Servers"); got != "tcp@127.0.0.1:3" {
+		t.Fatalf("unexpected legacy servers in zone b: %q", got)
+	}
+
+	// 更换可用区的服务器对原可用区的订阅者而言相当于离开
+	events, cancel := readEvents(t, client, ListOptions{Zone: "b"})
+	defer cancel()
+	if e := nextEvent(t, events); e.Type != EventSync || len(e.List.Servers) != 1 {
+		t.Fatalf("expect a sync event with 1 server, got %+v", e)
+	}
+	_ = client.Register("tcp@127.0.0.1:4", Meta{Zone: "a"})
+	_ = client.Register("tcp@127.0.0.1:3", Meta{Zone: "a", Services: []string{"Foo"}})
+	if e := nextEvent(t, events); e.Type != EventLeave || e.Server.Addr != "tcp@127.0.0.1:3" {
+		t.Fatalf("expect the server that moved out of zone b to leave, got %+v", e)
+	}
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SetToken 设置注册中心的共享令牌。设置后，注册、心跳和注销请求必须携带该令牌，
+// protectReads 为 true 时查询服务器列表也需要令牌，token 为空表示关闭鉴权。
+// 令牌可以通过 "Authorization: Bearer <token>" 请求头传递，也可以作为 Basic 认证的密码，
+// 后者允许直接把令牌写在注册中心的地址中（例如 http://geerpc:<token>@host:9999/_geerpc_/registry），
+// 这样 Heartbeat 和 GeeRegistryDiscovery 无需任何改动即可携带令牌
+func (r *GeeRegistry) SetToken(token string, protectReads bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token = token
+	r.protectReads = protectReads
+}
+
+// authorized 检查请求是否携带了正确的令牌
+func (r *GeeRegistry) authorized(req *http.Request) bool {
+	r.mu.Lock()
+	token, protectReads := r.token, r.protectReads
+	r.mu.Unlock()
+	if token == "" || (req.Method == "GET" && !protectReads) {
+		return true
+	}
+	got := ""
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	} else if _, password, ok := req.BasicAuth(); ok {
+		got = password
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// signatureHeader 携带注册、心跳和注销请求的签名，格式为 "<密钥 ID>:<Unix 纳秒时间戳>:<十六进制 HMAC>"
+const signatureHeader = "X-Geerpc-Signature"
+
+// defaultSignatureSkew 是默认允许的签名时间戳偏差
+const defaultSignatureSkew = time.Minute * 5
+
+// SetSigningKeys 要求注册、心跳和注销请求携带服务器的签名，适用于无法通过 mTLS 保护注册中心的部署。
+// keys 根据请求中的密钥 ID 返回 HMAC 密钥，返回 nil 表示密钥 ID 未知；keys 为 nil 表示关闭签名校验。
+// 签名覆盖请求方法、时间戳、服务器地址、元数据和负载，时间戳与注册中心的时间相差超过 maxSkew（0 表示 5 分钟）
+// 或不晚于该服务器上一次通过校验的请求时，请求被拒绝，防止截获的请求被重放（包括在同一时刻内重放）。
+// 集群中转发的请求会携带原始签名，所有成员应使用相同的密钥
+func (r *GeeRegistry) SetSigningKeys(keys func(keyID string) []byte, maxSkew time.Duration) {
+	if maxSkew <= 0 {
+		maxSkew = defaultSignatureSkew
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signingKeys = keys
+	r.signatureSkew = maxSkew
+	r.lastSigned = make(map[string]int64)
+}
+
+// signRegistration 计算一次注册（POST）或注销（DELETE）的签名
+func signRegistration(key []byte, method string, timestamp int64, reg Registration) string {
+	body, _ := json.Marshal(reg)
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(method + "\n" + strconv.FormatInt(timestamp, 10) + "\n"))
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lastSignatureTime 是本进程上一次签名使用的纳秒时间戳
+var lastSignatureTime int64
+
+// signatureTime 返回严格递增的纳秒时间戳，同一进程连续发送的请求不会得到相同的时间戳
+func signatureTime() int64 {
+	for {
+		last := atomic.LoadInt64(&lastSignatureTime)
+		ts := time.Now().UnixNano()
+		if ts <= last {
+			ts = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastSignatureTime, last, ts) {
+			return ts
+		}
+	}
+}
+
+// signatureValue 返回请求签名头的值
+func signatureValue(keyID string, key []byte, method string, reg Registration) string {
+	ts := signatureTime()
+	return keyID + ":" + strconv.FormatInt(ts, 10) + ":" + signRegistration(key, method, ts, reg)
+}
+
+// verifySignature 检查写请求的签名，未启用签名校验时总是返回 true
+func (r *GeeRegistry) verifySignature(req *http.Request, method string, reg Registration) bool {
+	r.mu.Lock()
+	keys, skew := r.signingKeys, r.signatureSkew
+	r.mu.Unlock()
+	if keys == nil {
+		return true
+	}
+	parts := strings.Split(req.Header.Get(signatureHeader), ":")
+	if len(parts) != 3 {
+		return false
+	}
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := time.Since(time.Unix(0, ts)); d > skew || d < -skew {
+		return false
+	}
+	key := keys(parts[0])
+	if key == nil || !hmac.Equal([]byte(parts[2]), []byte(signRegistration(key, method, ts, reg))) {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ts <= r.lastSigned[reg.Addr] {
+		return false
+	}
+	if len(r.lastSigned) >= maxLimitSources {
+		// 只清除已经超出时间偏差的记录，这些时间戳的请求本身就会被拒绝，清除后不会被重放
+		expired := time.Now().Add(-skew).UnixNano()
+		for addr, last := range r.lastSigned {
+			if last < expired {
+				delete(r.lastSigned, addr)
+			}
+		}
+	}
+	r.lastSigned[reg.Addr] = ts
+	return true
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotModified 表示服务器列表自 ListOptions.ETag 对应的版本以来没有变化
+var ErrNotModified = errors.New("rpc registry: server list not modified")
+
+// Client 是注册中心 HTTP API 的客户端，封装了注册、注销和查询服务器列表的请求
+type Client struct {
+	URL        string       // 注册中心的地址，即 <registryPath> 的完整 URL
+	Token      string       // 注册中心的共享令牌，为空时不携带（也可以写在 URL 的密码中）
+	HTTPClient *http.Client // 为 nil 时使用 http.DefaultClient
+
+	// SigningKey 不为 nil 时对注册、心跳和注销请求签名，注册中心需要使用 SetSigningKeys 设置对应的密钥
+	SigningKeyID string
+	SigningKey   []byte
+}
+
+// NewClient 创建一个访问 registryURL 上的注册中心的 Client
+func NewClient(registryURL string) *Client {
+	return &Client{URL: registryURL}
+}
+
+// ListOptions 是 List 和 Watch 的查询条件
+type ListOptions struct {
+	Service string // 只返回提供该服务的服务器
+	Zone    string // 只返回位于该可用区的服务器
+	ETag    string // 上一次 List 得到的 ETag，列表没有变化时 List 返回 ErrNotModified
+}
+
+// Register 注册服务器或发送一次心跳
+func (c *Client) Register(addr string, meta Meta) error {
+	return c.register(Registration{Addr: addr, Meta: meta})
+}
+
+// RegisterWithLoad 与 Register 相同，但同时报告服务器当前的负载
+func (c *Client) RegisterWithLoad(addr string, meta Meta, load Load) error {
+	return c.register(Registration{Addr: addr, Meta: meta, Load: &load})
+}
+
+// register 发送 POST <registryPath>/register 请求
+func (c *Client) register(reg Registration) error {
+	body, _ := json.Marshal(reg)
+	req, _ := http.NewRequest("POST", c.endpoint("/register", nil), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, "POST", reg)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("rpc registry: register returned " + resp.Status)
+	}
+	return nil
+}
+
+// Deregister 从注册中心注销服务器，服务器未注册时不视为错误
+func (c *Client) Deregister(addr string) error {
+	req, _ := http.NewRequest("DELETE", c.endpoint("/register", url.Values{"addr": {addr}}), nil)
+	c.sign(req, "DELETE", Registration{Addr: addr})
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return errors.New("rpc registry: deregister returned " + resp.Status)
+	}
+	return nil
+}
+
+// List 返回满足 opt 的服务器列表以及列表的 ETag
+func (c *Client) List(opt ListOptions) (*ServerList, string, error) {
+	return c.list(context.Background(), opt, nil)
+}
+
+// Watch 以长轮询的方式等待服务器列表的版本号不再是 index，最多等待 wait，
+// 然后返回最新的服务器列表。index 为 0 时立即返回
+func (c *Client) Watch(ctx context.Context, index uint64, wait time.Duration, opt ListOptions) (*ServerList, error) {
+	q := url.Values{}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", wait.String())
+	}
+	opt.ETag = ""
+	list, _, err := c.list(ctx, opt, q)
+	return list, err
+}
+
+// list 发送 GET <registryPath>/servers 请求并解析返回的服务器列表
+func (c *Client) list(ctx context.Context, opt ListOptions, q url.Values) (*ServerList, string, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	if opt.Service != "" {
+		q.Set("service", opt.Service)
+	}
+	if opt.Zone != "" {
+		q.Set("zone", opt.Zone)
+	}
+	req, _ := http.NewRequest("GET", c.endpoint("/servers", q), nil)
+	req = req.WithContext(ctx)
+	if opt.ETag != "" {
+		req.Header.Set("If-None-Match", opt.ETag)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, opt.ETag, ErrNotModified
+	default:
+		return nil, "", errors.New("rpc registry: list returned " + resp.Status)
+	}
+	var list ServerList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", err
+	}
+	return &list, resp.Header.Get("ETag"), nil
+}
+
+// Events 订阅满足 opt 的服务器的成员变化事件流（GET <registryPath>/events），
+// 返回 Server-Sent Events 格式的响应体，调用方读取完毕后需要关闭，ctx 结束时连接被断开
+func (c *Client) Events(ctx context.Context, opt ListOptions) (io.ReadCloser, error) {
+	q := url.Values{}
+	if opt.Service != "" {
+		q.Set("service", opt.Service)
+	}
+	if opt.Zone != "" {
+		q.Set("zone", opt.Zone)
+	}
+	req, _ := http.NewRequest("GET", c.endpoint("/events", q), nil)
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.New("rpc registry: subscribe returned " + resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// endpoint 返回注册中心上 path 对应的地址
+func (c *Client) endpoint(path string, q url.Values) string {
+	target := strings.TrimSuffix(c.URL, "/") + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	return target
+}
+
+// sign 为写请求添加签名
+func (c *Client) sign(req *http.Request, method string, reg Registration) {
+	if c.SigningKey != nil {
+		req.Header.Set(signatureHeader, signatureValue(c.SigningKeyID, c.SigningKey, method, reg))
+	}
+}
+
+// do 携带令牌发送请求
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
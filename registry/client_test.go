@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	r := New(time.Minute)
+	r.SetToken("secret", true)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	if _, _, err := NewClient(ts.URL).List(ListOptions{}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expect a 401 error without the token, got %v", err)
+	}
+	var requests int32
+	client := &Client{URL: ts.URL + "/", Token: "secret", HTTPClient: &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&requests, 1)
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}}
+	if err := client.Register("tcp@127.0.0.1:1", Meta{Zone: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RegisterWithLoad("tcp@127.0.0.1:2", Meta{Zone: "b"}, Load{Inflight: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Fatalf("expect requests to go through the custom HTTP client, got %d", requests)
+	}
+	if err := client.Register("not-an-addr", Meta{}); err == nil {
+		t.Fatal("expect an error for an invalid address")
+	}
+
+	list, etag, err := client.List(ListOptions{Zone: "b"})
+	if err != nil || len(list.Servers) != 1 || list.Servers[0].Load == nil || list.Servers[0].Load.Inflight != 3 || etag == "" {
+		t.Fatalf("unexpected list %+v, etag %q, err %v", list, etag, err)
+	}
+	if _, got, err := client.List(ListOptions{Zone: "b", ETag: etag}); err != ErrNotModified || got != etag {
+		t.Fatalf("expect ErrNotModified with the same ETag, got %q, %v", got, err)
+	}
+
+	// Watch 在 index 为 0 时立即返回，否则等到版本号变化
+	list, err = client.Watch(context.Background(), 0, time.Minute, ListOptions{})
+	if err != nil || len(list.Servers) != 2 {
+		t.Fatalf("unexpected list %+v, err %v", list, err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = client.Deregister("tcp@127.0.0.1:1")
+	}()
+	start := time.Now()
+	changed, err := client.Watch(context.Background(), list.Index, time.Minute, ListOptions{})
+	if err != nil || changed.Index <= list.Index || len(changed.Servers) != 1 || time.Since(start) > 5*time.Second {
+		t.Fatalf("expect Watch to return after the deregistration, got %+v, err %v", changed, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Watch(ctx, changed.Index, time.Minute, ListOptions{}); err == nil {
+		t.Fatal("expect Watch to end with the context")
+	}
+
+	events, cancelEvents := readEvents(t, client, ListOptions{Zone: "b"})
+	defer cancelEvents()
+	if e := nextEvent(t, events); e.Type != EventSync || len(e.List.Servers) != 1 {
+		t.Fatalf("expect a sync event, got %+v", e)
+	}
+	if _, err := NewClient(ts.URL).Events(context.Background(), ListOptions{}); err == nil {
+		t.Fatal("expect an error subscribing without the token")
+	}
+}
+
+// roundTripFunc 将普通函数适配为 http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// replicatedHeader 标记由其他注册中心转发的请求，收到后不再继续转发，避免循环
+const replicatedHeader = "X-Geerpc-Replicated"
+
+// peerClient 是向其他注册中心转发请求使用的 HTTP 客户端
+var peerClient = &http.Client{Timeout: time.Second * 5}
+
+// SetPeers 设置集群中其他注册中心的地址（即各自 <registryPath> 的完整 URL）。
+// 设置后，本注册中心收到的注册、心跳和注销都会异步转发给其他成员，
+// 客户端可以连接集群中的任意一个注册中心。
+// 设置了 SetToken 时，转发和 SyncFromPeers 的请求携带本注册中心的令牌，集群的所有成员应使用相同的令牌
+func (r *GeeRegistry) SetPeers(peers ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers = make([]string, 0, len(peers))
+	for _, p := range peers {
+		r.peers = append(r.peers, strings.TrimSuffix(p, "/"))
+	}
+}
+
+// replicate 将一次注册（POST）或注销（DELETE）异步转发给所有其他注册中心，
+// 由其他注册中心转发来的请求不会再次转发
+func (r *GeeRegistry) replicate(req *http.Request, method string, reg Registration) {
+	if req.Header.Get(replicatedHeader) != "" {
+		return
+	}
+	r.mu.Lock()
+	peers := r.peers
+	r.mu.Unlock()
+	for _, peer := range peers {
+		go func(peer string) {
+			var preq *http.Request
+			if method == "DELETE" {
+				preq, _ = http.NewRequest("DELETE", peer+"/register?addr="+url.QueryEscape(reg.Addr), nil)
+			} else {
+				body, _ := json.Marshal(reg)
+				preq, _ = http.NewRequest("POST", peer+"/register", bytes.NewReader(body))
+				preq.Header.Set("Content-Type", "application/json")
+			}
+			preq.Header.Set(replicatedHeader, "1")
+			if sig := req.Header.Get(signatureHeader); sig != "" {
+				preq.Header.Set(signatureHeader, sig)
+			}
+			resp, err := r.doPeer(preq)
+			if err != nil {
+				r.log().Error("rpc registry: replicate err", "peer", peer, "err", err)
+				return
+			}
+			_ = resp.Body.Close()
+		}(peer)
+	}
+}
+
+// SyncFromPeers 从其他注册中心拉取服务器列表，补充本地缺少的服务器，
+// 用于注册中心启动或网络分区恢复后追上集群的状态
+func (r *GeeRegistry) SyncFromPeers() error {
+	r.mu.Lock()
+	peers := r.peers
+	r.mu.Unlock()
+	var errs []string
+	for _, peer := range peers {
+		preq, err := http.NewRequest("GET", peer+"/servers", nil)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		resp, err := r.doPeer(preq)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		var list ServerList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		_ = resp.Body.Close()
+		if err != nil {
+			errs = append(errs, peer+": "+err.Error())
+			continue
+		}
+		for _, s := range list.Servers {
+			r.mu.Lock()
+			_, ok := r.servers[s.Addr]
+			r.mu.Unlock()
+			if !ok {
+				r.putServer(s.Addr, s.Meta)
+			}
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(peers) {
+		return errors.New("rpc registry: sync from peers failed: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// doPeer 向其他注册中心发送请求，设置了令牌时携带令牌。响应的状态码不是 2xx 时关闭响应并返回错误
+func (r *GeeRegistry) doPeer(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	token := r.token
+	r.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("rpc registry: %s %s%s: %s", req.Method, req.URL.Host, req.URL.Path, resp.Status)
+	}
+	return resp, nil
+}
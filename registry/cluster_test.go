@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitServers 等待注册中心的活动服务器数量变为 n
+func waitServers(t *testing.T, r *GeeRegistry, n int) {
+	t.Helper()
+	for i := 0; i < 100 && len(r.aliveServers()) != n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if servers := r.aliveServers(); len(servers) != n {
+		t.Fatalf("expect %d servers, got %v", n, servers)
+	}
+}
+
+func TestGeeRegistry_Replicate(t *testing.T) {
+	a, b := New(time.Minute), New(time.Minute)
+	tsA, tsB := httptest.NewServer(a), httptest.NewServer(b)
+	defer tsA.Close()
+	defer tsB.Close()
+	a.SetPeers(tsB.URL + "/")
+	b.SetPeers(tsA.URL)
+
+	if err := NewClient(tsA.URL).Register("tcp@127.0.0.1:9999", Meta{Zone: "z1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitServers(t, b, 1)
+	if items := b.aliveItems(); items[0].Meta.Zone != "z1" {
+		t.Fatalf("expect the metadata to be replicated, got %+v", items[0].Meta)
+	}
+	// 转发来的请求不会被再次转发，a 只收到客户端的一次注册
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadUint64(&a.metrics.heartbeats); n != 1 {
+		t.Fatalf("expect the replicated request not to bounce back, got %d heartbeats on a", n)
+	}
+
+	// 旧的请求头协议同样被转发
+	req, _ := http.NewRequest("POST", tsA.URL, nil)
+	req.Header.Set("X-Geerpc-Server", "tcp@127.0.0.1:8888")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("legacy register failed: %v %v", err, resp)
+	}
+	waitServers(t, b, 2)
+
+	// 在任意成员上注销都会传播到整个集群
+	if err := NewClient(tsB.URL).Deregister("tcp@127.0.0.1:9999"); err != nil {
+		t.Fatal(err)
+	}
+	waitServers(t, a, 1)
+}
+
+func TestGeeRegistry_SyncFromPeers(t *testing.T) {
+	a := New(time.Minute)
+	tsA := httptest.NewServer(a)
+	defer tsA.Close()
+	a.putServer("tcp@127.0.0.1:9999", Meta{Weight: 3})
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// 部分成员不可用时仍然从其他成员同步
+	c := New(time.Minute)
+	c.putServer("tcp@127.0.0.1:9999", Meta{Weight: 7})
+	c.putServer("tcp@127.0.0.1:7777", Meta{})
+	c.SetPeers(dead.URL, tsA.URL)
+	if err := c.SyncFromPeers(); err != nil {
+		t.Fatal(err)
+	}
+	items := c.aliveItems()
+	if len(items) != 2 || items[1].Meta.Weight != 7 {
+		t.Fatalf("expect existing servers to be kept as they are, got %+v", items)
+	}
+
+	d := New(time.Minute)
+	d.SetPeers(dead.URL, tsA.URL)
+	if err := d.SyncFromPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if items := d.aliveItems(); len(items) != 1 || items[0].Meta.Weight != 3 {
+		t.Fatalf("expect the peer's server to be synced, got %+v", items)
+	}
+
+	// 所有成员都不可用时返回错误
+	d.SetPeers(dead.URL)
+	if err := d.SyncFromPeers(); err == nil {
+		t.Fatal("expect an error when no peer is reachable")
+	}
+}
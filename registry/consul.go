@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"geerpc"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulCheck 是 Consul 的健康检查定义
+type consulCheck struct {
+	TCP                            string
+	Interval                       string
+	Timeout                        string
+	DeregisterCriticalServiceAfter string
+}
+
+// consulService 是 Consul 的服务注册定义
+type consulService struct {
+	ID      string
+	Name    string
+	Address string
+	Port    int
+	Meta    map[string]string
+	Check   consulCheck
+}
+
+// ConsulRegister 将 rpcAddr（protocol@addr 格式）注册为 Consul 中名为 service 的服务实例，
+// 并由 Consul 每隔 interval 对其进行 TCP 健康检查，检查持续失败的实例会被 Consul 自动注销。
+// 返回的函数用于在服务器关闭时主动注销
+func ConsulRegister(consulAddr, service, rpcAddr string, interval time.Duration) (deregister func() error, err error) {
+	if interval == 0 {
+		interval = time.Second * 10
+	}
+	protocol, addr := "tcp", rpcAddr
+	if parts := strings.SplitN(rpcAddr, "@", 2); len(parts) == 2 {
+		protocol, addr = parts[0], parts[1]
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "" // 监听在所有地址上时，使用 Consul 节点的地址
+	}
+	checkHost := host
+	if checkHost == "" {
+		checkHost = "127.0.0.1"
+	}
+	consulAddr = strings.TrimSuffix(consulAddr, "/")
+	svc := consulService{
+		ID:      service + "-" + rpcAddr,
+		Name:    service,
+		Address: host,
+		Port:    port,
+		Meta:    map[string]string{"protocol": protocol},
+		Check: consulCheck{
+			TCP:                            net.JoinHostPort(checkHost, portStr),
+			Interval:                       interval.String(),
+			Timeout:                        (interval / 2).String(),
+			DeregisterCriticalServiceAfter: (interval * 6).String(),
+		},
+	}
+	body, _ := json.Marshal(svc)
+	if err := consulPut(consulAddr+"/v1/agent/service/register", body); err != nil {
+		geerpc.DefaultLogger().Error("rpc server: consul register err", "err", err)
+		return nil, err
+	}
+	geerpc.DefaultLogger().Info("rpc server: registered to consul", "addr", rpcAddr, "consul", consulAddr, "service", service)
+	return func() error {
+		return consulPut(consulAddr+"/v1/agent/service/deregister/"+url.PathEscape(svc.ID), nil)
+	}, nil
+}
+
+// consulPut 向 Consul agent 发送 PUT 请求
+func consulPut(target string, body []byte) error {
+	req, _ := http.NewRequest("PUT", target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc registry: consul returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 成员变化事件的类型
+const (
+	EventSync   = "sync"   // 订阅建立时推送的完整服务器列表
+	EventJoin   = "join"   // 新服务器注册
+	EventLeave  = "leave"  // 服务器注销或过期
+	EventUpdate = "update" // 服务器元数据变化
+)
+
+// Event 是注册中心推送的成员变化事件
+type Event struct {
+	Type   string       `json:"type"`
+	Index  uint64       `json:"index"`          // 事件发生后服务器列表的版本号
+	Server Registration `json:"server"`         // 发生变化的服务器，sync 事件中为空
+	List   *ServerList  `json:"list,omitempty"` // 仅 sync 事件携带完整列表
+}
+
+// eventBuffer 是每个订阅者的事件缓冲区大小，缓冲区满时断开该订阅者，由其重新订阅
+const eventBuffer = 64
+
+// subscribe 订阅成员变化事件，返回订阅时按 q 过滤后的完整服务器列表
+func (r *GeeRegistry) subscribe(q url.Values) (chan Event, ServerList) {
+	_, items := r.snapshot(false)
+	items = filterItems(items, q)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch := make(chan Event, eventBuffer)
+	r.subs[ch] = struct{}{}
+	list := ServerList{Index: r.index, Servers: make([]Registration, 0, len(items))}
+	for _, s := range items {
+		list.Servers = append(list.Servers, Registration{Addr: s.Addr, Meta: s.Meta, Load: s.Load})
+	}
+	return ch, list
+}
+
+// unsubscribe 取消订阅
+func (r *GeeRegistry) unsubscribe(ch chan Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[ch]; ok {
+		delete(r.subs, ch)
+		close(ch)
+	}
+}
+
+// publish 向所有订阅者推送事件，调用方需持有 r.mu。
+// 跟不上的订阅者会被断开，重新订阅时会收到完整列表，从而不会丢失状态
+func (r *GeeRegistry) publish(e Event) {
+	for ch := range r.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(r.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// serveEvents 处理 GET <registryPath>/events，以 Server-Sent Events 的形式推送成员变化。
+// 连接建立后先推送一个携带完整列表的 sync 事件，之后推送 join/leave/update 事件，
+// ?service=<name> 和 ?zone=<zone> 只推送满足条件的服务器的变化
+func (r *GeeRegistry) serveEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, apiError{"streaming unsupported"})
+		return
+	}
+	q := req.URL.Query()
+	ch, list := r.subscribe(req.URL.Query())
+	defer r.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeEvent(w, Event{Type: EventSync, Index: list.Index, List: &list})
+	flusher.Flush()
+
+	// 过期的服务器只在读取列表时被清理，因此定期检查以便及时推送 leave 事件
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !e.Server.Meta.matches(q) {
+				if e.Type != EventUpdate {
+					continue
+				}
+				// 服务器不再提供该服务或更换了可用区，对该订阅者而言相当于离开
+				e.Type = EventLeave
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-tick.C:
+			r.aliveItems()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent 以 SSE 格式写入一个事件
+func writeEvent(w http.ResponseWriter, e Event) {
+	data, _ := json.Marshal(e)
+	_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Index, e.Type, data)
+}
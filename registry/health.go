@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"strings"
+	"time"
+)
+
+// errNoHealthService 表示服务器关闭了内置的 Health 服务（参见 geerpc.Server.SetHealthService），无法主动检查
+var errNoHealthService = errors.New("rpc registry: server does not provide the Health service")
+
+// SetHealthCheck 开启主动健康检查：每隔 interval 对所有注册的服务器调用内置的 "Health.Check"，
+// 在 timeout 内没有返回 geerpc.HealthServing 的服务器会被摘除，而不是只依赖进程发来的心跳
+// （进程存活不代表它的 RPC 监听器仍能处理请求）。被摘除的服务器继续接收心跳，但直到检查再次通过前
+// 都不会出现在服务器列表中。关闭了 Health 服务的服务器不参与主动检查。
+// interval 为 0 表示关闭主动健康检查
+func (r *GeeRegistry) SetHealthCheck(interval, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopHealth != nil {
+		close(r.stopHealth)
+		r.stopHealth = nil
+	}
+	if interval == 0 {
+		return
+	}
+	if timeout == 0 {
+		timeout = time.Second * 3
+	}
+	stop := make(chan struct{})
+	r.stopHealth = stop
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				r.checkServers(timeout)
+			}
+		}
+	}()
+}
+
+// SetHealthCheckOption 设置主动健康检查连接服务器时使用的 Option，
+// 服务器要求 TLS、凭证或签名时需要在其中设置 TLSConfig、Credentials 或签名密钥。
+// ConnectTimeout 总是使用 SetHealthCheck 的 timeout
+func (r *GeeRegistry) SetHealthCheckOption(opt *geerpc.Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthOpt = opt
+}
+
+// checkServers 并发检查所有服务器，摘除检查失败的服务器，恢复检查重新通过的服务器
+func (r *GeeRegistry) checkServers(timeout time.Duration) {
+	r.mu.Lock()
+	opt := r.healthOpt
+	r.mu.Unlock()
+	items := r.aliveItems()
+	done := make(chan struct{}, len(items))
+	for _, s := range items {
+		go func(addr string) {
+			defer func() { done <- struct{}{} }()
+			err := checkServer(addr, opt, timeout)
+			if err == errNoHealthService {
+				r.log().Debug("rpc registry: skip health check", "addr", addr, "err", err)
+				err = nil
+			}
+			if err != nil {
+				r.log().Warn("rpc registry: health check failed", "addr", addr, "err", err)
+			}
+			r.setHealthy(addr, err == nil)
+		}(s.Addr)
+	}
+	for range items {
+		<-done
+	}
+}
+
+// setHealthy 记录服务器的健康检查结果，状态变化时通知长轮询请求和订阅者
+func (r *GeeRegistry) setHealthy(addr string, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.servers[addr]
+	if !ok || healthy != r.unhealthy[addr] {
+		return // 服务器已被移除，或状态没有变化
+	}
+	if healthy {
+		delete(r.unhealthy, addr)
+		r.bump(EventJoin, s)
+		return
+	}
+	wasHidden := r.hidden(addr)
+	if r.unhealthy == nil {
+		r.unhealthy = make(map[string]bool)
+	}
+	r.unhealthy[addr] = true
+	if !wasHidden {
+		r.bump(EventLeave, s)
+	}
+}
+
+// checkServer 使用 opt 连接服务器并调用 Health.Check
+func checkServer(addr string, opt *geerpc.Option, timeout time.Duration) error {
+	dialOpt := *geerpc.DefaultOption
+	if opt != nil {
+		dialOpt = *opt
+	}
+	dialOpt.ConnectTimeout = timeout
+	client, err := geerpc.XDial(addr, &dialOpt)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var status string
+	if err := client.Call(ctx, "Health.Check", "", &status); err != nil {
+		if se, ok := err.(geerpc.ServerError); ok && strings.HasPrefix(string(se), "rpc server: can't find service Health") {
+			return errNoHealthService
+		}
+		return err
+	}
+	if status != geerpc.HealthServing {
+		return errors.New("status " + status)
+	}
+	return nil
+}
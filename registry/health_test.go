@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"geerpc"
+	"net"
+	"testing"
+	"time"
+)
+
+// startHealthServer 启动一个 geerpc 服务器，返回它的 RPC 地址
+func startHealthServer(t *testing.T, server *geerpc.Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return "tcp@" + l.Addr().String()
+}
+
+// visible 判断 addr 是否出现在返回给服务发现客户端的服务器列表中
+func visible(r *GeeRegistry, addr string) bool {
+	_, items := r.snapshot(false)
+	for _, s := range items {
+		if s.Addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGeeRegistry_HealthCheck(t *testing.T) {
+	server := geerpc.NewServer()
+	addr := startHealthServer(t, server)
+	r := New(time.Minute)
+	r.putServer(addr, Meta{})
+	index, _ := r.watch()
+
+	r.checkServers(time.Second)
+	if !visible(r, addr) {
+		t.Fatal("expect a serving server to stay in the list")
+	}
+
+	// 检查失败的服务器被摘除，之后的心跳不会使它重新出现
+	server.SetServingStatus(geerpc.HealthNotServing)
+	r.checkServers(time.Second)
+	r.putServer(addr, Meta{})
+	if visible(r, addr) {
+		t.Fatal("expect a failing server to stay out of the list despite heartbeats")
+	}
+	if current, _ := r.watch(); current == index {
+		t.Fatal("expect the index to change when a server is taken out")
+	}
+
+	// 检查再次通过后恢复
+	server.SetServingStatus(geerpc.HealthServing)
+	r.checkServers(time.Second)
+	if !visible(r, addr) {
+		t.Fatal("expect the server to come back once the check passes")
+	}
+
+	// 无法连接的服务器同样被摘除，但仍然保持注册
+	const dead = "tcp@127.0.0.1:1"
+	r.putServer(dead, Meta{})
+	r.checkServers(time.Second)
+	if visible(r, dead) || len(r.aliveItems()) != 2 {
+		t.Fatalf("expect %s to be hidden but still registered, got %v", dead, r.aliveItems())
+	}
+	r.removeServer(dead)
+	r.putServer(dead, Meta{})
+	if !visible(r, dead) {
+		t.Fatal("expect a re-registered server to start healthy")
+	}
+}
+
+func TestGeeRegistry_HealthCheckOption(t *testing.T) {
+	server := geerpc.NewServer()
+	server.SetTokenValidator(geerpc.StaticTokens{"good": "registry"})
+	addr := startHealthServer(t, server)
+	r := New(time.Minute)
+	r.putServer(addr, Meta{})
+
+	r.checkServers(time.Second)
+	if visible(r, addr) {
+		t.Fatal("expect the check to fail without credentials")
+	}
+	opt := *geerpc.DefaultOption
+	opt.Credentials = "good"
+	r.SetHealthCheckOption(&opt)
+	r.checkServers(time.Second)
+	if !visible(r, addr) {
+		t.Fatal("expect the check to pass with the configured credentials")
+	}
+}
+
+func TestGeeRegistry_HealthCheckDisabled(t *testing.T) {
+	// 关闭了 Health 服务的服务器不参与主动检查
+	server := geerpc.NewServer()
+	server.SetHealthService(false)
+	addr := startHealthServer(t, server)
+	r := New(time.Minute)
+	r.putServer(addr, Meta{})
+	r.checkServers(time.Second)
+	if !visible(r, addr) {
+		t.Fatal("expect a server without the Health service to be kept")
+	}
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"geerpc"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxBodySize     = 64 << 10 // JSON API 请求体的最大字节数
+	maxAddrLen      = 256      // 服务器地址的最大长度
+	maxMetaLen      = 16 << 10 // X-Geerpc-Meta 请求头的最大长度
+	maxLimitSources = 4096     // 限流时最多记录的来源数量，超过后清空重新计数
+)
+
+// writeLimiter 按来源 IP 限制写请求的速率
+type writeLimiter struct {
+	mu       sync.Mutex
+	burst    int
+	interval time.Duration
+	buckets  map[string]*geerpc.TokenBucket
+}
+
+// SetWriteLimit 限制每个来源 IP 的写请求（注册、心跳和注销）速率：
+// 每个来源最多连续发送 burst 个请求，之后每隔 interval 恢复一个配额，超出的请求返回 429，
+// 防止配置错误的客户端高频发送心跳占用注册中心的 CPU 和锁。
+// 集群中其他注册中心转发的请求同样计入转发方的配额。burst 为 0 表示不限制，interval 默认为 1 秒
+func (r *GeeRegistry) SetWriteLimit(burst int, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+	r.limiter.burst = burst
+	r.limiter.interval = interval
+	r.limiter.buckets = make(map[string]*geerpc.TokenBucket)
+}
+
+// allow 判断来自 req 的写请求是否在配额之内
+func (l *writeLimiter) allow(req *http.Request) bool {
+	l.mu.Lock()
+	if l.burst <= 0 {
+		l.mu.Unlock()
+		return true
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	tb, ok := l.buckets[host]
+	if !ok {
+		if len(l.buckets) >= maxLimitSources {
+			l.buckets = make(map[string]*geerpc.TokenBucket)
+		}
+		tb = geerpc.NewTokenBucket(l.burst, 1, l.interval)
+		l.buckets[host] = tb
+	}
+	l.mu.Unlock()
+	return tb.Allow()
+}
+
+// validAddr 检查服务器地址是否为 protocol@addr 的格式且长度合理
+func validAddr(addr string) bool {
+	if addr == "" || len(addr) > maxAddrLen {
+		return false
+	}
+	parts := strings.SplitN(addr, "@", 2)
+	return len(parts) == 2 && parts[0] != "" && parts[1] != ""
+}
@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets 是 GET 请求耗时直方图的桶上界（秒）
+var latencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30}
+
+// registryMetrics 记录注册中心的内部指标
+type registryMetrics struct {
+	heartbeats  uint64 // 收到的注册和心跳次数
+	expirations uint64 // 因租约过期被移除的服务器数
+	removals    uint64 // 主动注销或健康检查失败被移除的服务器数
+
+	mu      sync.Mutex // 保护以下字段
+	buckets []uint64   // 与 latencyBuckets 一一对应的累计计数
+	count   uint64
+	sum     float64
+}
+
+// observeGet 记录一次 GET 请求的耗时
+func (m *registryMetrics) observeGet(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets == nil {
+		m.buckets = make([]uint64, len(latencyBuckets))
+	}
+	v := d.Seconds()
+	for i, le := range latencyBuckets {
+		if v <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += v
+}
+
+// serveMetrics 处理 GET <registryPath>/metrics，以 Prometheus 文本格式输出注册中心的指标
+func (r *GeeRegistry) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	registered := len(r.aliveItems())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_registry_servers Number of alive registered servers.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_registry_servers gauge\n")
+	_, _ = fmt.Fprintf(w, "geerpc_registry_servers %d\n", registered)
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_registry_heartbeats_total Registrations and heartbeats received.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_registry_heartbeats_total counter\n")
+	_, _ = fmt.Fprintf(w, "geerpc_registry_heartbeats_total %d\n", atomic.LoadUint64(&r.metrics.heartbeats))
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_registry_expirations_total Servers removed because their lease expired.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_registry_expirations_total counter\n")
+	_, _ = fmt.Fprintf(w, "geerpc_registry_expirations_total %d\n", atomic.LoadUint64(&r.metrics.expirations))
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_registry_removals_total Servers removed by deregistration or failed health checks.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_registry_removals_total counter\n")
+	_, _ = fmt.Fprintf(w, "geerpc_registry_removals_total %d\n", atomic.LoadUint64(&r.metrics.removals))
+
+	m := &r.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_registry_get_duration_seconds Latency of server list queries.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_registry_get_duration_seconds histogram\n")
+	for i, le := range latencyBuckets {
+		var n uint64
+		if m.buckets != nil {
+			n = m.buckets[i]
+		}
+		_, _ = fmt.Fprintf(w, "geerpc_registry_get_duration_seconds_bucket{le=\"%g\"} %d\n", le, n)
+	}
+	_, _ = fmt.Fprintf(w, "geerpc_registry_get_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.count)
+	_, _ = fmt.Fprintf(w, "geerpc_registry_get_duration_seconds_sum %g\n", m.sum)
+	_, _ = fmt.Fprintf(w, "geerpc_registry_get_duration_seconds_count %d\n", m.count)
+}
@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeeRegistry_Metrics(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+	for _, addr := range []string{"tcp@127.0.0.1:1", "tcp@127.0.0.1:2", "tcp@127.0.0.1:3"} {
+		_ = client.Register(addr, Meta{TTL: 10})
+	}
+	_ = client.Register("tcp@127.0.0.1:1", Meta{TTL: 10})
+	_ = client.Deregister("tcp@127.0.0.1:2")
+	r.mu.Lock()
+	r.servers["tcp@127.0.0.1:3"].start = time.Now().Add(-time.Minute)
+	r.mu.Unlock()
+	// 两次普通查询计入耗时直方图，长轮询不计入
+	_, _, _ = client.List(ListOptions{})
+	_, _, _ = client.List(ListOptions{Zone: "z1"})
+	index, _ := r.watch()
+	_, _ = client.Watch(context.Background(), index, time.Millisecond, ListOptions{})
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("expect the Prometheus text format, got %q", ct)
+	}
+	for _, line := range []string{
+		"geerpc_registry_servers 1",
+		"geerpc_registry_heartbeats_total 4",
+		"geerpc_registry_expirations_total 1",
+		"geerpc_registry_removals_total 1",
+		`geerpc_registry_get_duration_seconds_bucket{le="+Inf"} 2`,
+		"geerpc_registry_get_duration_seconds_count 2",
+		"# TYPE geerpc_registry_get_duration_seconds histogram",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("expect %q in the metrics:\n%s", line, body)
+		}
+	}
+}
+
+func TestGeeRegistry_MetricsToken(t *testing.T) {
+	r := New(time.Minute)
+	r.SetToken("secret", true)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expect metrics to require the token when reads are protected, got %d", resp.StatusCode)
+	}
+}
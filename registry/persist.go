@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// persistedServer 是持久化到磁盘的单个服务器记录
+type persistedServer struct {
+	Addr          string        `json:"addr"`
+	Meta          Meta          `json:"meta"`
+	LastHeartbeat time.Time     `json:"last_heartbeat"`
+	TTL           time.Duration `json:"ttl"`
+}
+
+// Save 将当前注册的服务器（包括最近一次心跳的时间）写入 path，
+// 先写入临时文件再重命名，保证文件内容始终完整
+func (r *GeeRegistry) Save(path string) error {
+	r.mu.Lock()
+	servers := make([]persistedServer, 0, len(r.servers))
+	for _, s := range r.servers {
+		servers = append(servers, persistedServer{Addr: s.Addr, Meta: s.Meta, LastHeartbeat: s.start, TTL: s.ttl})
+	}
+	r.mu.Unlock()
+	data, err := json.Marshal(servers)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load 从 path 恢复注册的服务器，已经过期的记录会被忽略，文件不存在时不做任何事。
+// 注册中心重启后立即调用，可以避免在所有服务器下一次心跳之前服务发现拿到空列表
+func (r *GeeRegistry) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var servers []persistedServer
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	restored := 0
+	for _, p := range servers {
+		if p.TTL != 0 && p.LastHeartbeat.Add(p.TTL).Before(time.Now()) {
+			continue
+		}
+		if _, ok := r.servers[p.Addr]; ok {
+			continue // 重启后已经收到心跳的服务器以最新的心跳为准
+		}
+		s := &ServerItem{Addr: p.Addr, Meta: p.Meta, start: p.LastHeartbeat, ttl: p.TTL}
+		r.servers[p.Addr] = s
+		r.bump(EventJoin, s)
+		restored++
+	}
+	r.log().Info("rpc registry: restored servers", "count", restored, "path", path)
+	return nil
+}
+
+// Persist 每隔 interval 将注册的服务器保存到 path，
+// 返回的函数用于在注册中心关闭时停止定期保存并做最后一次保存，重复调用时返回第一次保存的结果
+func (r *GeeRegistry) Persist(path string, interval time.Duration) (stop func() error) {
+	if interval == 0 {
+		interval = time.Second * 30
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := r.Save(path); err != nil {
+					r.log().Error("rpc registry: persist err", "err", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	var err error
+	return func() error {
+		once.Do(func() {
+			close(done)
+			err = r.Save(path)
+		})
+		return err
+	}
+}
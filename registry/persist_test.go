@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGeeRegistry_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+
+	r := New(time.Minute)
+	r.putServer("tcp@127.0.0.1:1", Meta{Weight: 3, Zone: "a"})
+	r.putServer("tcp@127.0.0.1:2", Meta{TTL: 1})
+	r.mu.Lock()
+	r.servers["tcp@127.0.0.1:2"].start = time.Now().Add(-time.Minute) // 租约已经过期
+	r.mu.Unlock()
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已过期的记录被忽略，元数据原样恢复
+	restored := New(time.Minute)
+	if err := restored.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	items := restored.aliveItems()
+	if len(items) != 1 || items[0].Addr != "tcp@127.0.0.1:1" || items[0].Meta.Weight != 3 || items[0].Meta.Zone != "a" {
+		t.Fatalf("expect the live server to be restored with its meta, got %+v", items)
+	}
+
+	// 文件不存在时不做任何事
+	if err := New(time.Minute).Load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Fatalf("expect no error for a missing file, got %v", err)
+	}
+	_ = ioutil.WriteFile(path, []byte("not json"), 0644)
+	if err := New(time.Minute).Load(path); err == nil {
+		t.Fatal("expect an error for a corrupted file")
+	}
+}
+
+func TestGeeRegistry_Persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "registry.json")
+
+	r := New(time.Minute)
+	r.putServer("tcp@127.0.0.1:1", Meta{})
+	stop := r.Persist(path, 10*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect the registry to be saved periodically")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 停止时做最后一次保存，重复调用不会 panic
+	r.putServer("tcp@127.0.0.1:2", Meta{})
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("expect stop to be idempotent, got %v", err)
+	}
+	restored := New(time.Minute)
+	if err := restored.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if servers := restored.aliveServers(); len(servers) != 2 {
+		t.Fatalf("expect both servers in the final save, got %v", servers)
+	}
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"geerpc"
+	"net"
+	"sync"
+)
+
+var (
+	_ geerpc.RegisterPlugin  = (*Plugin)(nil)
+	_ geerpc.ServePlugin     = (*Plugin)(nil)
+	_ geerpc.ShutdownPlugin  = (*Plugin)(nil)
+	_ geerpc.RegistrarPlugin = (*Plugin)(nil)
+)
+
+// Plugin 是将服务器登记到注册中心的 geerpc 插件（参见 geerpc.Server.AddPlugin）：
+// 注册服务时把服务名加入 Meta.Services，Accept 开始提供服务时开始发送心跳，Shutdown 时注销服务器，
+// 使其在排空期间就从服务器列表中消失，而不是等到超时才被移除
+type Plugin struct {
+	registry string
+	addr     string
+	opt      HeartbeatOptions
+
+	mu       sync.Mutex // 保护以下字段
+	services []string   // 注册到服务器的服务名
+	stop     func()     // 不为 nil 时心跳已经开始
+}
+
+// NewPlugin 创建向 registry 登记 addr 的插件，addr 是客户端连接服务器使用的地址，例如 tcp@10.0.0.1:9999。
+// opt.Meta.Services 会在注册服务时自动补充
+func NewPlugin(registry, addr string, opt HeartbeatOptions) *Plugin {
+	return &Plugin{registry: registry, addr: addr, opt: opt}
+}
+
+// Name 返回插件的名称
+func (p *Plugin) Name() string { return "registry" }
+
+// Register 记录服务名，心跳已经开始时立即以新的元数据发送一次心跳
+func (p *Plugin) Register(name string, _ interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services = append(p.services, name)
+	if p.stop != nil {
+		p.start()
+	}
+	return nil
+}
+
+// Serve 在服务器开始接受连接时开始发送心跳，服务器在多个监听器上提供服务时只发送一份心跳
+func (p *Plugin) Serve(net.Addr) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		p.start()
+	}
+}
+
+// Shutdown 停止心跳并从注册中心注销服务器
+func (p *Plugin) Shutdown(context.Context) error {
+	return p.Deregister()
+}
+
+// Deregister 停止心跳并从注册中心注销服务器，之后可以通过 Reregister 重新登记，例如管理接口的 drain 和 undrain
+func (p *Plugin) Deregister() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		return nil
+	}
+	p.stop = nil
+	return Deregister(p.registry, p.addr)
+}
+
+// Reregister 在 Deregister 之后重新开始发送心跳，心跳没有停止时不做任何事
+func (p *Plugin) Reregister() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		p.start()
+	}
+}
+
+// start 以当前的服务名开始发送心跳，同一服务器之前的心跳会被替换
+func (p *Plugin) start() {
+	opt := p.opt
+	opt.Meta.Services = append(append([]string(nil), p.opt.Meta.Services...), p.services...)
+	p.stop = StartHeartbeat(context.Background(), p.registry, p.addr, opt)
+}
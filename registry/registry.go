@@ -1,11 +1,17 @@
 package registry
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"geerpc"
+	"math/rand"
 	"net/http"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,12 +22,66 @@ type GeeRegistry struct {
 	timeout time.Duration
 	mu      sync.Mutex // 保护以下字段
 	servers map[string]*ServerItem
+	minTTL  time.Duration // 服务器自选租约时长的下限，0 表示不限制
+	maxTTL  time.Duration // 服务器自选租约时长的上限，0 表示不限制
+	index   uint64        // 服务器列表的版本号，每次成员变化时递增
+	loads   uint64        // 负载的版本号，每次服务器报告的负载变化时递增
+	changed chan struct{} // 成员变化时关闭并替换，用于唤醒长轮询请求
+	subs    map[chan Event]struct{}
+	peers   []string       // 集群中其他注册中心的地址
+	weights map[string]int // 管理员设置的权重，覆盖服务器心跳中携带的权重
+
+	quarantined map[string]bool // 被管理员隔离的服务器，不出现在服务器列表中
+	unhealthy   map[string]bool // 主动健康检查失败的服务器，直到检查通过前不出现在服务器列表中
+
+	adminAuth func(req *http.Request) bool // 检查管理接口的请求，为 nil 时不挂载管理接口
+
+	token        string // 共享令牌，为空表示不鉴权
+	protectReads bool   // 查询服务器列表是否也需要令牌
+
+	signingKeys   func(keyID string) []byte // 不为 nil 时写请求必须携带签名
+	signatureSkew time.Duration             // 允许的签名时间戳偏差
+	lastSigned    map[string]int64          // 每个服务器上一次通过校验的签名时间戳
+
+	stopHealth chan struct{}  // 关闭后停止主动健康检查
+	healthOpt  *geerpc.Option // 主动健康检查连接服务器时使用的 Option，为 nil 时使用 geerpc.DefaultOption
+	metrics    registryMetrics
+	limiter    writeLimiter  // 不使用 mu，避免被限流的请求争用注册中心的锁
+	logger     geerpc.Logger // 为 nil 时使用 geerpc.DefaultLogger
 }
 
 // ServerItem 记录服务器的信息
 type ServerItem struct {
 	Addr  string
+	Meta  Meta
+	Load  *Load // 最近一次心跳报告的负载，没有报告时为 nil
 	start time.Time
+	ttl   time.Duration // 租约时长，超过该时间未收到心跳则移除，0 表示永不过期
+}
+
+// Meta 是服务器注册时携带的元数据，会随服务器列表一起返回给服务发现客户端，
+// 用于下游按权重、可用区或版本进行路由
+type Meta struct {
+	Weight   int      `json:"weight"`             // 权重，注册时未设置则为 1
+	Zone     string   `json:"zone,omitempty"`     // 所在的可用区或数据中心
+	Version  string   `json:"version,omitempty"`  // 服务器版本，可用于金丝雀发布
+	Codecs   []string `json:"codecs,omitempty"`   // 支持的编解码器类型
+	Services []string `json:"services,omitempty"` // 提供的服务名
+	TTL      int      `json:"ttl,omitempty"`      // 租约时长（秒），未设置时使用注册中心的超时时间
+}
+
+// Hosts 判断服务器是否提供名为 service 的服务。
+// 没有声明 Services 的服务器被视为提供所有服务，以兼容旧版本的服务器
+func (m Meta) Hosts(service string) bool {
+	if service == "" || len(m.Services) == 0 {
+		return true
+	}
+	for _, s := range m.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
 }
 
 const (
@@ -34,45 +94,235 @@ func New(timeout time.Duration) *GeeRegistry {
 	return &GeeRegistry{
 		servers: make(map[string]*ServerItem),
 		timeout: timeout,
+		index:   1,
+		changed: make(chan struct{}),
+		subs:    make(map[chan Event]struct{}),
 	}
 }
 
 var DefaultGeeRegister = New(defaultTimeout)
 
-// putServer 将服务器添加到注册中心或更新其活动时间
-func (r *GeeRegistry) putServer(addr string) {
+// SetLogger 设置注册中心使用的 Logger，应在开始服务之前调用
+func (r *GeeRegistry) SetLogger(l geerpc.Logger) {
+	r.logger = l
+}
+
+// log 返回注册中心使用的 Logger
+func (r *GeeRegistry) log() geerpc.Logger {
+	if r.logger != nil {
+		return r.logger
+	}
+	return geerpc.DefaultLogger()
+}
+
+// SetTTLBounds 设置服务器注册时自选租约时长的范围，超出范围的值会被截断到边界，
+// 0 表示对应方向不限制
+func (r *GeeRegistry) SetTTLBounds(min, max time.Duration) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.minTTL, r.maxTTL = min, max
+}
+
+// leaseTTL 根据注册时请求的租约时长和注册中心的策略计算实际的租约时长，调用方需持有 r.mu
+func (r *GeeRegistry) leaseTTL(seconds int) time.Duration {
+	if seconds <= 0 {
+		return r.timeout
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if r.minTTL > 0 && ttl < r.minTTL {
+		ttl = r.minTTL
+	}
+	if r.maxTTL > 0 && ttl > r.maxTTL {
+		ttl = r.maxTTL
+	}
+	return ttl
+}
+
+// putServer 将服务器添加到注册中心或更新其活动时间和元数据
+func (r *GeeRegistry) putServer(addr string, meta Meta) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	atomic.AddUint64(&r.metrics.heartbeats, 1)
+	if meta.Weight <= 0 {
+		meta.Weight = 1
+	}
+	if w, ok := r.weights[addr]; ok {
+		meta.Weight = w
+	}
+	ttl := r.leaseTTL(meta.TTL)
+	meta.TTL = int(ttl / time.Second)
 	s := r.servers[addr]
 	if s == nil {
-		r.servers[addr] = &ServerItem{Addr: addr, start: time.Now()}
+		s = &ServerItem{Addr: addr, Meta: meta, start: time.Now(), ttl: ttl}
+		r.servers[addr] = s
+		r.bump(EventJoin, s)
 	} else {
 		s.start = time.Now() // 如果已存在，更新活动时间以保持活跃
+		if !reflect.DeepEqual(s.Meta, meta) {
+			s.Meta = meta
+			r.bump(EventUpdate, s)
+		}
+		s.ttl = ttl
 	}
 }
 
-// aliveServers 返回所有活动服务器的地址
-func (r *GeeRegistry) aliveServers() []string {
+// setLoad 记录服务器报告的负载。负载变化不改变服务器列表的版本号，
+// 避免每次心跳都唤醒长轮询请求，只递增负载的版本号使 GET <registryPath>/servers 的 ETag 随之变化
+func (r *GeeRegistry) setLoad(addr string, load *Load) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	var alive []string
+	s, ok := r.servers[addr]
+	if !ok || (s.Load == nil && load == nil) || (s.Load != nil && load != nil && *s.Load == *load) {
+		return
+	}
+	s.Load = load
+	r.loads++
+}
+
+// loadVersion 返回负载的版本号
+func (r *GeeRegistry) loadVersion() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loads
+}
+
+// removeServer 从注册中心移除服务器，返回该服务器之前是否已注册
+func (r *GeeRegistry) removeServer(addr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.servers[addr]
+	if ok {
+		delete(r.servers, addr)
+		delete(r.unhealthy, addr)
+		atomic.AddUint64(&r.metrics.removals, 1)
+		r.bump(EventLeave, s)
+	}
+	return ok
+}
+
+// bump 递增服务器列表的版本号，唤醒所有长轮询请求并向订阅者推送事件，调用方需持有 r.mu
+func (r *GeeRegistry) bump(typ string, s *ServerItem) {
+	r.index++
+	close(r.changed)
+	r.changed = make(chan struct{})
+	if r.hidden(s.Addr) && typ != EventLeave {
+		return // 被隔离或健康检查失败的服务器对订阅者不可见
+	}
+	r.publish(Event{Type: typ, Index: r.index, Server: Registration{Addr: s.Addr, Meta: s.Meta, Load: s.Load}})
+}
+
+// watch 返回当前的版本号，以及在下一次成员变化时关闭的 channel
+func (r *GeeRegistry) watch() (uint64, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.index, r.changed
+}
+
+// aliveItems 返回所有活动服务器的信息，按地址排序
+func (r *GeeRegistry) aliveItems() []ServerItem {
+	_, alive := r.snapshot(true)
+	return alive
+}
+
+// hidden 判断服务器是否因被隔离或健康检查失败而不出现在服务器列表中，调用方需持有 r.mu
+func (r *GeeRegistry) hidden(addr string) bool {
+	return r.quarantined[addr] || r.unhealthy[addr]
+}
+
+// snapshot 清理失效的服务器，并原子地返回当前的版本号和活动服务器列表，
+// all 为 false 时不包括被隔离或健康检查失败的服务器
+func (r *GeeRegistry) snapshot(all bool) (uint64, []ServerItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alive []ServerItem
 	for addr, s := range r.servers {
-		if r.timeout == 0 || s.start.Add(r.timeout).After(time.Now()) {
-			alive = append(alive, addr)
+		if s.ttl == 0 || s.start.Add(s.ttl).After(time.Now()) {
+			if all || !r.hidden(addr) {
+				alive = append(alive, *s)
+			}
 		} else {
 			delete(r.servers, addr)
+			delete(r.unhealthy, addr)
+			atomic.AddUint64(&r.metrics.expirations, 1)
+			r.bump(EventLeave, s)
 		}
 	}
-	sort.Strings(alive)
+	sort.Slice(alive, func(i, j int) bool { return alive[i].Addr < alive[j].Addr })
+	return r.index, alive
+}
+
+// aliveServers 返回所有活动服务器的地址
+func (r *GeeRegistry) aliveServers() []string {
+	items := r.aliveItems()
+	alive := make([]string, 0, len(items))
+	for _, s := range items {
+		alive = append(alive, s.Addr)
+	}
 	return alive
 }
 
-// ServeHTTP 处理 HTTP 请求，返回活动服务器列表或接收服务器的心跳
+// ServeHTTP 处理 HTTP 请求，返回活动服务器列表或接收服务器的心跳。
+// <registryPath>/servers 和 <registryPath>/register 是 JSON API，
+// <registryPath>/events 以 Server-Sent Events 推送成员变化，
+// <registryPath>/weight 和 <registryPath>/quarantine 供管理员调整服务器的权重和隔离服务器（参见 SetAdminHTTP），
+// <registryPath> 本身保留基于请求头的旧协议以保持兼容
 func (r *GeeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && !r.limiter.allow(req) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	// 管理接口只接受管理员凭据，不接受共享令牌
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/weight"):
+		r.serveAdmin(w, req, r.serveWeight)
+		return
+	case strings.HasSuffix(req.URL.Path, "/quarantine"):
+		r.serveAdmin(w, req, r.serveQuarantine)
+		return
+	}
+	if !r.authorized(req) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="geerpc registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	// 只统计普通的列表查询，长轮询和事件流的耗时取决于成员变化，没有参考意义
+	if req.Method == "GET" && req.URL.Query().Get("index") == "" &&
+		!strings.HasSuffix(req.URL.Path, "/events") && !strings.HasSuffix(req.URL.Path, "/metrics") {
+		start := time.Now()
+		defer func() { r.metrics.observeGet(time.Since(start)) }()
+	}
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/metrics"):
+		r.serveMetrics(w, req)
+		return
+	case strings.HasSuffix(req.URL.Path, "/servers"):
+		r.serveServers(w, req)
+		return
+	case strings.HasSuffix(req.URL.Path, "/register"):
+		r.serveRegister(w, req)
+		return
+	case strings.HasSuffix(req.URL.Path, "/events"):
+		r.serveEvents(w, req)
+		return
+	}
 	switch req.Method {
 	case "GET":
-		// 简化起见，服务器列表在 req.Header 中
-		w.Header().Set("X-Geerpc-Servers", strings.Join(r.aliveServers(), ","))
+		// 简化起见，服务器列表在 req.Header 中，元数据以 JSON 形式放在 X-Geerpc-Meta 中
+		index, items := r.snapshot(false)
+		if notModified(w, req, strconv.FormatUint(index, 10)) {
+			return
+		}
+		items = filterItems(items, req.URL.Query())
+		servers := make([]string, 0, len(items))
+		metas := make(map[string]Meta, len(items))
+		for _, s := range items {
+			servers = append(servers, s.Addr)
+			metas[s.Addr] = s.Meta
+		}
+		meta, _ := json.Marshal(metas)
+		w.Header().Set("X-Geerpc-Servers", strings.Join(servers, ","))
+		w.Header().Set("X-Geerpc-Meta", string(meta))
 	case "POST":
 		// 简化起见，服务器地址在 req.Header 中
 		addr := req.Header.Get("X-Geerpc-Server")
@@ -80,16 +330,48 @@ func (r *GeeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		r.putServer(addr)
+		if !validAddr(addr) || len(req.Header.Get("X-Geerpc-Meta")) > maxMetaLen {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var meta Meta
+		if m := req.Header.Get("X-Geerpc-Meta"); m != "" {
+			if err := json.Unmarshal([]byte(m), &meta); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if !r.verifySignature(req, "POST", Registration{Addr: addr, Meta: meta}) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.putServer(addr, meta)
+		r.replicate(req, "POST", Registration{Addr: addr, Meta: meta})
+	case "DELETE":
+		// 服务器优雅关闭时主动注销，地址同样在 req.Header 中
+		addr := req.Header.Get("X-Geerpc-Server")
+		if addr == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !r.verifySignature(req, "DELETE", Registration{Addr: addr}) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !r.removeServer(addr) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		r.replicate(req, "DELETE", Registration{Addr: addr})
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-// HandleHTTP 在 registryPath 上注册 GeeRegistry 的 HTTP 处理程序
+// HandleHTTP 在 registryPath 及其子路径上注册 GeeRegistry 的 HTTP 处理程序
 func (r *GeeRegistry) HandleHTTP(registryPath string) {
 	http.Handle(registryPath, r)
-	log.Println("rpc registry path:", registryPath)
+	http.Handle(strings.TrimSuffix(registryPath, "/")+"/", r)
+	r.log().Info("rpc registry path", "path", registryPath)
 }
 
 // HandleHTTP 注册默认路径的 HTTP 处理程序
@@ -98,30 +380,175 @@ func HandleHTTP() {
 }
 
 // Heartbeat 定期发送心跳消息
-// 作为服务器注册或发送心跳的辅助函数
+// 作为服务器注册或发送心跳的辅助函数，心跳失败后会退避重试，可以通过 Deregister 停止
 func Heartbeat(registry, addr string, duration time.Duration) {
-	if duration == 0 {
+	HeartbeatWithMeta(registry, addr, Meta{}, duration)
+}
+
+// HeartbeatWithMeta 与 Heartbeat 相同，但在每次心跳中携带服务器的元数据
+func HeartbeatWithMeta(registry, addr string, meta Meta, duration time.Duration) {
+	StartHeartbeat(context.Background(), registry, addr, HeartbeatOptions{Meta: meta, Interval: duration})
+}
+
+// HeartbeatOptions 控制 StartHeartbeat 发送心跳的方式
+type HeartbeatOptions struct {
+	Meta       Meta                          // 每次心跳携带的服务器元数据
+	Interval   time.Duration                 // 心跳间隔，为 0 时根据 Meta.TTL 或注册中心的默认超时时间推算
+	Jitter     float64                       // 每次间隔随机增减的比例，例如 0.1 表示 ±10%，避免大量服务器同时发送心跳
+	MaxBackoff time.Duration                 // 心跳失败后重试间隔的上限，为 0 时使用 Interval
+	OnFailure  func(err error, failures int) // 每次心跳失败时调用，failures 为连续失败的次数
+	Load       func() Load                   // 不为 nil 时，每次心跳前调用以获取并报告服务器当前的负载
+
+	// SigningKey 不为 nil 时对心跳签名，参见 GeeRegistry.SetSigningKeys。Deregister 会使用相同的密钥对注销请求签名
+	SigningKeyID string
+	SigningKey   []byte
+}
+
+// minHeartbeatBackoff 是心跳失败后第一次重试前的等待时间，之后每次失败翻倍
+const minHeartbeatBackoff = time.Second
+
+// StartHeartbeat 立即发送一次心跳，然后在后台协程中定期发送，
+// 心跳失败时按指数退避重试并调用 opt.OnFailure，而不是停止发送。
+// ctx 结束、调用返回的 stop 函数或调用 Deregister 都会停止心跳
+func StartHeartbeat(ctx context.Context, registry, addr string, opt HeartbeatOptions) (stop func()) {
+	interval := opt.Interval
+	if interval == 0 && opt.Meta.TTL > 0 {
+		// 自选了租约时长时，在租约内至少发送三次心跳
+		interval = time.Duration(opt.Meta.TTL) * time.Second / 3
+	}
+	if interval == 0 {
 		// 确保在从注册中心移除之前有足够的时间发送心跳
-		duration = defaultTimeout - time.Duration(1)*time.Minute
+		interval = defaultTimeout - time.Duration(1)*time.Minute
+	}
+	maxBackoff := opt.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = interval
 	}
-	var err error
-	err = sendHeartbeat(registry, addr)
+
+	client := NewClient(registry)
+	client.SigningKeyID, client.SigningKey = opt.SigningKeyID, opt.SigningKey
+	done := startHeartbeat(registry, addr, client)
+	stop = func() { cancelHeartbeat(registry, addr, done) }
+	failures := 0
+	beat := func() time.Duration {
+		var load *Load
+		if opt.Load != nil {
+			l := opt.Load()
+			load = &l
+		}
+		err := sendHeartbeat(client, addr, opt.Meta, load)
+		if err == nil {
+			failures = 0
+			return jitter(interval, opt.Jitter)
+		}
+		failures++
+		if opt.OnFailure != nil {
+			opt.OnFailure(err, failures)
+		}
+		backoff := minHeartbeatBackoff
+		for i := 1; i < failures && backoff < maxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		return jitter(backoff, opt.Jitter)
+	}
+	wait := beat()
 	go func() {
-		t := time.NewTicker(duration)
-		for err == nil {
-			<-t.C
-			err = sendHeartbeat(registry, addr)
+		for {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				stop()
+				return
+			case <-done:
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			wait = beat()
 		}
 	}()
+	return stop
+}
+
+// jitter 将 d 随机增减不超过 d*ratio
+func jitter(d time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*ratio*float64(d))
+}
+
+var (
+	heartbeatsMu sync.Mutex
+	heartbeats   = make(map[string]heartbeat) // registry + addr -> 心跳协程
+)
+
+// heartbeat 记录一个心跳协程
+type heartbeat struct {
+	stop   chan struct{} // 关闭后停止心跳
+	client *Client       // 发送心跳使用的客户端，注销时沿用其签名密钥
+}
+
+// startHeartbeat 登记一个新的心跳协程，同一服务器之前的心跳协程会被停止
+func startHeartbeat(registry, addr string, client *Client) chan struct{} {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	key := registry + " " + addr
+	if hb, ok := heartbeats[key]; ok {
+		close(hb.stop)
+	}
+	stop := make(chan struct{})
+	heartbeats[key] = heartbeat{stop: stop, client: client}
+	return stop
+}
+
+// stopHeartbeat 停止服务器的心跳协程，返回其使用的客户端，没有心跳协程时返回 nil
+func stopHeartbeat(registry, addr string) *Client {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	key := registry + " " + addr
+	if hb, ok := heartbeats[key]; ok {
+		close(hb.stop)
+		delete(heartbeats, key)
+		return hb.client
+	}
+	return nil
+}
+
+// cancelHeartbeat 停止 stop 对应的心跳协程，该协程已被停止或替换时不做任何事
+func cancelHeartbeat(registry, addr string, stop chan struct{}) {
+	heartbeatsMu.Lock()
+	defer heartbeatsMu.Unlock()
+	key := registry + " " + addr
+	if heartbeats[key].stop == stop {
+		close(stop)
+		delete(heartbeats, key)
+	}
+}
+
+// Deregister 停止服务器的心跳，并从注册中心注销该服务器，
+// 应在服务器优雅关闭时调用，使其立即从服务器列表中消失，而不是等到超时才被移除
+func Deregister(registry, addr string) error {
+	client := stopHeartbeat(registry, addr)
+	if client == nil {
+		client = NewClient(registry)
+	}
+	if err := client.Deregister(addr); err != nil {
+		geerpc.DefaultLogger().Error("rpc server: deregister err", "err", err)
+		return err
+	}
+	geerpc.DefaultLogger().Info("rpc server: deregistered from registry", "addr", addr, "registry", registry)
+	return nil
 }
 
-func sendHeartbeat(registry, addr string) error {
-	log.Println(addr, "send heart beat to registry", registry)
-	httpClient := &http.Client{}
-	req, _ := http.NewRequest("POST", registry, nil)
-	req.Header.Set("X-Geerpc-Server", addr)
-	if _, err := httpClient.Do(req); err != nil {
-		log.Println("rpc server: heart beat err:", err)
+func sendHeartbeat(client *Client, addr string, meta Meta, load *Load) error {
+	geerpc.DefaultLogger().Debug("rpc server: send heart beat to registry", "addr", addr, "registry", client.URL)
+	if err := client.register(Registration{Addr: addr, Meta: meta, Load: load}); err != nil {
+		geerpc.DefaultLogger().Error("rpc server: heart beat err", "err", err)
 		return err
 	}
 	return nil
@@ -0,0 +1,689 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"geerpc"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGeeRegistry_JSONAPI(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	body := `{"addr":"tcp@127.0.0.1:9999","weight":3,"zone":"us-east-1a"}`
+	resp, err := http.Post(ts.URL+"/_geerpc_/registry/register", "application/json", strings.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("register failed: %v %v", err, resp)
+	}
+	resp, err = http.Post(ts.URL+"/_geerpc_/registry/register", "text/plain", strings.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expect 415 for non-JSON body, but got %v %v", err, resp)
+	}
+
+	resp, err = http.Get(ts.URL + "/_geerpc_/registry/servers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list ServerList
+	_ = json.NewDecoder(resp.Body).Decode(&list)
+	_ = resp.Body.Close()
+	if len(list.Servers) != 1 || list.Servers[0].Weight != 3 || list.Servers[0].Zone != "us-east-1a" {
+		t.Fatalf("unexpected server list: %+v", list)
+	}
+	// 旧的请求头协议仍然可用
+	resp, _ = http.Get(ts.URL + "/_geerpc_/registry")
+	if got := resp.Header.Get("X-Geerpc-Servers"); got != "tcp@127.0.0.1:9999" {
+		t.Fatalf("unexpected legacy server list: %q", got)
+	}
+
+	req, _ := http.NewRequest("DELETE", ts.URL+"/_geerpc_/registry/register?addr=tcp@127.0.0.1:9999", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("deregister failed: %v %v", err, resp)
+	}
+	if servers := r.aliveServers(); len(servers) != 0 {
+		t.Fatalf("expect no servers after deregister, but got %v", servers)
+	}
+}
+
+func TestGeeRegistry_LongPoll(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	index, _ := r.watch()
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		r.putServer("tcp@127.0.0.1:9999", Meta{})
+	}()
+	start := time.Now()
+	resp, err := http.Get(ts.URL + "/servers?wait=10s&index=" + strconv.FormatUint(index, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list ServerList
+	_ = json.NewDecoder(resp.Body).Decode(&list)
+	_ = resp.Body.Close()
+	if time.Since(start) > time.Second*5 {
+		t.Fatal("long poll should return as soon as membership changes")
+	}
+	if list.Index <= index || len(list.Servers) != 1 {
+		t.Fatalf("unexpected server list: %+v", list)
+	}
+}
+
+func TestGeeRegistry_Token(t *testing.T) {
+	r := New(time.Minute)
+	r.SetToken("secret", false)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	if err := sendHeartbeat(NewClient(ts.URL), "tcp@127.0.0.1:9999", Meta{}, nil); err == nil {
+		t.Fatal("expect an error for heartbeat without token")
+	}
+	if servers := r.aliveServers(); len(servers) != 0 {
+		t.Fatalf("heartbeat without token should be rejected, but got %v", servers)
+	}
+	authed := strings.Replace(ts.URL, "http://", "http://geerpc:secret@", 1)
+	if err := sendHeartbeat(NewClient(authed), "tcp@127.0.0.1:9999", Meta{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if servers := r.aliveServers(); len(servers) != 1 {
+		t.Fatalf("heartbeat with token should be accepted, but got %v", servers)
+	}
+}
+
+func TestGeeRegistry_ClusterToken(t *testing.T) {
+	a, b := New(time.Minute), New(time.Minute)
+	a.SetToken("secret", true)
+	b.SetToken("secret", true)
+	tsA, tsB := httptest.NewServer(a), httptest.NewServer(b)
+	defer tsA.Close()
+	defer tsB.Close()
+	a.SetPeers(tsB.URL)
+	b.SetPeers(tsA.URL)
+
+	client := &Client{URL: tsA.URL, Token: "secret"}
+	if err := sendHeartbeat(client, "tcp@127.0.0.1:9999", Meta{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && len(b.aliveServers()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if servers := b.aliveServers(); len(servers) != 1 {
+		t.Fatalf("expect the registration to be replicated with the token, but got %v", servers)
+	}
+
+	// 读取也需要令牌时，SyncFromPeers 同样携带令牌
+	a.putServer("tcp@127.0.0.1:8888", Meta{})
+	if err := b.SyncFromPeers(); err != nil {
+		t.Fatal(err)
+	}
+	if servers := b.aliveServers(); len(servers) != 2 {
+		t.Fatalf("expect servers to be synced from the peer, but got %v", servers)
+	}
+
+	// 令牌不一致时，拒绝的请求作为错误报告，而不是被忽略
+	b.SetToken("other", true)
+	if err := b.SyncFromPeers(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expect a 401 error from the peer, got %v", err)
+	}
+}
+
+func TestGeeRegistry_SignedHeartbeat(t *testing.T) {
+	r := New(time.Minute)
+	r.SetSigningKeys(func(keyID string) []byte {
+		if keyID == "k1" {
+			return []byte("secret")
+		}
+		return nil
+	}, 0)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	if err := sendHeartbeat(NewClient(ts.URL), "tcp@127.0.0.1:9999", Meta{}, nil); err == nil {
+		t.Fatal("expect an error for unsigned heartbeat")
+	}
+	wrong := &Client{URL: ts.URL, SigningKeyID: "k1", SigningKey: []byte("wrong")}
+	if err := sendHeartbeat(wrong, "tcp@127.0.0.1:9999", Meta{}, nil); err == nil {
+		t.Fatal("expect an error for heartbeat signed with a wrong key")
+	}
+	signed := &Client{URL: ts.URL, SigningKeyID: "k1", SigningKey: []byte("secret")}
+	if err := sendHeartbeat(signed, "tcp@127.0.0.1:9999", Meta{Weight: 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if servers := r.aliveServers(); len(servers) != 1 {
+		t.Fatalf("signed heartbeat should be accepted, but got %v", servers)
+	}
+	if err := NewClient(ts.URL).Deregister("tcp@127.0.0.1:9999"); err == nil {
+		t.Fatal("expect an error for unsigned deregistration")
+	}
+	if err := signed.Deregister("tcp@127.0.0.1:9999"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	r := New(time.Minute)
+	r.SetToken("secret", false)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	failures := 0
+	stop := StartHeartbeat(context.Background(), ts.URL, "tcp@127.0.0.1:9999", HeartbeatOptions{
+		OnFailure: func(err error, n int) { failures = n },
+	})
+	stop()
+	if failures != 1 {
+		t.Fatalf("expect OnFailure to be called once, but got %d", failures)
+	}
+
+	authed := strings.Replace(ts.URL, "http://", "http://geerpc:secret@", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	StartHeartbeat(ctx, authed, "tcp@127.0.0.1:9999", HeartbeatOptions{Interval: 10 * time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if servers := r.aliveServers(); len(servers) != 1 {
+		t.Fatalf("expect 1 alive server, but got %v", servers)
+	}
+}
+
+func TestGeeRegistry_WriteLimit(t *testing.T) {
+	r := New(time.Minute)
+	r.SetWriteLimit(2, time.Hour)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	post := func(addr string) int {
+		req, _ := http.NewRequest("POST", ts.URL, nil)
+		req.Header.Set("X-Geerpc-Server", addr)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("127.0.0.1:9999"); code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for address without protocol, but got %d", code)
+	}
+	if code := post("tcp@127.0.0.1:9999"); code != http.StatusOK {
+		t.Fatalf("expect 200, but got %d", code)
+	}
+	if code := post("tcp@127.0.0.1:9999"); code != http.StatusTooManyRequests {
+		t.Fatalf("expect 429 after the burst is used up, but got %d", code)
+	}
+}
+
+func TestGeeRegistry_ETag(t *testing.T) {
+	r := New(time.Minute)
+	r.putServer("tcp@127.0.0.1:9999", Meta{})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/servers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expect an ETag header")
+	}
+	get := func() int {
+		req, _ := http.NewRequest("GET", ts.URL+"/servers", nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(); code != http.StatusNotModified {
+		t.Fatalf("expect 304 for unchanged list, but got %d", code)
+	}
+	r.putServer("tcp@127.0.0.1:9998", Meta{})
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("expect 200 after the list changed, but got %d", code)
+	}
+
+	// 负载变化不改变版本号，但会改变 ETag
+	resp, _ = http.Get(ts.URL + "/servers")
+	_ = resp.Body.Close()
+	etag, index := resp.Header.Get("ETag"), resp.Header.Get("X-Geerpc-Index")
+	r.setLoad("tcp@127.0.0.1:9998", &Load{Inflight: 1})
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("expect 200 after a load report, but got %d", code)
+	}
+	resp, _ = http.Get(ts.URL + "/servers")
+	_ = resp.Body.Close()
+	if resp.Header.Get("X-Geerpc-Index") != index {
+		t.Fatalf("expect a load report to keep index %s, got %s", index, resp.Header.Get("X-Geerpc-Index"))
+	}
+	etag = resp.Header.Get("ETag")
+	r.setLoad("tcp@127.0.0.1:9998", &Load{Inflight: 1})
+	if code := get(); code != http.StatusNotModified {
+		t.Fatalf("expect 304 for an unchanged load, but got %d", code)
+	}
+}
+
+func TestGeeRegistry_Quarantine(t *testing.T) {
+	r := New(time.Minute)
+	r.putServer("tcp@127.0.0.1:9999", Meta{})
+	r.putServer("tcp@127.0.0.1:9998", Meta{})
+	r.Quarantine("tcp@127.0.0.1:9999")
+	if _, items := r.snapshot(false); len(items) != 1 || items[0].Addr != "tcp@127.0.0.1:9998" {
+		t.Fatalf("expect quarantined server to be hidden, but got %v", items)
+	}
+	if servers := r.aliveServers(); len(servers) != 2 {
+		t.Fatalf("expect quarantined server to stay registered, but got %v", servers)
+	}
+	r.Unquarantine("tcp@127.0.0.1:9999")
+	if _, items := r.snapshot(false); len(items) != 2 {
+		t.Fatalf("expect 2 servers after lifting quarantine, but got %v", items)
+	}
+}
+
+func TestGeeRegistry_AdminHTTP(t *testing.T) {
+	r := New(time.Minute)
+	r.SetToken("shared", false)
+	r.putServer("tcp@127.0.0.1:9999", Meta{})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	do := func(path, token, body string) int {
+		req, _ := http.NewRequest("POST", ts.URL+"/_geerpc_/registry"+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	weight := `{"addr":"tcp@127.0.0.1:9999","weight":0}`
+	quarantine := `{"addr":"tcp@127.0.0.1:9999"}`
+
+	// 未设置管理员凭据时管理接口不可用，共享令牌也不能使用它们
+	if code := do("/weight", "shared", weight); code != http.StatusNotFound {
+		t.Fatalf("expect 404 without an admin credential, but got %d", code)
+	}
+	if code := do("/quarantine", "shared", quarantine); code != http.StatusNotFound {
+		t.Fatalf("expect 404 without an admin credential, but got %d", code)
+	}
+
+	r.SetAdminHTTP(geerpc.BearerToken("admin"))
+	for _, token := range []string{"", "shared", "wrong"} {
+		if code := do("/weight", token, weight); code != http.StatusUnauthorized {
+			t.Fatalf("expect 401 for token %q, but got %d", token, code)
+		}
+		if code := do("/quarantine", token, quarantine); code != http.StatusUnauthorized {
+			t.Fatalf("expect 401 for token %q, but got %d", token, code)
+		}
+	}
+	if _, items := r.snapshot(false); len(items) != 1 || items[0].Meta.Weight == 0 {
+		t.Fatalf("expect rejected requests to change nothing, but got %v", items)
+	}
+
+	if code := do("/weight", "admin", weight); code != http.StatusOK {
+		t.Fatalf("expect 200 with the admin credential, but got %d", code)
+	}
+	if code := do("/quarantine", "admin", quarantine); code != http.StatusNoContent {
+		t.Fatalf("expect 204 with the admin credential, but got %d", code)
+	}
+	if _, items := r.snapshot(false); len(items) != 0 {
+		t.Fatalf("expect the server to be quarantined, but got %v", items)
+	}
+	if _, items := r.snapshot(true); len(items) != 1 || items[0].Meta.Weight != 0 {
+		t.Fatalf("expect the weight override to apply, but got %v", items)
+	}
+}
+
+type Foo int
+
+func (Foo) Sum(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func TestPlugin(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	server := geerpc.NewServer()
+	server.AddPlugin(NewPlugin(ts.URL, "tcp@127.0.0.1:9999", HeartbeatOptions{Meta: Meta{Zone: "z1"}}))
+	_ = server.Register(new(Foo))
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	done := make(chan struct{})
+	go func() {
+		server.Accept(l)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(r.aliveItems()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	items := r.aliveItems()
+	if len(items) != 1 || items[0].Meta.Zone != "z1" || !reflect.DeepEqual(items[0].Meta.Services, []string{"Foo"}) {
+		t.Fatalf("expect the server to be registered with its services, but got %+v", items)
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if servers := r.aliveServers(); len(servers) != 0 {
+		t.Fatalf("expect the server to be deregistered on shutdown, but got %v", servers)
+	}
+}
+
+func TestGeeRegistry_SignatureReplay(t *testing.T) {
+	r := New(time.Minute)
+	r.SetSigningKeys(func(keyID string) []byte { return []byte("secret") }, 0)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	reg := Registration{Addr: "tcp@127.0.0.1:9999"}
+	body, _ := json.Marshal(reg)
+	sig := signatureValue("k1", []byte("secret"), "POST", reg)
+	post := func() int {
+		req, _ := http.NewRequest("POST", ts.URL+"/register", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(signatureHeader, sig)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(); code >= 300 {
+		t.Fatalf("expect the signed registration to be accepted, got %d", code)
+	}
+	// 原样重放同一个请求，即使仍在同一时刻内也会被拒绝
+	if code := post(); code < 400 {
+		t.Fatalf("expect the replayed registration to be rejected, got %d", code)
+	}
+	// 连续发送的请求使用递增的时间戳，不会被当作重放
+	signed := &Client{URL: ts.URL, SigningKeyID: "k1", SigningKey: []byte("secret")}
+	for i := 0; i < 3; i++ {
+		if err := signed.Register(reg.Addr, Meta{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGeeRegistry_SignatureEviction(t *testing.T) {
+	r := New(time.Minute)
+	r.SetSigningKeys(func(keyID string) []byte { return []byte("secret") }, time.Minute)
+	expired := time.Now().Add(-2 * time.Minute).UnixNano()
+	for i := 0; i < maxLimitSources-1; i++ {
+		r.lastSigned[fmt.Sprintf("tcp@expired-%d", i)] = expired
+	}
+	reg := Registration{Addr: "tcp@127.0.0.1:9999"}
+	recent := signatureValue("k1", []byte("secret"), "POST", reg)
+	req := httptest.NewRequest("POST", "/register", nil)
+	req.Header.Set(signatureHeader, recent)
+	if !r.verifySignature(req, "POST", reg) {
+		t.Fatal("expect a valid signature to be accepted")
+	}
+	// 记录已满时只清除过期的记录，仍在时间偏差内的记录被保留，无法借此重放
+	other := Registration{Addr: "tcp@127.0.0.1:9998"}
+	req2 := httptest.NewRequest("POST", "/register", nil)
+	req2.Header.Set(signatureHeader, signatureValue("k1", []byte("secret"), "POST", other))
+	if !r.verifySignature(req2, "POST", other) {
+		t.Fatal("expect a valid signature to be accepted")
+	}
+	if len(r.lastSigned) != 2 {
+		t.Fatalf("expect only expired entries to be evicted, got %d entries", len(r.lastSigned))
+	}
+	if r.verifySignature(req, "POST", reg) {
+		t.Fatal("expect the replayed signature to be rejected after eviction")
+	}
+}
+
+func TestConsulRegister(t *testing.T) {
+	var mu sync.Mutex
+	var registered consulService
+	var deregistered string
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.Method != "PUT":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case req.URL.Path == "/v1/agent/service/register":
+			_ = json.NewDecoder(req.Body).Decode(&registered)
+		case strings.HasPrefix(req.URL.Path, "/v1/agent/service/deregister/"):
+			deregistered = strings.TrimPrefix(req.URL.Path, "/v1/agent/service/deregister/")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer consul.Close()
+
+	deregister, err := ConsulRegister(consul.URL+"/", "Foo", "tcp@0.0.0.0:9999", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := registered
+	mu.Unlock()
+	// 监听在所有地址上时由 Consul 使用节点地址，健康检查访问本机
+	if got.ID != "Foo-tcp@0.0.0.0:9999" || got.Name != "Foo" || got.Address != "" || got.Port != 9999 ||
+		got.Meta["protocol"] != "tcp" {
+		t.Fatalf("unexpected service definition %+v", got)
+	}
+	if got.Check.TCP != "127.0.0.1:9999" || got.Check.Interval != "1s" || got.Check.DeregisterCriticalServiceAfter != "6s" {
+		t.Fatalf("unexpected health check %+v", got.Check)
+	}
+
+	if err := deregister(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if deregistered != "Foo-tcp@0.0.0.0:9999" {
+		t.Fatalf("expect the service to be deregistered by ID, but got %q", deregistered)
+	}
+}
+
+func TestConsulRegister_Errors(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer consul.Close()
+	if _, err := ConsulRegister(consul.URL, "Foo", "tcp@127.0.0.1:9999", 0); err == nil {
+		t.Fatal("expect an error when consul rejects the registration")
+	}
+	if _, err := ConsulRegister(consul.URL, "Foo", "tcp@127.0.0.1", 0); err == nil {
+		t.Fatal("expect an error for an address without a port")
+	}
+}
+
+func TestGeeRegistry_Meta(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+
+	meta := Meta{Zone: "us-east-1a", Version: "v1.2.0", Codecs: []string{"application/gob"}, Services: []string{"Foo"}}
+	if err := client.Register("tcp@127.0.0.1:9999", meta); err != nil {
+		t.Fatal(err)
+	}
+	list, _, err := client.List(ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := meta
+	want.Weight = 1 // 未设置权重时默认为 1
+	want.TTL = 60
+	if len(list.Servers) != 1 || !reflect.DeepEqual(list.Servers[0].Meta, want) {
+		t.Fatalf("expect the metadata to round-trip as %+v, got %+v", want, list.Servers)
+	}
+
+	// 元数据变化时递增版本号并推送 update 事件，未变化的心跳不改变版本号
+	ch, _ := r.subscribe(nil)
+	defer r.unsubscribe(ch)
+	index, _ := r.watch()
+	_ = client.Register("tcp@127.0.0.1:9999", meta)
+	if current, _ := r.watch(); current != index {
+		t.Fatalf("expect an unchanged heartbeat to keep index %d, got %d", index, current)
+	}
+	meta.Version = "v1.3.0"
+	_ = client.Register("tcp@127.0.0.1:9999", meta)
+	select {
+	case e := <-ch:
+		if e.Type != EventUpdate || e.Server.Version != "v1.3.0" || e.Index != index+1 {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect an update event when the metadata changes")
+	}
+
+	// 旧的请求头协议同样携带元数据
+	req, _ := http.NewRequest("POST", ts.URL, nil)
+	req.Header.Set("X-Geerpc-Server", "tcp@127.0.0.1:8888")
+	req.Header.Set("X-Geerpc-Meta", `{"weight":5,"zone":"us-west-2b"}`)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("legacy register failed: %v %v", err, resp)
+	}
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metas map[string]Meta
+	if err := json.Unmarshal([]byte(resp.Header.Get("X-Geerpc-Meta")), &metas); err != nil {
+		t.Fatal(err)
+	}
+	if m := metas["tcp@127.0.0.1:8888"]; m.Weight != 5 || m.Zone != "us-west-2b" {
+		t.Fatalf("unexpected legacy metadata %+v", metas)
+	}
+	req.Header.Set("X-Geerpc-Meta", `not json`)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect 400 for invalid metadata, got %v %v", err, resp)
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	const addr = "tcp@127.0.0.1:9999"
+
+	StartHeartbeat(context.Background(), ts.URL, addr, HeartbeatOptions{Interval: 10 * time.Millisecond})
+	if servers := r.aliveServers(); len(servers) != 1 {
+		t.Fatalf("expect the first heartbeat to register the server, got %v", servers)
+	}
+	ch, _ := r.subscribe(nil)
+	defer r.unsubscribe(ch)
+	if err := Deregister(ts.URL, addr); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-ch:
+		if e.Type != EventLeave || e.Server.Addr != addr {
+			t.Fatalf("expect a leave event, got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect a leave event after deregistration")
+	}
+	// 心跳已经停止，服务器不会被重新注册
+	time.Sleep(50 * time.Millisecond)
+	if servers := r.aliveServers(); len(servers) != 0 {
+		t.Fatalf("expect the heartbeat to stop after deregistration, got %v", servers)
+	}
+	// 重复注销不是错误
+	if err := Deregister(ts.URL, addr); err != nil {
+		t.Fatalf("expect deregistering an unknown server to succeed, got %v", err)
+	}
+
+	// 旧的请求头协议：未注册时返回 404，缺少地址时返回 400
+	r.putServer(addr, Meta{})
+	del := func(addr string) int {
+		req, _ := http.NewRequest("DELETE", ts.URL, nil)
+		if addr != "" {
+			req.Header.Set("X-Geerpc-Server", addr)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := del(addr); code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", code)
+	}
+	if code := del(addr); code != http.StatusNotFound {
+		t.Fatalf("expect 404 for an unknown server, got %d", code)
+	}
+	if code := del(""); code != http.StatusBadRequest {
+		t.Fatalf("expect 400 without an address, got %d", code)
+	}
+	if err := NewClient("http://127.0.0.1:1").Deregister(addr); err == nil {
+		t.Fatal("expect an error when the registry is unreachable")
+	}
+}
+
+func TestGeeRegistry_LeaseTTL(t *testing.T) {
+	r := New(time.Minute)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := NewClient(ts.URL)
+	_ = client.Register("tcp@127.0.0.1:1", Meta{})
+	_ = client.Register("tcp@127.0.0.1:2", Meta{TTL: 10})
+	ttls := func() map[string]int {
+		list, _, err := client.List(ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, s := range list.Servers {
+			got[s.Addr] = s.TTL
+		}
+		return got
+	}
+	if got := ttls(); got["tcp@127.0.0.1:1"] != 60 || got["tcp@127.0.0.1:2"] != 10 {
+		t.Fatalf("expect the default and the chosen lease, got %v", got)
+	}
+
+	// 租约过期的服务器被移除，其他服务器不受影响
+	age := func(addr string, d time.Duration) {
+		r.mu.Lock()
+		r.servers[addr].start = time.Now().Add(-d)
+		r.mu.Unlock()
+	}
+	age("tcp@127.0.0.1:1", 30*time.Second)
+	age("tcp@127.0.0.1:2", 30*time.Second)
+	if servers := r.aliveServers(); !reflect.DeepEqual(servers, []string{"tcp@127.0.0.1:1"}) {
+		t.Fatalf("expect only the server with the short lease to expire, got %v", servers)
+	}
+	if n := atomic.LoadUint64(&r.metrics.expirations); n != 1 {
+		t.Fatalf("expect 1 expiration, got %d", n)
+	}
+
+	// 超出范围的租约被截断到边界
+	r.SetTTLBounds(5*time.Second, time.Hour)
+	_ = client.Register("tcp@127.0.0.1:3", Meta{TTL: 1})
+	_ = client.Register("tcp@127.0.0.1:4", Meta{TTL: 7200})
+	if got := ttls(); got["tcp@127.0.0.1:3"] != 5 || got["tcp@127.0.0.1:4"] != 3600 {
+		t.Fatalf("expect the leases to be clamped to [5, 3600], got %v", got)
+	}
+}
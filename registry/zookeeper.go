@@ -0,0 +1,30 @@
+package registry
+
+import (
+	"geerpc"
+	"net/url"
+	"strings"
+)
+
+// ZKConn 是 ZKRegister 和 xclient.ZKDiscovery 所需的 ZooKeeper 连接能力。
+// geerpc 不依赖任何第三方库，调用方可以用几行代码把所使用的 ZooKeeper 客户端
+// （例如 github.com/go-zookeeper/zk）适配为该接口
+type ZKConn interface {
+	// CreateEphemeral 创建一个临时节点，会话断开后节点由 ZooKeeper 自动删除，
+	// 父节点不存在时应一并创建（持久节点）
+	CreateEphemeral(path string, data []byte) error
+	// ChildrenW 返回 path 的子节点列表，并在子节点变化时关闭返回的 channel
+	ChildrenW(path string) (children []string, changed <-chan struct{}, err error)
+}
+
+// ZKRegister 在 root 下为 rpcAddr 创建一个临时节点，
+// 服务器进程退出或会话过期后节点自动消失，无需发送心跳
+func ZKRegister(conn ZKConn, root, rpcAddr string) error {
+	path := strings.TrimSuffix(root, "/") + "/" + url.PathEscape(rpcAddr)
+	if err := conn.CreateEphemeral(path, []byte(rpcAddr)); err != nil {
+		geerpc.DefaultLogger().Error("rpc server: zookeeper register err", "err", err)
+		return err
+	}
+	geerpc.DefaultLogger().Info("rpc server: registered to zookeeper", "addr", rpcAddr, "path", path)
+	return nil
+}
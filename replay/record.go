@@ -0,0 +1,116 @@
+// Package replay 录制 RPC 调用并在之后重放，用于以真实的生产流量形态做回归测试。
+//
+// Recorder 以拦截器的形式挂在服务端（Server.Use）或客户端（XClient.Use）上，
+// 将每次调用的方法、参数、返回值、错误和耗时以 JSON Lines 格式写入文件；
+// Run 读取这些记录，按原来的顺序（可选地按原来的时间间隔）重新发起调用，并比较返回值与录制时是否一致。
+// 重放的目标可以是真实的服务器，也可以是 geerpctest 的模拟服务器，只要它实现了 geerpc.Caller
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"geerpc"
+	"geerpc/xclient"
+	"io"
+	"sync"
+	"time"
+)
+
+// Record 是一次调用的录制记录，参数和返回值以 JSON 保存，便于查看和编辑
+type Record struct {
+	Time          time.Time       `json:"time"` // 调用开始的时间
+	ServiceMethod string          `json:"method"`
+	RequestID     string          `json:"request_id,omitempty"`
+	Args          json.RawMessage `json:"args"`
+	Reply         json.RawMessage `json:"reply,omitempty"` // 调用失败时为空
+	Error         string          `json:"error,omitempty"`
+	Duration      time.Duration   `json:"duration"`
+}
+
+// Recorder 将调用记录以每行一个 JSON 对象的格式写入 io.Writer，可以同时被多个 goroutine 使用
+type Recorder struct {
+	mu sync.Mutex // 保证记录不会交错
+	w  io.Writer
+
+	// Filter 不为 nil 时只录制返回 true 的方法，例如跳过 "Health.Check"
+	Filter func(serviceMethod string) bool
+}
+
+// NewRecorder 创建一个写入 w 的 Recorder，通常 w 是一个文件
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// ServerInterceptor 返回录制服务端调用的拦截器，应通过 Server.Use 追加在最内层，以录制实际传给服务方法的参数
+func (r *Recorder) ServerInterceptor() geerpc.ServerInterceptor {
+	return func(ctx context.Context, info *geerpc.CallInfo, args, reply interface{}, next geerpc.Handler) error {
+		if r.Filter != nil && !r.Filter(info.ServiceMethod) {
+			return next(ctx, info, args, reply)
+		}
+		rec := r.begin(info.ServiceMethod, info.RequestID, args)
+		err := next(ctx, info, args, reply)
+		r.finish(rec, reply, err)
+		return err
+	}
+}
+
+// ClientInterceptor 返回录制 XClient 调用的拦截器
+func (r *Recorder) ClientInterceptor() xclient.Interceptor {
+	return func(ctx context.Context, serviceMethod string, args, reply interface{}, next xclient.Invoker) error {
+		if r.Filter != nil && !r.Filter(serviceMethod) {
+			return next(ctx, serviceMethod, args, reply)
+		}
+		rec := r.begin(serviceMethod, geerpc.RequestIDFromContext(ctx), args)
+		err := next(ctx, serviceMethod, args, reply)
+		r.finish(rec, reply, err)
+		return err
+	}
+}
+
+// begin 在调用之前编码参数，避免服务方法修改参数后录制到修改过的值
+func (r *Recorder) begin(serviceMethod, requestID string, args interface{}) *Record {
+	rec := &Record{Time: time.Now(), ServiceMethod: serviceMethod, RequestID: requestID}
+	rec.Args, _ = json.Marshal(args)
+	return rec
+}
+
+func (r *Recorder) finish(rec *Record, reply interface{}, err error) {
+	rec.Duration = time.Since(rec.Time)
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Reply, _ = json.Marshal(reply)
+	}
+	r.write(rec)
+}
+
+// write 写入一条记录，写入失败时忽略，不影响调用本身
+func (r *Recorder) write(rec *Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.w.Write(append(data, '\n'))
+}
+
+// ReadRecords 读取 Recorder 写入的所有记录，按录制的顺序排列，忽略空行
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,145 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Options 是重放的选项
+type Options struct {
+	// Speed 控制调用之间的间隔：0 表示不等待，依次尽快发起；1 表示按录制时的间隔；2 表示两倍速，依此类推。
+	// 调用是依次发起的，前一次调用耗时超过间隔时后面的调用会相应推迟
+	Speed float64
+
+	// Timeout 是单次调用的超时时间，0 表示不限制
+	Timeout time.Duration
+
+	// Match 不为 nil 时用于判断重放的结果是否与录制时一致，默认要求错误信息相同，且成功时返回值的 JSON 等价
+	Match func(want, got *Record) bool
+}
+
+// Mismatch 是一次与录制时不一致的调用
+type Mismatch struct {
+	Want Record // 录制的记录
+	Got  Record // 重放的结果
+}
+
+// Report 是重放的结果
+type Report struct {
+	Calls      int        // 重放的调用数
+	Skipped    []Record   // methods 中没有对应类型、无法重放的记录
+	Mismatches []Mismatch // 结果与录制时不一致的调用
+}
+
+// Run 将 records 按录制的时间顺序通过 c 重新发起调用。methods 提供参数和返回值的类型，通常来自 geerpc.DescribeService，
+// 没有对应类型的记录被跳过。ctx 被取消时停止重放并返回已完成部分的结果和 ctx 的错误
+func Run(ctx context.Context, c geerpc.Caller, records []Record, methods []geerpc.MethodDesc, opt Options) (*Report, error) {
+	types := make(map[string]geerpc.MethodDesc, len(methods))
+	for _, m := range methods {
+		types[m.ServiceMethod] = m
+	}
+	match := opt.Match
+	if match == nil {
+		match = defaultMatch
+	}
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	report := &Report{}
+	start := time.Now()
+	for i := range sorted {
+		want := &sorted[i]
+		m, ok := types[want.ServiceMethod]
+		if !ok {
+			report.Skipped = append(report.Skipped, *want)
+			continue
+		}
+		if opt.Speed > 0 {
+			offset := time.Duration(float64(want.Time.Sub(sorted[0].Time)) / opt.Speed)
+			if err := sleepUntil(ctx, start.Add(offset)); err != nil {
+				return report, err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		got, err := call(ctx, c, want, m, opt.Timeout)
+		if err != nil {
+			return report, err
+		}
+		report.Calls++
+		if !match(want, got) {
+			report.Mismatches = append(report.Mismatches, Mismatch{Want: *want, Got: *got})
+		}
+	}
+	return report, nil
+}
+
+// call 重新发起 want 记录的调用，返回的错误表示记录本身无效（例如参数无法解码），调用的错误记录在结果中
+func call(ctx context.Context, c geerpc.Caller, want *Record, m geerpc.MethodDesc, timeout time.Duration) (*Record, error) {
+	argv := reflect.New(m.ArgType)
+	if len(want.Args) > 0 {
+		if err := json.Unmarshal(want.Args, argv.Interface()); err != nil {
+			return nil, errors.New("replay: invalid args of " + want.ServiceMethod + ": " + err.Error())
+		}
+	}
+	replyv := reflect.New(m.ReplyType.Elem())
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if want.RequestID != "" {
+		ctx = geerpc.WithRequestID(ctx, want.RequestID)
+	}
+	got := &Record{Time: time.Now(), ServiceMethod: want.ServiceMethod, RequestID: want.RequestID, Args: want.Args}
+	err := c.Call(ctx, want.ServiceMethod, argv.Elem().Interface(), replyv.Interface())
+	got.Duration = time.Since(got.Time)
+	if err != nil {
+		got.Error = err.Error()
+	} else {
+		got.Reply, _ = json.Marshal(replyv.Interface())
+	}
+	return got, nil
+}
+
+// defaultMatch 要求错误信息相同，成功时返回值的 JSON 解码后相等，因此不受字段顺序和空白的影响
+func defaultMatch(want, got *Record) bool {
+	if want.Error != got.Error {
+		return false
+	}
+	if want.Error != "" {
+		return true
+	}
+	return JSONEqual(want.Reply, got.Reply)
+}
+
+// JSONEqual 返回 a 和 b 解码后是否相等，自定义 Options.Match 时可以用它比较返回值的一部分
+func JSONEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
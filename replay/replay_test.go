@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"geerpc"
+	"testing"
+)
+
+type Args struct{ Num1, Num2 int }
+
+type Arith struct{ bug bool }
+
+func (a *Arith) Add(args Args, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	if a.bug {
+		*reply++
+	}
+	return nil
+}
+
+func (a *Arith) Div(args *Args, reply *int) error {
+	if args.Num2 == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.Num1 / args.Num2
+	return nil
+}
+
+func newClient(t *testing.T, arith *Arith, rec *Recorder) *geerpc.Client {
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	if rec != nil {
+		server.Use(rec.ServerInterceptor())
+	}
+	if err := server.Register(arith); err != nil {
+		t.Fatal(err)
+	}
+	client, err := geerpc.DialInProc(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	client := newClient(t, &Arith{}, NewRecorder(&buf))
+	ctx := context.Background()
+	var reply int
+	_ = client.Call(ctx, "Arith.Add", Args{1, 2}, &reply)
+	_ = client.Call(geerpc.WithRequestID(ctx, "req-1"), "Arith.Div", &Args{6, 3}, &reply)
+	_ = client.Call(ctx, "Arith.Div", &Args{1, 0}, &reply)
+	_ = client.Close()
+
+	records, err := ReadRecords(&buf)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("expect 3 records, got %d, err %v", len(records), err)
+	}
+	if records[2].Error != "divide by zero" || records[1].RequestID != "req-1" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	methods, _ := geerpc.DescribeService(&Arith{})
+
+	client = newClient(t, &Arith{}, nil)
+	report, err := Run(ctx, client, records, methods, Options{})
+	if err != nil || report.Calls != 3 || len(report.Mismatches) != 0 {
+		t.Fatalf("expect a clean replay, got %+v, err %v", report, err)
+	}
+	_ = client.Close()
+
+	client = newClient(t, &Arith{bug: true}, nil)
+	defer func() { _ = client.Close() }()
+	report, err = Run(ctx, client, records, methods[:1], Options{})
+	if err != nil || report.Calls != 1 || len(report.Skipped) != 2 || len(report.Mismatches) != 1 {
+		t.Fatalf("expect one mismatch, got %+v, err %v", report, err)
+	}
+	if m := report.Mismatches[0]; string(m.Want.Reply) != "3" || string(m.Got.Reply) != "4" {
+		t.Fatalf("unexpected mismatch %+v", m)
+	}
+}
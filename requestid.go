@@ -0,0 +1,38 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// requestIDKey 是 context 中保存请求 ID 的键
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求 ID 的 context。客户端使用该 context 发起调用时，
+// 请求 ID 会随请求头发送给服务端，而不是生成新的 ID，从而在多个服务之间传递同一个 ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 返回 context 中的请求 ID。
+// 服务端方法的第一个参数声明为 context.Context 时，可以通过它获取当前请求的 ID
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// fallbackID 在无法读取随机数时用于生成请求 ID
+var fallbackID uint64
+
+// newRequestID 生成一个随机的请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16) + "-" + strconv.FormatUint(atomic.AddUint64(&fallbackID, 1), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,17 +1,23 @@
 package geerpc
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"geerpc/codec"
 	"io"
-	"log"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +30,46 @@ type Option struct {
 	CodecType      codec.Type    // 客户端可以选择不同的编解码器来编码请求体
 	ConnectTimeout time.Duration // 0 表示没有超时限制
 	HandleTimeout  time.Duration
+	Credentials    string `json:",omitempty"` // 握手时发送给服务端的凭证（例如令牌或 JWT），应与 TLS 一起使用以免泄露
+	SigningKeyID   string `json:",omitempty"` // 签名密钥的 ID，服务端据此查找密钥
+	SigningKey     []byte `json:"-"`          // 不为 nil 时对连接上的数据进行 HMAC 签名，不会发送给服务端
+	Logger         Logger `json:"-"`          // 客户端使用的 Logger，为 nil 时使用 DefaultLogger，不会发送给服务端
+
+	// SigningTimestamp 和 SigningNonce 由客户端在握手时自动填写，用于防止重放
+	SigningTimestamp int64  `json:",omitempty"`
+	SigningNonce     string `json:",omitempty"`
+
+	// Encryption 不为 nil 时使用其中的主密钥加密连接上的数据，服务端需要使用 SetEncryption 设置对应的密钥，
+	// Encrypted 由客户端在握手时自动填写
+	Encryption *Keyring `json:"-"`
+	Encrypted  bool     `json:",omitempty"`
+
+	// Chunking 由客户端在握手时自动填写，表示客户端可以接收分块发送的返回值，参见 Server.SetChunkSize
+	Chunking bool `json:",omitempty"`
+
+	// SlowCallThreshold 是客户端的慢调用阈值，耗时不低于该值的调用会输出一条 Warn 级别的日志，0 表示不记录
+	SlowCallThreshold time.Duration `json:"-"`
+
+	// TLSConfig 不为 nil 时，Dial、DialHTTP 和 XDial 通过 TLS 连接服务端，
+	// 服务端需要使用 AcceptTLS（或通过 HTTPS 提供 HandleHTTP）
+	TLSConfig *tls.Config `json:"-"`
+
+	// Events 不为 nil 时，客户端会在调用超时时发布 CallTimedOut 事件
+	Events *EventBus `json:"-"`
+
+	// Metrics 不为 nil 时，客户端的每次调用都会记录到其中
+	Metrics *ClientMetrics `json:"-"`
+
+	// Capture 不为 nil 时，客户端连接上收发的原始字节（包括选项握手）会被记录下来，仅用于调试
+	Capture *Capture `json:"-"`
+}
+
+// log 返回客户端使用的 Logger
+func (opt *Option) log() Logger {
+	if opt != nil && opt.Logger != nil {
+		return opt.Logger
+	}
+	return DefaultLogger()
 }
 
 // DefaultOption 是默认的 Option 实例
@@ -33,7 +79,7 @@ var DefaultOption = &Option{
 	ConnectTimeout: time.Second * 10,
 }
 
-//令牌桶
+// 令牌桶
 type TokenBucket struct {
 	tokens         int           // 当前令牌数量
 	capacity       int           // 令牌桶容量
@@ -58,7 +104,7 @@ func (tb *TokenBucket) Allow() bool {
 	defer tb.mu.Unlock()
 
 	now := time.Now()
-	tokensToAdd := int(now.Sub(tb.lastRefill) / tb.refillInterval) * tb.refillAmount
+	tokensToAdd := int(now.Sub(tb.lastRefill)/tb.refillInterval) * tb.refillAmount
 	if tokensToAdd > 0 {
 		tb.tokens = tb.tokens + tokensToAdd
 		if tb.tokens > tb.capacity {
@@ -77,7 +123,59 @@ func (tb *TokenBucket) Allow() bool {
 
 // Server 表示一个 RPC 服务器
 type Server struct {
+	// 以下计数器使用原子操作访问，放在首位以保证 64 位对齐
+	inflight    int64  // 正在处理的请求数
+	connections int64  // 当前的连接数
+	accepted    uint64 // 累计的连接数
+	sampleSeq   uint64 // 参与采样的请求数
+	overload    int64  // 过载阈值，0 表示不检查
+	closing     int32  // 是否已调用 Shutdown
+	timeout     int64  // 服务端的处理超时（纳秒），0 表示只使用客户端的 HandleTimeout
+	chunkSize   int64  // 返回值分块发送的阈值和每块的字节数，0 表示不分块
+
 	serviceMap sync.Map
+	metrics    serverMetrics
+	logger     Logger         // 为 nil 时使用 DefaultLogger
+	logCalls   int32          // 不为 0 时为每个请求输出一条日志，使用原子操作访问
+	slowCall   time.Duration  // 慢调用阈值，0 表示不记录
+	pprof      bool           // HandleDebugHTTP 是否挂载 pprof 接口
+	capture    *Capture       // 不为 nil 时记录连接上收发的原始字节
+	audit      AuditSink      // 不为 nil 时为每个请求生成审计记录
+	sampling   LogSampling    // 详细请求日志的采样策略
+	events     *EventBus      // 不为 nil 时发布框架事件
+	authorizer Authorizer     // 不为 nil 时在分发请求之前检查权限
+	validator  TokenValidator // 不为 nil 时要求每个请求都经过认证
+
+	signingKeys    func(keyID string) []byte // 根据密钥 ID 查找签名密钥
+	requireSigning bool                      // 是否拒绝没有签名的连接
+	ipFilter       *ipFilter                 // 不为 nil 时按地址过滤连接
+	encryptKeys    *Keyring                  // 解密连接数据使用的密钥
+	requireEncrypt bool                      // 是否拒绝没有加密的连接
+	quotas         quotaLimiter              // 按调用方身份的配额
+	lockout        authLockout               // 按来源记录认证失败并暂时锁定
+	replayWindow   time.Duration             // 允许的握手时间戳偏差，0 表示使用默认值
+	nonces         nonceCache                // 窗口期内使用过的握手随机数
+	status         atomic.Value              // 手动设置的就绪状态（string）
+	interceptors   []ServerInterceptor       // 服务端拦截器，先追加的位于外层
+	decodeLimits   *codec.Limits             // 不为 nil 时覆盖编解码器默认的解码限制
+	noDebug        bool                      // HandleDebugHTTP 是否不挂载任何调试接口
+	debugAuth      func(*http.Request) bool  // 不为 nil 时调试接口只响应返回 true 的请求
+	noHealth       bool                      // 是否不提供内置的 Health 服务和 HTTP 健康检查接口
+	jsonrpc        bool                      // 是否接受 JSON-RPC 2.0 请求
+	reflection     bool                      // 是否提供内置的 Reflection 服务
+	connRate       atomic.Value              // connRateLimit，未设置时使用 defaultConnRateLimit
+	coalescing     atomic.Value              // writeCoalescing，未设置时不合并响应的写入
+	wsOrigins      []string                  // 额外允许发起 WebSocket 连接的来源
+	plugins        []Plugin                  // 通过 AddPlugin 添加的插件
+
+	connsMu sync.Mutex // 保护 conns
+	conns   map[uint64]*connTracker
+
+	listenersMu sync.Mutex // 保护 listeners
+	listeners   map[net.Listener]struct{}
+
+	builtinMu sync.Mutex   // 保护 noHealth 和 reflection 的修改，使 builtin 与它们保持一致
+	builtin   atomic.Value // map[string]*service，内置服务，例如 Health 和 Reflection
 }
 
 // NewServer 返回一个新的 Server 实例
@@ -85,79 +183,413 @@ func NewServer() *Server {
 	return &Server{}
 }
 
+// SetLogger 设置服务器使用的 Logger，应在开始服务之前调用
+func (server *Server) SetLogger(l Logger) {
+	server.logger = l
+}
+
+// SetRequestLogging 设置是否为每个处理完的请求输出一条 Info 级别的日志，
+// 日志携带 method、seq、remote、duration 和 error（仅失败时）键值对，
+// 与 JSONLogger 配合使用时日志系统可以直接索引这些字段。默认关闭，可以在运行时开启或关闭
+func (server *Server) SetRequestLogging(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&server.logCalls, v)
+}
+
+// finishRequest 在请求处理完成后记录指标和审计记录，并按需输出请求日志
+func (server *Server) finishRequest(req *request, start time.Time, errMsg string) {
+	d := time.Since(start)
+	server.metrics.observe(req.h.ServiceMethod, d, errMsg != "")
+	server.logSlowRequest(req, d, errMsg)
+	server.auditRequest(req, start, d, errMsg)
+	sampled := server.sampleRequest(errMsg != "")
+	if atomic.LoadInt32(&server.logCalls) == 0 && !sampled {
+		return
+	}
+	keyvals := []interface{}{"method", req.h.ServiceMethod, "seq", req.h.Seq, "request_id", req.h.RequestID, "remote", req.remote, "duration", d}
+	if errMsg != "" {
+		keyvals = append(keyvals, "error", errMsg)
+	}
+	if sampled {
+		keyvals = append(keyvals, "args", argsSummary(req.argv.Interface()))
+		if errMsg == "" {
+			keyvals = append(keyvals, "reply", argsSummary(req.replyv.Interface()))
+		}
+	}
+	server.log().Info("rpc server: request handled", keyvals...)
+}
+
+// SetCapture 设置记录连接原始字节的 Capture，之后建立的连接上收发的数据（包括选项握手）都会被记录，
+// 传入 nil 关闭记录。记录会包含完整的请求和响应数据，仅应在调试时开启
+func (server *Server) SetCapture(c *Capture) {
+	server.capture = c
+}
+
+// log 返回服务器使用的 Logger
+func (server *Server) log() Logger {
+	if server.logger != nil {
+		return server.logger
+	}
+	return DefaultLogger()
+}
+
 // DefaultServer 是默认的 *Server 实例
 var DefaultServer = NewServer()
 
+// maxOptionSize 是选项握手消息的最大字节数
+const maxOptionSize = 64 << 10
+
 // ServeConn 在单个连接上运行服务器，阻塞地为连接服务，直到客户端挂断
 func (server *Server) ServeConn(conn io.ReadWriteCloser) {
-	defer func() { _ = conn.Close() }()
+	server.serveConn(conn, nil)
+}
+
+// serveConn 完成选项握手并为连接提供服务。pc 不为 nil 时，握手之后的普通连接交给事件循环，
+// 空闲时不占用协程，参见 AcceptEvented
+func (server *Server) serveConn(conn io.ReadWriteCloser, pc *pollConn) {
+	conn, tracker, done := server.openConn(conn)
+	parked := false
+	defer func() {
+		if !parked {
+			done()
+		}
+	}()
+	if conn == nil {
+		return
+	}
+	remote := tracker.remote
 	var opt Option
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
-		log.Println("rpc server: options error: ", err)
+	var raw json.RawMessage
+	// 限制握手消息的大小，避免畸形的握手在解码时无限制地读取和分配内存。
+	// 开启 JSON-RPC 时第一个消息可能是请求本身，与之后的 JSON-RPC 消息使用相同的限制
+	hs := &messageLimitReader{r: conn, limit: maxOptionSize}
+	if server.jsonrpc {
+		hs.limit = server.handshakelessMessageSize()
+	}
+	dec := json.NewDecoder(hs)
+	err := dec.Decode(&raw)
+	if err == nil && server.jsonrpc && isJSONRPC(raw) {
+		if err := server.checkJSONRPC(); err != nil {
+			server.log().Warn("rpc server: json-rpc rejected", "remote", remote, "err", err)
+			server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: err})
+			return
+		}
+		server.serveJSONRPC(tracker, conn, raw, io.MultiReader(dec.Buffered(), conn))
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &opt)
+	}
+	if err != nil {
+		server.log().Error("rpc server: options error", "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: err})
 		return
 	}
 	if opt.MagicNumber != MagicNumber {
-		log.Printf("rpc server: invalid magic number %x", opt.MagicNumber)
+		server.log().Error("rpc server: invalid magic number", "magic", fmt.Sprintf("%x", opt.MagicNumber))
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: fmt.Errorf("rpc server: invalid magic number %x", opt.MagicNumber)})
 		return
 	}
 	f := codec.NewCodecFuncMap[opt.CodecType]
 	if f == nil {
-		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
+		server.log().Error("rpc server: invalid codec type", "codec", opt.CodecType)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: fmt.Errorf("rpc server: invalid codec type %s", opt.CodecType)})
 		return
 	}
-	server.serveCodec(f(conn), &opt)
+	if err := server.authenticateConn(tracker, opt.Credentials); err != nil {
+		server.log().Warn("rpc server: invalid credentials", "remote", remote, "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: err})
+		return
+	}
+	signingKey, err := server.signingKey(&opt)
+	if err != nil {
+		server.log().Warn("rpc server: signing error", "remote", remote, "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: err})
+		return
+	}
+	if err := server.checkEncryption(&opt); err != nil {
+		server.log().Warn("rpc server: encryption error", "remote", remote, "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: remote, Err: err})
+		return
+	}
+	r := afterOption(dec, conn)
+	bc := &bufferedConn{Reader: r, ReadWriteCloser: server.coalesce(conn)}
+	var rwc io.ReadWriteCloser = bc
+	if signingKey != nil {
+		sc := newSignedConn(rwc, signingKey, signServer)
+		sc.onFirst = server.checkNonce(opt.SigningNonce)
+		rwc = sc
+	}
+	if opt.Encrypted {
+		rwc = newEncryptedConn(rwc, server.encryptKeys, signServer)
+	}
+	cc := f(rwc)
+	if l, ok := cc.(codec.Limiter); ok && server.decodeLimits != nil {
+		l.SetLimits(*server.decodeLimits)
+	}
+	// 签名和加密的连接在内部缓冲解码后的数据，无法判断是否还有未处理的请求，仍然由协程服务
+	if b, ok := cc.(codec.Buffered); ok && pc != nil && signingKey == nil && !opt.Encrypted {
+		parked = true
+		pc.start(server.newCodecConn(cc, &opt, tracker), func() int { return r.Buffered() + b.Buffered() }, done)
+		return
+	}
+	server.serveCodec(cc, &opt, tracker)
+}
+
+// openConn 登记一个新连接并发布 ConnAccepted 事件，返回用于读写的连接（可能被插件或 Capture 包装）和连接的统计数据，
+// 连接处理结束后必须调用 done 关闭连接并取消登记。服务器正在关闭或插件拒绝连接时返回的 rwc 为 nil
+func (server *Server) openConn(conn io.ReadWriteCloser) (rwc io.ReadWriteCloser, tracker *connTracker, done func()) {
+	var remote string
+	if c, ok := conn.(net.Conn); ok {
+		remote = c.RemoteAddr().String()
+	}
+	var identity string
+	if c, ok := conn.(*tls.Conn); ok {
+		identity = TLSIdentity(c.ConnectionState())
+	}
+	if atomic.LoadInt32(&server.closing) != 0 {
+		return nil, nil, func() { _ = conn.Close() }
+	}
+	if c := server.pluginsAccept(conn, remote); c != nil {
+		conn = c
+	} else {
+		return nil, nil, func() { _ = conn.Close() }
+	}
+	id := atomic.AddUint64(&server.accepted, 1)
+	atomic.AddInt64(&server.connections, 1)
+	tracker, untrack := server.trackConn(id, remote, identity, conn)
+	rwc = tracker
+	if server.capture != nil {
+		rwc = server.capture.wrap(rwc)
+	}
+	server.events.Publish(ConnAccepted{Time: time.Now(), Remote: remote})
+	return rwc, tracker, func() {
+		untrack()
+		_ = conn.Close()
+		atomic.AddInt64(&server.connections, -1)
+		server.pluginsConnClose(tracker)
+	}
+}
+
+// SetDecodeLimits 设置解码请求时的资源限制，防止很小的恶意请求在解码时展开为大量的内存分配。
+// 未设置时编解码器使用各自的默认限制（gob 只限制单个消息不超过 codec.DefaultMaxMessageSize）。
+// 消息超过大小限制时连接被关闭，消息体超过长度或深度限制时请求返回错误。应在开始服务之前调用
+func (server *Server) SetDecodeLimits(l codec.Limits) {
+	server.decodeLimits = &l
+}
+
+// connRateLimit 是每个连接的请求速率限制
+type connRateLimit struct {
+	burst     int // 令牌桶容量
+	perSecond int // 每秒添加的令牌数，0 表示不限制
+}
+
+// defaultConnRateLimit 是每个连接默认的请求速率限制
+var defaultConnRateLimit = connRateLimit{burst: 10, perSecond: 2}
+
+// SetConnRateLimit 设置每个连接的请求速率限制：每秒 perSecond 个请求，最多允许 burst 个突发请求，
+// burst 小于 1 时与 perSecond 相同。perSecond <= 0 表示不限制，默认为每秒 2 个、突发 10 个。
+// 按调用方身份的限制参见 SetQuotas。可以在运行时调用，对之后建立的连接生效
+func (server *Server) SetConnRateLimit(burst, perSecond int) {
+	if burst < 1 {
+		burst = perSecond
+	}
+	server.connRate.Store(connRateLimit{burst: burst, perSecond: perSecond})
+}
+
+// currentConnRate 返回当前每个连接的请求速率限制
+func (server *Server) currentConnRate() connRateLimit {
+	if rate, ok := server.connRate.Load().(connRateLimit); ok {
+		return rate
+	}
+	return defaultConnRateLimit
+}
+
+// SetHandleTimeout 设置服务端处理请求的超时时间：客户端没有设置 Option.HandleTimeout 或设置的值更长时使用 d，
+// 0（默认）表示只使用客户端的设置。可以在运行时调用，对之后的请求生效
+func (server *Server) SetHandleTimeout(d time.Duration) {
+	atomic.StoreInt64(&server.timeout, int64(d))
+}
+
+// handleTimeout 返回处理请求的超时时间，client 是客户端握手时设置的 HandleTimeout
+func (server *Server) handleTimeout(client time.Duration) time.Duration {
+	d := time.Duration(atomic.LoadInt64(&server.timeout))
+	if d > 0 && (client == 0 || client > d) {
+		return d
+	}
+	return client
+}
+
+// afterOption 返回读取选项之后的数据的 Reader。JSON 解码器可能多读了紧随其后的请求数据，需要将其拼回连接的读取端，
+// 同时跳过 json.Encoder 在选项末尾追加的换行符。缓冲区足以容纳这些数据，
+// 使它们在第一次读取后全部进入缓冲区，事件驱动的连接据此判断是否还有未处理的数据
+func afterOption(dec *json.Decoder, conn io.Reader) *bufio.Reader {
+	rest, _ := ioutil.ReadAll(dec.Buffered())
+	size := 4096 // bufio.Reader 的默认大小
+	if len(rest) > size {
+		size = len(rest)
+	}
+	r := bufio.NewReaderSize(io.MultiReader(bytes.NewReader(rest), conn), size)
+	if b, err := r.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = r.Discard(1)
+	}
+	return r
+}
+
+// bufferedConn 先读取选项解码时缓冲的剩余数据，再继续读取原连接
+type bufferedConn struct {
+	io.Reader
+	io.ReadWriteCloser
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
 }
 
 // invalidRequest 是发生错误时响应的占位符
 var invalidRequest = struct{}{}
 
 // serveCodec 处理编解码器并为请求提供服务
-func (server *Server) serveCodec(cc codec.Codec, opt *Option) {
-	sending := new(sync.Mutex) // 确保发送完整的响应
-	wg := new(sync.WaitGroup)  // 等待所有请求处理完成
-	tb := NewTokenBucket(10, 2, time.Second) // 创建令牌桶，每秒添加2个令牌
-	for {
-		// 检查令牌桶中是否有足够的令牌
-		if !tb.Allow() {
-			log.Println("rpc server: rate limit exceeded")
-			// Send error response indicating rate limit exceeded
-			server.sendResponse(cc, &codec.Header{ServiceMethod: ""}, "rate limit exceeded", sending)
-			continue
-		}
-		req, err := server.readRequest(cc)
-		if err != nil {
-			if req == nil {
-				break // 无法恢复，关闭连接
-			}
-			req.h.Error = err.Error()
-			server.sendResponse(cc, req.h, invalidRequest, sending)
-			continue
+func (server *Server) serveCodec(cc codec.Codec, opt *Option, conn *connTracker) {
+	c := server.newCodecConn(cc, opt, conn)
+	for c.next() {
+	}
+	c.close()
+}
+
+// codecConn 是 serveCodec 服务的一个连接的状态
+type codecConn struct {
+	server  *Server
+	cc      codec.Codec
+	opt     *Option
+	conn    *connTracker
+	sending *sync.Mutex     // 确保发送完整的响应
+	wg      *sync.WaitGroup // 等待所有请求处理完成
+	tb      *TokenBucket    // 连接的请求速率限制，不限制时为 nil
+	chunk   int             // 返回值分块发送的阈值，客户端不支持或服务器未开启时为 0
+}
+
+func (server *Server) newCodecConn(cc codec.Codec, opt *Option, conn *connTracker) *codecConn {
+	c := &codecConn{server: server, cc: cc, opt: opt, conn: conn, sending: new(sync.Mutex), wg: new(sync.WaitGroup)}
+	if rate := server.currentConnRate(); rate.perSecond > 0 {
+		c.tb = NewTokenBucket(rate.burst, rate.perSecond, time.Second) // 创建令牌桶，每秒添加 perSecond 个令牌
+	}
+	if opt.Chunking {
+		c.chunk = int(atomic.LoadInt64(&server.chunkSize))
+	}
+	return c
+}
+
+// next 读取一个请求并开始处理，连接无法继续使用时返回 false
+func (c *codecConn) next() bool {
+	server, cc, sending := c.server, c.cc, c.sending
+	// 检查令牌桶中是否有足够的令牌
+	if c.tb != nil && !c.tb.Allow() {
+		server.log().Warn("rpc server: rate limit exceeded")
+		server.events.Publish(RequestRejectedRateLimit{Time: time.Now(), Remote: c.conn.remote})
+		// Send error response indicating rate limit exceeded
+		server.sendResponse(cc, &codec.Header{ServiceMethod: ""}, "rate limit exceeded", sending)
+		return true
+	}
+	req, err := server.readRequest(cc)
+	if req != nil {
+		atomic.AddUint64(&c.conn.requests, 1)
+	}
+	if err != nil {
+		if req == nil {
+			return false // 无法恢复，关闭连接
 		}
-		wg.Add(1)
-		go server.handleRequest(cc, req, sending, wg, opt.HandleTimeout)
+		req.h.Error = err.Error()
+		server.sendResponse(cc, req.h, invalidRequest, sending)
+		freeRequest(req)
+		return true
+	}
+	req.remote = c.conn.remote
+	req.token = req.h.Token
+	if req.token == "" {
+		req.token = c.opt.Credentials
 	}
-	wg.Wait()
-	_ = cc.Close()
+	if atomic.LoadInt32(&server.closing) != 0 {
+		err = errShuttingDown
+	} else {
+		err = server.authenticate(req, c.conn)
+	}
+	// 凭证已经保存在 req.token 中，响应的头部不再回显凭证，客户端也不必解码这个字段
+	req.h.Token = ""
+	if err == nil {
+		err = server.authorize(req)
+	}
+	if err == nil {
+		err = server.acquireQuota(req)
+	}
+	if err != nil {
+		req.h.Error = err.Error()
+		server.sendResponse(cc, req.h, invalidRequest, sending)
+		freeRequest(req)
+		return true
+	}
+	c.wg.Add(1)
+	atomic.AddInt64(&server.inflight, 1)
+	go server.handleRequest(c, req, server.handleTimeout(c.opt.HandleTimeout))
+	return true
+}
+
+// close 等待所有请求处理完成后关闭编解码器
+func (c *codecConn) close() {
+	c.wg.Wait()
+	_ = c.cc.Close()
 }
 
 // request 存储调用的所有信息
 type request struct {
-	h            *codec.Header // 请求的头部
+	h            *codec.Header // 请求的头部，serveCodec 读取的请求指向 header
+	header       codec.Header
+	refs         int32         // handleRequest 中仍在使用请求的协程数，降为 0 时放回 requestPool
 	argv, replyv reflect.Value // 请求的参数和返回值
 	mtype        *methodType
 	svc          *service
+	remote       string // 客户端地址
+	identity     string // 调用方身份，由认证机制填充
+	release      func() // 释放请求占用的配额，未设置配额时为 nil
+	token        string // 单次调用的凭证，没有时为握手时的凭证
+}
+
+// requestPool 缓存 serveCodec 读取的请求及其头部，减少每个请求的内存分配。
+// argv 和 replyv 会交给服务方法和拦截器，可能被它们保留，因此不放入池中
+var requestPool = sync.Pool{New: func() interface{} { return new(request) }}
+
+// newRequest 从 requestPool 中取出一个空的请求
+func newRequest() *request {
+	req := requestPool.Get().(*request)
+	req.h = &req.header
+	return req
+}
+
+// freeRequest 清空请求并放回 requestPool，调用方之后不能再访问 req
+func freeRequest(req *request) {
+	*req = request{}
+	requestPool.Put(req)
+}
+
+// unref 在 handleRequest 中使用请求的一个协程结束时调用，最后一个结束的协程将请求放回 requestPool
+func (req *request) unref() {
+	if atomic.AddInt32(&req.refs, -1) == 0 {
+		freeRequest(req)
+	}
 }
 
-// readRequestHeader 从编解码器中读取请求头部
-func (server *Server) readRequestHeader(cc codec.Codec) (*codec.Header, error) {
-	var h codec.Header
-	if err := cc.ReadHeader(&h); err != nil {
+// readRequestHeader 从编解码器中读取请求头部到 h
+func (server *Server) readRequestHeader(cc codec.Codec, h *codec.Header) error {
+	if err := cc.ReadHeader(h); err != nil {
 		if err != io.EOF && err != io.ErrUnexpectedEOF {
-			log.Println("rpc server: read header error:", err)
+			server.log().Error("rpc server: read header error", "err", err)
 		}
-		return nil, err
+		return err
 	}
-	return &h, nil
+	return nil
 }
 
 // findService 根据服务和方法名查找服务和方法类型
@@ -169,11 +601,12 @@ func (server *Server) findService(serviceMethod string) (svc *service, mtype *me
 	}
 	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
 	svci, ok := server.serviceMap.Load(serviceName)
-	if !ok {
+	if ok {
+		svc = svci.(*service)
+	} else if svc = server.builtinService(serviceName); svc == nil {
 		err = errors.New("rpc server: can't find service " + serviceName)
 		return
 	}
-	svc = svci.(*service)
 	mtype = svc.method[methodName]
 	if mtype == nil {
 		err = errors.New("rpc server: can't find method " + methodName)
@@ -183,13 +616,17 @@ func (server *Server) findService(serviceMethod string) (svc *service, mtype *me
 
 // readRequest 从编解码器中读取请求
 func (server *Server) readRequest(cc codec.Codec) (*request, error) {
-	h, err := server.readRequestHeader(cc)
+	req := newRequest()
+	h := req.h
+	err := server.readRequestHeader(cc, h)
 	if err != nil {
+		freeRequest(req)
 		return nil, err
 	}
-	req := &request{h: h}
 	req.svc, req.mtype, err = server.findService(h.ServiceMethod)
 	if err != nil {
+		// 跳过消息体，否则它会被当作下一个请求的消息头读取
+		_ = cc.ReadBody(nil)
 		return req, err
 	}
 	req.argv = req.mtype.newArgv()
@@ -201,34 +638,255 @@ func (server *Server) readRequest(cc codec.Codec) (*request, error) {
 		argvi = req.argv.Addr().Interface()
 	}
 	if err = cc.ReadBody(argvi); err != nil {
-		log.Println("rpc server: read body err:", err)
+		server.log().Error("rpc server: read body err", "err", err)
 		return req, err
 	}
+	if s, ok := cc.(codec.Sizer); ok {
+		size := s.ReadBodySize()
+		atomic.AddUint64(&req.mtype.requestBytes, uint64(size))
+		server.metrics.observeSizes(h.ServiceMethod, size, -1)
+	}
 	return req, nil
 }
 
-// sendResponse 将响应发送给客户端
-func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex) {
+// sendResponse 将响应发送给客户端，返回写入的消息体字节数，大小未知或写入失败时返回 -1
+func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex) int {
 	sending.Lock()
 	defer sending.Unlock()
 	if err := cc.Write(h, body); err != nil {
-		log.Println("rpc server: write response error:", err)
+		server.log().Error("rpc server: write response error", "err", err)
+		return -1
 	}
+	if s, ok := cc.(codec.Sizer); ok {
+		return s.WrittenBodySize()
+	}
+	return -1
 }
 
 // handleRequest 处理请求
-func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup, timeout time.Duration) {
-	defer wg.Done()
-	called := make(chan struct{})
-	sent := make(chan struct{})
+func (server *Server) handleRequest(c *codecConn, req *request, timeout time.Duration) {
+	cc, sending := c.cc, c.sending
+	// 超时后调用方法的协程仍在使用请求，两个协程都结束后才能放回 requestPool
+	atomic.StoreInt32(&req.refs, 2)
+	defer req.unref()
+	defer c.wg.Done()
+	defer atomic.AddInt64(&server.inflight, -1)
+	start := time.Now()
+	var callErr error
+	var errMsg string // 调用失败或超时时的错误信息
+	defer func() { server.finishRequest(req, start, errMsg) }()
+	ctx := WithRequestID(context.Background(), req.h.RequestID)
+	if req.identity != "" {
+		ctx = WithIdentity(ctx, req.identity)
+	}
+	// 处理结束（包括超时）后取消 ctx，声明了 context.Context 参数的方法可以据此提前退出
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 缓冲的 channel 使超时后才返回的协程不会阻塞，能够照常释放请求
+	called := make(chan struct{}, 1)
+	sent := make(chan struct{}, 1)
+	var state int32 // 0：处理中，1：方法已返回，由调用方法的协程发送响应，2：已超时，由本协程发送响应
 	go func() {
-		err := req.svc.call(req.mtype, req.argv, req.replyv)
+		defer req.unref()
+		err := server.invoke(ctx, req)
+		if req.release != nil {
+			req.release() // 超时后方法仍在执行，直到返回才释放并发配额
+		}
+		if !atomic.CompareAndSwapInt32(&state, 0, 1) {
+			return // 已经发送了超时的响应
+		}
+		callErr = err
 		called <- struct{}{}
+		defer func() { sent <- struct{}{} }()
+		if err == ErrDropResponse || err == ErrResetConn {
+			if err == ErrResetConn {
+				_ = cc.Close()
+			}
+			return
+		}
 		if err != nil {
 			req.h.Error = err.Error()
 			server.sendResponse(cc, req.h, invalidRequest, sending)
-			sent <- struct{}{}
 			return
 		}
-		server.sendResponse(cc, req.h, req.replyv.Interface(), sending)
-		sent <- struct{}{}
+		if size := c.sendReply(req); size >= 0 {
+			atomic.AddUint64(&req.mtype.replyBytes, uint64(size))
+			server.metrics.observeSizes(req.h.ServiceMethod, -1, size)
+		}
+	}()
+
+	if timeout == 0 {
+		<-called
+		if callErr != nil {
+			errMsg = callErr.Error()
+		}
+		<-sent
+		return
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		if atomic.CompareAndSwapInt32(&state, 0, 2) {
+			errMsg = fmt.Sprintf("rpc server: request handle timeout: expect within %s (request_id=%s)", timeout, req.h.RequestID)
+			req.h.Error = errMsg
+			server.sendResponse(cc, req.h, invalidRequest, sending)
+			server.events.Publish(CallTimedOut{
+				Time:          time.Now(),
+				Side:          "server",
+				ServiceMethod: req.h.ServiceMethod,
+				RequestID:     req.h.RequestID,
+				Remote:        req.remote,
+				Err:           errMsg,
+			})
+			return
+		}
+		// 方法恰好在超时时返回，照常等待它的响应发送完毕
+		<-called
+		if callErr != nil {
+			errMsg = callErr.Error()
+		}
+		<-sent
+	case <-called:
+		if callErr != nil {
+			errMsg = callErr.Error()
+		}
+		<-sent
+	}
+}
+
+// Accept 接受监听器上的连接，并为每个连接提供服务
+func (server *Server) Accept(lis net.Listener) {
+	server.acceptLoop(lis, func(conn net.Conn) { server.ServeConn(conn) })
+}
+
+// Accept 使用 DefaultServer 接受监听器上的连接
+func Accept(lis net.Listener) { DefaultServer.Accept(lis) }
+
+// Register 在服务器中发布满足以下条件的接收器的方法集合：
+// - 导出类型的导出方法
+// - 两个参数，均为导出类型（或内置类型）
+// - 第二个参数是指针
+// - 一个返回值，类型为 error
+func (server *Server) Register(rcvr interface{}) error {
+	return server.register(rcvr, "", nil)
+}
+
+// RegisterName 与 Register 相同，但使用 name 而不是接收器的类型名作为服务名
+func (server *Server) RegisterName(name string, rcvr interface{}) error {
+	return server.register(rcvr, name, nil)
+}
+
+// RegisterHandlers 与 RegisterName 相同，同时为 handlers 中的方法设置由代码生成器生成的适配器（参见 MethodHandler），
+// 这些方法被调用时不再经过 reflect.Value.Call，其余方法仍然通过反射调用。handlers 的键是方法名，
+// 必须是 rcvr 按 Register 的规则发布的方法，适配器的行为应与直接调用 rcvr 的方法相同
+func (server *Server) RegisterHandlers(name string, rcvr interface{}, handlers map[string]MethodHandler) error {
+	return server.register(rcvr, name, handlers)
+}
+
+func (server *Server) register(rcvr interface{}, name string, handlers map[string]MethodHandler) error {
+	s := newNamedService(rcvr, name)
+	for method, h := range handlers {
+		m := s.method[method]
+		if m == nil {
+			return errors.New("rpc: can't find method " + s.name + "." + method + " for handler")
+		}
+		m.handler = h
+	}
+	if _, dup := server.serviceMap.Load(s.name); dup {
+		return errors.New("rpc: service already defined: " + s.name)
+	}
+	if err := server.pluginsRegister(s.name, rcvr); err != nil {
+		return err
+	}
+	if _, dup := server.serviceMap.LoadOrStore(s.name, s); dup {
+		return errors.New("rpc: service already defined: " + s.name)
+	}
+	methods := make([]string, 0, len(s.method))
+	for name := range s.method {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	for _, name := range methods {
+		server.log().Info("rpc server: register", "method", s.name+"."+name)
+	}
+	return nil
+}
+
+// Register 在 DefaultServer 中发布接收器的方法
+func Register(rcvr interface{}) error { return DefaultServer.Register(rcvr) }
+
+const (
+	connected        = "200 Connected to Gee RPC"
+	defaultRPCPath   = "/_geeprc_"
+	defaultDebugPath = "/debug/geerpc"
+	defaultAdminPath = "/admin/geerpc"
+)
+
+// ServeHTTP 实现了 http.Handler 接口，用于响应 RPC 请求。开启 SetJSONRPC 后也接受 POST 的 JSON-RPC 请求，
+// WebSocket 升级请求在升级后的连接上提供 RPC 服务（参见 DialWebSocket）
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if isWebSocketUpgrade(req) {
+		if !server.permitConn(req.RemoteAddr) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		server.serveWebSocket(w, req)
+		return
+	}
+	if req.Method == http.MethodPost && server.jsonrpc {
+		if !server.permitConn(req.RemoteAddr) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		server.serveJSONRPCHTTP(w, req)
+		return
+	}
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	if !server.permitConn(req.RemoteAddr) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		server.log().Error("rpc hijacking", "remote", req.RemoteAddr, "err", err)
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+	server.ServeConn(conn)
+}
+
+// HandleHTTP 在 defaultRPCPath 上注册 RPC 消息的 HTTP 处理程序，
+// 并通过 HandleDebugHTTP 在 defaultDebugPath 下注册调试处理程序。
+// HTTP 健康检查接口需要单独调用 HandleHealthHTTP 挂载
+func (server *Server) HandleHTTP() {
+	http.Handle(defaultRPCPath, server)
+	server.HandleDebugHTTP(http.DefaultServeMux)
+}
+
+// HandleHTTP 是 DefaultServer 注册 HTTP 处理程序的便捷方法
+func HandleHTTP() {
+	DefaultServer.HandleHTTP()
+}
+
+// Inflight 返回服务器正在处理的请求数，可以通过 registry.HeartbeatOptions.Load 随心跳报告给注册中心
+func (server *Server) Inflight() int {
+	return int(atomic.LoadInt64(&server.inflight))
+}
+
+// Services 返回服务器上注册的所有服务名，按名称排序，
+// 可用于在注册中心登记服务器提供的服务
+func (server *Server) Services() []string {
+	var names []string
+	server.serviceMap.Range(func(name, _ interface{}) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
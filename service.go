@@ -1,18 +1,42 @@
 package geerpc
 
 import (
+	"context"
+	"errors"
 	"go/ast"
 	"log"
 	"reflect"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// latencyWindow 是计算方法耗时分位数时保留的最近调用数
+const latencyWindow = 256
+
 // methodType 存储RPC方法的信息
 type methodType struct {
+	// 以下计数器使用原子操作访问，放在首位以保证 64 位对齐
+	numCalls  uint64 // 方法被调用的次数
+	numErrors uint64 // 方法返回错误的次数
+	inflight  int64  // 正在执行的调用数
+
+	requestBytes uint64 // 累计读取的请求消息体字节数
+	replyBytes   uint64 // 累计写入的响应消息体字节数
+
 	method    reflect.Method // 方法的反射信息
 	ArgType   reflect.Type   // 参数类型
 	ReplyType reflect.Type   // 返回值类型
-	numCalls  uint64         // 方法被调用的次数
+
+	withContext bool          // 方法的第一个参数是否为 context.Context
+	handler     MethodHandler // 由代码生成器生成的适配器，不为 nil 时不经过反射调用方法
+
+	mu        sync.Mutex      // 保护以下字段
+	total     time.Duration   // 所有已完成调用的总耗时
+	completed uint64          // 已完成的调用数
+	latencies []time.Duration // 最近 latencyWindow 次调用的耗时，循环覆盖
+	next      int             // latencies 中下一个写入的位置
 }
 
 // NumCalls 返回方法被调用的次数
@@ -20,6 +44,63 @@ func (m *methodType) NumCalls() uint64 {
 	return atomic.LoadUint64(&m.numCalls)
 }
 
+// NumErrors 返回方法返回错误的次数
+func (m *methodType) NumErrors() uint64 {
+	return atomic.LoadUint64(&m.numErrors)
+}
+
+// Inflight 返回方法正在执行的调用数
+func (m *methodType) Inflight() int64 {
+	return atomic.LoadInt64(&m.inflight)
+}
+
+// RequestBytes 返回累计读取的请求消息体字节数
+func (m *methodType) RequestBytes() uint64 {
+	return atomic.LoadUint64(&m.requestBytes)
+}
+
+// ReplyBytes 返回累计写入的响应消息体字节数
+func (m *methodType) ReplyBytes() uint64 {
+	return atomic.LoadUint64(&m.replyBytes)
+}
+
+// observe 记录一次调用的耗时
+func (m *methodType) observe(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total += d
+	m.completed++
+	if len(m.latencies) < latencyWindow {
+		m.latencies = append(m.latencies, d)
+		return
+	}
+	m.latencies[m.next] = d
+	m.next = (m.next + 1) % latencyWindow
+}
+
+// MeanLatency 返回方法所有已完成调用的平均耗时
+func (m *methodType) MeanLatency() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.completed == 0 {
+		return 0
+	}
+	return m.total / time.Duration(m.completed)
+}
+
+// P95Latency 返回方法最近 latencyWindow 次调用耗时的 95 分位数
+func (m *methodType) P95Latency() time.Duration {
+	m.mu.Lock()
+	sorted := make([]time.Duration, len(m.latencies))
+	copy(sorted, m.latencies)
+	m.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
 // newArgv 创建并返回一个新的方法参数实例
 func (m *methodType) newArgv() reflect.Value {
 	var argv reflect.Value
@@ -55,9 +136,17 @@ type service struct {
 
 // newService 创建一个新的服务实例
 func newService(rcvr interface{}) *service {
+	return newNamedService(rcvr, "")
+}
+
+// newNamedService 创建一个名为 name 的服务实例，name 为空时使用接收器的类型名
+func newNamedService(rcvr interface{}, name string) *service {
 	s := new(service)
 	s.rcvr = reflect.ValueOf(rcvr)
-	s.name = reflect.Indirect(s.rcvr).Type().Name()
+	s.name = name
+	if s.name == "" {
+		s.name = reflect.Indirect(s.rcvr).Type().Name()
+	}
 	s.typ = reflect.TypeOf(rcvr)
 	if !ast.IsExported(s.name) {
 		log.Fatalf("rpc server: %s is not a valid service name", s.name)
@@ -70,36 +159,102 @@ func newService(rcvr interface{}) *service {
 func (s *service) registerMethods() {
 	s.method = make(map[string]*methodType)
 	for i := 0; i < s.typ.NumMethod(); i++ {
-		method := s.typ.Method(i)
-		mType := method.Type
-		if mType.NumIn() != 3 || mType.NumOut() != 1 {
+		m := newMethodType(s.typ.Method(i))
+		if m == nil {
 			continue
 		}
-		if mType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
-			continue
-		}
-		argType, replyType := mType.In(1), mType.In(2)
-		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
-			continue
-		}
-		s.method[method.Name] = &methodType{
-			method:    method,
-			ArgType:   argType,
-			ReplyType: replyType,
+		s.method[m.method.Name] = m
+	}
+}
+
+// newMethodType 检查方法是否可以作为 RPC 方法，不可以时返回 nil
+func newMethodType(method reflect.Method) *methodType {
+	mType := method.Type
+	// 方法的第一个参数可以是 context.Context，用于获取请求 ID 和感知超时
+	withContext := mType.NumIn() == 4 && mType.In(1) == typeOfContext
+	if (mType.NumIn() != 3 && !withContext) || mType.NumOut() != 1 {
+		return nil
+	}
+	if mType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		return nil
+	}
+	first := 1
+	if withContext {
+		first = 2
+	}
+	argType, replyType := mType.In(first), mType.In(first+1)
+	if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+		return nil
+	}
+	return &methodType{
+		method:      method,
+		ArgType:     argType,
+		ReplyType:   replyType,
+		withContext: withContext,
+	}
+}
+
+// MethodDesc 描述一个 RPC 方法的参数和返回值类型
+type MethodDesc struct {
+	ServiceMethod string       // 形如 "Service.Method"
+	ArgType       reflect.Type // 参数类型，可能是指针类型或值类型
+	ReplyType     reflect.Type // 返回值类型，总是指针类型
+}
+
+// DescribeService 返回 rcvr 注册为服务后提供的所有方法，按名称排序，但不会注册服务。
+// 网关等需要在服务端之外构造参数和返回值的组件可以用它获取类型
+func DescribeService(rcvr interface{}) ([]MethodDesc, error) {
+	typ := reflect.TypeOf(rcvr)
+	name := reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name()
+	if !ast.IsExported(name) {
+		return nil, errors.New("rpc: " + name + " is not a valid service name")
+	}
+	var methods []MethodDesc
+	for i := 0; i < typ.NumMethod(); i++ {
+		if m := newMethodType(typ.Method(i)); m != nil {
+			methods = append(methods, MethodDesc{ServiceMethod: name + "." + m.method.Name, ArgType: m.ArgType, ReplyType: m.ReplyType})
 		}
-		log.Printf("rpc server: register %s.%s\n", s.name, method.Name)
 	}
+	return methods, nil
 }
 
+// MethodHandler 是由代码生成器（参见 cmd/geerpc-gen）为一个方法生成的适配器，通过类型断言取出参数和返回值后
+// 直接调用服务的方法，省去 reflect.Value.Call 的开销。args 和 reply 是服务端按方法的参数和返回值类型创建并解码的实例，
+// 类型与 MethodDesc 中的 ArgType 和 ReplyType 相同。参见 Server.RegisterHandlers
+type MethodHandler func(ctx context.Context, args, reply interface{}) error
+
+// typeOfContext 是 context.Context 接口的反射类型
+var typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 // call 调用服务的方法
 func (s *service) call(m *methodType, argv, replyv reflect.Value) error {
+	return s.callContext(context.Background(), m, argv, replyv)
+}
+
+// callContext 调用服务的方法，方法声明了 context.Context 参数时传入 ctx
+func (s *service) callContext(ctx context.Context, m *methodType, argv, replyv reflect.Value) error {
 	atomic.AddUint64(&m.numCalls, 1)
-	f := m.method.Func
-	returnValues := f.Call([]reflect.Value{s.rcvr, argv, replyv})
-	if errInter := returnValues[0].Interface(); errInter != nil {
-		return errInter.(error)
+	atomic.AddInt64(&m.inflight, 1)
+	start := time.Now()
+	var err error
+	if m.handler != nil {
+		err = m.handler(ctx, argv.Interface(), replyv.Interface())
+	} else {
+		f := m.method.Func
+		in := []reflect.Value{s.rcvr, argv, replyv}
+		if m.withContext {
+			in = []reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv}
+		}
+		if errInter := f.Call(in)[0].Interface(); errInter != nil {
+			err = errInter.(error)
+		}
+	}
+	m.observe(time.Since(start))
+	atomic.AddInt64(&m.inflight, -1)
+	if err != nil {
+		atomic.AddUint64(&m.numErrors, 1)
 	}
-	return nil
+	return err
 }
 
 // isExportedOrBuiltinType 检查类型是否是导出的或内置的类型
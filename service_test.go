@@ -1,9 +1,14 @@
 package geerpc
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type Foo int
@@ -45,4 +50,68 @@ func TestMethodType_Call(t *testing.T) {
 	argv.Set(reflect.ValueOf(Args{Num1: 1, Num2: 3}))
 	err := s.call(mType, argv, replyv)
 	_assert(err == nil && *replyv.Interface().(*int) == 4 && mType.NumCalls() == 1, "failed to call Foo.Sum")
+	_assert(mType.NumErrors() == 0 && mType.Inflight() == 0, "wrong error or in-flight count")
+	_assert(mType.P95Latency() > 0 && mType.MeanLatency() > 0, "latency should be recorded")
+}
+
+func TestServer_RegisterHandlers(t *testing.T) {
+	var foo Foo
+	server := NewServer()
+	var handled int
+	err := server.RegisterHandlers("Foo", &foo, map[string]MethodHandler{
+		"Sum": func(ctx context.Context, args, reply interface{}) error {
+			handled++
+			return foo.Sum(args.(Args), reply.(*int))
+		},
+	})
+	_assert(err == nil, "failed to register handlers: %v", err)
+	svc, mType, err := server.findService("Foo.Sum")
+	_assert(err == nil, "failed to find Foo.Sum")
+	argv := mType.newArgv()
+	replyv := mType.newReplyv()
+	argv.Set(reflect.ValueOf(Args{Num1: 1, Num2: 3}))
+	err = svc.call(mType, argv, replyv)
+	_assert(err == nil && *replyv.Interface().(*int) == 4, "failed to call Foo.Sum through the handler")
+	_assert(handled == 1 && mType.NumCalls() == 1, "expect the handler to be used instead of reflection")
+
+	err = NewServer().RegisterHandlers("Foo", &foo, map[string]MethodHandler{"Missing": nil})
+	_assert(err != nil, "expect an error for a handler without a method")
+}
+
+func TestServeMsgpackRPC(t *testing.T) {
+	server := NewServer()
+	server.SetConnRateLimit(0, 0)
+	var foo Foo
+	_ = server.Register(&foo)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go server.AcceptMsgpackRPC(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	_assert(err == nil, "failed to dial")
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	sumMethod := append([]byte{0xa7}, "Foo.Sum"...)
+	roundTrip := func(req, want []byte) {
+		_, err := conn.Write(req)
+		_assert(err == nil, "failed to write request")
+		got := make([]byte, len(want))
+		_, err = io.ReadFull(conn, got)
+		_assert(err == nil && bytes.Equal(got, want), "expect response % x, got % x (%v)", want, got, err)
+	}
+
+	// [0, 1, "Foo.Sum", [3, 4]]：多个位置参数依次对应 Args 的字段
+	req := append(append([]byte{0x94, 0x00, 0x01}, sumMethod...), 0x92, 0x03, 0x04)
+	roundTrip(req, []byte{0x94, 0x01, 0x01, 0xc0, 0x07})
+
+	// [2, "Foo.Sum", [1, 2]] 是通知，没有响应；之后的 [0, 2, "Foo.Sum", [{"Num1": 1, "Num2": 2}]] 得到响应
+	notify := append(append([]byte{0x93, 0x02}, sumMethod...), 0x92, 0x01, 0x02)
+	req = append(append([]byte{0x94, 0x00, 0x02}, sumMethod...), 0x91, 0x82)
+	req = append(append(append(req, 0xa4), "Num1"...), 0x01)
+	req = append(append(append(req, 0xa4), "Num2"...), 0x02)
+	roundTrip(append(notify, req...), []byte{0x94, 0x01, 0x02, 0xc0, 0x03})
+
+	// [0, 3, "Foo.Nope", []]：错误以字符串返回
+	req = append(append([]byte{0x94, 0x00, 0x03, 0xa8}, "Foo.Nope"...), 0x90)
+	msg := "rpc server: can't find method Nope"
+	roundTrip(req, append(append([]byte{0x94, 0x01, 0x03, 0xd9, byte(len(msg))}, msg...), 0xc0))
 }
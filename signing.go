@@ -0,0 +1,231 @@
+package geerpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxSignedFrame 是签名帧负载的最大长度，更长的写入会被拆分为多个帧
+const maxSignedFrame = 64 * 1024
+
+// 签名帧的方向标记，参与 HMAC 计算，防止把一个方向的帧反射回另一个方向
+const (
+	signClient byte = 'c'
+	signServer byte = 's'
+)
+
+// ErrBadSignature 表示收到的数据签名校验失败，连接会被关闭
+var ErrBadSignature = errors.New("rpc: bad request signature")
+
+// ErrReplay 表示握手的随机数已经使用过，连接可能是被截获后重放的
+var ErrReplay = errors.New("rpc server: replayed signing nonce")
+
+// defaultReplayWindow 是默认允许的握手时间戳偏差，也是服务端记住随机数的时长
+const defaultReplayWindow = time.Minute * 5
+
+// sessionKey 根据签名密钥、握手时间戳和随机数派生出连接的会话密钥，
+// 使签名帧与一次握手绑定，截获的数据无法在新的连接上重放
+func sessionKey(key []byte, timestamp int64, nonce string) []byte {
+	h := hmac.New(sha256.New, key)
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(timestamp))
+	_, _ = h.Write([]byte("geerpc-session"))
+	_, _ = h.Write(ts[:])
+	_, _ = h.Write([]byte(nonce))
+	return h.Sum(nil)
+}
+
+// newSigningNonce 生成握手使用的随机数
+func newSigningNonce() string {
+	return newRequestID() + newRequestID()
+}
+
+// nonceCache 记录窗口期内使用过的握手随机数
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // 随机数 -> 过期时间
+}
+
+// use 登记一个随机数，窗口期内已经使用过时返回 ErrReplay，同时清理过期的记录
+func (c *nonceCache) use(nonce string, window time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.seen == nil {
+		c.seen = make(map[string]time.Time)
+	}
+	for n, expire := range c.seen {
+		if now.After(expire) {
+			delete(c.seen, n)
+		}
+	}
+	if _, ok := c.seen[nonce]; ok {
+		return ErrReplay
+	}
+	// 时间戳允许前后各偏差 window，随机数至少需要记住 2*window
+	c.seen[nonce] = now.Add(2 * window)
+	return nil
+}
+
+// SetReplayWindow 设置启用签名时允许的握手时间戳偏差，默认 5 分钟。
+// 时间戳超出偏差的握手被拒绝，偏差内使用过的随机数不能再次使用，应在开始服务之前调用
+func (server *Server) SetReplayWindow(d time.Duration) {
+	server.replayWindow = d
+}
+
+// SetSigningKeys 设置服务器校验请求签名使用的密钥。keys 根据客户端在握手时提供的 Option.SigningKeyID 返回 HMAC 密钥，
+// 返回 nil 表示密钥 ID 未知，连接会被拒绝。require 为 true 时拒绝没有提供密钥 ID 的连接。
+// 启用签名的连接上，双方写入的每一段数据都带有 HMAC-SHA256 和递增的序号，
+// 篡改、删除或重排数据都会导致连接被关闭。握手时客户端还会发送时间戳和随机数，
+// 签名使用由它们派生的会话密钥，服务端拒绝过期的时间戳和重复的随机数，防止截获的连接被重放。
+// 适用于无法使用完整 TLS 的传输，应在开始服务之前调用
+func (server *Server) SetSigningKeys(keys func(keyID string) []byte, require bool) {
+	server.signingKeys = keys
+	server.requireSigning = require
+}
+
+// signingKey 检查握手的时间戳，返回握手选项对应的会话密钥，未启用签名时返回 nil
+func (server *Server) signingKey(opt *Option) ([]byte, error) {
+	if opt.SigningKeyID == "" {
+		if server.requireSigning {
+			return nil, errors.New("rpc server: request signing required")
+		}
+		return nil, nil
+	}
+	if server.signingKeys == nil {
+		return nil, errors.New("rpc server: request signing not supported")
+	}
+	key := server.signingKeys(opt.SigningKeyID)
+	if key == nil {
+		return nil, errors.New("rpc server: unknown signing key " + opt.SigningKeyID)
+	}
+	window := server.window()
+	skew := time.Since(time.Unix(opt.SigningTimestamp, 0))
+	if skew > window || skew < -window {
+		return nil, errors.New("rpc server: signing timestamp out of window")
+	}
+	if len(opt.SigningNonce) < 16 || len(opt.SigningNonce) > 64 {
+		return nil, errors.New("rpc server: invalid signing nonce")
+	}
+	return sessionKey(key, opt.SigningTimestamp, opt.SigningNonce), nil
+}
+
+// checkNonce 在连接的第一个签名帧校验通过后登记握手的随机数。
+// 放在第一个帧之后而不是握手时，使得只有持有密钥的客户端才能占用随机数缓存
+func (server *Server) checkNonce(nonce string) func() error {
+	return func() error {
+		return server.nonces.use(nonce, server.window())
+	}
+}
+
+// window 返回允许的握手时间戳偏差
+func (server *Server) window() time.Duration {
+	if server.replayWindow <= 0 {
+		return defaultReplayWindow
+	}
+	return server.replayWindow
+}
+
+// signedConn 为连接上的数据添加和校验 HMAC 签名。
+// 每次写入被编码为一个帧：4 字节负载长度、32 字节 HMAC、负载，
+// HMAC 覆盖方向标记、8 字节帧序号和负载
+type signedConn struct {
+	io.ReadWriteCloser
+	key        []byte
+	readDir    byte // 读取的帧的方向
+	writeDir   byte // 写入的帧的方向
+	wmu        sync.Mutex
+	wseq, rseq uint64
+	pending    []byte // 当前帧中尚未被读取的负载
+	hdr        [4 + sha256.Size]byte
+	readBuf    []byte
+	onFirst    func() error // 不为 nil 时在第一个帧校验通过后调用，返回错误时关闭连接
+}
+
+// newSignedConn 创建一个 signedConn，dir 是本端写入的方向
+func newSignedConn(conn io.ReadWriteCloser, key []byte, dir byte) *signedConn {
+	c := &signedConn{ReadWriteCloser: conn, key: key, writeDir: dir, readDir: signServer}
+	if dir == signServer {
+		c.readDir = signClient
+	}
+	return c
+}
+
+// mac 计算一个帧的 HMAC
+func (c *signedConn) mac(dir byte, seq uint64, payload []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	var prefix [9]byte
+	prefix[0] = dir
+	binary.BigEndian.PutUint64(prefix[1:], seq)
+	_, _ = h.Write(prefix[:])
+	_, _ = h.Write(payload)
+	return h.Sum(nil)
+}
+
+func (c *signedConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxSignedFrame {
+			chunk = chunk[:maxSignedFrame]
+		}
+		frame := make([]byte, 4+sha256.Size+len(chunk))
+		binary.BigEndian.PutUint32(frame, uint32(len(chunk)))
+		copy(frame[4:], c.mac(c.writeDir, c.wseq, chunk))
+		copy(frame[4+sha256.Size:], chunk)
+		c.wseq++
+		if _, err := c.ReadWriteCloser.Write(frame); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *signedConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if err := c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readFrame 读取并校验下一个帧
+func (c *signedConn) readFrame() error {
+	if _, err := io.ReadFull(c.ReadWriteCloser, c.hdr[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(c.hdr[:4])
+	if n > maxSignedFrame {
+		return ErrBadSignature
+	}
+	if cap(c.readBuf) < int(n) {
+		c.readBuf = make([]byte, n)
+	}
+	payload := c.readBuf[:n]
+	if _, err := io.ReadFull(c.ReadWriteCloser, payload); err != nil {
+		return err
+	}
+	if !hmac.Equal(c.hdr[4:], c.mac(c.readDir, c.rseq, payload)) {
+		return ErrBadSignature
+	}
+	if c.rseq == 0 && c.onFirst != nil {
+		if err := c.onFirst(); err != nil {
+			return err
+		}
+	}
+	c.rseq++
+	c.pending = payload
+	return nil
+}
@@ -0,0 +1,172 @@
+package geerpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"geerpc/codec"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// bufferConn 是基于内存缓冲区的连接，用于检查写入的帧
+type bufferConn struct{ bytes.Buffer }
+
+func (*bufferConn) Close() error { return nil }
+
+// signedFrames 使用 key 以客户端方向写入 payloads，返回写入的每个帧
+func signedFrames(key []byte, payloads ...string) [][]byte {
+	var frames [][]byte
+	buf := &bufferConn{}
+	w := newSignedConn(buf, key, signClient)
+	for _, p := range payloads {
+		_, _ = w.Write([]byte(p))
+		frames = append(frames, append([]byte(nil), buf.Bytes()...))
+		buf.Reset()
+	}
+	return frames
+}
+
+// readSigned 以服务端方向读取 frames 中的所有数据
+func readSigned(key []byte, frames ...[]byte) ([]byte, error) {
+	buf := &bufferConn{}
+	for _, f := range frames {
+		buf.Write(f)
+	}
+	return ioutil.ReadAll(newSignedConn(buf, key, signServer))
+}
+
+func TestSignedConn(t *testing.T) {
+	key := []byte("session-key")
+	frames := signedFrames(key, "hello", "world")
+	if data, err := readSigned(key, frames...); err != nil || string(data) != "helloworld" {
+		t.Fatalf("expect helloworld, got %q, err %v", data, err)
+	}
+
+	tampered := append([]byte(nil), frames[0]...)
+	tampered[4+sha256.Size] ^= 1
+	if _, err := readSigned(key, tampered, frames[1]); err != ErrBadSignature {
+		t.Fatalf("expect ErrBadSignature for a tampered frame, got %v", err)
+	}
+	if _, err := readSigned(key, frames[1], frames[0]); err != ErrBadSignature {
+		t.Fatalf("expect ErrBadSignature for reordered frames, got %v", err)
+	}
+	if _, err := readSigned(key, frames[1]); err != ErrBadSignature {
+		t.Fatalf("expect ErrBadSignature for a dropped frame, got %v", err)
+	}
+	if _, err := readSigned([]byte("other-key"), frames...); err != ErrBadSignature {
+		t.Fatalf("expect ErrBadSignature for a different key, got %v", err)
+	}
+
+	// 客户端写入的帧不能被反射回客户端
+	buf := &bufferConn{}
+	buf.Write(frames[0])
+	if _, err := ioutil.ReadAll(newSignedConn(buf, key, signClient)); err != ErrBadSignature {
+		t.Fatalf("expect ErrBadSignature for a reflected frame, got %v", err)
+	}
+}
+
+func TestServer_SigningKeys(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetSigningKeys(func(keyID string) []byte {
+		if keyID == "k1" {
+			return []byte("secret")
+		}
+		return nil
+	}, true)
+
+	client, err := DialInProc(server, &Option{SigningKeyID: "k1", SigningKey: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var reply string
+	if err := client.Call(WithRequestID(context.Background(), "req-1"), "Bar.RequestID", 1, &reply); err != nil || reply != "req-1" {
+		t.Fatalf("expect a signed call to succeed, got %q, err %v", reply, err)
+	}
+
+	for _, opt := range []*Option{
+		{},
+		{SigningKeyID: "k1", SigningKey: []byte("wrong")},
+		{SigningKeyID: "k2", SigningKey: []byte("secret")},
+	} {
+		c, err := DialInProc(server, opt)
+		if err != nil {
+			continue
+		}
+		if err := c.Call(context.Background(), "Bar.RequestID", 1, &reply); err == nil {
+			t.Fatalf("expect the call with key %q to be rejected", opt.SigningKeyID)
+		}
+		_ = c.Close()
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	var c nonceCache
+	if err := c.use("nonce-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.use("nonce-1", time.Minute); err != ErrReplay {
+		t.Fatalf("expect ErrReplay for a reused nonce, got %v", err)
+	}
+	// 过期的随机数被清理，之后可以再次使用
+	if err := c.use("nonce-2", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.use("nonce-2", time.Minute); err != nil {
+		t.Fatalf("expect an expired nonce to be forgotten, got %v", err)
+	}
+}
+
+// recordConn 记录写入连接的所有数据
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.written.Write(p)
+	return c.Conn.Write(p)
+}
+
+func TestServer_SigningReplay(t *testing.T) {
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	server.SetSigningKeys(func(keyID string) []byte { return []byte("secret") }, true)
+	opt := &Option{MagicNumber: MagicNumber, CodecType: codec.GobType, SigningKeyID: "k1", SigningKey: []byte("secret")}
+
+	s, c := net.Pipe()
+	go server.ServeConn(s)
+	rc := &recordConn{Conn: c}
+	client, err := NewClient(rc, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := client.Call(context.Background(), "Bar.RequestID", 1, &reply); err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	// 在新的连接上原样重放截获的数据，服务端不作任何响应就关闭连接
+	s, c = net.Pipe()
+	go server.ServeConn(s)
+	go func() { _, _ = c.Write(rc.written.Bytes()) }()
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	if data, err := ioutil.ReadAll(c); len(data) != 0 || err != nil {
+		t.Fatalf("expect the replayed connection to be closed without a reply, got %d bytes, err %v", len(data), err)
+	}
+
+	// 时间戳超出窗口的握手被拒绝
+	stale := *opt
+	stale.SigningTimestamp = time.Now().Add(-time.Hour).Unix()
+	stale.SigningNonce = newSigningNonce()
+	if _, err := server.signingKey(&stale); err == nil {
+		t.Fatal("expect a handshake outside the replay window to be rejected")
+	}
+}
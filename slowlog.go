@@ -0,0 +1,60 @@
+package geerpc
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+// SetSlowCallThreshold 设置服务器的慢调用阈值，处理耗时不低于 d 的请求会输出一条 Warn 级别的日志，
+// 携带方法名、耗时、客户端地址以及请求和响应的大小。d 为 0（默认）时不记录慢调用
+func (server *Server) SetSlowCallThreshold(d time.Duration) {
+	server.slowCall = d
+}
+
+// logSlowRequest 在请求处理耗时超过阈值时输出慢调用日志，调用失败或超时时不统计数据大小
+func (server *Server) logSlowRequest(req *request, d time.Duration, errMsg string) {
+	if server.slowCall <= 0 || d < server.slowCall {
+		return
+	}
+	keyvals := []interface{}{"method", req.h.ServiceMethod, "seq", req.h.Seq, "request_id", req.h.RequestID, "remote", req.remote, "duration", d}
+	if errMsg == "" {
+		keyvals = append(keyvals, "request_bytes", payloadSize(req.argv.Interface()), "reply_bytes", payloadSize(req.replyv.Interface()))
+	} else {
+		keyvals = append(keyvals, "error", errMsg)
+	}
+	server.log().Warn("rpc server: slow call", keyvals...)
+}
+
+// logSlowCall 在调用耗时超过 Option.SlowCallThreshold 时输出慢调用日志
+func (client *Client) logSlowCall(call *Call) {
+	threshold := client.opt.SlowCallThreshold
+	d := time.Since(call.start)
+	if threshold <= 0 || d < threshold {
+		return
+	}
+	keyvals := []interface{}{"method", call.ServiceMethod, "seq", call.Seq, "request_id", call.RequestID, "remote", client.peer, "duration", d}
+	if call.Error == nil {
+		keyvals = append(keyvals, "request_bytes", payloadSize(call.Args), "reply_bytes", payloadSize(call.Reply))
+	} else {
+		keyvals = append(keyvals, "error", call.Error)
+	}
+	client.opt.log().Warn("rpc client: slow call", keyvals...)
+}
+
+// byteCounter 只统计写入的字节数
+type byteCounter int
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// payloadSize 返回 v 经 gob 编码后的字节数，作为数据大小的近似值，无法编码时返回 -1。
+// 只在记录慢调用时计算，不影响正常请求的性能
+func payloadSize(v interface{}) int {
+	var c byteCounter
+	if err := gob.NewEncoder(&c).Encode(v); err != nil {
+		return -1
+	}
+	return int(c)
+}
@@ -0,0 +1,83 @@
+package geerpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Sleepy 的方法按参数休眠指定的毫秒数，用于测试慢调用
+type Sleepy int
+
+func (s Sleepy) Sleep(ms int, reply *[]byte) error {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	*reply = make([]byte, 100)
+	return nil
+}
+
+// waitLog 等待 l 收到消息为 msg 的日志
+func waitLog(t *testing.T, l *recordLogger, msg string) logEntry {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if e, ok := l.find(msg); ok {
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect a log %q", msg)
+	return logEntry{}
+}
+
+// keyval 返回日志中 key 对应的值
+func (e logEntry) keyval(key string) interface{} {
+	for i := 0; i+1 < len(e.keyvals); i += 2 {
+		if e.keyvals[i] == key {
+			return e.keyvals[i+1]
+		}
+	}
+	return nil
+}
+
+func TestSlowCallLogging(t *testing.T) {
+	serverLog, clientLog := &recordLogger{}, &recordLogger{}
+	server := NewServer()
+	server.SetLogger(serverLog)
+	server.SetSlowCallThreshold(50 * time.Millisecond)
+	var s Sleepy
+	_ = server.Register(&s)
+	client, err := DialInProc(server, &Option{Logger: clientLog, SlowCallThreshold: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var reply []byte
+	if err := client.Call(context.Background(), "Sleepy.Sleep", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(context.Background(), "Sleepy.Sleep", 60, &reply); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []logEntry{waitLog(t, serverLog, "rpc server: slow call"), waitLog(t, clientLog, "rpc client: slow call")} {
+		if e.level != LevelWarn || e.keyval("method") != "Sleepy.Sleep" || e.keyval("duration").(time.Duration) < 50*time.Millisecond {
+			t.Fatalf("unexpected slow call log %+v", e)
+		}
+		if n, _ := e.keyval("reply_bytes").(int); n < 100 {
+			t.Fatalf("expect the reply size in the slow call log, got %+v", e)
+		}
+	}
+	// 只有超过阈值的调用被记录
+	for _, l := range []*recordLogger{serverLog, clientLog} {
+		n := 0
+		l.mu.Lock()
+		for _, e := range l.entries {
+			if e.level == LevelWarn {
+				n++
+			}
+		}
+		l.mu.Unlock()
+		if n != 1 {
+			t.Fatalf("expect exactly one slow call log, got %d", n)
+		}
+	}
+}
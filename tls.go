@@ -0,0 +1,83 @@
+package geerpc
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	// alpnProtocol 是 GeeRPC 在 TLS 握手时通过 ALPN 协商的协议名，
+	// 保证 TLS 客户端不会误连到其他 TLS 服务（例如 HTTPS），反之亦然
+	alpnProtocol = "geerpc"
+	// alpnHTTP 是 DialHTTP 通过 TLS 连接 HTTP 服务器时协商的协议名
+	alpnHTTP = "http/1.1"
+	// tlsHandshakeTimeout 是服务端完成 TLS 握手的时间限制
+	tlsHandshakeTimeout = time.Second * 10
+)
+
+// clientTLS 在 conn 上完成客户端的 TLS 握手，并检查协商的 ALPN 协议是否为 proto。
+// cfg 未设置 ServerName 时使用 address 中的主机名
+func clientTLS(conn net.Conn, cfg *tls.Config, address, proto string) (net.Conn, error) {
+	r, reloading := reloadingClients.Load(cfg)
+	cfg = cfg.Clone()
+	if reloading {
+		cfg.RootCAs = r.(*CertReloader).pool()
+	}
+	cfg.NextProtos = []string{proto}
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			cfg.ServerName = host
+		} else {
+			cfg.ServerName = address
+		}
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	if got := tlsConn.ConnectionState().NegotiatedProtocol; got != proto {
+		return nil, errors.New("rpc client: tls: server did not negotiate protocol " + proto + ", got " + got)
+	}
+	return tlsConn, nil
+}
+
+// AcceptTLS 与 Accept 相同，但在 TLS 上为连接提供服务。cfg 必须包含服务端证书（需要热更新证书时使用 CertReloader.ServerConfig），
+// ALPN 协议会被设置为 GeeRPC 专用的协议名，未协商该协议的连接（包括明文连接）会被拒绝
+func (server *Server) AcceptTLS(lis net.Listener, cfg *tls.Config) {
+	cfg = cfg.Clone()
+	cfg.NextProtos = []string{alpnProtocol}
+	if get := cfg.GetConfigForClient; get != nil {
+		// 为每次握手动态生成的配置（例如 CertReloader 的 mTLS 配置）同样需要协商 GeeRPC 的协议名
+		cfg.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			c, err := get(hello)
+			if c != nil {
+				c = c.Clone()
+				c.NextProtos = []string{alpnProtocol}
+			}
+			return c, err
+		}
+	}
+	server.acceptLoop(lis, func(conn net.Conn) { server.serveTLS(tls.Server(conn, cfg)) })
+}
+
+// AcceptTLS 是 DefaultServer 在 TLS 上接受连接的便捷方法
+func AcceptTLS(lis net.Listener, cfg *tls.Config) { DefaultServer.AcceptTLS(lis, cfg) }
+
+// serveTLS 完成服务端的 TLS 握手并检查 ALPN 协议，然后为连接提供服务
+func (server *Server) serveTLS(conn *tls.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	err := conn.Handshake()
+	if err == nil && conn.ConnectionState().NegotiatedProtocol != alpnProtocol {
+		err = errors.New("rpc server: tls: client did not negotiate protocol " + alpnProtocol)
+	}
+	if err != nil {
+		server.log().Warn("rpc server: tls handshake failed", "remote", conn.RemoteAddr().String(), "err", err)
+		server.events.Publish(HandshakeFailed{Time: time.Now(), Remote: conn.RemoteAddr().String(), Err: err})
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	server.ServeConn(conn)
+}
@@ -0,0 +1,206 @@
+package geerpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// CertReloader 从磁盘加载 TLS 证书、私钥和 CA 证书，并在文件变化或收到信号时重新加载，
+// 适用于内部 CA 签发的短期证书：证书更新后无需重启进程，已经建立的连接不受影响，新的握手使用新的证书
+type CertReloader struct {
+	certFile, keyFile, caFile string
+
+	mu      sync.RWMutex // 保护以下字段
+	cert    *tls.Certificate
+	roots   *x509.CertPool // caFile 为空时为 nil
+	modTime time.Time      // 已加载的文件中最新的修改时间
+}
+
+// NewCertReloader 创建一个 CertReloader 并立即加载一次文件。
+// certFile 和 keyFile 是 PEM 格式的证书和私钥，客户端不使用客户端证书时可以为空；
+// caFile 是 PEM 格式的 CA 证书，服务端用于校验客户端证书，客户端用于校验服务端证书，为空时不设置
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新加载所有文件，任一文件无效时保留原有的证书并返回错误
+func (r *CertReloader) Reload() error {
+	var modTime time.Time
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	var cert *tls.Certificate
+	if r.certFile != "" || r.keyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+	var roots *x509.CertPool
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return errors.New("rpc tls: no certificates found in " + r.caFile)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.roots, r.modTime = cert, roots, modTime
+	return nil
+}
+
+// reloadIfChanged 在任一文件的修改时间晚于上次加载时重新加载
+func (r *CertReloader) reloadIfChanged() error {
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		r.mu.RLock()
+		changed := info.ModTime().After(r.modTime)
+		r.mu.RUnlock()
+		if changed {
+			return r.Reload()
+		}
+	}
+	return nil
+}
+
+// WatchFiles 每隔 interval（0 表示默认的 10 秒）检查一次文件，文件变化后重新加载，
+// 返回的 stop 函数用于停止检查
+func (r *CertReloader) WatchFiles(interval time.Duration) (stop func()) {
+	if interval == 0 {
+		interval = time.Second * 10
+	}
+	t := time.NewTicker(interval)
+	return r.watch(func(done <-chan struct{}) bool {
+		select {
+		case <-done:
+			return false
+		case <-t.C:
+			return true
+		}
+	}, r.reloadIfChanged, t.Stop)
+}
+
+// ReloadOnSignal 在进程收到 sigs（默认为 SIGHUP）时重新加载文件，返回的 stop 函数用于停止监听
+func (r *CertReloader) ReloadOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{reloadSignal}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	return r.watch(func(done <-chan struct{}) bool {
+		select {
+		case <-done:
+			return false
+		case <-ch:
+			return true
+		}
+	}, r.Reload, func() { signal.Stop(ch) })
+}
+
+// watch 在 next 每次返回 true 时调用 reload，直到 stop 被调用，退出时调用 cleanup
+func (r *CertReloader) watch(next func(done <-chan struct{}) bool, reload func() error, cleanup func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		defer cleanup()
+		for next(done) {
+			if err := reload(); err != nil {
+				DefaultLogger().Error("rpc tls: reload certificates err", "cert", r.certFile, "err", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// certificate 返回当前的证书
+func (r *CertReloader) certificate() (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, errors.New("rpc tls: no certificate configured")
+	}
+	return r.cert, nil
+}
+
+// pool 返回当前的 CA 证书
+func (r *CertReloader) pool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roots
+}
+
+// ServerConfig 返回一个在每次握手时使用当前证书的服务端配置，可以传给 AcceptTLS 或 tls.Listen。
+// 设置了 caFile 时，配置要求并使用当前的 CA 证书校验客户端证书（mTLS）。base 可以为 nil
+func (r *CertReloader) ServerConfig(base *tls.Config) *tls.Config {
+	if base == nil {
+		base = &tls.Config{}
+	}
+	cfg := base.Clone()
+	cfg.Certificates = nil
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return r.certificate() }
+	if r.caFile != "" {
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		// ClientCAs 不能在握手时动态获取，为每次握手生成一份使用当前 CA 证书的配置
+		tmpl := cfg.Clone()
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := tmpl.Clone()
+			c.ClientCAs = r.pool()
+			return c, nil
+		}
+	}
+	return cfg
+}
+
+// reloadingClients 记录 ClientConfig 返回的配置对应的 CertReloader，
+// 客户端在每次 TLS 握手时从中获取当前的 CA 证书
+var reloadingClients sync.Map // *tls.Config -> *CertReloader
+
+// ClientConfig 返回一个使用当前证书的客户端配置，可以设置为 Option.TLSConfig。
+// 客户端证书在每次握手时获取；设置了 caFile 时，Dial 在每次握手时使用当前的 CA 证书校验服务端证书。base 可以为 nil
+func (r *CertReloader) ClientConfig(base *tls.Config) *tls.Config {
+	if base == nil {
+		base = &tls.Config{}
+	}
+	cfg := base.Clone()
+	if r.certFile != "" {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return r.certificate() }
+	}
+	if r.caFile != "" {
+		cfg.RootCAs = r.pool()
+		reloadingClients.Store(cfg, r)
+	}
+	return cfg
+}
@@ -0,0 +1,6 @@
+package geerpc
+
+import "os"
+
+// reloadSignal 是 CertReloader.ReloadOnSignal 默认监听的信号，js/wasm 没有 SIGHUP，使用 os.Interrupt 代替
+var reloadSignal = os.Interrupt
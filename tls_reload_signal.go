@@ -0,0 +1,9 @@
+//go:build !js
+// +build !js
+
+package geerpc
+
+import "syscall"
+
+// reloadSignal 是 CertReloader.ReloadOnSignal 默认监听的信号
+var reloadSignal = syscall.SIGHUP
@@ -0,0 +1,116 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned 生成一个用于 127.0.0.1 的自签名证书，同时作为 CA 证书，
+// 写入 certFile 和 keyFile，并将文件的修改时间设置为 modTime
+func writeSelfSigned(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "geerpc-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	_ = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	_ = os.Chtimes(certFile, modTime, modTime)
+	_ = os.Chtimes(keyFile, modTime, modTime)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Minute)
+	writeSelfSigned(t, certFile, keyFile, start)
+
+	// 服务端使用 certFile 作为证书，客户端使用同一个文件作为 CA 证书
+	serverCerts, err := NewCertReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCerts, err := NewCertReloader("", "", certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	var b Bar
+	_ = server.Register(&b)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.AcceptTLS(l, serverCerts.ServerConfig(nil))
+
+	opt := &Option{TLSConfig: clientCerts.ClientConfig(nil), ConnectTimeout: time.Second}
+	call := func() error {
+		client, err := Dial("tcp", l.Addr().String(), opt)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		var reply string
+		return client.Call(context.Background(), "Bar.RequestID", 1, &reply)
+	}
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 证书轮换后，服务端重新加载即使用新证书，客户端在重新加载 CA 证书之前无法校验它
+	writeSelfSigned(t, certFile, keyFile, start.Add(time.Second))
+	if err := serverCerts.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(); err == nil {
+		t.Fatal("expect the rotated certificate not to be trusted by the old CA")
+	}
+	if err := clientCerts.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(); err != nil {
+		t.Fatalf("expect calls to succeed after both sides reload, got %v", err)
+	}
+
+	// 无效的文件不会替换已加载的证书
+	_ = ioutil.WriteFile(certFile, []byte("not a certificate"), 0600)
+	_ = os.Chtimes(certFile, start.Add(2*time.Second), start.Add(2*time.Second))
+	if err := serverCerts.reloadIfChanged(); err == nil {
+		t.Fatal("expect an error for an invalid certificate file")
+	}
+	if err := call(); err != nil {
+		t.Fatalf("expect the previous certificate to stay in use, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package geerpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSignedTLS 返回使用自签名证书的服务端配置，以及信任该证书的 CA 证书池
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	dir, err := ioutil.TempDir("", "geerpc-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSigned(t, certFile, keyFile, time.Now())
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, _ := ioutil.ReadFile(certFile)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pem)
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, pool
+}
+
+// startTLSServer 在 TLS 上启动一个注册了 Foo 的服务器，返回服务器和监听器
+func startTLSServer(t *testing.T, cfg *tls.Config) (*Server, net.Listener) {
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.AcceptTLS(l, cfg)
+	return server, l
+}
+
+func TestServer_AcceptTLS(t *testing.T) {
+	serverCfg, pool := selfSignedTLS(t)
+	_, l := startTLSServer(t, serverCfg)
+	defer l.Close()
+
+	clientCfg := &tls.Config{RootCAs: pool}
+	client, err := Dial("tcp", l.Addr().String(), &Option{TLSConfig: clientCfg, ConnectTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 over TLS, got %d, %v", reply, err)
+	}
+	// 调用方和服务端传入的配置不应被修改
+	if clientCfg.NextProtos != nil || clientCfg.ServerName != "" || serverCfg.NextProtos != nil {
+		t.Fatal("expect the caller's tls.Config to be left untouched")
+	}
+
+	// 不信任服务端证书时握手失败
+	if _, err := Dial("tcp", l.Addr().String(), &Option{TLSConfig: &tls.Config{}, ConnectTimeout: time.Second}); err == nil {
+		t.Fatal("expect an error dialing with an untrusted certificate")
+	}
+}
+
+func TestServer_AcceptTLSRejectsOtherProtocols(t *testing.T) {
+	serverCfg, pool := selfSignedTLS(t)
+	server, l := startTLSServer(t, serverCfg)
+	defer l.Close()
+	failed := make(chan error, 4)
+	bus := NewEventBus()
+	bus.Subscribe(func(e Event) {
+		if e, ok := e.(HandshakeFailed); ok {
+			failed <- e.Err
+		}
+	})
+	server.SetEventBus(bus)
+
+	// 明文客户端无法完成握手
+	client, err := Dial("tcp", l.Addr().String(), &Option{ConnectTimeout: time.Second})
+	if err == nil {
+		var reply int
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = client.Call(ctx, "Foo.Sum", Args{Num1: 1, Num2: 2}, &reply)
+		cancel()
+		_ = client.Close()
+	}
+	if err == nil {
+		t.Fatal("expect a plaintext client to be rejected")
+	}
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("expect HandshakeFailed for the plaintext client")
+	}
+
+	// 协商了其他 ALPN 协议的 TLS 客户端（例如 HTTPS 客户端）被拒绝
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "127.0.0.1", NextProtos: []string{"http/1.1"}})
+	if err == nil {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		_ = conn.Close()
+	}
+	if err == nil {
+		t.Fatal("expect a TLS client without the geerpc protocol to be rejected")
+	}
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("expect HandshakeFailed for the TLS client without the geerpc protocol")
+	}
+}
+
+func TestDial_TLSRequiresGeeRPCProtocol(t *testing.T) {
+	// HTTPS 服务器只协商 http/1.1，Dial 不应把它当作 GeeRPC 服务器
+	ts := httptest.NewUnstartedServer(NewServer())
+	ts.StartTLS()
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	addr := ts.Listener.Addr().String()
+	opt := &Option{TLSConfig: &tls.Config{RootCAs: pool}, ConnectTimeout: time.Second}
+	if _, err := Dial("tcp", addr, opt); err == nil {
+		t.Fatal("expect Dial to reject a server that does not negotiate geerpc")
+	}
+
+	// DialHTTP 通过 TLS 连接 HTTP RPC 服务器时协商 http/1.1
+	server := NewServer()
+	var foo Foo
+	_ = server.Register(&foo)
+	ts2 := httptest.NewUnstartedServer(server)
+	ts2.StartTLS()
+	defer ts2.Close()
+	pool.AddCert(ts2.Certificate())
+	client, err := DialHTTP("tcp", ts2.Listener.Addr().String(), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	if err := client.Call(context.Background(), "Foo.Sum", Args{Num1: 2, Num2: 3}, &reply); err != nil || reply != 5 {
+		t.Fatalf("expect 5 over HTTPS, got %d, %v", reply, err)
+	}
+}
@@ -0,0 +1,276 @@
+package geerpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket 传输：ServeHTTP 收到 WebSocket 升级请求时，在升级后的连接上提供与 TCP 相同的 geerpc 协议，
+// 连接上的所有消息（文本或二进制）的负载依次拼接为字节流，因此选项握手、编解码、签名和加密都与 TCP 相同。
+// 浏览器中的客户端（参见 cmd/geerpc-wasm）无法发起 CONNECT 请求，通过这种方式调用服务，
+// 通常与 JSON 编解码器（codec.JsonType）一起使用。只实现了 RFC 6455 中 geerpc 需要的部分：不支持扩展和子协议
+
+// webSocketGUID 是 RFC 6455 规定的用于计算 Sec-WebSocket-Accept 的常量
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket 帧的操作码
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+var errWebSocketFrame = errors.New("rpc websocket: invalid frame")
+
+// SetWebSocketOrigins 设置允许发起 WebSocket 连接的页面来源（Origin 头），例如 "http://localhost:3000"，"*" 允许任意来源。
+// 默认只允许与请求的 Host 相同的来源和没有 Origin 头的非浏览器客户端，防止任意网页借助访问者的浏览器调用内网服务
+func (server *Server) SetWebSocketOrigins(origins ...string) {
+	server.wsOrigins = origins
+}
+
+// checkWebSocketOrigin 检查 WebSocket 升级请求的来源是否被允许
+func (server *Server) checkWebSocketOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range server.wsOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// isWebSocketUpgrade 判断 req 是否是 WebSocket 升级请求
+func isWebSocketUpgrade(req *http.Request) bool {
+	return headerContains(req.Header, "Upgrade", "websocket")
+}
+
+// headerContains 判断以逗号分隔的头部 name 中是否包含 token，不区分大小写
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketAccept 返回 key 对应的 Sec-WebSocket-Accept
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// serveWebSocket 完成 WebSocket 握手，然后在升级后的连接上提供服务
+func (server *Server) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Method != http.MethodGet || !headerContains(req.Header, "Connection", "upgrade") ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "400 bad websocket handshake", http.StatusBadRequest)
+		return
+	}
+	if !server.checkWebSocketOrigin(req) {
+		server.log().Warn("rpc server: websocket origin rejected", "remote", req.RemoteAddr, "origin", req.Header.Get("Origin"))
+		http.Error(w, "403 origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		server.log().Error("rpc hijacking", "remote", req.RemoteAddr, "err", err)
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+webSocketAccept(key)+"\r\n\r\n")
+	server.ServeConn(newWebSocketConn(conn, brw.Reader, false))
+}
+
+// webSocketURL 解析 WebSocket 地址，路径为空时使用 HandleHTTP 注册的默认路径
+func webSocketURL(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, errors.New("rpc client: websocket url must start with ws:// or wss://")
+	}
+	if u.Path == "" {
+		u.Path = defaultRPCPath
+	}
+	return u, nil
+}
+
+// webSocketConn 将 WebSocket 连接适配为字节流：Read 依次返回收到的数据帧的负载，
+// 并在读取时回应 Ping、处理 Close；每次 Write 发送一个二进制帧
+type webSocketConn struct {
+	net.Conn
+	r      *bufio.Reader
+	client bool // 客户端发送的帧需要掩码
+
+	// 以下字段只在 Read 中访问
+	remaining int64   // 当前数据帧尚未读取的负载字节数
+	masked    bool    // 当前数据帧是否带有掩码
+	mask      [4]byte // 当前数据帧的掩码
+	maskPos   int     // 下一个负载字节在掩码中的位置
+
+	wmu       sync.Mutex // 保证帧的完整写入
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(conn net.Conn, r *bufio.Reader, client bool) *webSocketConn {
+	return &webSocketConn{Conn: conn, r: r, client: client}
+}
+
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			p[i] ^= c.mask[c.maskPos&3]
+			c.maskPos++
+		}
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame 读取下一个帧的头部，控制帧在这里处理完毕，数据帧的负载留给 Read
+func (c *webSocketConn) nextFrame() error {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return err
+	}
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(b[:]))
+		if length < 0 {
+			return errWebSocketFrame
+		}
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return err
+		}
+	}
+	switch opcode {
+	case wsContinuation, wsText, wsBinary:
+		c.remaining, c.masked, c.mask, c.maskPos = length, masked, mask, 0
+		return nil
+	case wsClose, wsPing, wsPong:
+		if length > 125 {
+			return errWebSocketFrame
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i&3]
+			}
+		}
+		switch opcode {
+		case wsClose:
+			_ = c.Close()
+			return io.EOF
+		case wsPing:
+			return c.writeFrame(wsPong, payload)
+		}
+		return nil
+	}
+	return errWebSocketFrame
+}
+
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame 发送一个完整的帧
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(n))
+		frame = append(frame, b[:]...)
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i&3])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Close 发送 Close 帧（状态码 1000，正常关闭）后关闭底层连接，对端不读取数据时最多等待一秒
+func (c *webSocketConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeFrame(wsClose, []byte{0x03, 0xe8})
+		err = c.Conn.Close()
+	})
+	return err
+}
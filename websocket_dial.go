@@ -0,0 +1,71 @@
+//go:build !js
+// +build !js
+
+package geerpc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// DialWebSocket 通过 WebSocket 连接到 HTTP RPC 服务器（Server.ServeHTTP），rawurl 形如 ws://10.0.0.1:7001/_geeprc_，
+// 路径为空时使用 HandleHTTP 注册的默认路径。wss 使用 TLS，Option.TLSConfig 为 nil 时使用默认配置，ws 忽略 Option.TLSConfig。
+// 在 js/wasm 下使用浏览器的 WebSocket，参见 cmd/geerpc-wasm
+func DialWebSocket(rawurl string, opts ...*Option) (*Client, error) {
+	u, err := webSocketURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	wsOpt := *opt
+	port := "80"
+	if u.Scheme == "wss" {
+		port = "443"
+		if wsOpt.TLSConfig == nil {
+			wsOpt.TLSConfig = &tls.Config{}
+		}
+	} else {
+		wsOpt.TLSConfig = nil
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), port)
+	}
+	return dialTimeoutALPN(func(conn net.Conn, opt *Option) (*Client, error) {
+		return newWebSocketClient(conn, u, opt)
+	}, alpnHTTP, "tcp", address, &wsOpt)
+}
+
+// newWebSocketClient 在 conn 上完成 WebSocket 握手并创建客户端
+func newWebSocketClient(conn net.Conn, u *url.URL, opt *Option) (*Client, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(b[:])
+	_, err := fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, errors.New("rpc client: websocket handshake failed: " + resp.Status)
+	}
+	return NewClient(newWebSocketConn(conn, r, true), opt)
+}
@@ -0,0 +1,199 @@
+package geerpc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// DialWebSocket 通过浏览器的 WebSocket 连接到 HTTP RPC 服务器（Server.ServeHTTP），rawurl 形如 ws://10.0.0.1:7001/_geeprc_，
+// 路径为空时使用 HandleHTTP 注册的默认路径。TLS 由浏览器处理，Option.TLSConfig 被忽略；
+// 浏览器会发送页面的 Origin，服务端需要通过 SetWebSocketOrigins 允许跨域的页面
+func DialWebSocket(rawurl string, opts ...*Option) (*Client, error) {
+	u, err := webSocketURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	opt, err := parseOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialBrowserWebSocket(u.String(), opt.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(conn, opt)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// webSocketAddr 是浏览器 WebSocket 连接的地址
+type webSocketAddr string
+
+func (a webSocketAddr) Network() string { return "websocket" }
+func (a webSocketAddr) String() string  { return string(a) }
+
+// browserConn 将浏览器的 WebSocket 对象适配为 net.Conn：收到的消息依次拼接为字节流，每次 Write 发送一个二进制消息。
+// 浏览器的 WebSocket 不支持截止时间，SetDeadline 等方法不做任何事，调用的超时由 ctx 和 HandleTimeout 控制
+type browserConn struct {
+	ws    js.Value
+	addr  webSocketAddr
+	funcs []js.Func
+
+	mu     sync.Mutex // 保护以下字段
+	buf    []byte     // 已收到、尚未读取的数据
+	err    error      // 连接关闭后 Read 和 Write 返回的错误
+	notify chan struct{}
+
+	closeOnce sync.Once
+}
+
+// dialBrowserWebSocket 创建浏览器的 WebSocket 并等待连接建立，timeout 为 0 表示不限制
+func dialBrowserWebSocket(url string, timeout time.Duration) (*browserConn, error) {
+	ws := js.Global().Get("WebSocket")
+	if ws.IsUndefined() {
+		return nil, errors.New("rpc client: WebSocket is not supported in this environment")
+	}
+	c := &browserConn{ws: ws.New(url), addr: webSocketAddr(url), notify: make(chan struct{}, 1)}
+	c.ws.Set("binaryType", "arraybuffer")
+	opened := make(chan error, 1)
+	c.on("open", func(js.Value) {
+		select {
+		case opened <- nil:
+		default:
+		}
+	})
+	c.on("message", func(event js.Value) {
+		data := event.Get("data")
+		var b []byte
+		if data.Type() == js.TypeString {
+			b = []byte(data.String())
+		} else {
+			arr := js.Global().Get("Uint8Array").New(data)
+			b = make([]byte, arr.Get("length").Int())
+			js.CopyBytesToGo(b, arr)
+		}
+		c.mu.Lock()
+		c.buf = append(c.buf, b...)
+		c.mu.Unlock()
+		c.wake()
+	})
+	// 浏览器在连接失败时先后触发 error 和 close，有的实现只触发 error，因此两者都视为连接关闭
+	fail := func(err error) {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = io.EOF
+		}
+		c.mu.Unlock()
+		c.wake()
+		select {
+		case opened <- err:
+		default:
+		}
+	}
+	c.on("error", func(js.Value) {
+		fail(errors.New("rpc client: websocket error"))
+	})
+	c.on("close", func(event js.Value) {
+		fail(errors.New("rpc client: websocket closed with code " + strconv.Itoa(event.Get("code").Int())))
+	})
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case err := <-opened:
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		return c, nil
+	case <-expired:
+		_ = c.Close()
+		return nil, errors.New("rpc client: connect timeout: expect within " + timeout.String())
+	}
+}
+
+// on 注册 WebSocket 的事件处理函数，事件处理函数在浏览器的事件循环中执行，不能阻塞
+func (c *browserConn) on(event string, f func(event js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		f(args[0])
+		return nil
+	})
+	c.funcs = append(c.funcs, fn)
+	c.ws.Set("on"+event, fn)
+}
+
+// wake 唤醒等待数据的 Read
+func (c *browserConn) wake() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *browserConn) Read(p []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		if len(c.buf) > 0 {
+			n := copy(p, c.buf)
+			c.buf = c.buf[n:]
+			c.mu.Unlock()
+			return n, nil
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		<-c.notify
+	}
+}
+
+func (c *browserConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return 0, io.ErrClosedPipe
+	}
+	arr := js.Global().Get("Uint8Array").New(len(p))
+	js.CopyBytesToJS(arr, p)
+	c.ws.Call("send", arr)
+	return len(p), nil
+}
+
+// Close 关闭 WebSocket 并释放事件处理函数
+func (c *browserConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = io.EOF
+		}
+		c.mu.Unlock()
+		c.wake()
+		c.ws.Call("close")
+		for _, event := range []string{"open", "message", "error", "close"} {
+			c.ws.Set("on"+event, js.Null())
+		}
+		for _, fn := range c.funcs {
+			fn.Release()
+		}
+	})
+	return nil
+}
+
+func (c *browserConn) LocalAddr() net.Addr              { return c.addr }
+func (c *browserConn) RemoteAddr() net.Addr             { return c.addr }
+func (c *browserConn) SetDeadline(time.Time) error      { return nil }
+func (c *browserConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *browserConn) SetWriteDeadline(time.Time) error { return nil }
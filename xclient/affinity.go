@@ -0,0 +1,73 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+)
+
+// affinityKey 是亲和性键在 context 中的键类型
+type affinityKey struct{}
+
+// WithAffinity 返回携带亲和性键的 context。
+// 使用 HashSelect 模式时，相同亲和性键的调用会被路由到同一台服务器
+func WithAffinity(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+// AffinityFromContext 返回 context 中的亲和性键
+func AffinityFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(affinityKey{}).(string)
+	return key, ok && key != ""
+}
+
+// Rank 使用最高随机权重（rendezvous）哈希，返回按 key 的偏好程度从高到低排列的服务器列表。
+// 服务器列表变化时，只有原本映射到被移除服务器的 key 会改变映射
+func (d *MultiServersDiscovery) Rank(key string) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.servers) == 0 {
+		return nil, errors.New("rpc discovery: no available servers")
+	}
+	scores := make(map[string]uint64, len(d.servers))
+	servers := make([]string, len(d.servers))
+	copy(servers, d.servers)
+	for _, s := range servers {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		scores[s] = h.Sum64()
+	}
+	sort.Slice(servers, func(i, j int) bool { return scores[servers[i]] > scores[servers[j]] })
+	return servers, nil
+}
+
+// ranker 是支持按亲和性键排序服务器的 Discovery
+type ranker interface {
+	Rank(key string) ([]string, error)
+}
+
+// pickByAffinity 按亲和性键选择服务器，偏好的服务器熔断时依次选择下一台。
+// ok 为 false 表示无法按亲和性选择，调用方应退回普通的选择逻辑
+func (xc *XClient) pickByAffinity(ctx context.Context, d Discovery, serviceMethod string, candidates []string) (rpcAddr string, ok bool, err error) {
+	key, hasKey := AffinityFromContext(ctx)
+	r, isRanker := d.(ranker)
+	if xc.mode != HashSelect || !hasKey || !isRanker {
+		return "", false, nil
+	}
+	ranked, err := r.Rank(key)
+	if err != nil {
+		return "", true, err
+	}
+	var skipped []string
+	for _, rpcAddr := range ranked {
+		if xc.breaker(rpcAddr).allow() {
+			xc.notifyPick(d, serviceMethod, rpcAddr, candidates, skipped)
+			return rpcAddr, true, nil
+		}
+		skipped = append(skipped, rpcAddr)
+	}
+	return "", true, ErrAllBreakersOpen
+}
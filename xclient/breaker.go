@@ -0,0 +1,182 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	. "geerpc"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAllBreakersOpen 表示所有后端的熔断器都处于打开状态
+var ErrAllBreakersOpen = errors.New("rpc xclient: all backends are circuit-open")
+
+// breakerState 表示熔断器的状态
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // 关闭，正常放行请求
+	breakerOpen                         // 打开，拒绝请求
+	breakerHalfOpen                     // 半开，仅放行少量探测请求
+)
+
+// BreakerConfig 定义了每个后端熔断器的参数
+type BreakerConfig struct {
+	FailureThreshold int           // 连续失败多少次后打开熔断器，0 表示禁用熔断
+	OpenTimeout      time.Duration // 打开状态持续多久后进入半开状态
+	HalfOpenProbes   int           // 半开状态下允许同时进行的探测请求数
+}
+
+// DefaultBreakerConfig 是 XClient 默认使用的熔断器参数
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,
+	OpenTimeout:      time.Second * 10,
+	HalfOpenProbes:   1,
+}
+
+// breaker 是单个后端的熔断器
+type breaker struct {
+	cfg      BreakerConfig
+	mu       sync.Mutex // 保护以下字段
+	state    breakerState
+	failures int       // 连续失败次数
+	openedAt time.Time // 进入打开状态的时间
+	probes   int       // 半开状态下正在进行的探测请求数
+}
+
+// allow 判断是否放行一次请求，半开状态下放行的请求作为探测请求
+func (b *breaker) allow() bool {
+	if b.cfg.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probes = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// ready 判断 allow 此刻是否会放行请求，但不占用半开状态的探测名额，用于在选择后端之前排除熔断的后端
+func (b *breaker) ready() bool {
+	if b.cfg.FailureThreshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		return time.Since(b.openedAt) >= b.cfg.OpenTimeout && b.cfg.HalfOpenProbes > 0
+	case breakerHalfOpen:
+		return b.probes < b.cfg.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// record 记录一次调用结果，返回熔断器是否因此进入打开状态
+func (b *breaker) record(err error) (opened bool) {
+	if b.cfg.FailureThreshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.cfg.FailureThreshold) {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// release 在调用结果不能说明后端状态时（例如调用方取消了调用）调用，只归还半开状态下占用的探测名额
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// backendFailure 判断调用错误是否说明后端出了问题：连接和传输错误、ErrShutdown、超时，
+// 以及服务端因处理超时或正在关闭而返回的错误计入熔断；服务方法返回的错误说明后端工作正常，不计入
+func backendFailure(err error) bool {
+	se, ok := err.(ServerError)
+	if !ok {
+		return err != nil
+	}
+	return strings.HasPrefix(string(se), "rpc server: request handle timeout") || string(se) == "rpc server: shutting down"
+}
+
+// recordBreaker 根据一次调用的结果更新 rpcAddr 的熔断器，熔断器因此打开时摘除该后端。
+// 调用方取消的调用（例如 FailFast 广播中被取消的其他调用）不影响熔断器
+func (xc *XClient) recordBreaker(ctx context.Context, rpcAddr string, err error) {
+	b := xc.breaker(rpcAddr)
+	if err != nil && ctx.Err() == context.Canceled {
+		b.release()
+		return
+	}
+	if !backendFailure(err) {
+		err = nil
+	}
+	if b.record(err) {
+		xc.mu.Lock()
+		opt := xc.opt
+		xc.mu.Unlock()
+		xc.eject(rpcAddr, "circuit-open", opt)
+	}
+}
+
+// SetBreaker 设置每个后端熔断器的参数，已有的熔断器状态会被重置
+func (xc *XClient) SetBreaker(cfg BreakerConfig) {
+	xc.breakerMu.Lock()
+	defer xc.breakerMu.Unlock()
+	xc.breakerCfg = cfg
+	xc.breakers = make(map[string]*breaker)
+}
+
+// breaker 返回 rpcAddr 对应的熔断器，不存在时创建
+func (xc *XClient) breaker(rpcAddr string) *breaker {
+	xc.breakerMu.Lock()
+	defer xc.breakerMu.Unlock()
+	b, ok := xc.breakers[rpcAddr]
+	if !ok {
+		b = &breaker{cfg: xc.breakerCfg}
+		xc.breakers[rpcAddr] = b
+	}
+	return b
+}
+
+// pruneBreakers 删除已不在服务发现中的后端的熔断器
+func (xc *XClient) pruneBreakers(alive map[string]bool) {
+	xc.breakerMu.Lock()
+	defer xc.breakerMu.Unlock()
+	for addr := range xc.breakers {
+		if !alive[addr] {
+			delete(xc.breakers, addr)
+		}
+	}
+}
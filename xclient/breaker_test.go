@@ -0,0 +1,103 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{cfg: BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Millisecond * 50, HalfOpenProbes: 1}}
+	errFail := errors.New("fail")
+	if b.record(errFail) || !b.allow() {
+		t.Fatal("breaker should stay closed before reaching the threshold")
+	}
+	if !b.record(errFail) || b.allow() {
+		t.Fatal("breaker should open after 2 consecutive failures")
+	}
+	time.Sleep(time.Millisecond * 60)
+	if !b.allow() || b.allow() {
+		t.Fatal("half-open breaker should allow exactly one probe")
+	}
+	if b.record(nil) || !b.allow() || !b.allow() {
+		t.Fatal("breaker should close after a successful probe")
+	}
+}
+
+type Slow int
+
+func (Slow) Sleep(ms int, reply *int) error {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return nil
+}
+
+func (Slow) Fail(args int, reply *int) error { return errors.New("application error") }
+
+func TestXClient_BreakerIgnoresCallerErrors(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := geerpc.NewServer()
+	_ = server.Register(new(Slow))
+	go server.Accept(l)
+	defer l.Close()
+	addr := "tcp@" + l.Addr().String()
+
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenProbes: 1})
+
+	var reply int
+	for i := 0; i < 5; i++ {
+		// 服务方法返回的错误说明后端工作正常
+		if err := xc.Call(context.Background(), "Slow.Fail", 1, &reply); err == nil {
+			t.Fatal("expect the application error")
+		}
+		// 调用方取消的调用（例如 FailFast 广播中的其他调用）不计入熔断
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(5*time.Millisecond, cancel)
+		if err := xc.Call(ctx, "Slow.Sleep", 100, &reply); err == nil {
+			t.Fatal("expect the call to be canceled")
+		}
+	}
+	if !xc.breaker(addr).allow() {
+		t.Fatal("breaker should stay closed for application errors and caller cancellation")
+	}
+
+	// 超时计入熔断
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		_ = xc.Call(ctx, "Slow.Sleep", 100, &reply)
+		cancel()
+	}
+	if err := xc.Call(context.Background(), "Slow.Sleep", 0, &reply); err != ErrAllBreakersOpen {
+		t.Fatalf("expect the breaker to open after timeouts, got %v", err)
+	}
+}
+
+func TestXClient_PickSkipsOpenBreakers(t *testing.T) {
+	addr := startArith(t)
+	dead := "tcp@127.0.0.1:1"
+	for _, mode := range []SelectMode{RandomSelect, RoundRobinSelect, WeightedRandomSelect, LeastLoadSelect} {
+		t.Run(mode.String(), func(t *testing.T) {
+			xc := NewXClient(NewMultiServerDiscovery([]string{addr, dead}), mode, nil)
+			defer func() { _ = xc.Close() }()
+			xc.SetBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1})
+			xc.breaker(dead).record(errors.New("down"))
+
+			// 选择模式只作用于熔断器关闭的后端，不会因为抽中熔断的后端而失败
+			var reply int
+			for i := 0; i < 100; i++ {
+				if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+					t.Fatalf("call %d: expect success while %s is closed, got %v", i, addr, err)
+				}
+			}
+
+			xc.breaker(addr).record(errors.New("down"))
+			if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{}, &reply); err != ErrAllBreakersOpen {
+				t.Fatalf("expect ErrAllBreakersOpen once every breaker is open, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package xclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BroadcastPolicy 定义了广播调用出现部分失败时的处理策略
+type BroadcastPolicy int
+
+const (
+	FailFast   BroadcastPolicy = iota // 任一调用失败即取消未完成的调用（默认）
+	BestEffort                        // 等待所有调用完成，失败信息汇总在 *BroadcastError 中
+)
+
+// BroadcastError 汇总了 BestEffort 策略下广播调用失败的服务器及其错误
+type BroadcastError struct {
+	Succeeded int              // 调用成功的服务器数量
+	Failed    map[string]error // 调用失败的服务器及对应的错误
+}
+
+func (e *BroadcastError) Error() string {
+	addrs := make([]string, 0, len(e.Failed))
+	for addr := range e.Failed {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	msgs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		msgs = append(msgs, addr+": "+e.Failed[addr].Error())
+	}
+	return fmt.Sprintf("rpc xclient: broadcast failed on %d of %d servers: %s",
+		len(e.Failed), len(e.Failed)+e.Succeeded, strings.Join(msgs, "; "))
+}
+
+// SetBroadcast 设置广播调用的最大并发数（0 表示不限制）和部分失败时的处理策略
+func (xc *XClient) SetBroadcast(concurrency int, policy BroadcastPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.broadcastLimit = concurrency
+	xc.broadcastPolicy = policy
+}
@@ -0,0 +1,122 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Node 是广播测试中的一台服务器，按配置延迟或失败
+type Node struct {
+	delay   time.Duration
+	fail    bool
+	running *int32 // 所有服务器上正在执行的调用数
+	peak    *int32 // running 的最大值
+}
+
+func (n *Node) Echo(args int, reply *int) error {
+	if n.running != nil {
+		cur := atomic.AddInt32(n.running, 1)
+		defer atomic.AddInt32(n.running, -1)
+		for {
+			peak := atomic.LoadInt32(n.peak)
+			if cur <= peak || atomic.CompareAndSwapInt32(n.peak, peak, cur) {
+				break
+			}
+		}
+	}
+	time.Sleep(n.delay)
+	if n.fail {
+		return errors.New("node failed")
+	}
+	*reply = args
+	return nil
+}
+
+// startNode 启动一个注册了 node 的服务器，返回它的 RPC 地址
+func startNode(t *testing.T, node *Node) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	_ = server.Register(node)
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return "tcp@" + l.Addr().String()
+}
+
+func TestXClient_BroadcastFailFast(t *testing.T) {
+	failing := startNode(t, &Node{fail: true})
+	slow := startNode(t, &Node{delay: 2 * time.Second})
+	xc := NewXClient(NewMultiServerDiscovery([]string{failing, slow}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	// 默认的 FailFast 策略在第一个错误后取消其他服务器上未完成的调用
+	start := time.Now()
+	var reply int
+	err := xc.Broadcast(context.Background(), "Node.Echo", 1, &reply)
+	if err == nil || err.Error() != "node failed" {
+		t.Fatalf("expect the first error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expect the slow call to be cancelled, Broadcast took %v", d)
+	}
+}
+
+func TestXClient_BroadcastBestEffort(t *testing.T) {
+	failing := startNode(t, &Node{fail: true})
+	ok1 := startNode(t, &Node{delay: 50 * time.Millisecond})
+	ok2 := startNode(t, &Node{delay: 50 * time.Millisecond})
+	xc := NewXClient(NewMultiServerDiscovery([]string{failing, ok1, ok2}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetBroadcast(0, BestEffort)
+
+	var reply int
+	err := xc.Broadcast(context.Background(), "Node.Echo", 7, &reply)
+	be, ok := err.(*BroadcastError)
+	if !ok {
+		t.Fatalf("expect a *BroadcastError, got %v", err)
+	}
+	if be.Succeeded != 2 || len(be.Failed) != 1 || be.Failed[failing] == nil {
+		t.Fatalf("expect 2 successes and a failure on %s, got %+v", failing, be)
+	}
+	if reply != 7 {
+		t.Fatalf("expect the reply of a successful server, got %d", reply)
+	}
+
+	// 全部成功时不返回错误
+	xc2 := NewXClient(NewMultiServerDiscovery([]string{ok1, ok2}), RandomSelect, nil)
+	defer func() { _ = xc2.Close() }()
+	xc2.SetBroadcast(0, BestEffort)
+	if err := xc2.Broadcast(context.Background(), "Node.Echo", 7, &reply); err != nil {
+		t.Fatalf("expect no error when every server succeeds, got %v", err)
+	}
+}
+
+func TestXClient_BroadcastLimit(t *testing.T) {
+	var running, peak int32
+	var servers []string
+	for i := 0; i < 5; i++ {
+		servers = append(servers, startNode(t, &Node{delay: 50 * time.Millisecond, running: &running, peak: &peak}))
+	}
+	xc := NewXClient(NewMultiServerDiscovery(servers), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetBroadcast(2, BestEffort)
+
+	var reply int
+	if err := xc.Broadcast(context.Background(), "Node.Echo", 1, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 || p == 0 {
+		t.Fatalf("expect at most 2 concurrent calls, got %d", p)
+	}
+	if n := len(xc.Stats()); n != 5 {
+		t.Fatalf("expect every server to be called, got %d", n)
+	}
+}
@@ -2,6 +2,7 @@ package xclient
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"sync"
@@ -12,16 +13,38 @@ import (
 type SelectMode int
 
 const (
-	RandomSelect     SelectMode = iota // 随机选择
-	RoundRobinSelect                   // 轮询选择
+	RandomSelect         SelectMode = iota // 随机选择
+	RoundRobinSelect                       // 轮询选择
+	WeightedRandomSelect                   // 按权重随机选择
+	HashSelect                             // 按 context 中的亲和性键哈希选择，没有亲和性键时随机选择
+	LeastLoadSelect                        // 按服务器报告的负载选择，不支持负载报告的 Discovery 退化为随机选择
 )
 
+// String 返回选择模式的名称
+func (m SelectMode) String() string {
+	switch m {
+	case RandomSelect:
+		return "RandomSelect"
+	case RoundRobinSelect:
+		return "RoundRobinSelect"
+	case WeightedRandomSelect:
+		return "WeightedRandomSelect"
+	case HashSelect:
+		return "HashSelect"
+	case LeastLoadSelect:
+		return "LeastLoadSelect"
+	default:
+		return fmt.Sprintf("SelectMode(%d)", int(m))
+	}
+}
+
 // Discovery 是一个服务发现的接口，用于获取可用的服务器列表
 type Discovery interface {
 	Refresh() error // 刷新服务器列表
 	Update(servers []string) error
 	Get(mode SelectMode) (string, error)
 	GetAll() ([]string, error)
+	Changes() <-chan struct{} // 服务器列表下一次变化时关闭的 channel
 }
 
 var _ Discovery = (*MultiServersDiscovery)(nil)
@@ -32,7 +55,9 @@ type MultiServersDiscovery struct {
 	r       *rand.Rand   // 用于生成随机数
 	mu      sync.RWMutex // 保护以下字段
 	servers []string
-	index   int // 记录轮询算法选择的位置
+	index   int            // 记录轮询算法选择的位置
+	weights map[string]int // 服务器权重，未设置的服务器权重为 1
+	changed chan struct{}  // 服务器列表变化时关闭并替换
 }
 
 // Refresh 对 MultiServersDiscovery 来说没有意义，因此忽略它
@@ -44,10 +69,29 @@ func (d *MultiServersDiscovery) Refresh() error {
 func (d *MultiServersDiscovery) Update(servers []string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.servers = servers
+	d.setServers(servers)
 	return nil
 }
 
+// setServers 更新服务器列表，列表发生变化时通知所有等待 Changes 的协程，调用方需持有 d.mu
+func (d *MultiServersDiscovery) setServers(servers []string) {
+	if d.servers != nil && equalStrings(d.servers, servers) {
+		return
+	}
+	d.servers = servers
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// Changes 返回一个在服务器列表下一次发生变化时被关闭的 channel。
+// 正确的用法是先调用 Changes，再读取服务器列表，然后等待 channel 关闭，
+// 这样读取列表之后发生的变化不会被遗漏
+func (d *MultiServersDiscovery) Changes() <-chan struct{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.changed
+}
+
 // Get 根据选择模式获取一个服务器
 func (d *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
 	d.mu.Lock()
@@ -57,17 +101,65 @@ func (d *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
 		return "", errors.New("rpc discovery: no available servers")
 	}
 	switch mode {
-	case RandomSelect:
+	case RandomSelect, HashSelect, LeastLoadSelect:
 		return d.servers[d.r.Intn(n)], nil
 	case RoundRobinSelect:
 		s := d.servers[d.index%n] // 服务器列表可能已更新，使用取模 n 确保安全性
 		d.index = (d.index + 1) % n
 		return s, nil
+	case WeightedRandomSelect:
+		return d.weightedRandom(), nil
 	default:
 		return "", errors.New("rpc discovery: not supported select mode")
 	}
 }
 
+// SetWeights 设置服务器的权重，权重为 0 的服务器不会被按权重选中
+func (d *MultiServersDiscovery) SetWeights(weights map[string]int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.weights = weights
+}
+
+// Weight 返回服务器当前的权重
+func (d *MultiServersDiscovery) Weight(server string) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.weight(server)
+}
+
+// weight 返回服务器的权重，调用方需持有 d.mu
+func (d *MultiServersDiscovery) weight(server string) int {
+	w, ok := d.weights[server]
+	if !ok {
+		return 1
+	}
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// weightedRandom 按权重随机选择一个服务器，调用方需持有 d.mu，且服务器列表不为空。
+// 与加权轮询相比，权重相差悬殊时不会连续地把请求集中打到同一台服务器
+func (d *MultiServersDiscovery) weightedRandom() string {
+	total := 0
+	for _, s := range d.servers {
+		total += d.weight(s)
+	}
+	if total == 0 {
+		// 所有服务器权重都为 0 时退化为随机选择
+		return d.servers[d.r.Intn(len(d.servers))]
+	}
+	n := d.r.Intn(total)
+	for _, s := range d.servers {
+		if n -= d.weight(s); n < 0 {
+			return s
+		}
+	}
+	return d.servers[len(d.servers)-1]
+}
+
 // GetAll 返回发现实例中的所有服务器
 func (d *MultiServersDiscovery) GetAll() ([]string, error) {
 	d.mu.RLock()
@@ -83,6 +175,7 @@ func NewMultiServerDiscovery(servers []string) *MultiServersDiscovery {
 	d := &MultiServersDiscovery{
 		servers: servers,
 		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		changed: make(chan struct{}),
 	}
 	d.index = d.r.Intn(math.MaxInt32 - 1)
 	return d
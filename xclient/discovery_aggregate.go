@@ -0,0 +1,140 @@
+package xclient
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// AggregatePolicy 定义了 AggregateDiscovery 如何组合多个来源
+type AggregatePolicy int
+
+const (
+	FailoverSources AggregatePolicy = iota // 只使用优先级最高的可用来源，不可用时依次切换到下一个
+	MergeSources                           // 合并所有可用来源的服务器并去重
+)
+
+// AggregateDiscovery 组合多个 Discovery（例如两个地域的注册中心，或注册中心加静态文件），
+// 按优先级合并或故障切换，适用于迁移等场景
+type AggregateDiscovery struct {
+	*MultiServersDiscovery
+	sources []Discovery // 按优先级从高到低排列
+	policy  AggregatePolicy
+	follow  sync.Once     // 保证只启动一次监听来源变化的协程
+	stop    chan struct{} // Close 后关闭，停止监听来源的变化
+}
+
+var _ Discovery = (*AggregateDiscovery)(nil)
+
+// NewAggregateDiscovery 创建一个 AggregateDiscovery 实例，sources 按优先级从高到低排列
+func NewAggregateDiscovery(policy AggregatePolicy, sources ...Discovery) *AggregateDiscovery {
+	return &AggregateDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		sources:               sources,
+		policy:                policy,
+		stop:                  make(chan struct{}),
+	}
+}
+
+// Close 停止监听来源的变化，不关闭来源本身
+func (d *AggregateDiscovery) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
+
+// Refresh 刷新所有来源并重新组合服务器列表，只有所有来源都不可用时才返回错误
+func (d *AggregateDiscovery) Refresh() error {
+	for _, s := range d.sources {
+		_ = s.Refresh()
+	}
+	return d.aggregate()
+}
+
+// Update 对 AggregateDiscovery 没有意义，服务器列表应由各个来源更新
+func (d *AggregateDiscovery) Update(servers []string) error {
+	return errors.New("rpc discovery: update the sources of an AggregateDiscovery instead")
+}
+
+// Get 根据选择模式从组合后的服务器列表中选择一个服务器
+func (d *AggregateDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.aggregate(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// Changes 返回一个在组合后的服务器列表下一次发生变化时被关闭的 channel，
+// 首次调用时启动后台协程，在任一来源变化时重新组合服务器列表，直到 Close
+func (d *AggregateDiscovery) Changes() <-chan struct{} {
+	d.follow.Do(func() {
+		for _, s := range d.sources {
+			// 在返回之前取得来源的 channel，不会遗漏返回之后立即发生的变化
+			go func(s Discovery, ch <-chan struct{}) {
+				for {
+					select {
+					case <-ch:
+					case <-d.stop:
+						return
+					}
+					ch = s.Changes()
+					_ = d.aggregate()
+				}
+			}(s, s.Changes())
+		}
+	})
+	return d.MultiServersDiscovery.Changes()
+}
+
+// GetAll 返回组合后的服务器列表
+func (d *AggregateDiscovery) GetAll() ([]string, error) {
+	if err := d.aggregate(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
+
+// aggregate 从各个来源获取服务器列表，按策略组合后更新服务器列表和权重
+func (d *AggregateDiscovery) aggregate() error {
+	var servers []string
+	weights := make(map[string]int)
+	seen := make(map[string]bool)
+	var errs []string
+	for _, s := range d.sources {
+		list, err := s.GetAll()
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if len(list) == 0 {
+			continue
+		}
+		w, hasWeight := s.(interface{ Weight(string) int })
+		for _, addr := range list {
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			servers = append(servers, addr)
+			if hasWeight {
+				weights[addr] = w.Weight(addr)
+			}
+		}
+		if d.policy == FailoverSources {
+			break
+		}
+	}
+	if len(servers) == 0 && len(errs) == len(d.sources) && len(errs) > 0 {
+		return errors.New("rpc discovery: all sources failed: " + strings.Join(errs, "; "))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	d.weights = weights
+	return nil
+}
@@ -0,0 +1,169 @@
+package xclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"geerpc"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConsulDiscovery 是一个基于 Consul 健康检查结果的服务发现实现，
+// 只返回健康检查通过的实例
+type ConsulDiscovery struct {
+	*MultiServersDiscovery
+	consul     string        // Consul agent 地址，例如 http://127.0.0.1:8500
+	service    string        // Consul 中的服务名
+	timeout    time.Duration // 刷新超时时间
+	lastUpdate time.Time     // 上次刷新时间
+	stop       chan struct{} // 关闭后停止 Watch
+}
+
+// consulEntry 是 /v1/health/service 接口返回的单个实例
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+}
+
+// NewConsulDiscovery 创建一个 ConsulDiscovery 实例
+func NewConsulDiscovery(consulAddr, service string, timeout time.Duration) *ConsulDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+	return &ConsulDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		consul:                strings.TrimSuffix(consulAddr, "/"),
+		service:               service,
+		timeout:               timeout,
+		stop:                  make(chan struct{}),
+	}
+}
+
+// Update 更新服务器列表
+func (d *ConsulDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// Refresh 从 Consul 刷新健康的服务器列表
+func (d *ConsulDiscovery) Refresh() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastUpdate.Add(d.timeout).After(time.Now()) {
+		return nil
+	}
+	geerpc.DefaultLogger().Debug("rpc registry: refresh servers from consul", "consul", d.consul)
+	servers, _, err := d.fetch(0, 0)
+	if err != nil {
+		geerpc.DefaultLogger().Error("rpc registry refresh err", "err", err)
+		return err
+	}
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// Get 根据选择模式从服务器列表中选择一个服务器
+func (d *ConsulDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// GetAll 返回所有服务器列表
+func (d *ConsulDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
+
+// Watch 启动后台协程，使用 Consul 的阻塞查询监听服务实例的变化，
+// 一旦健康实例发生变化立即更新服务器列表，直到调用 Close
+func (d *ConsulDiscovery) Watch() {
+	go func() {
+		var index uint64
+		for {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+			servers, newIndex, err := d.fetch(index, time.Minute)
+			if err != nil {
+				geerpc.DefaultLogger().Error("rpc registry: consul watch err", "err", err)
+				select {
+				case <-d.stop:
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			// 索引回退时需要重置，参见 Consul 阻塞查询的文档
+			if newIndex < index {
+				newIndex = 0
+			}
+			index = newIndex
+			_ = d.Update(servers)
+		}
+	}()
+}
+
+// Close 停止 Watch 启动的后台协程
+func (d *ConsulDiscovery) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
+
+// fetch 查询健康的服务实例，index 和 wait 不为 0 时使用阻塞查询
+func (d *ConsulDiscovery) fetch(index uint64, wait time.Duration) ([]string, uint64, error) {
+	q := url.Values{"passing": {"true"}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%ds", int(wait/time.Second)))
+	}
+	resp, err := http.Get(d.consul + "/v1/health/service/" + url.PathEscape(d.service) + "?" + q.Encode())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("rpc registry: consul returned %s", resp.Status)
+	}
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, err
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	servers := make([]string, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		protocol := e.Service.Meta["protocol"]
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		servers = append(servers, protocol+"@"+net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+	return servers, newIndex, nil
+}
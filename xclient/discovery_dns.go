@@ -0,0 +1,113 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"geerpc"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSDiscovery 是一个基于 DNS 的服务发现实现，
+// 优先解析 SRV 记录，没有 SRV 记录时回退为解析 A/AAAA 记录并使用固定端口，
+// 适用于部署在 headless DNS 之后的简单场景
+type DNSDiscovery struct {
+	*MultiServersDiscovery
+	name       string        // 要解析的域名，例如 _geerpc._tcp.example.com 或 geerpc.example.com
+	port       int           // 回退为 A/AAAA 记录时使用的端口
+	timeout    time.Duration // 刷新间隔
+	lastUpdate time.Time     // 上次刷新时间
+	resolver   resolver
+}
+
+// resolver 是 DNSDiscovery 使用的域名解析能力，由 *net.Resolver 实现，测试中可以替换
+type resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewDNSDiscovery 创建一个 DNSDiscovery 实例
+func NewDNSDiscovery(name string, port int, timeout time.Duration) *DNSDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+	return &DNSDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		name:                  name,
+		port:                  port,
+		timeout:               timeout,
+		resolver:              net.DefaultResolver,
+	}
+}
+
+// Update 更新服务器列表
+func (d *DNSDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// Refresh 重新解析域名，刷新服务器列表
+func (d *DNSDiscovery) Refresh() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastUpdate.Add(d.timeout).After(time.Now()) {
+		return nil
+	}
+	geerpc.DefaultLogger().Debug("rpc registry: refresh servers from dns", "name", d.name)
+	servers, err := d.resolve()
+	if err != nil {
+		geerpc.DefaultLogger().Error("rpc registry refresh err", "err", err)
+		return err
+	}
+	d.setServers(servers)
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+// Get 根据选择模式从服务器列表中选择一个服务器
+func (d *DNSDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// GetAll 返回所有服务器列表
+func (d *DNSDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
+
+// resolve 解析 SRV 记录，失败时回退为 A/AAAA 记录
+func (d *DNSDiscovery) resolve() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	var servers []string
+	if _, srvs, err := d.resolver.LookupSRV(ctx, "", "", d.name); err == nil && len(srvs) > 0 {
+		for _, srv := range srvs {
+			host := strings.TrimSuffix(srv.Target, ".")
+			servers = append(servers, "tcp@"+net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+		}
+	} else {
+		if d.port == 0 {
+			return nil, errors.New("rpc discovery: no SRV records for " + d.name + " and no fallback port")
+		}
+		addrs, err := d.resolver.LookupHost(ctx, d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			servers = append(servers, "tcp@"+net.JoinHostPort(addr, strconv.Itoa(d.port)))
+		}
+	}
+	sort.Strings(servers)
+	return servers, nil
+}
@@ -0,0 +1,157 @@
+package xclient
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"geerpc/internal/confparse"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileDiscovery 是一个从 JSON 或 YAML 文件读取服务器列表的服务发现实现，
+// 并定期检查文件是否变化，变化后自动重新加载。扩展名为 .yaml 或 .yml 的文件按 YAML 解析，其余按 JSON 解析。
+// JSON 文件的内容可以是服务器数组：
+//
+//	["tcp@10.0.0.1:9999", "tcp@10.0.0.2:9999"]
+//
+// 也可以是带权重的对象：
+//
+//	{"servers": ["tcp@10.0.0.1:9999"], "weights": {"tcp@10.0.0.1:9999": 10}}
+//
+// YAML 文件使用与 config 包相同的 YAML 子集，格式与带权重的对象相同：
+//
+//	servers:
+//	  - tcp@10.0.0.1:9999
+//	weights:
+//	  tcp@10.0.0.1:9999: 10
+//
+// 文件的大小、修改时间或内容的哈希任一不同即视为变化，因此修改时间精度内的多次编辑也不会被遗漏
+type FileDiscovery struct {
+	*MultiServersDiscovery
+	path    string
+	size    int64     // 上次加载时文件的大小
+	modTime time.Time // 上次加载时文件的修改时间
+	sum     [sha256.Size]byte
+	stop    chan struct{}
+}
+
+// fileServers 是带权重的文件格式
+type fileServers struct {
+	Servers []string       `json:"servers"`
+	Weights map[string]int `json:"weights"`
+}
+
+// NewFileDiscovery 创建一个 FileDiscovery 实例，立即加载文件，
+// 并每隔 interval 检查一次文件是否变化（0 表示默认的 2 秒）
+func NewFileDiscovery(path string, interval time.Duration) (*FileDiscovery, error) {
+	if interval == 0 {
+		interval = time.Second * 2
+	}
+	d := &FileDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		path:                  path,
+		stop:                  make(chan struct{}),
+	}
+	if err := d.Refresh(); err != nil {
+		return nil, err
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-t.C:
+				if err := d.Refresh(); err != nil {
+					geerpc.DefaultLogger().Error("rpc registry: reload server file err", "err", err)
+				}
+			}
+		}
+	}()
+	return d, nil
+}
+
+// Refresh 在文件发生变化时重新加载服务器列表，
+// 文件内容无效时保留原有的服务器列表
+func (d *FileDiscovery) Refresh() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	d.mu.RLock()
+	unchanged := info.Size() == d.size && info.ModTime().Equal(d.modTime) && sum == d.sum
+	d.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	var fs fileServers
+	switch strings.ToLower(filepath.Ext(d.path)) {
+	case ".yaml", ".yml":
+		err = parseServersYAML(data, &fs)
+	default:
+		if err = json.Unmarshal(data, &fs.Servers); err != nil {
+			err = json.Unmarshal(data, &fs)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	geerpc.DefaultLogger().Debug("rpc registry: load servers from file", "path", d.path)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServers(fs.Servers)
+	d.weights = fs.Weights
+	d.size, d.modTime, d.sum = info.Size(), info.ModTime(), sum
+	return nil
+}
+
+// parseServersYAML 解析 YAML 格式的服务器列表
+func parseServersYAML(data []byte, fs *fileServers) error {
+	vs, err := confparse.ParseYAML(data)
+	if err != nil {
+		return err
+	}
+	for key, v := range vs {
+		switch {
+		case key == "servers":
+			if !v.IsList {
+				return errors.New("rpc registry: servers must be a list")
+			}
+			fs.Servers = v.List
+		case strings.HasPrefix(key, "weights."):
+			weight, err := strconv.Atoi(v.Scalar)
+			if err != nil || v.IsList {
+				return errors.New("rpc registry: invalid weight for " + strings.TrimPrefix(key, "weights."))
+			}
+			if fs.Weights == nil {
+				fs.Weights = make(map[string]int)
+			}
+			fs.Weights[strings.TrimPrefix(key, "weights.")] = weight
+		default:
+			return errors.New("rpc registry: unknown key " + strconv.Quote(key))
+		}
+	}
+	return nil
+}
+
+// Close 停止检查文件变化
+func (d *FileDiscovery) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package xclient
 
 import (
-	"log"
-	"net/http"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"geerpc/registry"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
@@ -13,6 +19,16 @@ type GeeRegistryDiscovery struct {
 	registry   string        // 注册中心地址
 	timeout    time.Duration // 刷新超时时间
 	lastUpdate time.Time     // 上次刷新时间
+	stop       chan struct{} // 关闭后停止 Watch
+	service    string        // 只发现提供该服务的服务器，为空表示不过滤
+	zone       string        // 只发现位于该可用区的服务器，为空表示不过滤
+	etag       string        // 上一次刷新得到的服务器列表的 ETag
+	maxStale   time.Duration // 注册中心不可用时，缓存的服务器列表过期后最多还能继续使用多久
+	stale      bool          // 当前的服务器列表是否为注册中心不可用时保留的旧列表
+	client     *registry.Client
+
+	metas map[string]registry.Meta
+	loads map[string]registry.Load // 服务器在心跳中报告的负载
 }
 
 const defaultUpdateTimeout = time.Second * 10
@@ -21,44 +37,372 @@ const defaultUpdateTimeout = time.Second * 10
 func (d *GeeRegistryDiscovery) Update(servers []string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.servers = servers
+	d.setServers(servers)
+	d.etag = ""
 	d.lastUpdate = time.Now()
+	d.stale = false
 	return nil
 }
 
 // Refresh 从注册中心刷新服务器列表
 func (d *GeeRegistryDiscovery) Refresh() error {
+	return d.refresh(false)
+}
+
+// refresh 从注册中心刷新服务器列表，force 为 true 时忽略刷新超时时间
+func (d *GeeRegistryDiscovery) refresh(force bool) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	// 如果上次刷新时间距离现在超过超时时间，则进行刷新
-	if d.lastUpdate.Add(d.timeout).After(time.Now()) {
+	if !force && d.lastUpdate.Add(d.timeout).After(time.Now()) {
 		return nil
 	}
-	log.Println("rpc registry: refresh servers from registry", d.registry)
-	resp, err := http.Get(d.registry)
+	geerpc.DefaultLogger().Debug("rpc registry: refresh servers from registry", "registry", d.registry)
+	list, etag, err := d.client.List(registry.ListOptions{Service: d.service, Zone: d.zone, ETag: d.etag})
+	if err == registry.ErrNotModified {
+		// 服务器列表没有变化，无需重新解析
+		d.lastUpdate = time.Now()
+		d.stale = false
+		return nil
+	}
+	if err != nil {
+		geerpc.DefaultLogger().Error("rpc registry refresh err", "err", err)
+		return d.fallback(err)
+	}
+	d.setServerList(list)
+	d.etag = etag
+	d.lastUpdate = time.Now()
+	d.stale = false
+	return nil
+}
+
+// SetMaxStale 设置注册中心不可用时的降级策略：缓存的服务器列表过期后的 maxStale 时间内，
+// 刷新失败不再返回错误，而是继续使用上一次成功获取的服务器列表，并将其标记为过期（见 Stale），
+// 避免注册中心故障立即导致所有客户端不可用。maxStale 为 0（默认）时刷新失败直接返回错误
+func (d *GeeRegistryDiscovery) SetMaxStale(maxStale time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxStale = maxStale
+}
+
+// Stale 返回当前的服务器列表是否为注册中心不可用时保留的旧列表
+func (d *GeeRegistryDiscovery) Stale() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.stale
+}
+
+// fallback 在刷新失败时决定是否继续使用缓存的服务器列表，调用方需持有 d.mu
+func (d *GeeRegistryDiscovery) fallback(err error) error {
+	if d.maxStale == 0 || len(d.servers) == 0 || d.lastUpdate.Add(d.timeout+d.maxStale).Before(time.Now()) {
+		d.stale = false
+		return err
+	}
+	if !d.stale {
+		geerpc.DefaultLogger().Warn("rpc registry: registry unavailable, serving stale servers", "registry", d.registry)
+	}
+	d.stale = true
+	return nil
+}
+
+// ForceRefresh 忽略刷新超时时间，立即从注册中心刷新服务器列表
+func (d *GeeRegistryDiscovery) ForceRefresh() error {
+	return d.refresh(true)
+}
+
+// RefreshEvery 启动后台协程，每隔 interval 主动刷新一次服务器列表，直到调用 Close。
+// 即使没有任何调用，缓存的服务器列表也能保持最新，避免调用突增时集中刷新
+func (d *GeeRegistryDiscovery) RefreshEvery(interval time.Duration) {
+	if interval == 0 {
+		interval = d.timeout
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-t.C:
+				_ = d.ForceRefresh()
+			}
+		}
+	}()
+}
+
+// SetZone 只发现位于 zone 可用区的服务器，由注册中心完成过滤，
+// 比在客户端按 Meta.Zone 过滤更省带宽。应在开始使用 Discovery 之前调用
+func (d *GeeRegistryDiscovery) SetZone(zone string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.zone = zone
+	d.etag = ""
+	d.lastUpdate = time.Time{}
+}
+
+// SetToken 设置访问注册中心时携带的令牌，刷新、长轮询和事件订阅都会携带它，
+// 与把令牌写在注册中心地址的密码中等价。应在开始使用 Discovery 之前调用
+func (d *GeeRegistryDiscovery) SetToken(token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.client.Token = token
+}
+
+// endpoint 返回注册中心上 path 对应的地址，并附加服务名和可用区过滤参数
+func (d *GeeRegistryDiscovery) endpoint(path string, q url.Values) string {
+	if q == nil {
+		q = url.Values{}
+	}
+	if d.service != "" {
+		q.Set("service", d.service)
+	}
+	if d.zone != "" {
+		q.Set("zone", d.zone)
+	}
+	target := d.registry
+	if path != "" {
+		target = strings.TrimSuffix(d.registry, "/") + path
+	}
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	return target
+}
+
+// setServerList 根据注册中心返回的服务器列表更新服务器列表和元数据，调用方需持有 d.mu
+func (d *GeeRegistryDiscovery) setServerList(list *registry.ServerList) {
+	servers := make([]string, 0, len(list.Servers))
+	metas := make(map[string]registry.Meta, len(list.Servers))
+	loads := make(map[string]registry.Load, len(list.Servers))
+	for _, s := range list.Servers {
+		servers = append(servers, s.Addr)
+		metas[s.Addr] = s.Meta
+		if s.Load != nil {
+			loads[s.Addr] = *s.Load
+		}
+	}
+	d.setList(servers, metas)
+	d.loads = loads
+}
+
+// setList 更新服务器列表，保存服务器的元数据并据此更新服务器权重，调用方需持有 d.mu。
+// 注册中心只会把管理员排空的服务器的权重设为 0，这些服务器不会出现在服务器列表中
+func (d *GeeRegistryDiscovery) setList(servers []string, metas map[string]registry.Meta) {
+	active := make([]string, 0, len(servers))
+	for _, addr := range servers {
+		if meta, ok := metas[addr]; ok && meta.Weight == 0 {
+			continue
+		}
+		active = append(active, addr)
+	}
+	d.setServers(active)
+	d.metas = metas
+	d.weights = make(map[string]int, len(metas))
+	for addr, meta := range metas {
+		d.weights[addr] = meta.Weight
+	}
+}
+
+// Watch 启动后台协程，通过注册中心 JSON API 的长轮询监听成员变化，
+// 成员一旦变化立即更新服务器列表，而不必等到下一次定时刷新，直到调用 Close
+func (d *GeeRegistryDiscovery) Watch() {
+	go func() {
+		var index uint64
+		for {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+			newIndex, err := d.poll(index)
+			if err != nil {
+				geerpc.DefaultLogger().Error("rpc registry: watch err", "err", err)
+				select {
+				case <-d.stop:
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			index = newIndex
+		}
+	}()
+}
+
+// Subscribe 启动后台协程，订阅注册中心的成员变化事件流（Server-Sent Events），
+// 近乎实时地更新服务器列表，连接断开后自动重新订阅，直到调用 Close
+func (d *GeeRegistryDiscovery) Subscribe() {
+	go func() {
+		for {
+			err := d.subscribe()
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+			if err != nil {
+				geerpc.DefaultLogger().Error("rpc registry: subscribe err", "err", err)
+			}
+			select {
+			case <-d.stop:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// subscribe 连接事件流并持续应用事件，直到连接断开或调用 Close
+func (d *GeeRegistryDiscovery) subscribe() error {
+	// 请求在返回或 Close 时取消，每次重新订阅不会留下等待 Close 的协程
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	body, err := d.client.Events(ctx, registry.ListOptions{Service: d.service, Zone: d.zone})
 	if err != nil {
-		log.Println("rpc registry refresh err:", err)
 		return err
 	}
-	servers := strings.Split(resp.Header.Get("X-Geerpc-Servers"), ",")
-	d.servers = make([]string, 0, len(servers))
-	for _, server := range servers {
-		if strings.TrimSpace(server) != "" {
-			d.servers = append(d.servers, strings.TrimSpace(server))
+	defer func() { _ = body.Close() }()
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "" && data != "":
+			var e registry.Event
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				return err
+			}
+			d.apply(e)
+			data = ""
 		}
 	}
+	return scanner.Err()
+}
+
+// apply 将一个成员变化事件应用到服务器列表
+func (d *GeeRegistryDiscovery) apply(e registry.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e.Type == registry.EventSync {
+		list := e.List
+		if list == nil {
+			list = &registry.ServerList{}
+		}
+		d.setServerList(list)
+	} else {
+		metas := make(map[string]registry.Meta, len(d.metas))
+		for addr, meta := range d.metas {
+			metas[addr] = meta
+		}
+		loads := make(map[string]registry.Load, len(d.loads))
+		for addr, load := range d.loads {
+			loads[addr] = load
+		}
+		switch e.Type {
+		case registry.EventJoin, registry.EventUpdate:
+			metas[e.Server.Addr] = e.Server.Meta
+			if e.Server.Load != nil {
+				loads[e.Server.Addr] = *e.Server.Load
+			}
+		case registry.EventLeave:
+			delete(metas, e.Server.Addr)
+			delete(loads, e.Server.Addr)
+		}
+		servers := make([]string, 0, len(metas))
+		for addr := range metas {
+			servers = append(servers, addr)
+		}
+		sort.Strings(servers)
+		d.setList(servers, metas)
+		d.loads = loads
+	}
 	d.lastUpdate = time.Now()
+	d.stale = false
+}
+
+// Close 停止 Watch、Subscribe 和 RefreshEvery 启动的后台协程
+func (d *GeeRegistryDiscovery) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
 	return nil
 }
 
+// poll 发送一次长轮询请求，成员变化或等待超时后更新服务器列表，返回最新的版本号
+func (d *GeeRegistryDiscovery) poll(index uint64) (uint64, error) {
+	list, err := d.client.Watch(context.Background(), index, 30*time.Second, registry.ListOptions{Service: d.service, Zone: d.zone})
+	if err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setServerList(list)
+	d.lastUpdate = time.Now()
+	d.stale = false
+	return list.Index, nil
+}
+
+// Meta 返回注册中心中服务器的元数据
+func (d *GeeRegistryDiscovery) Meta(addr string) (registry.Meta, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	meta, ok := d.metas[addr]
+	return meta, ok
+}
+
+// Load 返回服务器最近一次报告的负载
+func (d *GeeRegistryDiscovery) Load(addr string) (registry.Load, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	load, ok := d.loads[addr]
+	return load, ok
+}
+
 // Get 根据选择模式从服务器列表中选择一个服务器
 func (d *GeeRegistryDiscovery) Get(mode SelectMode) (string, error) {
 	if err := d.Refresh(); err != nil {
 		return "", err
 	}
+	if mode == LeastLoadSelect {
+		return d.leastLoaded()
+	}
 	return d.MultiServersDiscovery.Get(mode)
 }
 
+// leastLoaded 随机选出两台服务器，返回其中报告的负载较低的一台（power of two choices），
+// 避免所有客户端在负载信息更新之前同时涌向负载最低的同一台服务器
+func (d *GeeRegistryDiscovery) leastLoaded() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.servers)
+	if n == 0 {
+		return "", errors.New("rpc discovery: no available servers")
+	}
+	a, b := d.servers[d.r.Intn(n)], d.servers[d.r.Intn(n)]
+	if lessLoaded(d.loads[b], d.loads[a]) {
+		return b, nil
+	}
+	return a, nil
+}
+
+// lessLoaded 判断负载 a 是否低于 b：先比较正在处理和排队的请求数，相同时比较 CPU 使用率
+func lessLoaded(a, b registry.Load) bool {
+	return a.Inflight+a.QueueDepth < b.Inflight+b.QueueDepth ||
+		(a.Inflight+a.QueueDepth == b.Inflight+b.QueueDepth && a.CPU < b.CPU)
+}
+
 // GetAll 返回所有服务器列表
 func (d *GeeRegistryDiscovery) GetAll() ([]string, error) {
 	if err := d.Refresh(); err != nil {
@@ -75,7 +419,17 @@ func NewGeeRegistryDiscovery(registerAddr string, timeout time.Duration) *GeeReg
 	d := &GeeRegistryDiscovery{
 		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
 		registry:              registerAddr,
+		client:                registry.NewClient(registerAddr),
 		timeout:               timeout,
+		stop:                  make(chan struct{}),
 	}
 	return d
 }
+
+// NewGeeRegistryServiceDiscovery 创建一个只发现提供 service 服务的服务器的 GeeRegistryDiscovery 实例，
+// 通常与 XClient.SetServiceDiscovery 配合使用
+func NewGeeRegistryServiceDiscovery(registerAddr, service string, timeout time.Duration) *GeeRegistryDiscovery {
+	d := NewGeeRegistryDiscovery(registerAddr, timeout)
+	d.service = service
+	return d
+}
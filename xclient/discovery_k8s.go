@@ -0,0 +1,261 @@
+package xclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geerpc"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountDir 是 Pod 内 ServiceAccount 凭据的挂载目录
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+// K8sDiscovery 是一个监听 Kubernetes Service 的 EndpointSlice 的服务发现实现，
+// 将处于 ready 状态的 Pod IP 作为服务器列表，集群内的 geerpc 服务器无需额外的注册中心
+type K8sDiscovery struct {
+	*MultiServersDiscovery
+	api       string // API Server 地址
+	token     string // ServiceAccount 令牌
+	client    *http.Client
+	namespace string
+	service   string
+	portName  string // EndpointSlice 中的端口名，为空时使用第一个端口
+
+	slicesMu sync.Mutex          // 保护 slices
+	slices   map[string][]string // EndpointSlice 名称 -> 该分片中的服务器
+	stop     chan struct{}
+}
+
+// endpointSlice 是 discovery.k8s.io/v1 EndpointSlice 中用到的字段
+type endpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name *string `json:"name"`
+		Port *int    `json:"port"`
+	} `json:"ports"`
+}
+
+// NewK8sDiscovery 使用 Pod 内的 ServiceAccount 凭据创建 K8sDiscovery 实例，
+// 并启动后台协程监听 namespace 下 service 的 EndpointSlice 变化
+func NewK8sDiscovery(namespace, service, portName string) (*K8sDiscovery, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("rpc discovery: not running inside a kubernetes cluster")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	if namespace == "" {
+		ns, _ := ioutil.ReadFile(serviceAccountDir + "namespace")
+		namespace = strings.TrimSpace(string(ns))
+	}
+	d := &K8sDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		api:                   "https://" + net.JoinHostPort(host, port),
+		token:                 strings.TrimSpace(string(token)),
+		client:                &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}},
+		namespace:             namespace,
+		service:               service,
+		portName:              portName,
+		slices:                make(map[string][]string),
+		stop:                  make(chan struct{}),
+	}
+	go d.watch()
+	return d, nil
+}
+
+// Refresh 对 K8sDiscovery 来说没有意义，服务器列表由后台监听实时更新
+func (d *K8sDiscovery) Refresh() error {
+	return nil
+}
+
+// Close 停止监听
+func (d *K8sDiscovery) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
+
+// watch 先全量列出 EndpointSlice，再从返回的 resourceVersion 开始监听变化，出错时重新列出
+func (d *K8sDiscovery) watch() {
+	for {
+		version, err := d.list()
+		if err == nil {
+			err = d.watchFrom(version)
+		}
+		if err != nil {
+			geerpc.DefaultLogger().Error("rpc registry: kubernetes watch err", "err", err)
+		}
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// path 返回 EndpointSlice 的查询路径
+func (d *K8sDiscovery) path(extra url.Values) string {
+	q := url.Values{"labelSelector": {"kubernetes.io/service-name=" + d.service}}
+	for k, v := range extra {
+		q[k] = v
+	}
+	return fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s", d.api, d.namespace, q.Encode())
+}
+
+// get 向 API Server 发送带令牌的 GET 请求，ctx 结束时取消请求
+func (d *K8sDiscovery) get(ctx context.Context, target string) (*http.Response, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", target, nil)
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("rpc discovery: kubernetes api returned %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// list 全量列出 EndpointSlice，返回列表的 resourceVersion
+func (d *K8sDiscovery) list() (string, error) {
+	resp, err := d.get(context.Background(), d.path(nil))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []endpointSlice `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", err
+	}
+	d.slicesMu.Lock()
+	d.slices = make(map[string][]string)
+	for _, s := range list.Items {
+		d.slices[s.Metadata.Name] = d.ready(s)
+	}
+	d.slicesMu.Unlock()
+	d.publish()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watchFrom 从 version 开始监听 EndpointSlice 的变化，直到连接断开或 Close
+func (d *K8sDiscovery) watchFrom(version string) error {
+	// 监听请求在返回或 Close 时取消，API Server 定期结束监听，每次重连不能留下等待 Close 的协程
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	resp, err := d.get(ctx, d.path(url.Values{"watch": {"true"}, "resourceVersion": {version}}))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string        `json:"type"`
+			Object endpointSlice `json:"object"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			return err
+		}
+		d.slicesMu.Lock()
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			d.slices[event.Object.Metadata.Name] = d.ready(event.Object)
+		case "DELETED":
+			delete(d.slices, event.Object.Metadata.Name)
+		case "ERROR":
+			d.slicesMu.Unlock()
+			return errors.New("rpc discovery: kubernetes watch expired")
+		}
+		d.slicesMu.Unlock()
+		d.publish()
+	}
+}
+
+// ready 返回 EndpointSlice 中处于 ready 状态的服务器
+func (d *K8sDiscovery) ready(s endpointSlice) []string {
+	port := 0
+	for _, p := range s.Ports {
+		if p.Port != nil && (d.portName == "" || (p.Name != nil && *p.Name == d.portName)) {
+			port = *p.Port
+			break
+		}
+	}
+	if port == 0 {
+		return nil
+	}
+	var servers []string
+	for _, e := range s.Endpoints {
+		// ready 为空表示状态未知，按 Kubernetes 的约定视为 ready
+		if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+			continue
+		}
+		for _, addr := range e.Addresses {
+			servers = append(servers, "tcp@"+net.JoinHostPort(addr, strconv.Itoa(port)))
+		}
+	}
+	return servers
+}
+
+// publish 合并所有 EndpointSlice 中的服务器并更新服务器列表
+func (d *K8sDiscovery) publish() {
+	d.slicesMu.Lock()
+	seen := make(map[string]bool)
+	servers := make([]string, 0)
+	for _, s := range d.slices {
+		for _, addr := range s {
+			if !seen[addr] {
+				seen[addr] = true
+				servers = append(servers, addr)
+			}
+		}
+	}
+	d.slicesMu.Unlock()
+	sort.Strings(servers)
+	_ = d.Update(servers)
+}
@@ -0,0 +1,166 @@
+package xclient
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// SubsetDiscovery 从底层 Discovery 返回的服务器列表中，根据客户端 ID
+// 确定性地挑选一个大小为 size 的子集，限制单个客户端的连接数，
+// 同时保证所有客户端的总体负载均匀
+type SubsetDiscovery struct {
+	*MultiServersDiscovery
+	d        Discovery
+	clientID uint64
+	size     int
+
+	subsetMu sync.Mutex    // 保护 last
+	last     []string      // 上一次计算子集时底层的服务器列表
+	follow   sync.Once     // 保证只启动一次监听底层变化的协程
+	stop     chan struct{} // Close 后关闭，停止监听底层的变化
+}
+
+var _ Discovery = (*SubsetDiscovery)(nil)
+
+// NewSubsetDiscovery 创建一个 SubsetDiscovery 实例，size <= 0 表示不做子集划分。
+// clientID 被哈希为一个随机的客户端序号，各客户端的子集只在统计上均匀：
+// 客户端较少时部分服务器可能比其他服务器多承担几个客户端。
+// 能为客户端分配连续序号（例如 StatefulSet 的序号）时应使用 NewSubsetDiscoveryIndex
+func NewSubsetDiscovery(d Discovery, clientID string, size int) *SubsetDiscovery {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(clientID))
+	return newSubsetDiscovery(d, h.Sum64(), size)
+}
+
+// NewSubsetDiscoveryIndex 与 NewSubsetDiscovery 相同，但使用从 0 开始的连续客户端序号 index。
+// 序号连续的每 len(servers)/size 个客户端恰好把所有服务器均匀地分完，
+// 客户端数是该值的整数倍时每台服务器的客户端数完全相同
+func NewSubsetDiscoveryIndex(d Discovery, index int, size int) *SubsetDiscovery {
+	if index < 0 {
+		index = 0
+	}
+	return newSubsetDiscovery(d, uint64(index), size)
+}
+
+func newSubsetDiscovery(d Discovery, clientID uint64, size int) *SubsetDiscovery {
+	return &SubsetDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		d:                     d,
+		clientID:              clientID,
+		size:                  size,
+		stop:                  make(chan struct{}),
+	}
+}
+
+// Close 停止监听底层 Discovery 的变化，不关闭底层 Discovery
+func (d *SubsetDiscovery) Close() error {
+	d.subsetMu.Lock()
+	defer d.subsetMu.Unlock()
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
+
+// Refresh 刷新底层 Discovery，并在服务器列表变化时重新计算子集
+func (d *SubsetDiscovery) Refresh() error {
+	if err := d.d.Refresh(); err != nil {
+		return err
+	}
+	return d.resubset()
+}
+
+// Update 更新底层 Discovery 的服务器列表，并重新计算子集
+func (d *SubsetDiscovery) Update(servers []string) error {
+	if err := d.d.Update(servers); err != nil {
+		return err
+	}
+	return d.resubset()
+}
+
+// Get 根据选择模式从子集中选择一个服务器
+func (d *SubsetDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.resubset(); err != nil {
+		return "", err
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+// Changes 返回一个在子集下一次发生变化时被关闭的 channel，
+// 首次调用时启动后台协程，在底层服务器列表变化时重新计算子集，直到 Close
+func (d *SubsetDiscovery) Changes() <-chan struct{} {
+	d.follow.Do(func() {
+		go func(ch <-chan struct{}) {
+			for {
+				select {
+				case <-ch:
+				case <-d.stop:
+					return
+				}
+				ch = d.d.Changes()
+				_ = d.resubset()
+			}
+		}(d.d.Changes())
+	})
+	return d.MultiServersDiscovery.Changes()
+}
+
+// GetAll 返回子集中的所有服务器
+func (d *SubsetDiscovery) GetAll() ([]string, error) {
+	if err := d.resubset(); err != nil {
+		return nil, err
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
+
+// resubset 从底层 Discovery 获取服务器列表，列表发生变化时重新计算子集
+func (d *SubsetDiscovery) resubset() error {
+	servers, err := d.d.GetAll()
+	if err != nil {
+		return err
+	}
+	d.subsetMu.Lock()
+	defer d.subsetMu.Unlock()
+	if d.last != nil && equalStrings(d.last, servers) {
+		return nil
+	}
+	d.last = servers
+	return d.MultiServersDiscovery.Update(subset(servers, d.clientID, d.size))
+}
+
+// subset 实现确定性子集划分，clientID 是客户端序号：
+// 客户端按 len(servers)/size 个一组划分为若干轮，同一轮的客户端使用相同的种子打乱服务器列表，
+// 再各自取不相交的一段，从而使每一轮内所有服务器被均匀地分配
+func subset(servers []string, clientID uint64, size int) []string {
+	if size <= 0 || size >= len(servers) {
+		return servers
+	}
+	sorted := make([]string, len(servers))
+	copy(sorted, servers)
+	sort.Strings(sorted)
+
+	subsetCount := uint64(len(sorted) / size)
+	round := clientID / subsetCount
+	r := rand.New(rand.NewSource(int64(round)))
+	r.Shuffle(len(sorted), func(i, j int) { sorted[i], sorted[j] = sorted[j], sorted[i] })
+
+	start := int(clientID%subsetCount) * size
+	return sorted[start : start+size]
+}
+
+// equalStrings 判断两个字符串切片是否相等
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
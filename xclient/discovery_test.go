@@ -0,0 +1,793 @@
+package xclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geerpc/registry"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMultiServersDiscovery_Rank(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{"tcp@a", "tcp@b", "tcp@c"})
+	ranked, _ := d.Rank("user-42")
+	if len(ranked) != 3 {
+		t.Fatalf("expect 3 ranked servers, but got %d", len(ranked))
+	}
+	// 移除一台非首选服务器后，首选服务器保持不变
+	var rest []string
+	for _, s := range ranked {
+		if s != ranked[1] {
+			rest = append(rest, s)
+		}
+	}
+	_ = d.Update(rest)
+	again, _ := d.Rank("user-42")
+	if again[0] != ranked[0] {
+		t.Fatalf("expect %s to stay preferred, but got %s", ranked[0], again[0])
+	}
+}
+
+func TestAffinityFromContext(t *testing.T) {
+	if _, ok := AffinityFromContext(context.Background()); ok {
+		t.Fatal("expect no affinity key in background context")
+	}
+	key, ok := AffinityFromContext(WithAffinity(context.Background(), "session-1"))
+	if !ok || key != "session-1" {
+		t.Fatalf("expect affinity key session-1, but got %q", key)
+	}
+}
+
+func TestGeeRegistryDiscovery_MaxStale(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	req, _ := http.NewRequest("POST", ts.URL, nil)
+	req.Header.Set("X-Geerpc-Server", "tcp@127.0.0.1:9999")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	d := NewGeeRegistryDiscovery(ts.URL, 10*time.Millisecond)
+	d.SetMaxStale(time.Minute)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 {
+		t.Fatalf("expect 1 server, but got %v %v", servers, err)
+	}
+	ts.Close()
+	time.Sleep(20 * time.Millisecond)
+	if servers, err := d.GetAll(); err != nil || len(servers) != 1 || !d.Stale() {
+		t.Fatalf("expect stale servers, but got %v %v", servers, err)
+	}
+	d.SetMaxStale(0)
+	if _, err := d.GetAll(); err == nil {
+		t.Fatal("expect an error without max stale")
+	}
+}
+
+func TestK8sDiscovery_WatchReconnect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 每次监听只返回一个事件就结束，模拟 API Server 定期结束监听
+		_, _ = fmt.Fprint(w, `{"type":"ADDED","object":{"metadata":{"name":"s1"},"endpoints":[{"addresses":["10.0.0.1"]}],"ports":[{"port":9999}]}}`)
+	}))
+	defer ts.Close()
+	d := &K8sDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		api:                   ts.URL,
+		client:                ts.Client(),
+		namespace:             "default",
+		service:               "geerpc",
+		slices:                make(map[string][]string),
+		stop:                  make(chan struct{}),
+	}
+	defer d.Close()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		_ = d.watchFrom("1")
+	}
+	if servers, _ := d.GetAll(); len(servers) != 1 || servers[0] != "tcp@10.0.0.1:9999" {
+		t.Fatalf("unexpected servers %v", servers)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := runtime.NumGoroutine(); n > before+5 {
+		t.Fatalf("expect no goroutine left per reconnect, got %d goroutines (%d before)", n, before)
+	}
+}
+
+func TestGeeRegistryDiscovery_SubscribeWithToken(t *testing.T) {
+	r := registry.New(time.Minute)
+	r.SetToken("secret", true)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	d := NewGeeRegistryDiscovery(ts.URL, time.Minute)
+	d.SetToken("secret")
+	c := registry.NewClient(ts.URL)
+	c.Token = "secret"
+	if err := c.Register("tcp@127.0.0.1:9999", registry.Meta{}); err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	d.Subscribe()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		d.mu.RLock()
+		n := len(d.servers)
+		d.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect the subscription to carry the token and receive the server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = d.Close()
+	time.Sleep(50 * time.Millisecond)
+	if n := runtime.NumGoroutine(); n > before+2 {
+		t.Fatalf("expect the subscription to stop after Close, got %d goroutines (%d before)", n, before)
+	}
+}
+
+func TestSubsetDiscoveryIndex_Spread(t *testing.T) {
+	var servers []string
+	for i := 0; i < 12; i++ {
+		servers = append(servers, fmt.Sprintf("tcp@10.0.0.%d:9999", i))
+	}
+	src := NewMultiServerDiscovery(servers)
+	// 12 台服务器、子集大小 3，每 4 个客户端分完一轮，12 个客户端时每台服务器恰好 3 个客户端
+	counts := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		d := NewSubsetDiscoveryIndex(src, i, 3)
+		got, err := d.GetAll()
+		if err != nil || len(got) != 3 {
+			t.Fatalf("expect a subset of 3, got %v %v", got, err)
+		}
+		for _, s := range got {
+			counts[s]++
+		}
+	}
+	for _, s := range servers {
+		if counts[s] != 3 {
+			t.Fatalf("expect every server to get 3 clients, got %v", counts)
+		}
+	}
+}
+
+func TestFileDiscovery_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geerpc-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "servers.yaml")
+	mtime := time.Now().Add(-time.Minute)
+	write := func(content string) {
+		// 先写入临时文件再改名，后台的轮询不会读到写了一半的文件
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// 保持修改时间不变，模拟在修改时间精度内的多次编辑
+		_ = os.Chtimes(tmp, mtime, mtime)
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("servers:\n  - tcp@10.0.0.1:9999\n  - tcp@10.0.0.2:9999\nweights:\n  tcp@10.0.0.1:9999: 10\n")
+	d, err := NewFileDiscovery(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if servers, _ := d.GetAll(); len(servers) != 2 {
+		t.Fatalf("expect 2 servers, got %v", servers)
+	}
+	d.mu.RLock()
+	weight := d.weights["tcp@10.0.0.1:9999"]
+	d.mu.RUnlock()
+	if weight != 10 {
+		t.Fatalf("expect weight 10, got %d", weight)
+	}
+
+	// 大小和修改时间都不变，只有内容不同
+	write("servers:\n  - tcp@10.0.0.3:9999\n  - tcp@10.0.0.4:9999\nweights:\n  tcp@10.0.0.3:9999: 10\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		servers, _ := d.GetAll()
+		if len(servers) == 2 && servers[0] == "tcp@10.0.0.3:9999" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect the edit to be reloaded, got %v", servers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 无效的内容不会替换原有的服务器列表
+	write("servers: [tcp@10.0.0.5:9999]\nunknown: 1\n")
+	if err := d.Refresh(); err == nil {
+		t.Fatal("expect an error for an unknown key")
+	}
+	if servers, _ := d.GetAll(); len(servers) != 2 {
+		t.Fatalf("expect the previous servers to be kept, got %v", servers)
+	}
+
+	jsonPath := filepath.Join(dir, "servers.json")
+	_ = ioutil.WriteFile(jsonPath, []byte(`{"servers": ["tcp@10.0.0.6:9999"]}`), 0644)
+	jd, err := NewFileDiscovery(jsonPath, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jd.Close()
+	if servers, _ := jd.GetAll(); len(servers) != 1 || servers[0] != "tcp@10.0.0.6:9999" {
+		t.Fatalf("expect the JSON file to be loaded, got %v", servers)
+	}
+}
+
+func TestMultiServersDiscovery_WeightedRandom(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{"tcp@a", "tcp@b", "tcp@c"})
+	d.SetWeights(map[string]int{"tcp@a": 1, "tcp@b": 9, "tcp@c": 0})
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		s, err := d.Get(WeightedRandomSelect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[s]++
+	}
+	if counts["tcp@c"] != 0 {
+		t.Fatalf("expect a server with weight 0 never to be picked, got %d", counts["tcp@c"])
+	}
+	if share := float64(counts["tcp@b"]) / n; share < 0.85 || share > 0.95 {
+		t.Fatalf("expect tcp@b to get about 90%% of picks, got %.2f", share)
+	}
+
+	// 所有权重都为 0 时退化为随机选择
+	d.SetWeights(map[string]int{"tcp@a": 0, "tcp@b": 0, "tcp@c": 0})
+	counts = make(map[string]int)
+	for i := 0; i < 300; i++ {
+		s, _ := d.Get(WeightedRandomSelect)
+		counts[s]++
+	}
+	if len(counts) != 3 {
+		t.Fatalf("expect all servers to be picked when every weight is 0, got %v", counts)
+	}
+}
+
+func TestGeeRegistryDiscovery_WeightedRandom(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	for addr, weight := range map[string]int{"tcp@127.0.0.1:9998": 1, "tcp@127.0.0.1:9999": 9} {
+		body := fmt.Sprintf(`{"addr":%q,"weight":%d}`, addr, weight)
+		resp, err := http.Post(ts.URL+"/_geerpc_/registry/register", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	d := NewGeeRegistryDiscovery(ts.URL+"/_geerpc_/registry", time.Minute)
+	const n = 10000
+	heavy := 0
+	for i := 0; i < n; i++ {
+		s, err := d.Get(WeightedRandomSelect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == "tcp@127.0.0.1:9999" {
+			heavy++
+		}
+	}
+	if share := float64(heavy) / n; share < 0.85 || share > 0.95 {
+		t.Fatalf("expect the server with weight 9 to get about 90%% of picks, got %.2f", share)
+	}
+	if w := d.Weight("tcp@127.0.0.1:9999"); w != 9 {
+		t.Fatalf("expect the weight announced to the registry, got %d", w)
+	}
+}
+
+func TestGeeRegistryDiscovery_Load(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	client := registry.NewClient(ts.URL + "/_geerpc_/registry")
+	const addr = "tcp@127.0.0.1:9999"
+	if err := client.RegisterWithLoad(addr, registry.Meta{}, registry.Load{Inflight: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewGeeRegistryDiscovery(ts.URL+"/_geerpc_/registry", time.Millisecond)
+	if err := d.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if load, _ := d.Load(addr); load.Inflight != 1 {
+		t.Fatalf("expect inflight 1, got %+v", load)
+	}
+
+	// 心跳中报告的新负载在下一次拉取时生效，即使成员没有变化
+	if err := client.RegisterWithLoad(addr, registry.Meta{}, registry.Load{Inflight: 7}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := d.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if load, _ := d.Load(addr); load.Inflight != 7 {
+		t.Fatalf("expect the changed load to reach the discovery, got %+v", load)
+	}
+}
+
+func TestGeeRegistryDiscovery_Meta(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	client := registry.NewClient(ts.URL + "/_geerpc_/registry")
+	const addr = "tcp@127.0.0.1:9999"
+	meta := registry.Meta{Weight: 2, Zone: "us-east-1a", Version: "v2", Codecs: []string{"application/json"}}
+	if err := client.Register(addr, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewGeeRegistryDiscovery(ts.URL+"/_geerpc_/registry", time.Minute)
+	if err := d.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := d.Meta(addr)
+	if !ok || got.Zone != "us-east-1a" || got.Version != "v2" || !reflect.DeepEqual(got.Codecs, meta.Codecs) || d.Weight(addr) != 2 {
+		t.Fatalf("expect the registered metadata, got %+v, %v", got, ok)
+	}
+	if _, ok := d.Meta("tcp@127.0.0.1:1"); ok {
+		t.Fatal("expect no metadata for an unknown server")
+	}
+
+	// 事件中携带的元数据同样更新到 Discovery
+	meta.Version = "v3"
+	d.apply(registry.Event{Type: registry.EventUpdate, Server: registry.Registration{Addr: addr, Meta: meta}})
+	if got, _ := d.Meta(addr); got.Version != "v3" {
+		t.Fatalf("expect the updated version, got %+v", got)
+	}
+	d.apply(registry.Event{Type: registry.EventLeave, Server: registry.Registration{Addr: addr}})
+	if _, ok := d.Meta(addr); ok {
+		t.Fatal("expect the metadata to be dropped when the server leaves")
+	}
+}
+
+func TestGeeRegistryServiceDiscovery(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	client := registry.NewClient(ts.URL + "/_geerpc_/registry")
+	_ = client.Register("tcp@127.0.0.1:1", registry.Meta{Services: []string{"Foo"}})
+	_ = client.Register("tcp@127.0.0.1:2", registry.Meta{Services: []string{"Bar"}})
+
+	d := NewGeeRegistryServiceDiscovery(ts.URL+"/_geerpc_/registry", "Foo", time.Minute)
+	servers, err := d.GetAll()
+	if err != nil || !reflect.DeepEqual(servers, []string{"tcp@127.0.0.1:1"}) {
+		t.Fatalf("expect only the server hosting Foo, got %v, %v", servers, err)
+	}
+	_ = client.Register("tcp@127.0.0.1:3", registry.Meta{Services: []string{"Foo", "Bar"}})
+	if err := d.ForceRefresh(); err != nil {
+		t.Fatal(err)
+	}
+	if servers, _ := d.GetAll(); !reflect.DeepEqual(servers, []string{"tcp@127.0.0.1:1", "tcp@127.0.0.1:3"}) {
+		t.Fatalf("expect the new Foo server after refresh, got %v", servers)
+	}
+}
+
+func TestGeeRegistryDiscovery_SetZone(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	client := registry.NewClient(ts.URL + "/_geerpc_/registry")
+	_ = client.Register("tcp@127.0.0.1:1", registry.Meta{Zone: "a", Services: []string{"Foo"}})
+	_ = client.Register("tcp@127.0.0.1:2", registry.Meta{Zone: "b", Services: []string{"Foo"}})
+	_ = client.Register("tcp@127.0.0.1:3", registry.Meta{Zone: "b", Services: []string{"Bar"}})
+
+	d := NewGeeRegistryServiceDiscovery(ts.URL+"/_geerpc_/registry", "Foo", time.Minute)
+	if servers, _ := d.GetAll(); len(servers) != 2 {
+		t.Fatalf("expect both Foo servers before filtering by zone, got %v", servers)
+	}
+	// 更换可用区后立即按新的条件重新拉取，不受刷新间隔和缓存的 ETag 影响
+	d.SetZone("b")
+	servers, err := d.GetAll()
+	if err != nil || !reflect.DeepEqual(servers, []string{"tcp@127.0.0.1:2"}) {
+		t.Fatalf("expect the Foo server in zone b only, got %v, %v", servers, err)
+	}
+}
+
+func TestAggregateDiscovery(t *testing.T) {
+	// 不可用的注册中心作为始终失败的来源
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	failing := NewGeeRegistryDiscovery(dead.URL, time.Minute)
+
+	primary := NewMultiServerDiscovery([]string{"tcp@a", "tcp@b"})
+	primary.SetWeights(map[string]int{"tcp@a": 5})
+	secondary := NewMultiServerDiscovery([]string{"tcp@b", "tcp@c"})
+
+	failover := NewAggregateDiscovery(FailoverSources, failing, primary, secondary)
+	defer failover.Close()
+	if servers, err := failover.GetAll(); err != nil || !reflect.DeepEqual(servers, []string{"tcp@a", "tcp@b"}) {
+		t.Fatalf("expect the first available source only, got %v, %v", servers, err)
+	}
+	if w := failover.Weight("tcp@a"); w != 5 {
+		t.Fatalf("expect the weight from the source, got %d", w)
+	}
+	_ = primary.Update(nil)
+	if servers, _ := failover.GetAll(); !reflect.DeepEqual(servers, []string{"tcp@b", "tcp@c"}) {
+		t.Fatalf("expect to fail over to the next source when the primary is empty, got %v", servers)
+	}
+
+	_ = primary.Update([]string{"tcp@a", "tcp@b"})
+	merge := NewAggregateDiscovery(MergeSources, primary, failing, secondary)
+	defer merge.Close()
+	if servers, err := merge.GetAll(); err != nil || !reflect.DeepEqual(servers, []string{"tcp@a", "tcp@b", "tcp@c"}) {
+		t.Fatalf("expect the merged and deduplicated servers, got %v, %v", servers, err)
+	}
+	if _, err := NewAggregateDiscovery(MergeSources, failing).Get(RandomSelect); err == nil {
+		t.Fatal("expect an error when all sources fail")
+	}
+	if err := merge.Update([]string{"tcp@d"}); err == nil {
+		t.Fatal("expect Update on the aggregate to be rejected")
+	}
+
+	// 任一来源变化时，组合后的列表随之变化并通知等待 Changes 的协程
+	ch := merge.Changes()
+	_ = secondary.Update([]string{"tcp@c", "tcp@d"})
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("expect Changes to fire when a source changes")
+	}
+	if servers, _ := merge.MultiServersDiscovery.GetAll(); !reflect.DeepEqual(servers, []string{"tcp@a", "tcp@b", "tcp@c", "tcp@d"}) {
+		t.Fatalf("expect the change to be aggregated without a call to GetAll, got %v", servers)
+	}
+}
+
+func TestGeeRegistryDiscovery_RefreshEvery(t *testing.T) {
+	ts := httptest.NewServer(registry.New(time.Minute))
+	defer ts.Close()
+	client := registry.NewClient(ts.URL + "/_geerpc_/registry")
+	_ = client.Register("tcp@127.0.0.1:1", registry.Meta{})
+
+	d := NewGeeRegistryDiscovery(ts.URL+"/_geerpc_/registry", time.Hour)
+	cached := func() int {
+		servers, _ := d.MultiServersDiscovery.GetAll()
+		return len(servers)
+	}
+	if servers, _ := d.GetAll(); len(servers) != 1 {
+		t.Fatalf("expect 1 server, got %v", servers)
+	}
+	// 刷新间隔内 Refresh 使用缓存，ForceRefresh 立即拉取
+	_ = client.Register("tcp@127.0.0.1:2", registry.Meta{})
+	if err := d.Refresh(); err != nil || cached() != 1 {
+		t.Fatalf("expect Refresh to keep the cached list, got %d servers, %v", cached(), err)
+	}
+	if err := d.ForceRefresh(); err != nil || cached() != 2 {
+		t.Fatalf("expect ForceRefresh to fetch the new server, got %d servers, %v", cached(), err)
+	}
+
+	// 后台刷新在没有调用时也会更新服务器列表
+	d.RefreshEvery(10 * time.Millisecond)
+	_ = client.Register("tcp@127.0.0.1:3", registry.Meta{})
+	for i := 0; i < 100 && cached() != 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cached() != 3 {
+		t.Fatalf("expect the background refresh to pick up the new server, got %d", cached())
+	}
+	_ = d.Close()
+	time.Sleep(20 * time.Millisecond)
+	_ = client.Register("tcp@127.0.0.1:4", registry.Meta{})
+	time.Sleep(50 * time.Millisecond)
+	if cached() != 3 {
+		t.Fatalf("expect the background refresh to stop after Close, got %d servers", cached())
+	}
+}
+
+// fakeConsul 模拟 Consul 的 /v1/health/service 接口，支持阻塞查询
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	entries []consulEntry
+	changed chan struct{}
+	queries []url.Values
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{index: 1, changed: make(chan struct{})}
+}
+
+// set 更新健康的实例并递增索引，唤醒阻塞查询
+func (c *fakeConsul) set(entries ...consulEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = entries
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/health/service/Foo" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := req.URL.Query()
+	c.mu.Lock()
+	c.queries = append(c.queries, q)
+	index, changed := c.index, c.changed
+	c.mu.Unlock()
+	if i, _ := strconv.ParseUint(q.Get("index"), 10, 64); i >= index {
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	_ = json.NewEncoder(w).Encode(c.entries)
+}
+
+func consulInstance(node, addr string, port int, protocol string) consulEntry {
+	var e consulEntry
+	e.Node.Address = node
+	e.Service.Address = addr
+	e.Service.Port = port
+	if protocol != "" {
+		e.Service.Meta = map[string]string{"protocol": protocol}
+	}
+	return e
+}
+
+func TestConsulDiscovery(t *testing.T) {
+	consul := newFakeConsul()
+	consul.set(consulInstance("10.0.0.1", "", 9999, ""), consulInstance("10.0.0.1", "10.0.0.2", 9998, "http"))
+	ts := httptest.NewServer(consul)
+	defer ts.Close()
+
+	// 服务没有地址时使用节点地址，协议来自服务的元数据，默认为 tcp
+	d := NewConsulDiscovery(ts.URL+"/", "Foo", time.Minute)
+	servers, err := d.GetAll()
+	want := []string{"tcp@10.0.0.1:9999", "http@10.0.0.2:9998"}
+	if err != nil || !reflect.DeepEqual(servers, want) {
+		t.Fatalf("expect %v, but got %v %v", want, servers, err)
+	}
+	consul.mu.Lock()
+	passing := consul.queries[0].Get("passing")
+	consul.mu.Unlock()
+	if passing != "true" {
+		t.Fatal("expect only instances passing health checks to be queried")
+	}
+
+	// Watch 通过阻塞查询立即收到实例的变化
+	d.Watch()
+	defer func() { _ = d.Close() }()
+	time.Sleep(50 * time.Millisecond)
+	ch := d.Changes()
+	consul.set(consulInstance("10.0.0.1", "", 9999, ""))
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("expect Watch to pick up the change before the refresh timeout")
+	}
+	if servers, _ := d.GetAll(); !reflect.DeepEqual(servers, []string{"tcp@10.0.0.1:9999"}) {
+		t.Fatalf("expect the unhealthy instance to be removed, but got %v", servers)
+	}
+}
+
+func TestConsulDiscovery_Error(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	d := NewConsulDiscovery(ts.URL, "Foo", time.Minute)
+	if _, err := d.Get(RandomSelect); err == nil {
+		t.Fatal("expect an error when consul cannot be queried")
+	}
+}
+
+// fakeZK 是内存中的 ZooKeeper，只支持临时节点和子节点监听
+type fakeZK struct {
+	mu       sync.Mutex
+	nodes    map[string][]byte
+	watchers map[string]chan struct{}
+	fail     int // 接下来 ChildrenW 返回错误的次数
+}
+
+var _ registry.ZKConn = (*fakeZK)(nil)
+
+func newFakeZK() *fakeZK {
+	return &fakeZK{nodes: make(map[string][]byte), watchers: make(map[string]chan struct{})}
+}
+
+func (z *fakeZK) CreateEphemeral(p string, data []byte) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if _, ok := z.nodes[p]; ok {
+		return fmt.Errorf("zk: node %s already exists", p)
+	}
+	z.nodes[p] = data
+	z.notify(path.Dir(p))
+	return nil
+}
+
+// expire 模拟会话过期，删除临时节点
+func (z *fakeZK) expire(p string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	delete(z.nodes, p)
+	z.notify(path.Dir(p))
+}
+
+// notify 唤醒 parent 的子节点监听，调用方需持有 z.mu
+func (z *fakeZK) notify(parent string) {
+	if ch, ok := z.watchers[parent]; ok {
+		close(ch)
+		delete(z.watchers, parent)
+	}
+}
+
+func (z *fakeZK) ChildrenW(p string) ([]string, <-chan struct{}, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.fail > 0 {
+		z.fail--
+		return nil, nil, errors.New("zk: connection loss")
+	}
+	var children []string
+	for node := range z.nodes {
+		if path.Dir(node) == p {
+			children = append(children, path.Base(node))
+		}
+	}
+	sort.Strings(children)
+	ch, ok := z.watchers[p]
+	if !ok {
+		ch = make(chan struct{})
+		z.watchers[p] = ch
+	}
+	return children, ch, nil
+}
+
+func TestZKDiscovery(t *testing.T) {
+	zk := newFakeZK()
+	if err := registry.ZKRegister(zk, "/geerpc/Foo/", "tcp@127.0.0.1:9999"); err != nil {
+		t.Fatal(err)
+	}
+	if data := zk.nodes["/geerpc/Foo/tcp@127.0.0.1:9999"]; string(data) != "tcp@127.0.0.1:9999" {
+		t.Fatalf("expect an ephemeral node named after the escaped address, but got %v", zk.nodes)
+	}
+	if err := registry.ZKRegister(zk, "/geerpc/Foo", "tcp@127.0.0.1:9999"); err == nil {
+		t.Fatal("expect an error when the node already exists")
+	}
+
+	d := NewZKDiscovery(zk, "/geerpc/Foo")
+	defer func() { _ = d.Close() }()
+	waitServers := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			servers, _ := d.GetAll()
+			sort.Strings(servers)
+			if reflect.DeepEqual(servers, want) || (len(servers) == 0 && len(want) == 0) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expect servers %v, but got %v", want, servers)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitServers([]string{"tcp@127.0.0.1:9999"})
+
+	// 新节点和过期的节点通过监听立即反映到服务器列表中
+	_ = registry.ZKRegister(zk, "/geerpc/Foo", "unix@/tmp/foo.sock")
+	waitServers([]string{"tcp@127.0.0.1:9999", "unix@/tmp/foo.sock"})
+	zk.expire("/geerpc/Foo/tcp@127.0.0.1:9999")
+	waitServers([]string{"unix@/tmp/foo.sock"})
+}
+
+func TestZKDiscovery_Retry(t *testing.T) {
+	zk := newFakeZK()
+	zk.fail = 1
+	_ = registry.ZKRegister(zk, "/geerpc/Foo", "tcp@127.0.0.1:9999")
+	d := NewZKDiscovery(zk, "/geerpc/Foo")
+	defer func() { _ = d.Close() }()
+	// 第一次读取失败后稍后重试
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if servers, _ := d.GetAll(); len(servers) == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect ZKDiscovery to retry after an error")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// fakeResolver 返回预设的 SRV 和 A/AAAA 记录
+type fakeResolver struct {
+	mu    sync.Mutex
+	srvs  []*net.SRV
+	hosts []string
+	calls int
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if len(r.srvs) == 0 {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return "", r.srvs, nil
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.hosts) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return r.hosts, nil
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	r := &fakeResolver{srvs: []*net.SRV{
+		{Target: "b.example.com.", Port: 9998},
+		{Target: "a.example.com.", Port: 9999},
+	}}
+	d := NewDNSDiscovery("_geerpc._tcp.example.com", 7000, time.Minute)
+	d.resolver = r
+
+	// 优先使用 SRV 记录中的主机和端口
+	servers, err := d.GetAll()
+	want := []string{"tcp@a.example.com:9999", "tcp@b.example.com:9998"}
+	if err != nil || !reflect.DeepEqual(servers, want) {
+		t.Fatalf("expect %v, but got %v %v", want, servers, err)
+	}
+	// 刷新间隔内不重新解析
+	_, _ = d.Get(RandomSelect)
+	if r.calls != 1 {
+		t.Fatalf("expect 1 lookup within the refresh interval, but got %d", r.calls)
+	}
+
+	// 没有 SRV 记录时回退为 A/AAAA 记录和固定端口
+	r.mu.Lock()
+	r.srvs, r.hosts = nil, []string{"10.0.0.2", "::1"}
+	r.mu.Unlock()
+	d.lastUpdate = time.Time{}
+	servers, err = d.GetAll()
+	want = []string{"tcp@10.0.0.2:7000", "tcp@[::1]:7000"}
+	if err != nil || !reflect.DeepEqual(servers, want) {
+		t.Fatalf("expect %v, but got %v %v", want, servers, err)
+	}
+}
+
+func TestDNSDiscovery_Errors(t *testing.T) {
+	d := NewDNSDiscovery("geerpc.example.com", 0, time.Minute)
+	d.resolver = &fakeResolver{hosts: []string{"10.0.0.1"}}
+	if _, err := d.GetAll(); err == nil {
+		t.Fatal("expect an error without SRV records and without a fallback port")
+	}
+	d = NewDNSDiscovery("geerpc.example.com", 7000, time.Minute)
+	d.resolver = &fakeResolver{}
+	if _, err := d.Get(RandomSelect); err == nil {
+		t.Fatal("expect an error when the name does not resolve")
+	}
+}
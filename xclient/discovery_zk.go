@@ -0,0 +1,84 @@
+package xclient
+
+import (
+	"geerpc"
+	"geerpc/registry"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ZKDiscovery 是一个基于 ZooKeeper 的服务发现实现。
+// 每个服务器在 root 下创建一个以 url.PathEscape(rpcAddr) 命名的临时节点，
+// ZKDiscovery 监听 root 的子节点变化并更新服务器列表
+type ZKDiscovery struct {
+	*MultiServersDiscovery
+	conn registry.ZKConn
+	root string
+	stop chan struct{}
+}
+
+// NewZKDiscovery 创建一个 ZKDiscovery 实例，并启动后台协程监听 root 的子节点变化
+func NewZKDiscovery(conn registry.ZKConn, root string) *ZKDiscovery {
+	d := &ZKDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		conn:                  conn,
+		root:                  strings.TrimSuffix(root, "/"),
+		stop:                  make(chan struct{}),
+	}
+	go d.watch()
+	return d
+}
+
+// Refresh 立即从 ZooKeeper 读取一次子节点列表
+func (d *ZKDiscovery) Refresh() error {
+	_, err := d.refresh()
+	return err
+}
+
+// Close 停止监听
+func (d *ZKDiscovery) Close() error {
+	select {
+	case <-d.stop:
+	default:
+		close(d.stop)
+	}
+	return nil
+}
+
+// watch 在子节点变化时重新读取服务器列表，出错时稍后重试
+func (d *ZKDiscovery) watch() {
+	for {
+		changed, err := d.refresh()
+		if err != nil {
+			geerpc.DefaultLogger().Error("rpc registry: zookeeper watch err", "err", err)
+			select {
+			case <-d.stop:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		select {
+		case <-d.stop:
+			return
+		case <-changed:
+		}
+	}
+}
+
+// refresh 读取子节点列表并更新服务器列表，返回下一次变化的通知 channel
+func (d *ZKDiscovery) refresh() (<-chan struct{}, error) {
+	children, changed, err := d.conn.ChildrenW(d.root)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]string, 0, len(children))
+	for _, child := range children {
+		if addr, err := url.PathUnescape(child); err == nil && addr != "" {
+			servers = append(servers, addr)
+		}
+	}
+	_ = d.Update(servers)
+	return changed, nil
+}
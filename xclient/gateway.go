@@ -0,0 +1,238 @@
+package xclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	. "geerpc"
+	"geerpc/codec"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGatewayPath 是 Gateway 处理的默认路径前缀
+const DefaultGatewayPath = "/rpc/"
+
+// maxGatewayBody 是网关请求体的最大字节数
+const maxGatewayBody = 4 << 20
+
+// Gateway 将 HTTP/JSON 请求转换为通过 XClient 发起的 RPC 调用，使浏览器和 curl 无需专门的客户端即可调用服务：
+//
+//	POST /rpc/{Service}/{Method}
+//	Content-Type: application/json
+//
+//	{"Num1": 1, "Num2": 2}
+//
+// 请求体按方法的参数类型解码（为空时使用零值），返回值编码为 JSON 返回；调用失败时返回 {"error": "..."}。
+// Authorization: Bearer 头作为单次调用的凭证转发（参见 WithCredentials），X-Request-ID 头作为请求 ID 转发。
+// 网关只知道通过 Register 登记的服务，服务本身运行在 XClient 发现的服务器上。
+// GET {prefix}openapi.json 返回描述这些方法的 OpenAPI 文档（参见 OpenAPI）
+type Gateway struct {
+	xc      *XClient
+	prefix  string
+	timeout time.Duration // 单次调用的超时时间，0 表示只受 HTTP 请求的 context 限制
+	title   string        // OpenAPI 文档的标题
+	version string        // OpenAPI 文档的版本
+	raw     bool          // 是否直接转发请求体和返回值，参见 SetRawForwarding
+
+	mu      sync.RWMutex
+	methods map[string]MethodDesc // "Service.Method" -> 方法描述
+}
+
+// NewGateway 创建一个使用 xc 发起调用的 Gateway，处理 DefaultGatewayPath 下的请求
+func NewGateway(xc *XClient) *Gateway {
+	return &Gateway{xc: xc, prefix: DefaultGatewayPath, methods: make(map[string]MethodDesc)}
+}
+
+// SetPrefix 设置网关处理的路径前缀，例如 "/api/"，应在开始服务之前调用
+func (g *Gateway) SetPrefix(prefix string) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	g.prefix = prefix
+}
+
+// SetTimeout 设置单次调用的超时时间，0 表示不限制，应在开始服务之前调用
+func (g *Gateway) SetTimeout(d time.Duration) {
+	g.timeout = d
+}
+
+// SetRawForwarding 设置是否直接转发请求体和返回值：开启后，如果 XClient 使用 JSON 编解码器，
+// 请求体只检查是否为合法的 JSON，然后原样作为参数转发给服务器，服务器返回的 JSON 也原样写入 HTTP 响应，
+// 省去网关按方法类型解码和重新编码的开销。参数与方法类型不匹配时由服务器报告错误（502 而不是 400），
+// 返回值中空的映射和切片编码为 null。gob 的消息体依赖连接上先前发送的类型信息，无法直接转发，
+// 因此使用 gob 时该设置不生效。应在开始服务之前调用
+func (g *Gateway) SetRawForwarding(enable bool) {
+	g.raw = enable
+}
+
+// Register 登记 rcvr 的所有 RPC 方法，rcvr 与服务端传给 Server.Register 的类型相同，
+// 网关只使用它的类型信息构造参数和返回值，不会调用它的方法
+func (g *Gateway) Register(rcvr interface{}) error {
+	methods, err := DescribeService(rcvr)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range methods {
+		g.methods[m.ServiceMethod] = m
+	}
+	return nil
+}
+
+// Methods 返回所有已登记的方法名，形如 "Service.Method"，按名称排序
+func (g *Gateway) Methods() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	names := make([]string, 0, len(g.methods))
+	for name := range g.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gatewayError 是调用失败时返回的 JSON
+type gatewayError struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP 实现了 http.Handler 接口
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet && req.URL.Path == g.prefix+openAPIPath {
+		g.serveOpenAPI(w)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGatewayError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, g.prefix)
+	slash := strings.Index(path, "/")
+	if path == req.URL.Path || slash <= 0 || slash == len(path)-1 || strings.Contains(path[slash+1:], "/") {
+		writeGatewayError(w, http.StatusNotFound, "expect "+g.prefix+"{Service}/{Method}")
+		return
+	}
+	serviceMethod := path[:slash] + "." + path[slash+1:]
+	g.mu.RLock()
+	m, ok := g.methods[serviceMethod]
+	g.mu.RUnlock()
+	if !ok {
+		writeGatewayError(w, http.StatusNotFound, "can't find method "+serviceMethod)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxGatewayBody+1))
+	if err != nil {
+		writeGatewayError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body) > maxGatewayBody {
+		writeGatewayError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	var args, reply interface{}
+	var rawReply json.RawMessage
+	raw := g.rawForwarding()
+	if raw {
+		if len(bytes.TrimSpace(body)) == 0 {
+			body = []byte("null") // 服务器将 null 解码为参数的零值
+		} else if !json.Valid(body) {
+			writeGatewayError(w, http.StatusBadRequest, "invalid arguments: malformed JSON")
+			return
+		}
+		args, reply = json.RawMessage(body), &rawReply
+	} else {
+		argv := newValue(m.ArgType)
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if err := json.Unmarshal(body, argv.Interface()); err != nil {
+				writeGatewayError(w, http.StatusBadRequest, "invalid arguments: "+err.Error())
+				return
+			}
+		}
+		args = argv.Interface()
+		if m.ArgType.Kind() != reflect.Ptr {
+			args = argv.Elem().Interface()
+		}
+		reply = newValue(m.ReplyType.Elem()).Interface()
+	}
+
+	ctx := req.Context()
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		ctx = WithCredentials(ctx, strings.TrimPrefix(auth, "Bearer "))
+	}
+	if id := req.Header.Get("X-Request-ID"); id != "" {
+		ctx = WithRequestID(ctx, id)
+	}
+	if err := g.xc.Call(ctx, serviceMethod, args, reply); err != nil {
+		writeGatewayError(w, gatewayStatus(ctx, err), err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if raw {
+		_, _ = w.Write(append(rawReply, '\n'))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// rawForwarding 判断是否直接转发消息体，只有 XClient 使用 JSON 编解码器时才可以
+func (g *Gateway) rawForwarding() bool {
+	return g.raw && g.xc.opt != nil && g.xc.opt.CodecType == codec.JsonType
+}
+
+// newValue 返回指向 t 类型零值的指针，映射和切片会被初始化为空值，使其编码为 {} 和 []
+func newValue(t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	switch t.Kind() {
+	case reflect.Map:
+		v.Elem().Set(reflect.MakeMap(t))
+	case reflect.Slice:
+		v.Elem().Set(reflect.MakeSlice(t, 0, 0))
+	}
+	return v
+}
+
+// gatewayStatus 返回调用错误对应的 HTTP 状态码
+func gatewayStatus(ctx context.Context, err error) int {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrAllBreakersOpen):
+		return http.StatusServiceUnavailable
+	}
+	// 服务端返回的错误只保留了错误信息，按信息匹配
+	switch err.Error() {
+	case ErrUnauthenticated.Error():
+		return http.StatusUnauthorized
+	case ErrPermissionDenied.Error():
+		return http.StatusForbidden
+	case ErrQuotaExceeded.Error():
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadGateway
+}
+
+// writeGatewayError 以 JSON 格式返回错误
+func writeGatewayError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(gatewayError{Error: msg})
+}
@@ -0,0 +1,119 @@
+package xclient
+
+import (
+	"encoding/json"
+	"errors"
+	"geerpc"
+	"geerpc/codec"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Arith int
+
+type ArithArgs struct{ Num1, Num2 int }
+
+func (a Arith) Sum(args ArithArgs, reply *int) error {
+	*reply = args.Num1 + args.Num2
+	return nil
+}
+
+func (a Arith) Fail(args ArithArgs, reply *int) error {
+	return errors.New("always fails")
+}
+
+func TestGateway(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := geerpc.NewServer()
+	_ = server.Register(new(Arith))
+	go server.Accept(l)
+
+	xc := NewXClient(NewMultiServerDiscovery([]string{"tcp@" + l.Addr().String()}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	gw := NewGateway(xc)
+	if err := gw.Register(new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(gw)
+	defer ts.Close()
+
+	cases := []struct {
+		path, body string
+		status     int
+		expect     string
+	}{
+		{"/rpc/Arith/Sum", `{"Num1": 1, "Num2": 2}`, http.StatusOK, "3"},
+		{"/rpc/Arith/Sum", ``, http.StatusOK, "0"},
+		{"/rpc/Arith/Sum", `{"Num1": "x"}`, http.StatusBadRequest, "invalid arguments"},
+		{"/rpc/Arith/Fail", `{}`, http.StatusBadGateway, "always fails"},
+		{"/rpc/Arith/Missing", `{}`, http.StatusNotFound, "can't find method"},
+		{"/rpc/Arith", `{}`, http.StatusNotFound, "expect"},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(ts.URL+c.path, "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != c.status || !strings.Contains(buf.String(), c.expect) {
+			t.Fatalf("%s %s: expect %d %q, but got %d %q", c.path, c.body, c.status, c.expect, resp.StatusCode, buf.String())
+		}
+	}
+	resp, err := http.Get(ts.URL + "/rpc/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var doc struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil || doc.Paths["/rpc/Arith/Sum"] == nil {
+		t.Fatalf("expect openapi document with /rpc/Arith/Sum, but got %v %v", doc.Paths, err)
+	}
+}
+
+func TestGateway_RawForwarding(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := geerpc.NewServer()
+	_ = server.Register(new(Arith))
+	go server.Accept(l)
+
+	xc := NewXClient(NewMultiServerDiscovery([]string{"tcp@" + l.Addr().String()}), RandomSelect, &geerpc.Option{CodecType: codec.JsonType})
+	defer func() { _ = xc.Close() }()
+	gw := NewGateway(xc)
+	gw.SetRawForwarding(true)
+	if err := gw.Register(new(Arith)); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(gw)
+	defer ts.Close()
+
+	cases := []struct {
+		body   string
+		status int
+		expect string
+	}{
+		{`{"Num1": 1, "Num2": 2}`, http.StatusOK, "3"},
+		{``, http.StatusOK, "0"},
+		{`{"Num1": `, http.StatusBadRequest, "malformed JSON"},
+		{`{"Num1": "x"}`, http.StatusBadGateway, "cannot unmarshal"},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(ts.URL+"/rpc/Arith/Sum", "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != c.status || !strings.Contains(buf.String(), c.expect) {
+			t.Fatalf("%s: expect %d %q, but got %d %q", c.body, c.status, c.expect, resp.StatusCode, buf.String())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package xclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PickHook 在 XClient 选定后端后被调用，用于记录或追踪选择该后端的原因。
+// candidates 是 Discovery 返回的全部候选服务器，reason 描述了选择模式、权重和被跳过的后端
+type PickHook func(serviceMethod, chosen string, candidates []string, reason string)
+
+// OnPick 设置选择后端时的回调，传入 nil 表示取消
+func (xc *XClient) OnPick(hook PickHook) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.pickHook = hook
+}
+
+// notifyPick 在设置了 PickHook 时构造选择原因并调用它
+func (xc *XClient) notifyPick(d Discovery, serviceMethod, chosen string, candidates, skipped []string) {
+	xc.mu.Lock()
+	hook := xc.pickHook
+	xc.mu.Unlock()
+	if hook == nil {
+		return
+	}
+	reason := "mode=" + xc.mode.String()
+	if w, ok := d.(interface{ Weight(string) int }); ok {
+		reason += fmt.Sprintf(" weight=%d", w.Weight(chosen))
+	}
+	if len(skipped) > 0 {
+		reason += " skipped(circuit-open)=" + strings.Join(skipped, ",")
+	}
+	hook(serviceMethod, chosen, candidates, reason)
+}
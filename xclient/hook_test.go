@@ -0,0 +1,73 @@
+package xclient
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXClient_OnPick(t *testing.T) {
+	live := startArith(t)
+	dead := "tcp@127.0.0.1:1" // 拒绝连接
+	d := NewMultiServerDiscovery([]string{dead, live})
+	d.SetWeights(map[string]int{live: 3})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1})
+
+	type pick struct {
+		method, chosen string
+		candidates     []string
+		reason         string
+	}
+	var picks []pick
+	xc.OnPick(func(serviceMethod, chosen string, candidates []string, reason string) {
+		picks = append(picks, pick{serviceMethod, chosen, candidates, reason})
+	})
+
+	// 轮询依次选中两台服务器，连接失败使 dead 的熔断器打开
+	var reply int
+	for i := 0; i < 2; i++ {
+		_ = xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply)
+	}
+	if len(picks) != 2 {
+		t.Fatalf("expect the hook to be called for every pick, got %d", len(picks))
+	}
+	for _, p := range picks {
+		if p.method != "Arith.Sum" || !reflect.DeepEqual(p.candidates, []string{dead, live}) {
+			t.Fatalf("expect method and candidates to be reported, got %+v", p)
+		}
+		// dead 先被选中时，它的熔断器在第二次选择前已经打开
+		if p.chosen == live && !strings.HasPrefix(p.reason, "mode=RoundRobinSelect weight=3") {
+			t.Fatalf("expect mode and weight in the reason, got %q", p.reason)
+		}
+	}
+
+	// 熔断器打开后，被跳过的后端出现在选择原因中
+	picks = nil
+	for i := 0; i < 2; i++ {
+		if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	skipped := false
+	for _, p := range picks {
+		if p.chosen != live {
+			t.Fatalf("expect only %s to be chosen once %s is circuit-open, got %s", live, dead, p.chosen)
+		}
+		skipped = skipped || strings.HasSuffix(p.reason, " skipped(circuit-open)="+dead)
+	}
+	if !skipped {
+		t.Fatalf("expect %s to be reported as skipped, got %+v", dead, picks)
+	}
+
+	// 传入 nil 取消回调
+	xc.OnPick(nil)
+	picks = nil
+	_ = xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply)
+	if len(picks) != 0 {
+		t.Fatalf("expect no picks to be reported after OnPick(nil), got %d", len(picks))
+	}
+}
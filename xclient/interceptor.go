@@ -0,0 +1,31 @@
+package xclient
+
+import "context"
+
+// Invoker 执行一次 XClient 调用，包括选择后端和发起调用
+type Invoker func(ctx context.Context, serviceMethod string, args, reply interface{}) error
+
+// Interceptor 拦截 XClient 的调用，可以在调用 next 前后加入额外逻辑，
+// 例如统一的追踪标签、鉴权或故障注入，且不受最终选中哪个后端的影响
+type Interceptor func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error
+
+// Use 追加拦截器，先追加的拦截器位于调用链的外层
+func (xc *XClient) Use(interceptors ...Interceptor) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.interceptors = append(xc.interceptors, interceptors...)
+}
+
+// chain 使用已注册的拦截器包装 invoker
+func (xc *XClient) chain(invoker Invoker) Invoker {
+	xc.mu.Lock()
+	interceptors := xc.interceptors
+	xc.mu.Unlock()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+			return interceptor(ctx, serviceMethod, args, reply, next)
+		}
+	}
+	return invoker
+}
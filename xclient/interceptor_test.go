@@ -0,0 +1,62 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestXClient_Use(t *testing.T) {
+	addr := startArith(t)
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+			order = append(order, name+" "+serviceMethod)
+			err := next(ctx, serviceMethod, args, reply)
+			order = append(order, name+" done")
+			return err
+		}
+	}
+	xc.Use(trace("outer"), trace("inner"))
+
+	var reply int
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, err %v", reply, err)
+	}
+	want := []string{"outer Arith.Sum", "inner Arith.Sum", "inner done", "outer done"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("expect interceptors to run in order %v, got %v", want, order)
+	}
+
+	// 广播调用同样经过拦截器
+	order = nil
+	if err := xc.Broadcast(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("expect Broadcast to go through the interceptors, got %v", order)
+	}
+}
+
+func TestXClient_UseShortCircuit(t *testing.T) {
+	addr := startArith(t)
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	// 故障注入：不调用 next 时不会选择后端，也不会发起调用
+	injected := errors.New("injected fault")
+	xc.Use(func(ctx context.Context, serviceMethod string, args, reply interface{}, next Invoker) error {
+		return injected
+	})
+	var reply int
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != injected {
+		t.Fatalf("expect the injected error, got %v", err)
+	}
+	if stats := xc.Stats(); len(stats) != 0 {
+		t.Fatalf("expect no backend to be called, got %v", stats)
+	}
+}
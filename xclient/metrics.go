@@ -0,0 +1,49 @@
+package xclient
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// WriteMetrics 以 Prometheus 文本格式输出每个后端的熔断器状态和剔除次数，以及缓存的客户端连接数。
+// 设置了 Option.Metrics 时，NewXClient 会将 XClient 注册到其中，Close 时取消注册
+func (xc *XClient) WriteMetrics(w io.Writer) {
+	xc.mu.Lock()
+	clients := len(xc.clients)
+	xc.mu.Unlock()
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_xclient_clients Number of cached client connections.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_xclient_clients gauge\n")
+	_, _ = fmt.Fprintf(w, "geerpc_xclient_clients %d\n", clients)
+
+	states := make(map[string]breakerState)
+	xc.breakerMu.Lock()
+	for addr, b := range xc.breakers {
+		b.mu.Lock()
+		states[addr] = b.state
+		b.mu.Unlock()
+	}
+	xc.breakerMu.Unlock()
+	addrs := make([]string, 0, len(states))
+	for addr := range states {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_xclient_breaker_state Circuit breaker state by target (0 closed, 1 open, 2 half-open).\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_xclient_breaker_state gauge\n")
+	for _, addr := range addrs {
+		_, _ = fmt.Fprintf(w, "geerpc_xclient_breaker_state{target=%q} %d\n", addr, states[addr])
+	}
+
+	stats := xc.Stats()
+	addrs = addrs[:0]
+	for addr := range stats {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	_, _ = fmt.Fprintf(w, "# HELP geerpc_xclient_ejections_total Cached clients ejected as unavailable, by target.\n")
+	_, _ = fmt.Fprintf(w, "# TYPE geerpc_xclient_ejections_total counter\n")
+	for _, addr := range addrs {
+		_, _ = fmt.Fprintf(w, "geerpc_xclient_ejections_total{target=%q} %d\n", addr, stats[addr].Ejections)
+	}
+}
@@ -0,0 +1,210 @@
+package xclient
+
+import (
+	"encoding/json"
+	. "geerpc"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPIPath 是网关提供 OpenAPI 文档的路径，相对于网关的路径前缀
+const openAPIPath = "openapi.json"
+
+// SetAPIInfo 设置 OpenAPI 文档中的标题和版本，默认为 "geerpc gateway" 和 "1.0.0"，应在开始服务之前调用
+func (g *Gateway) SetAPIInfo(title, version string) {
+	g.title, g.version = title, version
+}
+
+// OpenAPI 返回描述所有已登记方法的 OpenAPI 3.0 文档（JSON 格式）。每个方法对应一个 POST 操作，
+// 请求体和响应体的结构由参数和返回值的类型生成：字段名遵循 encoding/json 的规则（包括 json 标签），
+// 具名的结构体类型放在 components.schemas 中。网关同时在 GET {prefix}openapi.json 上提供该文档，
+// 登记的服务变化后文档随之变化
+func (g *Gateway) OpenAPI() ([]byte, error) {
+	g.mu.RLock()
+	methods := make([]MethodDesc, 0, len(g.methods))
+	for _, m := range g.methods {
+		methods = append(methods, m)
+	}
+	g.mu.RUnlock()
+
+	title, version := g.title, g.version
+	if title == "" {
+		title = "geerpc gateway"
+	}
+	if version == "" {
+		version = "1.0.0"
+	}
+	s := newSchemaBuilder()
+	s.components["Error"] = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+	}
+	paths := make(map[string]interface{}, len(methods))
+	errorSchema := map[string]interface{}{"$ref": "#/components/schemas/Error"}
+	for _, m := range methods {
+		dot := strings.Index(m.ServiceMethod, ".")
+		service, method := m.ServiceMethod[:dot], m.ServiceMethod[dot+1:]
+		paths[g.prefix+service+"/"+method] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": m.ServiceMethod,
+				"tags":        []string{service},
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": s.schema(m.ArgType)},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": s.schema(m.ReplyType.Elem())},
+						},
+					},
+					"default": map[string]interface{}{
+						"description": "call failed",
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": errorSchema},
+						},
+					},
+				},
+			},
+		}
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": title, "version": version},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": s.components},
+	}, "", "  ")
+}
+
+// serveOpenAPI 返回 OpenAPI 文档
+func (g *Gateway) serveOpenAPI(w http.ResponseWriter) {
+	doc, err := g.OpenAPI()
+	if err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(doc)
+}
+
+// schemaBuilder 根据 Go 类型生成 JSON Schema，具名结构体生成到 components 中并通过 $ref 引用
+type schemaBuilder struct {
+	components map[string]interface{}
+	names      map[reflect.Type]string // 已生成的结构体类型对应的组件名
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: make(map[string]interface{}), names: make(map[reflect.Type]string)}
+}
+
+var (
+	typeOfTime           = reflect.TypeOf(time.Time{})
+	typeOfJSONMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfRawJSONMessage = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema 返回类型 t 的 JSON Schema
+func (s *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeOfTime:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == typeOfRawJSONMessage, t.Implements(typeOfJSONMarshaler), reflect.PtrTo(t).Implements(typeOfJSONMarshaler):
+		return map[string]interface{}{} // 自定义编码，无法推断结构
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return map[string]interface{}{"type": "string", "format": "byte"} // encoding/json 将 []byte 编码为 base64
+		}
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+	return map[string]interface{}{} // interface{} 等任意类型
+}
+
+// component 为具名结构体生成组件并返回组件名，不同包中的同名类型使用包名区分
+func (s *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	s.names[t] = name
+	s.components[name] = nil // 先占位，支持递归类型
+	s.components[name] = s.structSchema(t)
+	return name
+}
+
+// structSchema 按照 encoding/json 的规则生成结构体的字段，匿名嵌入的结构体字段会被展开
+func (s *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	s.addFields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (s *schemaBuilder) addFields(t reflect.Type, props map[string]interface{}) {
+	// 外层的字段优先于嵌入结构体中的同名字段，因此先处理外层字段
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // 未导出的字段
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+	}
+	for _, et := range embedded {
+		inner := make(map[string]interface{})
+		s.addFields(et, inner)
+		for name, schema := range inner {
+			if _, ok := props[name]; !ok {
+				props[name] = schema
+			}
+		}
+	}
+}
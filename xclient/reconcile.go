@@ -0,0 +1,64 @@
+package xclient
+
+// watch 等待 d 的服务器列表变化，每次变化后调整缓存的客户端，直到 stop 被关闭或 XClient 关闭
+func (xc *XClient) watch(d Discovery, stop <-chan struct{}) {
+	ch := d.Changes()
+	for {
+		select {
+		case <-ch:
+		case <-stop:
+			return
+		case <-xc.done:
+			return
+		}
+		// 先取得下一次变化的 channel，再读取服务器列表，避免遗漏调整期间发生的变化
+		ch = d.Changes()
+		xc.reconcile()
+	}
+}
+
+// reconcile 根据所有 Discovery 最新的服务器列表，主动关闭已被移除的服务器的客户端并丢弃它们的统计和熔断器，
+// 并在后台预先连接新加入的服务器，而不是等到调用失败时才发现变化
+func (xc *XClient) reconcile() {
+	xc.mu.Lock()
+	ds := []Discovery{xc.d}
+	for _, d := range xc.services {
+		ds = append(ds, d)
+	}
+	xc.mu.Unlock()
+
+	alive := make(map[string]bool)
+	for _, d := range ds {
+		servers, err := d.GetAll()
+		if err != nil {
+			return // 无法确定完整的服务器列表时不做调整，避免误关连接
+		}
+		for _, addr := range servers {
+			alive[addr] = true
+		}
+	}
+
+	xc.mu.Lock()
+	if xc.closing {
+		xc.mu.Unlock()
+		return
+	}
+	for addr, client := range xc.clients {
+		if !alive[addr] {
+			_ = client.Close()
+			delete(xc.clients, addr)
+		}
+	}
+	var added []string
+	for addr := range alive {
+		if _, ok := xc.clients[addr]; !ok {
+			added = append(added, addr)
+		}
+	}
+	xc.mu.Unlock()
+	xc.pruneStats(alive)
+	xc.pruneBreakers(alive)
+	for _, addr := range added {
+		go func(addr string) { _, _ = xc.dial(addr) }(addr)
+	}
+}
@@ -0,0 +1,90 @@
+package xclient
+
+import (
+	"sync"
+	"time"
+)
+
+// ewmaWeight 是计算延迟指数加权移动平均时新样本所占的权重
+const ewmaWeight = 0.2
+
+// BackendStats 是单个后端（rpcAddr）调用统计的快照
+type BackendStats struct {
+	Requests   uint64        // 发往该后端的请求数
+	Errors     uint64        // 失败的请求数（包括建立连接失败）
+	Latency    time.Duration // 调用耗时的指数加权移动平均（EWMA）
+	Ejections  uint64        // 缓存的客户端因不可用被剔除的次数
+	LastUpdate time.Time     // 最近一次更新统计的时间
+}
+
+// backendStats 记录单个后端的统计数据
+type backendStats struct {
+	mu    sync.Mutex // 保护以下字段
+	stats BackendStats
+}
+
+// observe 记录一次调用的耗时和结果
+func (s *backendStats) observe(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Requests++
+	if err != nil {
+		s.stats.Errors++
+	}
+	if s.stats.Latency == 0 {
+		s.stats.Latency = d
+	} else {
+		s.stats.Latency = time.Duration(ewmaWeight*float64(d) + (1-ewmaWeight)*float64(s.stats.Latency))
+	}
+	s.stats.LastUpdate = time.Now()
+}
+
+// eject 记录一次剔除
+func (s *backendStats) eject() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Ejections++
+	s.stats.LastUpdate = time.Now()
+}
+
+// snapshot 返回统计数据的副本
+func (s *backendStats) snapshot() BackendStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// backend 返回 rpcAddr 对应的统计对象，不存在时创建
+func (xc *XClient) backend(rpcAddr string) *backendStats {
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	s, ok := xc.stats[rpcAddr]
+	if !ok {
+		s = new(backendStats)
+		xc.stats[rpcAddr] = s
+	}
+	return s
+}
+
+// pruneStats 删除已不在服务发现中的后端的统计，避免统计随后端的变动无限增长
+func (xc *XClient) pruneStats(alive map[string]bool) {
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	for addr := range xc.stats {
+		if !alive[addr] {
+			delete(xc.stats, addr)
+		}
+	}
+}
+
+// Stats 返回每个后端（以 rpcAddr 为键）的调用统计，
+// 用于定位负载均衡问题来自哪个后端
+func (xc *XClient) Stats() map[string]BackendStats {
+	xc.statsMu.Lock()
+	defer xc.statsMu.Unlock()
+	stats := make(map[string]BackendStats, len(xc.stats))
+	for addr, s := range xc.stats {
+		stats[addr] = s.snapshot()
+	}
+	return stats
+}
@@ -0,0 +1,143 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"geerpc"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackendStats_Observe(t *testing.T) {
+	var s backendStats
+	s.observe(100*time.Millisecond, nil)
+	s.observe(200*time.Millisecond, errors.New("failed"))
+	s.eject()
+	got := s.snapshot()
+	if got.Requests != 2 || got.Errors != 1 || got.Ejections != 1 {
+		t.Fatalf("expect 2 requests, 1 error and 1 ejection, got %+v", got)
+	}
+	// 第一个样本直接作为初始值，之后按 ewmaWeight 加权：0.2*200ms + 0.8*100ms
+	if got.Latency != 120*time.Millisecond {
+		t.Fatalf("expect EWMA latency 120ms, got %v", got.Latency)
+	}
+	if got.LastUpdate.IsZero() {
+		t.Fatal("expect LastUpdate to be set")
+	}
+}
+
+func TestXClient_Stats(t *testing.T) {
+	addr := startArith(t)
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var reply int
+	for i := 0; i < 2; i++ {
+		if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := xc.Call(context.Background(), "Arith.Fail", ArithArgs{}, &reply); err == nil {
+		t.Fatal("expect Arith.Fail to fail")
+	}
+
+	// 缓存的客户端不可用时被剔除并重新建立连接
+	xc.mu.Lock()
+	_ = xc.clients[addr].Close()
+	xc.mu.Unlock()
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := xc.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expect stats for 1 backend, got %d", len(stats))
+	}
+	got := stats[addr]
+	if got.Requests != 4 || got.Errors != 1 || got.Ejections != 1 {
+		t.Fatalf("expect 4 requests, 1 error and 1 ejection for %s, got %+v", addr, got)
+	}
+	if got.Latency <= 0 {
+		t.Fatalf("expect a positive latency, got %v", got.Latency)
+	}
+}
+
+func TestXClient_StatsPruned(t *testing.T) {
+	addr1, addr2 := startArith(t), startArith(t)
+	d := NewMultiServerDiscovery([]string{addr1, addr2})
+	xc := NewXClient(d, RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+
+	var reply int
+	for i := 0; i < 2; i++ {
+		if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := xc.Stats(); len(stats) != 2 {
+		t.Fatalf("expect stats for 2 backends, got %v", stats)
+	}
+
+	// 离开服务发现的后端的统计和熔断器被删除
+	_ = d.Update([]string{addr1})
+	deadline := time.Now().Add(time.Second)
+	for {
+		xc.breakerMu.Lock()
+		_, hasBreaker := xc.breakers[addr2]
+		xc.breakerMu.Unlock()
+		_, hasStats := xc.Stats()[addr2]
+		if !hasBreaker && !hasStats {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %s to be pruned, stats %v", addr2, xc.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := xc.Stats()[addr1]; !ok {
+		t.Fatalf("expect stats for %s to be kept", addr1)
+	}
+}
+
+func TestXClient_WriteMetrics(t *testing.T) {
+	addr := startArith(t)
+	metrics := geerpc.NewClientMetrics()
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, &geerpc.Option{Metrics: metrics})
+	var reply int
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	b := xc.breaker(addr)
+	b.mu.Lock()
+	b.state = breakerOpen
+	b.mu.Unlock()
+
+	body := scrapeMetrics(metrics)
+	for _, line := range []string{
+		fmt.Sprintf("geerpc_client_requests_total{target=%q,method=\"Arith.Sum\"} 1", strings.TrimPrefix(addr, "tcp@")),
+		"geerpc_xclient_clients 1",
+		fmt.Sprintf("geerpc_xclient_breaker_state{target=%q} 1", addr),
+		fmt.Sprintf("geerpc_xclient_ejections_total{target=%q} 0", addr),
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expect %q in the metrics:\n%s", line, body)
+		}
+	}
+
+	// Close 之后不再输出 XClient 的指标
+	_ = xc.Close()
+	if body := scrapeMetrics(metrics); strings.Contains(body, "geerpc_xclient_") {
+		t.Fatalf("expect the XClient to be unregistered on Close:\n%s", body)
+	}
+}
+
+// scrapeMetrics 读取 h 输出的指标
+func scrapeMetrics(h http.Handler) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
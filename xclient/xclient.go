@@ -2,19 +2,44 @@ package xclient
 
 import (
 	"context"
+	"fmt"
 	. "geerpc" // 引入 geerpc 包
+	"geerpc/registry"
 	"io"
+	"math/rand"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // XClient 定义了一个支持负载均衡的 RPC 客户端
 type XClient struct {
-	d       Discovery
-	mode    SelectMode
-	opt     *Option
-	mu      sync.Mutex // 用于保护以下字段
-	clients map[string]*Client
+	d            Discovery
+	mode         SelectMode
+	opt          *Option
+	mu           sync.Mutex // 用于保护以下字段
+	clients      map[string]*Client
+	interceptors []Interceptor
+	services     map[string]Discovery     // 按服务名划分的服务发现，未设置的服务使用 d
+	watchers     map[string]chan struct{} // 关闭后停止监听 services 中对应 Discovery 的变化
+	pickHook     PickHook
+	closing      bool                 // 调用了 CloseGraceful，不再接受新的调用
+	inflight     sync.WaitGroup       // 正在进行的调用
+	done         chan struct{}        // XClient 关闭后关闭，用于停止监听服务器列表的变化
+	dialing      map[string]*dialCall // 正在建立的连接
+	rrIndex      uint32               // 部分后端被熔断时轮询选择的位置
+
+	broadcastLimit  int // 广播调用的最大并发数，0 表示不限制
+	broadcastPolicy BroadcastPolicy
+
+	statsMu sync.Mutex // 保护 stats
+	stats   map[string]*backendStats
+
+	breakerMu  sync.Mutex // 保护以下字段
+	breakerCfg BreakerConfig
+	breakers   map[string]*breaker
 }
 
 // 实现 io.Closer 接口
@@ -22,13 +47,43 @@ var _ io.Closer = (*XClient)(nil)
 
 // NewXClient 创建一个新的 XClient 实例
 func NewXClient(d Discovery, mode SelectMode, opt *Option) *XClient {
-	return &XClient{d: d, mode: mode, opt: opt, clients: make(map[string]*Client)}
+	xc := &XClient{
+		d:        d,
+		mode:     mode,
+		opt:      opt,
+		clients:  make(map[string]*Client),
+		dialing:  make(map[string]*dialCall),
+		services: make(map[string]Discovery),
+		watchers: make(map[string]chan struct{}),
+		stats:    make(map[string]*backendStats),
+		done:     make(chan struct{}),
+
+		breakerCfg: DefaultBreakerConfig,
+		breakers:   make(map[string]*breaker),
+	}
+	go xc.watch(d, nil)
+	if opt != nil && opt.Metrics != nil {
+		opt.Metrics.Register(xc)
+	}
+	return xc
 }
 
 // Close 关闭 XClient，释放底层的客户端连接
 func (xc *XClient) Close() error {
 	xc.mu.Lock()
 	defer xc.mu.Unlock()
+	select {
+	case <-xc.done:
+	default:
+		close(xc.done)
+		for service, stop := range xc.watchers {
+			close(stop)
+			delete(xc.watchers, service)
+		}
+		if xc.opt != nil && xc.opt.Metrics != nil {
+			xc.opt.Metrics.Unregister(xc)
+		}
+	}
 	for key, client := range xc.clients {
 		// 忽略错误，关闭客户端连接
 		_ = client.Close()
@@ -37,29 +92,175 @@ func (xc *XClient) Close() error {
 	return nil
 }
 
-// dial 根据给定的 RPC 地址创建一个客户端连接
-func (xc *XClient) dial(rpcAddr string) (*Client, error) {
+// SetLogger 设置 XClient 建立的客户端使用的 Logger，应在发起调用之前调用
+func (xc *XClient) SetLogger(l Logger) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	opt := *DefaultOption
+	if xc.opt != nil {
+		opt = *xc.opt
+	}
+	opt.Logger = l
+	xc.opt = &opt
+}
+
+// SetEventBus 设置 XClient 及其建立的客户端发布事件使用的 EventBus，应在发起调用之前调用
+func (xc *XClient) SetEventBus(b *EventBus) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	opt := *DefaultOption
+	if xc.opt != nil {
+		opt = *xc.opt
+	}
+	opt.Events = b
+	xc.opt = &opt
+}
+
+// eject 记录一次剔除，并在 opt 设置了 EventBus 时发布 BackendEjected 事件
+func (xc *XClient) eject(rpcAddr, reason string, opt *Option) {
+	xc.backend(rpcAddr).eject()
+	if opt != nil {
+		opt.Events.Publish(BackendEjected{Time: time.Now(), Addr: rpcAddr, Reason: reason})
+	}
+}
+
+// SetServiceDiscovery 为指定服务设置独立的服务发现，
+// 之后 "<service>.*" 的调用只会路由到该 Discovery 返回的服务器，
+// 使一个 XClient 可以同时访问多组提供不同服务的服务器。d 为 nil 时恢复使用默认的 Discovery。
+// 被替换或移除的 Discovery 不再被监听，只由它提供的服务器的连接随后被关闭
+func (xc *XClient) SetServiceDiscovery(service string, d Discovery) {
 	xc.mu.Lock()
 	defer xc.mu.Unlock()
+	if stop, ok := xc.watchers[service]; ok {
+		close(stop)
+		delete(xc.watchers, service)
+	}
+	if d == nil {
+		delete(xc.services, service)
+	} else {
+		stop := make(chan struct{})
+		xc.services[service] = d
+		xc.watchers[service] = stop
+		go xc.watch(d, stop)
+	}
+	go xc.reconcile()
+}
+
+// discovery 返回 serviceMethod 所属服务对应的 Discovery
+func (xc *XClient) discovery(serviceMethod string) Discovery {
+	service := serviceMethod
+	if dot := strings.LastIndex(serviceMethod, "."); dot >= 0 {
+		service = serviceMethod[:dot]
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if d, ok := xc.services[service]; ok {
+		return d
+	}
+	return xc.d
+}
+
+// CloseGraceful 优雅地关闭 XClient：先停止选择新的服务器，
+// 再最多等待 timeout 让正在进行的调用完成，最后关闭所有客户端连接。
+// timeout 为 0 表示一直等待，等待超时时仍会关闭连接并返回错误
+func (xc *XClient) CloseGraceful(timeout time.Duration) error {
+	xc.mu.Lock()
+	xc.closing = true
+	xc.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		xc.inflight.Wait()
+		close(done)
+	}()
+	var err error
+	if timeout == 0 {
+		<-done
+	} else {
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			err = fmt.Errorf("rpc xclient: close timeout: calls still in flight after %s", timeout)
+		}
+	}
+	_ = xc.Close()
+	return err
+}
+
+// begin 登记一次新的调用，XClient 正在关闭时返回 ErrShutdown
+func (xc *XClient) begin() error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if xc.closing {
+		return ErrShutdown
+	}
+	xc.inflight.Add(1)
+	return nil
+}
+
+// dialCall 是一次正在进行的连接，同一地址上并发的 dial 等待同一次连接的结果
+type dialCall struct {
+	done   chan struct{}
+	client *Client
+	err    error
+}
+
+// dial 返回给定 RPC 地址的客户端连接，没有可用的连接时建立新连接。
+// 建立连接时不持有 xc.mu，一个连接缓慢的服务器不会阻塞其他服务器上的调用
+func (xc *XClient) dial(rpcAddr string) (*Client, error) {
+	xc.mu.Lock()
 	client, ok := xc.clients[rpcAddr]
 	if ok && !client.IsAvailable() {
 		_ = client.Close()
 		delete(xc.clients, rpcAddr)
-		client = nil
+		xc.eject(rpcAddr, "unavailable", xc.opt)
+		ok = false
+	}
+	if ok {
+		xc.mu.Unlock()
+		return client, nil
+	}
+	if dc, ok := xc.dialing[rpcAddr]; ok {
+		xc.mu.Unlock()
+		<-dc.done
+		return dc.client, dc.err
+	}
+	select {
+	case <-xc.done:
+		xc.mu.Unlock()
+		return nil, ErrShutdown
+	default:
 	}
-	if client == nil {
-		var err error
-		client, err = XDial(rpcAddr, xc.opt)
-		if err != nil {
-			return nil, err
+	dc := &dialCall{done: make(chan struct{})}
+	xc.dialing[rpcAddr] = dc
+	opt := xc.opt
+	xc.mu.Unlock()
+
+	dc.client, dc.err = XDial(rpcAddr, opt)
+	xc.mu.Lock()
+	delete(xc.dialing, rpcAddr)
+	if dc.err == nil {
+		select {
+		case <-xc.done:
+			// XClient 在建立连接期间被关闭，Close 不会再关闭这个连接
+			_ = dc.client.Close()
+			dc.client, dc.err = nil, ErrShutdown
+		default:
+			xc.clients[rpcAddr] = dc.client
 		}
-		xc.clients[rpcAddr] = client
 	}
-	return client, nil
+	xc.mu.Unlock()
+	close(dc.done)
+	return dc.client, dc.err
 }
 
 // call 调用指定的服务方法
-func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		xc.backend(rpcAddr).observe(time.Since(start), err)
+		xc.recordBreaker(ctx, rpcAddr, err)
+	}()
 	client, err := xc.dial(rpcAddr)
 	if err != nil {
 		return err
@@ -67,9 +268,109 @@ func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod strin
 	return client.Call(ctx, serviceMethod, args, reply)
 }
 
+// pick 根据选择模式选择一个服务器。熔断器处于打开状态的服务器先被排除，选择模式只作用于其余的服务器，
+// 因此只要还有熔断器关闭的后端，就不会返回 ErrAllBreakersOpen
+func (xc *XClient) pick(ctx context.Context, serviceMethod string) (string, error) {
+	d := xc.discovery(serviceMethod)
+	servers, err := d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if rpcAddr, ok, err := xc.pickByAffinity(ctx, d, serviceMethod, servers); ok {
+		return rpcAddr, err
+	}
+	var allowed, skipped []string
+	for _, rpcAddr := range servers {
+		if xc.breaker(rpcAddr).ready() {
+			allowed = append(allowed, rpcAddr)
+		} else {
+			skipped = append(skipped, rpcAddr)
+		}
+	}
+	for first := true; first || len(allowed) > 0; first = false {
+		var rpcAddr string
+		if len(skipped) == 0 {
+			// 没有被熔断的后端时由 Discovery 按自己的策略选择，服务器列表为空时返回 Discovery 自己的错误
+			if rpcAddr, err = d.Get(xc.mode); err != nil {
+				return "", err
+			}
+		} else if len(allowed) > 0 {
+			rpcAddr = xc.selectFrom(d, allowed)
+		} else {
+			break
+		}
+		if xc.breaker(rpcAddr).allow() {
+			xc.notifyPick(d, serviceMethod, rpcAddr, servers, skipped)
+			return rpcAddr, nil
+		}
+		// 半开状态的探测名额被并发的调用占用，从候选中移除后重新选择
+		allowed = removeString(allowed, rpcAddr)
+		skipped = append(skipped, rpcAddr)
+	}
+	return "", ErrAllBreakersOpen
+}
+
+// selectFrom 按选择模式从 candidates 中选择一个服务器，用于部分后端被熔断时在其余的后端中选择。
+// Discovery 提供权重或负载信息时按它们选择，否则随机选择
+func (xc *XClient) selectFrom(d Discovery, candidates []string) string {
+	switch xc.mode {
+	case RoundRobinSelect:
+		n := atomic.AddUint32(&xc.rrIndex, 1)
+		return candidates[int(n%uint32(len(candidates)))]
+	case WeightedRandomSelect:
+		if w, ok := d.(interface{ Weight(string) int }); ok {
+			total := 0
+			for _, s := range candidates {
+				total += w.Weight(s)
+			}
+			if total > 0 {
+				n := rand.Intn(total)
+				for _, s := range candidates {
+					if n -= w.Weight(s); n < 0 {
+						return s
+					}
+				}
+			}
+		}
+	case LeastLoadSelect:
+		if l, ok := d.(interface {
+			Load(string) (registry.Load, bool)
+		}); ok {
+			a, b := candidates[rand.Intn(len(candidates))], candidates[rand.Intn(len(candidates))]
+			la, _ := l.Load(a)
+			lb, _ := l.Load(b)
+			if lessLoaded(lb, la) {
+				return b
+			}
+			return a
+		}
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// removeString 返回删除了 s 的 list
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 // Call 调用指定的服务方法，XClient 会选择一个合适的服务器进行调用
 func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
-	rpcAddr, err := xc.d.Get(xc.mode)
+	if err := xc.begin(); err != nil {
+		return err
+	}
+	defer xc.inflight.Done()
+	return xc.chain(xc.invoke)(ctx, serviceMethod, args, reply)
+}
+
+// invoke 选择一个服务器并发起调用，是拦截器链的最内层
+func (xc *XClient) invoke(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	rpcAddr, err := xc.pick(ctx, serviceMethod)
 	if err != nil {
 		return err
 	}
@@ -78,26 +379,59 @@ func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply i
 
 // Broadcast 对注册在发现服务中的所有服务器调用指定的服务方法
 func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
-	servers, err := xc.d.GetAll()
+	if err := xc.begin(); err != nil {
+		return err
+	}
+	defer xc.inflight.Done()
+	return xc.chain(xc.broadcast)(ctx, serviceMethod, args, reply)
+}
+
+// broadcast 是 Broadcast 拦截器链的最内层
+func (xc *XClient) broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	servers, err := xc.discovery(serviceMethod).GetAll()
 	if err != nil {
 		return err
 	}
+	xc.mu.Lock()
+	limit, policy := xc.broadcastLimit, xc.broadcastPolicy
+	xc.mu.Unlock()
+	var sem chan struct{} // 限制同时进行的调用数
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+
 	var wg sync.WaitGroup
-	var mu sync.Mutex // 保护 e 和 replyDone
+	var mu sync.Mutex // 保护 e、failed 和 replyDone
 	var e error
+	failed := make(map[string]error)
 	replyDone := reply == nil // 如果 reply 为 nil，则无需设置值
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	for _, rpcAddr := range servers {
 		wg.Add(1)
 		go func(rpcAddr string) {
 			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					mu.Lock()
+					failed[rpcAddr] = ctx.Err()
+					mu.Unlock()
+					return
+				}
+			}
 			var clonedReply interface{}
 			if reply != nil {
 				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
 			}
 			err := xc.call(rpcAddr, ctx, serviceMethod, args, clonedReply)
 			mu.Lock()
-			if err != nil && e == nil {
+			if err != nil {
+				failed[rpcAddr] = err
+			}
+			if err != nil && e == nil && policy == FailFast {
 				e = err
 				cancel() // 如果任何调用失败，取消未完成的调用
 			}
@@ -109,5 +443,8 @@ func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, re
 		}(rpcAddr)
 	}
 	wg.Wait()
+	if policy == BestEffort && len(failed) > 0 {
+		return &BroadcastError{Succeeded: len(servers) - len(failed), Failed: failed}
+	}
 	return e
 }
@@ -0,0 +1,261 @@
+package xclient
+
+import (
+	"context"
+	"geerpc"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startArith 启动一个注册了 Arith 的服务器，返回它的 RPC 地址
+func startArith(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := geerpc.NewServer()
+	server.SetConnRateLimit(0, 0)
+	_ = server.Register(new(Arith))
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return "tcp@" + l.Addr().String()
+}
+
+func TestXClient_DialOutsideLock(t *testing.T) {
+	addr := startArith(t)
+	slow, err := geerpc.ListenInProc("xclient-slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := xc.dial("inproc@xclient-slow")
+		dialed <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// 连接缓慢的服务器不影响其他服务器上的调用
+	start := time.Now()
+	var reply int
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3, got %d, err %v", reply, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("call was blocked by a pending dial for %v", d)
+	}
+
+	// 连接在 Close 之后才完成时被关闭，不会留在 XClient 中
+	_ = xc.Close()
+	conn, err := slow.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _, _ = io.Copy(ioutil.Discard, conn) }()
+	if err := <-dialed; err != geerpc.ErrShutdown {
+		t.Fatalf("expect ErrShutdown for a dial that finished after Close, got %v", err)
+	}
+	xc.mu.Lock()
+	n := len(xc.clients)
+	xc.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expect no clients after Close, got %d", n)
+	}
+}
+
+func TestAggregateDiscovery_Close(t *testing.T) {
+	src := NewMultiServerDiscovery([]string{"tcp@a"})
+	d := NewAggregateDiscovery(MergeSources, src)
+	ch := d.Changes()
+	_ = src.Update([]string{"tcp@a", "tcp@b"})
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("expect a change after the source is updated")
+	}
+	_ = d.Close()
+	ch = d.Changes()
+	_ = src.Update([]string{"tcp@a"})
+	select {
+	case <-ch:
+		t.Fatal("expect no changes to be followed after Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestXClient_CloseGraceful(t *testing.T) {
+	addr := startNode(t, &Node{delay: 200 * time.Millisecond})
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+
+	called := make(chan error, 1)
+	go func() {
+		var reply int
+		called <- xc.Call(context.Background(), "Node.Echo", 1, &reply)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- xc.CloseGraceful(time.Second) }()
+	time.Sleep(20 * time.Millisecond)
+
+	// 关闭期间拒绝新的调用，正在进行的调用照常完成
+	var reply int
+	if err := xc.Call(context.Background(), "Node.Echo", 2, &reply); err != geerpc.ErrShutdown {
+		t.Fatalf("expect ErrShutdown for a call made while closing, got %v", err)
+	}
+	select {
+	case err := <-closed:
+		t.Fatalf("expect CloseGraceful to wait for the call in flight, returned %v", err)
+	default:
+	}
+	if err := <-called; err != nil {
+		t.Fatalf("expect the call in flight to complete, got %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("expect CloseGraceful to succeed, got %v", err)
+	}
+	xc.mu.Lock()
+	n := len(xc.clients)
+	xc.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expect no clients after CloseGraceful, got %d", n)
+	}
+}
+
+func TestXClient_CloseGracefulTimeout(t *testing.T) {
+	addr := startNode(t, &Node{delay: time.Second})
+	xc := NewXClient(NewMultiServerDiscovery([]string{addr}), RandomSelect, nil)
+
+	called := make(chan error, 1)
+	go func() {
+		var reply int
+		called <- xc.Call(context.Background(), "Node.Echo", 1, &reply)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 超时后仍然关闭连接，正在进行的调用因连接关闭而失败
+	if err := xc.CloseGraceful(50 * time.Millisecond); err == nil {
+		t.Fatal("expect an error when calls are still in flight after the timeout")
+	}
+	select {
+	case err := <-called:
+		if err == nil {
+			t.Fatal("expect the call in flight to fail once the connection is closed")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expect the call in flight to end when the connection is closed")
+	}
+}
+
+func TestXClient_SetServiceDiscovery(t *testing.T) {
+	arith := startArith(t)
+	node := startNode(t, &Node{})
+	xc := NewXClient(NewMultiServerDiscovery([]string{arith}), RandomSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetServiceDiscovery("Node", NewMultiServerDiscovery([]string{node}))
+
+	// 每个服务的调用只路由到该服务的服务器
+	var reply int
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("expect 3 from %s, got %d, err %v", arith, reply, err)
+	}
+	if err := xc.Broadcast(context.Background(), "Node.Echo", 5, &reply); err != nil || reply != 5 {
+		t.Fatalf("expect 5 from %s, got %d, err %v", node, reply, err)
+	}
+	stats := xc.Stats()
+	if len(stats) != 2 || stats[arith].Requests != 1 || stats[node].Requests != 1 {
+		t.Fatalf("expect one call on each pool, got %v", stats)
+	}
+
+	// 传入 nil 后恢复使用默认的 Discovery
+	xc.SetServiceDiscovery("Node", nil)
+	if err := xc.Call(context.Background(), "Node.Echo", 5, &reply); err == nil {
+		t.Fatal("expect Node.Echo to be routed to the default pool, which does not serve it")
+	}
+	if n := xc.Stats()[arith].Requests; n != 2 {
+		t.Fatalf("expect the call to go to %s, got %d requests there", arith, n)
+	}
+}
+
+// waitGoroutines 等待协程数回落到 n 以内
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect at most %d goroutines, got %d", n, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestXClient_SetServiceDiscoveryStopsWatch(t *testing.T) {
+	base := runtime.NumGoroutine()
+	xc := NewXClient(NewMultiServerDiscovery(nil), RandomSelect, nil)
+	withDefault := runtime.NumGoroutine()
+
+	// 被替换和移除的 Discovery 的监听协程退出
+	for i := 0; i < 50; i++ {
+		xc.SetServiceDiscovery("Node", NewMultiServerDiscovery(nil))
+	}
+	waitGoroutines(t, withDefault+1)
+	xc.SetServiceDiscovery("Node", nil)
+	waitGoroutines(t, withDefault)
+
+	// Close 停止所有监听协程
+	xc.SetServiceDiscovery("Node", NewMultiServerDiscovery(nil))
+	_ = xc.Close()
+	waitGoroutines(t, base)
+}
+
+func TestXClient_SetEventBus(t *testing.T) {
+	var mu sync.Mutex
+	var ejected []geerpc.BackendEjected
+	bus := geerpc.NewEventBus()
+	bus.Subscribe(func(e geerpc.Event) {
+		if e, ok := e.(geerpc.BackendEjected); ok {
+			mu.Lock()
+			defer mu.Unlock()
+			ejected = append(ejected, e)
+		}
+	})
+	live := startArith(t)
+	dead := "tcp@127.0.0.1:1" // 拒绝连接
+	xc := NewXClient(NewMultiServerDiscovery([]string{live, dead}), RoundRobinSelect, nil)
+	defer func() { _ = xc.Close() }()
+	xc.SetEventBus(bus)
+	xc.SetBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1})
+
+	// 连接失败使 dead 的熔断器打开
+	var reply int
+	for i := 0; i < 2; i++ {
+		_ = xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply)
+	}
+	// 缓存的客户端断开后被剔除
+	xc.mu.Lock()
+	_ = xc.clients[live].Close()
+	xc.mu.Unlock()
+	if err := xc.Call(context.Background(), "Arith.Sum", ArithArgs{Num1: 1, Num2: 2}, &reply); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	reasons := make(map[string]string)
+	for _, e := range ejected {
+		reasons[e.Addr] = e.Reason
+		if e.Time.IsZero() {
+			t.Fatalf("expect the event time to be set, got %+v", e)
+		}
+	}
+	if len(ejected) != 2 || reasons[dead] != "circuit-open" || reasons[live] != "unavailable" {
+		t.Fatalf("expect %s circuit-open and %s unavailable, got %+v", dead, live, ejected)
+	}
+}